@@ -0,0 +1,183 @@
+// Command audit-verify checks the audit log archives in write-once storage.
+//
+// By default every archive recorded in the database is verified: the manifest
+// signature, the archive digest, the chain of archives, the Object Lock retention of
+// both objects and that the audit log table still holds exactly the archived
+// entries. With -manifest and -object an archive downloaded from the bucket is
+// checked offline, without database or bucket access:
+//
+//	audit-verify -public-key BASE64
+//	audit-verify -public-key BASE64 -manifest a.jsonl.gz.manifest.json -object a.jsonl.gz
+//
+// -generate-key prints a new signing key for AUDIT_ARCHIVE_SIGNING_KEY and the
+// public key auditors verify archives with.
+package main
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/hillmatthew2000/HealthHub/internal/audit"
+	"github.com/hillmatthew2000/HealthHub/internal/config"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/transfer"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+func main() {
+	cfg := config.Load()
+
+	publicKey := flag.String("public-key", "", "base64 Ed25519 public key; derived from AUDIT_ARCHIVE_SIGNING_KEY when empty")
+	manifestFile := flag.String("manifest", "", "verify this downloaded manifest offline")
+	objectFile := flag.String("object", "", "archive object belonging to -manifest")
+	generateKey := flag.Bool("generate-key", false, "print a new signing key and its public key")
+	flag.Parse()
+
+	logger.Init("warn")
+	defer logger.Sync()
+
+	var err error
+	switch {
+	case *generateKey:
+		err = printKey()
+	case *manifestFile != "":
+		err = verifyFiles(cfg, *publicKey, *manifestFile, *objectFile)
+	default:
+		err = verifyArchives(cfg, *publicKey)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "audit-verify:", err)
+		os.Exit(1)
+	}
+}
+
+func printKey() error {
+	public, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return err
+	}
+	fmt.Println("AUDIT_ARCHIVE_SIGNING_KEY=" + base64.StdEncoding.EncodeToString(private.Seed()))
+	fmt.Println("public key: " + base64.StdEncoding.EncodeToString(public))
+	return nil
+}
+
+// verificationKey returns the public key archives are verified with
+func verificationKey(cfg *config.Config, publicKey string) (ed25519.PublicKey, error) {
+	if publicKey != "" {
+		return audit.ParsePublicKey(publicKey)
+	}
+	if cfg.AuditArchiveSigningKey == "" {
+		return nil, fmt.Errorf("no public key given; pass -public-key or set AUDIT_ARCHIVE_SIGNING_KEY")
+	}
+	key, err := audit.ParseSigningKey(cfg.AuditArchiveSigningKey)
+	if err != nil {
+		return nil, err
+	}
+	return key.Public().(ed25519.PublicKey), nil
+}
+
+func verifyFiles(cfg *config.Config, publicKey, manifestFile, objectFile string) error {
+	key, err := verificationKey(cfg, publicKey)
+	if err != nil {
+		return err
+	}
+	data, err := os.ReadFile(manifestFile)
+	if err != nil {
+		return err
+	}
+	manifest, err := audit.VerifyManifest(data, key)
+	if err != nil {
+		return err
+	}
+
+	if objectFile != "" {
+		object, err := os.ReadFile(objectFile)
+		if err != nil {
+			return err
+		}
+		if err := audit.VerifyObject(manifest, object); err != nil {
+			return err
+		}
+	}
+
+	fmt.Printf("OK  %s  entries %d-%d (%d)\n", manifest.Object, manifest.FirstEntryID, manifest.LastEntryID, manifest.Entries)
+	if manifest.Encryption != nil {
+		fmt.Println("    encrypted archive: entries were not checked; decrypt it and verify the entries before relying on them")
+	}
+	return nil
+}
+
+func verifyArchives(cfg *config.Config, publicKey string) error {
+	key, err := verificationKey(cfg, publicKey)
+	if err != nil {
+		return err
+	}
+	if cfg.AuditArchiveBucket == "" {
+		return fmt.Errorf("AUDIT_ARCHIVE_BUCKET is not set")
+	}
+	if err := egress.Configure(egress.Options{
+		Allowlist: cfg.EgressAllowlist,
+		ProxyURL:  cfg.EgressProxyURL,
+		NoProxy:   cfg.EgressNoProxy,
+		CAFile:    cfg.EgressCAFile,
+		Pins:      cfg.EgressTLSPins,
+	}); err != nil {
+		return err
+	}
+	store, err := transfer.NewS3(transfer.Options{
+		Bucket:      cfg.AuditArchiveBucket,
+		Region:      cfg.AuditArchiveRegion,
+		Endpoint:    cfg.AuditArchiveEndpoint,
+		Prefix:      cfg.AuditArchivePrefix,
+		AccessKeyID: cfg.AuditArchiveAccessKeyID,
+		Secret:      cfg.AuditArchiveSecretKey,
+	})
+	if err != nil {
+		return err
+	}
+
+	db, err := database.Open(cfg.DatabaseURL)
+	if err != nil {
+		return err
+	}
+	defer database.CloseDB(db)
+	db.Logger = gormlogger.Default.LogMode(gormlogger.Warn)
+
+	var archives []models.AuditArchive
+	if err := db.Order("last_entry_id").Find(&archives).Error; err != nil {
+		return fmt.Errorf("failed to list audit archives: %w", err)
+	}
+
+	verifier := audit.NewVerifier(db, store, key)
+	failed := 0
+	previous := ""
+	var lastEntryID uint64
+	for _, archive := range archives {
+		err := verifier.Verify(context.Background(), archive, previous)
+		if err == nil && archive.FirstEntryID <= lastEntryID {
+			err = fmt.Errorf("entries overlap the previous archive")
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("FAIL  %s  %v\n", archive.ObjectKey, err)
+		} else {
+			fmt.Printf("OK    %s  entries %d-%d (%d)\n", archive.ObjectKey, archive.FirstEntryID, archive.LastEntryID, archive.Entries)
+		}
+		previous = archive.SHA256
+		lastEntryID = archive.LastEntryID
+	}
+
+	fmt.Printf("%d archives verified, %d failed\n", len(archives), failed)
+	if failed > 0 {
+		return fmt.Errorf("%d archives failed verification", failed)
+	}
+	return nil
+}
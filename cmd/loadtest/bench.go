@@ -0,0 +1,268 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"regexp"
+	"runtime"
+	"sort"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
+	"github.com/hillmatthew2000/HealthHub/internal/handlers"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/validation"
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// pageSize is the number of records serialized per benchmark operation, matching a
+// full page of a list endpoint
+const pageSize = 100
+
+// benchmark is a named hot path measurement
+type benchmark struct {
+	name string
+	fn   func(b *testing.B)
+}
+
+func runBench(args []string) error {
+	// testing.Benchmark reads its settings from the test flags
+	testing.Init()
+
+	fs := flag.NewFlagSet("bench", flag.ExitOnError)
+	filter := fs.String("bench", ".", "run only benchmarks matching this regular expression")
+	benchtime := fs.String("benchtime", "1s", "run time or iteration count (e.g. 100x) per benchmark")
+	count := fs.Int("count", 1, "run each benchmark this many times")
+	out := fs.String("out", "", "also write the results to this file")
+	fs.Parse(args)
+
+	match, err := regexp.Compile(*filter)
+	if err != nil {
+		return fmt.Errorf("invalid -bench expression: %w", err)
+	}
+	if err := flag.Set("test.benchtime", *benchtime); err != nil {
+		return fmt.Errorf("invalid -benchtime: %w", err)
+	}
+
+	benchmarks, err := hotPaths()
+	if err != nil {
+		return err
+	}
+
+	w := io.Writer(os.Stdout)
+	if *out != "" {
+		f, err := os.Create(*out)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		w = io.MultiWriter(os.Stdout, f)
+	}
+
+	fmt.Fprintf(w, "goos: %s\ngoarch: %s\npkg: github.com/hillmatthew2000/HealthHub/cmd/loadtest\n", runtime.GOOS, runtime.GOARCH)
+	for _, bm := range benchmarks {
+		if !match.MatchString(bm.name) {
+			continue
+		}
+		for i := 0; i < *count; i++ {
+			result := testing.Benchmark(bm.fn)
+			if result.N == 0 {
+				return fmt.Errorf("benchmark %s failed", bm.name)
+			}
+			fmt.Fprintf(w, "Benchmark%s-%d\t%s\t%s\n", bm.name, runtime.GOMAXPROCS(0), result.String(), result.MemString())
+		}
+	}
+	return nil
+}
+
+// hotPaths returns the benchmarks of the query builders and serializers used by
+// the busiest endpoints. Queries are built against a dry-run connection, which
+// generates SQL without sending it, so no database is needed.
+func hotPaths() ([]benchmark, error) {
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               gormlogger.Default.LogMode(gormlogger.Silent),
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open dry-run connection: %w", err)
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	patientHandler := handlers.NewPatientHandler(db, nil, validation.NewProfileService(db), nil, 0)
+	router.GET("/api/v1/patients", patientHandler.GetPatients)
+
+	now := time.Now().UTC()
+	patients := make([]models.Patient, pageSize)
+	observations := make([]models.Observation, pageSize)
+	for i := range patients {
+		patients[i] = samplePatient(i, now)
+		observations[i] = sampleObservation(patients[i].ID, now.Add(-time.Duration(i)*time.Hour))
+		observations[i].ID = fmt.Sprintf("00000000-0000-4000-8000-%012d", i)
+		observations[i].ReferenceRange = []models.ReferenceRange{{
+			Low:  &models.Quantity{Value: 60, Unit: "/min"},
+			High: &models.Quantity{Value: 100, Unit: "/min"},
+		}}
+	}
+
+	ingest, err := json.Marshal(observations[0])
+	if err != nil {
+		return nil, err
+	}
+	validate := validator.New()
+
+	return []benchmark{
+		{"PatientSearchQuery", func(b *testing.B) {
+			b.ReportAllocs()
+			target := "/api/v1/patients?search=smith&gender=female&active=true&age=ge18&age=lt65&birthdate=ge1950-01-01&language=es&limit=20"
+			for i := 0; i < b.N; i++ {
+				w := httptest.NewRecorder()
+				router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, target, nil))
+				if w.Code != http.StatusOK {
+					b.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+				}
+			}
+		}},
+		{"ObservationFilterQuery", func(b *testing.B) {
+			b.ReportAllocs()
+			filter := models.ObservationFilter{
+				Patient:  patients[0].ID,
+				Status:   "final",
+				Category: "vital-signs",
+				Code:     "8867-4",
+				From:     "2024-01-01",
+				To:       "2024-12-31",
+			}
+			for i := 0; i < b.N; i++ {
+				var found []models.Observation
+				stmt := filter.Apply(db.Model(&models.Observation{})).
+					Order("effective_date_time DESC").Limit(20).Find(&found).Statement
+				if stmt.SQL.Len() == 0 {
+					b.Fatal("no SQL generated")
+				}
+			}
+		}},
+		{"PatientListJSON", func(b *testing.B) {
+			b.ReportAllocs()
+			page := handlers.PaginatedResponse{Data: patients, Total: pageSize, Page: 1, Limit: pageSize, TotalPages: 1}
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(page); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		{"PatientBundleFHIR", func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				entries := make([]fhir.BundleEntry, 0, len(patients))
+				for j := range patients {
+					entries = append(entries, fhir.BundleEntry{
+						FullURL:  "Patient/" + patients[j].ID,
+						Resource: fhir.FromPatient(&patients[j]),
+					})
+				}
+				if _, err := json.Marshal(fhir.NewSearchBundle(pageSize, entries)); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		{"ObservationListJSON", func(b *testing.B) {
+			b.ReportAllocs()
+			page := handlers.PaginatedResponse{Data: observations, Total: pageSize, Page: 1, Limit: pageSize, TotalPages: 1}
+			for i := 0; i < b.N; i++ {
+				if _, err := json.Marshal(page); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+		// Each pair serves a full page through the list response path with the
+		// hand-written encoders off and on, reporting allocations and p99 latency
+		{"PatientListPage", func(b *testing.B) { servePage(b, patients, false) }},
+		{"PatientListPageFast", func(b *testing.B) { servePage(b, patients, true) }},
+		{"ObservationListPage", func(b *testing.B) { servePage(b, observations, false) }},
+		{"ObservationListPageFast", func(b *testing.B) { servePage(b, observations, true) }},
+		{"ObservationIngestDecode", func(b *testing.B) {
+			b.ReportAllocs()
+			b.SetBytes(int64(len(ingest)))
+			for i := 0; i < b.N; i++ {
+				var observation models.Observation
+				if err := json.Unmarshal(ingest, &observation); err != nil {
+					b.Fatal(err)
+				}
+				if err := validate.Struct(observation); err != nil {
+					b.Fatal(err)
+				}
+			}
+		}},
+	}, nil
+}
+
+// servePage benchmarks writing a page of items as a list response, with or without
+// FAST_LIST_ENCODING, and reports the 99th percentile time of an operation
+func servePage(b *testing.B, items interface{}, fast bool) {
+	b.ReportAllocs()
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/v1/list?page=1&limit=100", nil)
+	handlers.FastListEncodingMiddleware(fast)(c)
+	latencies := make([]time.Duration, b.N)
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		started := time.Now()
+		w.Body.Reset()
+		c.Writer.Header().Del("Vary")
+		handlers.RespondPage(c, items, pageSize, 1, pageSize)
+		latencies[i] = time.Since(started)
+	}
+	b.StopTimer()
+
+	if w.Code != http.StatusOK || w.Body.Len() == 0 {
+		b.Fatalf("unexpected status %d: %s", w.Code, w.Body.String())
+	}
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+	b.ReportMetric(float64(latencies[(len(latencies)*99)/100].Nanoseconds()), "p99-ns/op")
+}
+
+// samplePatient returns a fully populated synthetic patient
+func samplePatient(i int, now time.Time) models.Patient {
+	return models.Patient{
+		ID:     fmt.Sprintf("00000000-0000-4000-a000-%012d", i),
+		Active: true,
+		Identifier: []models.Identifier{{
+			Use: "official", System: "urn:oid:2.16.840.1.113883.4.1", Value: fmt.Sprintf("MRN%07d", i),
+		}},
+		Name: []models.Name{{
+			Use: "official", Family: "Smith", Given: []string{"Jordan", "Lee"}, Prefix: []string{"Dr."},
+		}},
+		Gender:    "female",
+		BirthDate: time.Date(1950+i%60, time.Month(1+i%12), 1+i%28, 0, 0, 0, 0, time.UTC),
+		Telecom: []models.Contact{
+			{System: "phone", Value: "+1-202-555-0100", Use: "mobile", Rank: 1},
+			{System: "email", Value: fmt.Sprintf("patient%d@example.com", i), Use: "home"},
+		},
+		Address: []models.Address{{
+			Use: "home", Type: "physical", Line: []string{strings.Repeat("1", 1+i%4) + " Main Street"},
+			City: "Springfield", State: "IL", PostalCode: "62701", Country: "US",
+		}},
+		Communication: []models.PatientCommunication{{
+			Language:  models.CodeableConcept{Coding: []models.Coding{{System: "urn:ietf:bcp:47", Code: "es"}}},
+			Preferred: true,
+		}},
+		CreatedAt: now,
+		UpdatedAt: now,
+		CreatedBy: "loadtest",
+	}
+}
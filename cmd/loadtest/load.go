@@ -0,0 +1,360 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"math/rand"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"sort"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// scenario is one kind of request issued at a fixed rate
+type scenario struct {
+	name string
+	rate float64
+	do   func(ctx context.Context) (int, error)
+}
+
+// ScenarioResult summarizes the requests of one scenario
+type ScenarioResult struct {
+	Name       string         `json:"name"`
+	Rate       float64        `json:"rate"`
+	Requests   int            `json:"requests"`
+	Errors     int            `json:"errors"`
+	Dropped    int            `json:"dropped"` // Not sent because the concurrency limit was reached
+	Throughput float64        `json:"throughput"`
+	P50        float64        `json:"p50Ms"`
+	P90        float64        `json:"p90Ms"`
+	P99        float64        `json:"p99Ms"`
+	Max        float64        `json:"maxMs"`
+	Statuses   map[string]int `json:"statuses"`
+}
+
+// LoadReport is the result of a load test run
+type LoadReport struct {
+	Target    string           `json:"target"`
+	StartedAt time.Time        `json:"startedAt"`
+	Duration  float64          `json:"durationSeconds"`
+	Scenarios []ScenarioResult `json:"scenarios"`
+}
+
+// recorder collects the outcomes of one scenario
+type recorder struct {
+	mu        sync.Mutex
+	latencies []time.Duration
+	errors    int
+	dropped   int
+	statuses  map[string]int
+}
+
+func (r *recorder) record(latency time.Duration, status int, err error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.latencies = append(r.latencies, latency)
+	if err != nil {
+		r.errors++
+	}
+	key := "error"
+	if status > 0 {
+		key = fmt.Sprint(status)
+	}
+	r.statuses[key]++
+}
+
+func (r *recorder) drop() {
+	r.mu.Lock()
+	r.dropped++
+	r.mu.Unlock()
+}
+
+// client issues API requests against the target environment
+type client struct {
+	base  string
+	http  *http.Client
+	token string
+}
+
+func runLoad(args []string) error {
+	fs := flag.NewFlagSet("run", flag.ExitOnError)
+	target := fs.String("target", "http://localhost:8080", "base URL of the environment under test")
+	email := fs.String("email", os.Getenv("LOADTEST_EMAIL"), "account used for the test (LOADTEST_EMAIL)")
+	password := fs.String("password", os.Getenv("LOADTEST_PASSWORD"), "password of the account (LOADTEST_PASSWORD)")
+	duration := fs.Duration("duration", time.Minute, "how long to apply load")
+	loginRate := fs.Float64("login-rate", 1, "logins per second")
+	searchRate := fs.Float64("search-rate", 10, "patient searches per second")
+	ingestRate := fs.Float64("ingest-rate", 5, "observations created per second")
+	terms := fs.String("search-terms", "smith,jo,an,lee,mar", "comma-separated patient search terms")
+	patientID := fs.String("patient", "", "patient that ingested observations belong to (default: create one)")
+	concurrency := fs.Int("concurrency", 50, "maximum requests in flight per scenario")
+	timeout := fs.Duration("timeout", 10*time.Second, "request timeout")
+	maxErrorRate := fs.Float64("max-error-rate", 0.01, "fail when more than this fraction of requests fail")
+	out := fs.String("out", "", "write the JSON report to this file")
+	fs.Parse(args)
+
+	if *email == "" || *password == "" {
+		return fmt.Errorf("-email and -password are required")
+	}
+
+	c := &client{
+		base: strings.TrimRight(*target, "/"),
+		http: &http.Client{
+			Timeout:   *timeout,
+			Transport: &http.Transport{MaxIdleConnsPerHost: *concurrency * 3},
+		},
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	token, _, err := c.login(ctx, *email, *password)
+	if err != nil {
+		return fmt.Errorf("login failed: %w", err)
+	}
+	c.token = token
+
+	if *ingestRate > 0 && *patientID == "" {
+		if *patientID, err = c.createPatient(ctx); err != nil {
+			return fmt.Errorf("failed to create the ingestion patient, pass -patient instead: %w", err)
+		}
+	}
+
+	searchTerms := strings.Split(*terms, ",")
+	scenarios := []scenario{
+		{name: "login", rate: *loginRate, do: func(ctx context.Context) (int, error) {
+			_, status, err := c.login(ctx, *email, *password)
+			return status, err
+		}},
+		{name: "patient_search", rate: *searchRate, do: func(ctx context.Context) (int, error) {
+			term := strings.TrimSpace(searchTerms[rand.Intn(len(searchTerms))])
+			return c.do(ctx, http.MethodGet, "/api/v1/patients?limit=20&search="+url.QueryEscape(term), nil, nil)
+		}},
+		{name: "observation_ingest", rate: *ingestRate, do: func(ctx context.Context) (int, error) {
+			return c.do(ctx, http.MethodPost, "/api/v1/observations", sampleObservation(*patientID, time.Now().UTC()), nil)
+		}},
+	}
+
+	fmt.Fprintf(os.Stderr, "Applying load to %s for %s\n", c.base, *duration)
+	report := drive(ctx, c.base, scenarios, *duration, *concurrency)
+	printReport(report)
+
+	if *out != "" {
+		data, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			return err
+		}
+		if err := os.WriteFile(*out, data, 0o644); err != nil {
+			return err
+		}
+	}
+
+	for _, result := range report.Scenarios {
+		if result.Requests > 0 && float64(result.Errors) > *maxErrorRate*float64(result.Requests) {
+			return fmt.Errorf("%s: %d of %d requests failed", result.Name, result.Errors, result.Requests)
+		}
+	}
+	return nil
+}
+
+// drive issues the requests of each scenario at its rate until the duration has
+// passed. Load is open-loop: a request that would exceed the concurrency limit is
+// dropped and counted rather than delaying the schedule, so an overloaded target
+// shows up as drops instead of a silently lower rate.
+func drive(ctx context.Context, target string, scenarios []scenario, duration time.Duration, concurrency int) *LoadReport {
+	started := time.Now()
+	ctx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	recorders := make([]*recorder, len(scenarios))
+	var requests sync.WaitGroup
+	var schedulers sync.WaitGroup
+	for i, s := range scenarios {
+		recorders[i] = &recorder{statuses: map[string]int{}}
+		if s.rate <= 0 {
+			continue
+		}
+
+		schedulers.Add(1)
+		go func(s scenario, rec *recorder) {
+			defer schedulers.Done()
+			slots := make(chan struct{}, concurrency)
+			ticker := time.NewTicker(time.Duration(float64(time.Second) / s.rate))
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ctx.Done():
+					return
+				case <-ticker.C:
+				}
+
+				select {
+				case slots <- struct{}{}:
+				default:
+					rec.drop()
+					continue
+				}
+
+				requests.Add(1)
+				go func() {
+					defer requests.Done()
+					defer func() { <-slots }()
+					// In-flight requests finish after the test window closes
+					begin := time.Now()
+					status, err := s.do(context.Background())
+					rec.record(time.Since(begin), status, err)
+				}()
+			}
+		}(s, recorders[i])
+	}
+	schedulers.Wait()
+	requests.Wait()
+
+	elapsed := time.Since(started)
+	report := &LoadReport{Target: target, StartedAt: started.UTC(), Duration: elapsed.Seconds()}
+	for i, s := range scenarios {
+		report.Scenarios = append(report.Scenarios, summarize(s, recorders[i], elapsed))
+	}
+	return report
+}
+
+// summarize computes the latency percentiles of a scenario
+func summarize(s scenario, rec *recorder, elapsed time.Duration) ScenarioResult {
+	latencies := rec.latencies
+	sort.Slice(latencies, func(i, j int) bool { return latencies[i] < latencies[j] })
+
+	percentile := func(p float64) float64 {
+		if len(latencies) == 0 {
+			return 0
+		}
+		index := int(p*float64(len(latencies))+0.5) - 1
+		if index < 0 {
+			index = 0
+		}
+		if index >= len(latencies) {
+			index = len(latencies) - 1
+		}
+		return float64(latencies[index]) / float64(time.Millisecond)
+	}
+
+	return ScenarioResult{
+		Name:       s.name,
+		Rate:       s.rate,
+		Requests:   len(latencies),
+		Errors:     rec.errors,
+		Dropped:    rec.dropped,
+		Throughput: float64(len(latencies)) / elapsed.Seconds(),
+		P50:        percentile(0.50),
+		P90:        percentile(0.90),
+		P99:        percentile(0.99),
+		Max:        percentile(1),
+		Statuses:   rec.statuses,
+	}
+}
+
+func printReport(report *LoadReport) {
+	fmt.Printf("target %s, %.0fs\n\n", report.Target, report.Duration)
+	fmt.Printf("%-20s %8s %8s %8s %8s %10s %10s %10s %10s\n",
+		"scenario", "rate", "requests", "errors", "dropped", "p50 ms", "p90 ms", "p99 ms", "max ms")
+	for _, r := range report.Scenarios {
+		fmt.Printf("%-20s %8.1f %8d %8d %8d %10.1f %10.1f %10.1f %10.1f\n",
+			r.Name, r.Rate, r.Requests, r.Errors, r.Dropped, r.P50, r.P90, r.P99, r.Max)
+	}
+}
+
+// do sends a request and decodes a successful JSON response into dest when given.
+// Responses outside the 2xx range are returned as errors.
+func (c *client) do(ctx context.Context, method, path string, body, dest interface{}) (int, error) {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return 0, err
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.base+path, reader)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Accept", "application/json")
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return resp.StatusCode, fmt.Errorf("%s %s: %s: %s", method, path, resp.Status, strings.TrimSpace(string(message)))
+	}
+	if dest != nil {
+		return resp.StatusCode, json.NewDecoder(resp.Body).Decode(dest)
+	}
+	_, err = io.Copy(io.Discard, resp.Body)
+	return resp.StatusCode, err
+}
+
+// login signs in and returns the issued token
+func (c *client) login(ctx context.Context, email, password string) (string, int, error) {
+	anonymous := &client{base: c.base, http: c.http}
+	var response models.AuthResponse
+	status, err := anonymous.do(ctx, http.MethodPost, "/api/v1/auth/login",
+		models.AuthRequest{Email: email, Password: password}, &response)
+	return response.Token, status, err
+}
+
+// createPatient registers a synthetic patient for ingested observations
+func (c *client) createPatient(ctx context.Context) (string, error) {
+	patient := models.Patient{
+		Name:      []models.Name{{Use: "official", Family: "Loadtest", Given: []string{"Synthetic"}}},
+		Gender:    "unknown",
+		BirthDate: time.Date(1970, 1, 1, 0, 0, 0, 0, time.UTC),
+		Telecom:   []models.Contact{},
+		Address:   []models.Address{},
+	}
+	var created models.Patient
+	if _, err := c.do(ctx, http.MethodPost, "/api/v1/patients", patient, &created); err != nil {
+		return "", err
+	}
+	return created.ID, nil
+}
+
+// sampleObservation returns a heart rate observation for a patient
+func sampleObservation(patientID string, effective time.Time) models.Observation {
+	return models.Observation{
+		Status: "final",
+		Category: []models.Category{{Coding: []models.Coding{{
+			System: models.ObservationCategorySystem, Code: "vital-signs", Display: "Vital Signs",
+		}}}},
+		Code: models.CodeableConcept{
+			Coding: []models.Coding{{System: "http://loinc.org", Code: "8867-4", Display: "Heart rate"}},
+		},
+		Subject:           models.Reference{Reference: "Patient/" + patientID},
+		EffectiveDateTime: effective,
+		ValueQuantity: &models.Quantity{
+			Value: float64(55 + rand.Intn(50)), Unit: "/min", System: "http://unitsofmeasure.org", Code: "/min",
+		},
+	}
+}
@@ -0,0 +1,49 @@
+// Command loadtest exercises a running HealthHub environment and benchmarks the
+// request hot paths.
+//
+// The run subcommand drives login, patient search and observation ingestion at
+// fixed request rates against a target environment and reports latency
+// percentiles per scenario:
+//
+//	loadtest run -target https://staging.example.com -email loadtest@example.com \
+//		-password ... -duration 2m -search-rate 20 -ingest-rate 10 -out load.json
+//
+// The bench subcommand runs Go benchmarks of the query builders and response
+// serialization without a database, printing results in the go test format so
+// they can be compared with benchstat:
+//
+//	loadtest bench -benchtime 2s -out bench.txt
+package main
+
+import (
+	"fmt"
+	"os"
+)
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+		os.Exit(2)
+	}
+
+	var err error
+	switch os.Args[1] {
+	case "run":
+		err = runLoad(os.Args[2:])
+	case "bench":
+		err = runBench(os.Args[2:])
+	default:
+		usage()
+		os.Exit(2)
+	}
+
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "loadtest:", err)
+		os.Exit(1)
+	}
+}
+
+func usage() {
+	fmt.Fprintln(os.Stderr, "usage: loadtest run [flags]   drive load against a target environment")
+	fmt.Fprintln(os.Stderr, "       loadtest bench [flags] run the hot path benchmarks")
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"context"
+	"flag"
 	"net/http"
 	"os"
 	"os/signal"
@@ -9,17 +10,71 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/audit"
 	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/backup"
+	"github.com/hillmatthew2000/HealthHub/internal/billing"
+	"github.com/hillmatthew2000/HealthHub/internal/bulk"
+	"github.com/hillmatthew2000/HealthHub/internal/capture"
 	"github.com/hillmatthew2000/HealthHub/internal/config"
+	"github.com/hillmatthew2000/HealthHub/internal/dashboard"
+	"github.com/hillmatthew2000/HealthHub/internal/deid"
+	"github.com/hillmatthew2000/HealthHub/internal/deltacheck"
+	"github.com/hillmatthew2000/HealthHub/internal/escalation"
+	"github.com/hillmatthew2000/HealthHub/internal/events"
+	"github.com/hillmatthew2000/HealthHub/internal/export"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
+	"github.com/hillmatthew2000/HealthHub/internal/fixtures"
 	"github.com/hillmatthew2000/HealthHub/internal/handlers"
+	"github.com/hillmatthew2000/HealthHub/internal/inbound"
+	"github.com/hillmatthew2000/HealthHub/internal/indexing"
+	"github.com/hillmatthew2000/HealthHub/internal/integrity"
+	"github.com/hillmatthew2000/HealthHub/internal/interaction"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/internal/qc"
+	"github.com/hillmatthew2000/HealthHub/internal/quarantine"
+	"github.com/hillmatthew2000/HealthHub/internal/retention"
+	"github.com/hillmatthew2000/HealthHub/internal/standingorder"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/internal/usage"
+	"github.com/hillmatthew2000/HealthHub/internal/validation"
+	"github.com/hillmatthew2000/HealthHub/internal/warmup"
+	"github.com/hillmatthew2000/HealthHub/internal/writecheck"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+	"github.com/hillmatthew2000/HealthHub/pkg/cluster"
+	"github.com/hillmatthew2000/HealthHub/pkg/cursor"
 	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/disclosure"
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/encryption"
+	"github.com/hillmatthew2000/HealthHub/pkg/geocoding"
+	"github.com/hillmatthew2000/HealthHub/pkg/loadshed"
 	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/metrics"
+	"github.com/hillmatthew2000/HealthHub/pkg/notify"
+	"github.com/hillmatthew2000/HealthHub/pkg/opensearch"
+	"github.com/hillmatthew2000/HealthHub/pkg/recovery"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+	"github.com/hillmatthew2000/HealthHub/pkg/sanitize"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"github.com/hillmatthew2000/HealthHub/pkg/timeout"
+	"github.com/hillmatthew2000/HealthHub/pkg/transfer"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
 	"go.uber.org/zap"
+	"gorm.io/gorm"
 )
 
 func main() {
+	standalone := flag.Bool("standalone", false, "run with an embedded SQLite database and local storage under DATA_DIR, without external services")
+	flag.Parse()
+
 	// Load configuration
 	cfg := config.Load()
+	if *standalone || cfg.Standalone {
+		cfg.ApplyStandalone()
+	}
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
@@ -38,29 +93,129 @@ func main() {
 		zap.String("version", "1.0.0"),
 		zap.String("environment", cfg.Environment),
 		zap.String("port", cfg.Port),
+		zap.Bool("standalone", cfg.Standalone),
 	)
 
+	// Guard calls to external dependencies; set before any client is created
+	resilience.DefaultPolicy.Timeout = time.Duration(cfg.ExternalTimeoutSeconds) * time.Second
+	resilience.DefaultPolicy.MaxAttempts = cfg.ExternalMaxAttempts
+	resilience.DefaultPolicy.FailureThreshold = cfg.CircuitFailureThreshold
+	resilience.DefaultPolicy.OpenTimeout = time.Duration(cfg.CircuitOpenDurationSeconds) * time.Second
+
+	// Restrict and route outbound connections before any client is used
+	if err := egress.Configure(egress.Options{
+		Allowlist: cfg.EgressAllowlist,
+		ProxyURL:  cfg.EgressProxyURL,
+		NoProxy:   cfg.EgressNoProxy,
+		CAFile:    cfg.EgressCAFile,
+		Pins:      cfg.EgressTLSPins,
+	}); err != nil {
+		logger.Fatal("Invalid egress configuration", zap.Error(err))
+	}
+	if len(cfg.EgressAllowlist) == 0 && cfg.IsProduction() {
+		logger.Warn("EGRESS_ALLOWLIST is empty; outbound connections to any destination are allowed")
+	}
+
 	// Initialize database
-	db, err := database.NewPostgresDB(cfg.DatabaseURL)
+	if cfg.Standalone {
+		if err := os.MkdirAll(cfg.DataDir, 0o750); err != nil {
+			logger.Fatal("Failed to create data directory", zap.Error(err))
+		}
+	}
+	db, err := database.Open(cfg.DatabaseURL)
 	if err != nil {
 		logger.Fatal("Failed to connect to database", zap.Error(err))
 	}
 
-	// Run database migrations
+	// Sanitize free-text fields on every write, whichever path it comes through
+	sanitizer, err := sanitize.New(cfg.FreeTextSanitization)
+	if err != nil {
+		logger.Fatal("Invalid free-text sanitization policies", zap.Error(err))
+	}
+	if err := db.Use(sanitizer); err != nil {
+		logger.Fatal("Failed to register free-text sanitization", zap.Error(err))
+	}
+
+	// Connect the read replica; reads presenting a consistency token fall back to the
+	// primary until the replica has caught up with the token's write
+	var replica *gorm.DB
+	if cfg.ReadReplicaURL != "" {
+		replica, err = database.Open(cfg.ReadReplicaURL)
+		if err != nil {
+			logger.Fatal("Failed to connect to read replica", zap.Error(err))
+		}
+	}
+	dbRouter := database.NewRouter(db, replica)
+
+	// Run database migrations and create indexes on one replica at a time; replicas
+	// starting together wait for the first, then find the schema up to date
+	schemaCtx, cancelSchema := context.WithTimeout(context.Background(), time.Duration(cfg.SchemaLockTimeoutSeconds)*time.Second)
+	schemaLock, err := cluster.Wait(schemaCtx, db, "schema", time.Second)
+	cancelSchema()
+	if err != nil {
+		logger.Fatal("Failed to acquire schema migration lock", zap.Error(err))
+	}
+	schemaStart := time.Now()
 	if err := database.AutoMigrate(db); err != nil {
 		logger.Fatal("Failed to migrate database", zap.Error(err))
 	}
-
-	// Create database indexes
 	if err := database.CreateIndexes(db); err != nil {
-		logger.Warn("Failed to create some database indexes", zap.Error(err))
+		logger.Warn("Failed to create some database indexes; see GET /api/v1/admin/indexes", zap.Error(err))
 	}
+	if err := database.CreateViews(db); err != nil {
+		logger.Warn("Failed to create the analytics views; see GET /api/v1/admin/analytics-views", zap.Error(err))
+	}
+	schemaLock.Release()
+	logger.Info("Database schema is up to date", zap.Duration("duration", time.Since(schemaStart)))
+
+	// Persist audit events so they can be archived to write-once storage
+	logger.SetAuditSink(audit.NewRecorder(db).Record)
 
 	// Initialize RBAC service and create default roles
 	rbacService := auth.NewRBACService(db)
 	if err := rbacService.InitializeDefaultRoles(); err != nil {
 		logger.Warn("Failed to initialize default roles", zap.Error(err))
 	}
+	// Permission checks read the roles from a cache instead of the database
+	if roles, err := rbacService.LoadPermissions(); err != nil {
+		logger.Warn("Failed to load role permissions; using the default roles", zap.Error(err))
+	} else {
+		logger.Info("Role permissions loaded", zap.Int("roles", roles))
+	}
+
+	// Initialize the observation category code system and map legacy free-form categories
+	categoryService := terminology.NewCategoryService(db)
+	if err := categoryService.InitializeDefaultCategories(); err != nil {
+		logger.Warn("Failed to initialize observation categories", zap.Error(err))
+	}
+	if updated, unmapped, err := categoryService.MigrateExisting(); err != nil {
+		logger.Warn("Failed to migrate observation categories", zap.Error(err))
+	} else if updated > 0 || unmapped > 0 {
+		logger.Info("Migrated observation categories",
+			zap.Int("updated", updated),
+			zap.Int("unmapped", unmapped),
+		)
+	}
+
+	// Seed the displays patients see coded values by, in their language
+	localizer := terminology.NewLocalizer(db)
+	if err := localizer.InitializeDefaultDisplays(); err != nil {
+		logger.Warn("Failed to initialize concept displays", zap.Error(err))
+	}
+
+	// Load the configurable validation profiles
+	profileService := validation.NewProfileService(db)
+	if err := profileService.Reload(); err != nil {
+		logger.Warn("Failed to load validation profiles", zap.Error(err))
+	}
+
+	// Validate resources sent as FHIR JSON against the bundled US Core profiles
+	var conformance *fhir.Validator
+	if cfg.FHIRProfileValidation {
+		if conformance, err = fhir.NewValidator(); err != nil {
+			logger.Fatal("Failed to load FHIR profiles", zap.Error(err))
+		}
+	}
 
 	// Initialize Gin router
 	if cfg.IsProduction() {
@@ -70,19 +225,93 @@ func main() {
 	r := gin.New()
 
 	// Add middleware
+	r.Use(apierror.RequestIDMiddleware())
 	r.Use(gin.LoggerWithConfig(gin.LoggerConfig{
 		Formatter: func(param gin.LogFormatterParams) string {
+			// Requests that were not authenticated carry no user
+			userID, _ := param.Keys["user_id"].(string)
 			logger.LogHTTPRequest(
 				param.Method,
 				param.Path,
 				param.StatusCode,
 				param.Latency.Milliseconds(),
-				param.Keys["user_id"].(string),
+				userID,
 			)
 			return ""
 		},
 	}))
-	r.Use(gin.Recovery())
+
+	// Request metrics wrap the recovery so recovered panics count as server errors
+	sloObjectives, err := metrics.ParseObjectives(cfg.SLOTargets)
+	if err != nil {
+		logger.Fatal("Invalid SLO targets", zap.Error(err))
+	}
+	metricsRegistry := metrics.NewRegistry()
+	sloTracker := metricsRegistry.TrackSLOs(sloObjectives)
+	r.Use(metricsRegistry.PrometheusMiddleware())
+
+	// Requests are counted by endpoint and client, and by the deprecated endpoints and
+	// fields they use, to see who a deprecation affects
+	deprecations, err := usage.ParseDeprecations(cfg.Deprecations)
+	if err != nil {
+		logger.Fatal("Invalid deprecations", zap.Error(err))
+	}
+	usageTracker := usage.NewTracker(db, deprecations, time.Duration(cfg.UsageRetentionDays)*24*time.Hour)
+	r.Use(usageTracker.Middleware())
+
+	// API requests are served by priority class so bulk traffic is shed before
+	// interactive requests under overload
+	priorityClasses, err := loadshed.ParseClasses(cfg.PriorityClasses)
+	if err != nil {
+		logger.Fatal("Invalid priority classes", zap.Error(err))
+	}
+	priorityRoutes, err := loadshed.ParseAssignments(cfg.PriorityRoutes)
+	if err != nil {
+		logger.Fatal("Invalid priority routes", zap.Error(err))
+	}
+	priorityKeys, err := loadshed.ParseAssignments(cfg.PriorityKeys)
+	if err != nil {
+		logger.Fatal("Invalid priority keys", zap.Error(err))
+	}
+	priorityLimiter, err := loadshed.New(loadshed.Options{
+		Classes:    priorityClasses,
+		Routes:     priorityRoutes,
+		Keys:       priorityKeys,
+		KeyHeader:  inbound.KeyIDHeader,
+		Threshold:  float64(cfg.LoadShedThresholdPct) / 100,
+		RetryAfter: time.Duration(cfg.LoadShedRetryAfterSecs) * time.Second,
+	})
+	if err != nil {
+		logger.Fatal("Invalid request prioritization", zap.Error(err))
+	}
+
+	// Requests running past their route's timeout are cancelled and answered with 504
+	requestTimeouts, err := timeout.ParseRoutes(cfg.RequestTimeouts)
+	if err != nil {
+		logger.Fatal("Invalid request timeouts", zap.Error(err))
+	}
+
+	// Panics are logged with their stack trace and, when configured, reported to Sentry
+	var sentryReporter *recovery.SentryReporter
+	var panicReporter recovery.Reporter
+	if cfg.SentryDSN != "" {
+		hostname, _ := os.Hostname()
+		sentryReporter, err = recovery.NewSentryReporter(recovery.SentryOptions{
+			DSN:         cfg.SentryDSN,
+			Environment: cfg.Environment,
+			Release:     cfg.SentryRelease,
+			ServerName:  hostname,
+		})
+		if err != nil {
+			logger.Fatal("Failed to configure Sentry", zap.Error(err))
+		}
+		panicReporter = sentryReporter
+	}
+	r.Use(handlers.ResponseEnvelopeMiddleware(cfg.ResponseEnvelope))
+	r.Use(handlers.FastListEncodingMiddleware(cfg.FastListEncoding))
+	// FHIR clients get errors as OperationOutcome resources, including recovered panics
+	r.Use(handlers.OperationOutcomeMiddleware())
+	r.Use(recovery.Middleware(panicReporter))
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
@@ -100,7 +329,8 @@ func main() {
 			c.Header("Access-Control-Allow-Origin", origin)
 		}
 		c.Header("Access-Control-Allow-Methods", "GET, POST, PUT, DELETE, OPTIONS")
-		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization")
+		c.Header("Access-Control-Allow-Headers", "Content-Type, Authorization, "+handlers.ConsistencyHeader+", "+apierror.RequestIDHeader)
+		c.Header("Access-Control-Expose-Headers", handlers.ConsistencyHeader+", "+apierror.RequestIDHeader+", Link, X-Total-Count, Retry-After")
 		c.Header("Access-Control-Allow-Credentials", "true")
 
 		if c.Request.Method == "OPTIONS" {
@@ -122,6 +352,9 @@ func main() {
 		c.Next()
 	})
 
+	// Prometheus metrics endpoint
+	r.GET("/metrics", gin.WrapH(promhttp.Handler()))
+
 	// Health check endpoint
 	r.GET(cfg.HealthCheckPath, func(c *gin.Context) {
 		// Check database connectivity
@@ -148,44 +381,423 @@ func main() {
 			return
 		}
 
+		services := map[string]string{
+			"database": "ok",
+			"api":      "ok",
+		}
+		// Open circuits are reported but do not make the instance unhealthy
+		for name, state := range resilience.States() {
+			services[name] = state
+		}
+		for name, state := range cluster.Workers() {
+			services["worker_"+name] = state
+		}
+
 		c.JSON(200, handlers.HealthResponse{
 			Status:    "healthy",
 			Timestamp: time.Now(),
 			Version:   "1.0.0",
-			Services: map[string]string{
-				"database": "ok",
-				"api":      "ok",
-			},
+			Services:  services,
 		})
 	})
 
-	// Initialize token manager
+	// Initialize token manager; keys rotated through the admin endpoint are stored
+	// encrypted and shared by every replica
+	credentialEncryptor := encryption.NewEncryptorFromHash(cfg.EncryptionKey)
 	tokenManager := auth.NewTokenManager(cfg.JWTSecret, "HealthHub API")
+	tokenManager.SetLifetime(time.Duration(cfg.AccessTokenTTLMinutes) * time.Minute)
+	keyRotator := auth.NewKeyRotator(db, credentialEncryptor, tokenManager, cfg.JWTSecret, cfg.JWTPreviousSecrets,
+		time.Duration(cfg.JWTRotationWindowHours)*time.Hour)
+	if err := keyRotator.Load(context.Background()); err != nil {
+		logger.Fatal("Failed to load JWT signing keys", zap.Error(err))
+	}
+	if keyRotator.Window() < tokenManager.Lifetime() {
+		logger.Warn("JWT rotation window is shorter than the token lifetime; rotations will reject unexpired tokens")
+	}
+
+	// Access tokens are short-lived; refresh tokens renew them and rotate on every use
+	refreshTokens := auth.NewRefreshStore(db, time.Duration(cfg.RefreshTokenTTLHours)*time.Hour)
+
+	// Access tokens ended by logging out are denied until they expire. The denylist is
+	// shared through Redis; without Redis each instance keeps its own.
+	var denylist auth.Denylist = auth.NewMemoryDenylist()
+	if cfg.RedisURL != "" {
+		redisDenylist, err := auth.NewRedisDenylist(context.Background(), cfg.RedisURL)
+		if err != nil {
+			logger.Warn("Redis unavailable; keeping the token denylist in memory", zap.Error(err))
+		} else {
+			defer redisDenylist.Close()
+			denylist = redisDenylist
+		}
+	}
+
+	// Users may also sign in at the hospital's identity provider; local login stays
+	// available alongside it
+	var oidcProvider *auth.OIDCProvider
+	if cfg.OIDCIssuerURL != "" {
+		groupRoles, err := auth.ParseGroupRoles(cfg.OIDCGroupRoles)
+		if err != nil {
+			logger.Fatal("Invalid single sign-on group roles", zap.Error(err))
+		}
+		oidcProvider = auth.NewOIDCProvider(db, auth.OIDCConfig{
+			IssuerURL:    cfg.OIDCIssuerURL,
+			ClientID:     cfg.OIDCClientID,
+			ClientSecret: cfg.OIDCClientSecret,
+			RedirectURL:  cfg.OIDCRedirectURL,
+			Scopes:       cfg.OIDCScopes,
+			GroupsClaim:  cfg.OIDCGroupsClaim,
+			GroupRoles:   groupRoles,
+			DefaultRoles: cfg.OIDCDefaultRoles,
+		})
+	}
+
+	// Initialize attachment storage
+	mediaStorage, err := storage.NewLocalStorage(cfg.StoragePath)
+	if err != nil {
+		logger.Fatal("Failed to initialize storage", zap.Error(err))
+	}
+
+	mediaURLSecret := cfg.MediaURLSecret
+	if mediaURLSecret == "" {
+		mediaURLSecret = cfg.DerivedSecret("media-url")
+	}
+	urlSigner := storage.NewURLSigner(mediaURLSecret)
+
+	cursorSecret := cfg.CursorSecret
+	if cursorSecret == "" {
+		cursorSecret = cfg.DerivedSecret("cursor")
+	}
+	cursors, err := cursor.NewSigner(cursorSecret, time.Duration(cfg.CursorTTLHours)*time.Hour)
+	if err != nil {
+		logger.Fatal("Failed to initialize cursor signer", zap.Error(err))
+	}
+
+	// Initialize address verification provider
+	geocoder, err := geocoding.NewProvider(cfg.GeocoderProvider, geocoding.Options{
+		BaseURL: cfg.GeocoderURL,
+		APIKey:  cfg.GeocoderAPIKey,
+		AuthID:  cfg.GeocoderAuthID,
+		Email:   cfg.GeocoderEmail,
+	})
+	if err != nil {
+		logger.Fatal("Failed to initialize address verification", zap.Error(err))
+	}
+
+	// Initialize drug interaction checking
+	interactionKB, err := interaction.NewKnowledgeBase(cfg.InteractionProvider, db, interaction.Options{
+		BaseURL: cfg.InteractionAPIURL,
+		APIKey:  cfg.InteractionAPIKey,
+	})
+	if err != nil {
+		logger.Fatal("Failed to initialize drug interaction checking", zap.Error(err))
+	}
+
+	// Initialize notification senders
+	emailSender, err := notify.NewEmailSender(cfg.EmailProvider, notify.EmailOptions{
+		Host:     cfg.SMTPHost,
+		Port:     cfg.SMTPPort,
+		Username: cfg.SMTPUsername,
+		Password: cfg.SMTPPassword,
+		From:     cfg.SMTPFrom,
+	})
+	if err != nil {
+		logger.Fatal("Failed to initialize email sender", zap.Error(err))
+	}
+
+	smsSender, err := notify.NewSMSSender(cfg.SMSProvider, notify.SMSOptions{
+		AccountSID: cfg.TwilioAccountSID,
+		AuthToken:  cfg.TwilioAuthToken,
+		From:       cfg.TwilioFrom,
+	})
+	if err != nil {
+		logger.Fatal("Failed to initialize SMS sender", zap.Error(err))
+	}
+
+	// Pseudonymous IDs in de-identified data are keyed apart from tokens and the
+	// encryption of stored data
+	deidSecret := cfg.DeidentificationSecret
+	if deidSecret == "" {
+		deidSecret = cfg.DerivedSecret("deid")
+	}
+	deidentifier := deid.New(deidSecret)
+
+	// Background workers are stopped when the server shuts down. Each worker runs on
+	// one replica at a time; the others stand by to take over.
+	workerCtx, stopWorkers := context.WithCancel(context.Background())
+	defer stopWorkers()
+	lockRetry := time.Duration(cfg.WorkerLockRetrySeconds) * time.Second
+	singleton := func(name string, run func(ctx context.Context)) {
+		go cluster.NewSingleton(db, name, lockRetry, run).Run(workerCtx)
+	}
+
+	// Every replica reloads the signing keys and role permissions to pick up changes
+	// made elsewhere
+	go keyRotator.Run(workerCtx)
+	go rbacService.Run(workerCtx)
+
+	// Every replica samples its own request counters for the ops dashboard and
+	// evaluates the SLOs of the requests it served
+	dashboardCollector := dashboard.NewCollector(db, replica, prometheus.DefaultGatherer)
+	go dashboardCollector.Run(workerCtx)
+	go sloTracker.Run(workerCtx)
+
+	// Every replica records the requests it serves for the active capture sessions
+	captureRecorder := capture.NewRecorder(db, time.Duration(cfg.CaptureRetentionHours)*time.Hour)
+	go captureRecorder.Run(workerCtx)
+	go usageTracker.Run(workerCtx)
+	if sentryReporter != nil {
+		go sentryReporter.Run(workerCtx)
+	}
+
+	// Initialize search indexing pipeline
+	var indexer *indexing.Indexer
+	if cfg.SearchIndexEnabled {
+		indexer = indexing.NewIndexer(db, opensearch.NewClient(cfg.OpenSearchURL, cfg.OpenSearchUsername, cfg.OpenSearchPassword), cfg.SearchIndexPrefix)
+		consumer := events.NewConsumer(db, indexing.ConsumerName, indexer.HandleEvent, time.Duration(cfg.SearchIndexPollSeconds)*time.Second)
+
+		singleton("search_indexer", func(ctx context.Context) {
+			needsBackfill, err := indexer.EnsureIndices(ctx)
+			if err != nil {
+				logger.Error("Failed to prepare search indices", zap.Error(err))
+				return
+			}
+			if needsBackfill {
+				logger.Info("Backfilling search indices")
+				if err := indexer.Backfill(ctx); err != nil {
+					logger.Error("Failed to backfill search indices", zap.Error(err))
+				}
+			}
+			consumer.Run(ctx)
+		})
+	}
+
+	// Initialize charge capture from the outbox
+	chargeCapturer := billing.NewCapturer(db, terminology.NewTranslator(db))
+	if cfg.ChargeCaptureEnabled {
+		chargeConsumer := events.NewConsumer(db, billing.CaptureConsumerName, chargeCapturer.HandleEvent, time.Duration(cfg.ChargeCapturePollSeconds)*time.Second)
+		singleton("charge_capture", chargeConsumer.Run)
+	}
+
+	// Run long requests, such as bulk jobs and exports, as operations clients poll
+	// and cancel; each kind has a worker of its own
+	operations := operation.NewRunner(db)
+	operations.Register(models.OperationKindBulk, bulk.NewRunner(db, 500).Execute)
+	singleton("bulk_jobs", operations.Worker(models.OperationKindBulk))
+
+	// Finalize deletions once their undo window has passed
+	undoWindow := time.Duration(cfg.UndoWindowMinutes) * time.Minute
+	purger := retention.NewPurger(db, mediaStorage, undoWindow)
+	singleton("retention_purger", purger.Run)
+
+	// Initialize export job runner; export files share the attachment storage backend
+	operations.Register(models.OperationKindExport, export.NewRunner(db, mediaStorage, deidentifier).Execute)
+	singleton("export_jobs", operations.Worker(models.OperationKindExport))
+
+	// Build database indexes on request, one at a time across replicas
+	operations.Register(models.OperationKindIndexes, database.NewIndexBuilder(db).Execute)
+	singleton("index_builds", operations.Worker(models.OperationKindIndexes))
+
+	// Refresh the analytics materialized views on a schedule and on request
+	viewRefresher := database.NewViewRefresher(db, operations, time.Duration(cfg.AnalyticsViewRefreshMinutes)*time.Minute)
+	operations.Register(models.OperationKindViews, viewRefresher.Execute)
+	singleton("view_refreshes", operations.Worker(models.OperationKindViews))
+	singleton("view_refresh_scheduler", viewRefresher.Run)
+
+	// Replay outbox events to the consumers running here, such as after an outage
+	// of the system a consumer feeds
+	replayer := events.NewReplayer(db)
+	if cfg.SearchIndexEnabled {
+		replayer.Register(indexing.ConsumerName, indexer.HandleEvent)
+	}
+	if cfg.ChargeCaptureEnabled {
+		replayer.Register(billing.CaptureConsumerName, chargeCapturer.HandleEvent)
+	}
+	operations.Register(models.OperationKindReplay, replayer.Execute)
+	singleton("event_replays", operations.Worker(models.OperationKindReplay))
+
+	// Run recurring exports; destination credentials are stored encrypted
+	exportScheduler := export.NewScheduler(db, deidentifier, credentialEncryptor, emailSender)
+	singleton("export_scheduler", exportScheduler.Run)
+
+	// Initialize backup runner; dumps share the attachment storage backend
+	backupRunner, err := backup.NewRunner(db, mediaStorage, backup.Options{
+		DatabaseURL:        cfg.DatabaseURL,
+		StagingDatabaseURL: cfg.StagingDatabaseURL,
+		PgDumpPath:         cfg.PgDumpPath,
+		PgRestorePath:      cfg.PgRestorePath,
+	})
+	if err != nil {
+		logger.Fatal("Failed to initialize backup runner", zap.Error(err))
+	}
+	singleton("backup_jobs", backupRunner.Run)
+
+	// Generate the expected observations of standing orders and match results to them
+	standingOrderScheduler := standingorder.NewScheduler(db)
+	singleton("standing_orders", standingOrderScheduler.Run)
+
+	// Escalate unacknowledged critical alerts along the on-call chains
+	singleton("alert_escalator", escalation.NewEscalator(db, emailSender, smsSender).Run)
+
+	// Remove refresh tokens once they have expired
+	singleton("refresh_token_purger", refreshTokens.Run)
+
+	// Ship the audit log to an S3 bucket with Object Lock as signed archives
+	if cfg.AuditArchiveBucket != "" {
+		archiver, err := newAuditArchiver(cfg, db)
+		if err != nil {
+			logger.Fatal("Failed to initialize audit archiver", zap.Error(err))
+		}
+		singleton("audit_archiver", archiver.Run)
+	}
+
+	// Verify signed requests of inbound integrations and expire their nonces
+	inboundVerifier := inbound.NewVerifier(db, credentialEncryptor)
+	singleton("integration_nonces", inboundVerifier.Run)
+
+	// Every replica reloads the integrations' field mappings to pick up changes made
+	// elsewhere
+	inboundMapper := inbound.NewMapper(db)
+	go inboundMapper.Run(workerCtx)
+
+	// Quarantine the inbound messages validation rejects for review and reprocessing
+	quarantineQueue := quarantine.NewQueue(db, inboundVerifier, time.Duration(cfg.QuarantineRetentionDays)*24*time.Hour)
+	singleton("quarantine_purger", quarantineQueue.Run)
+
+	// Check the patient references left behind by deletes and merges; 0 disables
+	// scheduled runs
+	integrityChecker := integrity.NewChecker(db, time.Duration(cfg.IntegrityCheckIntervalHours)*time.Hour, cfg.IntegrityAutoRepoint)
+	singleton("integrity_checker", integrityChecker.Run)
+
+	// Check new resources with the checks the deployment runs
+	writeChecks := writecheck.NewRegistry()
+	writecheck.RegisterObservationChecks(writeChecks, db, categoryService, deltacheck.NewChecker(db))
+	if unknown := writeChecks.Configure(cfg.WriteChecksEnabled, cfg.WriteChecksDisabled); len(unknown) > 0 {
+		logger.Warn("Ignoring unknown write checks; see GET /api/v1/admin/write-checks", zap.Strings("checks", unknown))
+	}
 
 	// Initialize handlers
-	patientHandler := handlers.NewPatientHandler(db)
-	observationHandler := handlers.NewObservationHandler(db)
-	authHandler := handlers.NewAuthHandler(db, cfg.JWTSecret)
+	patientHandler := handlers.NewPatientHandler(db, geocoder, profileService, conformance, undoWindow)
+	observationHandler := handlers.NewObservationHandler(db, categoryService, profileService, conformance, writeChecks, localizer, undoWindow)
+	authHandler := handlers.NewAuthHandler(db, tokenManager, refreshTokens, denylist, oidcProvider)
+	signingKeyHandler := handlers.NewSigningKeyHandler(db, keyRotator)
+	dashboardHandler := handlers.NewDashboardHandler(dashboardCollector)
+	sloHandler := handlers.NewSLOHandler(sloTracker)
+	priorityHandler := handlers.NewPriorityHandler(priorityLimiter)
+	captureHandler := handlers.NewCaptureHandler(db, captureRecorder)
+	sandboxHandler := handlers.NewSandboxHandler(db)
+	fixtureHandler := handlers.NewFixtureHandler(fixtures.NewLoader(db))
+	integrationCredentialHandler := handlers.NewIntegrationCredentialHandler(db, inboundVerifier)
+	integrationMappingHandler := handlers.NewIntegrationMappingHandler(db, inboundMapper)
+	quarantineHandler := handlers.NewQuarantineHandler(db, quarantineQueue)
+	integrityHandler := handlers.NewIntegrityHandler(db, integrityChecker)
+	clinicalNoteHandler := handlers.NewClinicalNoteHandler(db)
+	questionnaireHandler := handlers.NewQuestionnaireHandler(db)
+	searchHandler := handlers.NewSearchHandler(db, indexer)
+	observationCategoryHandler := handlers.NewObservationCategoryHandler(db, categoryService)
+	validationProfileHandler := handlers.NewValidationProfileHandler(db, profileService)
+	bulkHandler := handlers.NewBulkHandler(db, categoryService, operations)
+	trashHandler := handlers.NewTrashHandler(db, purger, undoWindow)
+	exportHandler := handlers.NewExportHandler(db, deidentifier, mediaStorage, operations)
+	operationHandler := handlers.NewOperationHandler(db, operations)
+	databaseIndexHandler := handlers.NewDatabaseIndexHandler(db, operations)
+	analyticsViewHandler := handlers.NewAnalyticsViewHandler(db, viewRefresher)
+	writeCheckHandler := handlers.NewWriteCheckHandler(writeChecks)
+	eventReplayHandler := handlers.NewEventReplayHandler(db, operations, replayer)
+	exportScheduleHandler := handlers.NewExportScheduleHandler(db, credentialEncryptor, exportScheduler)
+	// Research users read analytics with small counts suppressed and optional noise
+	disclosurePolicies, err := disclosure.ParsePolicies(cfg.AnalyticsDisclosure)
+	if err != nil {
+		logger.Fatal("Invalid analytics disclosure policies", zap.Error(err))
+	}
+	analyticsHandler := handlers.NewAnalyticsHandler(db, disclosurePolicies)
+	qcHandler := handlers.NewQCHandler(db, qc.NewRecorder(db))
+	specimenHandler := handlers.NewSpecimenHandler(db, emailSender)
+	deviceHandler := handlers.NewDeviceHandler(db)
+	usageHandler := handlers.NewUsageHandler(db, usageTracker)
+	standingOrderHandler := handlers.NewStandingOrderHandler(db, standingOrderScheduler)
+	medicationHandler := handlers.NewMedicationHandler(db, interaction.NewChecker(db, interactionKB), cfg.AllergyCheckMode == "block")
+	allergyHandler := handlers.NewAllergyHandler(db, localizer, undoWindow)
+	coverageHandler := handlers.NewCoverageHandler(db, undoWindow)
+	relatedPersonHandler := handlers.NewRelatedPersonHandler(db)
+	practitionerHandler := handlers.NewPractitionerHandler(db)
+	procedureHandler := handlers.NewProcedureHandler(db, undoWindow)
+	carePlanHandler := handlers.NewCarePlanHandler(db)
+	chartSnapshotHandler := handlers.NewChartSnapshotHandler(db)
+	legalHoldHandler := handlers.NewLegalHoldHandler(db)
+	translator := terminology.NewTranslator(db)
+	conditionHandler := handlers.NewConditionHandler(db, translator, localizer, cfg.BillingCodeSystem, undoWindow)
+	terminologyHandler := handlers.NewTerminologyHandler(db, translator, localizer)
+	chargeHandler := handlers.NewChargeHandler(db, chargeCapturer)
+	billingHandler := handlers.NewBillingHandler(db, billing.NewAssembler(db, translator, cfg.BillingCodeSystem), billing.Submitter{
+		Name:           cfg.BillingProviderName,
+		NPI:            cfg.BillingProviderNPI,
+		TaxID:          cfg.BillingProviderTaxID,
+		Phone:          cfg.BillingProviderPhone,
+		Address:        cfg.BillingProviderAddress,
+		City:           cfg.BillingProviderCity,
+		State:          cfg.BillingProviderState,
+		PostalCode:     cfg.BillingProviderPostalCode,
+		SenderID:       cfg.BillingSenderID,
+		ReceiverID:     cfg.BillingReceiverID,
+		ReceiverName:   cfg.BillingReceiverName,
+		PlaceOfService: cfg.BillingPlaceOfService,
+		Production:     cfg.BillingProduction,
+	})
+	drugInteractionHandler := handlers.NewDrugInteractionHandler(db)
+	alertHandler := handlers.NewAlertHandler(db)
+	deltaCheckHandler := handlers.NewDeltaCheckHandler(db)
+	onCallChainHandler := handlers.NewOnCallChainHandler(db)
+	changeHandler := handlers.NewChangeHandler(db, cursors)
+	backupHandler := handlers.NewBackupHandler(db, mediaStorage, backupRunner)
+	contactVerificationHandler := handlers.NewContactVerificationHandler(db, emailSender, smsSender,
+		cfg.DerivedSecret("contact-verification"))
+	mediaHandler := handlers.NewMediaHandler(db, mediaStorage, urlSigner,
+		time.Duration(cfg.MediaURLTTLMinutes)*time.Minute, int64(cfg.MaxUploadSizeMB)<<20, undoWindow)
+	documentHandler := handlers.NewDocumentReferenceHandler(db, mediaStorage, urlSigner,
+		time.Duration(cfg.MediaURLTTLMinutes)*time.Minute, int64(cfg.MaxUploadSizeMB)<<20, undoWindow)
 
 	// Public routes
 	public := r.Group("/api/v1")
+	public.Use(priorityLimiter.Middleware())
+	public.Use(timeout.Middleware(requestTimeouts))
 	{
 		public.POST("/auth/login", authHandler.Login)
 		public.POST("/auth/register", authHandler.Register)
+		public.POST("/auth/refresh", authHandler.RefreshToken)
+		public.POST("/auth/logout", authHandler.Logout)
+		public.GET("/auth/oidc/login", authHandler.OIDCLogin)
+		public.GET("/auth/oidc/callback", authHandler.OIDCCallback)
+		public.GET("/errors", handlers.GetErrorCodes)
+		public.GET("/errors/:code", handlers.GetErrorCode)
+		// The catalog's former path, kept for existing clients and listed in DEPRECATIONS
+		public.GET("/error-codes", handlers.RedirectErrorCodes)
+
+		// Media downloads are authorized by signed URLs rather than bearer tokens
+		public.GET("/media/:id/content", mediaHandler.DownloadMediaContent)
+		public.GET("/media/:id/thumbnail", mediaHandler.DownloadMediaThumbnail)
+		public.GET("/document-references/:id/content", documentHandler.DownloadDocumentContent)
 	}
 
 	// Protected routes
 	protected := r.Group("/api/v1")
-	protected.Use(auth.AuthMiddleware(tokenManager))
+	protected.Use(priorityLimiter.Middleware())
+	protected.Use(timeout.Middleware(requestTimeouts))
+	protected.Use(auth.AuthMiddleware(tokenManager, denylist))
+	protected.Use(handlers.ConsistencyMiddleware(dbRouter))
+	protected.Use(captureRecorder.Middleware())
+	protected.Use(handlers.SandboxMiddleware(db))
 	{
 		// Auth routes
-		auth := protected.Group("/auth")
+		authRoutes := protected.Group("/auth")
 		{
-			auth.POST("/refresh", authHandler.RefreshToken)
-			auth.GET("/profile", authHandler.GetProfile)
-			auth.POST("/change-password", authHandler.ChangePassword)
+			authRoutes.GET("/profile", authHandler.GetProfile)
+			authRoutes.POST("/change-password", authHandler.ChangePassword)
 		}
 
+		// Global search
+		protected.GET("/search", auth.RequireRole("practitioner", "admin", "nurse"), searchHandler.Search)
+		protected.GET("/search/index", auth.RequireRole("practitioner", "admin", "nurse"), searchHandler.IndexedSearch)
+
 		// Patient endpoints
 		patients := protected.Group("/patients")
 		{
@@ -194,7 +806,40 @@ func main() {
 			patients.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), patientHandler.GetPatient)
 			patients.PUT("/:id", auth.RequireRole("practitioner", "admin"), patientHandler.UpdatePatient)
 			patients.DELETE("/:id", auth.RequireRole("admin"), patientHandler.DeletePatient)
-			patients.GET("/:patientId/observations", auth.RequireRole("practitioner", "admin", "nurse"), observationHandler.GetPatientObservations)
+			patients.POST("/:id/links", auth.RequireRole("practitioner", "admin"), patientHandler.LinkPatient)
+			patients.POST("/:id/merge", auth.RequireRole("admin"), patientHandler.MergePatient)
+			patients.POST("/:id/undelete", auth.RequireRole("admin"), patientHandler.UndeletePatient)
+			patients.GET("/:id/links", auth.RequireRole("practitioner", "admin", "nurse"), patientHandler.GetPatientLinks)
+			patients.GET("/:id/$everything", auth.RequireRole("practitioner", "admin"), patientHandler.GetPatientEverything)
+			patients.DELETE("/:id/links/:linkId", auth.RequireRole("practitioner", "admin"), patientHandler.UnlinkPatient)
+			patients.POST("/:id/telecom/verifications", auth.RequireRole("practitioner", "admin", "nurse"), contactVerificationHandler.StartVerification)
+			patients.POST("/:id/telecom/verifications/:verificationId/confirm", auth.RequireRole("practitioner", "admin", "nurse"), contactVerificationHandler.ConfirmVerification)
+			patients.GET("/:id/observations", auth.RequireRole("practitioner", "admin", "nurse"), observationHandler.GetPatientObservations)
+			patients.GET("/:id/observations/latest", auth.RequireRole("practitioner", "admin", "nurse"), observationHandler.GetLatestPatientObservations)
+			patients.GET("/:id/notes", auth.RequireRole("practitioner", "admin", "nurse"), clinicalNoteHandler.GetPatientNotes)
+			patients.GET("/:id/questionnaire-responses", auth.RequireRole("practitioner", "admin", "nurse"), questionnaireHandler.GetPatientResponses)
+			patients.GET("/:id/medication-doses", auth.RequireRole("practitioner", "admin", "nurse"), medicationHandler.GetDueDoses)
+			patients.GET("/:id/medication-administrations", auth.RequireRole("practitioner", "admin", "nurse"), medicationHandler.GetPatientAdministrations)
+			patients.GET("/:id/allergy-intolerances", auth.RequireRole("practitioner", "admin", "nurse"), allergyHandler.GetPatientAllergies)
+			patients.POST("/:id/allergy-intolerances", auth.RequireRole("practitioner", "admin", "nurse"), allergyHandler.CreatePatientAllergy)
+			patients.GET("/:id/procedures", auth.RequireRole("practitioner", "admin", "nurse"), procedureHandler.GetPatientProcedures)
+			patients.POST("/:id/procedures", auth.RequireRole("practitioner", "admin"), procedureHandler.CreatePatientProcedure)
+			patients.GET("/:id/coverages", auth.RequireRole("practitioner", "admin", "nurse"), coverageHandler.GetPatientCoverages)
+			patients.POST("/:id/coverages", auth.RequireRole("practitioner", "admin", "nurse"), coverageHandler.CreatePatientCoverage)
+			patients.GET("/:id/related-persons", auth.RequireRole("practitioner", "admin", "nurse"), relatedPersonHandler.GetRelatedPersons)
+			patients.POST("/:id/related-persons", auth.RequireRole("practitioner", "admin", "nurse"), relatedPersonHandler.CreateRelatedPerson)
+			patients.GET("/:id/related-persons/:relatedPersonId", auth.RequireRole("practitioner", "admin", "nurse"), relatedPersonHandler.GetRelatedPerson)
+			patients.PUT("/:id/related-persons/:relatedPersonId", auth.RequireRole("practitioner", "admin", "nurse"), relatedPersonHandler.UpdateRelatedPerson)
+			patients.DELETE("/:id/related-persons/:relatedPersonId", auth.RequireRole("admin"), relatedPersonHandler.DeleteRelatedPerson)
+			patients.GET("/:id/care-plans", auth.RequireRole("practitioner", "admin", "nurse"), carePlanHandler.GetPatientCarePlans)
+			patients.POST("/:id/care-plans", auth.RequireRole("practitioner", "admin", "nurse"), carePlanHandler.CreatePatientCarePlan)
+			patients.GET("/:id/chart-snapshots", auth.RequireRole("compliance", "admin"), chartSnapshotHandler.GetPatientChartSnapshots)
+			patients.POST("/:id/chart-snapshots", auth.RequireRole("compliance", "admin"), chartSnapshotHandler.CreateChartSnapshot)
+			patients.GET("/:id/document-references", auth.RequireRole("practitioner", "admin", "nurse"), documentHandler.GetPatientDocuments)
+			patients.POST("/:id/document-references", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), documentHandler.UploadPatientDocument)
+			patients.GET("/:id/conditions", auth.RequireRole("practitioner", "admin", "nurse"), conditionHandler.GetPatientConditions)
+			patients.POST("/:id/conditions", auth.RequireRole("practitioner", "admin"), conditionHandler.CreatePatientCondition)
+			patients.GET("/:id/conditions/:condId/related", auth.RequireRole("practitioner", "admin", "nurse"), conditionHandler.GetConditionRelated)
 		}
 
 		// Observation endpoints
@@ -205,7 +850,405 @@ func main() {
 			observations.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), observationHandler.GetObservation)
 			observations.PUT("/:id", auth.RequireRole("practitioner", "admin"), observationHandler.UpdateObservation)
 			observations.DELETE("/:id", auth.RequireRole("admin"), observationHandler.DeleteObservation)
+			observations.POST("/:id/undelete", auth.RequireRole("admin"), observationHandler.UndeleteObservation)
+			observations.POST("/_bulk-update", auth.RequireRole("admin"), bulkHandler.PreviewBulkUpdate)
+			observations.POST("/_bulk-delete", auth.RequireRole("admin"), bulkHandler.PreviewBulkDelete)
+			observations.POST("/:id/media", auth.RequireRole("practitioner", "admin", "lab-tech"), mediaHandler.UploadObservationMedia)
+			observations.GET("/:id/media", auth.RequireRole("practitioner", "admin", "nurse"), mediaHandler.GetObservationMedia)
+		}
+
+		// Observation category code system
+		categories := protected.Group("/observation-categories")
+		{
+			categories.GET("", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), observationCategoryHandler.GetCategories)
+			categories.POST("", auth.RequireRole("admin"), observationCategoryHandler.CreateCategory)
+			categories.PUT("/:code", auth.RequireRole("admin"), observationCategoryHandler.UpdateCategory)
+		}
+
+		// Operation endpoints; operations are visible to the user who started them and
+		// to admins
+		operationRoutes := protected.Group("/operations")
+		{
+			operationRoutes.GET("/:id", operationHandler.GetOperation)
+			operationRoutes.POST("/:id/cancel", operationHandler.CancelOperation)
+		}
+
+		// Bulk job endpoints (admin only)
+		bulkJobs := protected.Group("/bulk-jobs")
+		bulkJobs.Use(auth.RequireRole("admin"))
+		{
+			bulkJobs.GET("/:id", bulkHandler.GetBulkJob)
+			bulkJobs.GET("/:id/items", bulkHandler.GetBulkJobItems)
+			bulkJobs.POST("/:id/execute", bulkHandler.ExecuteBulkJob)
+		}
+
+		// Recycle bin, backup and restore, and signing key endpoints (admin only)
+		admin := protected.Group("/admin")
+		admin.Use(auth.RequireRole("admin"))
+		{
+			admin.GET("/trash", trashHandler.GetTrash)
+			admin.DELETE("/trash/:type/:id", trashHandler.PurgeTrashItem)
+			admin.POST("/backups", backupHandler.CreateBackup)
+			admin.GET("/backups", backupHandler.GetBackups)
+			admin.GET("/backups/:id", backupHandler.GetBackup)
+			admin.DELETE("/backups/:id", backupHandler.DeleteBackup)
+			admin.POST("/backups/:id/restore", backupHandler.RestoreBackup)
+			admin.GET("/restores", backupHandler.GetRestores)
+			admin.GET("/restores/:id", backupHandler.GetRestore)
+			admin.GET("/signing-keys", signingKeyHandler.GetSigningKeys)
+			admin.POST("/signing-keys/rotate", signingKeyHandler.RotateSigningKey)
+			admin.GET("/dashboard", dashboardHandler.GetDashboard)
+			admin.GET("/slos", sloHandler.GetSLOs)
+			admin.GET("/slos/violations", sloHandler.GetSLOViolations)
+			admin.GET("/usage", usageHandler.GetEndpointUsage)
+			admin.GET("/usage/deprecations", usageHandler.GetDeprecationUsage)
+			admin.GET("/priority-classes", priorityHandler.GetPriorityClasses)
+			admin.POST("/captures", captureHandler.StartCapture)
+			admin.GET("/captures", captureHandler.GetCaptures)
+			admin.GET("/captures/:id", captureHandler.GetCapture)
+			admin.DELETE("/captures/:id", captureHandler.DeleteCapture)
+			admin.POST("/captures/:id/stop", captureHandler.StopCapture)
+			admin.GET("/captures/:id/exchanges", captureHandler.GetCapturedExchanges)
+			admin.PUT("/users/:id/sandbox", sandboxHandler.SetUserSandbox)
+			admin.POST("/integration-credentials", integrationCredentialHandler.CreateIntegrationCredential)
+			admin.GET("/integration-credentials", integrationCredentialHandler.GetIntegrationCredentials)
+			admin.DELETE("/integration-credentials/:id", integrationCredentialHandler.RevokeIntegrationCredential)
+			admin.GET("/integration-credentials/:id/mappings", integrationMappingHandler.GetIntegrationMappings)
+			admin.POST("/integration-credentials/:id/mappings", integrationMappingHandler.CreateIntegrationMapping)
+			admin.PUT("/integration-credentials/:id/mappings/:mappingId", integrationMappingHandler.UpdateIntegrationMapping)
+			admin.DELETE("/integration-credentials/:id/mappings/:mappingId", integrationMappingHandler.DeleteIntegrationMapping)
+			admin.GET("/quarantine", quarantineHandler.GetQuarantinedMessages)
+			admin.GET("/quarantine/:id", quarantineHandler.GetQuarantinedMessage)
+			admin.PUT("/quarantine/:id/hints", quarantineHandler.UpdateMappingHints)
+			admin.POST("/quarantine/:id/reprocess", quarantineHandler.ReprocessQuarantinedMessage)
+			admin.POST("/quarantine/:id/discard", quarantineHandler.DiscardQuarantinedMessage)
+			admin.POST("/integrity/runs", integrityHandler.CreateIntegrityRun)
+			admin.GET("/integrity/runs", integrityHandler.GetIntegrityRuns)
+			admin.GET("/integrity/runs/:id", integrityHandler.GetIntegrityRun)
+			admin.GET("/integrity/runs/:id/findings", integrityHandler.GetIntegrityFindings)
+			admin.GET("/integrity-report", integrityHandler.GetIntegrityReport)
+			admin.GET("/indexes", databaseIndexHandler.GetDatabaseIndexes)
+			admin.POST("/indexes/build", databaseIndexHandler.BuildDatabaseIndexes)
+			admin.POST("/indexes/:name/rebuild", databaseIndexHandler.RebuildDatabaseIndex)
+			admin.GET("/analytics-views", analyticsViewHandler.GetAnalyticsViews)
+			admin.POST("/analytics-views/refresh", analyticsViewHandler.RefreshAnalyticsViews)
+			admin.GET("/write-checks", writeCheckHandler.GetWriteChecks)
+			admin.GET("/event-consumers", eventReplayHandler.GetEventConsumers)
+			admin.GET("/event-replays", eventReplayHandler.GetEventReplays)
+			admin.POST("/event-replays", eventReplayHandler.CreateEventReplay)
+			admin.GET("/event-replays/:id", eventReplayHandler.GetEventReplay)
+			admin.POST("/event-replays/:id/resume", eventReplayHandler.ResumeEventReplay)
+		}
+
+		// Validation profile endpoints (admin only)
+		profiles := protected.Group("/validation-profiles")
+		profiles.Use(auth.RequireRole("admin"))
+		{
+			profiles.GET("", validationProfileHandler.GetProfiles)
+			profiles.POST("", validationProfileHandler.CreateProfile)
+			profiles.PUT("/:id", validationProfileHandler.UpdateProfile)
+			profiles.DELETE("/:id", validationProfileHandler.DeleteProfile)
+		}
+
+		// Clinical note endpoints
+		notes := protected.Group("/clinical-notes")
+		{
+			notes.POST("", auth.RequireRole("practitioner", "admin", "nurse"), clinicalNoteHandler.CreateNote)
+			notes.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), clinicalNoteHandler.GetNote)
+			notes.PUT("/:id", auth.RequireRole("practitioner", "admin", "nurse"), clinicalNoteHandler.UpdateNote)
+			notes.POST("/:id/sign", auth.RequireRole("practitioner", "admin", "nurse"), clinicalNoteHandler.SignNote)
+			notes.POST("/:id/addenda", auth.RequireRole("practitioner", "admin", "nurse"), clinicalNoteHandler.AddAddendum)
+			notes.GET("/:id/versions", auth.RequireRole("practitioner", "admin", "nurse"), clinicalNoteHandler.GetNoteVersions)
+		}
+
+		// Questionnaire endpoints
+		questionnaires := protected.Group("/questionnaires")
+		{
+			questionnaires.POST("", auth.RequireRole("admin"), questionnaireHandler.CreateQuestionnaire)
+			questionnaires.GET("", auth.RequireRole("practitioner", "admin", "nurse"), questionnaireHandler.GetQuestionnaires)
+			questionnaires.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), questionnaireHandler.GetQuestionnaire)
+			questionnaires.PUT("/:id", auth.RequireRole("admin"), questionnaireHandler.UpdateQuestionnaire)
+			questionnaires.POST("/:id/responses", auth.RequireRole("practitioner", "admin", "nurse"), questionnaireHandler.SubmitResponse)
+		}
+		protected.GET("/questionnaire-responses/:id", auth.RequireRole("practitioner", "admin", "nurse"), questionnaireHandler.GetResponse)
+
+		// Export endpoints
+		exports := protected.Group("/exports")
+		{
+			exports.GET("/patients/deidentified", auth.RequireRole("admin"), exportHandler.ExportDeidentifiedPatients)
+			exports.POST("/jobs", auth.RequireRole("admin"), exportHandler.CreateExportJob)
+			exports.GET("/jobs/:id", auth.RequireRole("admin"), exportHandler.GetExportJob)
+			exports.GET("/jobs/:id/files/:name", auth.RequireRole("admin"), exportHandler.DownloadExportFile)
+			exports.GET("/schedules", auth.RequireRole("admin"), exportScheduleHandler.GetExportSchedules)
+			exports.POST("/schedules", auth.RequireRole("admin"), exportScheduleHandler.CreateExportSchedule)
+			exports.GET("/schedules/:id", auth.RequireRole("admin"), exportScheduleHandler.GetExportSchedule)
+			exports.PUT("/schedules/:id", auth.RequireRole("admin"), exportScheduleHandler.UpdateExportSchedule)
+			exports.DELETE("/schedules/:id", auth.RequireRole("admin"), exportScheduleHandler.DeleteExportSchedule)
+			exports.POST("/schedules/:id/run", auth.RequireRole("admin"), exportScheduleHandler.RunExportSchedule)
+			exports.GET("/schedules/:id/runs", auth.RequireRole("admin"), exportScheduleHandler.GetExportScheduleRuns)
+		}
+
+		// Analytics endpoints
+		analytics := protected.Group("/analytics")
+		{
+			analytics.GET("/demographics", auth.RequireRole("admin", "research"), analyticsHandler.GetDemographics)
+			analytics.GET("/reference-range-breaches", auth.RequireRole("practitioner", "admin", "lab-tech", "research"), analyticsHandler.GetReferenceRangeBreaches)
+			analytics.GET("/escalations", auth.RequireRole("admin"), analyticsHandler.GetEscalationReport)
+			analytics.GET("/turnaround", auth.RequireRole("practitioner", "admin", "lab-tech"), analyticsHandler.GetTurnaround)
+			analytics.GET("/specimen-rejections", auth.RequireRole("practitioner", "admin", "lab-tech", "research"), analyticsHandler.GetSpecimenRejections)
+			analytics.GET("/observation-volume", auth.RequireRole("practitioner", "admin", "lab-tech"), analyticsHandler.GetObservationVolume)
+			analytics.GET("/latest-observations", auth.RequireRole("practitioner", "admin"), analyticsHandler.GetLatestObservations)
+		}
+
+		// Laboratory quality control endpoints
+		qcRoutes := protected.Group("/qc")
+		{
+			qcRoutes.GET("/controls", auth.RequireRole("practitioner", "admin", "lab-tech"), qcHandler.GetControls)
+			qcRoutes.POST("/controls", auth.RequireRole("admin", "lab-tech"), qcHandler.CreateControl)
+			qcRoutes.POST("/controls/:id/results", auth.RequireRole("admin", "lab-tech"), qcHandler.RecordResult)
+			qcRoutes.GET("/levey-jennings", auth.RequireRole("practitioner", "admin", "lab-tech"), qcHandler.GetLeveyJennings)
+		}
+
+		// Delta check rule endpoints
+		deltaChecks := protected.Group("/delta-check-rules")
+		{
+			deltaChecks.GET("", auth.RequireRole("admin"), deltaCheckHandler.GetRules)
+			deltaChecks.PUT("/:code", auth.RequireRole("admin"), deltaCheckHandler.PutRule)
+			deltaChecks.DELETE("/:code", auth.RequireRole("admin"), deltaCheckHandler.DeleteRule)
+		}
+
+		// Specimen endpoints
+		specimens := protected.Group("/specimens")
+		{
+			specimens.GET("", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), specimenHandler.GetSpecimens)
+			specimens.POST("", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), specimenHandler.CreateSpecimen)
+			specimens.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), specimenHandler.GetSpecimen)
+			specimens.PUT("/:id", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), specimenHandler.UpdateSpecimen)
+			specimens.DELETE("/:id", auth.RequireRole("admin"), specimenHandler.DeleteSpecimen)
+			specimens.POST("/:id/reject", auth.RequireRole("admin", "lab-tech"), specimenHandler.RejectSpecimen)
+		}
+
+		// Device endpoints
+		devices := protected.Group("/devices")
+		{
+			devices.GET("", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), deviceHandler.GetDevices)
+			devices.POST("", auth.RequireRole("admin", "lab-tech"), deviceHandler.CreateDevice)
+			devices.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), deviceHandler.GetDevice)
+			devices.PUT("/:id", auth.RequireRole("admin", "lab-tech"), deviceHandler.UpdateDevice)
+			devices.DELETE("/:id", auth.RequireRole("admin"), deviceHandler.DeleteDevice)
+		}
+
+		// Standing order endpoints
+		standingOrders := protected.Group("/standing-orders")
+		{
+			standingOrders.GET("", auth.RequireRole("practitioner", "admin", "nurse"), standingOrderHandler.GetOrders)
+			standingOrders.POST("", auth.RequireRole("practitioner", "admin"), standingOrderHandler.CreateOrder)
+			standingOrders.GET("/compliance", auth.RequireRole("practitioner", "admin", "nurse"), standingOrderHandler.GetCompliance)
+			standingOrders.GET("/:id/slots", auth.RequireRole("practitioner", "admin", "nurse"), standingOrderHandler.GetOrderSlots)
+			standingOrders.DELETE("/:id", auth.RequireRole("practitioner", "admin"), standingOrderHandler.DiscontinueOrder)
+		}
+
+		// Medication endpoints
+		medicationRequests := protected.Group("/medication-requests")
+		{
+			medicationRequests.GET("", auth.RequireRole("practitioner", "admin", "nurse"), medicationHandler.GetRequests)
+			medicationRequests.POST("", auth.RequireRole("practitioner", "admin"), medicationHandler.CreateRequest)
+			medicationRequests.POST("/:id/status", auth.RequireRole("practitioner", "admin"), medicationHandler.UpdateRequestStatus)
+		}
+		protected.POST("/medication-administrations", auth.RequireRole("practitioner", "admin", "nurse"), medicationHandler.RecordAdministration)
+		// Terminology endpoints
+		terminologyGroup := protected.Group("/terminology")
+		{
+			terminologyGroup.POST("/$translate", terminologyHandler.Translate)
+			terminologyGroup.GET("/mappings", auth.RequireRole("practitioner", "admin"), terminologyHandler.GetMappings)
+			terminologyGroup.POST("/mappings", auth.RequireRole("admin"), terminologyHandler.PutMappings)
+			terminologyGroup.DELETE("/mappings/:id", auth.RequireRole("admin"), terminologyHandler.DeleteMapping)
+			terminologyGroup.GET("/$lookup", terminologyHandler.Lookup)
+			terminologyGroup.GET("/displays", auth.RequireRole("practitioner", "admin"), terminologyHandler.GetDisplays)
+			terminologyGroup.POST("/displays", auth.RequireRole("admin"), terminologyHandler.PutDisplays)
+			terminologyGroup.DELETE("/displays/:id", auth.RequireRole("admin"), terminologyHandler.DeleteDisplay)
+		}
+
+		billingGroup := protected.Group("/billing")
+		{
+			billingGroup.POST("/claims", auth.RequireRole("admin"), billingHandler.ExtractClaims)
+			billingGroup.GET("/fees", auth.RequireRole("admin"), billingHandler.GetFees)
+			billingGroup.POST("/fees", auth.RequireRole("admin"), billingHandler.PutFees)
+			billingGroup.GET("/charge-rules", auth.RequireRole("admin"), chargeHandler.GetChargeRules)
+			billingGroup.POST("/charge-rules", auth.RequireRole("admin"), chargeHandler.PutChargeRule)
+			billingGroup.DELETE("/charge-rules/:id", auth.RequireRole("admin"), chargeHandler.DeleteChargeRule)
+			billingGroup.GET("/charges", auth.RequireRole("admin"), chargeHandler.GetCharges)
+			billingGroup.GET("/charges/reconciliation", auth.RequireRole("admin"), chargeHandler.GetChargeReconciliation)
+			billingGroup.POST("/charges/:id/status", auth.RequireRole("admin"), chargeHandler.UpdateChargeStatus)
+		}
+		conditions := protected.Group("/conditions")
+		{
+			conditions.POST("", auth.RequireRole("practitioner", "admin"), conditionHandler.CreateCondition)
+			conditions.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), conditionHandler.GetCondition)
+			conditions.PUT("/:id", auth.RequireRole("practitioner", "admin"), conditionHandler.UpdateCondition)
+			conditions.DELETE("/:id", auth.RequireRole("admin"), conditionHandler.DeleteCondition)
+			conditions.POST("/:id/undelete", auth.RequireRole("admin"), conditionHandler.UndeleteCondition)
+			conditions.POST("/:id/status", auth.RequireRole("practitioner", "admin"), conditionHandler.UpdateConditionStatus)
+		}
+		allergies := protected.Group("/allergy-intolerances")
+		{
+			allergies.POST("", auth.RequireRole("practitioner", "admin", "nurse"), allergyHandler.CreateAllergy)
+			allergies.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), allergyHandler.GetAllergy)
+			allergies.PUT("/:id", auth.RequireRole("practitioner", "admin"), allergyHandler.UpdateAllergy)
+			allergies.DELETE("/:id", auth.RequireRole("admin"), allergyHandler.DeleteAllergy)
+			allergies.POST("/:id/undelete", auth.RequireRole("admin"), allergyHandler.UndeleteAllergy)
+			allergies.POST("/:id/status", auth.RequireRole("practitioner", "admin"), allergyHandler.UpdateAllergyStatus)
+		}
+		procedures := protected.Group("/procedures")
+		{
+			procedures.POST("", auth.RequireRole("practitioner", "admin"), procedureHandler.CreateProcedure)
+			procedures.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), procedureHandler.GetProcedure)
+			procedures.PUT("/:id", auth.RequireRole("practitioner", "admin"), procedureHandler.UpdateProcedure)
+			procedures.DELETE("/:id", auth.RequireRole("admin"), procedureHandler.DeleteProcedure)
+			procedures.POST("/:id/undelete", auth.RequireRole("admin"), procedureHandler.UndeleteProcedure)
+		}
+		coverages := protected.Group("/coverages")
+		{
+			coverages.POST("", auth.RequireRole("practitioner", "admin", "nurse"), coverageHandler.CreateCoverage)
+			coverages.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), coverageHandler.GetCoverage)
+			coverages.PUT("/:id", auth.RequireRole("practitioner", "admin", "nurse"), coverageHandler.UpdateCoverage)
+			coverages.DELETE("/:id", auth.RequireRole("admin"), coverageHandler.DeleteCoverage)
+			coverages.POST("/:id/undelete", auth.RequireRole("admin"), coverageHandler.UndeleteCoverage)
+		}
+		carePlans := protected.Group("/care-plans")
+		carePlans.Use(auth.RequireRole("practitioner", "admin", "nurse"))
+		{
+			carePlans.POST("", carePlanHandler.CreateCarePlan)
+			carePlans.GET("/:id", carePlanHandler.GetCarePlan)
+			carePlans.PUT("/:id", carePlanHandler.UpdateCarePlan)
+			carePlans.POST("/:id/status", carePlanHandler.UpdateCarePlanStatus)
+			carePlans.POST("/:id/activities", carePlanHandler.AddCarePlanActivity)
+			carePlans.POST("/:id/activities/:activityId/complete", carePlanHandler.CompleteCarePlanActivity)
+		}
+		chartSnapshots := protected.Group("/chart-snapshots")
+		chartSnapshots.Use(auth.RequireRole("compliance", "admin"))
+		{
+			chartSnapshots.GET("/:id", chartSnapshotHandler.GetChartSnapshot)
+			chartSnapshots.GET("/:id/content", chartSnapshotHandler.GetChartSnapshotContent)
+		}
+		legalHolds := protected.Group("/legal-holds")
+		{
+			legalHolds.GET("", auth.RequireRole("compliance", "admin"), legalHoldHandler.GetLegalHolds)
+			legalHolds.POST("", auth.RequireRole("compliance"), legalHoldHandler.CreateLegalHold)
+			legalHolds.GET("/:id", auth.RequireRole("compliance", "admin"), legalHoldHandler.GetLegalHold)
+			legalHolds.POST("/:id/release", auth.RequireRole("compliance"), legalHoldHandler.ReleaseLegalHold)
+		}
+		practitioners := protected.Group("/practitioners")
+		{
+			practitioners.GET("", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), practitionerHandler.GetPractitioners)
+			practitioners.POST("", auth.RequireRole("admin"), practitionerHandler.CreatePractitioner)
+			practitioners.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), practitionerHandler.GetPractitioner)
+			practitioners.PUT("/:id", auth.RequireRole("admin"), practitionerHandler.UpdatePractitioner)
+			practitioners.DELETE("/:id", auth.RequireRole("admin"), practitionerHandler.DeletePractitioner)
+		}
+		drugInteractions := protected.Group("/drug-interactions")
+		{
+			drugInteractions.GET("", auth.RequireRole("practitioner", "admin"), drugInteractionHandler.GetInteractions)
+			drugInteractions.POST("", auth.RequireRole("admin"), drugInteractionHandler.PutInteraction)
+			drugInteractions.DELETE("/:id", auth.RequireRole("admin"), drugInteractionHandler.DeleteInteraction)
+		}
+
+		// Alert endpoints
+		alerts := protected.Group("/alerts")
+		{
+			alerts.GET("", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), alertHandler.GetAlerts)
+			alerts.POST("/:id/acknowledge", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), alertHandler.AcknowledgeAlert)
+			alerts.GET("/:id/escalations", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), alertHandler.GetAlertEscalations)
+		}
+
+		// On-call chain endpoints
+		onCallChains := protected.Group("/on-call-chains")
+		{
+			onCallChains.GET("", auth.RequireRole("admin"), onCallChainHandler.GetChains)
+			onCallChains.PUT("/:department", auth.RequireRole("admin"), onCallChainHandler.PutChain)
+			onCallChains.DELETE("/:department", auth.RequireRole("admin"), onCallChainHandler.DeleteChain)
+		}
+
+		// Change data capture feed for data warehouse pipelines
+		protected.GET("/changes", auth.RequireRole("admin"), changeHandler.GetChanges)
+
+		// Media endpoints
+		media := protected.Group("/media")
+		{
+			media.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), mediaHandler.GetMedia)
+			media.DELETE("/:id", auth.RequireRole("admin"), mediaHandler.DeleteMedia)
+			media.POST("/:id/undelete", auth.RequireRole("admin"), mediaHandler.UndeleteMedia)
+		}
+
+		// Document endpoints
+		documents := protected.Group("/document-references")
+		{
+			documents.GET("/:id", auth.RequireRole("practitioner", "admin", "nurse"), documentHandler.GetDocumentReference)
+			documents.POST("/:id/status", auth.RequireRole("practitioner", "admin"), documentHandler.UpdateDocumentStatus)
+			documents.DELETE("/:id", auth.RequireRole("admin"), documentHandler.DeleteDocumentReference)
+			documents.POST("/:id/undelete", auth.RequireRole("admin"), documentHandler.UndeleteDocumentReference)
 		}
+
+		// Contract-test fixtures reset shared environments and never run in production
+		if !cfg.IsProduction() {
+			fixtureRoutes := protected.Group("/_fixtures")
+			fixtureRoutes.Use(auth.RequireRole("practitioner", "admin"))
+			{
+				fixtureRoutes.GET("", fixtureHandler.GetFixtures)
+				fixtureRoutes.POST("/reset", fixtureHandler.ResetFixtures)
+				fixtureRoutes.POST("/entities", fixtureHandler.CreateFixtureEntity)
+			}
+		}
+	}
+
+	// Inbound integrations, such as lab interfaces and devices pushing results,
+	// authenticate with signed requests instead of tokens
+	inboundRoutes := r.Group("/api/v1/inbound")
+	inboundRoutes.Use(priorityLimiter.Middleware())
+	inboundRoutes.Use(timeout.Middleware(requestTimeouts))
+	inboundRoutes.Use(inboundVerifier.Middleware())
+	inboundRoutes.Use(quarantineQueue.Middleware())
+	inboundRoutes.Use(inboundMapper.Middleware())
+	inboundRoutes.Use(handlers.ConsistencyMiddleware(dbRouter))
+	inboundRoutes.Use(captureRecorder.Middleware())
+	inboundRoutes.Use(handlers.SandboxMiddleware(db))
+	{
+		inboundRoutes.POST("/observations", auth.RequireRole("practitioner", "admin", "lab-tech"), observationHandler.CreateObservation)
+		inboundRoutes.POST("/specimens", auth.RequireRole("practitioner", "admin", "nurse", "lab-tech"), specimenHandler.CreateSpecimen)
+	}
+
+	// Reprocessed messages are resubmitted through the inbound routes
+	quarantineQueue.SetHandler(r)
+
+	// Open connections and prepare the hottest queries before listening, so the first
+	// requests after a deploy are not slowed by work every later request is spared
+	if cfg.WarmupEnabled {
+		warmupCtx, cancelWarmup := context.WithTimeout(context.Background(), time.Duration(cfg.WarmupTimeoutSeconds)*time.Second)
+		warmupOpts := warmup.Options{
+			Connections: cfg.WarmupConnections,
+			Queries:     handlers.WarmupQueries(),
+			Models:      database.Models(),
+		}
+		names, targets := []string{"primary"}, []*gorm.DB{db}
+		if replica != nil {
+			names, targets = append(names, "replica"), append(targets, replica)
+		}
+		for i, target := range targets {
+			result, err := warmup.Run(warmupCtx, target, warmupOpts)
+			fields := []zap.Field{
+				zap.String("database", names[i]),
+				zap.Int("connections", result.Connections),
+				zap.Int("queries", result.Queries),
+				zap.Int("models", result.Models),
+				zap.Duration("duration", result.Duration),
+			}
+			if err != nil {
+				logger.Warn("Database warm-up incomplete", append(fields, zap.Int("failed", result.Failed), zap.Error(err))...)
+				continue
+			}
+			logger.Info("Database warmed up", fields...)
+		}
+		cancelWarmup()
 	}
 
 	// Start server with graceful shutdown
@@ -234,15 +1277,24 @@ func main() {
 	signal.Notify(quit, syscall.SIGINT, syscall.SIGTERM)
 	<-quit
 	logger.Info("Server shutting down...")
+	stopWorkers()
 
 	// Give outstanding requests a deadline for completion
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
 
-	if err := srv.Shutdown(ctx); err != nil {
+	metricsRegistry.StartDrain()
+	err = srv.Shutdown(ctx)
+	logDrainSummary(metricsRegistry.DrainSummary())
+	if err != nil {
 		logger.Fatal("Server forced to shutdown", zap.Error(err))
 	}
 
+	// Requests served since the last flush, including the drained ones, are counted
+	if err := usageTracker.Flush(context.Background()); err != nil {
+		logger.Error("Failed to store API usage", zap.Error(err))
+	}
+
 	// Close database connection
 	if err := database.CloseDB(db); err != nil {
 		logger.Error("Failed to close database connection", zap.Error(err))
@@ -250,3 +1302,54 @@ func main() {
 
 	logger.Info("Server exited")
 }
+
+// logDrainSummary logs how the requests being served at shutdown ended, so
+// interrupted requests show up in rollouts
+func logDrainSummary(summary metrics.DrainSummary) {
+	fields := []zap.Field{
+		zap.Int("in_flight", summary.InFlight),
+		zap.Int("drained", summary.Drained),
+		zap.Int("aborted", summary.Aborted),
+		zap.Duration("duration", summary.Duration),
+	}
+	if summary.Aborted == 0 {
+		logger.Info("Drained in-flight requests", fields...)
+		return
+	}
+	logger.Warn("Aborted in-flight requests at shutdown", append(fields, zap.Any("aborted_routes", summary.AbortedRoutes))...)
+}
+
+// newAuditArchiver creates the audit log archiver from the configuration
+func newAuditArchiver(cfg *config.Config, db *gorm.DB) (*audit.Archiver, error) {
+	store, err := transfer.NewS3(transfer.Options{
+		Bucket:      cfg.AuditArchiveBucket,
+		Region:      cfg.AuditArchiveRegion,
+		Endpoint:    cfg.AuditArchiveEndpoint,
+		Prefix:      cfg.AuditArchivePrefix,
+		AccessKeyID: cfg.AuditArchiveAccessKeyID,
+		Secret:      cfg.AuditArchiveSecretKey,
+	})
+	if err != nil {
+		return nil, err
+	}
+	signingKey, err := audit.ParseSigningKey(cfg.AuditArchiveSigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	opts := audit.Options{
+		Store:      store,
+		SigningKey: signingKey,
+		LockMode:   cfg.AuditArchiveLockMode,
+		Retention:  time.Duration(cfg.AuditArchiveRetentionDays) * 24 * time.Hour,
+		BatchSize:  cfg.AuditArchiveBatchSize,
+		Interval:   time.Duration(cfg.AuditArchiveIntervalMinutes) * time.Minute,
+	}
+	if cfg.AuditArchiveEncryption != "" {
+		if opts.Recipient, err = encryption.ParseRecipient(cfg.AuditArchiveEncryption, cfg.AuditArchiveRecipient); err != nil {
+			return nil, err
+		}
+		opts.EncryptionFormat = cfg.AuditArchiveEncryption
+	}
+	return audit.NewArchiver(db, opts), nil
+}
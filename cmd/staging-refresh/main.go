@@ -0,0 +1,275 @@
+// Command staging-refresh replaces the clinical data of a staging database with a
+// scrambled copy of production, so developers can test against realistic volumes
+// without access to PHI.
+//
+// Names, identifiers, contacts and free text are replaced and every date of a
+// patient is moved by a stable per-patient offset (see the deid package). Staff
+// accounts, jobs and credentials in the staging database are left untouched, and
+// media files are not copied. The outbox is rebuilt so the staging search indexer
+// reindexes the new data.
+//
+// Usage:
+//
+//	DATABASE_URL=... STAGING_DATABASE_URL=... DEID_SECRET=... staging-refresh -yes
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/internal/backup"
+	"github.com/hillmatthew2000/HealthHub/internal/config"
+	"github.com/hillmatthew2000/HealthHub/internal/deid"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/cluster"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// refreshedTables are emptied in the staging database before the copy. Outbox
+// tables are included so the search indexer starts again from the first event.
+var refreshedTables = []string{
+	"patients", "patient_links", "observations", "media",
+	"clinical_notes", "note_addenda", "clinical_note_versions",
+	"questionnaires", "questionnaire_responses",
+	"observation_categories", "validation_profiles",
+	"outbox_events", "event_checkpoints",
+}
+
+func main() {
+	cfg := config.Load()
+
+	source := flag.String("source", cfg.DatabaseURL, "production database URL")
+	target := flag.String("target", cfg.StagingDatabaseURL, "staging database URL")
+	batchSize := flag.Int("batch", 500, "rows copied per batch")
+	confirm := flag.Bool("yes", false, "replace the clinical data in the staging database")
+	flag.Parse()
+
+	logger.Init(cfg.LogLevel)
+	defer logger.Sync()
+
+	if err := run(cfg, *source, *target, *batchSize, *confirm); err != nil {
+		logger.Error("Staging refresh failed", zap.Error(err))
+		os.Exit(1)
+	}
+}
+
+func run(cfg *config.Config, sourceURL, targetURL string, batchSize int, confirm bool) error {
+	if targetURL == "" {
+		return fmt.Errorf("no staging database given; set STAGING_DATABASE_URL or -target")
+	}
+	if database.SameDatabase(sourceURL, targetURL) {
+		return fmt.Errorf("the staging database is the production database")
+	}
+	if !confirm {
+		return fmt.Errorf("this replaces the clinical data in the staging database; pass -yes to continue")
+	}
+	if batchSize < 1 {
+		batchSize = 500
+	}
+
+	// Pseudonyms follow the export secret, so they match de-identified exports
+	secret := cfg.DeidentificationSecret
+	if secret == "" {
+		secret = cfg.EncryptionKey
+	}
+	deidentifier := deid.New(secret)
+
+	source, err := connect(sourceURL)
+	if err != nil {
+		return fmt.Errorf("source: %w", err)
+	}
+	defer database.CloseDB(source)
+
+	target, err := connect(targetURL)
+	if err != nil {
+		return fmt.Errorf("target: %w", err)
+	}
+	defer database.CloseDB(target)
+
+	// A restore into staging must not run at the same time
+	lock, err := cluster.Acquire(context.Background(), target, backup.StagingLock)
+	if errors.Is(err, cluster.ErrLocked) {
+		return fmt.Errorf("the staging database is being restored or refreshed by another process")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock the staging database: %w", err)
+	}
+	defer lock.Release()
+
+	if err := database.AutoMigrate(target); err != nil {
+		return err
+	}
+
+	// Previously indexed staging patients are removed from the search index by
+	// deleted events recorded after the outbox is emptied
+	var previous []string
+	if err := target.Model(&models.Patient{}).Unscoped().Pluck("id", &previous).Error; err != nil {
+		return fmt.Errorf("failed to list staging patients: %w", err)
+	}
+	if err := target.Exec("TRUNCATE TABLE " + strings.Join(refreshedTables, ", ") + " RESTART IDENTITY CASCADE").Error; err != nil {
+		return fmt.Errorf("failed to empty staging tables: %w", err)
+	}
+	for start := 0; start < len(previous); start += batchSize {
+		end := min(start+batchSize, len(previous))
+		if err := models.RecordEvents(target, "Patient", previous[start:end], models.EventActionDeleted); err != nil {
+			return fmt.Errorf("failed to record staging deletes: %w", err)
+		}
+	}
+
+	c := copier{source: source, target: target, batchSize: batchSize}
+	steps := []struct {
+		table string
+		copy  func() (int, error)
+	}{
+		{"observation_categories", func() (int, error) { return copyRows[models.ObservationCategory](c, nil) }},
+		{"validation_profiles", func() (int, error) { return copyRows[models.ValidationProfile](c, nil) }},
+		{"questionnaires", func() (int, error) { return copyRows[models.Questionnaire](c, nil) }},
+		{"patients", func() (int, error) {
+			return copyRows(c, func(rows []models.Patient) error {
+				ids := make([]string, len(rows))
+				for i := range rows {
+					deidentifier.ScramblePatient(&rows[i])
+					ids[i] = rows[i].ID
+				}
+				return models.RecordEvents(target, "Patient", ids, models.EventActionCreated)
+			})
+		}},
+		{"patient_links", func() (int, error) { return copyRows[models.PatientLink](c, nil) }},
+		{"observations", func() (int, error) {
+			return copyRows(c, func(rows []models.Observation) error {
+				ids := make([]string, len(rows))
+				for i := range rows {
+					deidentifier.ScrambleObservation(&rows[i])
+					ids[i] = rows[i].ID
+				}
+				return models.RecordEvents(target, "Observation", ids, models.EventActionCreated)
+			})
+		}},
+		{"media", func() (int, error) {
+			return copyRows(c, func(rows []models.Media) error {
+				for i := range rows {
+					deidentifier.ScrambleMedia(&rows[i])
+				}
+				return nil
+			})
+		}},
+		{"clinical_notes", func() (int, error) { return c.copyNotes(deidentifier) }},
+		{"questionnaire_responses", func() (int, error) {
+			return copyRows(c, func(rows []models.QuestionnaireResponse) error {
+				for i := range rows {
+					deidentifier.ScrambleResponse(&rows[i])
+				}
+				return nil
+			})
+		}},
+	}
+
+	for _, step := range steps {
+		copied, err := step.copy()
+		if err != nil {
+			return fmt.Errorf("failed to copy %s: %w", step.table, err)
+		}
+		logger.Info("Copied table", zap.String("table", step.table), zap.Int("rows", copied))
+	}
+
+	logger.LogAuditEvent("staging_refresh", "Database", "system", map[string]interface{}{
+		"target": redactURL(targetURL),
+	})
+	return nil
+}
+
+// connect opens a database without logging every statement of the copy
+func connect(databaseURL string) (*gorm.DB, error) {
+	db, err := database.NewPostgresDB(databaseURL)
+	if err != nil {
+		return nil, err
+	}
+	db.Logger = gormlogger.Default.LogMode(gormlogger.Warn)
+	return db, nil
+}
+
+// copier copies tables from the source to the target database in batches
+type copier struct {
+	source    *gorm.DB
+	target    *gorm.DB
+	batchSize int
+}
+
+// copyRows copies every live row of a model, passing each batch through scramble
+// first. Model hooks are skipped on insert so the copy keeps its IDs and does not
+// emit events of its own.
+func copyRows[T any](c copier, scramble func([]T) error) (int, error) {
+	var rows []T
+	copied := 0
+	err := c.source.Model(new(T)).FindInBatches(&rows, c.batchSize, func(tx *gorm.DB, batch int) error {
+		if scramble != nil {
+			if err := scramble(rows); err != nil {
+				return err
+			}
+		}
+		if err := c.insert(&rows); err != nil {
+			return err
+		}
+		copied += len(rows)
+		return nil
+	}).Error
+	return copied, err
+}
+
+// copyNotes copies clinical notes with their addenda and stored revisions, which
+// take the date shift of the note's subject
+func (c copier) copyNotes(deidentifier *deid.Deidentifier) (int, error) {
+	var notes []models.ClinicalNote
+	copied := 0
+	err := c.source.Preload("Addenda").FindInBatches(&notes, c.batchSize, func(tx *gorm.DB, batch int) error {
+		ids := make([]string, len(notes))
+		subjects := make(map[string]string, len(notes))
+		for i := range notes {
+			ids[i] = notes[i].ID
+			subjects[notes[i].ID] = strings.TrimPrefix(notes[i].Subject.Reference, "Patient/")
+			deidentifier.ScrambleNote(&notes[i])
+		}
+		if err := c.insert(&notes); err != nil {
+			return err
+		}
+
+		var versions []models.ClinicalNoteVersion
+		if err := c.source.Where("note_id IN ?", ids).Find(&versions).Error; err != nil {
+			return err
+		}
+		for i := range versions {
+			deidentifier.ScrambleNoteVersion(&versions[i], subjects[versions[i].NoteID])
+		}
+		if len(versions) > 0 {
+			if err := c.insert(&versions); err != nil {
+				return err
+			}
+		}
+
+		copied += len(notes)
+		return nil
+	}).Error
+	return copied, err
+}
+
+// insert writes rows to the target without running model hooks
+func (c copier) insert(rows interface{}) error {
+	return c.target.Session(&gorm.Session{SkipHooks: true}).CreateInBatches(rows, c.batchSize).Error
+}
+
+// redactURL drops the password from a database URL for logging
+func redactURL(databaseURL string) string {
+	if u, err := url.Parse(databaseURL); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return "(connection string)"
+}
@@ -0,0 +1,237 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/encryption"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// settleDelay keeps entries out of archives until concurrent inserts with lower
+// IDs have committed, so no entry is skipped
+const settleDelay = time.Minute
+
+var archivedEntries = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "audit_entries_archived_total",
+	Help: "Total number of audit log entries written to write-once archives",
+})
+
+// Store is write-once object storage, such as an S3 bucket with Object Lock
+type Store interface {
+	// PutLocked writes an object that cannot be changed or deleted before retainUntil
+	PutLocked(name string, r io.Reader, mode string, retainUntil time.Time) (int64, error)
+	// Get reads an object
+	Get(name string) (io.ReadCloser, error)
+	// Retention returns the lock mode and retain-until date of an object
+	Retention(name string) (string, time.Time, error)
+}
+
+// Options configures an archiver
+type Options struct {
+	Store      Store
+	SigningKey ed25519.PrivateKey
+	// Recipient optionally encrypts archives; EncryptionFormat names its format
+	Recipient        encryption.Recipient
+	EncryptionFormat string
+	LockMode         string // GOVERNANCE or COMPLIANCE
+	Retention        time.Duration
+	BatchSize        int
+	Interval         time.Duration
+}
+
+// Archiver ships audit log entries to write-once storage. Each archive holds a batch
+// of consecutive entries as gzip-compressed JSON Lines, optionally encrypted, with a
+// signed manifest stored next to it.
+type Archiver struct {
+	db   *gorm.DB
+	opts Options
+}
+
+// NewArchiver creates a new archiver
+func NewArchiver(db *gorm.DB, opts Options) *Archiver {
+	if opts.BatchSize <= 0 {
+		opts.BatchSize = 10000
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = time.Hour
+	}
+	return &Archiver{db: db, opts: opts}
+}
+
+// Run archives new entries on every interval until the context is cancelled
+func (a *Archiver) Run(ctx context.Context) {
+	ticker := time.NewTicker(a.opts.Interval)
+	defer ticker.Stop()
+
+	for {
+		archives, err := a.Archive(ctx)
+		if err != nil {
+			logger.Error("Failed to archive audit log", zap.Error(err))
+		} else if archives > 0 {
+			logger.Info("Archived audit log", zap.Int("archives", archives))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Archive writes archives until every settled entry is archived and returns how
+// many archives were written
+func (a *Archiver) Archive(ctx context.Context) (int, error) {
+	written := 0
+	for ctx.Err() == nil {
+		var previous models.AuditArchive
+		err := a.db.WithContext(ctx).Order("last_entry_id DESC").First(&previous).Error
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return written, fmt.Errorf("failed to find the last audit archive: %w", err)
+		}
+
+		archive, err := a.archiveAfter(ctx, previous)
+		if err != nil {
+			return written, err
+		}
+		if archive == nil {
+			break
+		}
+		written++
+	}
+	return written, nil
+}
+
+// archiveAfter archives the next batch of entries after the previous archive, or
+// returns nil when there are none
+func (a *Archiver) archiveAfter(ctx context.Context, previous models.AuditArchive) (*models.AuditArchive, error) {
+	var entries []models.AuditLog
+	if err := a.db.WithContext(ctx).
+		Where("id > ? AND created_at < ?", previous.LastEntryID, time.Now().Add(-settleDelay)).
+		Order("id").Limit(a.opts.BatchSize).Find(&entries).Error; err != nil {
+		return nil, fmt.Errorf("failed to read audit log: %w", err)
+	}
+	if len(entries) == 0 {
+		return nil, nil
+	}
+	first, last := entries[0], entries[len(entries)-1]
+
+	var plain bytes.Buffer
+	if err := EncodeEntries(&plain, entries); err != nil {
+		return nil, err
+	}
+	entriesHash := sha256.Sum256(plain.Bytes())
+
+	object, err := a.pack(plain.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("failed to pack audit archive: %w", err)
+	}
+	objectHash := sha256.Sum256(object)
+
+	name := fmt.Sprintf("%s/%020d-%020d.jsonl.gz", first.CreatedAt.UTC().Format("2006/01/02"), first.ID, last.ID)
+	if a.opts.Recipient != nil {
+		name += a.opts.Recipient.Extension()
+	}
+	now := time.Now().UTC()
+	archive := &models.AuditArchive{
+		ID:             uuid.New().String(),
+		FirstEntryID:   first.ID,
+		LastEntryID:    last.ID,
+		Entries:        len(entries),
+		ObjectKey:      name,
+		ManifestKey:    name + ".manifest.json",
+		SHA256:         hex.EncodeToString(objectHash[:]),
+		PreviousSHA256: previous.SHA256,
+		Bytes:          int64(len(object)),
+		RetainUntil:    now.Add(a.opts.Retention).Truncate(time.Second),
+	}
+
+	manifest := models.AuditArchiveManifest{
+		ArchiveID:      archive.ID,
+		Object:         archive.ObjectKey,
+		FirstEntryID:   archive.FirstEntryID,
+		LastEntryID:    archive.LastEntryID,
+		Entries:        archive.Entries,
+		FirstEntryAt:   first.CreatedAt.UTC(),
+		LastEntryAt:    last.CreatedAt.UTC(),
+		SHA256:         archive.SHA256,
+		EntriesSHA256:  hex.EncodeToString(entriesHash[:]),
+		PreviousSHA256: archive.PreviousSHA256,
+		LockMode:       a.opts.LockMode,
+		RetainUntil:    archive.RetainUntil,
+		GeneratedAt:    now,
+	}
+	if a.opts.Recipient != nil {
+		manifest.Encryption = &models.ExportEncryption{Format: a.opts.EncryptionFormat, Fingerprint: a.opts.Recipient.Fingerprint()}
+	}
+	signed, err := SignManifest(manifest, a.opts.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+
+	// The manifest goes last: an archive without one is an incomplete upload
+	if _, err := a.opts.Store.PutLocked(archive.ObjectKey, bytes.NewReader(object), a.opts.LockMode, archive.RetainUntil); err != nil {
+		return nil, fmt.Errorf("failed to upload audit archive: %w", err)
+	}
+	if _, err := a.opts.Store.PutLocked(archive.ManifestKey, bytes.NewReader(signed), a.opts.LockMode, archive.RetainUntil); err != nil {
+		return nil, fmt.Errorf("failed to upload audit archive manifest: %w", err)
+	}
+	if err := a.db.WithContext(ctx).Create(archive).Error; err != nil {
+		return nil, fmt.Errorf("failed to record audit archive: %w", err)
+	}
+
+	archivedEntries.Add(float64(archive.Entries))
+	logger.Info("Wrote audit archive",
+		zap.String("archive_id", archive.ID),
+		zap.String("object", archive.ObjectKey),
+		zap.Uint64("first_entry_id", archive.FirstEntryID),
+		zap.Uint64("last_entry_id", archive.LastEntryID),
+	)
+	return archive, nil
+}
+
+// pack compresses and, with a recipient, encrypts the encoded entries
+func (a *Archiver) pack(plain []byte) ([]byte, error) {
+	var out bytes.Buffer
+	var sink io.WriteCloser = nopCloser{&out}
+	if a.opts.Recipient != nil {
+		encrypted, err := a.opts.Recipient.Encrypt(&out)
+		if err != nil {
+			return nil, err
+		}
+		sink = encrypted
+	}
+
+	gz := gzip.NewWriter(sink)
+	if _, err := gz.Write(plain); err != nil {
+		return nil, err
+	}
+	if err := gz.Close(); err != nil {
+		return nil, err
+	}
+	if err := sink.Close(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
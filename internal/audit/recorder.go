@@ -0,0 +1,49 @@
+// Package audit persists audit events and ships them to write-once storage as
+// signed, compressed archives that can be verified independently of the database.
+package audit
+
+import (
+	"context"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// recordTimeout bounds the insert of a single audit entry
+const recordTimeout = 5 * time.Second
+
+// Recorder writes audit events to the audit log table
+type Recorder struct {
+	db *gorm.DB
+}
+
+// NewRecorder creates a new recorder
+func NewRecorder(db *gorm.DB) *Recorder {
+	return &Recorder{db: db}
+}
+
+// Record stores an audit event; it matches logger.AuditSink. Failures are logged
+// rather than returned, since the action being audited has already happened.
+func (r *Recorder) Record(action, resource, userID string, details map[string]interface{}) {
+	ctx, cancel := context.WithTimeout(context.Background(), recordTimeout)
+	defer cancel()
+
+	entry := models.AuditLog{
+		Action:   action,
+		Resource: resource,
+		UserID:   userID,
+		Details:  details,
+		// Stored at the database's precision, so archives and the table encode alike
+		CreatedAt: time.Now().UTC().Truncate(time.Microsecond),
+	}
+	if err := r.db.WithContext(ctx).Create(&entry).Error; err != nil {
+		logger.Error("Failed to record audit event",
+			zap.String("action", action),
+			zap.String("resource", resource),
+			zap.Error(err),
+		)
+	}
+}
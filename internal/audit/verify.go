@@ -0,0 +1,128 @@
+package audit
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"crypto/ed25519"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+)
+
+// Verifier checks archives in write-once storage against their signed manifests and
+// the audit log table
+type Verifier struct {
+	db    *gorm.DB
+	store Store
+	key   ed25519.PublicKey
+}
+
+// NewVerifier creates a new verifier for archives signed with key
+func NewVerifier(db *gorm.DB, store Store, key ed25519.PublicKey) *Verifier {
+	return &Verifier{db: db, store: store, key: key}
+}
+
+// Verify checks an archive: the manifest signature, the object digest, the
+// archive chain, the object's retention lock and that the audit log table still
+// holds exactly the archived entries. previousSHA256 is the digest of the archive
+// before it, empty for the first archive.
+func (v *Verifier) Verify(ctx context.Context, archive models.AuditArchive, previousSHA256 string) error {
+	data, err := v.read(archive.ManifestKey)
+	if err != nil {
+		return fmt.Errorf("manifest: %w", err)
+	}
+	manifest, err := VerifyManifest(data, v.key)
+	if err != nil {
+		return err
+	}
+	if manifest.ArchiveID != archive.ID || manifest.Object != archive.ObjectKey ||
+		manifest.FirstEntryID != archive.FirstEntryID || manifest.LastEntryID != archive.LastEntryID ||
+		manifest.SHA256 != archive.SHA256 {
+		return fmt.Errorf("manifest does not match the archive record")
+	}
+	if manifest.PreviousSHA256 != previousSHA256 {
+		return fmt.Errorf("archive chain is broken: manifest follows %q, previous archive is %q",
+			manifest.PreviousSHA256, previousSHA256)
+	}
+
+	object, err := v.read(archive.ObjectKey)
+	if err != nil {
+		return fmt.Errorf("object: %w", err)
+	}
+	if err := VerifyObject(manifest, object); err != nil {
+		return err
+	}
+
+	for _, name := range []string{archive.ObjectKey, archive.ManifestKey} {
+		mode, until, err := v.store.Retention(name)
+		if err != nil {
+			return fmt.Errorf("retention of %s: %w", name, err)
+		}
+		if mode != manifest.LockMode || until.Before(manifest.RetainUntil) {
+			return fmt.Errorf("%s is locked in %q mode until %s, expected %q until %s", name, mode,
+				until.Format("2006-01-02"), manifest.LockMode, manifest.RetainUntil.Format("2006-01-02"))
+		}
+	}
+
+	var entries []models.AuditLog
+	if err := v.db.WithContext(ctx).Where("id BETWEEN ? AND ?", archive.FirstEntryID, archive.LastEntryID).
+		Order("id").Find(&entries).Error; err != nil {
+		return fmt.Errorf("failed to read audit log: %w", err)
+	}
+	var plain bytes.Buffer
+	if err := EncodeEntries(&plain, entries); err != nil {
+		return err
+	}
+	if sum := sha256.Sum256(plain.Bytes()); len(entries) != manifest.Entries || hex.EncodeToString(sum[:]) != manifest.EntriesSHA256 {
+		return fmt.Errorf("audit log table differs from the archive: %d entries in the table, %d archived",
+			len(entries), manifest.Entries)
+	}
+	return nil
+}
+
+// VerifyObject checks an archive object against its manifest. The entries of an
+// unencrypted archive are checked as well; an encrypted archive can only be checked
+// this far after decrypting it.
+func VerifyObject(manifest *models.AuditArchiveManifest, object []byte) error {
+	if sum := sha256.Sum256(object); hex.EncodeToString(sum[:]) != manifest.SHA256 {
+		return fmt.Errorf("archive digest does not match the manifest")
+	}
+	if manifest.Encryption != nil {
+		return nil
+	}
+	return VerifyEntries(manifest, bytes.NewReader(object))
+}
+
+// VerifyEntries checks the gzip-compressed, decrypted entries of an archive against
+// its manifest
+func VerifyEntries(manifest *models.AuditArchiveManifest, compressed io.Reader) error {
+	gz, err := gzip.NewReader(compressed)
+	if err != nil {
+		return fmt.Errorf("archive is not gzip-compressed: %w", err)
+	}
+	plain, err := io.ReadAll(gz)
+	if err != nil {
+		return fmt.Errorf("failed to decompress archive: %w", err)
+	}
+	if sum := sha256.Sum256(plain); hex.EncodeToString(sum[:]) != manifest.EntriesSHA256 {
+		return fmt.Errorf("archived entries do not match the manifest")
+	}
+	if lines := bytes.Count(plain, []byte("\n")); lines != manifest.Entries {
+		return fmt.Errorf("archive holds %d entries, manifest lists %d", lines, manifest.Entries)
+	}
+	return nil
+}
+
+func (v *Verifier) read(name string) ([]byte, error) {
+	r, err := v.store.Get(name)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return io.ReadAll(r)
+}
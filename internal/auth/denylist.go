@@ -0,0 +1,123 @@
+package auth
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/go-redis/redis/v8"
+)
+
+// denylistPrefix namespaces the denylist's keys in Redis
+const denylistPrefix = "healthhub:denylist:"
+
+// denylistPruneInterval is how often the in-memory denylist drops entries whose
+// tokens have expired
+const denylistPruneInterval = time.Minute
+
+// redisTimeout bounds the Redis calls made while authenticating a request
+const redisTimeout = 2 * time.Second
+
+// Denylist holds the IDs of access tokens revoked before they expire. An entry only
+// needs to outlive its token, so entries are kept until the token's expiry.
+type Denylist interface {
+	// Deny revokes the token with an ID until it expires
+	Deny(ctx context.Context, tokenID string, expiresAt time.Time) error
+	// Denied reports whether the token with an ID is revoked
+	Denied(ctx context.Context, tokenID string) (bool, error)
+}
+
+// MemoryDenylist is a denylist kept in the process. Tokens revoked on one instance
+// stay valid on the others, so it suits a single instance or a fallback.
+type MemoryDenylist struct {
+	mu         sync.Mutex
+	entries    map[string]time.Time
+	lastPruned time.Time
+}
+
+// NewMemoryDenylist creates an empty in-memory denylist
+func NewMemoryDenylist() *MemoryDenylist {
+	return &MemoryDenylist{entries: make(map[string]time.Time), lastPruned: time.Now()}
+}
+
+// Deny revokes the token with an ID until it expires
+func (d *MemoryDenylist) Deny(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(d.lastPruned) >= denylistPruneInterval {
+		for id, expiry := range d.entries {
+			if now.After(expiry) {
+				delete(d.entries, id)
+			}
+		}
+		d.lastPruned = now
+	}
+	d.entries[tokenID] = expiresAt
+	return nil
+}
+
+// Denied reports whether the token with an ID is revoked
+func (d *MemoryDenylist) Denied(ctx context.Context, tokenID string) (bool, error) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	expiry, ok := d.entries[tokenID]
+	return ok && time.Now().Before(expiry), nil
+}
+
+// RedisDenylist is a denylist shared by every instance through Redis. Entries are
+// stored with the remaining lifetime of their token, so Redis expires them.
+type RedisDenylist struct {
+	client *redis.Client
+}
+
+// NewRedisDenylist connects to the Redis server at a redis:// URL, returning an error
+// when it cannot be reached
+func NewRedisDenylist(ctx context.Context, redisURL string) (*RedisDenylist, error) {
+	opts, err := redis.ParseURL(redisURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid Redis URL: %w", err)
+	}
+	client := redis.NewClient(opts)
+
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	if err := client.Ping(ctx).Err(); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("failed to connect to Redis: %w", err)
+	}
+	return &RedisDenylist{client: client}, nil
+}
+
+// Deny revokes the token with an ID until it expires
+func (d *RedisDenylist) Deny(ctx context.Context, tokenID string, expiresAt time.Time) error {
+	ttl := time.Until(expiresAt)
+	if ttl <= 0 {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	if err := d.client.Set(ctx, denylistPrefix+tokenID, 1, ttl).Err(); err != nil {
+		return fmt.Errorf("failed to deny token: %w", err)
+	}
+	return nil
+}
+
+// Denied reports whether the token with an ID is revoked
+func (d *RedisDenylist) Denied(ctx context.Context, tokenID string) (bool, error) {
+	ctx, cancel := context.WithTimeout(ctx, redisTimeout)
+	defer cancel()
+	n, err := d.client.Exists(ctx, denylistPrefix+tokenID).Result()
+	if err != nil {
+		return false, fmt.Errorf("failed to check token denylist: %w", err)
+	}
+	return n > 0, nil
+}
+
+// Close closes the connections to Redis
+func (d *RedisDenylist) Close() error {
+	return d.client.Close()
+}
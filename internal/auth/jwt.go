@@ -1,9 +1,14 @@
 package auth
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sync"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 )
 
 // Claims represents the JWT claims structure
@@ -14,23 +19,129 @@ type Claims struct {
 	jwt.RegisteredClaims
 }
 
-// TokenManager handles JWT token generation and validation
+// DefaultTokenLifetime is how long an issued access token is valid unless the token
+// manager is given another lifetime. Access tokens are renewed with refresh tokens.
+const DefaultTokenLifetime = 15 * time.Minute
+
+// reloadInterval limits how often an unknown key ID triggers a key reload
+const reloadInterval = 5 * time.Second
+
+// SigningKey is a key tokens are signed or validated with
+type SigningKey struct {
+	ID        string
+	Secret    []byte
+	ExpiresAt time.Time // Zero for keys without an expiry
+}
+
+// KeyID derives the key ID of a signing secret
+func KeyID(secret []byte) string {
+	sum := sha256.Sum256(secret)
+	return hex.EncodeToString(sum[:8])
+}
+
+// TokenManager handles JWT token generation and validation. Tokens are signed with
+// the current key and carry its ID; tokens signed with a previous key still validate
+// until that key expires, so keys can be rotated without logging users out.
 type TokenManager struct {
-	secretKey []byte
-	issuer    string
+	issuer   string
+	lifetime time.Duration
+
+	mu         sync.RWMutex
+	current    SigningKey
+	previous   []SigningKey
+	reload     func() error
+	lastReload time.Time
 }
 
 // NewTokenManager creates a new token manager
 func NewTokenManager(secretKey, issuer string) *TokenManager {
+	secret := []byte(secretKey)
 	return &TokenManager{
-		secretKey: []byte(secretKey),
-		issuer:    issuer,
+		issuer:   issuer,
+		lifetime: DefaultTokenLifetime,
+		current:  SigningKey{ID: KeyID(secret), Secret: secret},
+	}
+}
+
+// SetLifetime sets how long the access tokens issued from now on are valid
+func (tm *TokenManager) SetLifetime(lifetime time.Duration) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.lifetime = lifetime
+}
+
+// Lifetime returns how long an issued access token is valid
+func (tm *TokenManager) Lifetime() time.Duration {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.lifetime
+}
+
+// SetKeys replaces the signing key and the previous keys that are still accepted
+func (tm *TokenManager) SetKeys(current SigningKey, previous []SigningKey) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.current = current
+	tm.previous = previous
+}
+
+// SetReloader sets the function that reloads the keys when a token carries an
+// unknown key ID, as happens just after another replica rotates the key
+func (tm *TokenManager) SetReloader(reload func() error) {
+	tm.mu.Lock()
+	defer tm.mu.Unlock()
+	tm.reload = reload
+}
+
+// CurrentKeyID returns the ID of the key new tokens are signed with
+func (tm *TokenManager) CurrentKeyID() string {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.current.ID
+}
+
+func (tm *TokenManager) currentKey() SigningKey {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+	return tm.current
+}
+
+// lookupKey returns the accepted keys for a key ID; tokens issued before key IDs
+// were added are checked against every accepted key
+func (tm *TokenManager) lookupKey(id string) []SigningKey {
+	tm.mu.RLock()
+	defer tm.mu.RUnlock()
+
+	now := time.Now()
+	var keys []SigningKey
+	for _, key := range append([]SigningKey{tm.current}, tm.previous...) {
+		if !key.ExpiresAt.IsZero() && now.After(key.ExpiresAt) {
+			continue
+		}
+		if id == "" || key.ID == id {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+// reloadKeys reloads the keys, at most once per reloadInterval
+func (tm *TokenManager) reloadKeys() {
+	tm.mu.Lock()
+	reload := tm.reload
+	if reload == nil || time.Since(tm.lastReload) < reloadInterval {
+		tm.mu.Unlock()
+		return
 	}
+	tm.lastReload = time.Now()
+	tm.mu.Unlock()
+
+	reload()
 }
 
 // GenerateToken generates a JWT token for a user
 func (tm *TokenManager) GenerateToken(userID, email string, roles []string) (string, time.Time, error) {
-	expirationTime := time.Now().Add(24 * time.Hour)
+	expirationTime := time.Now().Add(tm.Lifetime())
 
 	claims := &Claims{
 		UserID: userID,
@@ -42,11 +153,14 @@ func (tm *TokenManager) GenerateToken(userID, email string, roles []string) (str
 			NotBefore: jwt.NewNumericDate(time.Now()),
 			Issuer:    tm.issuer,
 			Subject:   userID,
+			ID:        uuid.New().String(),
 		},
 	}
 
+	key := tm.currentKey()
 	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	tokenString, err := token.SignedString(tm.secretKey)
+	token.Header["kid"] = key.ID
+	tokenString, err := token.SignedString(key.Secret)
 	if err != nil {
 		return "", time.Time{}, err
 	}
@@ -56,34 +170,51 @@ func (tm *TokenManager) GenerateToken(userID, email string, roles []string) (str
 
 // ValidateToken validates a JWT token and returns the claims
 func (tm *TokenManager) ValidateToken(tokenString string) (*Claims, error) {
-	token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
-		// Validate the signing method
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, jwt.ErrSignatureInvalid
-		}
-		return tm.secretKey, nil
-	})
+	claims, err := tm.parse(tokenString)
+	if errors.Is(err, errUnknownKey) {
+		tm.reloadKeys()
+		claims, err = tm.parse(tokenString)
+	}
+	return claims, err
+}
+
+// errUnknownKey is returned when no accepted key has the token's key ID
+var errUnknownKey = errors.New("token signed with an unknown or retired key")
 
+func (tm *TokenManager) parse(tokenString string) (*Claims, error) {
+	unverified, _, err := jwt.NewParser().ParseUnverified(tokenString, &Claims{})
 	if err != nil {
 		return nil, err
 	}
-
-	if claims, ok := token.Claims.(*Claims); ok && token.Valid {
-		return claims, nil
+	id, _ := unverified.Header["kid"].(string)
+	keys := tm.lookupKey(id)
+	if len(keys) == 0 {
+		return nil, errUnknownKey
 	}
 
-	return nil, jwt.ErrTokenInvalid
-}
+	for i, key := range keys {
+		token, err := jwt.ParseWithClaims(tokenString, &Claims{}, func(token *jwt.Token) (interface{}, error) {
+			// Validate the signing method
+			if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+			return key.Secret, nil
+		})
+
+		if errors.Is(err, jwt.ErrTokenSignatureInvalid) && i < len(keys)-1 {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
 
-// RefreshToken generates a new token from an existing valid token
-func (tm *TokenManager) RefreshToken(tokenString string) (string, time.Time, error) {
-	claims, err := tm.ValidateToken(tokenString)
-	if err != nil {
-		return "", time.Time{}, err
+		if claims, ok := token.Claims.(*Claims); ok && token.Valid {
+			return claims, nil
+		}
+		break
 	}
 
-	// Generate new token with the same claims but updated expiration
-	return tm.GenerateToken(claims.UserID, claims.Email, claims.Roles)
+	return nil, jwt.ErrTokenInvalidClaims
 }
 
 // ExtractUserInfo extracts user information from claims
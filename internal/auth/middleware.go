@@ -3,45 +3,62 @@ package auth
 import (
 	"net/http"
 	"strings"
+	"sync/atomic"
 
 	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
 )
 
-// AuthMiddleware creates a middleware function for JWT authentication
-func AuthMiddleware(tokenManager *TokenManager) gin.HandlerFunc {
+// AuthMiddleware creates a middleware function for JWT authentication. Tokens on the
+// denylist are rejected; when the denylist cannot be read, tokens are accepted, as
+// they expire shortly anyway.
+func AuthMiddleware(tokenManager *TokenManager, denylist Denylist) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
 		if authHeader == "" {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Authorization header required",
-				"code":  "MISSING_AUTH_HEADER",
+			apierror.Abort(c, http.StatusUnauthorized, apierror.Response{
+				Error: "Authorization header required",
+				Code:  "MISSING_AUTH_HEADER",
 			})
-			c.Abort()
 			return
 		}
 
 		// Extract token from "Bearer <token>" format
 		tokenString := strings.TrimPrefix(authHeader, "Bearer ")
 		if tokenString == authHeader {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Bearer token required",
-				"code":  "INVALID_AUTH_FORMAT",
+			apierror.Abort(c, http.StatusUnauthorized, apierror.Response{
+				Error: "Bearer token required",
+				Code:  "INVALID_AUTH_FORMAT",
 			})
-			c.Abort()
 			return
 		}
 
 		// Validate token
 		claims, err := tokenManager.ValidateToken(tokenString)
 		if err != nil {
-			c.JSON(http.StatusUnauthorized, gin.H{
-				"error": "Invalid or expired token",
-				"code":  "INVALID_TOKEN",
+			apierror.Abort(c, http.StatusUnauthorized, apierror.Response{
+				Error: "Invalid or expired token",
+				Code:  "INVALID_TOKEN",
 			})
-			c.Abort()
 			return
 		}
 
+		if claims.ID != "" {
+			denied, err := denylist.Denied(c.Request.Context(), claims.ID)
+			if err != nil {
+				logger.Warn("Failed to check token denylist", zap.Error(err))
+			}
+			if denied {
+				apierror.Abort(c, http.StatusUnauthorized, apierror.Response{
+					Error: "Token has been revoked",
+					Code:  "TOKEN_REVOKED",
+				})
+				return
+			}
+		}
+
 		// Store user information in context
 		c.Set("user_id", claims.UserID)
 		c.Set("user_email", claims.Email)
@@ -57,33 +74,35 @@ func RequireRole(allowedRoles ...string) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		claims, exists := c.Get("claims")
 		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "User authentication required",
-				"code":  "NOT_AUTHENTICATED",
+			apierror.Abort(c, http.StatusUnauthorized, apierror.Response{
+				Error: "User authentication required",
+				Code:  "NOT_AUTHENTICATED",
 			})
-			c.Abort()
 			return
 		}
 
 		userClaims, ok := claims.(*Claims)
 		if !ok {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "Invalid user claims",
-				"code":  "INVALID_CLAIMS",
+			apierror.Abort(c, http.StatusForbidden, apierror.Response{
+				Error: "Invalid user claims",
+				Code:  "INVALID_CLAIMS",
 			})
-			c.Abort()
 			return
 		}
 
 		// Check if user has any of the allowed roles
 		if !userClaims.HasAnyRole(allowedRoles...) {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":          "Insufficient permissions",
-				"code":           "INSUFFICIENT_PERMISSIONS",
+			logger.LogAuditEvent("access_denied", "Route", userClaims.UserID, map[string]interface{}{
+				"method":         c.Request.Method,
+				"path":           c.FullPath(),
 				"required_roles": allowedRoles,
-				"user_roles":     userClaims.Roles,
+				"client_ip":      c.ClientIP(),
+			})
+			apierror.Abort(c, http.StatusForbidden, apierror.Response{
+				Error:   "Insufficient permissions",
+				Message: "requires one of the roles " + strings.Join(allowedRoles, ", "),
+				Code:    "INSUFFICIENT_PERMISSIONS",
 			})
-			c.Abort()
 			return
 		}
 
@@ -94,13 +113,11 @@ func RequireRole(allowedRoles ...string) gin.HandlerFunc {
 // RequirePermission creates a middleware that requires specific permissions
 func RequirePermission(resource, action string) gin.HandlerFunc {
 	return func(c *gin.Context) {
-		userID, exists := c.Get("user_id")
-		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "User authentication required",
-				"code":  "NOT_AUTHENTICATED",
+		if _, exists := c.Get("user_id"); !exists {
+			apierror.Abort(c, http.StatusUnauthorized, apierror.Response{
+				Error: "User authentication required",
+				Code:  "NOT_AUTHENTICATED",
 			})
-			c.Abort()
 			return
 		}
 
@@ -110,11 +127,10 @@ func RequirePermission(resource, action string) gin.HandlerFunc {
 
 		claims, exists := c.Get("claims")
 		if !exists {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error": "User claims not found",
-				"code":  "MISSING_CLAIMS",
+			apierror.Abort(c, http.StatusForbidden, apierror.Response{
+				Error: "User claims not found",
+				Code:  "MISSING_CLAIMS",
 			})
-			c.Abort()
 			return
 		}
 
@@ -124,14 +140,11 @@ func RequirePermission(resource, action string) gin.HandlerFunc {
 		hasPermission := checkPermission(userClaims.Roles, resource, action)
 
 		if !hasPermission {
-			c.JSON(http.StatusForbidden, gin.H{
-				"error":    "Insufficient permissions",
-				"code":     "INSUFFICIENT_PERMISSIONS",
-				"resource": resource,
-				"action":   action,
-				"user_id":  userID,
+			apierror.Abort(c, http.StatusForbidden, apierror.Response{
+				Error:   "Insufficient permissions",
+				Message: "requires permission to " + action + " " + resource,
+				Code:    "INSUFFICIENT_PERMISSIONS",
 			})
-			c.Abort()
 			return
 		}
 
@@ -139,31 +152,49 @@ func RequirePermission(resource, action string) gin.HandlerFunc {
 	}
 }
 
+// permissionTable maps each role to the actions it may take on each resource
+type permissionTable map[string]map[string][]string
+
+// defaultRolePermissions are the permissions of the roles InitializeDefaultRoles
+// seeds, used until the roles are loaded from the database
+var defaultRolePermissions = permissionTable{
+	"admin": {
+		"patients":     {"create", "read", "update", "delete"},
+		"observations": {"create", "read", "update", "delete"},
+		"users":        {"create", "read", "update", "delete"},
+	},
+	"practitioner": {
+		"patients":     {"create", "read", "update"},
+		"observations": {"create", "read", "update"},
+	},
+	"nurse": {
+		"patients":     {"read"},
+		"observations": {"read"},
+	},
+	"lab-tech": {
+		"patients":     {"read"},
+		"observations": {"create", "read", "update"},
+	},
+	"compliance": {
+		"patients": {"read"},
+	},
+	"research": {},
+}
+
+// rolePermissions caches the permissions of the roles in the database, so permission
+// checks do not query them. RBACService loads it at startup and reloads it when roles
+// change.
+var rolePermissions atomic.Pointer[permissionTable]
+
 // checkPermission is a helper function to check permissions based on roles
 func checkPermission(userRoles []string, resource, action string) bool {
-	// Define role-based permissions
-	permissions := map[string]map[string][]string{
-		"admin": {
-			"patients":     {"create", "read", "update", "delete"},
-			"observations": {"create", "read", "update", "delete"},
-			"users":        {"create", "read", "update", "delete"},
-		},
-		"practitioner": {
-			"patients":     {"create", "read", "update"},
-			"observations": {"create", "read", "update"},
-		},
-		"nurse": {
-			"patients":     {"read"},
-			"observations": {"read"},
-		},
-		"lab-tech": {
-			"patients":     {"read"},
-			"observations": {"create", "read", "update"},
-		},
+	permissions := rolePermissions.Load()
+	if permissions == nil {
+		permissions = &defaultRolePermissions
 	}
 
 	for _, role := range userRoles {
-		if resourcePerms, exists := permissions[role]; exists {
+		if resourcePerms, exists := (*permissions)[role]; exists {
 			if actions, exists := resourcePerms[resource]; exists {
 				for _, allowedAction := range actions {
 					if allowedAction == action {
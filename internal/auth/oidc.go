@@ -0,0 +1,562 @@
+package auth
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrOIDCStateInvalid is returned for login callbacks whose state was not issued,
+	// has expired or was already used
+	ErrOIDCStateInvalid = errors.New("oidc: login state is invalid, expired or already used")
+	// ErrOIDCTokenInvalid is returned when the identity provider's ID token does not
+	// verify
+	ErrOIDCTokenInvalid = errors.New("oidc: ID token is invalid")
+)
+
+// oidcLoginLifetime is how long a user has to sign in at the identity provider
+const oidcLoginLifetime = 10 * time.Minute
+
+// OIDCConfig configures the identity provider users sign in with
+type OIDCConfig struct {
+	IssuerURL    string // e.g. https://login.microsoftonline.com/{tenant}/v2.0
+	ClientID     string
+	ClientSecret string // Empty for public clients, which rely on PKCE alone
+	RedirectURL  string // Where the provider sends the browser back with the code
+	Scopes       []string
+	GroupsClaim  string // ID token claim listing the user's groups
+	// Roles given to a user on their first login by the groups they are in, and to
+	// users in none of the mapped groups
+	GroupRoles   map[string][]string
+	DefaultRoles []string
+}
+
+// OIDCIdentity is the user an identity provider signed in
+type OIDCIdentity struct {
+	Issuer     string
+	Subject    string
+	Email      string
+	FirstName  string
+	LastName   string
+	Groups     []string
+	Unverified bool // The provider says the email address is not verified
+}
+
+type oidcDiscovery struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	JWKSURI               string `json:"jwks_uri"`
+}
+
+type jsonWebKey struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+// OIDCProvider signs users in with an OpenID Connect identity provider using the
+// authorization code flow with PKCE. The provider's endpoints and signing keys are
+// discovered from the issuer on first use; keys are reloaded when a token carries an
+// unknown key ID. The state of logins in progress is stored, so the login and the
+// callback may be served by different replicas.
+type OIDCProvider struct {
+	db         *gorm.DB
+	config     OIDCConfig
+	httpClient *http.Client
+
+	mu         sync.RWMutex
+	discovery  *oidcDiscovery
+	keys       map[string]interface{}
+	lastReload time.Time
+}
+
+// NewOIDCProvider creates a provider for the identity provider at the configured
+// issuer
+func NewOIDCProvider(db *gorm.DB, config OIDCConfig) *OIDCProvider {
+	config.IssuerURL = strings.TrimRight(config.IssuerURL, "/")
+	config.Scopes = trimAll(config.Scopes)
+	config.DefaultRoles = trimAll(config.DefaultRoles)
+	if len(config.Scopes) == 0 {
+		config.Scopes = []string{"openid", "email", "profile"}
+	}
+	if config.GroupsClaim == "" {
+		config.GroupsClaim = "groups"
+	}
+	return &OIDCProvider{
+		db:         db,
+		config:     config,
+		httpClient: egress.NewClient(resilience.Get("oidc"), 10*time.Second),
+	}
+}
+
+// Issuer returns the issuer users are signed in by
+func (p *OIDCProvider) Issuer() string {
+	return p.config.IssuerURL
+}
+
+// Roles returns the roles a user of the groups is given on their first login, in the
+// order they are mapped
+func (p *OIDCProvider) Roles(groups []string) []string {
+	var roles []string
+	seen := make(map[string]bool)
+	for _, group := range groups {
+		for _, role := range p.config.GroupRoles[group] {
+			if !seen[role] {
+				seen[role] = true
+				roles = append(roles, role)
+			}
+		}
+	}
+	if len(roles) == 0 {
+		return p.config.DefaultRoles
+	}
+	return roles
+}
+
+// Start begins a login, returning the provider URL to send the browser to
+func (p *OIDCProvider) Start(ctx context.Context) (string, error) {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	state, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	nonce, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+	verifier, err := randomToken()
+	if err != nil {
+		return "", err
+	}
+
+	db := p.db.WithContext(ctx)
+	// Logins abandoned at the provider are removed as new ones start
+	if err := db.Where("expires_at < ?", time.Now()).Delete(&models.OIDCLoginState{}).Error; err != nil {
+		return "", fmt.Errorf("failed to remove expired login states: %w", err)
+	}
+	record := &models.OIDCLoginState{
+		StateHash:    hashOIDCState(state),
+		Nonce:        nonce,
+		CodeVerifier: verifier,
+		ExpiresAt:    time.Now().Add(oidcLoginLifetime),
+	}
+	if err := db.Create(record).Error; err != nil {
+		return "", fmt.Errorf("failed to store login state: %w", err)
+	}
+
+	challenge := sha256.Sum256([]byte(verifier))
+	query := url.Values{
+		"response_type":         {"code"},
+		"client_id":             {p.config.ClientID},
+		"redirect_uri":          {p.config.RedirectURL},
+		"scope":                 {strings.Join(p.config.Scopes, " ")},
+		"state":                 {state},
+		"nonce":                 {nonce},
+		"code_challenge":        {base64.RawURLEncoding.EncodeToString(challenge[:])},
+		"code_challenge_method": {"S256"},
+	}
+	separator := "?"
+	if strings.Contains(d.AuthorizationEndpoint, "?") {
+		separator = "&"
+	}
+	return d.AuthorizationEndpoint + separator + query.Encode(), nil
+}
+
+// Finish completes a login with the code and state the provider sent the browser
+// back with, returning the signed-in user. Each state can be used once.
+func (p *OIDCProvider) Finish(ctx context.Context, state, code string) (*OIDCIdentity, error) {
+	var record models.OIDCLoginState
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("state_hash = ? AND expires_at > ?", hashOIDCState(state), time.Now()).First(&record).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				return ErrOIDCStateInvalid
+			}
+			return err
+		}
+		// Deleting claims the state; a concurrent callback with it deletes nothing
+		result := tx.Delete(&record)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrOIDCStateInvalid
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	d, err := p.discover(ctx)
+	if err != nil {
+		return nil, err
+	}
+	idToken, err := p.exchange(ctx, d, code, record.CodeVerifier)
+	if err != nil {
+		return nil, err
+	}
+	return p.verify(ctx, d, idToken, record.Nonce)
+}
+
+// exchange redeems an authorization code for the provider's ID token
+func (p *OIDCProvider) exchange(ctx context.Context, d *oidcDiscovery, code, verifier string) (string, error) {
+	form := url.Values{
+		"grant_type":    {"authorization_code"},
+		"code":          {code},
+		"redirect_uri":  {p.config.RedirectURL},
+		"client_id":     {p.config.ClientID},
+		"code_verifier": {verifier},
+	}
+	if p.config.ClientSecret != "" {
+		form.Set("client_secret", p.config.ClientSecret)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, d.TokenEndpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	var body struct {
+		IDToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	status, err := p.doJSON(req, &body)
+	if err != nil {
+		return "", fmt.Errorf("oidc: token request failed: %w", err)
+	}
+	if body.Error != "" {
+		return "", fmt.Errorf("%w: provider rejected the code: %s %s", ErrOIDCTokenInvalid, body.Error, body.ErrorDescription)
+	}
+	if status != http.StatusOK || body.IDToken == "" {
+		return "", fmt.Errorf("oidc: token request returned status %d without an ID token", status)
+	}
+	return body.IDToken, nil
+}
+
+// verify checks an ID token's signature, issuer, audience, expiry and nonce and
+// reads the identity it asserts
+func (p *OIDCProvider) verify(ctx context.Context, d *oidcDiscovery, idToken, nonce string) (*OIDCIdentity, error) {
+	claims := jwt.MapClaims{}
+	_, err := jwt.ParseWithClaims(idToken, claims, func(token *jwt.Token) (interface{}, error) {
+		id, _ := token.Header["kid"].(string)
+		key, err := p.key(ctx, id)
+		if err != nil {
+			return nil, err
+		}
+		switch key.(type) {
+		case *rsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodRSA); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+		case *ecdsa.PublicKey:
+			if _, ok := token.Method.(*jwt.SigningMethodECDSA); !ok {
+				return nil, jwt.ErrSignatureInvalid
+			}
+		}
+		return key, nil
+	}, jwt.WithIssuer(d.Issuer), jwt.WithAudience(p.config.ClientID), jwt.WithLeeway(time.Minute))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", ErrOIDCTokenInvalid, err)
+	}
+	if exp, _ := claims.GetExpirationTime(); exp == nil {
+		return nil, fmt.Errorf("%w: no expiry", ErrOIDCTokenInvalid)
+	}
+	if got, _ := claims["nonce"].(string); got != nonce {
+		return nil, fmt.Errorf("%w: nonce does not match the login", ErrOIDCTokenInvalid)
+	}
+
+	subject, _ := claims.GetSubject()
+	if subject == "" {
+		return nil, fmt.Errorf("%w: no subject", ErrOIDCTokenInvalid)
+	}
+	identity := &OIDCIdentity{
+		Issuer:  d.Issuer,
+		Subject: subject,
+		Email:   stringClaim(claims, "email"),
+		Groups:  stringsClaim(claims, p.config.GroupsClaim),
+	}
+	if identity.Email == "" {
+		// Azure AD leaves out email for accounts without a mailbox
+		identity.Email = stringClaim(claims, "preferred_username")
+	}
+	switch verified := claims["email_verified"].(type) {
+	case bool:
+		identity.Unverified = !verified
+	case string:
+		identity.Unverified = verified == "false"
+	}
+	identity.FirstName = stringClaim(claims, "given_name")
+	identity.LastName = stringClaim(claims, "family_name")
+	if identity.FirstName == "" && identity.LastName == "" {
+		identity.FirstName, identity.LastName, _ = strings.Cut(stringClaim(claims, "name"), " ")
+	}
+	return identity, nil
+}
+
+// discover returns the provider's endpoints, fetching them on first use
+func (p *OIDCProvider) discover(ctx context.Context) (*oidcDiscovery, error) {
+	p.mu.RLock()
+	d := p.discovery
+	p.mu.RUnlock()
+	if d != nil {
+		return d, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.config.IssuerURL+"/.well-known/openid-configuration", nil)
+	if err != nil {
+		return nil, err
+	}
+	d = &oidcDiscovery{}
+	status, err := p.doJSON(req, d)
+	if err != nil {
+		return nil, fmt.Errorf("oidc: discovery failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return nil, fmt.Errorf("oidc: discovery returned status %d", status)
+	}
+	if strings.TrimRight(d.Issuer, "/") != p.config.IssuerURL {
+		return nil, fmt.Errorf("oidc: discovery issuer %s does not match %s", d.Issuer, p.config.IssuerURL)
+	}
+	if d.AuthorizationEndpoint == "" || d.TokenEndpoint == "" || d.JWKSURI == "" {
+		return nil, errors.New("oidc: discovery document is missing endpoints")
+	}
+
+	p.mu.Lock()
+	p.discovery = d
+	p.mu.Unlock()
+	return d, nil
+}
+
+// key returns the provider's signing key with an ID, reloading the keys when it is
+// unknown. Tokens without a key ID are accepted when the provider has one key.
+func (p *OIDCProvider) key(ctx context.Context, id string) (interface{}, error) {
+	lookup := func() interface{} {
+		p.mu.RLock()
+		defer p.mu.RUnlock()
+		if id == "" && len(p.keys) == 1 {
+			for _, key := range p.keys {
+				return key
+			}
+		}
+		return p.keys[id]
+	}
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+
+	p.mu.Lock()
+	if time.Since(p.lastReload) < reloadInterval {
+		p.mu.Unlock()
+		return nil, errUnknownKey
+	}
+	p.lastReload = time.Now()
+	p.mu.Unlock()
+
+	if err := p.loadKeys(ctx); err != nil {
+		return nil, err
+	}
+	if key := lookup(); key != nil {
+		return key, nil
+	}
+	return nil, errUnknownKey
+}
+
+// loadKeys fetches the provider's signing keys
+func (p *OIDCProvider) loadKeys(ctx context.Context) error {
+	d, err := p.discover(ctx)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, d.JWKSURI, nil)
+	if err != nil {
+		return err
+	}
+	var set struct {
+		Keys []jsonWebKey `json:"keys"`
+	}
+	status, err := p.doJSON(req, &set)
+	if err != nil {
+		return fmt.Errorf("oidc: key request failed: %w", err)
+	}
+	if status != http.StatusOK {
+		return fmt.Errorf("oidc: key request returned status %d", status)
+	}
+
+	keys := make(map[string]interface{}, len(set.Keys))
+	for _, jwk := range set.Keys {
+		if jwk.Use != "" && jwk.Use != "sig" {
+			continue
+		}
+		// Keys of types or curves not used for ID tokens are skipped
+		if key, err := jwk.publicKey(); err == nil {
+			keys[jwk.Kid] = key
+		}
+	}
+
+	p.mu.Lock()
+	p.keys = keys
+	p.mu.Unlock()
+	return nil
+}
+
+// doJSON sends a request and decodes its JSON response, returning the status
+func (p *OIDCProvider) doJSON(req *http.Request, out interface{}) (int, error) {
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return resp.StatusCode, err
+	}
+	if err := json.Unmarshal(body, out); err != nil && resp.StatusCode == http.StatusOK {
+		return resp.StatusCode, fmt.Errorf("invalid response: %w", err)
+	}
+	return resp.StatusCode, nil
+}
+
+// publicKey decodes an RSA or elliptic curve key
+func (k jsonWebKey) publicKey() (interface{}, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %s", k.Crv)
+		}
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+		return &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}, nil
+	}
+	return nil, fmt.Errorf("unsupported key type %s", k.Kty)
+}
+
+// ParseGroupRoles parses mappings of identity provider groups to roles, of the form
+// group=role, e.g. HealthHub-Nurses=nurse. A group may be mapped to several roles.
+func ParseGroupRoles(entries []string) (map[string][]string, error) {
+	mappings := make(map[string][]string)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		// Group names may contain '=', role names do not
+		i := strings.LastIndex(entry, "=")
+		if i < 0 {
+			return nil, fmt.Errorf("oidc: invalid group mapping %q, expected group=role", entry)
+		}
+		group, role := strings.TrimSpace(entry[:i]), strings.TrimSpace(entry[i+1:])
+		if group == "" || role == "" {
+			return nil, fmt.Errorf("oidc: invalid group mapping %q, expected group=role", entry)
+		}
+		mappings[group] = append(mappings[group], role)
+	}
+	return mappings, nil
+}
+
+// trimAll trims the values of a list, leaving out empty ones
+func trimAll(values []string) []string {
+	var trimmed []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			trimmed = append(trimmed, value)
+		}
+	}
+	return trimmed
+}
+
+func stringClaim(claims jwt.MapClaims, name string) string {
+	value, _ := claims[name].(string)
+	return value
+}
+
+// stringsClaim reads a claim holding a list of strings, or a single one
+func stringsClaim(claims jwt.MapClaims, name string) []string {
+	switch value := claims[name].(type) {
+	case string:
+		return []string{value}
+	case []interface{}:
+		values := make([]string, 0, len(value))
+		for _, v := range value {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
+
+// randomToken returns a random URL-safe string, used for states, nonces and PKCE
+// verifiers
+func randomToken() (string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", fmt.Errorf("failed to generate random token: %w", err)
+	}
+	return base64.RawURLEncoding.EncodeToString(raw), nil
+}
+
+// hashOIDCState returns the hash a login state is stored and looked up by
+func hashOIDCState(state string) string {
+	sum := sha256.Sum256([]byte(state))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,14 +1,21 @@
 package auth
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"time"
 
 	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
+// permissionReloadInterval is how often the permission cache is reloaded, which picks
+// up role changes made on other replicas
+const permissionReloadInterval = time.Minute
+
 // RBACService handles role-based access control operations
 type RBACService struct {
 	db *gorm.DB
@@ -51,6 +58,7 @@ func (s *RBACService) CreateRole(name, description string, permissionIDs []strin
 
 	// Load the role with permissions
 	s.db.Preload("Permissions").First(role, "id = ?", role.ID)
+	s.reloadPermissions()
 
 	return role, nil
 }
@@ -73,6 +81,7 @@ func (s *RBACService) CreatePermission(name, description, resource, action strin
 	if err := s.db.Create(permission).Error; err != nil {
 		return nil, fmt.Errorf("failed to create permission: %w", err)
 	}
+	s.reloadPermissions()
 
 	return permission, nil
 }
@@ -108,6 +117,7 @@ func (s *RBACService) AssignRoleToUser(userID, roleID, grantedBy string) error {
 	if err := s.db.Create(assignment).Error; err != nil {
 		return fmt.Errorf("failed to assign role: %w", err)
 	}
+	s.reloadPermissions()
 
 	return nil
 }
@@ -122,6 +132,7 @@ func (s *RBACService) RemoveRoleFromUser(userID, roleID string) error {
 	if result.RowsAffected == 0 {
 		return fmt.Errorf("role assignment not found")
 	}
+	s.reloadPermissions()
 
 	return nil
 }
@@ -252,7 +263,11 @@ func (s *RBACService) DeleteRole(roleID string) error {
 		return fmt.Errorf("failed to delete role: %w", err)
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	s.reloadPermissions()
+	return nil
 }
 
 // DeletePermission deletes a permission and its associations
@@ -277,7 +292,55 @@ func (s *RBACService) DeletePermission(permissionID string) error {
 		return fmt.Errorf("failed to delete permission: %w", err)
 	}
 
-	return tx.Commit().Error
+	if err := tx.Commit().Error; err != nil {
+		return err
+	}
+	s.reloadPermissions()
+	return nil
+}
+
+// LoadPermissions loads the permissions of every role into the cache permission checks
+// read, returning the number of roles loaded
+func (s *RBACService) LoadPermissions() (int, error) {
+	var roles []models.Role
+	if err := s.db.Preload("Permissions").Find(&roles).Error; err != nil {
+		return 0, fmt.Errorf("failed to load role permissions: %w", err)
+	}
+
+	permissions := make(permissionTable, len(roles))
+	for _, role := range roles {
+		resources := make(map[string][]string)
+		for _, permission := range role.Permissions {
+			resources[permission.Resource] = append(resources[permission.Resource], permission.Action)
+		}
+		permissions[role.Name] = resources
+	}
+	rolePermissions.Store(&permissions)
+	return len(roles), nil
+}
+
+// reloadPermissions refreshes the permission cache after roles change; a failed
+// reload keeps the previous permissions
+func (s *RBACService) reloadPermissions() {
+	if _, err := s.LoadPermissions(); err != nil {
+		logger.Warn("Failed to reload role permissions", zap.Error(err))
+	}
+}
+
+// Run reloads the permission cache periodically until the context is cancelled, which
+// picks up role changes made by other replicas
+func (s *RBACService) Run(ctx context.Context) {
+	ticker := time.NewTicker(permissionReloadInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.reloadPermissions()
+		}
+	}
 }
 
 // InitializeDefaultRoles creates default roles and permissions
@@ -329,6 +392,10 @@ func (s *RBACService) InitializeDefaultRoles() error {
 		"lab-tech": {
 			"patients:read", "observations:create", "observations:read", "observations:update",
 		},
+		"compliance": {
+			"patients:read",
+		},
+		"research": {},
 	}
 
 	// Create roles if they don't exist
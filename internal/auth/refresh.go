@@ -0,0 +1,178 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var (
+	// ErrRefreshTokenInvalid is returned for refresh tokens that are unknown, expired
+	// or revoked
+	ErrRefreshTokenInvalid = errors.New("refresh token is invalid, expired or revoked")
+	// ErrRefreshTokenReused is returned when a refresh token that was already rotated
+	// is presented again. Its family is revoked: either the token was copied, or the
+	// client holding it lost track of its replacement.
+	ErrRefreshTokenReused = errors.New("refresh token was already used")
+)
+
+// refreshPurgeInterval is how often expired refresh tokens are removed
+const refreshPurgeInterval = time.Hour
+
+// RefreshStore issues, rotates and revokes refresh tokens. Tokens are random and
+// stored hashed, so the table does not hold usable tokens.
+type RefreshStore struct {
+	db  *gorm.DB
+	ttl time.Duration
+}
+
+// NewRefreshStore creates a refresh token store whose tokens expire ttl after they
+// are issued
+func NewRefreshStore(db *gorm.DB, ttl time.Duration) *RefreshStore {
+	return &RefreshStore{db: db, ttl: ttl}
+}
+
+// Issue creates a refresh token for a user, starting a new family
+func (s *RefreshStore) Issue(userID, clientIP, userAgent string) (string, *models.RefreshToken, error) {
+	return s.issue(s.db, userID, "", clientIP, userAgent)
+}
+
+func (s *RefreshStore) issue(tx *gorm.DB, userID, familyID, clientIP, userAgent string) (string, *models.RefreshToken, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return "", nil, fmt.Errorf("failed to generate refresh token: %w", err)
+	}
+	token := base64.RawURLEncoding.EncodeToString(raw)
+
+	record := &models.RefreshToken{
+		UserID:    userID,
+		FamilyID:  familyID,
+		TokenHash: hashRefreshToken(token),
+		ExpiresAt: time.Now().Add(s.ttl),
+		ClientIP:  clientIP,
+		UserAgent: userAgent,
+	}
+	if err := tx.Create(record).Error; err != nil {
+		return "", nil, fmt.Errorf("failed to store refresh token: %w", err)
+	}
+	return token, record, nil
+}
+
+// Rotate exchanges a refresh token for a new one of the same family, returning the
+// new token. A token that was already exchanged revokes its family and returns
+// ErrRefreshTokenReused.
+func (s *RefreshStore) Rotate(token, clientIP, userAgent string) (string, *models.RefreshToken, error) {
+	var current models.RefreshToken
+	if err := s.db.Where("token_hash = ?", hashRefreshToken(token)).First(&current).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil, ErrRefreshTokenInvalid
+		}
+		return "", nil, err
+	}
+	if current.RevokedAt != nil || time.Now().After(current.ExpiresAt) {
+		return "", nil, ErrRefreshTokenInvalid
+	}
+
+	var next string
+	var record *models.RefreshToken
+	err := s.db.Transaction(func(tx *gorm.DB) error {
+		// Of concurrent exchanges of the same token only one marks it used
+		result := tx.Model(&models.RefreshToken{}).
+			Where("id = ? AND used_at IS NULL AND revoked_at IS NULL", current.ID).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrRefreshTokenReused
+		}
+
+		var err error
+		next, record, err = s.issue(tx, current.UserID, current.FamilyID, clientIP, userAgent)
+		return err
+	})
+	if errors.Is(err, ErrRefreshTokenReused) {
+		if revokeErr := s.RevokeFamily(current.FamilyID, models.RefreshRevokedReuse); revokeErr != nil {
+			return "", nil, revokeErr
+		}
+		return "", &current, ErrRefreshTokenReused
+	}
+	if err != nil {
+		return "", nil, err
+	}
+	return next, record, nil
+}
+
+// Lookup returns the record of a refresh token that is neither expired nor revoked
+func (s *RefreshStore) Lookup(token string) (*models.RefreshToken, error) {
+	var record models.RefreshToken
+	if err := s.db.Where("token_hash = ? AND revoked_at IS NULL AND expires_at > ?", hashRefreshToken(token), time.Now()).
+		First(&record).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrRefreshTokenInvalid
+		}
+		return nil, err
+	}
+	return &record, nil
+}
+
+// RevokeFamily revokes every token of a refresh token family
+func (s *RefreshStore) RevokeFamily(familyID, reason string) error {
+	return s.revoke(s.db.Where("family_id = ?", familyID), reason)
+}
+
+// RevokeUser revokes every refresh token of a user, ending all their sessions
+func (s *RefreshStore) RevokeUser(userID, reason string) error {
+	return s.revoke(s.db.Where("user_id = ?", userID), reason)
+}
+
+func (s *RefreshStore) revoke(query *gorm.DB, reason string) error {
+	err := query.Model(&models.RefreshToken{}).Where("revoked_at IS NULL").
+		Updates(map[string]interface{}{"revoked_at": time.Now(), "revoked_reason": reason}).Error
+	if err != nil {
+		return fmt.Errorf("failed to revoke refresh tokens: %w", err)
+	}
+	return nil
+}
+
+// Purge removes the refresh tokens that have expired
+func (s *RefreshStore) Purge(ctx context.Context) (int64, error) {
+	result := s.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.RefreshToken{})
+	return result.RowsAffected, result.Error
+}
+
+// Run removes expired refresh tokens periodically until the context is cancelled
+func (s *RefreshStore) Run(ctx context.Context) {
+	ticker := time.NewTicker(refreshPurgeInterval)
+	defer ticker.Stop()
+
+	for {
+		if purged, err := s.Purge(ctx); err != nil {
+			logger.Warn("Failed to purge expired refresh tokens", zap.Error(err))
+		} else if purged > 0 {
+			logger.Info("Purged expired refresh tokens", zap.Int64("count", purged))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// hashRefreshToken returns the hash a refresh token is stored and looked up by
+func hashRefreshToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,233 @@
+package auth
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/cluster"
+	"github.com/hillmatthew2000/HealthHub/pkg/encryption"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrRotationInProgress is returned when another rotation is running
+var ErrRotationInProgress = errors.New("a signing key rotation is already in progress")
+
+// rotationLock serializes rotations across replicas
+const rotationLock = "jwt-key-rotation"
+
+// KeyRotator rotates the JWT signing key. Rotated keys are stored encrypted in the
+// database so every replica signs with the same key; the key being replaced keeps
+// validating tokens for the rotation window and is retired after it.
+//
+// Until the first rotation the key from JWT_SECRET signs tokens. Secrets listed in
+// JWT_PREVIOUS_SECRETS are accepted for validation, which allows a rotation through
+// configuration alone: deploy the new secret with the old one listed as previous,
+// then remove the old one once its tokens have expired.
+type KeyRotator struct {
+	db        *gorm.DB
+	encryptor *encryption.Encryptor
+	tokens    *TokenManager
+	secret    []byte
+	previous  [][]byte
+	window    time.Duration
+	interval  time.Duration
+}
+
+// NewKeyRotator creates a new key rotator for the token manager. The window should
+// be at least the token lifetime so no token outlives the key it was signed with.
+func NewKeyRotator(db *gorm.DB, encryptor *encryption.Encryptor, tokens *TokenManager, secret string, previous []string, window time.Duration) *KeyRotator {
+	r := &KeyRotator{
+		db:        db,
+		encryptor: encryptor,
+		tokens:    tokens,
+		secret:    []byte(secret),
+		window:    window,
+		interval:  time.Minute,
+	}
+	for _, p := range previous {
+		r.previous = append(r.previous, []byte(p))
+	}
+	tokens.SetReloader(func() error { return r.Load(context.Background()) })
+	return r
+}
+
+// Window returns how long a replaced key keeps validating tokens
+func (r *KeyRotator) Window() time.Duration {
+	return r.window
+}
+
+// CurrentKeyID returns the ID of the key new tokens are signed with
+func (r *KeyRotator) CurrentKeyID() string {
+	return r.tokens.CurrentKeyID()
+}
+
+// Load loads the current and still accepted keys into the token manager
+func (r *KeyRotator) Load(ctx context.Context) error {
+	var stored []models.SigningKey
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&stored).Error; err != nil {
+		return fmt.Errorf("failed to load signing keys: %w", err)
+	}
+
+	now := time.Now()
+	current := SigningKey{ID: KeyID(r.secret), Secret: r.secret}
+	var previous []SigningKey
+	retired := map[string]bool{}
+	rotated := false
+
+	for _, key := range stored {
+		if key.Status == models.SigningKeyRetired || (key.RetiresAt != nil && now.After(*key.RetiresAt)) {
+			retired[key.ID] = true
+			continue
+		}
+		secret, err := r.encryptor.Decrypt(key.Secret)
+		if err != nil {
+			logger.Warn("Failed to decrypt signing key", zap.String("key_id", key.ID), zap.Error(err))
+			continue
+		}
+
+		loaded := SigningKey{ID: key.ID, Secret: []byte(secret)}
+		switch {
+		case key.Status == models.SigningKeyActive && !rotated:
+			current = loaded
+			rotated = true
+		case key.RetiresAt != nil:
+			loaded.ExpiresAt = *key.RetiresAt
+			previous = append(previous, loaded)
+		default:
+			previous = append(previous, loaded)
+		}
+	}
+
+	// Configured previous secrets are accepted until a rotation retires them
+	for _, secret := range r.previous {
+		id := KeyID(secret)
+		if !retired[id] && id != current.ID {
+			previous = append(previous, SigningKey{ID: id, Secret: secret})
+		}
+	}
+
+	r.tokens.SetKeys(current, previous)
+	return nil
+}
+
+// Rotate makes a new random key the signing key. The key it replaces keeps
+// validating tokens until the rotation window has passed.
+func (r *KeyRotator) Rotate(ctx context.Context, userID string) (*models.SigningKey, error) {
+	lock, err := cluster.Acquire(ctx, r.db, rotationLock)
+	if errors.Is(err, cluster.ErrLocked) {
+		return nil, ErrRotationInProgress
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer lock.Release()
+
+	// Pick up rotations made by other replicas before replacing the current key
+	if err := r.Load(ctx); err != nil {
+		return nil, err
+	}
+	replaced := r.tokens.currentKey()
+
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, fmt.Errorf("failed to generate signing key: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+	encrypted, err := r.encryptor.Encrypt(secret)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt signing key: %w", err)
+	}
+	key := &models.SigningKey{
+		ID:        KeyID([]byte(secret)),
+		Secret:    encrypted,
+		Status:    models.SigningKeyActive,
+		CreatedBy: userID,
+	}
+
+	retiresAt := time.Now().Add(r.window)
+	err = r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&models.SigningKey{}).Where("status = ?", models.SigningKeyActive).
+			Updates(map[string]interface{}{"status": models.SigningKeyRetiring, "retires_at": retiresAt})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// First rotation: record the configured secret so it can be retired
+			encryptedReplaced, err := r.encryptor.Encrypt(string(replaced.Secret))
+			if err != nil {
+				return err
+			}
+			if err := tx.Where("id = ?", replaced.ID).Delete(&models.SigningKey{}).Error; err != nil {
+				return err
+			}
+			if err := tx.Create(&models.SigningKey{
+				ID:        replaced.ID,
+				Secret:    encryptedReplaced,
+				Status:    models.SigningKeyRetiring,
+				CreatedBy: "config",
+				RetiresAt: &retiresAt,
+			}).Error; err != nil {
+				return err
+			}
+		}
+		return tx.Create(key).Error
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to rotate signing key: %w", err)
+	}
+
+	if err := r.Load(ctx); err != nil {
+		return nil, err
+	}
+	logger.Info("Rotated JWT signing key",
+		zap.String("key_id", key.ID),
+		zap.String("replaced_key_id", replaced.ID),
+		zap.Time("replaced_key_retires_at", retiresAt),
+	)
+	return key, nil
+}
+
+// Retire retires keys whose rotation window has passed and returns how many were
+// retired. Tokens signed with a retired key are rejected.
+func (r *KeyRotator) Retire(ctx context.Context) (int64, error) {
+	now := time.Now()
+	result := r.db.WithContext(ctx).Model(&models.SigningKey{}).
+		Where("status = ? AND retires_at <= ?", models.SigningKeyRetiring, now).
+		Updates(map[string]interface{}{"status": models.SigningKeyRetired, "retired_at": now, "secret": ""})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to retire signing keys: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// Run retires expired keys and reloads the keys until the context is cancelled,
+// which picks up rotations made by other replicas
+func (r *KeyRotator) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		retired, err := r.Retire(ctx)
+		if err != nil {
+			logger.Warn("Failed to retire signing keys", zap.Error(err))
+		} else if retired > 0 {
+			logger.Info("Retired JWT signing keys", zap.Int64("count", retired))
+		}
+		if err := r.Load(ctx); err != nil {
+			logger.Warn("Failed to reload signing keys", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
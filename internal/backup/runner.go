@@ -0,0 +1,408 @@
+package backup
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/url"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/cluster"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrRestoreDisabled is returned when no staging database is configured
+var ErrRestoreDisabled = errors.New("no staging database is configured")
+
+// ErrBackupUnsupported is returned when the database cannot be dumped with pg_dump
+var ErrBackupUnsupported = errors.New("backups require a PostgreSQL database")
+
+// StagingLock is the cluster lock held on the staging database while it is
+// rewritten, by a restore or by the staging refresh command
+const StagingLock = "staging-database"
+
+// stderrLimit bounds how much tool output is kept for an error message
+const stderrLimit = 4096
+
+// Options configures the backup tools and databases
+type Options struct {
+	DatabaseURL        string
+	StagingDatabaseURL string
+	PgDumpPath         string
+	PgRestorePath      string
+}
+
+// Runner executes queued backup and restore jobs one at a time, so a restore never
+// reads a dump that is still being written
+type Runner struct {
+	db       *gorm.DB
+	store    storage.Storage
+	opts     Options
+	interval time.Duration
+	wake     chan struct{}
+}
+
+// NewRunner creates a new backup job runner. It refuses a staging database that is
+// the primary database, since a restore drops and recreates every object it contains.
+func NewRunner(db *gorm.DB, store storage.Storage, opts Options) (*Runner, error) {
+	if opts.StagingDatabaseURL != "" && database.SameDatabase(opts.DatabaseURL, opts.StagingDatabaseURL) {
+		return nil, errors.New("the staging database must not be the primary database")
+	}
+
+	return &Runner{
+		db:       db,
+		store:    store,
+		opts:     opts,
+		interval: 10 * time.Second,
+		wake:     make(chan struct{}, 1),
+	}, nil
+}
+
+// RestoreTarget returns the staging database URL without credentials
+func (r *Runner) RestoreTarget() (string, error) {
+	if r.opts.StagingDatabaseURL == "" {
+		return "", ErrRestoreDisabled
+	}
+	return redact(r.opts.StagingDatabaseURL), nil
+}
+
+// Supported reports whether the primary database can be backed up
+func (r *Runner) Supported() error {
+	if !dialect.IsPostgres(r.db) {
+		return ErrBackupUnsupported
+	}
+	return nil
+}
+
+// Wake signals the runner that a job has been queued
+func (r *Runner) Wake() {
+	select {
+	case r.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes queued jobs until the context is cancelled. Jobs interrupted by a
+// restart are run again from the start.
+func (r *Runner) Run(ctx context.Context) {
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		for r.next(ctx) {
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-r.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// next runs the oldest pending job, backups before restores, and reports whether
+// there was one
+func (r *Runner) next(ctx context.Context) bool {
+	pending := []string{models.BackupJobQueued, models.BackupJobRunning}
+
+	var job models.BackupJob
+	err := r.db.Where("status IN ?", pending).Order("created_at ASC").First(&job).Error
+	if err == nil {
+		if err := r.backup(ctx, &job); err != nil && ctx.Err() == nil {
+			r.failBackup(&job, err)
+		}
+		return true
+	}
+	if err != gorm.ErrRecordNotFound {
+		logger.Warn("Failed to fetch backup jobs", zap.Error(err))
+		return false
+	}
+
+	var restore models.RestoreJob
+	err = r.db.Where("status IN ?", pending).Order("created_at ASC").First(&restore).Error
+	if err == nil {
+		if err := r.restore(ctx, &restore); err != nil && ctx.Err() == nil {
+			r.failRestore(&restore, err)
+		}
+		return true
+	}
+	if err != gorm.ErrRecordNotFound {
+		logger.Warn("Failed to fetch restore jobs", zap.Error(err))
+	}
+	return false
+}
+
+// backup streams pg_dump's custom-format output to storage while hashing it
+func (r *Runner) backup(ctx context.Context, job *models.BackupJob) error {
+	now := time.Now()
+	job.Status = models.BackupJobRunning
+	job.StartedAt = &now
+	job.StorageKey = models.BackupStorageKey(job.ID)
+	if err := r.db.Model(job).Select("status", "started_at", "storage_key").Updates(job).Error; err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	conn, env := connection(r.opts.DatabaseURL)
+	cmd := exec.CommandContext(ctx, r.opts.PgDumpPath, "--format=custom", "--no-owner", "--no-privileges", "--dbname="+conn)
+	cmd.Env = append(os.Environ(), env...)
+	stderr := &tailBuffer{limit: stderrLimit}
+	cmd.Stderr = stderr
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start pg_dump: %w", err)
+	}
+
+	hash := sha256.New()
+	n, putErr := r.store.Put(job.StorageKey, io.TeeReader(stdout, hash))
+	if putErr != nil {
+		cancel() // Stop pg_dump rather than let it block on a full pipe
+	}
+	waitErr := cmd.Wait()
+
+	if putErr != nil || waitErr != nil {
+		if err := r.store.Delete(job.StorageKey); err != nil && err != storage.ErrNotFound {
+			logger.Warn("Failed to remove incomplete backup", zap.String("job_id", job.ID), zap.Error(err))
+		}
+		if putErr != nil {
+			return fmt.Errorf("failed to store backup: %w", putErr)
+		}
+		return toolError("pg_dump", waitErr, stderr)
+	}
+
+	completed := time.Now()
+	job.Status = models.BackupJobCompleted
+	job.Bytes = n
+	job.SHA256 = hex.EncodeToString(hash.Sum(nil))
+	job.CompletedAt = &completed
+	if err := r.db.Model(job).Select("status", "bytes", "sha256", "completed_at").Updates(job).Error; err != nil {
+		return err
+	}
+
+	logger.LogAuditEvent("backup_complete", "Database", job.CreatedBy, map[string]interface{}{
+		"job_id": job.ID,
+		"bytes":  job.Bytes,
+	})
+	return nil
+}
+
+// restore verifies a backup's checksum and loads it into the staging database with
+// pg_restore, replacing the objects it contains
+func (r *Runner) restore(ctx context.Context, job *models.RestoreJob) error {
+	now := time.Now()
+	job.Status = models.BackupJobRunning
+	job.StartedAt = &now
+	if err := r.db.Model(job).Select("status", "started_at").Updates(job).Error; err != nil {
+		return err
+	}
+
+	if r.opts.StagingDatabaseURL == "" {
+		return ErrRestoreDisabled
+	}
+
+	var backup models.BackupJob
+	if err := r.db.Where("id = ?", job.BackupID).First(&backup).Error; err != nil {
+		return fmt.Errorf("failed to load backup: %w", err)
+	}
+	if backup.Status != models.BackupJobCompleted {
+		return fmt.Errorf("backup %s has not completed", backup.ID)
+	}
+
+	// Check the dump before touching the staging database
+	if err := r.verify(&backup); err != nil {
+		return err
+	}
+
+	staging, err := database.NewPostgresDB(r.opts.StagingDatabaseURL)
+	if err != nil {
+		return fmt.Errorf("failed to connect to the staging database: %w", err)
+	}
+	defer database.CloseDB(staging)
+
+	lock, err := cluster.Acquire(ctx, staging, StagingLock)
+	if errors.Is(err, cluster.ErrLocked) {
+		return errors.New("the staging database is being refreshed; try again when it completes")
+	}
+	if err != nil {
+		return fmt.Errorf("failed to lock the staging database: %w", err)
+	}
+	defer lock.Release()
+
+	reader, err := r.store.Get(backup.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	defer reader.Close()
+
+	conn, env := connection(r.opts.StagingDatabaseURL)
+	cmd := exec.CommandContext(ctx, r.opts.PgRestorePath,
+		"--clean", "--if-exists", "--no-owner", "--no-privileges", "--single-transaction",
+		"--dbname="+conn)
+	cmd.Env = append(os.Environ(), env...)
+	cmd.Stdin = reader
+	stderr := &tailBuffer{limit: stderrLimit}
+	cmd.Stderr = stderr
+	if err := cmd.Run(); err != nil {
+		return toolError("pg_restore", err, stderr)
+	}
+
+	counts, err := rowCounts(staging)
+	if err != nil {
+		return fmt.Errorf("restore finished but the staging database could not be inspected: %w", err)
+	}
+
+	completed := time.Now()
+	job.Status = models.BackupJobCompleted
+	job.RowCounts = counts
+	job.CompletedAt = &completed
+	if err := r.db.Model(job).Select("status", "row_counts", "completed_at").Updates(job).Error; err != nil {
+		return err
+	}
+
+	logger.LogAuditEvent("restore_complete", "Database", job.CreatedBy, map[string]interface{}{
+		"job_id":    job.ID,
+		"backup_id": job.BackupID,
+		"target":    job.Target,
+	})
+	return nil
+}
+
+// verify compares a stored dump against the checksum recorded when it was written
+func (r *Runner) verify(backup *models.BackupJob) error {
+	reader, err := r.store.Get(backup.StorageKey)
+	if err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	defer reader.Close()
+
+	hash := sha256.New()
+	if _, err := io.Copy(hash, reader); err != nil {
+		return fmt.Errorf("failed to read backup: %w", err)
+	}
+	if hex.EncodeToString(hash.Sum(nil)) != backup.SHA256 {
+		return errors.New("backup checksum mismatch; the dump file is corrupt")
+	}
+	return nil
+}
+
+// rowCounts counts the rows of every table in the staging database
+func rowCounts(staging *gorm.DB) (map[string]int64, error) {
+	var tables []string
+	if err := staging.Raw(`SELECT table_name FROM information_schema.tables
+		WHERE table_schema = 'public' AND table_type = 'BASE TABLE' ORDER BY table_name`).
+		Scan(&tables).Error; err != nil {
+		return nil, err
+	}
+
+	counts := make(map[string]int64, len(tables))
+	for _, table := range tables {
+		var count int64
+		quoted := `"public"."` + strings.ReplaceAll(table, `"`, `""`) + `"`
+		if err := staging.Raw("SELECT count(*) FROM " + quoted).Scan(&count).Error; err != nil {
+			return nil, err
+		}
+		counts[table] = count
+	}
+	return counts, nil
+}
+
+// failBackup marks a backup job as failed
+func (r *Runner) failBackup(job *models.BackupJob, cause error) {
+	now := time.Now()
+	job.Status = models.BackupJobFailed
+	job.Error = cause.Error()
+	job.CompletedAt = &now
+	if err := r.db.Model(job).Select("status", "error", "completed_at").Updates(job).Error; err != nil {
+		logger.Error("Failed to record backup failure", zap.String("job_id", job.ID), zap.Error(err))
+	}
+
+	logger.Error("Backup failed", zap.String("job_id", job.ID), zap.Error(cause))
+}
+
+// failRestore marks a restore job as failed
+func (r *Runner) failRestore(job *models.RestoreJob, cause error) {
+	now := time.Now()
+	job.Status = models.BackupJobFailed
+	job.Error = cause.Error()
+	job.CompletedAt = &now
+	if err := r.db.Model(job).Select("status", "error", "completed_at").Updates(job).Error; err != nil {
+		logger.Error("Failed to record restore failure", zap.String("job_id", job.ID), zap.Error(err))
+	}
+
+	logger.Error("Restore failed", zap.String("job_id", job.ID), zap.Error(cause))
+}
+
+// connection splits a database URL into a password-free URL for the command line
+// and a PGPASSWORD environment entry, so the password does not appear in process
+// listings. Key/value connection strings are passed through unchanged.
+func connection(databaseURL string) (string, []string) {
+	u, err := url.Parse(databaseURL)
+	if err != nil || u.Scheme == "" || u.User == nil {
+		return databaseURL, nil
+	}
+
+	password, ok := u.User.Password()
+	if !ok {
+		return databaseURL, nil
+	}
+	u.User = url.User(u.User.Username())
+	return u.String(), []string{"PGPASSWORD=" + password}
+}
+
+// redact removes the password from a database URL
+func redact(databaseURL string) string {
+	if u, err := url.Parse(databaseURL); err == nil && u.Scheme != "" {
+		return u.Redacted()
+	}
+	return "(connection string)"
+}
+
+// toolError wraps a failed command's error with the end of its output
+func toolError(tool string, err error, stderr *tailBuffer) error {
+	if output := strings.TrimSpace(stderr.String()); output != "" {
+		return fmt.Errorf("%s failed: %w: %s", tool, err, output)
+	}
+	return fmt.Errorf("%s failed: %w", tool, err)
+}
+
+// tailBuffer keeps the last limit bytes written to it
+type tailBuffer struct {
+	limit int
+	buf   bytes.Buffer
+}
+
+// Write appends p, discarding the oldest bytes beyond the limit
+func (t *tailBuffer) Write(p []byte) (int, error) {
+	t.buf.Write(p)
+	if extra := t.buf.Len() - t.limit; extra > 0 {
+		t.buf.Next(extra)
+	}
+	return len(p), nil
+}
+
+// String returns the buffered output
+func (t *tailBuffer) String() string {
+	return t.buf.String()
+}
@@ -0,0 +1,366 @@
+package billing
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// CaptureConsumerName is the outbox consumer name of the charge capturer
+const CaptureConsumerName = "charge_capture"
+
+// Reasons a service matching a charge rule has no charge
+const (
+	UncapturedNoChargeCode = "no-charge-code" // The rule names no charge code and the service code maps to none
+	UncapturedPending      = "pending"        // The capturer has not reached the service yet, or the rule is newer than it
+)
+
+// Capturer captures charges for the observations and medication administrations
+// whose codes have a charge rule, as their outbox events are consumed
+type Capturer struct {
+	db         *gorm.DB
+	translator *terminology.Translator
+}
+
+// NewCapturer creates a new charge capturer
+func NewCapturer(db *gorm.DB, translator *terminology.Translator) *Capturer {
+	return &Capturer{db: db, translator: translator}
+}
+
+// UncapturedService is a billable service without a charge for one of its rules
+type UncapturedService struct {
+	Service    models.Reference `json:"service"`
+	Subject    models.Reference `json:"subject"`
+	Code       models.Coding    `json:"code"` // The service code the rule matched
+	OccurredAt time.Time        `json:"occurrenceDateTime"`
+	RuleID     string           `json:"ruleId"`
+	Reason     string           `json:"reason"`
+}
+
+// DuplicateCharge is a set of billable charges of the same code for a patient on
+// the same day from different services or rules
+type DuplicateCharge struct {
+	Subject models.Reference    `json:"subject"`
+	Code    models.Coding       `json:"code"`
+	Date    string              `json:"date"`
+	Charges []models.ChargeItem `json:"charges"`
+}
+
+// Reconciliation compares the services of a period with the charges captured for them
+type Reconciliation struct {
+	Start      time.Time           `json:"start"`
+	End        time.Time           `json:"end"`
+	Captured   int                 `json:"captured"` // Billable charges in the period
+	Uncaptured []UncapturedService `json:"uncaptured"`
+	Duplicates []DuplicateCharge   `json:"duplicates"`
+}
+
+// HandleEvent captures the charges of a created or updated service. Charges of a
+// service that is deleted, cancelled or entered in error are voided unless they
+// were billed.
+func (c *Capturer) HandleEvent(ctx context.Context, event models.OutboxEvent) error {
+	db := c.db.WithContext(ctx)
+	ref := event.ResourceType + "/" + event.ResourceID
+
+	switch event.ResourceType {
+	case "Observation":
+		var obs models.Observation
+		err := db.Where("id = ?", event.ResourceID).First(&obs).Error
+		if err == gorm.ErrRecordNotFound {
+			return c.void(db, ref, "service deleted")
+		}
+		if err != nil {
+			return err
+		}
+		switch obs.Status {
+		case "final", "amended", "corrected":
+			return c.capture(ctx, observationService(&obs))
+		case "cancelled", "entered-in-error":
+			return c.void(db, ref, "service "+obs.Status)
+		}
+
+	case "MedicationAdministration":
+		var admin models.MedicationAdministration
+		err := db.Where("id = ?", event.ResourceID).First(&admin).Error
+		if err == gorm.ErrRecordNotFound {
+			return c.void(db, ref, "service deleted")
+		}
+		if err != nil {
+			return err
+		}
+		switch admin.Status {
+		case models.AdministrationCompleted:
+			return c.capture(ctx, administrationService(&admin))
+		case models.AdministrationEnteredInError:
+			return c.void(db, ref, "service "+admin.Status)
+		}
+	}
+	return nil
+}
+
+// capture creates the charges of a service's rules that it has not captured yet
+func (c *Capturer) capture(ctx context.Context, s chargeable) error {
+	db := c.db.WithContext(ctx)
+	rules, err := matchingRules(db, s.resourceType, s.code)
+	if err != nil {
+		return err
+	}
+
+	items := make([]models.ChargeItem, 0, len(rules))
+	for _, rule := range rules {
+		code, err := c.chargeCode(ctx, rule, s.code)
+		if err != nil {
+			return err
+		}
+		if code == nil {
+			logger.Warn("Charge rule has no charge code for service",
+				zap.String("rule_id", rule.ID),
+				zap.String("service", s.source.Reference),
+			)
+			continue
+		}
+		items = append(items, models.ChargeItem{
+			Subject:    models.Reference{Reference: s.patient, Type: "Patient"},
+			Encounter:  s.encounter,
+			Code:       *code,
+			Quantity:   rule.Quantity,
+			OccurredAt: s.date,
+			Service:    s.source,
+			RuleID:     rule.ID,
+		})
+	}
+	if len(items) == 0 {
+		return nil
+	}
+
+	if err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "capture_key"}},
+		DoNothing: true,
+	}).Create(&items).Error; err != nil {
+		return fmt.Errorf("failed to capture charges: %w", err)
+	}
+	return nil
+}
+
+// void marks the unbilled charges of a service as entered in error
+func (c *Capturer) void(db *gorm.DB, service, reason string) error {
+	if err := db.Model(&models.ChargeItem{}).
+		Where("service_reference = ? AND status IN ?", service, []string{models.ChargeBillable, models.ChargeNotBillable}).
+		Updates(map[string]interface{}{"status": models.ChargeEnteredInError, "note": reason}).Error; err != nil {
+		return fmt.Errorf("failed to void charges: %w", err)
+	}
+	return nil
+}
+
+// chargeCode returns the code a rule charges a service as
+func (c *Capturer) chargeCode(ctx context.Context, rule models.ChargeRule, concept models.CodeableConcept) (*models.Coding, error) {
+	if rule.ChargeCode != "" {
+		return &models.Coding{System: rule.ChargeSystem, Code: rule.ChargeCode, Display: rule.ChargeDisplay}, nil
+	}
+	return serviceCode(ctx, c.translator, concept)
+}
+
+// Reconcile compares the services performed between start and end that match a
+// charge rule with the charges captured for them
+func (c *Capturer) Reconcile(ctx context.Context, start, end time.Time) (*Reconciliation, error) {
+	db := c.db.WithContext(ctx)
+	result := &Reconciliation{Start: start, End: end, Uncaptured: []UncapturedService{}, Duplicates: []DuplicateCharge{}}
+
+	var rules []models.ChargeRule
+	if err := db.Where("active = ?", true).Find(&rules).Error; err != nil {
+		return nil, fmt.Errorf("failed to load charge rules: %w", err)
+	}
+	byCode := make(map[string][]models.ChargeRule, len(rules))
+	for _, rule := range rules {
+		key := rule.ResourceType + "|" + rule.System + "|" + rule.Code
+		byCode[key] = append(byCode[key], rule)
+	}
+
+	var services []chargeable
+	if len(rules) > 0 {
+		var err error
+		if services, err = c.billableServices(db, start, end); err != nil {
+			return nil, err
+		}
+	}
+
+	// Every charge of the services counts as captured, whatever its status
+	refs := make([]string, 0, len(services))
+	for _, s := range services {
+		refs = append(refs, s.source.Reference)
+	}
+	captured := make(map[string]bool)
+	for i := 0; i < len(refs); i += 500 {
+		chunk := refs[i:minInt(i+500, len(refs))]
+		var keys []string
+		if err := db.Model(&models.ChargeItem{}).Where("service_reference IN ?", chunk).Pluck("capture_key", &keys).Error; err != nil {
+			return nil, fmt.Errorf("failed to load charges: %w", err)
+		}
+		for _, key := range keys {
+			captured[key] = true
+		}
+	}
+
+	for _, s := range services {
+		for _, coding := range s.code.Coding {
+			for _, rule := range byCode[s.resourceType+"|"+coding.System+"|"+coding.Code] {
+				if captured[s.source.Reference+"|"+rule.ID] {
+					continue
+				}
+				reason := UncapturedPending
+				code, err := c.chargeCode(ctx, rule, s.code)
+				if err != nil {
+					return nil, err
+				}
+				if code == nil {
+					reason = UncapturedNoChargeCode
+				}
+				result.Uncaptured = append(result.Uncaptured, UncapturedService{
+					Service:    s.source,
+					Subject:    models.Reference{Reference: s.patient, Type: "Patient"},
+					Code:       coding,
+					OccurredAt: s.date,
+					RuleID:     rule.ID,
+					Reason:     reason,
+				})
+			}
+		}
+	}
+
+	var charges []models.ChargeItem
+	if err := db.Where("status = ? AND occurred_at >= ? AND occurred_at < ?", models.ChargeBillable, start, end).
+		Order("occurred_at").Find(&charges).Error; err != nil {
+		return nil, fmt.Errorf("failed to load charges: %w", err)
+	}
+	result.Captured = len(charges)
+
+	groups := make(map[string]*DuplicateCharge)
+	var order []string
+	for _, charge := range charges {
+		date := charge.OccurredAt.UTC().Format("2006-01-02")
+		key := charge.Subject.Reference + "|" + charge.Code.System + "|" + charge.Code.Code + "|" + date
+		group, ok := groups[key]
+		if !ok {
+			group = &DuplicateCharge{Subject: charge.Subject, Code: charge.Code, Date: date}
+			groups[key] = group
+			order = append(order, key)
+		}
+		group.Charges = append(group.Charges, charge)
+	}
+	for _, key := range order {
+		if len(groups[key].Charges) > 1 {
+			result.Duplicates = append(result.Duplicates, *groups[key])
+		}
+	}
+
+	sort.SliceStable(result.Uncaptured, func(i, j int) bool {
+		return result.Uncaptured[i].OccurredAt.Before(result.Uncaptured[j].OccurredAt)
+	})
+	return result, nil
+}
+
+// billableServices loads the final observations and completed administrations of a period
+func (c *Capturer) billableServices(db *gorm.DB, start, end time.Time) ([]chargeable, error) {
+	var observations []models.Observation
+	if err := db.Where("status IN ? AND effective_date_time >= ? AND effective_date_time < ?",
+		[]string{"final", "amended", "corrected"}, start, end).Find(&observations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load observations: %w", err)
+	}
+	var administrations []models.MedicationAdministration
+	if err := db.Where("status = ? AND effective_at >= ? AND effective_at < ?",
+		models.AdministrationCompleted, start, end).Find(&administrations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load medication administrations: %w", err)
+	}
+
+	services := make([]chargeable, 0, len(observations)+len(administrations))
+	for i := range observations {
+		services = append(services, observationService(&observations[i]))
+	}
+	for i := range administrations {
+		services = append(services, administrationService(&administrations[i]))
+	}
+	return services, nil
+}
+
+// chargeable is a performed service that charge rules may apply to
+type chargeable struct {
+	resourceType string
+	source       models.Reference
+	patient      string
+	encounter    *models.Reference
+	date         time.Time
+	code         models.CodeableConcept
+}
+
+// observationService describes an observation as a chargeable service
+func observationService(obs *models.Observation) chargeable {
+	s := chargeable{
+		resourceType: "Observation",
+		source:       models.Reference{Reference: "Observation/" + obs.ID, Type: "Observation", Display: obs.Code.Text},
+		patient:      obs.Subject.Reference,
+		date:         obs.EffectiveDateTime,
+		code:         obs.Code,
+	}
+	if obs.Encounter != nil && obs.Encounter.Reference != "" {
+		s.encounter = &models.Reference{Reference: obs.Encounter.Reference, Type: "Encounter"}
+	}
+	return s
+}
+
+// administrationService describes a medication administration as a chargeable service
+func administrationService(admin *models.MedicationAdministration) chargeable {
+	return chargeable{
+		resourceType: "MedicationAdministration",
+		source:       models.Reference{Reference: "MedicationAdministration/" + admin.ID, Type: "MedicationAdministration", Display: admin.Medication.Text},
+		patient:      admin.Subject.Reference,
+		date:         admin.EffectiveAt,
+		code:         admin.Medication,
+	}
+}
+
+// matchingRules returns the active charge rules of a resource type matching one of
+// the codings of a service code
+func matchingRules(db *gorm.DB, resourceType string, concept models.CodeableConcept) ([]models.ChargeRule, error) {
+	var codes []string
+	for _, coding := range concept.Coding {
+		if coding.Code != "" {
+			codes = append(codes, coding.Code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	var candidates []models.ChargeRule
+	if err := db.Where("resource_type = ? AND active = ? AND code IN ?", resourceType, true, codes).
+		Order("created_at").Find(&candidates).Error; err != nil {
+		return nil, fmt.Errorf("failed to load charge rules: %w", err)
+	}
+
+	rules := candidates[:0]
+	for _, rule := range candidates {
+		for _, coding := range concept.Coding {
+			if coding.System == rule.System && coding.Code == rule.Code {
+				rules = append(rules, rule)
+				break
+			}
+		}
+	}
+	return rules, nil
+}
+
+// minInt returns the smaller of two ints
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,561 @@
+package billing
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+)
+
+// Limits of a professional claim
+const (
+	MaxDiagnoses        = 12
+	maxDiagnosisPointer = 4
+)
+
+// Claim is a claim-ready summary of the billable services of one encounter, or of one
+// patient's services on one day when they were not recorded against an encounter
+type Claim struct {
+	ID           string             `json:"id"` // Patient control number, stable across extractions
+	Patient      ClaimPatient       `json:"patient"`
+	Encounter    *models.Reference  `json:"encounter,omitempty"`
+	ServiceStart time.Time          `json:"serviceStart"`
+	ServiceEnd   time.Time          `json:"serviceEnd"`
+	Diagnoses    []Diagnosis        `json:"diagnoses"`
+	Lines        []ServiceLine      `json:"lines"`
+	Total        float64            `json:"total"`
+	Issues       []string           `json:"issues,omitempty"` // Why the claim cannot be submitted as is
+	Unbilled     []models.Reference `json:"unbilled,omitempty"`
+}
+
+// ClaimPatient is the patient, who is also the insurance subscriber
+type ClaimPatient struct {
+	Reference string          `json:"reference"`
+	Family    string          `json:"family"`
+	Given     []string        `json:"given,omitempty"`
+	BirthDate time.Time       `json:"birthDate"`
+	Gender    string          `json:"gender"`
+	Address   *models.Address `json:"address,omitempty"`
+	MemberID  string          `json:"memberId,omitempty"`
+}
+
+// Diagnosis is a billed diagnosis of a claim
+type Diagnosis struct {
+	Sequence  int              `json:"sequence"`
+	Code      models.Coding    `json:"code"`
+	Condition models.Reference `json:"condition"`
+}
+
+// ServiceLine is a billed service of a claim. Identical services on the same day
+// are billed as one line with a quantity.
+type ServiceLine struct {
+	Sequence          int                `json:"sequence"`
+	Service           models.Coding      `json:"service"`
+	Quantity          int                `json:"quantity"`
+	Charge            float64            `json:"charge"`
+	ServiceDate       time.Time          `json:"serviceDate"`
+	DiagnosisPointers []int              `json:"diagnosisPointers,omitempty"` // Sequences of the diagnoses the service treats
+	Sources           []models.Reference `json:"sources"`
+}
+
+// Query selects the services to extract claims for: an encounter, or the services
+// performed between Start and End, optionally of one patient
+type Query struct {
+	PatientID string
+	Encounter string
+	Start     time.Time
+	End       time.Time
+}
+
+// Assembler assembles claims from the observations and medication administrations
+// recorded for patients, billing diagnoses from the conditions they address
+type Assembler struct {
+	db              *gorm.DB
+	translator      *terminology.Translator
+	diagnosisSystem string
+}
+
+// NewAssembler creates a new claim assembler billing diagnoses in diagnosisSystem
+func NewAssembler(db *gorm.DB, translator *terminology.Translator, diagnosisSystem string) *Assembler {
+	return &Assembler{db: db, translator: translator, diagnosisSystem: diagnosisSystem}
+}
+
+// service is a billable record awaiting a claim
+type service struct {
+	source  models.Reference
+	patient string
+	date    time.Time
+	code    models.CodeableConcept
+	reasons []models.Reference
+}
+
+// claimGroup collects the services of one claim
+type claimGroup struct {
+	patient   string
+	encounter string
+	services  []service
+}
+
+// Assemble returns the claims of the services q selects, oldest first. Medication
+// administrations carry no encounter, so they are only billed for periods.
+func (a *Assembler) Assemble(ctx context.Context, q Query) ([]Claim, error) {
+	db := a.db.WithContext(ctx)
+	d := dialect.Of(db)
+
+	groups := make(map[string]*claimGroup)
+	var order []string
+	add := func(encounter string, s service) {
+		key := s.patient + "|" + encounter
+		if encounter == "" {
+			key += s.date.UTC().Format("2006-01-02")
+		}
+		group, ok := groups[key]
+		if !ok {
+			group = &claimGroup{patient: s.patient, encounter: encounter}
+			groups[key] = group
+			order = append(order, key)
+		}
+		group.services = append(group.services, s)
+	}
+
+	query := db.Where("status IN ?", []string{"final", "amended", "corrected"})
+	if q.PatientID != "" {
+		query = query.Where(d.JSONText("subject", "reference")+" = ?", "Patient/"+q.PatientID)
+	}
+	if q.Encounter != "" {
+		query = query.Where("encounter_reference = ?", q.Encounter)
+	} else {
+		query = query.Where("effective_date_time >= ? AND effective_date_time < ?", q.Start, q.End)
+	}
+	var observations []models.Observation
+	if err := query.Order("effective_date_time").Find(&observations).Error; err != nil {
+		return nil, fmt.Errorf("failed to load observations: %w", err)
+	}
+	for _, obs := range observations {
+		encounter := ""
+		if obs.Encounter != nil {
+			encounter = obs.Encounter.Reference
+		}
+		add(encounter, service{
+			source:  models.Reference{Reference: "Observation/" + obs.ID, Type: "Observation", Display: obs.Code.Text},
+			patient: obs.Subject.Reference,
+			date:    obs.EffectiveDateTime,
+			code:    obs.Code,
+			reasons: obs.ReasonReference,
+		})
+	}
+
+	if q.Encounter == "" {
+		query := db.Where("status = ? AND effective_at >= ? AND effective_at < ?", models.AdministrationCompleted, q.Start, q.End)
+		if q.PatientID != "" {
+			query = query.Where("subject_reference = ?", "Patient/"+q.PatientID)
+		}
+		var administrations []models.MedicationAdministration
+		if err := query.Order("effective_at").Find(&administrations).Error; err != nil {
+			return nil, fmt.Errorf("failed to load medication administrations: %w", err)
+		}
+
+		// Administrations bill the conditions their prescription treats
+		reasons := make(map[string][]models.Reference)
+		if len(administrations) > 0 {
+			ids := make([]string, 0, len(administrations))
+			for _, admin := range administrations {
+				ids = append(ids, admin.RequestID)
+			}
+			var requests []models.MedicationRequest
+			if err := db.Select("id", "reason_reference").Where("id IN ?", ids).Find(&requests).Error; err != nil {
+				return nil, fmt.Errorf("failed to load medication requests: %w", err)
+			}
+			for _, req := range requests {
+				reasons[req.ID] = req.ReasonReference
+			}
+		}
+		for _, admin := range administrations {
+			add("", service{
+				source:  models.Reference{Reference: "MedicationAdministration/" + admin.ID, Type: "MedicationAdministration", Display: admin.Medication.Text},
+				patient: admin.Subject.Reference,
+				date:    admin.EffectiveAt,
+				code:    admin.Medication,
+				reasons: reasons[admin.RequestID],
+			})
+		}
+	}
+
+	// Notes of an encounter name the conditions it addressed, which the services
+	// themselves may not
+	encounterReasons := make(map[string][]models.Reference)
+	var encounters []string
+	for _, key := range order {
+		if group := groups[key]; group.encounter != "" {
+			encounters = append(encounters, group.encounter)
+		}
+	}
+	if len(encounters) > 0 {
+		var notes []models.ClinicalNote
+		if err := db.Select("id", "encounter_reference", "reason_reference").
+			Where("encounter_reference IN ? AND status <> ?", encounters, models.NoteStatusEnteredInError).
+			Order("created_at").Find(&notes).Error; err != nil {
+			return nil, fmt.Errorf("failed to load clinical notes: %w", err)
+		}
+		for _, note := range notes {
+			if note.Encounter != nil {
+				encounterReasons[note.Encounter.Reference] = append(encounterReasons[note.Encounter.Reference], note.ReasonReference...)
+			}
+		}
+	}
+
+	patients, err := a.loadPatients(db, groups)
+	if err != nil {
+		return nil, err
+	}
+	conditions, err := a.loadConditions(db, groups, encounterReasons)
+	if err != nil {
+		return nil, err
+	}
+
+	claims := make([]Claim, 0, len(order))
+	for _, key := range order {
+		group := groups[key]
+		claim, err := a.assemble(ctx, group, patients[group.patient], conditions, encounterReasons[group.encounter])
+		if err != nil {
+			return nil, err
+		}
+		claims = append(claims, claim)
+	}
+	if err := a.price(db, claims); err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(claims, func(i, j int) bool {
+		if !claims[i].ServiceStart.Equal(claims[j].ServiceStart) {
+			return claims[i].ServiceStart.Before(claims[j].ServiceStart)
+		}
+		return claims[i].Patient.Reference < claims[j].Patient.Reference
+	})
+	return claims, nil
+}
+
+// assemble builds the claim of one group of services
+func (a *Assembler) assemble(ctx context.Context, group *claimGroup, patient *models.Patient, conditions map[string]*models.Condition, encounterReasons []models.Reference) (Claim, error) {
+	claim := Claim{
+		ID:           controlNumber(group),
+		Patient:      ClaimPatient{Reference: group.patient},
+		ServiceStart: group.services[0].date,
+		ServiceEnd:   group.services[0].date,
+		Diagnoses:    []Diagnosis{},
+		Lines:        []ServiceLine{},
+	}
+	if group.encounter != "" {
+		claim.Encounter = &models.Reference{Reference: group.encounter, Type: "Encounter"}
+	}
+	if patient == nil {
+		claim.Issues = append(claim.Issues, "patient not found")
+	} else {
+		claim.Patient = claimPatient(patient)
+		if claim.Patient.MemberID == "" {
+			claim.Issues = append(claim.Issues, "patient has no member identifier")
+		}
+	}
+
+	// Diagnoses are sequenced in the order the services name them
+	sequences := make(map[string]int)
+	diagnose := func(refs []models.Reference) ([]int, error) {
+		var pointers []int
+		for _, ref := range refs {
+			id := strings.TrimPrefix(ref.Reference, "Condition/")
+			condition, ok := conditions[id]
+			if !ok {
+				continue
+			}
+			seq, ok := sequences[id]
+			if !ok {
+				code, err := a.diagnosisCode(ctx, condition)
+				if err != nil {
+					return nil, fmt.Errorf("failed to map diagnosis code: %w", err)
+				}
+				if code == nil {
+					claim.Issues = append(claim.Issues, "Condition/"+id+" has no "+a.diagnosisSystem+" code")
+					sequences[id] = 0
+					continue
+				}
+				if len(claim.Diagnoses) == MaxDiagnoses {
+					claim.Issues = append(claim.Issues, fmt.Sprintf("more than %d diagnoses; Condition/%s is not billed", MaxDiagnoses, id))
+					sequences[id] = 0
+					continue
+				}
+				seq = len(claim.Diagnoses) + 1
+				sequences[id] = seq
+				claim.Diagnoses = append(claim.Diagnoses, Diagnosis{
+					Sequence:  seq,
+					Code:      *code,
+					Condition: models.Reference{Reference: "Condition/" + id, Type: "Condition", Display: condition.Code.Text},
+				})
+			}
+			if seq > 0 && len(pointers) < maxDiagnosisPointer && !containsInt(pointers, seq) {
+				pointers = append(pointers, seq)
+			}
+		}
+		return pointers, nil
+	}
+
+	lines := make(map[string]int)
+	for _, s := range group.services {
+		pointers, err := diagnose(s.reasons)
+		if err != nil {
+			return Claim{}, err
+		}
+		if s.date.Before(claim.ServiceStart) {
+			claim.ServiceStart = s.date
+		}
+		if s.date.After(claim.ServiceEnd) {
+			claim.ServiceEnd = s.date
+		}
+
+		code, err := serviceCode(ctx, a.translator, s.code)
+		if err != nil {
+			return Claim{}, err
+		}
+		if code == nil {
+			claim.Unbilled = append(claim.Unbilled, s.source)
+			continue
+		}
+
+		day := s.date.UTC().Format("2006-01-02")
+		key := code.System + "|" + code.Code + "|" + day
+		if i, ok := lines[key]; ok {
+			line := &claim.Lines[i]
+			line.Quantity++
+			line.Sources = append(line.Sources, s.source)
+			for _, p := range pointers {
+				if len(line.DiagnosisPointers) < maxDiagnosisPointer && !containsInt(line.DiagnosisPointers, p) {
+					line.DiagnosisPointers = append(line.DiagnosisPointers, p)
+				}
+			}
+			continue
+		}
+		lines[key] = len(claim.Lines)
+		claim.Lines = append(claim.Lines, ServiceLine{
+			Sequence:          len(claim.Lines) + 1,
+			Service:           *code,
+			Quantity:          1,
+			ServiceDate:       s.date,
+			DiagnosisPointers: pointers,
+			Sources:           []models.Reference{s.source},
+		})
+	}
+
+	// Diagnoses the encounter's notes name are billed after those of its services
+	if _, err := diagnose(encounterReasons); err != nil {
+		return Claim{}, err
+	}
+
+	if len(claim.Diagnoses) == 0 {
+		claim.Issues = append(claim.Issues, "no billable diagnosis")
+	}
+	if len(claim.Lines) == 0 {
+		claim.Issues = append(claim.Issues, "no billable service")
+	}
+	for i := range claim.Lines {
+		if len(claim.Lines[i].DiagnosisPointers) == 0 && len(claim.Diagnoses) > 0 {
+			claim.Lines[i].DiagnosisPointers = []int{1}
+		}
+	}
+	return claim, nil
+}
+
+// diagnosisCode returns the billing code of a condition: its coding in the diagnosis
+// system, or the code the concept maps translate it to
+func (a *Assembler) diagnosisCode(ctx context.Context, condition *models.Condition) (*models.Coding, error) {
+	for _, coding := range condition.Code.Coding {
+		if coding.System == a.diagnosisSystem && coding.Code != "" {
+			code := coding
+			return &code, nil
+		}
+	}
+	return a.translator.BillingCode(ctx, condition.Code, a.diagnosisSystem)
+}
+
+// serviceCode returns the CPT or HCPCS code a service is billed as, or nil when it
+// has none and the concept maps do not translate it to one
+func serviceCode(ctx context.Context, translator *terminology.Translator, concept models.CodeableConcept) (*models.Coding, error) {
+	for _, system := range []string{models.CPTSystem, models.HCPCSSystem} {
+		for _, coding := range concept.Coding {
+			if coding.System == system && coding.Code != "" {
+				code := coding
+				return &code, nil
+			}
+		}
+	}
+	for _, system := range []string{models.CPTSystem, models.HCPCSSystem} {
+		code, err := translator.BillingCode(ctx, concept, system)
+		if err != nil {
+			return nil, fmt.Errorf("failed to map service code: %w", err)
+		}
+		if code != nil {
+			return code, nil
+		}
+	}
+	return nil, nil
+}
+
+// loadPatients loads the patients of the claims by reference
+func (a *Assembler) loadPatients(db *gorm.DB, groups map[string]*claimGroup) (map[string]*models.Patient, error) {
+	ids := make([]string, 0, len(groups))
+	for _, group := range groups {
+		ids = append(ids, strings.TrimPrefix(group.patient, "Patient/"))
+	}
+	patients := make(map[string]*models.Patient, len(ids))
+	if len(ids) == 0 {
+		return patients, nil
+	}
+
+	var found []models.Patient
+	if err := db.Where("id IN ?", ids).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to load patients: %w", err)
+	}
+	for i := range found {
+		patients["Patient/"+found[i].ID] = &found[i]
+	}
+	return patients, nil
+}
+
+// loadConditions loads the conditions the services and encounter notes address.
+// Refuted conditions and conditions entered in error are never billed.
+func (a *Assembler) loadConditions(db *gorm.DB, groups map[string]*claimGroup, encounterReasons map[string][]models.Reference) (map[string]*models.Condition, error) {
+	seen := make(map[string]bool)
+	var ids []string
+	collect := func(refs []models.Reference) {
+		for _, ref := range refs {
+			id := strings.TrimPrefix(ref.Reference, "Condition/")
+			if id != "" && !seen[id] {
+				seen[id] = true
+				ids = append(ids, id)
+			}
+		}
+	}
+	for _, group := range groups {
+		for _, s := range group.services {
+			collect(s.reasons)
+		}
+	}
+	for _, refs := range encounterReasons {
+		collect(refs)
+	}
+
+	conditions := make(map[string]*models.Condition, len(ids))
+	if len(ids) == 0 {
+		return conditions, nil
+	}
+	var found []models.Condition
+	if err := db.Where("id IN ? AND verification_status NOT IN ?", ids,
+		[]string{models.ConditionRefuted, models.ConditionEnteredInError}).Find(&found).Error; err != nil {
+		return nil, fmt.Errorf("failed to load conditions: %w", err)
+	}
+	for i := range found {
+		conditions[found[i].ID] = &found[i]
+	}
+	return conditions, nil
+}
+
+// price charges the service lines from the fee schedule
+func (a *Assembler) price(db *gorm.DB, claims []Claim) error {
+	var codes []string
+	for _, claim := range claims {
+		for _, line := range claim.Lines {
+			codes = append(codes, line.Service.Code)
+		}
+	}
+	if len(codes) == 0 {
+		return nil
+	}
+
+	var entries []models.FeeScheduleEntry
+	if err := db.Where("code IN ?", codes).Find(&entries).Error; err != nil {
+		return fmt.Errorf("failed to load fee schedule: %w", err)
+	}
+	fees := make(map[string]float64, len(entries))
+	for _, entry := range entries {
+		fees[entry.System+"|"+entry.Code] = entry.Amount
+	}
+
+	for i := range claims {
+		claim := &claims[i]
+		for j := range claim.Lines {
+			line := &claim.Lines[j]
+			fee, ok := fees[line.Service.System+"|"+line.Service.Code]
+			if !ok {
+				claim.Issues = append(claim.Issues, "no fee for "+line.Service.Code)
+				continue
+			}
+			line.Charge = fee * float64(line.Quantity)
+			claim.Total += line.Charge
+		}
+	}
+	return nil
+}
+
+// claimPatient flattens a patient into the subscriber of a claim. The member ID is
+// the patient's identifier of type MB (member number).
+func claimPatient(patient *models.Patient) ClaimPatient {
+	cp := ClaimPatient{
+		Reference: "Patient/" + patient.ID,
+		BirthDate: patient.BirthDate,
+		Gender:    patient.Gender,
+	}
+	if len(patient.Name) > 0 {
+		name := patient.Name[0]
+		for _, n := range patient.Name {
+			if n.Use == "official" {
+				name = n
+				break
+			}
+		}
+		cp.Family = name.Family
+		cp.Given = name.Given
+	}
+	for i, address := range patient.Address {
+		if address.Use == "billing" || (cp.Address == nil && address.Use == "home") {
+			cp.Address = &patient.Address[i]
+		}
+	}
+	for _, identifier := range patient.Identifier {
+		if identifier.Type == nil {
+			continue
+		}
+		for _, coding := range identifier.Type.Coding {
+			if coding.Code == "MB" && identifier.Value != "" {
+				cp.MemberID = identifier.Value
+			}
+		}
+	}
+	return cp
+}
+
+// controlNumber derives the patient control number of a claim from what it bills,
+// so that extracting the same services again yields the same number
+func controlNumber(group *claimGroup) string {
+	key := group.patient + "|" + group.encounter
+	if group.encounter == "" {
+		key += "|" + group.services[0].date.UTC().Format("2006-01-02")
+	}
+	sum := sha256.Sum256([]byte(key))
+	return strings.ToUpper(hex.EncodeToString(sum[:10]))
+}
+
+// containsInt reports whether values contains v
+func containsInt(values []int, v int) bool {
+	for _, value := range values {
+		if value == v {
+			return true
+		}
+	}
+	return false
+}
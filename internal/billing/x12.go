@@ -0,0 +1,223 @@
+package billing
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// ErrSubmitterNotConfigured is returned when an X12 export lacks the billing
+// provider or interchange partner identifiers
+var ErrSubmitterNotConfigured = errors.New("billing provider and interchange identifiers are not configured")
+
+// x12Version is the 837 professional implementation guide the export follows
+const x12Version = "005010X222A1"
+
+// Submitter identifies the billing provider and the trading partners of an X12
+// interchange
+type Submitter struct {
+	Name           string
+	NPI            string
+	TaxID          string
+	Phone          string
+	Address        string
+	City           string
+	State          string
+	PostalCode     string
+	SenderID       string // Interchange sender ID assigned by the clearinghouse
+	ReceiverID     string
+	ReceiverName   string
+	PlaceOfService string // CMS place of service code, e.g. 11 for an office
+	Production     bool   // Interchanges are marked as test data otherwise
+}
+
+// configured reports whether the submitter carries every identifier an interchange needs
+func (s Submitter) configured() bool {
+	for _, v := range []string{s.Name, s.NPI, s.TaxID, s.Phone, s.Address, s.City, s.State, s.PostalCode, s.SenderID, s.ReceiverID, s.ReceiverName} {
+		if v == "" {
+			return false
+		}
+	}
+	return true
+}
+
+// WriteX12 writes claims as an X12 837P interchange with one transaction set. Claims
+// with issues cannot be submitted and are rejected; control is the interchange
+// control number.
+func WriteX12(w io.Writer, claims []Claim, submitter Submitter, payer models.ClaimPayer, control int, now time.Time) error {
+	if !submitter.configured() {
+		return ErrSubmitterNotConfigured
+	}
+	for _, claim := range claims {
+		if len(claim.Issues) > 0 {
+			return fmt.Errorf("claim %s cannot be submitted: %s", claim.ID, strings.Join(claim.Issues, "; "))
+		}
+	}
+
+	usage := "T"
+	if submitter.Production {
+		usage = "P"
+	}
+	placeOfService := submitter.PlaceOfService
+	if placeOfService == "" {
+		placeOfService = "11"
+	}
+	controlNumber := fmt.Sprintf("%09d", control%1000000000)
+
+	var x x12Builder
+	x.raw(fmt.Sprintf("ISA*00*%-10s*00*%-10s*ZZ*%-15s*ZZ*%-15s*%s*%s*^*00501*%s*0*%s*:~",
+		"", "", clean(submitter.SenderID), clean(submitter.ReceiverID),
+		now.Format("060102"), now.Format("1504"), controlNumber, usage))
+	x.raw(fmt.Sprintf("GS*HC*%s*%s*%s*%s*%d*X*%s~",
+		clean(submitter.SenderID), clean(submitter.ReceiverID), now.Format("20060102"), now.Format("1504"),
+		control%1000000000, x12Version))
+
+	// The transaction set's segment count runs from ST through SE
+	x.count = 0
+	x.segment("ST", "837", "0001", x12Version)
+	x.segment("BHT", "0019", "00", controlNumber, now.Format("20060102"), now.Format("1504"), "CH")
+	x.segment("NM1", "41", "2", submitter.Name, "", "", "", "", "46", submitter.SenderID)
+	x.segment("PER", "IC", submitter.Name, "TE", digits(submitter.Phone))
+	x.segment("NM1", "40", "2", submitter.ReceiverName, "", "", "", "", "46", submitter.ReceiverID)
+
+	x.segment("HL", "1", "", "20", "1")
+	x.segment("NM1", "85", "2", submitter.Name, "", "", "", "", "XX", submitter.NPI)
+	x.segment("N3", submitter.Address)
+	x.segment("N4", submitter.City, submitter.State, digits(submitter.PostalCode))
+	x.segment("REF", "EI", digits(submitter.TaxID))
+
+	for i, claim := range claims {
+		patient := claim.Patient
+		x.segment("HL", strconv.Itoa(i+2), "1", "22", "0")
+		x.segment("SBR", "P", "18", "", "", "", "", "", "", "CI")
+		first, middle := "", ""
+		if len(patient.Given) > 0 {
+			first = patient.Given[0]
+		}
+		if len(patient.Given) > 1 {
+			middle = patient.Given[1]
+		}
+		x.segment("NM1", "IL", "1", patient.Family, first, middle, "", "", "MI", patient.MemberID)
+		if patient.Address != nil {
+			x.segment("N3", strings.Join(patient.Address.Line, " "))
+			x.segment("N4", patient.Address.City, patient.Address.State, digits(patient.Address.PostalCode))
+		}
+		x.segment("DMG", "D8", patient.BirthDate.Format("20060102"), genderCode(patient.Gender))
+		x.segment("NM1", "PR", "2", payer.Name, "", "", "", "", "PI", payer.ID)
+
+		x.segment("CLM", claim.ID, amount(claim.Total), "", "", composite(placeOfService, "B", "1"), "Y", "A", "Y", "Y")
+		diagnoses := make([]string, 0, len(claim.Diagnoses))
+		for j, diagnosis := range claim.Diagnoses {
+			qualifier := "ABF"
+			if j == 0 {
+				qualifier = "ABK" // Principal diagnosis
+			}
+			diagnoses = append(diagnoses, composite(qualifier, strings.ReplaceAll(diagnosis.Code.Code, ".", "")))
+		}
+		x.segment("HI", diagnoses...)
+
+		for _, line := range claim.Lines {
+			pointers := make([]string, 0, len(line.DiagnosisPointers))
+			for _, p := range line.DiagnosisPointers {
+				pointers = append(pointers, strconv.Itoa(p))
+			}
+			x.segment("LX", strconv.Itoa(line.Sequence))
+			x.segment("SV1", composite("HC", line.Service.Code), amount(line.Charge), "UN", strconv.Itoa(line.Quantity), "", "", composite(pointers...))
+			x.segment("DTP", "472", "D8", line.ServiceDate.UTC().Format("20060102"))
+		}
+	}
+
+	x.segment("SE", strconv.Itoa(x.count+1), "0001")
+	x.raw(fmt.Sprintf("GE*1*%d~", control%1000000000))
+	x.raw(fmt.Sprintf("IEA*1*%s~", controlNumber))
+
+	_, err := io.WriteString(w, x.b.String())
+	return err
+}
+
+// x12Builder accumulates the segments of an interchange
+type x12Builder struct {
+	b     strings.Builder
+	count int
+}
+
+// componentMark stands in for the component separator of composite elements until
+// their segment is cleaned
+const componentMark = "\x1f"
+
+// segment appends a segment, cleaning its elements and dropping trailing empty ones
+func (x *x12Builder) segment(id string, elements ...string) {
+	cleaned := make([]string, len(elements))
+	for i, e := range elements {
+		cleaned[i] = strings.ReplaceAll(clean(e), componentMark, ":")
+	}
+	for len(cleaned) > 0 && cleaned[len(cleaned)-1] == "" {
+		cleaned = cleaned[:len(cleaned)-1]
+	}
+	x.b.WriteString(strings.Join(append([]string{id}, cleaned...), "*"))
+	x.b.WriteString("~")
+	x.count++
+}
+
+// raw appends a preformatted segment without counting it
+func (x *x12Builder) raw(segment string) {
+	x.b.WriteString(segment)
+}
+
+// composite joins the components of a composite element
+func composite(components ...string) string {
+	cleaned := make([]string, len(components))
+	for i, c := range components {
+		cleaned[i] = strings.ReplaceAll(clean(c), componentMark, "")
+	}
+	return strings.Join(cleaned, componentMark)
+}
+
+// clean uppercases a value and strips the interchange's delimiters from it
+func clean(v string) string {
+	v = strings.Map(func(r rune) rune {
+		switch r {
+		case '*', '~', ':', '^', '\n', '\r':
+			return -1
+		}
+		return r
+	}, v)
+	return strings.ToUpper(strings.TrimSpace(v))
+}
+
+// digits keeps only the digits of a value, e.g. of a phone number or tax ID
+func digits(v string) string {
+	return strings.Map(func(r rune) rune {
+		if r >= '0' && r <= '9' {
+			return r
+		}
+		return -1
+	}, v)
+}
+
+// amount formats a monetary amount without trailing zeros
+func amount(v float64) string {
+	s := strconv.FormatFloat(v, 'f', 2, 64)
+	s = strings.TrimRight(strings.TrimRight(s, "0"), ".")
+	if s == "" {
+		return "0"
+	}
+	return s
+}
+
+// genderCode maps an administrative gender to its X12 code
+func genderCode(gender string) string {
+	switch gender {
+	case "male":
+		return "M"
+	case "female":
+		return "F"
+	default:
+		return "U"
+	}
+}
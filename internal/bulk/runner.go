@@ -0,0 +1,197 @@
+package bulk
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Runner runs bulk jobs as operations. Each chunk is applied in its own transaction
+// together with its audit records and the job's progress, so a job that is interrupted
+// by a restart resumes after the last committed chunk.
+type Runner struct {
+	db        *gorm.DB
+	chunkSize int
+}
+
+// NewRunner creates a new bulk job runner
+func NewRunner(db *gorm.DB, chunkSize int) *Runner {
+	if chunkSize <= 0 {
+		chunkSize = 500
+	}
+
+	return &Runner{
+		db:        db,
+		chunkSize: chunkSize,
+	}
+}
+
+// Execute runs the bulk job of an operation and returns the completed job. Chunks
+// applied before the job failed or was cancelled stay applied.
+func (r *Runner) Execute(ctx context.Context, task *operation.Task) (interface{}, error) {
+	var job models.BulkJob
+	if err := r.db.Where("id = ?", task.Operation.TargetID()).First(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to load bulk job: %w", err)
+	}
+
+	if err := r.process(ctx, task, &job); err != nil {
+		switch {
+		case task.Cancelled():
+			r.finish(&job, models.BulkJobCancelled, nil)
+		case ctx.Err() == nil:
+			r.finish(&job, models.BulkJobFailed, err)
+		}
+		return nil, err
+	}
+	return job, nil
+}
+
+// process runs a job to completion in chunks ordered by resource ID
+func (r *Runner) process(ctx context.Context, task *operation.Task, job *models.BulkJob) error {
+	if job.Status == models.BulkJobQueued {
+		now := time.Now()
+		job.Status = models.BulkJobRunning
+		job.StartedAt = &now
+		if err := r.db.Model(job).Select("status", "started_at").Updates(job).Error; err != nil {
+			return err
+		}
+		logger.LogAuditEvent("bulk_"+job.Operation+"_start", job.ResourceType, job.ExecutedBy, map[string]interface{}{
+			"job_id":   job.ID,
+			"affected": job.Affected,
+		})
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		var observations []models.Observation
+		query := job.Filter.Apply(r.db.Model(&models.Observation{}))
+		if job.Cursor != "" {
+			query = query.Where("id > ?", job.Cursor)
+		}
+		if err := query.Order("id ASC").Limit(r.chunkSize).Find(&observations).Error; err != nil {
+			return fmt.Errorf("failed to load chunk: %w", err)
+		}
+		if len(observations) == 0 {
+			break
+		}
+
+		if err := r.applyChunk(job, observations); err != nil {
+			return err
+		}
+		if err := task.Progress(job.Processed, job.Affected); err != nil {
+			logger.Warn("Failed to record bulk job progress", zap.String("job_id", job.ID), zap.Error(err))
+		}
+	}
+
+	now := time.Now()
+	job.Status = models.BulkJobCompleted
+	job.CompletedAt = &now
+	if err := r.db.Model(job).Select("status", "completed_at").Updates(job).Error; err != nil {
+		return err
+	}
+
+	logger.LogAuditEvent("bulk_"+job.Operation+"_complete", job.ResourceType, job.ExecutedBy, map[string]interface{}{
+		"job_id":    job.ID,
+		"processed": job.Processed,
+	})
+	return nil
+}
+
+// applyChunk updates or deletes one chunk of observations and records an audit item for each
+func (r *Runner) applyChunk(job *models.BulkJob, observations []models.Observation) error {
+	tx := r.db.Begin()
+	defer func() {
+		if p := recover(); p != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for i := range observations {
+		observation := &observations[i]
+
+		previous, err := r.snapshot(job, observation)
+		if err != nil {
+			tx.Rollback()
+			return err
+		}
+
+		// Rows are written one at a time so model hooks see each ID and publish change events
+		switch job.Operation {
+		case models.BulkOperationUpdate:
+			job.Patch.ApplyTo(observation)
+			columns := append(job.Patch.Columns(), "updated_at")
+			err = tx.Model(observation).Select(columns).Updates(observation).Error
+		case models.BulkOperationDelete:
+			if err = tx.Model(observation).UpdateColumn("deleted_by", job.ExecutedBy).Error; err == nil {
+				err = tx.Delete(observation).Error
+			}
+		default:
+			err = fmt.Errorf("unsupported operation %q", job.Operation)
+		}
+		if err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to %s observation %s: %w", job.Operation, observation.ID, err)
+		}
+
+		item := models.BulkJobItem{JobID: job.ID, ResourceID: observation.ID, Previous: previous}
+		if err := tx.Create(&item).Error; err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record audit item: %w", err)
+		}
+	}
+
+	job.Processed += int64(len(observations))
+	job.Cursor = observations[len(observations)-1].ID
+	if err := tx.Model(job).Select("processed", "cursor").Updates(job).Error; err != nil {
+		tx.Rollback()
+		return err
+	}
+
+	return tx.Commit().Error
+}
+
+// snapshot captures the state an audit item needs to describe or reverse the change
+func (r *Runner) snapshot(job *models.BulkJob, observation *models.Observation) (json.RawMessage, error) {
+	if job.Operation == models.BulkOperationDelete {
+		return json.Marshal(observation)
+	}
+
+	previous := make(map[string]interface{})
+	if job.Patch.Status != nil {
+		previous["status"] = observation.Status
+	}
+	if job.Patch.Category != nil {
+		previous["category"] = observation.Category
+	}
+	return json.Marshal(previous)
+}
+
+// finish marks a job as failed or cancelled; chunks that were already applied stay
+// applied
+func (r *Runner) finish(job *models.BulkJob, status string, cause error) {
+	now := time.Now()
+	job.Status = status
+	job.CompletedAt = &now
+	if cause != nil {
+		job.Error = cause.Error()
+	}
+	if err := r.db.Model(job).Select("status", "error", "completed_at").Updates(job).Error; err != nil {
+		logger.Error("Failed to record bulk job "+status, zap.String("job_id", job.ID), zap.Error(err))
+	}
+
+	logger.LogAuditEvent("bulk_"+job.Operation+"_"+status, job.ResourceType, job.ExecutedBy, map[string]interface{}{
+		"job_id":    job.ID,
+		"processed": job.Processed,
+		"error":     job.Error,
+	})
+}
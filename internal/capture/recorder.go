@@ -0,0 +1,262 @@
+// Package capture records the requests and responses of a user for a limited time,
+// with PHI redacted, so admins can debug misbehaving integrations such as lab
+// interfaces.
+package capture
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DefaultMaxExchanges is the number of exchanges a session records when the request
+// names no limit
+const DefaultMaxExchanges = 500
+
+// maxBodyBytes bounds the part of a request or response body that is recorded
+const maxBodyBytes = 64 << 10
+
+// reloadInterval is how often sessions started or stopped on other instances are
+// picked up
+const reloadInterval = 15 * time.Second
+
+// purgeBatchSize bounds how many ended sessions are removed per reload
+const purgeBatchSize = 100
+
+// capturesPath is not recorded so reading a capture does not grow it
+const capturesPath = "/api/v1/admin/captures"
+
+// active is a session recording on this instance
+type active struct {
+	session  models.CaptureSession
+	redactor *Redactor
+	recorded int64 // Exchanges recorded by all instances as of the last reload, plus this one's since
+}
+
+// Recorder records the exchanges of users with an active capture session
+type Recorder struct {
+	db        *gorm.DB
+	retention time.Duration
+
+	mu       sync.RWMutex
+	sessions map[string]*active // By user ID
+}
+
+// NewRecorder creates a new recorder. Sessions and their exchanges are removed once
+// the session ended longer than the retention ago.
+func NewRecorder(db *gorm.DB, retention time.Duration) *Recorder {
+	return &Recorder{db: db, retention: retention, sessions: make(map[string]*active)}
+}
+
+// Run reloads the active sessions and purges ended ones until the context is
+// cancelled
+func (r *Recorder) Run(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := r.Reload(ctx); err != nil {
+			logger.Warn("Failed to load capture sessions", zap.Error(err))
+		}
+		if removed, err := r.Purge(ctx); err != nil {
+			logger.Warn("Failed to purge capture sessions", zap.Error(err))
+		} else if removed > 0 {
+			logger.Info("Purged capture sessions", zap.Int("sessions", removed))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reload loads the active sessions
+func (r *Recorder) Reload(ctx context.Context) error {
+	db := r.db.WithContext(ctx)
+	var sessions []models.CaptureSession
+	if err := db.Where("stopped_at IS NULL AND expires_at > ?", time.Now()).Find(&sessions).Error; err != nil {
+		return err
+	}
+
+	counts := make(map[string]int64)
+	if len(sessions) > 0 {
+		ids := make([]string, len(sessions))
+		for i, session := range sessions {
+			ids[i] = session.ID
+		}
+		var rows []struct {
+			SessionID string
+			Count     int64
+		}
+		if err := db.Model(&models.CapturedExchange{}).Select("session_id, COUNT(*) AS count").
+			Where("session_id IN ?", ids).Group("session_id").Scan(&rows).Error; err != nil {
+			return err
+		}
+		for _, row := range rows {
+			counts[row.SessionID] = row.Count
+		}
+	}
+
+	loaded := make(map[string]*active, len(sessions))
+	for _, session := range sessions {
+		// A user has one active session; the handler refuses to start another
+		loaded[session.UserID] = &active{
+			session:  session,
+			redactor: NewRedactor(session.RedactFields),
+			recorded: counts[session.ID],
+		}
+	}
+
+	r.mu.Lock()
+	r.sessions = loaded
+	r.mu.Unlock()
+	return nil
+}
+
+// Purge removes the sessions that ended longer than the retention ago with their
+// exchanges and returns how many were removed
+func (r *Recorder) Purge(ctx context.Context) (int, error) {
+	cutoff := time.Now().Add(-r.retention)
+	db := r.db.WithContext(ctx)
+
+	var ids []string
+	if err := db.Model(&models.CaptureSession{}).
+		Where("expires_at < ? OR stopped_at < ?", cutoff, cutoff).
+		Limit(purgeBatchSize).Pluck("id", &ids).Error; err != nil {
+		return 0, err
+	}
+	if len(ids) == 0 {
+		return 0, nil
+	}
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id IN ?", ids).Delete(&models.CapturedExchange{}).Error; err != nil {
+			return err
+		}
+		return tx.Where("id IN ?", ids).Delete(&models.CaptureSession{}).Error
+	})
+	if err != nil {
+		return 0, fmt.Errorf("failed to delete capture sessions: %w", err)
+	}
+	return len(ids), nil
+}
+
+// reserve returns the active session of the user with room for another exchange
+func (r *Recorder) reserve(userID string) *active {
+	r.mu.RLock()
+	a := r.sessions[userID]
+	r.mu.RUnlock()
+
+	if a == nil || !a.session.Active(time.Now()) {
+		return nil
+	}
+	if atomic.AddInt64(&a.recorded, 1) > int64(a.session.MaxExchanges) {
+		return nil
+	}
+	return a
+}
+
+// Middleware records the exchanges of users with an active session. It must follow
+// the authentication middleware.
+func (r *Recorder) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		userID, _ := auth.GetUserID(c)
+		if userID == "" || strings.HasPrefix(c.Request.URL.Path, capturesPath) {
+			c.Next()
+			return
+		}
+		a := r.reserve(userID)
+		if a == nil {
+			c.Next()
+			return
+		}
+
+		start := time.Now()
+		var requestBody []byte
+		requestTruncated := false
+		if c.Request.Body != nil {
+			body := c.Request.Body
+			read, _ := io.ReadAll(io.LimitReader(body, maxBodyBytes+1))
+			// The handler reads the whole body, recorded or not
+			c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(read), body), body}
+			requestBody = read
+			if len(read) > maxBodyBytes {
+				requestBody, requestTruncated = read[:maxBodyBytes], true
+			}
+		}
+
+		writer := &responseWriter{ResponseWriter: c.Writer}
+		c.Writer = writer
+		c.Next()
+
+		exchange := models.CapturedExchange{
+			SessionID:         a.session.ID,
+			UserID:            userID,
+			Method:            c.Request.Method,
+			Path:              c.Request.URL.Path,
+			Query:             a.redactor.Query(c.Request.URL.RawQuery),
+			Route:             c.FullPath(),
+			StatusCode:        writer.Status(),
+			DurationMs:        time.Since(start).Milliseconds(),
+			ClientIP:          c.ClientIP(),
+			RequestHeaders:    a.redactor.Headers(c.Request.Header),
+			RequestBody:       a.redactor.Body(c.Request.Header.Get("Content-Type"), requestBody, requestTruncated),
+			RequestTruncated:  requestTruncated,
+			ResponseHeaders:   a.redactor.Headers(writer.Header()),
+			ResponseBody:      a.redactor.Body(writer.Header().Get("Content-Type"), writer.body.Bytes(), writer.truncated),
+			ResponseTruncated: writer.truncated,
+		}
+		// Recorded even when the client went away
+		if err := r.db.Create(&exchange).Error; err != nil {
+			logger.Warn("Failed to record captured exchange",
+				zap.String("session_id", a.session.ID),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// readCloser reads a body replayed in front of the rest of the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// responseWriter keeps the start of the response body
+type responseWriter struct {
+	gin.ResponseWriter
+	body      bytes.Buffer
+	truncated bool
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// keep appends data to the recorded body up to its limit
+func (w *responseWriter) keep(data []byte) {
+	room := maxBodyBytes - w.body.Len()
+	if len(data) > room {
+		data, w.truncated = data[:room], true
+	}
+	w.body.Write(data)
+}
@@ -0,0 +1,175 @@
+package capture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Redacted replaces the values of redacted fields, headers and query parameters
+const Redacted = "[REDACTED]"
+
+// DefaultRedactFields are the JSON fields and query parameters redacted from every
+// capture: names, contact details, identifiers, free text that may describe the
+// patient, demographics and credentials. Matching ignores case.
+var DefaultRedactFields = []string{
+	"name", "given", "family", "prefix", "suffix", "firstName", "lastName", "display",
+	"birthDate", "deceasedDateTime",
+	"telecom", "email", "phone", "address", "line", "city", "district", "postalCode",
+	"geolocation", "latitude", "longitude",
+	"identifier", "ssn", "mrn",
+	"text", "body", "note", "comment", "valueString", "answer", "contact", "photo",
+	"race", "ethnicity", "genderIdentity", "pronouns", "sexAssignedAtBirth",
+	"password", "currentPassword", "newPassword", "token", "secret", "credential",
+}
+
+// redactedHeaders carry credentials
+var redactedHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+}
+
+// Redactor applies a session's redaction rules to captured requests and responses
+type Redactor struct {
+	fields map[string]bool
+}
+
+// NewRedactor creates a redactor for the default fields and the extra ones
+func NewRedactor(extra []string) *Redactor {
+	fields := make(map[string]bool, len(DefaultRedactFields)+len(extra))
+	for _, field := range append(append([]string(nil), DefaultRedactFields...), extra...) {
+		fields[strings.ToLower(strings.TrimSpace(field))] = true
+	}
+	return &Redactor{fields: fields}
+}
+
+// Headers returns the headers with credentials redacted, one value per name
+func (r *Redactor) Headers(header http.Header) map[string]string {
+	result := make(map[string]string, len(header))
+	for name, values := range header {
+		name = http.CanonicalHeaderKey(name)
+		if redactedHeaders[name] {
+			result[name] = Redacted
+			continue
+		}
+		result[name] = strings.Join(values, ", ")
+	}
+	return result
+}
+
+// Query returns the query string with the values of redacted parameters replaced
+func (r *Redactor) Query(raw string) string {
+	if raw == "" {
+		return ""
+	}
+	values, err := url.ParseQuery(raw)
+	if err != nil {
+		return Redacted
+	}
+	for name := range values {
+		if r.redacted(name) {
+			values[name] = []string{Redacted}
+		}
+	}
+	return values.Encode()
+}
+
+// Body returns the body with redacted fields replaced. JSON, NDJSON and form bodies
+// are redacted field by field; other bodies cannot be and are withheld, as are
+// truncated JSON bodies.
+func (r *Redactor) Body(contentType string, body []byte, truncated bool) string {
+	if len(body) == 0 {
+		return ""
+	}
+	mediaType, _, _ := mime.ParseMediaType(contentType)
+
+	switch {
+	case mediaType == "application/x-ndjson" || mediaType == "application/fhir+ndjson":
+		var out []string
+		for _, line := range bytes.Split(body, []byte("\n")) {
+			if len(bytes.TrimSpace(line)) == 0 {
+				continue
+			}
+			redacted, ok := r.json(line)
+			if !ok {
+				// A truncated body ends in a partial line
+				return strings.Join(out, "\n")
+			}
+			out = append(out, redacted)
+		}
+		return strings.Join(out, "\n")
+	case mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+		if truncated {
+			return withheld("truncated JSON", len(body))
+		}
+		if redacted, ok := r.json(body); ok {
+			return redacted
+		}
+		return withheld("invalid JSON", len(body))
+	case mediaType == "application/x-www-form-urlencoded":
+		return r.Query(string(body))
+	default:
+		if mediaType == "" {
+			mediaType = "unknown content type"
+		}
+		return withheld(mediaType, len(body))
+	}
+}
+
+// json redacts a JSON document
+func (r *Redactor) json(data []byte) (string, bool) {
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return "", false
+	}
+	redacted, err := json.Marshal(r.walk(value))
+	if err != nil {
+		return "", false
+	}
+	return string(redacted), true
+}
+
+// walk replaces the values of redacted fields at any depth
+func (r *Redactor) walk(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		for key, field := range v {
+			if r.redacted(key) {
+				v[key] = Redacted
+			} else {
+				v[key] = r.walk(field)
+			}
+		}
+		return v
+	case []interface{}:
+		for i := range v {
+			v[i] = r.walk(v[i])
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+// redacted reports whether the field or parameter is redacted
+func (r *Redactor) redacted(name string) bool {
+	name = strings.ToLower(name)
+	// Search parameters may carry modifiers, e.g. family:exact
+	if i := strings.IndexByte(name, ':'); i >= 0 {
+		name = name[:i]
+	}
+	return r.fields[name]
+}
+
+// withheld describes a body that was not recorded
+func withheld(kind string, size int) string {
+	return fmt.Sprintf("[WITHHELD: %s body, %d bytes]", kind, size)
+}
@@ -1,7 +1,11 @@
 package config
 
 import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
 	"os"
+	"path/filepath"
 	"strconv"
 	"strings"
 )
@@ -14,11 +18,36 @@ type Config struct {
 	LogLevel    string
 
 	// Database configuration
-	DatabaseURL string
+	DatabaseURL    string
+	ReadReplicaURL string // Streaming replica serving reads; all reads use the primary when empty
+
+	// Standalone mode runs from a single data directory with embedded SQLite
+	Standalone bool
+	DataDir    string
 
 	// Security configuration
-	JWTSecret     string
-	EncryptionKey string
+	JWTSecret string
+	// Previous JWT secrets still accepted during a rotation, and how long a key
+	// replaced through the rotation endpoint keeps validating tokens
+	JWTPreviousSecrets     []string
+	JWTRotationWindowHours int
+	// How long access tokens are valid, and how long a refresh token is valid after
+	// it was issued
+	AccessTokenTTLMinutes int
+	RefreshTokenTTLHours  int
+	EncryptionKey         string
+
+	// Single sign-on with an OpenID Connect identity provider, enabled by setting the
+	// issuer. Users signing in for the first time get the roles mapped to their
+	// provider groups (group=role entries), or the default roles when none is mapped.
+	OIDCIssuerURL    string
+	OIDCClientID     string
+	OIDCClientSecret string
+	OIDCRedirectURL  string
+	OIDCScopes       []string
+	OIDCGroupsClaim  string
+	OIDCGroupRoles   []string
+	OIDCDefaultRoles []string
 
 	// Redis configuration
 	RedisURL string
@@ -38,9 +67,185 @@ type Config struct {
 	// Health check configuration
 	HealthCheckPath string
 
+	// Service level objectives by route prefix, as prefix=latency_ms:latency_pct:error_pct
+	SLOTargets []string
+
+	// Request priority classes, highest first, as name=limit:wait_ms; routes and
+	// integration credentials are assigned to them as prefix=class and key_id=class.
+	// Lower classes are shed while a higher one uses the threshold percentage of its
+	// slots.
+	PriorityClasses        []string
+	PriorityRoutes         []string
+	PriorityKeys           []string
+	LoadShedThresholdPct   int
+	LoadShedRetryAfterSecs int
+
+	// Request timeouts by route prefix, as prefix=timeout_ms; 0 disables the timeout
+	RequestTimeouts []string
+
+	// Disclosure control of analytics read by research users, as
+	// endpoint=min_cell_size[:noise_scale]; * sets the default
+	AnalyticsDisclosure []string
+
+	// HTML policy of stored free-text fields by field class (note, annotation,
+	// display), as class=keep|strip; * sets the default. Control characters are always
+	// removed.
+	FreeTextSanitization []string
+
+	// Error reporting of recovered panics to Sentry; disabled when no DSN is set
+	SentryDSN     string
+	SentryRelease string
+
+	// How long recorded request captures are kept after their session ends
+	CaptureRetentionHours int
+
+	// Deprecated endpoints and fields whose usage is reported by client, as
+	// "METHOD route" or "METHOD route=field", and how long usage counts are kept
+	Deprecations       []string
+	UsageRetentionDays int
+
+	// Whether connections are opened and the hottest queries prepared before the
+	// server listens, on how many connections, and how long the warm-up may take
+	WarmupEnabled        bool
+	WarmupConnections    int
+	WarmupTimeoutSeconds int
+
+	// How long reprocessed or discarded inbound messages stay in quarantine
+	QuarantineRetentionDays int
+
+	// How often patient references are checked, and whether references to merged
+	// patients are re-pointed to the surviving record
+	IntegrityCheckIntervalHours int
+	IntegrityAutoRepoint        bool
+
+	// How often the analytics materialized views are refreshed; 0 refreshes them only
+	// on request
+	AnalyticsViewRefreshMinutes int
+
+	// Whether resources sent as FHIR JSON are validated against the bundled US Core
+	// profiles
+	FHIRProfileValidation bool
+
+	// Write-time checks turned on or off, such as duplicate or Observation.delta_check,
+	// overriding whether each runs by default
+	WriteChecksEnabled  []string
+	WriteChecksDisabled []string
+
+	// Envelope of list responses when a request does not choose one: paginated,
+	// bare or bundle
+	ResponseEnvelope string
+
+	// Whether observation and patient lists are encoded with their hand-written JSON
+	// encoders instead of encoding/json
+	FastListEncoding bool
+
 	// Pagination defaults
 	DefaultPageSize int
 	MaxPageSize     int
+	CursorSecret    string
+	CursorTTLHours  int
+
+	// Attachment storage configuration
+	StoragePath        string
+	MaxUploadSizeMB    int
+	MediaURLSecret     string
+	MediaURLTTLMinutes int
+
+	// Search index configuration
+	SearchIndexEnabled     bool
+	OpenSearchURL          string
+	OpenSearchUsername     string
+	OpenSearchPassword     string
+	SearchIndexPrefix      string
+	SearchIndexPollSeconds int
+
+	// Address verification configuration
+	GeocoderProvider string
+	GeocoderURL      string
+	GeocoderAPIKey   string
+	GeocoderAuthID   string
+	GeocoderEmail    string
+
+	// Drug interaction checking configuration
+	InteractionProvider string
+	InteractionAPIURL   string
+	InteractionAPIKey   string
+	AllergyCheckMode    string // block or warn
+
+	// Terminology configuration
+	BillingCodeSystem string // Code system conditions are billed in; empty disables mapping
+
+	// Billing configuration
+	BillingProviderName       string
+	BillingProviderNPI        string
+	BillingProviderTaxID      string
+	BillingProviderPhone      string
+	BillingProviderAddress    string
+	BillingProviderCity       string
+	BillingProviderState      string
+	BillingProviderPostalCode string
+	BillingSenderID           string // X12 interchange IDs assigned by the clearinghouse
+	BillingReceiverID         string
+	BillingReceiverName       string
+	BillingPlaceOfService     string
+	BillingProduction         bool // Marks X12 interchanges as production rather than test data
+	ChargeCaptureEnabled      bool
+	ChargeCapturePollSeconds  int
+
+	// Notification configuration
+	EmailProvider    string
+	SMTPHost         string
+	SMTPPort         int
+	SMTPUsername     string
+	SMTPPassword     string
+	SMTPFrom         string
+	SMSProvider      string
+	TwilioAccountSID string
+	TwilioAuthToken  string
+	TwilioFrom       string
+
+	// De-identification configuration
+	DeidentificationSecret string
+
+	// Deletion configuration
+	UndoWindowMinutes int
+
+	// Backup configuration
+	PgDumpPath         string
+	PgRestorePath      string
+	StagingDatabaseURL string // Restore target; restores are disabled when empty
+
+	// External dependency resilience defaults
+	ExternalTimeoutSeconds     int
+	ExternalMaxAttempts        int
+	CircuitFailureThreshold    int
+	CircuitOpenDurationSeconds int
+
+	// Background worker coordination across replicas
+	WorkerLockRetrySeconds   int
+	SchemaLockTimeoutSeconds int // How long a starting replica waits for another to finish migrating
+
+	// Outbound connection configuration
+	EgressAllowlist []string // Empty allows every destination
+	EgressProxyURL  string
+	EgressNoProxy   []string
+	EgressCAFile    string
+	EgressTLSPins   []string // host=sha256/BASE64 public key pins
+
+	// Audit log archives in an S3 bucket with Object Lock; disabled when no bucket is set
+	AuditArchiveBucket          string
+	AuditArchiveRegion          string
+	AuditArchiveEndpoint        string
+	AuditArchivePrefix          string
+	AuditArchiveAccessKeyID     string
+	AuditArchiveSecretKey       string
+	AuditArchiveLockMode        string // GOVERNANCE or COMPLIANCE
+	AuditArchiveRetentionDays   int
+	AuditArchiveIntervalMinutes int
+	AuditArchiveBatchSize       int
+	AuditArchiveSigningKey      string // Base64 Ed25519 seed
+	AuditArchiveEncryption      string // Optional age or gpg payload encryption
+	AuditArchiveRecipient       string
 }
 
 // Load reads configuration from environment variables with sensible defaults
@@ -52,11 +257,30 @@ func Load() *Config {
 		LogLevel:    getEnv("LOG_LEVEL", "info"),
 
 		// Database configuration
-		DatabaseURL: getEnv("DATABASE_URL", "postgresql://localhost:5432/healthcare_api?sslmode=disable"),
+		DatabaseURL:    getEnv("DATABASE_URL", "postgresql://localhost:5432/healthcare_api?sslmode=disable"),
+		ReadReplicaURL: getEnv("READ_REPLICA_URL", ""),
+
+		// Standalone mode
+		Standalone: getEnvAsBool("STANDALONE", false),
+		DataDir:    getEnv("DATA_DIR", "./data"),
 
 		// Security configuration
-		JWTSecret:     getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
-		EncryptionKey: getEnv("ENCRYPTION_KEY", "your-32-byte-encryption-key-change-this"),
+		JWTSecret:              getEnv("JWT_SECRET", "your-super-secret-jwt-key-change-in-production"),
+		JWTPreviousSecrets:     getEnvAsSlice("JWT_PREVIOUS_SECRETS", nil),
+		JWTRotationWindowHours: getEnvAsInt("JWT_ROTATION_WINDOW_HOURS", 24),
+		AccessTokenTTLMinutes:  getEnvAsInt("ACCESS_TOKEN_TTL_MINUTES", 15),
+		RefreshTokenTTLHours:   getEnvAsInt("REFRESH_TOKEN_TTL_HOURS", 720),
+		EncryptionKey:          getEnv("ENCRYPTION_KEY", "your-32-byte-encryption-key-change-this"),
+
+		// Single sign-on
+		OIDCIssuerURL:    getEnv("OIDC_ISSUER_URL", ""),
+		OIDCClientID:     getEnv("OIDC_CLIENT_ID", ""),
+		OIDCClientSecret: getEnv("OIDC_CLIENT_SECRET", ""),
+		OIDCRedirectURL:  getEnv("OIDC_REDIRECT_URL", ""),
+		OIDCScopes:       getEnvAsSlice("OIDC_SCOPES", []string{"openid", "email", "profile"}),
+		OIDCGroupsClaim:  getEnv("OIDC_GROUPS_CLAIM", "groups"),
+		OIDCGroupRoles:   getEnvAsSlice("OIDC_GROUP_ROLES", nil),
+		OIDCDefaultRoles: getEnvAsSlice("OIDC_DEFAULT_ROLES", nil),
 
 		// Redis configuration
 		RedisURL: getEnv("REDIS_URL", "redis://localhost:6379"),
@@ -76,9 +300,169 @@ func Load() *Config {
 		// Health check configuration
 		HealthCheckPath: getEnv("HEALTH_CHECK_PATH", "/health"),
 
+		// Service level objectives
+		SLOTargets: getEnvAsSlice("SLO_TARGETS", []string{"/api/v1=1000:99:1"}),
+
+		// Request prioritization
+		PriorityClasses:        getEnvAsSlice("PRIORITY_CLASSES", []string{"interactive=200:2000", "standard=50:1000", "bulk=10:0"}),
+		PriorityRoutes:         getEnvAsSlice("PRIORITY_ROUTES", []string{"/api/v1/exports=bulk", "/api/v1/bulk-jobs=bulk", "/api/v1/inbound=standard"}),
+		PriorityKeys:           getEnvAsSlice("PRIORITY_KEYS", nil),
+		LoadShedThresholdPct:   getEnvAsInt("LOAD_SHED_THRESHOLD_PCT", 80),
+		LoadShedRetryAfterSecs: getEnvAsInt("LOAD_SHED_RETRY_AFTER_SECONDS", 5),
+
+		// Request timeouts; streamed downloads are not bounded
+		RequestTimeouts: getEnvAsSlice("REQUEST_TIMEOUTS", []string{"/api/v1=30000", "/api/v1/exports=0", "/api/v1/media=0", "/api/v1/document-references=0"}),
+
+		// Analytics disclosure control; counts of 1 to 10 patients are suppressed
+		AnalyticsDisclosure: getEnvAsSlice("ANALYTICS_DISCLOSURE", []string{"*=11"}),
+
+		// Free-text sanitation; markup is kept unless a class strips it
+		FreeTextSanitization: getEnvAsSlice("FREE_TEXT_SANITIZATION", []string{"*=keep"}),
+
+		// Error reporting configuration
+		SentryDSN:     getEnv("SENTRY_DSN", ""),
+		SentryRelease: getEnv("SENTRY_RELEASE", ""),
+
+		// Request capture configuration
+		CaptureRetentionHours: getEnvAsInt("CAPTURE_RETENTION_HOURS", 72),
+
+		// API usage telemetry configuration
+		Deprecations:       getEnvAsSlice("DEPRECATIONS", []string{"GET /api/v1/error-codes"}),
+		UsageRetentionDays: getEnvAsInt("API_USAGE_RETENTION_DAYS", 400),
+
+		// Startup warm-up configuration
+		WarmupEnabled:        getEnvAsBool("WARMUP_ENABLED", true),
+		WarmupConnections:    getEnvAsInt("WARMUP_CONNECTIONS", 10),
+		WarmupTimeoutSeconds: getEnvAsInt("WARMUP_TIMEOUT_SECONDS", 30),
+
+		// Inbound message quarantine configuration
+		QuarantineRetentionDays: getEnvAsInt("QUARANTINE_RETENTION_DAYS", 30),
+
+		// Reference integrity configuration
+		IntegrityCheckIntervalHours: getEnvAsInt("INTEGRITY_CHECK_INTERVAL_HOURS", 24),
+		IntegrityAutoRepoint:        getEnvAsBool("INTEGRITY_AUTO_REPOINT", true),
+
+		// Analytics materialized view configuration
+		AnalyticsViewRefreshMinutes: getEnvAsInt("ANALYTICS_VIEW_REFRESH_MINUTES", 15),
+
+		// FHIR profile validation configuration
+		FHIRProfileValidation: getEnvAsBool("FHIR_PROFILE_VALIDATION", false),
+
+		// Write check configuration
+		WriteChecksEnabled:  getEnvAsSlice("WRITE_CHECKS_ENABLED", nil),
+		WriteChecksDisabled: getEnvAsSlice("WRITE_CHECKS_DISABLED", nil),
+
+		// Response envelope configuration
+		ResponseEnvelope: getEnv("RESPONSE_ENVELOPE", "paginated"),
+		FastListEncoding: getEnvAsBool("FAST_LIST_ENCODING", false),
+
 		// Pagination defaults
 		DefaultPageSize: getEnvAsInt("DEFAULT_PAGE_SIZE", 10),
 		MaxPageSize:     getEnvAsInt("MAX_PAGE_SIZE", 100),
+		CursorSecret:    getEnv("CURSOR_SECRET", ""),
+		CursorTTLHours:  getEnvAsInt("CURSOR_TTL_HOURS", 168),
+
+		// Attachment storage configuration
+		StoragePath:        getEnv("STORAGE_PATH", "./data/storage"),
+		MaxUploadSizeMB:    getEnvAsInt("MAX_UPLOAD_SIZE_MB", 20),
+		MediaURLSecret:     getEnv("MEDIA_URL_SECRET", ""),
+		MediaURLTTLMinutes: getEnvAsInt("MEDIA_URL_TTL_MINUTES", 15),
+
+		// Search index configuration
+		SearchIndexEnabled:     getEnvAsBool("SEARCH_INDEX_ENABLED", false),
+		OpenSearchURL:          getEnv("OPENSEARCH_URL", "http://localhost:9200"),
+		OpenSearchUsername:     getEnv("OPENSEARCH_USERNAME", ""),
+		OpenSearchPassword:     getEnv("OPENSEARCH_PASSWORD", ""),
+		SearchIndexPrefix:      getEnv("SEARCH_INDEX_PREFIX", "healthhub"),
+		SearchIndexPollSeconds: getEnvAsInt("SEARCH_INDEX_POLL_SECONDS", 5),
+
+		// Address verification configuration
+		GeocoderProvider: getEnv("GEOCODER_PROVIDER", "none"),
+		GeocoderURL:      getEnv("GEOCODER_URL", ""),
+		GeocoderAPIKey:   getEnv("GEOCODER_API_KEY", ""),
+		GeocoderAuthID:   getEnv("GEOCODER_AUTH_ID", ""),
+		GeocoderEmail:    getEnv("GEOCODER_EMAIL", ""),
+
+		// Drug interaction checking configuration
+		InteractionProvider: getEnv("INTERACTION_PROVIDER", "table"),
+		InteractionAPIURL:   getEnv("INTERACTION_API_URL", ""),
+		InteractionAPIKey:   getEnv("INTERACTION_API_KEY", ""),
+		AllergyCheckMode:    getEnv("ALLERGY_CHECK_MODE", "block"),
+
+		// Terminology configuration
+		BillingCodeSystem: getEnv("BILLING_CODE_SYSTEM", "http://hl7.org/fhir/sid/icd-10-cm"),
+
+		// Billing configuration
+		BillingProviderName:       getEnv("BILLING_PROVIDER_NAME", ""),
+		BillingProviderNPI:        getEnv("BILLING_PROVIDER_NPI", ""),
+		BillingProviderTaxID:      getEnv("BILLING_PROVIDER_TAX_ID", ""),
+		BillingProviderPhone:      getEnv("BILLING_PROVIDER_PHONE", ""),
+		BillingProviderAddress:    getEnv("BILLING_PROVIDER_ADDRESS", ""),
+		BillingProviderCity:       getEnv("BILLING_PROVIDER_CITY", ""),
+		BillingProviderState:      getEnv("BILLING_PROVIDER_STATE", ""),
+		BillingProviderPostalCode: getEnv("BILLING_PROVIDER_POSTAL_CODE", ""),
+		BillingSenderID:           getEnv("BILLING_SENDER_ID", ""),
+		BillingReceiverID:         getEnv("BILLING_RECEIVER_ID", ""),
+		BillingReceiverName:       getEnv("BILLING_RECEIVER_NAME", ""),
+		BillingPlaceOfService:     getEnv("BILLING_PLACE_OF_SERVICE", "11"),
+		BillingProduction:         getEnvAsBool("BILLING_PRODUCTION", false),
+		ChargeCaptureEnabled:      getEnvAsBool("CHARGE_CAPTURE_ENABLED", true),
+		ChargeCapturePollSeconds:  getEnvAsInt("CHARGE_CAPTURE_POLL_SECONDS", 10),
+
+		// Notification configuration
+		EmailProvider:    getEnv("EMAIL_PROVIDER", "log"),
+		SMTPHost:         getEnv("SMTP_HOST", ""),
+		SMTPPort:         getEnvAsInt("SMTP_PORT", 587),
+		SMTPUsername:     getEnv("SMTP_USERNAME", ""),
+		SMTPPassword:     getEnv("SMTP_PASSWORD", ""),
+		SMTPFrom:         getEnv("SMTP_FROM", ""),
+		SMSProvider:      getEnv("SMS_PROVIDER", "log"),
+		TwilioAccountSID: getEnv("TWILIO_ACCOUNT_SID", ""),
+		TwilioAuthToken:  getEnv("TWILIO_AUTH_TOKEN", ""),
+		TwilioFrom:       getEnv("TWILIO_FROM", ""),
+
+		// De-identification configuration
+		DeidentificationSecret: getEnv("DEID_SECRET", ""),
+
+		// Deletion configuration
+		UndoWindowMinutes: getEnvAsInt("UNDO_WINDOW_MINUTES", 30),
+
+		// Backup configuration
+		PgDumpPath:         getEnv("PG_DUMP_PATH", "pg_dump"),
+		PgRestorePath:      getEnv("PG_RESTORE_PATH", "pg_restore"),
+		StagingDatabaseURL: getEnv("STAGING_DATABASE_URL", ""),
+
+		// External dependency resilience defaults
+		ExternalTimeoutSeconds:     getEnvAsInt("EXTERNAL_TIMEOUT_SECONDS", 10),
+		ExternalMaxAttempts:        getEnvAsInt("EXTERNAL_MAX_ATTEMPTS", 3),
+		CircuitFailureThreshold:    getEnvAsInt("CIRCUIT_FAILURE_THRESHOLD", 5),
+		CircuitOpenDurationSeconds: getEnvAsInt("CIRCUIT_OPEN_DURATION_SECONDS", 30),
+
+		// Background worker coordination across replicas
+		WorkerLockRetrySeconds:   getEnvAsInt("WORKER_LOCK_RETRY_SECONDS", 15),
+		SchemaLockTimeoutSeconds: getEnvAsInt("SCHEMA_LOCK_TIMEOUT_SECONDS", 600),
+
+		// Outbound connection configuration
+		EgressAllowlist: getEnvAsSlice("EGRESS_ALLOWLIST", nil),
+		EgressProxyURL:  getEnv("EGRESS_PROXY_URL", ""),
+		EgressNoProxy:   getEnvAsSlice("EGRESS_NO_PROXY", nil),
+		EgressCAFile:    getEnv("EGRESS_CA_FILE", ""),
+		EgressTLSPins:   getEnvAsSlice("EGRESS_TLS_PINS", nil),
+
+		// Audit log archive configuration
+		AuditArchiveBucket:          getEnv("AUDIT_ARCHIVE_BUCKET", ""),
+		AuditArchiveRegion:          getEnv("AUDIT_ARCHIVE_REGION", "us-east-1"),
+		AuditArchiveEndpoint:        getEnv("AUDIT_ARCHIVE_ENDPOINT", ""),
+		AuditArchivePrefix:          getEnv("AUDIT_ARCHIVE_PREFIX", "audit"),
+		AuditArchiveAccessKeyID:     getEnv("AUDIT_ARCHIVE_ACCESS_KEY_ID", ""),
+		AuditArchiveSecretKey:       getEnv("AUDIT_ARCHIVE_SECRET_ACCESS_KEY", ""),
+		AuditArchiveLockMode:        strings.ToUpper(getEnv("AUDIT_ARCHIVE_LOCK_MODE", "COMPLIANCE")),
+		AuditArchiveRetentionDays:   getEnvAsInt("AUDIT_ARCHIVE_RETENTION_DAYS", 2190),
+		AuditArchiveIntervalMinutes: getEnvAsInt("AUDIT_ARCHIVE_INTERVAL_MINUTES", 60),
+		AuditArchiveBatchSize:       getEnvAsInt("AUDIT_ARCHIVE_BATCH_SIZE", 10000),
+		AuditArchiveSigningKey:      getEnv("AUDIT_ARCHIVE_SIGNING_KEY", ""),
+		AuditArchiveEncryption:      getEnv("AUDIT_ARCHIVE_ENCRYPTION", ""),
+		AuditArchiveRecipient:       getEnv("AUDIT_ARCHIVE_RECIPIENT", ""),
 	}
 }
 
@@ -133,6 +517,33 @@ func (c *Config) IsTest() bool {
 	return c.Environment == "test"
 }
 
+// DerivedSecret returns the key of a signer without a secret of its own, derived from
+// JWT_SECRET as HMAC-SHA256(JWT_SECRET, purpose) so that no signer shares the key of
+// the access tokens or of another signer
+func (c *Config) DerivedSecret(purpose string) string {
+	mac := hmac.New(sha256.New, []byte(c.JWTSecret))
+	mac.Write([]byte(purpose))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ApplyStandalone switches to standalone mode for demos and small sites without a
+// database server: the database is an SQLite file and attachments are stored on disk,
+// both under DataDir, and services that need other servers are turned off. Database
+// and storage locations set explicitly in the environment are kept.
+func (c *Config) ApplyStandalone() {
+	c.Standalone = true
+	if os.Getenv("DATABASE_URL") == "" {
+		c.DatabaseURL = "sqlite://" + filepath.Join(c.DataDir, "healthhub.db")
+	}
+	if os.Getenv("STORAGE_PATH") == "" {
+		c.StoragePath = filepath.Join(c.DataDir, "storage")
+	}
+	c.RedisURL = ""
+	c.ReadReplicaURL = ""
+	c.SearchIndexEnabled = false
+	c.StagingDatabaseURL = ""
+}
+
 // Validate checks if the configuration is valid
 func (c *Config) Validate() error {
 	// Check required fields
@@ -148,14 +559,107 @@ func (c *Config) Validate() error {
 		return NewConfigError("JWT_SECRET must be at least 32 characters long")
 	}
 
+	if c.CursorSecret == c.JWTSecret {
+		return NewConfigError("CURSOR_SECRET must differ from JWT_SECRET; leave it unset to derive one")
+	}
+
+	if c.MediaURLSecret == c.JWTSecret {
+		return NewConfigError("MEDIA_URL_SECRET must differ from JWT_SECRET; leave it unset to derive one")
+	}
+
+	if c.DeidentificationSecret != "" && c.DeidentificationSecret == c.EncryptionKey {
+		return NewConfigError("DEID_SECRET must differ from ENCRYPTION_KEY; leave it unset to derive one")
+	}
+
+	if c.AuditArchiveBucket != "" {
+		if c.AuditArchiveSigningKey == "" {
+			return NewConfigError("AUDIT_ARCHIVE_SIGNING_KEY is required when AUDIT_ARCHIVE_BUCKET is set")
+		}
+		if c.AuditArchiveLockMode != "GOVERNANCE" && c.AuditArchiveLockMode != "COMPLIANCE" {
+			return NewConfigError("AUDIT_ARCHIVE_LOCK_MODE must be GOVERNANCE or COMPLIANCE")
+		}
+		if c.AuditArchiveRetentionDays < 1 {
+			return NewConfigError("AUDIT_ARCHIVE_RETENTION_DAYS must be at least 1")
+		}
+		if (c.AuditArchiveEncryption == "") != (c.AuditArchiveRecipient == "") {
+			return NewConfigError("AUDIT_ARCHIVE_ENCRYPTION and AUDIT_ARCHIVE_RECIPIENT must be set together")
+		}
+	}
+
+	if c.CaptureRetentionHours < 1 {
+		return NewConfigError("CAPTURE_RETENTION_HOURS must be at least 1")
+	}
+
+	if c.UsageRetentionDays < 1 {
+		return NewConfigError("API_USAGE_RETENTION_DAYS must be at least 1")
+	}
+
+	if c.WarmupEnabled && (c.WarmupConnections < 1 || c.WarmupTimeoutSeconds < 1) {
+		return NewConfigError("WARMUP_CONNECTIONS and WARMUP_TIMEOUT_SECONDS must be at least 1")
+	}
+
+	if c.QuarantineRetentionDays < 1 {
+		return NewConfigError("QUARANTINE_RETENTION_DAYS must be at least 1")
+	}
+
+	if c.SchemaLockTimeoutSeconds < 1 {
+		return NewConfigError("SCHEMA_LOCK_TIMEOUT_SECONDS must be at least 1")
+	}
+
+	if c.IntegrityCheckIntervalHours < 0 {
+		return NewConfigError("INTEGRITY_CHECK_INTERVAL_HOURS must not be negative")
+	}
+
+	if c.AnalyticsViewRefreshMinutes < 0 {
+		return NewConfigError("ANALYTICS_VIEW_REFRESH_MINUTES must not be negative")
+	}
+
+	if c.LoadShedThresholdPct < 1 || c.LoadShedThresholdPct > 100 {
+		return NewConfigError("LOAD_SHED_THRESHOLD_PCT must be between 1 and 100")
+	}
+
+	if c.LoadShedRetryAfterSecs < 1 {
+		return NewConfigError("LOAD_SHED_RETRY_AFTER_SECONDS must be at least 1")
+	}
+
+	switch c.ResponseEnvelope {
+	case "paginated", "bare", "bundle":
+	default:
+		return NewConfigError("RESPONSE_ENVELOPE must be paginated, bare or bundle")
+	}
+
+	if c.JWTRotationWindowHours < 1 {
+		return NewConfigError("JWT_ROTATION_WINDOW_HOURS must be at least 1")
+	}
+
+	if c.AccessTokenTTLMinutes < 1 || c.RefreshTokenTTLHours < 1 {
+		return NewConfigError("ACCESS_TOKEN_TTL_MINUTES and REFRESH_TOKEN_TTL_HOURS must be at least 1")
+	}
+
+	if c.RefreshTokenTTLHours*60 <= c.AccessTokenTTLMinutes {
+		return NewConfigError("REFRESH_TOKEN_TTL_HOURS must be longer than ACCESS_TOKEN_TTL_MINUTES")
+	}
+
 	if c.EncryptionKey == "" {
 		return NewConfigError("ENCRYPTION_KEY is required")
 	}
 
+	if c.OIDCIssuerURL != "" && (c.OIDCClientID == "" || c.OIDCRedirectURL == "") {
+		return NewConfigError("OIDC_CLIENT_ID and OIDC_REDIRECT_URL are required when OIDC_ISSUER_URL is set")
+	}
+
 	if len(c.EncryptionKey) != 32 {
 		return NewConfigError("ENCRYPTION_KEY must be exactly 32 characters long")
 	}
 
+	if c.AllergyCheckMode != "block" && c.AllergyCheckMode != "warn" {
+		return NewConfigError("ALLERGY_CHECK_MODE must be block or warn")
+	}
+
+	if c.BillingProviderNPI != "" && !isDigits(c.BillingProviderNPI, 10) {
+		return NewConfigError("BILLING_PROVIDER_NPI must be a 10-digit NPI")
+	}
+
 	if c.TLSEnabled && (c.TLSCertFile == "" || c.TLSKeyFile == "") {
 		return NewConfigError("TLS_CERT_FILE and TLS_KEY_FILE are required when TLS is enabled")
 	}
@@ -177,3 +681,16 @@ func NewConfigError(message string) *ConfigError {
 func (e *ConfigError) Error() string {
 	return "Configuration error: " + e.Message
 }
+
+// isDigits reports whether s consists of exactly n ASCII digits
+func isDigits(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,315 @@
+// Package dashboard aggregates operational statistics of the running system from
+// the metrics registry and the database for the ops dashboard.
+package dashboard
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"gorm.io/gorm"
+)
+
+// Request rates are computed over the counters sampled in the last rateWindow
+const (
+	sampleInterval = 15 * time.Second
+	rateWindow     = 5 * time.Minute
+)
+
+// Sizes of the dashboard's lists
+const (
+	topErrorEndpoints = 5
+	securityEventRows = 20
+)
+
+// SecurityActions are the audit actions reported as security events: failed logins,
+// denied or forged requests, reused refresh tokens and operations that expose or
+// replace data or keys
+var SecurityActions = []string{
+	"login_failed", "access_denied", "rotate", "restore", "purge", "export", "export_download",
+	"capture_start", "capture_read", "signature_invalid", "replay_rejected", "refresh_token_reuse",
+}
+
+// Dashboard is a snapshot of the system's operational state
+type Dashboard struct {
+	GeneratedAt    time.Time            `json:"generatedAt"`
+	Requests       RequestStats         `json:"requests"`
+	Database       map[string]PoolStats `json:"database"` // By connection: primary, replica
+	Queues         QueueStats           `json:"queues"`
+	ActiveSessions int64                `json:"activeSessions"`
+	SecurityEvents []models.AuditLog    `json:"securityEvents"`
+}
+
+// RequestStats are the HTTP request counters of this instance. Totals count since
+// the instance started; rates are per second over the sampled window.
+type RequestStats struct {
+	Total              float64          `json:"total"`
+	ServerErrors       float64          `json:"serverErrors"`
+	ClientErrors       float64          `json:"clientErrors"`
+	WindowSeconds      float64          `json:"windowSeconds"`
+	RatePerSecond      float64          `json:"ratePerSecond"`
+	ErrorRatePerSecond float64          `json:"errorRatePerSecond"`
+	ErrorRatio         float64          `json:"errorRatio"` // Share of the window's requests that failed with a server error
+	TopErrors          []EndpointErrors `json:"topErrors"`
+}
+
+// EndpointErrors counts the server errors of one route
+type EndpointErrors struct {
+	Method   string  `json:"method"`
+	Endpoint string  `json:"endpoint"`
+	Errors   float64 `json:"errors"`
+}
+
+// PoolStats describes a database connection pool
+type PoolStats struct {
+	MaxOpen        int   `json:"maxOpen"`
+	Open           int   `json:"open"`
+	InUse          int   `json:"inUse"`
+	Idle           int   `json:"idle"`
+	WaitCount      int64 `json:"waitCount"`
+	WaitDurationMs int64 `json:"waitDurationMs"`
+}
+
+// QueueStats are the depths of the background work queues
+type QueueStats struct {
+	BulkJobs   JobCounts     `json:"bulkJobs"`
+	ExportJobs JobCounts     `json:"exportJobs"`
+	BackupJobs JobCounts     `json:"backupJobs"`
+	OutboxHead int64         `json:"outboxHead"` // Sequence of the newest outbox event
+	Consumers  []ConsumerLag `json:"consumers"`
+}
+
+// JobCounts counts the unfinished jobs of a queue
+type JobCounts struct {
+	Queued  int64 `json:"queued"`
+	Running int64 `json:"running"`
+}
+
+// ConsumerLag is how far an outbox consumer trails the newest event
+type ConsumerLag struct {
+	Consumer  string    `json:"consumer"`
+	Position  int64     `json:"position"`
+	Lag       int64     `json:"lag"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// requestSample is the request counters at one point in time
+type requestSample struct {
+	at     time.Time
+	total  float64
+	errors float64
+}
+
+// Collector assembles dashboards. It samples the request counters in the background
+// so that rates can be reported.
+type Collector struct {
+	db       *gorm.DB
+	replica  *gorm.DB
+	gatherer prometheus.Gatherer
+
+	mu      sync.Mutex
+	samples []requestSample
+}
+
+// NewCollector creates a new collector. replica may be nil.
+func NewCollector(db, replica *gorm.DB, gatherer prometheus.Gatherer) *Collector {
+	return &Collector{db: db, replica: replica, gatherer: gatherer}
+}
+
+// Run samples the request counters until the context is cancelled
+func (c *Collector) Run(ctx context.Context) {
+	ticker := time.NewTicker(sampleInterval)
+	defer ticker.Stop()
+
+	for {
+		if stats, err := c.requestCounters(); err == nil {
+			c.record(requestSample{at: time.Now(), total: stats.Total, errors: stats.ServerErrors})
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// record keeps a sample and drops the ones that fell out of the window
+func (c *Collector) record(s requestSample) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.samples = append(c.samples, s)
+	cutoff := s.at.Add(-rateWindow)
+	for len(c.samples) > 1 && c.samples[0].at.Before(cutoff) {
+		c.samples = c.samples[1:]
+	}
+}
+
+// Collect assembles the current dashboard
+func (c *Collector) Collect(ctx context.Context) (*Dashboard, error) {
+	now := time.Now()
+	d := &Dashboard{GeneratedAt: now.UTC(), Database: make(map[string]PoolStats)}
+
+	requests, err := c.requestCounters()
+	if err != nil {
+		return nil, err
+	}
+	c.mu.Lock()
+	if len(c.samples) > 0 {
+		oldest := c.samples[0]
+		if window := now.Sub(oldest.at).Seconds(); window > 0 {
+			requests.WindowSeconds = window
+			requests.RatePerSecond = (requests.Total - oldest.total) / window
+			requests.ErrorRatePerSecond = (requests.ServerErrors - oldest.errors) / window
+			if delta := requests.Total - oldest.total; delta > 0 {
+				requests.ErrorRatio = (requests.ServerErrors - oldest.errors) / delta
+			}
+		}
+	}
+	c.mu.Unlock()
+	d.Requests = requests
+
+	for name, conn := range map[string]*gorm.DB{"primary": c.db, "replica": c.replica} {
+		if conn == nil {
+			continue
+		}
+		sqlDB, err := conn.DB()
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s pool: %w", name, err)
+		}
+		stats := sqlDB.Stats()
+		d.Database[name] = PoolStats{
+			MaxOpen:        stats.MaxOpenConnections,
+			Open:           stats.OpenConnections,
+			InUse:          stats.InUse,
+			Idle:           stats.Idle,
+			WaitCount:      stats.WaitCount,
+			WaitDurationMs: stats.WaitDuration.Milliseconds(),
+		}
+	}
+
+	db := c.db.WithContext(ctx)
+	if d.Queues, err = c.queues(db); err != nil {
+		return nil, err
+	}
+
+	// A session lasts as long as its refresh token family has a live token
+	if err := db.Model(&models.RefreshToken{}).Where("revoked_at IS NULL AND used_at IS NULL AND expires_at > ?", now).
+		Count(&d.ActiveSessions).Error; err != nil {
+		return nil, fmt.Errorf("failed to count active sessions: %w", err)
+	}
+
+	d.SecurityEvents = []models.AuditLog{}
+	if err := db.Where("action IN ?", SecurityActions).Order("id DESC").Limit(securityEventRows).
+		Find(&d.SecurityEvents).Error; err != nil {
+		return nil, fmt.Errorf("failed to load security events: %w", err)
+	}
+	return d, nil
+}
+
+// requestCounters reads the HTTP request counters from the metrics registry
+func (c *Collector) requestCounters() (RequestStats, error) {
+	stats := RequestStats{TopErrors: []EndpointErrors{}}
+	families, err := c.gatherer.Gather()
+	if err != nil {
+		return stats, fmt.Errorf("failed to gather metrics: %w", err)
+	}
+
+	for _, family := range families {
+		if family.GetName() != "http_requests_total" {
+			continue
+		}
+		for _, metric := range family.GetMetric() {
+			labels := make(map[string]string, len(metric.GetLabel()))
+			for _, label := range metric.GetLabel() {
+				labels[label.GetName()] = label.GetValue()
+			}
+			value := metric.GetCounter().GetValue()
+			stats.Total += value
+			switch status := labels["status_code"]; {
+			case len(status) == 3 && status[0] == '5':
+				stats.ServerErrors += value
+				stats.TopErrors = append(stats.TopErrors, EndpointErrors{
+					Method:   labels["method"],
+					Endpoint: labels["endpoint"],
+					Errors:   value,
+				})
+			case len(status) == 3 && status[0] == '4':
+				stats.ClientErrors += value
+			}
+		}
+	}
+
+	// A route failing with several status codes is reported once
+	merged := make(map[string]int)
+	top := stats.TopErrors[:0]
+	for _, e := range stats.TopErrors {
+		key := e.Method + " " + e.Endpoint
+		if i, ok := merged[key]; ok {
+			top[i].Errors += e.Errors
+			continue
+		}
+		merged[key] = len(top)
+		top = append(top, e)
+	}
+	sort.SliceStable(top, func(i, j int) bool { return top[i].Errors > top[j].Errors })
+	if len(top) > topErrorEndpoints {
+		top = top[:topErrorEndpoints]
+	}
+	stats.TopErrors = top
+	return stats, nil
+}
+
+// queues counts the unfinished background jobs and the outbox consumer lag
+func (c *Collector) queues(db *gorm.DB) (QueueStats, error) {
+	var q QueueStats
+	for _, queue := range []struct {
+		model   interface{}
+		counts  *JobCounts
+		queued  string
+		running string
+	}{
+		{&models.BulkJob{}, &q.BulkJobs, models.BulkJobQueued, models.BulkJobRunning},
+		{&models.ExportJob{}, &q.ExportJobs, models.ExportJobQueued, models.ExportJobRunning},
+		{&models.BackupJob{}, &q.BackupJobs, models.BackupJobQueued, models.BackupJobRunning},
+	} {
+		var rows []struct {
+			Status string
+			Count  int64
+		}
+		if err := db.Model(queue.model).Select("status, COUNT(*) AS count").
+			Where("status IN ?", []string{queue.queued, queue.running}).Group("status").Scan(&rows).Error; err != nil {
+			return q, fmt.Errorf("failed to count jobs: %w", err)
+		}
+		for _, row := range rows {
+			if row.Status == queue.queued {
+				queue.counts.Queued = row.Count
+			} else {
+				queue.counts.Running = row.Count
+			}
+		}
+	}
+
+	if err := db.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(sequence), 0)").Scan(&q.OutboxHead).Error; err != nil {
+		return q, fmt.Errorf("failed to read outbox head: %w", err)
+	}
+	var checkpoints []models.EventCheckpoint
+	if err := db.Order("consumer").Find(&checkpoints).Error; err != nil {
+		return q, fmt.Errorf("failed to load event checkpoints: %w", err)
+	}
+	q.Consumers = make([]ConsumerLag, 0, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		q.Consumers = append(q.Consumers, ConsumerLag{
+			Consumer:  checkpoint.Consumer,
+			Position:  checkpoint.Position,
+			Lag:       q.OutboxHead - checkpoint.Position,
+			UpdatedAt: checkpoint.UpdatedAt,
+		})
+	}
+	return q, nil
+}
@@ -0,0 +1,123 @@
+package deid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// maxReportedAge is the HIPAA Safe Harbor cap; older ages are aggregated into a single group
+const maxReportedAge = 90
+
+// restrictedZIP3 lists three-digit ZIP prefixes covering fewer than 20,000 people, which
+// Safe Harbor requires to be reported as "000"
+var restrictedZIP3 = map[string]bool{
+	"036": true, "059": true, "063": true, "102": true, "203": true, "556": true,
+	"692": true, "790": true, "821": true, "823": true, "830": true, "831": true,
+	"878": true, "879": true, "884": true, "890": true, "893": true,
+}
+
+// Patient is a de-identified patient record following the HIPAA Safe Harbor method.
+// Direct identifiers are removed, dates are reduced to years and geography to state and
+// three-digit ZIP prefix.
+type Patient struct {
+	PseudoID            string                `json:"pseudoId"`
+	Active              bool                  `json:"active"`
+	Gender              string                `json:"gender"`
+	GenderIdentity      string                `json:"genderIdentity,omitempty"`
+	SexAssignedAtBirth  string                `json:"sexAssignedAtBirth,omitempty"`
+	BirthYear           int                   `json:"birthYear,omitempty"`
+	Age                 *int                  `json:"age,omitempty"`
+	AgeOver89           bool                  `json:"ageOver89,omitempty"`
+	Race                *models.RaceEthnicity `json:"race,omitempty"`
+	Ethnicity           *models.RaceEthnicity `json:"ethnicity,omitempty"`
+	Language            string                `json:"language,omitempty"`
+	InterpreterRequired bool                  `json:"interpreterRequired,omitempty"`
+	State               string                `json:"state,omitempty"`
+	ZIP3                string                `json:"zip3,omitempty"`
+}
+
+// Deidentifier produces de-identified records with stable pseudonymous IDs
+type Deidentifier struct {
+	secret []byte
+}
+
+// New creates a de-identifier. The secret keys pseudonymous IDs so they cannot be
+// reversed without it but remain stable across exports.
+func New(secret string) *Deidentifier {
+	return &Deidentifier{secret: []byte(secret)}
+}
+
+// PseudoID returns a stable pseudonym for a resource ID
+func (d *Deidentifier) PseudoID(id string) string {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte(id))
+	return hex.EncodeToString(mac.Sum(nil))[:32]
+}
+
+// Patient returns the de-identified form of a patient as of the given time
+func (d *Deidentifier) Patient(p *models.Patient, now time.Time) *Patient {
+	record := &Patient{
+		PseudoID:            d.PseudoID(p.ID),
+		Active:              p.Active,
+		Gender:              p.Gender,
+		SexAssignedAtBirth:  p.SexAssignedAtBirth,
+		Race:                stripRaceText(p.Race),
+		Ethnicity:           stripRaceText(p.Ethnicity),
+		Language:            p.PreferredLanguage(),
+		InterpreterRequired: p.InterpreterRequired,
+	}
+
+	if p.GenderIdentity != nil && len(p.GenderIdentity.Coding) > 0 {
+		record.GenderIdentity = p.GenderIdentity.Coding[0].Code
+	}
+
+	if age := p.AgeAt(now); age != nil {
+		if *age >= maxReportedAge {
+			record.AgeOver89 = true
+		} else {
+			record.Age = age
+			record.BirthYear = p.BirthDate.Year()
+		}
+	}
+
+	if len(p.Address) > 0 {
+		address := p.Address[0]
+		record.State = address.State
+		record.ZIP3 = ZIP3(address.PostalCode)
+	}
+
+	return record
+}
+
+// ZIP3 returns the Safe Harbor three-digit ZIP prefix for a postal code
+func ZIP3(postalCode string) string {
+	if len(postalCode) < 3 {
+		return ""
+	}
+	prefix := postalCode[:3]
+	for _, r := range prefix {
+		if r < '0' || r > '9' {
+			return ""
+		}
+	}
+	if restrictedZIP3[prefix] {
+		return "000"
+	}
+	return prefix
+}
+
+// stripRaceText drops the free-text description, which may contain identifying detail,
+// and keeps only the coded categories
+func stripRaceText(value *models.RaceEthnicity) *models.RaceEthnicity {
+	if value == nil {
+		return nil
+	}
+	return &models.RaceEthnicity{
+		OMBCategory: value.OMBCategory,
+		Detailed:    value.Detailed,
+	}
+}
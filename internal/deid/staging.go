@@ -0,0 +1,268 @@
+package deid
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/binary"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// maxShiftDays bounds the per-patient date shift applied to staging copies
+const maxShiftDays = 180
+
+// stagingGiven and stagingFamily are the names scrambled patients are given
+var stagingGiven = []string{
+	"Alex", "Avery", "Blake", "Casey", "Dakota", "Drew", "Elliot", "Emerson",
+	"Finley", "Hayden", "Jamie", "Jordan", "Kai", "Morgan", "Parker", "Quinn",
+	"Reese", "Riley", "Robin", "Rowan", "Sage", "Sam", "Skyler", "Taylor",
+}
+
+var stagingFamily = []string{
+	"Anderson", "Baker", "Carter", "Davis", "Edwards", "Foster", "Garcia", "Harris",
+	"Jackson", "Kim", "Lopez", "Martin", "Nguyen", "Owens", "Patel", "Quinn",
+	"Reed", "Smith", "Thomas", "Turner", "Walker", "Young", "Zhang", "Wright",
+}
+
+// stagingFiller replaces free text; it is repeated to roughly the original length
+const stagingFiller = "lorem ipsum dolor sit amet consectetur adipiscing elit sed do eiusmod tempor "
+
+// The methods below scramble records in place for copying into a staging
+// database. Unlike the Safe Harbor export they keep records in their original
+// shape: names are replaced by pseudonyms, contacts are masked and every date
+// belonging to a patient is moved by the same stable offset, so intervals and
+// ordering survive while the real dates do not.
+
+// DateShift returns the stable offset applied to the dates of a patient, between
+// one and maxShiftDays days into the past or future
+func (d *Deidentifier) DateShift(patientID string) time.Duration {
+	sum := d.sum("shift", patientID)
+	days := int(binary.BigEndian.Uint32(sum)%maxShiftDays) + 1
+	if sum[4]&1 == 1 {
+		days = -days
+	}
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// ScramblePatient replaces the names, identifiers, contacts and address detail of a
+// patient and shifts its dates
+func (d *Deidentifier) ScramblePatient(p *models.Patient) {
+	shift := d.DateShift(p.ID)
+
+	for i := range p.Name {
+		name := &p.Name[i]
+		name.Family = d.pick(stagingFamily, "family", p.ID)
+		for j := range name.Given {
+			name.Given[j] = d.pick(stagingGiven, fmt.Sprintf("given%d", j), p.ID)
+		}
+		name.Prefix = nil
+		name.Suffix = nil
+	}
+
+	for i := range p.Identifier {
+		identifier := &p.Identifier[i]
+		identifier.Value = d.PseudoID(identifier.System + "|" + identifier.Value)[:12]
+		identifier.Assigner = nil
+		identifier.Period = shiftPeriod(identifier.Period, shift)
+	}
+
+	for i := range p.Telecom {
+		d.maskContact(&p.Telecom[i], p.ID, i)
+	}
+
+	for i := range p.Address {
+		address := &p.Address[i]
+		address.Line = []string{fmt.Sprintf("%d Main Street", d.number("line", p.ID, i)%9000+100)}
+		address.Text = ""
+		address.City = ""
+		address.District = ""
+		address.Geolocation = nil
+		if zip3 := ZIP3(address.PostalCode); zip3 != "" {
+			address.PostalCode = zip3 + "00"
+		} else {
+			address.PostalCode = ""
+		}
+		address.Period = shiftPeriod(address.Period, shift)
+	}
+
+	p.Race = stripRaceText(p.Race)
+	p.Ethnicity = stripRaceText(p.Ethnicity)
+	if !p.BirthDate.IsZero() {
+		p.BirthDate = p.BirthDate.Add(shift)
+	}
+	p.CreatedAt = p.CreatedAt.Add(shift)
+	p.UpdatedAt = p.UpdatedAt.Add(shift)
+}
+
+// ScrambleObservation masks the free text of an observation and shifts its dates by
+// the offset of its subject
+func (d *Deidentifier) ScrambleObservation(o *models.Observation) {
+	shift := d.DateShift(strings.TrimPrefix(o.Subject.Reference, "Patient/"))
+
+	o.Subject.Display = ""
+	o.Subject.Identifier = nil
+	for i := range o.Performer {
+		o.Performer[i].Display = ""
+	}
+
+	o.EffectiveDateTime = o.EffectiveDateTime.Add(shift)
+	o.OrderedAt = shiftTime(o.OrderedAt, shift)
+	o.Issued = shiftTime(o.Issued, shift)
+	o.ValueDateTime = shiftTime(o.ValueDateTime, shift)
+	o.ValuePeriod = shiftPeriod(o.ValuePeriod, shift)
+	o.ValueString = maskText(o.ValueString)
+	if o.ValueAttachment != nil {
+		o.ValueAttachment.URL = ""
+		o.ValueAttachment.Title = maskText(o.ValueAttachment.Title)
+		o.ValueAttachment.Creation = shiftTime(o.ValueAttachment.Creation, shift)
+	}
+
+	for i := range o.Note {
+		note := &o.Note[i]
+		note.AuthorString = ""
+		if note.AuthorReference != nil {
+			note.AuthorReference.Display = ""
+		}
+		note.Time = shiftTime(note.Time, shift)
+		note.Text = maskText(note.Text)
+	}
+
+	for i := range o.Component {
+		component := &o.Component[i]
+		component.ValueString = maskText(component.ValueString)
+		component.ValueDateTime = shiftTime(component.ValueDateTime, shift)
+		component.ValuePeriod = shiftPeriod(component.ValuePeriod, shift)
+	}
+
+	o.CreatedAt = o.CreatedAt.Add(shift)
+	o.UpdatedAt = o.UpdatedAt.Add(shift)
+}
+
+// ScrambleNote masks the title and body of a clinical note and shifts its dates by
+// the offset of its subject
+func (d *Deidentifier) ScrambleNote(n *models.ClinicalNote) {
+	shift := d.DateShift(strings.TrimPrefix(n.Subject.Reference, "Patient/"))
+
+	n.Subject.Display = ""
+	n.Author.Display = ""
+	n.Title = maskText(n.Title)
+	n.Body = maskText(n.Body)
+	n.SignedAt = shiftTime(n.SignedAt, shift)
+	n.CreatedAt = n.CreatedAt.Add(shift)
+	n.UpdatedAt = n.UpdatedAt.Add(shift)
+
+	for i := range n.Addenda {
+		addendum := &n.Addenda[i]
+		addendum.Author.Display = ""
+		addendum.Body = maskText(addendum.Body)
+		addendum.CreatedAt = addendum.CreatedAt.Add(shift)
+	}
+}
+
+// ScrambleNoteVersion masks a stored revision of a clinical note written for patientID
+func (d *Deidentifier) ScrambleNoteVersion(v *models.ClinicalNoteVersion, patientID string) {
+	v.Title = maskText(v.Title)
+	v.Body = maskText(v.Body)
+	v.CreatedAt = v.CreatedAt.Add(d.DateShift(patientID))
+}
+
+// ScrambleResponse masks the free-text answers of a questionnaire response and shifts
+// its dates by the offset of its subject
+func (d *Deidentifier) ScrambleResponse(r *models.QuestionnaireResponse) {
+	shift := d.DateShift(strings.TrimPrefix(r.Subject.Reference, "Patient/"))
+
+	r.Subject.Display = ""
+	r.Author.Display = ""
+	maskAnswers(r.Item)
+	r.Authored = r.Authored.Add(shift)
+	r.CreatedAt = r.CreatedAt.Add(shift)
+	r.UpdatedAt = r.UpdatedAt.Add(shift)
+}
+
+// ScrambleMedia clears the content location of a media record, whose file stays in
+// production storage, and shifts its dates by the offset of its subject
+func (d *Deidentifier) ScrambleMedia(m *models.Media) {
+	shift := d.DateShift(strings.TrimPrefix(m.Subject.Reference, "Patient/"))
+
+	m.Subject.Display = ""
+	m.Content.URL = ""
+	m.Content.Title = maskText(m.Content.Title)
+	m.Content.Creation = shiftTime(m.Content.Creation, shift)
+	m.StorageKey = ""
+	m.ThumbnailKey = ""
+	m.CreatedAt = m.CreatedAt.Add(shift)
+	m.UpdatedAt = m.UpdatedAt.Add(shift)
+}
+
+// maskContact replaces a contact value with a reserved fictional number or address
+func (d *Deidentifier) maskContact(contact *models.Contact, patientID string, index int) {
+	switch contact.System {
+	case "email":
+		contact.Value = d.PseudoID(fmt.Sprintf("%s|telecom%d", patientID, index))[:10] + "@example.com"
+	case "url":
+		contact.Value = "https://example.com/"
+	default:
+		// 555-0100 through 555-0199 are reserved for fictional use
+		contact.Value = fmt.Sprintf("+1-202-555-01%02d", d.number("telecom", patientID, index)%100)
+	}
+}
+
+// sum returns a keyed hash of a purpose and value, so different fields of the same
+// record draw independent pseudonyms
+func (d *Deidentifier) sum(purpose, value string) []byte {
+	mac := hmac.New(sha256.New, d.secret)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write([]byte(value))
+	return mac.Sum(nil)
+}
+
+// pick returns a stable choice from values for a purpose and value
+func (d *Deidentifier) pick(values []string, purpose, value string) string {
+	return values[binary.BigEndian.Uint32(d.sum(purpose, value))%uint32(len(values))]
+}
+
+// number returns a stable number for the index-th field of a purpose and value
+func (d *Deidentifier) number(purpose, value string, index int) int {
+	return int(binary.BigEndian.Uint32(d.sum(fmt.Sprintf("%s%d", purpose, index), value)) & 0x7fffffff)
+}
+
+// maskText replaces free text with filler of about the same length
+func maskText(text string) string {
+	n := len([]rune(text))
+	if n == 0 {
+		return ""
+	}
+	filler := strings.Repeat(stagingFiller, n/len(stagingFiller)+1)
+	return strings.TrimSpace(filler[:n])
+}
+
+// maskAnswers masks the string answers of response items and their nested items
+func maskAnswers(items []models.ResponseItem) {
+	for i := range items {
+		for j := range items[i].Answer {
+			items[i].Answer[j].ValueString = maskText(items[i].Answer[j].ValueString)
+		}
+		maskAnswers(items[i].Item)
+	}
+}
+
+// shiftTime returns a copy of t moved by shift
+func shiftTime(t *time.Time, shift time.Duration) *time.Time {
+	if t == nil {
+		return nil
+	}
+	shifted := t.Add(shift)
+	return &shifted
+}
+
+// shiftPeriod returns a copy of p moved by shift
+func shiftPeriod(p *models.Period, shift time.Duration) *models.Period {
+	if p == nil {
+		return nil
+	}
+	return &models.Period{Start: shiftTime(p.Start, shift), End: shiftTime(p.End, shift)}
+}
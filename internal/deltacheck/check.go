@@ -0,0 +1,196 @@
+// Package deltacheck compares a new result with the patient's previous result for
+// the same code. A change larger than the code's thresholds often means the
+// specimen was mislabeled or belongs to another patient.
+package deltacheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/ucum"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+var failures = promauto.NewCounter(prometheus.CounterOpts{
+	Name: "observation_delta_check_failures_total",
+	Help: "Total number of observations flagged by a delta check",
+})
+
+// Failure describes a result that changed more than its rule allows
+type Failure struct {
+	Rule          models.DeltaCheckRule
+	PreviousID    string
+	PreviousValue float64 // Converted to the unit of the new result
+	Change        float64
+	PercentChange *float64 // Absent when the previous value is zero
+}
+
+// Checker runs delta checks against stored results
+type Checker struct {
+	db *gorm.DB
+}
+
+// NewChecker creates a new delta checker
+func NewChecker(db *gorm.DB) *Checker {
+	return &Checker{db: db}
+}
+
+// Check compares a quantity result with the patient's previous result for its code.
+// It returns nil when the result passes or there is no rule or previous result to
+// compare with, and flags a failing observation with a significant change
+// interpretation.
+func (c *Checker) Check(ctx context.Context, observation *models.Observation) (*Failure, error) {
+	if observation.ValueQuantity == nil || observation.Subject.Reference == "" || len(observation.Code.Coding) == 0 {
+		return nil, nil
+	}
+	coding := observation.Code.Coding[0]
+
+	var rule models.DeltaCheckRule
+	err := c.db.WithContext(ctx).Where("code = ? AND active = ? AND (code_system = '' OR code_system = ?)", coding.Code, true, coding.System).
+		First(&rule).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load delta check rule: %w", err)
+	}
+
+	d := dialect.Of(c.db)
+	query := c.db.WithContext(ctx).
+		Where(d.JSONText("subject", "reference")+" = ?", observation.Subject.Reference).
+		Where(d.JSONText("code", "coding", "0", "code")+" = ?", coding.Code).
+		Where("value_quantity_value IS NOT NULL AND status NOT IN ?", []string{"cancelled", "entered-in-error"}).
+		Where("effective_date_time < ?", observation.EffectiveDateTime)
+	if rule.WindowHours > 0 {
+		query = query.Where("effective_date_time >= ?", observation.EffectiveDateTime.Add(-time.Duration(rule.WindowHours)*time.Hour))
+	}
+	var previous models.Observation
+	err = query.Order("effective_date_time DESC").First(&previous).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to load previous result: %w", err)
+	}
+	if previous.ValueQuantity == nil {
+		return nil, nil
+	}
+
+	unit := unitCode(observation.ValueQuantity)
+	previousValue := previous.ValueQuantity.Value
+	if previousUnit := unitCode(previous.ValueQuantity); previousUnit != unit {
+		converted, err := ucum.Convert(previousValue, previousUnit, unit)
+		if err != nil {
+			// Results in units that cannot be converted are not comparable
+			logger.Debug("Skipped delta check between incompatible units",
+				zap.String("code", coding.Code), zap.String("unit", unit), zap.String("previous_unit", previousUnit))
+			return nil, nil
+		}
+		previousValue = converted
+	}
+
+	failure := &Failure{
+		Rule:          rule,
+		PreviousID:    previous.ID,
+		PreviousValue: previousValue,
+		Change:        observation.ValueQuantity.Value - previousValue,
+	}
+	if previousValue != 0 {
+		percent := math.Abs(failure.Change) / math.Abs(previousValue) * 100
+		failure.PercentChange = &percent
+	}
+
+	exceeded := false
+	if rule.AbsoluteChange != nil {
+		change := math.Abs(failure.Change)
+		if rule.Unit != "" && rule.Unit != unit {
+			converted, err := convertDifference(change, unit, rule.Unit)
+			if err != nil {
+				logger.Warn("Delta check threshold unit does not match the result",
+					zap.String("code", coding.Code), zap.String("unit", unit), zap.String("rule_unit", rule.Unit))
+				return nil, nil
+			}
+			change = converted
+		}
+		exceeded = change > *rule.AbsoluteChange
+	}
+	if rule.PercentChange != nil && failure.PercentChange != nil && *failure.PercentChange > *rule.PercentChange {
+		exceeded = true
+	}
+	if !exceeded {
+		return nil, nil
+	}
+
+	flag := models.Coding{System: models.InterpretationSystem, Code: "U", Display: "Significant change up"}
+	if failure.Change < 0 {
+		flag = models.Coding{System: models.InterpretationSystem, Code: "D", Display: "Significant change down"}
+	}
+	observation.Interpretation = append(observation.Interpretation, models.CodeableConcept{
+		Coding: []models.Coding{flag},
+		Text:   "Delta check failed",
+	})
+	failures.Inc()
+	return failure, nil
+}
+
+// Alert returns the alert to raise for a failure of observation, or nil when the
+// rule does not raise alerts
+func (f *Failure) Alert(observation *models.Observation) *models.Alert {
+	if !f.Rule.RaiseAlert {
+		return nil
+	}
+	unit := unitCode(observation.ValueQuantity)
+	details := map[string]interface{}{
+		"patient":        observation.Subject.Reference,
+		"code":           f.Rule.Code,
+		"value":          observation.ValueQuantity.Value,
+		"unit":           unit,
+		"previous_id":    f.PreviousID,
+		"previous_value": f.PreviousValue,
+		"change":         f.Change,
+	}
+	if f.PercentChange != nil {
+		details["percent_change"] = *f.PercentChange
+	}
+	return &models.Alert{
+		Type:         models.AlertTypeDeltaCheck,
+		Severity:     models.AlertSeverityWarning,
+		ResourceType: "Observation",
+		ResourceID:   observation.ID,
+		Department:   observation.Department(),
+		Message: fmt.Sprintf("Delta check failed for %s on %s: %g %s, previously %g",
+			f.Rule.Code, observation.Subject.Reference, observation.ValueQuantity.Value, unit, f.PreviousValue),
+		Details: details,
+	}
+}
+
+// unitCode returns the UCUM code of a quantity, falling back to its display unit
+func unitCode(q *models.Quantity) string {
+	if q.Code != "" {
+		return q.Code
+	}
+	return q.Unit
+}
+
+// convertDifference converts a difference between two values, which has no
+// temperature offset, from one unit to another
+func convertDifference(value float64, from, to string) (float64, error) {
+	zero, err := ucum.Convert(0, from, to)
+	if err != nil {
+		return 0, err
+	}
+	converted, err := ucum.Convert(value, from, to)
+	if err != nil {
+		return 0, err
+	}
+	return converted - zero, nil
+}
@@ -0,0 +1,186 @@
+// Package escalation notifies the on-call chain of a department while a critical
+// alert stays unacknowledged.
+package escalation
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/notify"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// batchSize bounds how many alerts are escalated per pass
+const batchSize = 100
+
+var notificationsSent = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "alert_escalations_total",
+	Help: "Total number of alert escalation notifications, by department and status",
+}, []string{"department", "status"})
+
+// Escalator walks the on-call chains of unacknowledged critical alerts
+type Escalator struct {
+	db       *gorm.DB
+	email    notify.Sender
+	sms      notify.Sender
+	interval time.Duration
+}
+
+// NewEscalator creates a new escalator that notifies contacts by email and SMS
+func NewEscalator(db *gorm.DB, email, sms notify.Sender) *Escalator {
+	return &Escalator{
+		db:       db,
+		email:    email,
+		sms:      sms,
+		interval: 30 * time.Second,
+	}
+}
+
+// Run escalates due alerts until the context is cancelled
+func (e *Escalator) Run(ctx context.Context) {
+	ticker := time.NewTicker(e.interval)
+	defer ticker.Stop()
+
+	for {
+		if escalated, err := e.Escalate(ctx); err != nil {
+			logger.Error("Failed to escalate alerts", zap.Error(err))
+		} else if escalated > 0 {
+			logger.Info("Escalated alerts", zap.Int("alerts", escalated))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Escalate notifies the next contact of every open critical alert that is due and
+// returns the number of alerts escalated. An alert is due when it is raised and
+// again whenever the previous contact has not acknowledged it in time.
+func (e *Escalator) Escalate(ctx context.Context) (int, error) {
+	var chains []models.OnCallChain
+	if err := e.db.WithContext(ctx).Where("active = ?", true).Find(&chains).Error; err != nil {
+		return 0, fmt.Errorf("failed to load on-call chains: %w", err)
+	}
+	if len(chains) == 0 {
+		return 0, nil
+	}
+	byDepartment := make(map[string]models.OnCallChain, len(chains))
+	departments := make([]string, 0, len(chains))
+	for _, chain := range chains {
+		byDepartment[chain.Department] = chain
+		departments = append(departments, chain.Department)
+	}
+
+	now := time.Now().UTC()
+	var alerts []models.Alert
+	if err := e.db.WithContext(ctx).
+		Where("status = ? AND severity = ? AND department IN ?", models.AlertStatusOpen, models.AlertSeverityCritical, departments).
+		Where("(next_escalation_at IS NULL AND escalation_level = 0) OR next_escalation_at <= ?", now).
+		Order("created_at").Limit(batchSize).Find(&alerts).Error; err != nil {
+		return 0, fmt.Errorf("failed to find alerts to escalate: %w", err)
+	}
+
+	escalated := 0
+	for i := range alerts {
+		ok, err := e.escalate(ctx, &alerts[i], byDepartment[alerts[i].Department], now)
+		if err != nil {
+			return escalated, err
+		}
+		if ok {
+			escalated++
+		}
+	}
+	return escalated, nil
+}
+
+// escalate notifies the next contact in the chain of an alert. The alert is advanced
+// before notifying, so a contact is never notified twice for the same step.
+func (e *Escalator) escalate(ctx context.Context, alert *models.Alert, chain models.OnCallChain, now time.Time) (bool, error) {
+	level := alert.EscalationLevel
+	updates := map[string]interface{}{"next_escalation_at": nil}
+	if level < len(chain.Contacts) {
+		updates["escalation_level"] = level + 1
+		updates["next_escalation_at"] = now.Add(time.Duration(chain.AcknowledgeMinutes) * time.Minute)
+	}
+	result := e.db.WithContext(ctx).Model(&models.Alert{}).
+		Where("id = ? AND status = ? AND escalation_level = ?", alert.ID, models.AlertStatusOpen, level).
+		Updates(updates)
+	if result.Error != nil {
+		return false, fmt.Errorf("failed to advance alert %s: %w", alert.ID, result.Error)
+	}
+	if result.RowsAffected == 0 {
+		// Acknowledged or advanced in the meantime
+		return false, nil
+	}
+
+	if level >= len(chain.Contacts) {
+		logger.Error("Critical alert still unacknowledged after notifying the whole on-call chain",
+			zap.String("alert_id", alert.ID),
+			zap.String("department", alert.Department),
+		)
+		return false, e.record(ctx, models.AlertEscalation{
+			AlertID:    alert.ID,
+			Department: alert.Department,
+			Level:      level,
+			Status:     models.EscalationExhausted,
+		})
+	}
+
+	contact := chain.Contacts[level]
+	subject := fmt.Sprintf("Critical alert needs acknowledgement: %s", alert.Type)
+	body := fmt.Sprintf("%s\n\nRaised at %s in %s. You are on-call contact %d of %d.\nAlert: %s\n",
+		alert.Message, alert.CreatedAt.UTC().Format(time.RFC3339), alert.Department, level+1, len(chain.Contacts), alert.ID)
+
+	for _, target := range []struct {
+		channel, to string
+		sender      notify.Sender
+	}{
+		{"email", contact.Email, e.email},
+		{"sms", contact.Phone, e.sms},
+	} {
+		if target.to == "" {
+			continue
+		}
+		escalation := models.AlertEscalation{
+			AlertID:     alert.ID,
+			Department:  alert.Department,
+			Level:       level + 1,
+			Contact:     contact.Name,
+			Channel:     target.channel,
+			Destination: target.to,
+			Status:      models.EscalationSent,
+		}
+		if err := target.sender.Send(ctx, notify.Message{To: target.to, Subject: subject, Body: body}); err != nil {
+			escalation.Status = models.EscalationFailed
+			escalation.Error = err.Error()
+			logger.Warn("Failed to notify on-call contact",
+				zap.String("alert_id", alert.ID),
+				zap.String("contact", contact.Name),
+				zap.String("channel", target.channel),
+				zap.Error(err),
+			)
+		}
+		if err := e.record(ctx, escalation); err != nil {
+			return true, err
+		}
+	}
+	return true, nil
+}
+
+// record stores an escalation in the alert's history
+func (e *Escalator) record(ctx context.Context, escalation models.AlertEscalation) error {
+	notificationsSent.WithLabelValues(escalation.Department, escalation.Status).Inc()
+	if err := e.db.WithContext(ctx).Create(&escalation).Error; err != nil {
+		return fmt.Errorf("failed to record alert escalation: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,131 @@
+package events
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// SettleDelay holds back very recent events from consumers and the change feed.
+// Sequence numbers are assigned when a row is inserted, not when its transaction
+// commits, so a young gap may still be filled by a transaction that is about to commit.
+const SettleDelay = 2 * time.Second
+
+// Handler processes a single outbox event. Returning an error stops the current batch;
+// the event is retried on the next poll.
+type Handler func(ctx context.Context, event models.OutboxEvent) error
+
+// Consumer reads the outbox in sequence order on behalf of a named subscriber and
+// persists its position after each handled event
+type Consumer struct {
+	db        *gorm.DB
+	name      string
+	handler   Handler
+	interval  time.Duration
+	batchSize int
+}
+
+// NewConsumer creates a new outbox consumer
+func NewConsumer(db *gorm.DB, name string, handler Handler, interval time.Duration) *Consumer {
+	if interval <= 0 {
+		interval = 5 * time.Second
+	}
+
+	return &Consumer{
+		db:        db,
+		name:      name,
+		handler:   handler,
+		interval:  interval,
+		batchSize: 100,
+	}
+}
+
+// Run polls the outbox until the context is cancelled
+func (c *Consumer) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.interval)
+	defer ticker.Stop()
+
+	for {
+		// Drain full batches immediately, then wait for the next tick
+		for {
+			handled, err := c.Poll(ctx)
+			if err != nil {
+				logger.Warn("Outbox consumer failed",
+					zap.String("consumer", c.name),
+					zap.Error(err),
+				)
+				break
+			}
+			if handled < c.batchSize {
+				break
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Poll handles the next batch of events and returns how many were handled
+func (c *Consumer) Poll(ctx context.Context) (int, error) {
+	position, err := c.Position()
+	if err != nil {
+		return 0, err
+	}
+
+	var events []models.OutboxEvent
+	if err := c.db.WithContext(ctx).
+		Where("sequence > ? AND occurred_at <= ?", position, time.Now().UTC().Add(-SettleDelay)).
+		Order("sequence ASC").Limit(c.batchSize).Find(&events).Error; err != nil {
+		return 0, fmt.Errorf("failed to read outbox: %w", err)
+	}
+
+	for i, event := range events {
+		if err := c.handler(ctx, event); err != nil {
+			return i, fmt.Errorf("failed to handle event %d: %w", event.Sequence, err)
+		}
+		if err := c.SetPosition(event.Sequence); err != nil {
+			return i, err
+		}
+	}
+
+	return len(events), nil
+}
+
+// Position returns the sequence of the last event handled by this consumer
+func (c *Consumer) Position() (int64, error) {
+	var checkpoint models.EventCheckpoint
+	if err := c.db.Where("consumer = ?", c.name).First(&checkpoint).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			return 0, nil
+		}
+		return 0, fmt.Errorf("failed to read checkpoint: %w", err)
+	}
+	return checkpoint.Position, nil
+}
+
+// SetPosition stores the sequence of the last event handled by this consumer
+func (c *Consumer) SetPosition(position int64) error {
+	checkpoint := models.EventCheckpoint{
+		Consumer:  c.name,
+		Position:  position,
+		UpdatedAt: time.Now().UTC(),
+	}
+
+	if err := c.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "consumer"}},
+		DoUpdates: clause.AssignmentColumns([]string{"position", "updated_at"}),
+	}).Create(&checkpoint).Error; err != nil {
+		return fmt.Errorf("failed to store checkpoint: %w", err)
+	}
+	return nil
+}
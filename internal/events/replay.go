@@ -0,0 +1,139 @@
+package events
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrUnknownConsumer is returned when replaying events to a consumer that is not
+// registered
+var ErrUnknownConsumer = errors.New("events: unknown consumer")
+
+// Replayer replays ranges of outbox events to the handlers of named consumers as
+// operations. Handlers must tolerate seeing an event again, as consumers already do
+// after a failed batch.
+type Replayer struct {
+	db        *gorm.DB
+	handlers  map[string]Handler
+	batchSize int
+}
+
+// NewReplayer creates a replayer without consumers
+func NewReplayer(db *gorm.DB) *Replayer {
+	return &Replayer{
+		db:        db,
+		handlers:  make(map[string]Handler),
+		batchSize: 100,
+	}
+}
+
+// Register makes a consumer's handler available for replays
+func (r *Replayer) Register(consumer string, handler Handler) {
+	r.handlers[consumer] = handler
+}
+
+// Consumers returns the names of the registered consumers, sorted
+func (r *Replayer) Consumers() []string {
+	names := make([]string, 0, len(r.handlers))
+	for name := range r.handlers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Has reports whether a consumer is registered
+func (r *Replayer) Has(consumer string) bool {
+	_, ok := r.handlers[consumer]
+	return ok
+}
+
+// Scope restricts an outbox query to the events a replay covers
+func Scope(query *gorm.DB, replay *models.EventReplay) *gorm.DB {
+	if replay.ResourceType != "" {
+		query = query.Where("resource_type = ?", replay.ResourceType)
+	}
+	if replay.From != nil {
+		query = query.Where("occurred_at >= ?", *replay.From)
+	}
+	if replay.To != nil {
+		query = query.Where("occurred_at < ?", *replay.To)
+	}
+	return query
+}
+
+// Execute replays the events of the operation's replay in sequence order, resuming
+// after the last event it replayed
+func (r *Replayer) Execute(ctx context.Context, task *operation.Task) (interface{}, error) {
+	var replay models.EventReplay
+	if err := r.db.Where("id = ?", task.Operation.TargetID()).First(&replay).Error; err != nil {
+		return nil, fmt.Errorf("failed to load event replay: %w", err)
+	}
+	handler, ok := r.handlers[replay.Consumer]
+	if !ok {
+		return nil, fmt.Errorf("%w %s", ErrUnknownConsumer, replay.Consumer)
+	}
+
+	// Events recorded after the replay was requested reach the consumer as usual
+	scope := func() *gorm.DB {
+		return Scope(r.db.WithContext(ctx).Model(&models.OutboxEvent{}), &replay).
+			Where("occurred_at <= ?", replay.CreatedAt)
+	}
+	var remaining int64
+	if err := scope().Where("sequence > ?", replay.Cursor).Count(&remaining).Error; err != nil {
+		return nil, fmt.Errorf("failed to count events: %w", err)
+	}
+	total := replay.Replayed + remaining
+
+	for {
+		var events []models.OutboxEvent
+		if err := scope().Where("sequence > ?", replay.Cursor).Order("sequence ASC").Limit(r.batchSize).
+			Find(&events).Error; err != nil {
+			return nil, fmt.Errorf("failed to read outbox: %w", err)
+		}
+		if len(events) == 0 {
+			break
+		}
+
+		for _, event := range events {
+			if err := handler(ctx, event); err != nil {
+				// Keep the events replayed so far, so a new run resumes after them
+				if saveErr := r.save(&replay); saveErr != nil {
+					logger.Warn("Failed to store event replay progress", zap.String("replay_id", replay.ID), zap.Error(saveErr))
+				}
+				return nil, fmt.Errorf("failed to replay event %d: %w", event.Sequence, err)
+			}
+			replay.Cursor = event.Sequence
+			replay.Replayed++
+		}
+		if err := r.save(&replay); err != nil {
+			return nil, err
+		}
+		if err := task.Progress(replay.Replayed, total); err != nil {
+			logger.Warn("Failed to record event replay progress", zap.String("replay_id", replay.ID), zap.Error(err))
+		}
+	}
+
+	logger.LogAuditEvent("event_replay_complete", "EventReplay", replay.CreatedBy, map[string]interface{}{
+		"replay_id": replay.ID,
+		"consumer":  replay.Consumer,
+		"replayed":  replay.Replayed,
+	})
+	return replay, nil
+}
+
+// save stores how far a replay has got
+func (r *Replayer) save(replay *models.EventReplay) error {
+	if err := r.db.Model(replay).Select("cursor", "replayed").Updates(replay).Error; err != nil {
+		return fmt.Errorf("failed to store event replay progress: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,202 @@
+package export
+
+import (
+	"encoding/csv"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/deid"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// recordEncoder writes one export record
+type recordEncoder interface {
+	Encode(v interface{}) error
+}
+
+// CSV columns per record type. CSV exports flatten each record to its most commonly
+// used fields; NDJSON exports carry the full resource.
+var (
+	patientColumns = []string{
+		"id", "active", "identifier", "family", "given", "gender", "birthDate",
+		"phone", "email", "updatedAt",
+	}
+	deidentifiedPatientColumns = []string{
+		"pseudoId", "active", "gender", "sexAssignedAtBirth", "birthYear", "age",
+		"ageOver89", "language", "state", "zip3",
+	}
+	observationColumns = []string{
+		"id", "patient", "status", "category", "codeSystem", "code", "display",
+		"value", "unit", "effectiveDateTime", "issued", "updatedAt",
+	}
+)
+
+// csvColumns returns the CSV header for an export's records
+func csvColumns(resourceType string, deidentified bool) []string {
+	switch {
+	case resourceType == "Observation":
+		return observationColumns
+	case deidentified:
+		return deidentifiedPatientColumns
+	default:
+		return patientColumns
+	}
+}
+
+// csvEncoder writes records as CSV rows below a header line
+type csvEncoder struct {
+	w      *csv.Writer
+	header []string
+	wrote  bool
+}
+
+// newCSVEncoder creates a CSV encoder writing the given header
+func newCSVEncoder(w io.Writer, header []string) *csvEncoder {
+	return &csvEncoder{w: csv.NewWriter(w), header: header}
+}
+
+// Encode writes a record as one CSV row
+func (e *csvEncoder) Encode(v interface{}) error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+
+	var row []string
+	switch record := v.(type) {
+	case *models.Patient:
+		row = patientRow(record)
+	case *deid.Patient:
+		row = deidentifiedPatientRow(record)
+	case *models.Observation:
+		row = observationRow(record)
+	default:
+		return fmt.Errorf("unsupported CSV record type %T", v)
+	}
+	return e.w.Write(row)
+}
+
+// Flush writes the header of an empty file and any buffered rows
+func (e *csvEncoder) Flush() error {
+	if err := e.writeHeader(); err != nil {
+		return err
+	}
+	e.w.Flush()
+	return e.w.Error()
+}
+
+// writeHeader writes the header line once
+func (e *csvEncoder) writeHeader() error {
+	if e.wrote {
+		return nil
+	}
+	e.wrote = true
+	return e.w.Write(e.header)
+}
+
+// patientRow flattens a patient for CSV export
+func patientRow(p *models.Patient) []string {
+	var identifier, family, given string
+	if len(p.Identifier) > 0 {
+		identifier = p.Identifier[0].Value
+	}
+	if len(p.Name) > 0 {
+		family = p.Name[0].Family
+		given = strings.Join(p.Name[0].Given, " ")
+	}
+
+	return []string{
+		p.ID,
+		strconv.FormatBool(p.Active),
+		identifier,
+		family,
+		given,
+		p.Gender,
+		formatDate(p.BirthDate),
+		p.GetPrimaryPhone(),
+		p.GetPrimaryEmail(),
+		formatTime(&p.UpdatedAt),
+	}
+}
+
+// deidentifiedPatientRow flattens a de-identified patient for CSV export
+func deidentifiedPatientRow(p *deid.Patient) []string {
+	row := []string{
+		p.PseudoID,
+		strconv.FormatBool(p.Active),
+		p.Gender,
+		p.SexAssignedAtBirth,
+		"",
+		"",
+		strconv.FormatBool(p.AgeOver89),
+		p.Language,
+		p.State,
+		p.ZIP3,
+	}
+	if p.BirthYear != 0 {
+		row[4] = strconv.Itoa(p.BirthYear)
+	}
+	if p.Age != nil {
+		row[5] = strconv.Itoa(*p.Age)
+	}
+	return row
+}
+
+// observationRow flattens an observation for CSV export
+func observationRow(o *models.Observation) []string {
+	var categories []string
+	for _, category := range o.Category {
+		for _, coding := range category.Coding {
+			categories = append(categories, coding.Code)
+		}
+	}
+
+	var system, code string
+	if len(o.Code.Coding) > 0 {
+		system = o.Code.Coding[0].System
+		code = o.Code.Coding[0].Code
+	}
+
+	value := o.GetDisplayValue()
+	var unit string
+	if o.ValueQuantity != nil {
+		value = strconv.FormatFloat(o.ValueQuantity.Value, 'f', -1, 64)
+		unit = o.ValueQuantity.Unit
+		if unit == "" {
+			unit = o.ValueQuantity.Code
+		}
+	}
+
+	return []string{
+		o.ID,
+		o.Subject.Reference,
+		o.Status,
+		strings.Join(categories, " "),
+		system,
+		code,
+		o.GetCodeDisplay(),
+		value,
+		unit,
+		formatTime(&o.EffectiveDateTime),
+		formatTime(o.Issued),
+		formatTime(&o.UpdatedAt),
+	}
+}
+
+// formatDate formats a date-only value, leaving zero dates empty
+func formatDate(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format("2006-01-02")
+}
+
+// formatTime formats a timestamp as RFC 3339 in UTC, leaving missing values empty
+func formatTime(t *time.Time) string {
+	if t == nil || t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
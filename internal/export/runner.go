@@ -0,0 +1,435 @@
+package export
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/deid"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/encryption"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ManifestName is the name of the manifest file of every export
+const ManifestName = "manifest.json"
+
+// Export sizing
+const (
+	batchSize      = 500
+	recordsPerFile = 100000
+)
+
+// Runner runs export jobs as operations
+type Runner struct {
+	db    *gorm.DB
+	store storage.Storage
+	deid  *deid.Deidentifier
+}
+
+// NewRunner creates a new export job runner
+func NewRunner(db *gorm.DB, store storage.Storage, deidentifier *deid.Deidentifier) *Runner {
+	return &Runner{
+		db:    db,
+		store: store,
+		deid:  deidentifier,
+	}
+}
+
+// Execute runs the export job of an operation and returns the completed job. A job
+// interrupted by a restart is run again from the start, replacing any files already
+// written.
+func (r *Runner) Execute(ctx context.Context, task *operation.Task) (interface{}, error) {
+	var job models.ExportJob
+	if err := r.db.Where("id = ?", task.Operation.TargetID()).First(&job).Error; err != nil {
+		return nil, fmt.Errorf("failed to load export job: %w", err)
+	}
+
+	if err := r.process(ctx, &job); err != nil {
+		switch {
+		case task.Cancelled():
+			r.finish(&job, models.ExportJobCancelled, "")
+		case ctx.Err() == nil:
+			r.finish(&job, models.ExportJobFailed, err.Error())
+			logger.Error("Export job failed", zap.String("job_id", job.ID), zap.Error(err))
+		}
+		return nil, err
+	}
+	if err := task.Progress(job.Records, job.Records); err != nil {
+		logger.Warn("Failed to record export progress", zap.String("job_id", job.ID), zap.Error(err))
+	}
+	return job, nil
+}
+
+// process writes the export files and manifest for a job
+func (r *Runner) process(ctx context.Context, job *models.ExportJob) error {
+	now := time.Now()
+	job.Status = models.ExportJobRunning
+	job.StartedAt = &now
+	if err := r.db.Model(job).Select("status", "started_at").Updates(job).Error; err != nil {
+		return err
+	}
+
+	var recipient encryption.Recipient
+	if job.EncryptionFormat != "" {
+		var err error
+		if recipient, err = encryption.ParseRecipient(job.EncryptionFormat, job.RecipientKey); err != nil {
+			return err
+		}
+	}
+
+	put := func(name string, body io.Reader) (int64, error) {
+		return r.store.Put(models.ExportStorageKey(job.ID, name), body)
+	}
+	w := &exportWriter{
+		put:       put,
+		prefix:    fileStem(job.ResourceType, job.Deidentified),
+		format:    "ndjson",
+		recipient: recipient,
+	}
+	defer w.abort()
+
+	sel := selection{
+		resourceType: job.ResourceType,
+		deidentified: job.Deidentified,
+		activeOnly:   job.ActiveOnly,
+		filter:       job.Filter,
+	}
+	if err := sel.write(ctx, r.db, r.deid, w); err != nil {
+		return err
+	}
+
+	manifest := models.ExportManifest{
+		JobID:        job.ID,
+		ResourceType: job.ResourceType,
+		Deidentified: job.Deidentified,
+		ActiveOnly:   job.ActiveOnly,
+		Filter:       job.Filter,
+		Format:       "ndjson",
+		Records:      w.total,
+		Files:        w.files,
+		CreatedBy:    job.CreatedBy,
+		GeneratedAt:  time.Now().UTC(),
+	}
+	if recipient != nil {
+		manifest.Encryption = &models.ExportEncryption{Format: job.EncryptionFormat, Fingerprint: recipient.Fingerprint()}
+	}
+	if err := writeManifest(put, manifest); err != nil {
+		return err
+	}
+
+	completed := time.Now()
+	job.Status = models.ExportJobCompleted
+	job.Records = w.total
+	job.Files = w.files
+	job.CompletedAt = &completed
+	if err := r.db.Model(job).Select("status", "records", "files", "completed_at").Updates(job).Error; err != nil {
+		return err
+	}
+
+	logger.LogAuditEvent("export_complete", job.ResourceType, job.CreatedBy, map[string]interface{}{
+		"job_id":       job.ID,
+		"records":      job.Records,
+		"files":        len(job.Files),
+		"deidentified": job.Deidentified,
+		"encrypted":    recipient != nil,
+	})
+	return nil
+}
+
+// selection describes which records an export writes
+type selection struct {
+	resourceType string
+	deidentified bool
+	activeOnly   bool
+	filter       *models.ObservationFilter
+
+	// Update window of incremental exports
+	updatedAfter *time.Time
+	updatedUntil *time.Time
+}
+
+// write streams the selected records to w and closes its last file
+func (sel selection) write(ctx context.Context, db *gorm.DB, deidentifier *deid.Deidentifier, w *exportWriter) error {
+	var err error
+	switch sel.resourceType {
+	case "Patient":
+		err = sel.writePatients(ctx, db, deidentifier, w)
+	case "Observation":
+		err = sel.writeObservations(ctx, db, w)
+	default:
+		err = fmt.Errorf("unsupported resource type %q", sel.resourceType)
+	}
+	if err != nil {
+		return err
+	}
+	return w.finish()
+}
+
+// scope restricts a query to the update window, if any
+func (sel selection) scope(query *gorm.DB) *gorm.DB {
+	if sel.updatedAfter != nil {
+		query = query.Where("updated_at > ?", *sel.updatedAfter)
+	}
+	if sel.updatedUntil != nil {
+		query = query.Where("updated_at <= ?", *sel.updatedUntil)
+	}
+	return query
+}
+
+// writePatients writes patients, de-identified when the selection asks for it
+func (sel selection) writePatients(ctx context.Context, db *gorm.DB, deidentifier *deid.Deidentifier, w *exportWriter) error {
+	query := sel.scope(db.WithContext(ctx).Model(&models.Patient{}))
+	if sel.activeOnly {
+		query = query.Where("active = ?", true)
+	}
+
+	now := time.Now()
+	var patients []models.Patient
+	return query.Order("id ASC").FindInBatches(&patients, batchSize, func(tx *gorm.DB, batch int) error {
+		for i := range patients {
+			var record interface{} = &patients[i]
+			if sel.deidentified {
+				record = deidentifier.Patient(&patients[i], now)
+			}
+			if err := w.write(record); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error
+}
+
+// writeObservations writes the observations matching the selection's filter
+func (sel selection) writeObservations(ctx context.Context, db *gorm.DB, w *exportWriter) error {
+	query := sel.scope(db.WithContext(ctx).Model(&models.Observation{}))
+	if sel.filter != nil {
+		query = sel.filter.Apply(query)
+	}
+
+	// An incremental export lists the results corrected since the previous one, so
+	// its consumers can re-notify theirs
+	var corrected map[string]bool
+	if sel.updatedAfter != nil {
+		var ids []string
+		if err := db.WithContext(ctx).Model(&models.OutboxEvent{}).Distinct("resource_id").
+			Where("resource_type = ? AND corrected = ? AND occurred_at > ?", "Observation", true, *sel.updatedAfter).
+			Pluck("resource_id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to load corrections: %w", err)
+		}
+		corrected = make(map[string]bool, len(ids))
+		for _, id := range ids {
+			corrected[id] = true
+		}
+	}
+
+	var observations []models.Observation
+	return query.Order("id ASC").FindInBatches(&observations, batchSize, func(tx *gorm.DB, batch int) error {
+		for i := range observations {
+			if err := w.write(&observations[i]); err != nil {
+				return err
+			}
+			if corrected[observations[i].ID] {
+				w.corrected = append(w.corrected, observations[i].ID)
+			}
+		}
+		return nil
+	}).Error
+}
+
+// writeManifest stores a manifest next to the export files
+func writeManifest(put func(name string, r io.Reader) (int64, error), manifest models.ExportManifest) error {
+	data, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return err
+	}
+	if _, err := put(ManifestName, bytes.NewReader(data)); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// finish marks a job as failed or cancelled
+func (r *Runner) finish(job *models.ExportJob, status, cause string) {
+	now := time.Now()
+	job.Status = status
+	job.Error = cause
+	job.CompletedAt = &now
+	if err := r.db.Model(job).Select("status", "error", "completed_at").Updates(job).Error; err != nil {
+		logger.Error("Failed to record export job "+status, zap.String("job_id", job.ID), zap.Error(err))
+	}
+}
+
+// fileStem returns the base name shared by an export's files
+func fileStem(resourceType string, deidentified bool) string {
+	stem := strings.ToLower(resourceType) + "s"
+	if deidentified {
+		stem += "-deidentified"
+	}
+	return stem
+}
+
+// exportWriter splits records across NDJSON or CSV files, streaming each file to its
+// destination through the optional encryption layer while hashing the stored bytes
+type exportWriter struct {
+	put       func(name string, r io.Reader) (int64, error)
+	prefix    string
+	format    string
+	columns   []string // CSV header
+	recipient encryption.Recipient
+
+	files     []models.ExportFile
+	total     int64
+	corrected []string // IDs of written results corrected within the update window
+
+	current *openFile
+}
+
+// openFile is an export file that is being written
+type openFile struct {
+	name    string
+	records int64
+	pipe    *io.PipeWriter
+	sink    io.WriteCloser // Encryption layer, or nil for plaintext
+	encoder recordEncoder
+	sum     func() []byte
+	done    chan putResult
+}
+
+// putResult is the outcome of streaming a file to storage
+type putResult struct {
+	n   int64
+	err error
+}
+
+// write appends a record, starting a new file when the current one is full
+func (w *exportWriter) write(record interface{}) error {
+	if w.current != nil && w.current.records >= recordsPerFile {
+		if err := w.closeFile(); err != nil {
+			return err
+		}
+	}
+	if w.current == nil {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+
+	if err := w.current.encoder.Encode(record); err != nil {
+		return err
+	}
+	w.current.records++
+	w.total++
+	return nil
+}
+
+// finish closes the last file. An export without records still produces one empty file,
+// so consumers can tell an empty export from a missing one.
+func (w *exportWriter) finish() error {
+	if w.current == nil && len(w.files) == 0 {
+		if err := w.openFile(); err != nil {
+			return err
+		}
+	}
+	if w.current != nil {
+		return w.closeFile()
+	}
+	return nil
+}
+
+// openFile starts streaming the next numbered file to storage
+func (w *exportWriter) openFile() error {
+	name := fmt.Sprintf("%s-%04d.%s", w.prefix, len(w.files)+1, w.format)
+	if w.recipient != nil {
+		name += w.recipient.Extension()
+	}
+
+	reader, pipe := io.Pipe()
+	hash := sha256.New()
+	file := &openFile{
+		name: name,
+		pipe: pipe,
+		sum:  func() []byte { return hash.Sum(nil) },
+		done: make(chan putResult, 1),
+	}
+
+	go func() {
+		n, err := w.put(name, io.TeeReader(reader, hash))
+		reader.CloseWithError(err)
+		file.done <- putResult{n: n, err: err}
+	}()
+
+	var out io.Writer = pipe
+	if w.recipient != nil {
+		sink, err := w.recipient.Encrypt(pipe)
+		if err != nil {
+			pipe.CloseWithError(err)
+			<-file.done
+			return err
+		}
+		file.sink = sink
+		out = sink
+	}
+	if w.format == "csv" {
+		file.encoder = newCSVEncoder(out, w.columns)
+	} else {
+		file.encoder = json.NewEncoder(out)
+	}
+
+	w.current = file
+	return nil
+}
+
+// closeFile flushes the current file and records its checksum
+func (w *exportWriter) closeFile() error {
+	file := w.current
+	w.current = nil
+
+	if flusher, ok := file.encoder.(interface{ Flush() error }); ok {
+		if err := flusher.Flush(); err != nil {
+			file.pipe.CloseWithError(err)
+			<-file.done
+			return err
+		}
+	}
+	if file.sink != nil {
+		if err := file.sink.Close(); err != nil {
+			file.pipe.CloseWithError(err)
+			<-file.done
+			return err
+		}
+	}
+	file.pipe.Close()
+
+	result := <-file.done
+	if result.err != nil {
+		return fmt.Errorf("failed to store %s: %w", file.name, result.err)
+	}
+
+	w.files = append(w.files, models.ExportFile{
+		Name:    file.name,
+		Records: file.records,
+		Bytes:   result.n,
+		SHA256:  hex.EncodeToString(file.sum()),
+	})
+	return nil
+}
+
+// abort stops a file that is still being written, e.g. after a query error
+func (w *exportWriter) abort() {
+	if w.current != nil {
+		w.current.pipe.CloseWithError(fmt.Errorf("export aborted"))
+		<-w.current.done
+		w.current = nil
+	}
+}
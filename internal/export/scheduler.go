@@ -0,0 +1,307 @@
+package export
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/deid"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/encryption"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/notify"
+	"github.com/hillmatthew2000/HealthHub/pkg/transfer"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Scheduler runs export schedules when they are due and pushes the files to each
+// schedule's SFTP or S3 destination
+type Scheduler struct {
+	db          *gorm.DB
+	deid        *deid.Deidentifier
+	credentials *encryption.Encryptor
+	alerts      notify.Sender
+	interval    time.Duration
+	wake        chan struct{}
+}
+
+// NewScheduler creates a new export scheduler. Destination credentials are decrypted
+// with credentials; failed runs are reported through alerts.
+func NewScheduler(db *gorm.DB, deidentifier *deid.Deidentifier, credentials *encryption.Encryptor, alerts notify.Sender) *Scheduler {
+	return &Scheduler{
+		db:          db,
+		deid:        deidentifier,
+		credentials: credentials,
+		alerts:      alerts,
+		interval:    time.Minute,
+		wake:        make(chan struct{}, 1),
+	}
+}
+
+// Wake signals the scheduler that a schedule may have become due
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run executes due schedules until the context is cancelled. Runs interrupted by a
+// restart are marked failed; their update window is exported again by the next run,
+// since the watermark only advances on success.
+func (s *Scheduler) Run(ctx context.Context) {
+	now := time.Now()
+	if err := s.db.Model(&models.ExportScheduleRun{}).Where("status = ?", models.ExportRunRunning).
+		Updates(map[string]interface{}{
+			"status":       models.ExportRunFailed,
+			"error":        "interrupted by restart",
+			"completed_at": now,
+		}).Error; err != nil {
+		logger.Warn("Failed to close interrupted export runs", zap.Error(err))
+	}
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		for {
+			schedule, err := s.claim(time.Now())
+			if err != nil {
+				logger.Warn("Failed to fetch export schedules", zap.Error(err))
+				break
+			}
+			if schedule == nil {
+				break
+			}
+			s.execute(ctx, schedule)
+			if ctx.Err() != nil {
+				return
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-s.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// claim picks a due schedule and advances its next run time. The update is
+// conditional on the old run time, so two instances never run the same schedule.
+func (s *Scheduler) claim(now time.Time) (*models.ExportSchedule, error) {
+	var due []models.ExportSchedule
+	if err := s.db.Where("active = ? AND next_run_at <= ?", true, now).
+		Order("next_run_at ASC").Limit(10).Find(&due).Error; err != nil {
+		return nil, err
+	}
+
+	for i := range due {
+		schedule := &due[i]
+		next := schedule.NextRun(now)
+		result := s.db.Model(&models.ExportSchedule{}).
+			Where("id = ? AND next_run_at = ?", schedule.ID, *schedule.NextRunAt).
+			UpdateColumns(map[string]interface{}{"next_run_at": next, "last_run_at": now})
+		if result.Error != nil {
+			return nil, result.Error
+		}
+		if result.RowsAffected == 1 {
+			schedule.NextRunAt = &next
+			schedule.LastRunAt = &now
+			return schedule, nil
+		}
+	}
+
+	return nil, nil
+}
+
+// execute performs one run of a schedule and records its outcome
+func (s *Scheduler) execute(ctx context.Context, schedule *models.ExportSchedule) {
+	until := time.Now().UTC()
+	run := models.ExportScheduleRun{
+		ScheduleID: schedule.ID,
+		Status:     models.ExportRunRunning,
+		StartedAt:  until,
+	}
+	if schedule.Incremental {
+		run.UpdatedAfter = schedule.Watermark
+		run.UpdatedUntil = &until
+	}
+	if err := s.db.Create(&run).Error; err != nil {
+		logger.Error("Failed to record export run", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		return
+	}
+
+	err := s.push(ctx, schedule, &run)
+
+	completed := time.Now()
+	run.CompletedAt = &completed
+	if err != nil {
+		run.Status = models.ExportRunFailed
+		run.Error = err.Error()
+	} else {
+		run.Status = models.ExportRunCompleted
+	}
+	if err := s.db.Model(&run).Select("status", "location", "records", "files", "error", "completed_at").
+		Updates(&run).Error; err != nil {
+		logger.Error("Failed to record export run", zap.String("run_id", run.ID), zap.Error(err))
+	}
+
+	if err != nil {
+		schedule.ConsecutiveFailures++
+		if err := s.db.Model(&models.ExportSchedule{}).Where("id = ?", schedule.ID).
+			UpdateColumn("consecutive_failures", gorm.Expr("consecutive_failures + 1")).Error; err != nil {
+			logger.Error("Failed to record export schedule failure", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		}
+		s.alert(ctx, schedule, &run)
+		return
+	}
+
+	updates := map[string]interface{}{"consecutive_failures": 0, "last_success_at": completed}
+	if schedule.Incremental {
+		updates["watermark"] = until
+	}
+	if err := s.db.Model(&models.ExportSchedule{}).Where("id = ?", schedule.ID).UpdateColumns(updates).Error; err != nil {
+		logger.Error("Failed to record export schedule success", zap.String("schedule_id", schedule.ID), zap.Error(err))
+	}
+
+	logger.LogAuditEvent("export_scheduled", schedule.ResourceType, schedule.CreatedBy, map[string]interface{}{
+		"schedule_id":  schedule.ID,
+		"run_id":       run.ID,
+		"destination":  schedule.Destination.Type,
+		"location":     run.Location,
+		"records":      run.Records,
+		"files":        len(run.Files),
+		"deidentified": schedule.Deidentified,
+		"encrypted":    schedule.EncryptionFormat != "",
+	})
+}
+
+// push writes the run's files and manifest to the schedule's destination
+func (s *Scheduler) push(ctx context.Context, schedule *models.ExportSchedule, run *models.ExportScheduleRun) error {
+	secret, err := s.credentials.Decrypt(schedule.Credential)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt destination credential: %w", err)
+	}
+
+	var recipient encryption.Recipient
+	if schedule.EncryptionFormat != "" {
+		if recipient, err = encryption.ParseRecipient(schedule.EncryptionFormat, schedule.RecipientKey); err != nil {
+			return err
+		}
+	}
+
+	dest, err := transfer.Open(destinationOptions(schedule.Destination, secret))
+	if err != nil {
+		return err
+	}
+	defer dest.Close()
+
+	dir := schedule.Name + "/" + run.StartedAt.Format("20060102T150405Z")
+	run.Location = location(schedule.Destination, dir)
+	put := func(name string, r io.Reader) (int64, error) {
+		return dest.Put(dir+"/"+name, r)
+	}
+
+	w := &exportWriter{
+		put:       put,
+		prefix:    fileStem(schedule.ResourceType, schedule.Deidentified),
+		format:    schedule.Format,
+		columns:   csvColumns(schedule.ResourceType, schedule.Deidentified),
+		recipient: recipient,
+	}
+	defer w.abort()
+
+	sel := selection{
+		resourceType: schedule.ResourceType,
+		deidentified: schedule.Deidentified,
+		activeOnly:   schedule.ActiveOnly,
+		filter:       schedule.Filter,
+		updatedAfter: run.UpdatedAfter,
+		updatedUntil: run.UpdatedUntil,
+	}
+	if err := sel.write(ctx, s.db, s.deid, w); err != nil {
+		return err
+	}
+	run.Records = w.total
+	run.Files = w.files
+
+	manifest := models.ExportManifest{
+		ScheduleID:   schedule.ID,
+		RunID:        run.ID,
+		ResourceType: schedule.ResourceType,
+		Deidentified: schedule.Deidentified,
+		ActiveOnly:   schedule.ActiveOnly,
+		Filter:       schedule.Filter,
+		UpdatedAfter: run.UpdatedAfter,
+		UpdatedUntil: run.UpdatedUntil,
+		Format:       schedule.Format,
+		Records:      w.total,
+		Files:        w.files,
+		Corrected:    w.corrected,
+		CreatedBy:    schedule.CreatedBy,
+		GeneratedAt:  time.Now().UTC(),
+	}
+	if recipient != nil {
+		manifest.Encryption = &models.ExportEncryption{Format: schedule.EncryptionFormat, Fingerprint: recipient.Fingerprint()}
+	}
+	return writeManifest(put, manifest)
+}
+
+// alert logs a failed run and emails the schedule's alert recipients
+func (s *Scheduler) alert(ctx context.Context, schedule *models.ExportSchedule, run *models.ExportScheduleRun) {
+	logger.Error("Scheduled export failed",
+		zap.String("schedule_id", schedule.ID),
+		zap.String("run_id", run.ID),
+		zap.Int("consecutive_failures", schedule.ConsecutiveFailures),
+		zap.String("error", run.Error),
+	)
+
+	body := fmt.Sprintf("The scheduled export %q failed at %s.\n\nError: %s\nRun: %s\nConsecutive failures: %d\n",
+		schedule.Name, run.CompletedAt.UTC().Format(time.RFC3339), run.Error, run.ID, schedule.ConsecutiveFailures)
+	if schedule.Incremental {
+		body += "\nThe records of this run will be included in the next successful run.\n"
+	}
+
+	for _, to := range schedule.AlertEmails {
+		if err := s.alerts.Send(ctx, notify.Message{
+			To:      to,
+			Subject: fmt.Sprintf("Scheduled export %q failed", schedule.Name),
+			Body:    body,
+		}); err != nil {
+			logger.Warn("Failed to send export failure alert", zap.String("schedule_id", schedule.ID), zap.Error(err))
+		}
+	}
+}
+
+// destinationOptions converts a stored destination and its decrypted credential
+// into transfer options
+func destinationOptions(dest models.ExportDestination, secret string) transfer.Options {
+	return transfer.Options{
+		Type:        dest.Type,
+		Host:        dest.Host,
+		Port:        dest.Port,
+		Username:    dest.Username,
+		HostKey:     dest.HostKey,
+		Path:        dest.Path,
+		Bucket:      dest.Bucket,
+		Region:      dest.Region,
+		Endpoint:    dest.Endpoint,
+		Prefix:      dest.Prefix,
+		AccessKeyID: dest.AccessKeyID,
+		Secret:      secret,
+	}
+}
+
+// location describes where a run's directory was written, for the run history
+func location(dest models.ExportDestination, dir string) string {
+	if dest.Type == "s3" {
+		return "s3://" + path.Join(dest.Bucket, strings.Trim(dest.Prefix, "/"), dir)
+	}
+	return "sftp://" + dest.Host + path.Join("/", dest.Path, dir)
+}
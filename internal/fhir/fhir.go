@@ -0,0 +1,73 @@
+package fhir
+
+import (
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// MediaType is the FHIR JSON media type
+const MediaType = "application/fhir+json"
+
+// Extension URLs for patient demographics
+const (
+	GenderIdentityExtension = "http://hl7.org/fhir/StructureDefinition/individual-genderIdentity"
+	PronounsExtension       = "http://hl7.org/fhir/StructureDefinition/individual-pronouns"
+	BirthSexExtension       = "http://hl7.org/fhir/us/core/StructureDefinition/us-core-birthsex"
+	GeolocationExtension    = "http://hl7.org/fhir/StructureDefinition/geolocation"
+	InterpreterExtension    = "http://hl7.org/fhir/StructureDefinition/patient-interpreterRequired"
+	RaceExtension           = "http://hl7.org/fhir/us/core/StructureDefinition/us-core-race"
+	EthnicityExtension      = "http://hl7.org/fhir/us/core/StructureDefinition/us-core-ethnicity"
+)
+
+// Extension represents a FHIR extension. Only the value types used by the API are modelled.
+type Extension struct {
+	URL                  string                  `json:"url"`
+	ValueCode            string                  `json:"valueCode,omitempty"`
+	ValueString          string                  `json:"valueString,omitempty"`
+	ValueBoolean         *bool                   `json:"valueBoolean,omitempty"`
+	ValueDecimal         *float64                `json:"valueDecimal,omitempty"`
+	ValueCodeableConcept *models.CodeableConcept `json:"valueCodeableConcept,omitempty"`
+	ValueCoding          *models.Coding          `json:"valueCoding,omitempty"`
+	Extension            []Extension             `json:"extension,omitempty"`
+}
+
+// Meta represents resource metadata
+type Meta struct {
+	LastUpdated time.Time `json:"lastUpdated"`
+	Profile     []string  `json:"profile,omitempty"`
+}
+
+// Bundle represents a FHIR searchset bundle
+type Bundle struct {
+	ResourceType string        `json:"resourceType"`
+	Type         string        `json:"type"`
+	Total        int64         `json:"total"`
+	Link         []BundleLink  `json:"link,omitempty"`
+	Entry        []BundleEntry `json:"entry"`
+}
+
+// BundleLink represents a link to a page of results
+type BundleLink struct {
+	Relation string `json:"relation"`
+	URL      string `json:"url"`
+}
+
+// BundleEntry represents a single resource in a bundle
+type BundleEntry struct {
+	FullURL  string      `json:"fullUrl,omitempty"`
+	Resource interface{} `json:"resource"`
+}
+
+// NewSearchBundle wraps resources in a searchset bundle
+func NewSearchBundle(total int64, entries []BundleEntry) *Bundle {
+	if entries == nil {
+		entries = []BundleEntry{}
+	}
+	return &Bundle{
+		ResourceType: "Bundle",
+		Type:         "searchset",
+		Total:        total,
+		Entry:        entries,
+	}
+}
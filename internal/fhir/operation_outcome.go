@@ -0,0 +1,58 @@
+package fhir
+
+import "github.com/hillmatthew2000/HealthHub/internal/models"
+
+// Issue severities
+const (
+	SeverityFatal   = "fatal"
+	SeverityError   = "error"
+	SeverityWarning = "warning"
+)
+
+// Issue types used by the API, from http://hl7.org/fhir/issue-type
+const (
+	IssueInvalid      = "invalid"
+	IssueStructure    = "structure"
+	IssueRequired     = "required"
+	IssueValue        = "value"
+	IssueInvariant    = "invariant"
+	IssueLogin        = "login"
+	IssueForbidden    = "forbidden"
+	IssueProcessing   = "processing"
+	IssueNotSupported = "not-supported"
+	IssueCodeInvalid  = "code-invalid"
+	IssueNotFound     = "not-found"
+	IssueDeleted      = "deleted"
+	IssueTooCostly    = "too-costly"
+	IssueConflict     = "conflict"
+	IssueTransient    = "transient"
+	IssueThrottled    = "throttled"
+	IssueException    = "exception"
+)
+
+// OperationOutcome is a FHIR OperationOutcome, the outcome of a failed request
+type OperationOutcome struct {
+	ResourceType string  `json:"resourceType"`
+	Issue        []Issue `json:"issue"`
+}
+
+// Issue is an issue of an OperationOutcome. Expression holds FHIRPath expressions of
+// the elements at fault, such as Patient.identifier[0].system.
+type Issue struct {
+	Severity    string                  `json:"severity"`
+	Code        string                  `json:"code"`
+	Details     *models.CodeableConcept `json:"details,omitempty"` // The API's error code and message
+	Diagnostics string                  `json:"diagnostics,omitempty"`
+	Expression  []string                `json:"expression,omitempty"`
+}
+
+// NewOperationOutcome wraps issues in an OperationOutcome
+func NewOperationOutcome(issues ...Issue) *OperationOutcome {
+	if issues == nil {
+		issues = []Issue{}
+	}
+	return &OperationOutcome{
+		ResourceType: "OperationOutcome",
+		Issue:        issues,
+	}
+}
@@ -0,0 +1,158 @@
+package fhir
+
+import "github.com/hillmatthew2000/HealthHub/internal/models"
+
+// Patient is the FHIR R4 JSON representation of a patient
+type Patient struct {
+	ResourceType  string                        `json:"resourceType"`
+	ID            string                        `json:"id"`
+	Meta          *Meta                         `json:"meta,omitempty"`
+	Extension     []Extension                   `json:"extension,omitempty"`
+	Identifier    []models.Identifier           `json:"identifier,omitempty"`
+	Active        bool                          `json:"active"`
+	Name          []models.Name                 `json:"name,omitempty"`
+	Telecom       []ContactPoint                `json:"telecom,omitempty"`
+	Gender        string                        `json:"gender,omitempty"`
+	BirthDate     string                        `json:"birthDate,omitempty"`
+	Address       []Address                     `json:"address,omitempty"`
+	Communication []models.PatientCommunication `json:"communication,omitempty"`
+	Link          []PatientLink                 `json:"link,omitempty"`
+}
+
+// ContactPoint is a FHIR ContactPoint (telecom entry)
+type ContactPoint struct {
+	System string `json:"system,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Use    string `json:"use,omitempty"`
+	Rank   int    `json:"rank,omitempty"`
+}
+
+// Address is a FHIR Address, with coordinates carried in the geolocation extension
+type Address struct {
+	Extension  []Extension    `json:"extension,omitempty"`
+	Use        string         `json:"use,omitempty"`
+	Type       string         `json:"type,omitempty"`
+	Text       string         `json:"text,omitempty"`
+	Line       []string       `json:"line,omitempty"`
+	City       string         `json:"city,omitempty"`
+	District   string         `json:"district,omitempty"`
+	State      string         `json:"state,omitempty"`
+	PostalCode string         `json:"postalCode,omitempty"`
+	Country    string         `json:"country,omitempty"`
+	Period     *models.Period `json:"period,omitempty"`
+}
+
+// PatientLink is a FHIR Patient.link entry
+type PatientLink struct {
+	Other models.Reference `json:"other"`
+	Type  string           `json:"type"`
+}
+
+// FromPatient converts a patient to its FHIR representation
+func FromPatient(p *models.Patient) *Patient {
+	resource := &Patient{
+		ResourceType:  "Patient",
+		ID:            p.ID,
+		Meta:          &Meta{LastUpdated: p.UpdatedAt},
+		Identifier:    p.Identifier,
+		Active:        p.Active,
+		Name:          p.Name,
+		Gender:        p.Gender,
+		Communication: p.Communication,
+	}
+
+	if !p.BirthDate.IsZero() {
+		resource.BirthDate = p.BirthDate.Format("2006-01-02")
+	}
+
+	if p.GenderIdentity != nil {
+		resource.Extension = append(resource.Extension, Extension{
+			URL:                  GenderIdentityExtension,
+			ValueCodeableConcept: p.GenderIdentity,
+		})
+	}
+	for i := range p.Pronouns {
+		resource.Extension = append(resource.Extension, Extension{
+			URL:                  PronounsExtension,
+			ValueCodeableConcept: &p.Pronouns[i],
+		})
+	}
+	if p.SexAssignedAtBirth != "" {
+		resource.Extension = append(resource.Extension, Extension{
+			URL:       BirthSexExtension,
+			ValueCode: p.SexAssignedAtBirth,
+		})
+	}
+
+	if p.Race != nil {
+		resource.Extension = append(resource.Extension, raceEthnicityExtension(RaceExtension, p.Race))
+	}
+	if p.Ethnicity != nil {
+		resource.Extension = append(resource.Extension, raceEthnicityExtension(EthnicityExtension, p.Ethnicity))
+	}
+
+	if p.InterpreterRequired {
+		required := true
+		resource.Extension = append(resource.Extension, Extension{
+			URL:          InterpreterExtension,
+			ValueBoolean: &required,
+		})
+	}
+
+	for _, contact := range p.Telecom {
+		resource.Telecom = append(resource.Telecom, ContactPoint{
+			System: contact.System,
+			Value:  contact.Value,
+			Use:    contact.Use,
+			Rank:   contact.Rank,
+		})
+	}
+
+	for _, address := range p.Address {
+		fhirAddress := Address{
+			Use:        address.Use,
+			Type:       address.Type,
+			Text:       address.Text,
+			Line:       address.Line,
+			City:       address.City,
+			District:   address.District,
+			State:      address.State,
+			PostalCode: address.PostalCode,
+			Country:    address.Country,
+			Period:     address.Period,
+		}
+		if address.Geolocation != nil {
+			lat, lon := address.Geolocation.Latitude, address.Geolocation.Longitude
+			fhirAddress.Extension = []Extension{{
+				URL: GeolocationExtension,
+				Extension: []Extension{
+					{URL: "latitude", ValueDecimal: &lat},
+					{URL: "longitude", ValueDecimal: &lon},
+				},
+			}}
+		}
+		resource.Address = append(resource.Address, fhirAddress)
+	}
+
+	for _, link := range p.Link {
+		resource.Link = append(resource.Link, PatientLink{
+			Other: models.Reference{Reference: "Patient/" + link.OtherID},
+			Type:  link.Type,
+		})
+	}
+
+	return resource
+}
+
+// raceEthnicityExtension builds a US Core race or ethnicity complex extension
+func raceEthnicityExtension(url string, value *models.RaceEthnicity) Extension {
+	extension := Extension{URL: url}
+	for i := range value.OMBCategory {
+		extension.Extension = append(extension.Extension, Extension{URL: "ombCategory", ValueCoding: &value.OMBCategory[i]})
+	}
+	for i := range value.Detailed {
+		extension.Extension = append(extension.Extension, Extension{URL: "detailed", ValueCoding: &value.Detailed[i]})
+	}
+	extension.Extension = append(extension.Extension, Extension{URL: "text", ValueString: value.Text})
+	return extension
+}
@@ -0,0 +1,447 @@
+package fhir
+
+import (
+	"embed"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// bundled holds the StructureDefinitions resources are validated against and the
+// ValueSets their required bindings use
+//
+//go:embed profiles/*.json
+var bundled embed.FS
+
+// structureDefinition is the part of a StructureDefinition the validator reads
+type structureDefinition struct {
+	ResourceType string `json:"resourceType"`
+	URL          string `json:"url"`
+	Version      string `json:"version"`
+	Type         string `json:"type"`
+	Differential struct {
+		Element []elementDefinition `json:"element"`
+	} `json:"differential"`
+}
+
+// elementDefinition constrains the elements at a path of a resource
+type elementDefinition struct {
+	Path      string `json:"path"`
+	SliceName string `json:"sliceName,omitempty"`
+	Min       int    `json:"min,omitempty"`
+	Max       string `json:"max,omitempty"`
+	Binding   *struct {
+		Strength string `json:"strength"`
+		ValueSet string `json:"valueSet"`
+	} `json:"binding,omitempty"`
+	Type []struct {
+		Code          string   `json:"code"`
+		TargetProfile []string `json:"targetProfile,omitempty"`
+	} `json:"type,omitempty"`
+	PatternCodeableConcept *models.CodeableConcept `json:"patternCodeableConcept,omitempty"`
+	Constraint             []struct {
+		Key      string `json:"key"`
+		Severity string `json:"severity"`
+		Human    string `json:"human"`
+	} `json:"constraint,omitempty"`
+}
+
+// valueSet is the part of a ValueSet the validator reads; bundled value sets list
+// their codes
+type valueSet struct {
+	URL     string `json:"url"`
+	Version string `json:"version"`
+	Compose struct {
+		Include []struct {
+			System  string `json:"system"`
+			Concept []struct {
+				Code string `json:"code"`
+			} `json:"concept"`
+		} `json:"include"`
+	} `json:"compose"`
+}
+
+// invariants implement the FHIRPath constraints of the bundled profiles, which are
+// not evaluated generically. Each reports whether the element satisfies it.
+var invariants = map[string]func(element map[string]interface{}) bool{
+	"us-core-6": func(e map[string]interface{}) bool {
+		return present(e["family"]) || present(e["given"])
+	},
+	"us-core-2": func(e map[string]interface{}) bool {
+		return present(e["component"]) || present(e["hasMember"]) || hasChoice(e, "value") || present(e["dataAbsentReason"])
+	},
+	"obs-6": func(e map[string]interface{}) bool {
+		return !present(e["dataAbsentReason"]) || !hasChoice(e, "value")
+	},
+}
+
+// Validator validates resources against the bundled US Core profiles
+type Validator struct {
+	profiles  map[string]*structureDefinition // By resource type
+	types     map[string]string               // Profile URL → resource type
+	valueSets map[string]*codes               // By URL
+}
+
+// codes are the codes of a value set
+type codes struct {
+	canonical string
+	system    map[string]bool // "system|code"
+	code      map[string]bool // Code alone, for code elements that carry no system
+}
+
+// NewValidator creates a new validator from the bundled profiles
+func NewValidator() (*Validator, error) {
+	v := &Validator{
+		profiles:  make(map[string]*structureDefinition),
+		types:     make(map[string]string),
+		valueSets: make(map[string]*codes),
+	}
+
+	entries, err := bundled.ReadDir("profiles")
+	if err != nil {
+		return nil, fmt.Errorf("failed to read bundled profiles: %w", err)
+	}
+	for _, entry := range entries {
+		data, err := bundled.ReadFile("profiles/" + entry.Name())
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s: %w", entry.Name(), err)
+		}
+		var header struct {
+			ResourceType string `json:"resourceType"`
+		}
+		if err := json.Unmarshal(data, &header); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", entry.Name(), err)
+		}
+
+		switch header.ResourceType {
+		case "StructureDefinition":
+			var sd structureDefinition
+			if err := json.Unmarshal(data, &sd); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", entry.Name(), err)
+			}
+			v.profiles[sd.Type] = &sd
+			v.types[sd.URL] = sd.Type
+		case "ValueSet":
+			var vs valueSet
+			if err := json.Unmarshal(data, &vs); err != nil {
+				return nil, fmt.Errorf("invalid %s: %w", entry.Name(), err)
+			}
+			set := &codes{canonical: vs.URL + "|" + vs.Version, system: make(map[string]bool), code: make(map[string]bool)}
+			for _, include := range vs.Compose.Include {
+				for _, concept := range include.Concept {
+					set.system[include.System+"|"+concept.Code] = true
+					set.code[concept.Code] = true
+				}
+			}
+			v.valueSets[vs.URL] = set
+		default:
+			return nil, fmt.Errorf("unexpected %s in %s", header.ResourceType, entry.Name())
+		}
+	}
+
+	// Every rule must be enforceable, or resources would pass a profile they violate
+	for _, sd := range v.profiles {
+		for _, element := range sd.Differential.Element {
+			if element.Binding != nil && element.Binding.Strength == "required" && v.valueSets[element.Binding.ValueSet] == nil {
+				return nil, fmt.Errorf("%s: value set %s is not bundled", element.Path, element.Binding.ValueSet)
+			}
+			for _, constraint := range element.Constraint {
+				if invariants[constraint.Key] == nil {
+					return nil, fmt.Errorf("%s: constraint %s is not implemented", element.Path, constraint.Key)
+				}
+			}
+		}
+	}
+	return v, nil
+}
+
+// Profile returns the canonical URL, with its version, of the profile resources of
+// a type are validated against, or an empty string when none is bundled
+func (v *Validator) Profile(resourceType string) string {
+	if sd := v.profiles[resourceType]; sd != nil {
+		return sd.URL + "|" + sd.Version
+	}
+	return ""
+}
+
+// node is an element of a resource with the FHIRPath expression locating it
+type node struct {
+	value interface{}
+	expr  string
+}
+
+// Validate validates a resource against the profile of its type and returns the
+// issues found. It returns none when the resource conforms or no profile is bundled
+// for the type.
+func (v *Validator) Validate(resourceType string, resource interface{}) ([]Issue, error) {
+	sd := v.profiles[resourceType]
+	if sd == nil {
+		return nil, nil
+	}
+
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resource: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode resource: %w", err)
+	}
+
+	profile := sd.URL + "|" + sd.Version
+	var issues []Issue
+	for _, element := range sd.Differential.Element {
+		path := strings.Split(element.Path, ".")
+		if path[0] != resourceType {
+			continue
+		}
+
+		// Elements are checked below each of their parents
+		parents := []node{{value: doc, expr: resourceType}}
+		var found []node
+		if len(path) == 1 {
+			found = parents
+		} else {
+			for _, name := range path[1 : len(path)-1] {
+				parents = children(parents, name)
+			}
+			name := path[len(path)-1]
+			for _, parent := range parents {
+				matched := children([]node{parent}, name)
+				if element.PatternCodeableConcept != nil {
+					matched = matching(matched, element.PatternCodeableConcept)
+				}
+				issues = append(issues, cardinality(element, parent.expr+"."+name, len(matched), profile)...)
+				found = append(found, matched...)
+			}
+		}
+
+		for _, n := range found {
+			issues = append(issues, v.checkValue(element, n)...)
+		}
+	}
+	return issues, nil
+}
+
+// cardinality checks the number of values of an element below one parent
+func cardinality(element elementDefinition, expr string, count int, profile string) []Issue {
+	label := element.Path
+	if element.SliceName != "" {
+		label += ":" + element.SliceName
+	}
+	var issues []Issue
+	if count < element.Min {
+		diagnostics := fmt.Sprintf("%s: minimum required = %d, but only found %d (from %s)", label, element.Min, count, profile)
+		if element.SliceName != "" {
+			diagnostics = fmt.Sprintf("%s: a matching slice is required, but not found (from %s)", label, profile)
+		}
+		issues = append(issues, Issue{
+			Severity:    SeverityError,
+			Code:        IssueRequired,
+			Diagnostics: diagnostics,
+			Expression:  []string{expr},
+		})
+	}
+	if max, err := strconv.Atoi(element.Max); err == nil && count > max {
+		issues = append(issues, Issue{
+			Severity:    SeverityError,
+			Code:        IssueStructure,
+			Diagnostics: fmt.Sprintf("%s: max allowed = %d, but found %d (from %s)", label, max, count, profile),
+			Expression:  []string{expr},
+		})
+	}
+	return issues
+}
+
+// checkValue checks the binding, reference targets and constraints of a value
+func (v *Validator) checkValue(element elementDefinition, n node) []Issue {
+	var issues []Issue
+
+	if element.Binding != nil && element.Binding.Strength == "required" {
+		set := v.valueSets[element.Binding.ValueSet]
+		if value, ok := set.contains(n.value); !ok {
+			issues = append(issues, Issue{
+				Severity:    SeverityError,
+				Code:        IssueCodeInvalid,
+				Diagnostics: fmt.Sprintf("The value '%s' is not in the required value set %s", value, set.canonical),
+				Expression:  []string{n.expr},
+			})
+		}
+	}
+
+	for _, t := range element.Type {
+		if t.Code != "Reference" || len(t.TargetProfile) == 0 {
+			continue
+		}
+		fields, _ := n.value.(map[string]interface{})
+		reference, _ := fields["reference"].(string)
+		slash := strings.LastIndex(reference, "/")
+		if slash < 0 {
+			continue // Identifier-only and contained references name no type
+		}
+		target := reference[:slash]
+		if i := strings.LastIndex(target, "/"); i >= 0 {
+			target = target[i+1:]
+		}
+		allowed := make([]string, 0, len(t.TargetProfile))
+		valid := false
+		for _, url := range t.TargetProfile {
+			if resourceType, ok := v.types[url]; ok {
+				allowed = append(allowed, resourceType)
+				valid = valid || resourceType == target
+			}
+		}
+		if !valid && len(allowed) > 0 {
+			issues = append(issues, Issue{
+				Severity:    SeverityError,
+				Code:        IssueValue,
+				Diagnostics: fmt.Sprintf("The reference %s is not to a valid target; expected %s", reference, strings.Join(allowed, " or ")),
+				Expression:  []string{n.expr + ".reference"},
+			})
+		}
+	}
+
+	for _, constraint := range element.Constraint {
+		fields, ok := n.value.(map[string]interface{})
+		if !ok || invariants[constraint.Key](fields) {
+			continue
+		}
+		severity := constraint.Severity
+		if severity == "" {
+			severity = SeverityError
+		}
+		issues = append(issues, Issue{
+			Severity:    severity,
+			Code:        IssueInvariant,
+			Diagnostics: fmt.Sprintf("Constraint failed: %s: '%s'", constraint.Key, constraint.Human),
+			Expression:  []string{n.expr},
+		})
+	}
+	return issues
+}
+
+// contains reports whether a code, Coding or CodeableConcept is in the value set,
+// with the value as written for the issue
+func (c *codes) contains(value interface{}) (string, bool) {
+	switch v := value.(type) {
+	case string:
+		return v, c.code[v]
+	case map[string]interface{}:
+		if coding, ok := v["coding"].([]interface{}); ok {
+			var written []string
+			for _, item := range coding {
+				value, ok := c.contains(item)
+				if ok {
+					return value, true
+				}
+				written = append(written, value)
+			}
+			return strings.Join(written, ", "), false
+		}
+		system, _ := v["system"].(string)
+		code, _ := v["code"].(string)
+		return system + "#" + code, c.system[system+"|"+code]
+	}
+	return fmt.Sprint(value), false
+}
+
+// children returns the present values of an element below the nodes. A name ending
+// in [x] matches every type of a choice element, such as valueQuantity.
+func children(parents []node, name string) []node {
+	var found []node
+	for _, parent := range parents {
+		fields, ok := parent.value.(map[string]interface{})
+		if !ok {
+			continue
+		}
+		for _, key := range keys(fields, name) {
+			switch value := fields[key].(type) {
+			case []interface{}:
+				for i, item := range value {
+					if present(item) {
+						found = append(found, node{value: item, expr: fmt.Sprintf("%s.%s[%d]", parent.expr, key, i)})
+					}
+				}
+			default:
+				if present(value) {
+					found = append(found, node{value: value, expr: parent.expr + "." + key})
+				}
+			}
+		}
+	}
+	return found
+}
+
+// keys returns the keys of an object an element name matches
+func keys(fields map[string]interface{}, name string) []string {
+	base := strings.TrimSuffix(name, "[x]")
+	if base == name {
+		return []string{name}
+	}
+	var matched []string
+	for key := range fields {
+		if isChoice(key, base) {
+			matched = append(matched, key)
+		}
+	}
+	return matched
+}
+
+// matching returns the CodeableConcepts that carry every coding of a pattern
+func matching(nodes []node, pattern *models.CodeableConcept) []node {
+	var matched []node
+	for _, n := range nodes {
+		fields, _ := n.value.(map[string]interface{})
+		coding, _ := fields["coding"].([]interface{})
+		all := true
+		for _, want := range pattern.Coding {
+			found := false
+			for _, item := range coding {
+				c, _ := item.(map[string]interface{})
+				if c["system"] == want.System && c["code"] == want.Code {
+					found = true
+					break
+				}
+			}
+			all = all && found
+		}
+		if all {
+			matched = append(matched, n)
+		}
+	}
+	return matched
+}
+
+// hasChoice reports whether an object has a value for a choice element
+func hasChoice(fields map[string]interface{}, base string) bool {
+	for key, value := range fields {
+		if isChoice(key, base) && present(value) {
+			return true
+		}
+	}
+	return false
+}
+
+// isChoice reports whether a key is a typed name of a choice element, such as
+// valueQuantity for value[x]
+func isChoice(key, base string) bool {
+	return len(key) > len(base) && strings.HasPrefix(key, base) && key[len(base)] >= 'A' && key[len(base)] <= 'Z'
+}
+
+// present reports whether a decoded JSON value carries data
+func present(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return strings.TrimSpace(v) != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
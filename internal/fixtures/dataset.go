@@ -0,0 +1,166 @@
+package fixtures
+
+import (
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// Code systems used by the dataset
+const (
+	loinc            = "http://loinc.org"
+	snomed           = "http://snomed.info/sct"
+	ucum             = "http://unitsofmeasure.org"
+	categorySystem   = "http://terminology.hl7.org/CodeSystem/observation-category"
+	interpretationV3 = "http://terminology.hl7.org/CodeSystem/v3-ObservationInterpretation"
+)
+
+// datasetTime is the base of every date in the dataset, so resets load identical
+// resources
+var datasetTime = time.Date(2024, time.March, 4, 9, 0, 0, 0, time.UTC)
+
+// dataset returns the resources a reset loads for the tenant: three patients with
+// laboratory and vital sign observations, problems, allergies and a specimen.
+// Patients come first, as the other resources reference them by their derived IDs.
+func dataset(tenant string) []entity {
+	patient := func(key string) models.Reference {
+		return models.Reference{Reference: "Patient/" + ID(tenant, "Patient", key)}
+	}
+	at := func(days int) *time.Time {
+		t := datasetTime.AddDate(0, 0, days)
+		return &t
+	}
+	concept := func(system, code, display string) models.CodeableConcept {
+		return models.CodeableConcept{Coding: []models.Coding{{System: system, Code: code, Display: display}}, Text: display}
+	}
+	category := func(code, display string) []models.Category {
+		return []models.Category{{Coding: []models.Coding{{System: categorySystem, Code: code, Display: display}}}}
+	}
+	high := []models.CodeableConcept{concept(interpretationV3, "H", "High")}
+
+	return []entity{
+		{"Patient", "alice", &models.Patient{
+			Active:     true,
+			Identifier: []models.Identifier{{Use: "usual", System: "urn:healthhub:fixtures:mrn", Value: "FX-0001"}},
+			Name:       []models.Name{{Use: "official", Family: "Fixture", Given: []string{"Alice"}}},
+			Gender:     "female",
+			BirthDate:  time.Date(1980, time.April, 12, 0, 0, 0, 0, time.UTC),
+			Telecom: []models.Contact{
+				{System: "phone", Value: "+1-555-0100", Use: "mobile"},
+				{System: "email", Value: "alice.fixture@example.com", Use: "home"},
+			},
+			Address: []models.Address{{Use: "home", Line: []string{"1 Fixture Way"}, City: "Springfield", State: "IL", PostalCode: "62701", Country: "US"}},
+		}},
+		{"Patient", "bob", &models.Patient{
+			Active:              true,
+			Identifier:          []models.Identifier{{Use: "usual", System: "urn:healthhub:fixtures:mrn", Value: "FX-0002"}},
+			Name:                []models.Name{{Use: "official", Family: "Fixture", Given: []string{"Bob"}}},
+			Gender:              "male",
+			BirthDate:           time.Date(1955, time.November, 3, 0, 0, 0, 0, time.UTC),
+			Telecom:             []models.Contact{{System: "phone", Value: "+1-555-0101", Use: "home"}},
+			Address:             []models.Address{{Use: "home", Line: []string{"2 Fixture Way"}, City: "Springfield", State: "IL", PostalCode: "62701", Country: "US"}},
+			Communication:       []models.PatientCommunication{{Language: concept("urn:ietf:bcp:47", "es", "Spanish"), Preferred: true}},
+			InterpreterRequired: true,
+		}},
+		{"Patient", "carol", &models.Patient{
+			Active:     true,
+			Identifier: []models.Identifier{{Use: "usual", System: "urn:healthhub:fixtures:mrn", Value: "FX-0003"}},
+			Name:       []models.Name{{Use: "official", Family: "Fixture", Given: []string{"Carol"}}},
+			Gender:     "female",
+			BirthDate:  time.Date(2012, time.July, 30, 0, 0, 0, 0, time.UTC),
+		}},
+
+		{"Observation", "alice-glucose", &models.Observation{
+			Status:            "final",
+			Category:          category("laboratory", "Laboratory"),
+			Code:              concept(loinc, "15074-8", "Glucose [Moles/volume] in Blood"),
+			Subject:           patient("alice"),
+			EffectiveDateTime: datasetTime,
+			Issued:            at(0),
+			ValueQuantity:     &models.Quantity{Value: 5.4, Unit: "mmol/L", System: ucum, Code: "mmol/L"},
+		}},
+		{"Observation", "alice-hba1c", &models.Observation{
+			Status:            "final",
+			Category:          category("laboratory", "Laboratory"),
+			Code:              concept(loinc, "4548-4", "Hemoglobin A1c/Hemoglobin.total in Blood"),
+			Subject:           patient("alice"),
+			EffectiveDateTime: datasetTime,
+			Issued:            at(1),
+			ValueQuantity:     &models.Quantity{Value: 6.1, Unit: "%", System: ucum, Code: "%"},
+			Interpretation:    high,
+		}},
+		{"Observation", "bob-potassium", &models.Observation{
+			Status:            "preliminary",
+			Category:          category("laboratory", "Laboratory"),
+			Code:              concept(loinc, "2823-3", "Potassium [Moles/volume] in Serum or Plasma"),
+			Subject:           patient("bob"),
+			EffectiveDateTime: datasetTime.AddDate(0, 0, 2),
+			ValueQuantity:     &models.Quantity{Value: 5.6, Unit: "mmol/L", System: ucum, Code: "mmol/L"},
+			Interpretation:    high,
+		}},
+		{"Observation", "bob-systolic", &models.Observation{
+			Status:            "final",
+			Category:          category("vital-signs", "Vital Signs"),
+			Code:              concept(loinc, "8480-6", "Systolic blood pressure"),
+			Subject:           patient("bob"),
+			EffectiveDateTime: datasetTime.AddDate(0, 0, 2),
+			Issued:            at(2),
+			ValueQuantity:     &models.Quantity{Value: 148, Unit: "mm[Hg]", System: ucum, Code: "mm[Hg]"},
+			Interpretation:    high,
+		}},
+		{"Observation", "carol-weight", &models.Observation{
+			Status:            "final",
+			Category:          category("vital-signs", "Vital Signs"),
+			Code:              concept(loinc, "29463-7", "Body weight"),
+			Subject:           patient("carol"),
+			EffectiveDateTime: datasetTime.AddDate(0, 0, 3),
+			Issued:            at(3),
+			ValueQuantity:     &models.Quantity{Value: 32, Unit: "kg", System: ucum, Code: "kg"},
+		}},
+
+		{"Condition", "alice-prediabetes", &models.Condition{
+			Subject:            patient("alice"),
+			Code:               concept(snomed, "714628002", "Prediabetes"),
+			ClinicalStatus:     models.ConditionActive,
+			VerificationStatus: models.ConditionConfirmed,
+			Category:           "problem-list-item",
+			OnsetAt:            at(1),
+		}},
+		{"Condition", "bob-hypertension", &models.Condition{
+			Subject:            patient("bob"),
+			Code:               concept(snomed, "38341003", "Hypertensive disorder"),
+			ClinicalStatus:     models.ConditionActive,
+			VerificationStatus: models.ConditionConfirmed,
+			Category:           "problem-list-item",
+			Severity:           "moderate",
+			OnsetAt:            at(-365),
+		}},
+
+		{"AllergyIntolerance", "bob-penicillin", &models.AllergyIntolerance{
+			Subject:            patient("bob"),
+			Code:               concept(snomed, "764146007", "Penicillin"),
+			ClinicalStatus:     models.AllergyActive,
+			VerificationStatus: "confirmed",
+			Category:           "medication",
+			Criticality:        "high",
+			Reaction:           "anaphylaxis",
+		}},
+		{"AllergyIntolerance", "carol-peanut", &models.AllergyIntolerance{
+			Subject:            patient("carol"),
+			Code:               concept(snomed, "256349002", "Peanut"),
+			ClinicalStatus:     models.AllergyActive,
+			VerificationStatus: "confirmed",
+			Category:           "food",
+			Criticality:        "high",
+			Reaction:           "urticaria",
+		}},
+
+		{"Specimen", "alice-blood", &models.Specimen{
+			Status:      models.SpecimenStatusAvailable,
+			Type:        &models.CodeableConcept{Coding: []models.Coding{{System: snomed, Code: "119297000", Display: "Blood specimen"}}},
+			Subject:     patient("alice"),
+			CollectedAt: at(0),
+			ReceivedAt:  datasetTime.Add(time.Hour),
+		}},
+	}
+}
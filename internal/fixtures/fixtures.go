@@ -0,0 +1,285 @@
+// Package fixtures resets tenants of a shared, non-production environment to a known
+// dataset and creates test entities, so partner teams can run repeatable contract
+// tests. An entity's ID is derived from its tenant, resource type and key, so tests
+// can refer to the same IDs across resets.
+package fixtures
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+)
+
+// namespace scopes the derived IDs to fixtures
+var namespace = uuid.NewSHA1(uuid.NameSpaceURL, []byte("https://github.com/hillmatthew2000/HealthHub/fixtures"))
+
+// ID returns the ID of a tenant's fixture entity
+func ID(tenant, resourceType, key string) string {
+	return uuid.NewSHA1(namespace, []byte(tenant+"\x00"+resourceType+"\x00"+key)).String()
+}
+
+// EntityError reports a fixture entity that cannot be created as requested
+type EntityError struct {
+	Reason string
+}
+
+func (e *EntityError) Error() string {
+	return "fixtures: " + e.Reason
+}
+
+// entity is a fixture resource before it is created
+type entity struct {
+	resourceType string
+	key          string
+	resource     interface{} // Pointer to the model
+}
+
+// ResetResult describes a tenant reset
+type ResetResult struct {
+	Tenant   string                 `json:"tenant"`
+	Removed  int64                  `json:"removed"` // Resources removed, including those created against fixture patients
+	Entities []models.FixtureEntity `json:"entities"`
+}
+
+// Loader resets tenants to the dataset and creates test entities
+type Loader struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewLoader creates a new fixture loader
+func NewLoader(db *gorm.DB) *Loader {
+	return &Loader{db: db, validator: validator.New()}
+}
+
+// Entities returns the tenant's fixture entities
+func (l *Loader) Entities(ctx context.Context, tenant string) ([]models.FixtureEntity, error) {
+	var entities []models.FixtureEntity
+	err := l.db.WithContext(ctx).Where("tenant = ?", tenant).Order("resource_type, entity_key").Find(&entities).Error
+	return entities, err
+}
+
+// Reset removes the tenant's fixture entities, along with every observation,
+// condition, allergy, specimen and link of its fixture patients, and loads the
+// dataset in one transaction
+func (l *Loader) Reset(ctx context.Context, tenant, userID string) (*ResetResult, error) {
+	result := &ResetResult{Tenant: tenant}
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		removed, err := clear(tx, tenant)
+		if err != nil {
+			return err
+		}
+		result.Removed = removed
+
+		for _, e := range dataset(tenant) {
+			created, err := l.create(tx, tenant, e, true, userID)
+			if err != nil {
+				return fmt.Errorf("failed to load %s %s: %w", e.resourceType, e.key, err)
+			}
+			result.Entities = append(result.Entities, *created)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// Create creates or replaces a tenant's test entity and returns it with the
+// created resource. Resources other than patients must belong to one of the
+// tenant's fixture patients, so a reset removes them.
+func (l *Loader) Create(ctx context.Context, tenant string, req models.FixtureEntityRequest, userID string) (*models.FixtureEntity, interface{}, error) {
+	resource := newResource(req.ResourceType)
+	if resource == nil {
+		return nil, nil, &EntityError{Reason: "unsupported resource type " + req.ResourceType}
+	}
+	if err := json.Unmarshal(req.Resource, resource); err != nil {
+		return nil, nil, &EntityError{Reason: "invalid resource: " + err.Error()}
+	}
+
+	var created *models.FixtureEntity
+	err := l.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		var err error
+		created, err = l.create(tx, tenant, entity{resourceType: req.ResourceType, key: req.Key, resource: resource}, false, userID)
+		return err
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+	return created, resource, nil
+}
+
+// create creates the entity's resource with its derived ID, replacing the one
+// created for the same key before, and records the entity
+func (l *Loader) create(tx *gorm.DB, tenant string, e entity, inDataset bool, userID string) (*models.FixtureEntity, error) {
+	id, subject, createdBy := fields(e.resource)
+	*id = ID(tenant, e.resourceType, e.key)
+	*createdBy = userID
+	if specimen, ok := e.resource.(*models.Specimen); ok && specimen.Accession == "" {
+		specimen.Accession = "FX-" + strings.ToUpper((*id)[:8])
+	}
+	if err := l.validator.Struct(e.resource); err != nil {
+		return nil, &EntityError{Reason: err.Error()}
+	}
+
+	if subject != nil {
+		if !strings.HasPrefix(*subject, "Patient/") {
+			return nil, &EntityError{Reason: "subject must reference a fixture patient of the tenant"}
+		}
+		var owned int64
+		if err := tx.Model(&models.FixtureEntity{}).
+			Where("tenant = ? AND resource_type = ? AND resource_id = ?", tenant, "Patient", strings.TrimPrefix(*subject, "Patient/")).
+			Count(&owned).Error; err != nil {
+			return nil, err
+		}
+		if owned == 0 {
+			return nil, &EntityError{Reason: "subject must reference a fixture patient of the tenant"}
+		}
+	}
+
+	var existing int64
+	if err := tx.Model(&models.FixtureEntity{}).
+		Where("tenant = ? AND resource_type = ? AND entity_key = ?", tenant, e.resourceType, e.key).
+		Count(&existing).Error; err != nil {
+		return nil, err
+	}
+	if existing > 0 {
+		// Deleting through the model records the change event of a replaced patient
+		// or observation
+		replaced := newResource(e.resourceType)
+		replacedID, _, _ := fields(replaced)
+		*replacedID = *id
+		if err := tx.Unscoped().Delete(replaced).Error; err != nil {
+			return nil, fmt.Errorf("failed to replace %s: %w", e.resourceType, err)
+		}
+	}
+	if err := tx.Create(e.resource).Error; err != nil {
+		return nil, fmt.Errorf("failed to create %s: %w", e.resourceType, err)
+	}
+
+	record := &models.FixtureEntity{
+		Tenant:       tenant,
+		ResourceType: e.resourceType,
+		Key:          e.key,
+		ResourceID:   *id,
+		Dataset:      inDataset,
+		CreatedBy:    userID,
+	}
+	if err := tx.Save(record).Error; err != nil {
+		return nil, fmt.Errorf("failed to record fixture entity: %w", err)
+	}
+	return record, nil
+}
+
+// clear removes the tenant's fixture entities and the resources of its fixture
+// patients and returns how many resources were removed
+func clear(tx *gorm.DB, tenant string) (int64, error) {
+	var entities []models.FixtureEntity
+	if err := tx.Where("tenant = ?", tenant).Find(&entities).Error; err != nil {
+		return 0, fmt.Errorf("failed to find fixture entities: %w", err)
+	}
+	ids := make(map[string][]string)
+	for _, e := range entities {
+		ids[e.ResourceType] = append(ids[e.ResourceType], e.ResourceID)
+	}
+	patients := ids["Patient"]
+	refs := make([]string, len(patients))
+	for i, id := range patients {
+		refs[i] = "Patient/" + id
+	}
+
+	var removed int64
+	for _, owned := range []struct {
+		resourceType string
+		model        interface{}
+		subject      string
+		events       bool // Whether the model's hooks publish changes, which a batch delete bypasses
+	}{
+		{"Observation", &models.Observation{}, dialect.Of(tx).JSONText("subject", "reference"), true},
+		{"Condition", &models.Condition{}, "subject_reference", false},
+		{"AllergyIntolerance", &models.AllergyIntolerance{}, "subject_reference", false},
+		{"Specimen", &models.Specimen{}, "subject_reference", false},
+	} {
+		var found []string
+		if err := tx.Unscoped().Model(owned.model).
+			Where("id IN ? OR "+owned.subject+" IN ?", ids[owned.resourceType], refs).
+			Pluck("id", &found).Error; err != nil {
+			return 0, fmt.Errorf("failed to find fixture %s resources: %w", owned.resourceType, err)
+		}
+		if len(found) == 0 {
+			continue
+		}
+		if owned.events {
+			if err := models.RecordEvents(tx, owned.resourceType, found, models.EventActionDeleted); err != nil {
+				return 0, fmt.Errorf("failed to record change events: %w", err)
+			}
+		}
+		if err := tx.Unscoped().Where("id IN ?", found).Delete(owned.model).Error; err != nil {
+			return 0, fmt.Errorf("failed to remove fixture %s resources: %w", owned.resourceType, err)
+		}
+		removed += int64(len(found))
+	}
+
+	if len(patients) > 0 {
+		if err := tx.Where("patient_id IN ? OR other_id IN ?", patients, patients).Delete(&models.PatientLink{}).Error; err != nil {
+			return 0, fmt.Errorf("failed to remove fixture patient links: %w", err)
+		}
+		if err := models.RecordEvents(tx, "Patient", patients, models.EventActionDeleted); err != nil {
+			return 0, fmt.Errorf("failed to record change events: %w", err)
+		}
+		result := tx.Unscoped().Where("id IN ?", patients).Delete(&models.Patient{})
+		if result.Error != nil {
+			return 0, fmt.Errorf("failed to remove fixture patients: %w", result.Error)
+		}
+		removed += result.RowsAffected
+	}
+
+	if err := tx.Where("tenant = ?", tenant).Delete(&models.FixtureEntity{}).Error; err != nil {
+		return 0, fmt.Errorf("failed to remove fixture entities: %w", err)
+	}
+	return removed, nil
+}
+
+// newResource returns an empty resource of the type, or nil when fixtures do not
+// support it
+func newResource(resourceType string) interface{} {
+	switch resourceType {
+	case "Patient":
+		return &models.Patient{}
+	case "Observation":
+		return &models.Observation{}
+	case "Condition":
+		return &models.Condition{}
+	case "AllergyIntolerance":
+		return &models.AllergyIntolerance{}
+	case "Specimen":
+		return &models.Specimen{}
+	}
+	return nil
+}
+
+// fields returns the ID, subject reference and creator of a resource. Patients have
+// no subject.
+func fields(resource interface{}) (id, subject, createdBy *string) {
+	switch r := resource.(type) {
+	case *models.Patient:
+		return &r.ID, nil, &r.CreatedBy
+	case *models.Observation:
+		return &r.ID, &r.Subject.Reference, &r.CreatedBy
+	case *models.Condition:
+		return &r.ID, &r.Subject.Reference, &r.CreatedBy
+	case *models.AllergyIntolerance:
+		return &r.ID, &r.Subject.Reference, &r.CreatedBy
+	case *models.Specimen:
+		return &r.ID, &r.Subject.Reference, &r.CreatedBy
+	}
+	panic("fixtures: unsupported resource")
+}
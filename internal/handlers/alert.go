@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// AlertHandler handles HTTP requests for alerts raised to staff
+type AlertHandler struct {
+	db *gorm.DB
+}
+
+// NewAlertHandler creates a new alert handler
+func NewAlertHandler(db *gorm.DB) *AlertHandler {
+	return &AlertHandler{db: db}
+}
+
+// GetAlerts lists alerts
+// @Summary Get alerts
+// @Description List alerts, newest first
+// @Tags alerts
+// @Produce json
+// @Param status query string false "Filter by status (open, acknowledged)"
+// @Param type query string false "Filter by type, e.g. qc-violation"
+// @Param severity query string false "Filter by severity (warning, critical)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.Alert}
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/alerts [get]
+func (h *AlertHandler) GetAlerts(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := h.db.Model(&models.Alert{})
+	for _, column := range []string{"status", "type", "severity"} {
+		if value := c.Query(column); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count alerts",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var alerts []models.Alert
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&alerts).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch alerts",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, alerts, total, page, limit)
+}
+
+// AcknowledgeAlert acknowledges an open alert
+// @Summary Acknowledge alert
+// @Description Acknowledge an open alert, optionally with a comment on the action taken
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Param request body models.AlertAcknowledgeRequest false "Acknowledgement"
+// @Success 200 {object} models.Alert
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/alerts/{id}/acknowledge [post]
+func (h *AlertHandler) AcknowledgeAlert(c *gin.Context) {
+	var req models.AlertAcknowledgeRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Message: err.Error(),
+				Code:    "INVALID_REQUEST_BODY",
+			})
+			return
+		}
+	}
+
+	var alert models.Alert
+	if err := h.db.Where("id = ?", c.Param("id")).First(&alert).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Alert not found",
+				Code:  "ALERT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch alert",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	alreadyAcknowledged := func() {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Alert already acknowledged",
+			Code:  "ALERT_ACKNOWLEDGED",
+		})
+	}
+	if alert.Status != models.AlertStatusOpen {
+		alreadyAcknowledged()
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	now := time.Now().UTC()
+	// Only an open alert is updated, so of concurrent acknowledgements the first wins
+	result := h.db.Model(&models.Alert{}).Where("id = ? AND status = ?", alert.ID, models.AlertStatusOpen).
+		Updates(map[string]interface{}{
+			"status":          models.AlertStatusAcknowledged,
+			"acknowledged_at": now,
+			"acknowledged_by": userID,
+			"comment":         req.Comment,
+		})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to acknowledge alert",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		alreadyAcknowledged()
+		return
+	}
+	alert.Status = models.AlertStatusAcknowledged
+	alert.AcknowledgedAt = &now
+	alert.AcknowledgedBy = userID
+	alert.Comment = req.Comment
+
+	logger.LogAuditEvent("acknowledge", "Alert", userID, map[string]interface{}{
+		"alert_id": alert.ID,
+		"type":     alert.Type,
+	})
+
+	c.JSON(http.StatusOK, alert)
+}
+
+// GetAlertEscalations lists the escalation history of an alert
+// @Summary Get alert escalations
+// @Description List the on-call notifications sent for an alert, oldest first
+// @Tags alerts
+// @Produce json
+// @Param id path string true "Alert ID"
+// @Success 200 {array} models.AlertEscalation
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/alerts/{id}/escalations [get]
+func (h *AlertHandler) GetAlertEscalations(c *gin.Context) {
+	var count int64
+	if err := h.db.Model(&models.Alert{}).Where("id = ?", c.Param("id")).Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch alert",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if count == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Alert not found",
+			Code:  "ALERT_NOT_FOUND",
+		})
+		return
+	}
+
+	var escalations []models.AlertEscalation
+	if err := h.db.Where("alert_id = ?", c.Param("id")).Order("created_at ASC").Find(&escalations).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch alert escalations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, escalations)
+}
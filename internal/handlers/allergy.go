@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// AllergyHandler handles HTTP requests for patient allergies
+type AllergyHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	localizer *terminology.Localizer
+	undo      time.Duration
+}
+
+// NewAllergyHandler creates a new allergy handler. Allergies are read with the
+// displays of their codes in the reader's language. Deleted allergies can be restored
+// until the undo window has passed.
+func NewAllergyHandler(db *gorm.DB, localizer *terminology.Localizer, undoWindow time.Duration) *AllergyHandler {
+	return &AllergyHandler{
+		db:        db,
+		validator: validator.New(),
+		localizer: localizer,
+		undo:      undoWindow,
+	}
+}
+
+// CreateAllergy records an allergy
+// @Summary Create allergy intolerance
+// @Description Record a substance the patient reacts to. The code should use the same codes as medication ingredients so new prescriptions are checked against it; allergies without a matching code are matched by name.
+// @Tags allergies
+// @Accept json
+// @Produce json
+// @Param allergy body models.AllergyIntoleranceRequest true "Allergy"
+// @Success 201 {object} models.AllergyIntolerance
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/allergy-intolerances [post]
+func (h *AllergyHandler) CreateAllergy(c *gin.Context) {
+	var req models.AllergyIntoleranceRequest
+	if !h.bindAllergy(c, &req) {
+		return
+	}
+
+	h.create(c, strings.TrimPrefix(req.Subject.Reference, "Patient/"), req)
+}
+
+// CreatePatientAllergy records an allergy of the patient in the path
+// @Summary Create patient allergy intolerance
+// @Description Record a substance the patient in the path reacts to, as POST /allergy-intolerances does. The subject may be left out; one naming another patient is rejected.
+// @Tags allergies
+// @Accept json
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param allergy body models.AllergyIntoleranceRequest true "Allergy"
+// @Success 201 {object} models.AllergyIntolerance
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/allergy-intolerances [post]
+func (h *AllergyHandler) CreatePatientAllergy(c *gin.Context) {
+	var req models.AllergyIntoleranceRequest
+	if !h.bindAllergy(c, &req) {
+		return
+	}
+
+	patientID := c.Param("id")
+	if req.Subject.Reference != "" && strings.TrimPrefix(req.Subject.Reference, "Patient/") != patientID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "subject must be the patient in the path",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	h.create(c, patientID, req)
+}
+
+// GetAllergy retrieves an allergy by ID
+// @Summary Get allergy intolerance
+// @Description Get an allergy by its ID
+// @Tags allergies
+// @Produce json
+// @Param id path string true "Allergy ID"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
+// @Success 200 {object} models.AllergyIntolerance
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/allergy-intolerances/{id} [get]
+func (h *AllergyHandler) GetAllergy(c *gin.Context) {
+	var allergy models.AllergyIntolerance
+	if !findAllergy(c, readDB(c, h.db), &allergy) {
+		return
+	}
+
+	localizeCodings(c, h.localizer, allergy.Code.Coding)
+	c.JSON(http.StatusOK, allergy)
+}
+
+// UpdateAllergy replaces the clinical content of an allergy
+// @Summary Update allergy intolerance
+// @Description Replace the code, statuses, category, criticality, reaction and note of an allergy; statuses left out are kept. The subject cannot change. New prescriptions are checked against the updated allergy.
+// @Tags allergies
+// @Accept json
+// @Produce json
+// @Param id path string true "Allergy ID"
+// @Param allergy body models.AllergyIntoleranceRequest true "Allergy"
+// @Success 200 {object} models.AllergyIntolerance
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/allergy-intolerances/{id} [put]
+func (h *AllergyHandler) UpdateAllergy(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.AllergyIntoleranceRequest
+	if !h.bindAllergy(c, &req) {
+		return
+	}
+
+	var allergy models.AllergyIntolerance
+	if !findAllergy(c, db, &allergy) {
+		return
+	}
+
+	if req.Subject.Reference != "" && strings.TrimPrefix(req.Subject.Reference, "Patient/") != strings.TrimPrefix(allergy.Subject.Reference, "Patient/") {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "the subject of an allergy cannot change",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	if req.ClinicalStatus != "" {
+		allergy.ClinicalStatus = req.ClinicalStatus
+	}
+	if req.VerificationStatus != "" {
+		allergy.VerificationStatus = req.VerificationStatus
+	}
+	allergy.Code = req.Code
+	allergy.Category = req.Category
+	allergy.Criticality = req.Criticality
+	allergy.Reaction = req.Reaction
+	allergy.Note = req.Note
+
+	if err := db.Model(&allergy).Select("code", "clinical_status", "verification_status", "category", "criticality",
+		"reaction", "note", "updated_at").Updates(&allergy).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update allergy",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "AllergyIntolerance", userID, map[string]interface{}{
+		"allergy_id":          allergy.ID,
+		"clinical_status":     allergy.ClinicalStatus,
+		"verification_status": allergy.VerificationStatus,
+		"criticality":         allergy.Criticality,
+	})
+
+	c.JSON(http.StatusOK, allergy)
+}
+
+// DeleteAllergy removes an allergy
+// @Summary Delete allergy intolerance
+// @Description Delete an allergy recorded by mistake (admin only). Allergy warnings of earlier prescriptions keep the substance and criticality they were raised for. Prefer marking a refuted allergy refuted so the finding stays on record. The deletion can be undone until the undo window passes.
+// @Tags allergies
+// @Param id path string true "Allergy ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/allergy-intolerances/{id} [delete]
+func (h *AllergyHandler) DeleteAllergy(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var allergy models.AllergyIntolerance
+	if !findAllergy(c, db, &allergy) {
+		return
+	}
+
+	// Mark the allergy as pending deletion; the retention job removes it after the undo window
+	if err := trashRecord(c, db, &allergy); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete allergy",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "AllergyIntolerance", userID, map[string]interface{}{
+		"allergy_id": allergy.ID,
+		"patient_id": strings.TrimPrefix(allergy.Subject.Reference, "Patient/"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// UndeleteAllergy restores a deleted allergy within the undo window
+// @Summary Undelete allergy
+// @Description Restore a deleted allergy as long as the undo window has not passed. Allergies deleted with their patient are restored by undeleting the patient (admin only).
+// @Tags allergies
+// @Produce json
+// @Param id path string true "Allergy ID"
+// @Success 200 {object} models.AllergyIntolerance
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/allergy-intolerances/{id}/undelete [post]
+func (h *AllergyHandler) UndeleteAllergy(c *gin.Context) {
+	var allergy models.AllergyIntolerance
+	if !undeleteRecord(c, writeDB(c, h.db), "AllergyIntolerance", &allergy, h.undo, ErrorResponse{
+		Error: "Allergy not found",
+		Code:  "ALLERGY_NOT_FOUND",
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, allergy)
+}
+
+// bindAllergy binds and validates an allergy request, writing the error response on
+// failure
+func (h *AllergyHandler) bindAllergy(c *gin.Context, req *models.AllergyIntoleranceRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	if len(req.Code.Coding) == 0 && strings.TrimSpace(req.Code.Text) == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "code must have a coding or text naming the substance",
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	return true
+}
+
+// create records the allergy of a request for a patient
+func (h *AllergyHandler) create(c *gin.Context, patientID string, req models.AllergyIntoleranceRequest) {
+	db := writeDB(c, h.db)
+
+	// Validate that the referenced patient exists
+	var patient models.Patient
+	if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	allergy := models.AllergyIntolerance{
+		Subject:            models.Reference{Reference: "Patient/" + patientID, Display: req.Subject.Display},
+		Code:               req.Code,
+		ClinicalStatus:     req.ClinicalStatus,
+		VerificationStatus: req.VerificationStatus,
+		Category:           req.Category,
+		Criticality:        req.Criticality,
+		Reaction:           req.Reaction,
+		Note:               req.Note,
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		allergy.CreatedBy = userID
+	}
+	if err := db.Create(&allergy).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create allergy",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "AllergyIntolerance", allergy.CreatedBy, map[string]interface{}{
+		"allergy_id":  allergy.ID,
+		"patient_id":  patientID,
+		"criticality": allergy.Criticality,
+	})
+
+	c.JSON(http.StatusCreated, allergy)
+}
+
+// GetPatientAllergies lists the allergies of a patient
+// @Summary Get patient allergies
+// @Description List the allergies recorded for a patient. Only active allergies are listed unless all is set.
+// @Tags allergies
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param all query bool false "Include inactive, resolved, refuted and erroneous allergies"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
+// @Success 200 {array} models.AllergyIntolerance
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/allergy-intolerances [get]
+func (h *AllergyHandler) GetPatientAllergies(c *gin.Context) {
+	query := readDB(c, h.db).Where("subject_reference = ?", "Patient/"+c.Param("id"))
+	if c.Query("all") != "true" {
+		query = query.Where("clinical_status = ? AND verification_status NOT IN ?",
+			models.AllergyActive, []string{models.AllergyRefuted, models.AllergyEnteredInError})
+	}
+
+	allergies := []models.AllergyIntolerance{}
+	if err := query.Order("created_at DESC").Find(&allergies).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch allergies",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	codings := make([][]models.Coding, len(allergies))
+	for i := range allergies {
+		codings[i] = allergies[i].Code.Coding
+	}
+	localizeCodings(c, h.localizer, codings...)
+	c.JSON(http.StatusOK, allergies)
+}
+
+// UpdateAllergyStatus changes the clinical or verification status of an allergy
+// @Summary Update allergy status
+// @Description Confirm, refute, resolve or reactivate an allergy. Only active allergies that are not refuted or entered in error are checked when prescribing.
+// @Tags allergies
+// @Accept json
+// @Produce json
+// @Param id path string true "Allergy ID"
+// @Param request body models.AllergyIntoleranceStatusRequest true "New status"
+// @Success 200 {object} models.AllergyIntolerance
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/allergy-intolerances/{id}/status [post]
+func (h *AllergyHandler) UpdateAllergyStatus(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.AllergyIntoleranceStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var allergy models.AllergyIntolerance
+	if !findAllergy(c, db, &allergy) {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.ClinicalStatus != "" {
+		updates["clinical_status"] = req.ClinicalStatus
+		allergy.ClinicalStatus = req.ClinicalStatus
+	}
+	if req.VerificationStatus != "" {
+		updates["verification_status"] = req.VerificationStatus
+		allergy.VerificationStatus = req.VerificationStatus
+	}
+	if err := db.Model(&allergy).Updates(updates).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update allergy",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "AllergyIntolerance", userID, map[string]interface{}{
+		"allergy_id":          allergy.ID,
+		"clinical_status":     allergy.ClinicalStatus,
+		"verification_status": allergy.VerificationStatus,
+	})
+
+	c.JSON(http.StatusOK, allergy)
+}
+
+// findAllergy loads the allergy in the path, writing the error response when it is
+// not found
+func findAllergy(c *gin.Context, db *gorm.DB, allergy *models.AllergyIntolerance) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(allergy).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Allergy not found",
+				Code:  "ALLERGY_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch allergy",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
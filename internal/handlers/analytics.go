@@ -0,0 +1,227 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/disclosure"
+	"gorm.io/gorm"
+)
+
+// exactAnalyticsRoles read analytics as they are; other roles allowed on a report,
+// such as research, get its counts under the endpoint's disclosure policy
+var exactAnalyticsRoles = []string{"admin", "practitioner", "lab-tech"}
+
+// AnalyticsHandler handles aggregate reporting endpoints
+type AnalyticsHandler struct {
+	db       *gorm.DB
+	policies *disclosure.Policies
+}
+
+// NewAnalyticsHandler creates a new analytics handler
+func NewAnalyticsHandler(db *gorm.DB, policies *disclosure.Policies) *AnalyticsHandler {
+	return &AnalyticsHandler{db: db, policies: policies}
+}
+
+// CategoryCount represents the number of patients in a reporting category
+type CategoryCount struct {
+	Code       string `json:"code"`
+	Display    string `json:"display,omitempty"`
+	Count      int64  `json:"count"`
+	Suppressed bool   `json:"suppressed,omitempty"` // Count withheld by disclosure control
+}
+
+// DemographicsReport represents aggregate patient demographics for equity reporting
+type DemographicsReport struct {
+	Total          int64           `json:"total"`
+	Gender         []CategoryCount `json:"gender"`
+	Race           []CategoryCount `json:"race"`
+	TwoOrMoreRaces int64           `json:"twoOrMoreRaces"`
+	Ethnicity      []CategoryCount `json:"ethnicity"`
+	AgeBand        []CategoryCount `json:"ageBand"`
+	// Disclosure control applied to the counts, and the top-level counts it withheld
+	Disclosure  *disclosure.Policy `json:"disclosure,omitempty"`
+	Suppressed  []string           `json:"suppressed,omitempty"`
+	GeneratedAt time.Time          `json:"generatedAt"`
+}
+
+// raceNotRecorded matches patients without any OMB race or ethnicity category
+const raceNotRecorded = "not-recorded"
+
+// GetDemographics aggregates patients by gender, OMB race and ethnicity and age band
+// @Summary Patient demographics report
+// @Description Aggregate patient counts by gender, OMB race and ethnicity categories and age band. Patients reporting several races are counted in each category and in twoOrMoreRaces. For research users, counts of fewer patients than the endpoint's minimum cell size are suppressed, with a second count of a category suppressed when one alone would be recoverable from the total, and released counts may carry noise (admin or research).
+// @Tags analytics
+// @Produce json
+// @Param active query bool false "Only include active patients"
+// @Success 200 {object} DemographicsReport
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/analytics/demographics [get]
+func (h *AnalyticsHandler) GetDemographics(c *gin.Context) {
+	// The race, ethnicity and age aggregations rely on PostgreSQL JSON and date functions
+	if !dialect.IsPostgres(h.db) {
+		respondError(c, http.StatusNotImplemented, ErrorResponse{
+			Error:   "Report not available",
+			Message: "The demographics report requires a PostgreSQL database",
+			Code:    "UNSUPPORTED_DATABASE",
+		})
+		return
+	}
+
+	base := func() *gorm.DB {
+		query := h.db.Model(&models.Patient{})
+		if c.Query("active") == "true" {
+			query = query.Where("patients.active = ?", true)
+		}
+		return query
+	}
+
+	report := DemographicsReport{GeneratedAt: time.Now().UTC()}
+
+	if err := base().Count(&report.Total).Error; err != nil {
+		h.databaseError(c, err)
+		return
+	}
+
+	if err := base().Select("gender AS code, COUNT(*) AS count").
+		Group("gender").Order("gender").Scan(&report.Gender).Error; err != nil {
+		h.databaseError(c, err)
+		return
+	}
+
+	race, err := h.categoryCounts(base(), "race", models.OMBRaceCategories)
+	if err != nil {
+		h.databaseError(c, err)
+		return
+	}
+	report.Race = race
+
+	if err := base().Where("jsonb_array_length(COALESCE(patients.race->'ombCategory', '[]'::jsonb)) > 1").
+		Count(&report.TwoOrMoreRaces).Error; err != nil {
+		h.databaseError(c, err)
+		return
+	}
+
+	ethnicity, err := h.categoryCounts(base(), "ethnicity", models.OMBEthnicityCategories)
+	if err != nil {
+		h.databaseError(c, err)
+		return
+	}
+	report.Ethnicity = ethnicity
+
+	if err := base().Select(`CASE
+			WHEN birth_date IS NULL OR birth_date < '1800-01-01' THEN 'unknown'
+			WHEN date_part('year', age(CURRENT_DATE, birth_date::date)) < 18 THEN '0-17'
+			WHEN date_part('year', age(CURRENT_DATE, birth_date::date)) < 45 THEN '18-44'
+			WHEN date_part('year', age(CURRENT_DATE, birth_date::date)) < 65 THEN '45-64'
+			WHEN date_part('year', age(CURRENT_DATE, birth_date::date)) < 90 THEN '65-89'
+			ELSE '90+' END AS code, COUNT(*) AS count`).
+		Group("1").Order("1").Scan(&report.AgeBand).Error; err != nil {
+		h.databaseError(c, err)
+		return
+	}
+
+	if policy := h.disclosurePolicy(c, "demographics"); policy != nil {
+		report.applyDisclosure(*policy)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// categoryCounts counts patients per OMB category of the race or ethnicity column,
+// including a bucket for patients with no category recorded
+func (h *AnalyticsHandler) categoryCounts(query *gorm.DB, column string, displays map[string]string) ([]CategoryCount, error) {
+	var counts []CategoryCount
+	err := query.Select("COALESCE(category->>'code', '" + raceNotRecorded + "') AS code, COUNT(DISTINCT patients.id) AS count").
+		Joins("LEFT JOIN LATERAL jsonb_array_elements(COALESCE(patients." + column + "->'ombCategory', '[]'::jsonb)) AS category ON true").
+		Group("1").Order("1").Scan(&counts).Error
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range counts {
+		counts[i].Display = displays[counts[i].Code]
+	}
+	return counts, nil
+}
+
+// applyDisclosure suppresses the report's small counts and perturbs the rest
+func (r *DemographicsReport) applyDisclosure(policy disclosure.Policy) {
+	r.Disclosure = &policy
+
+	// Categories are judged on their true counts before any noise is added; the gender,
+	// ethnicity and age band counts each add up to the total
+	if policy.Suppressed(r.Total) {
+		r.Suppressed = append(r.Suppressed, "total")
+		r.Total = 0
+	} else {
+		r.Total = policy.Perturb(r.Total)
+	}
+	if policy.Suppressed(r.TwoOrMoreRaces) {
+		r.Suppressed = append(r.Suppressed, "twoOrMoreRaces")
+		r.TwoOrMoreRaces = 0
+	} else {
+		r.TwoOrMoreRaces = policy.Perturb(r.TwoOrMoreRaces)
+	}
+	suppressCategories(policy, r.Gender, true)
+	suppressCategories(policy, r.Race, false)
+	suppressCategories(policy, r.Ethnicity, true)
+	suppressCategories(policy, r.AgeBand, true)
+}
+
+// suppressCategories suppresses small category counts and perturbs the rest. The
+// counts of a partition of the patients have a second count suppressed when one alone
+// would otherwise be recoverable from the total.
+func suppressCategories(policy disclosure.Policy, counts []CategoryCount, partition bool) {
+	suppressed := make([]bool, len(counts))
+	if partition {
+		values := make([]int64, len(counts))
+		for i := range counts {
+			values[i] = counts[i].Count
+		}
+		suppressed = policy.SuppressPartition(values)
+	} else {
+		for i := range counts {
+			suppressed[i] = policy.Suppressed(counts[i].Count)
+		}
+	}
+
+	for i := range counts {
+		if suppressed[i] {
+			counts[i].Count = 0
+			counts[i].Suppressed = true
+			continue
+		}
+		counts[i].Count = policy.Perturb(counts[i].Count)
+	}
+}
+
+// disclosurePolicy returns the disclosure policy of an endpoint when the user is to
+// read its counts under one, or nil when they read exact counts
+func (h *AnalyticsHandler) disclosurePolicy(c *gin.Context, endpoint string) *disclosure.Policy {
+	roles, _ := auth.GetUserRoles(c)
+	for _, role := range exactAnalyticsRoles {
+		if containsRole(roles, role) {
+			return nil
+		}
+	}
+	policy := h.policies.For(endpoint)
+	return &policy
+}
+
+// databaseError writes a database error response
+func (h *AnalyticsHandler) databaseError(c *gin.Context, err error) {
+	respondError(c, http.StatusInternalServerError, ErrorResponse{
+		Error:   "Failed to aggregate patients",
+		Message: err.Error(),
+		Code:    "DATABASE_ERROR",
+	})
+}
@@ -0,0 +1,97 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// AnalyticsViewHandler reports and refreshes the materialized views the analytics
+// endpoints read
+type AnalyticsViewHandler struct {
+	db        *gorm.DB
+	refresher *database.ViewRefresher
+}
+
+// NewAnalyticsViewHandler creates a new analytics view handler
+func NewAnalyticsViewHandler(db *gorm.DB, refresher *database.ViewRefresher) *AnalyticsViewHandler {
+	return &AnalyticsViewHandler{
+		db:        db,
+		refresher: refresher,
+	}
+}
+
+// AnalyticsViewsResponse lists the analytics materialized views
+type AnalyticsViewsResponse struct {
+	Data []database.ViewStatus `json:"data"`
+}
+
+// GetAnalyticsViews lists the analytics materialized views and when they were refreshed
+// @Summary Get analytics views
+// @Description List the materialized views behind the observation analytics reports with whether each holds data and when it was last refreshed. A view holds no data until its first refresh; empty on databases other than PostgreSQL (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} AnalyticsViewsResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/analytics-views [get]
+func (h *AnalyticsViewHandler) GetAnalyticsViews(c *gin.Context) {
+	statuses, err := database.ViewStatuses(h.db)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read analytics views",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, AnalyticsViewsResponse{Data: statuses})
+}
+
+// RefreshAnalyticsViews refreshes the analytics materialized views now
+// @Summary Refresh analytics views
+// @Description Refresh every analytics materialized view ahead of the schedule as the operation in Location. A refresh already queued or running is returned instead of queueing another; populated views go on serving reads while refreshed (admin only)
+// @Tags admin
+// @Produce json
+// @Success 202 {object} models.Operation
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/analytics-views/refresh [post]
+func (h *AnalyticsViewHandler) RefreshAnalyticsViews(c *gin.Context) {
+	if !dialect.IsPostgres(h.db) {
+		respondError(c, http.StatusNotImplemented, ErrorResponse{
+			Error:   "Analytics views not available",
+			Message: "Materialized views require a PostgreSQL database",
+			Code:    "UNSUPPORTED_DATABASE",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	op, err := h.refresher.Queue(userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to queue analytics view refresh",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("analytics_view_refresh_requested", "AnalyticsView", userID, map[string]interface{}{
+		"operation_id": op.ID,
+	})
+
+	acceptOperation(c, op, op)
+}
@@ -1,40 +1,50 @@
 package handlers
 
 import (
+	"errors"
+	"io"
 	"net/http"
+	"strings"
 	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/hillmatthew2000/HealthHub/internal/auth"
 	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
 	"gorm.io/gorm"
 )
 
 // AuthHandler handles authentication requests
 type AuthHandler struct {
-	db           *gorm.DB
-	validator    *validator.Validate
-	tokenManager *auth.TokenManager
-	rbacService  *auth.RBACService
+	db            *gorm.DB
+	validator     *validator.Validate
+	tokenManager  *auth.TokenManager
+	refreshTokens *auth.RefreshStore
+	denylist      auth.Denylist
+	rbacService   *auth.RBACService
+	oidc          *auth.OIDCProvider // Nil without single sign-on
 }
 
-// NewAuthHandler creates a new authentication handler
-func NewAuthHandler(db *gorm.DB, jwtSecret string) *AuthHandler {
-	tokenManager := auth.NewTokenManager(jwtSecret, "HealthHub API")
+// NewAuthHandler creates a new authentication handler; oidc may be nil when single
+// sign-on is not configured
+func NewAuthHandler(db *gorm.DB, tokenManager *auth.TokenManager, refreshTokens *auth.RefreshStore, denylist auth.Denylist, oidc *auth.OIDCProvider) *AuthHandler {
 	rbacService := auth.NewRBACService(db)
 
 	return &AuthHandler{
-		db:           db,
-		validator:    validator.New(),
-		tokenManager: tokenManager,
-		rbacService:  rbacService,
+		db:            db,
+		validator:     validator.New(),
+		tokenManager:  tokenManager,
+		refreshTokens: refreshTokens,
+		denylist:      denylist,
+		rbacService:   rbacService,
+		oidc:          oidc,
 	}
 }
 
 // Login authenticates a user and returns a JWT token
 // @Summary User login
-// @Description Authenticate user and get access token
+// @Description Authenticate user and get a short-lived access token and a refresh token to renew it with
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -48,7 +58,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	var req models.AuthRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request body",
 			Message: err.Error(),
 			Code:    "INVALID_REQUEST_BODY",
@@ -57,7 +67,7 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	}
 
 	if err := h.validator.Struct(req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Validation failed",
 			Message: err.Error(),
 			Code:    "VALIDATION_FAILED",
@@ -69,13 +79,18 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	var user models.User
 	if err := h.db.Preload("Roles").Where("email = ? AND active = ?", req.Email, true).First(&user).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusUnauthorized, ErrorResponse{
+			logger.LogAuditEvent("login_failed", "User", "", map[string]interface{}{
+				"email":     req.Email,
+				"client_ip": c.ClientIP(),
+				"reason":    "unknown_user",
+			})
+			respondError(c, http.StatusUnauthorized, ErrorResponse{
 				Error: "Invalid credentials",
 				Code:  "INVALID_CREDENTIALS",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to authenticate user",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -85,7 +100,12 @@ func (h *AuthHandler) Login(c *gin.Context) {
 
 	// Check password
 	if err := user.CheckPassword(req.Password); err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+		logger.LogAuditEvent("login_failed", "User", user.ID, map[string]interface{}{
+			"email":     req.Email,
+			"client_ip": c.ClientIP(),
+			"reason":    "invalid_password",
+		})
+		respondError(c, http.StatusUnauthorized, ErrorResponse{
 			Error: "Invalid credentials",
 			Code:  "INVALID_CREDENTIALS",
 		})
@@ -97,31 +117,14 @@ func (h *AuthHandler) Login(c *gin.Context) {
 	user.LastLogin = &now
 	h.db.Model(&user).Update("last_login", now)
 
-	// Generate JWT token
-	roleNames := user.GetRoleNames()
-	token, expiresAt, err := h.tokenManager.GenerateToken(user.ID, user.Email, roleNames)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to generate token",
-			Message: err.Error(),
-			Code:    "TOKEN_GENERATION_FAILED",
-		})
+	response, ok := h.startSession(c, &user)
+	if !ok {
 		return
 	}
 
-	// Prepare response
-	response := models.AuthResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-		User: models.UserInfo{
-			ID:        user.ID,
-			Email:     user.Email,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			Roles:     roleNames,
-			Active:    user.Active,
-		},
-	}
+	logger.LogAuditEvent("login", "User", user.ID, map[string]interface{}{
+		"client_ip": c.ClientIP(),
+	})
 
 	c.JSON(http.StatusOK, response)
 }
@@ -142,7 +145,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	var req models.RegisterRequest
 
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request body",
 			Message: err.Error(),
 			Code:    "INVALID_REQUEST_BODY",
@@ -151,7 +154,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	if err := h.validator.Struct(req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Validation failed",
 			Message: err.Error(),
 			Code:    "VALIDATION_FAILED",
@@ -162,7 +165,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	// Check if user already exists
 	var existingUser models.User
 	if err := h.db.Where("email = ?", req.Email).First(&existingUser).Error; err == nil {
-		c.JSON(http.StatusConflict, ErrorResponse{
+		respondError(c, http.StatusConflict, ErrorResponse{
 			Error: "User with this email already exists",
 			Code:  "USER_ALREADY_EXISTS",
 		})
@@ -180,7 +183,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	// Hash password
 	if err := user.HashPassword(); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to process password",
 			Message: err.Error(),
 			Code:    "PASSWORD_HASH_FAILED",
@@ -199,7 +202,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	// Create user
 	if err := tx.Create(&user).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to create user",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -217,7 +220,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		var role models.Role
 		if err := tx.Where("name = ?", roleName).First(&role).Error; err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusBadRequest, ErrorResponse{
+			respondError(c, http.StatusBadRequest, ErrorResponse{
 				Error: "Invalid role: " + roleName,
 				Code:  "INVALID_ROLE",
 			})
@@ -226,7 +229,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 		if err := h.rbacService.AssignRoleToUser(user.ID, role.ID, "system"); err != nil {
 			tx.Rollback()
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
 				Error:   "Failed to assign role",
 				Message: err.Error(),
 				Code:    "ROLE_ASSIGNMENT_FAILED",
@@ -236,7 +239,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 	}
 
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to complete registration",
 			Message: err.Error(),
 			Code:    "TRANSACTION_FAILED",
@@ -246,7 +249,7 @@ func (h *AuthHandler) Register(c *gin.Context) {
 
 	// Load user with roles for response
 	if err := h.db.Preload("Roles").Where("id = ?", user.ID).First(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to load user data",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -254,81 +257,241 @@ func (h *AuthHandler) Register(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	roleNames := user.GetRoleNames()
-	token, expiresAt, err := h.tokenManager.GenerateToken(user.ID, user.Email, roleNames)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to generate token",
-			Message: err.Error(),
-			Code:    "TOKEN_GENERATION_FAILED",
-		})
+	response, ok := h.startSession(c, &user)
+	if !ok {
 		return
 	}
 
-	// Prepare response
-	response := models.AuthResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
-		User: models.UserInfo{
-			ID:        user.ID,
-			Email:     user.Email,
-			FirstName: user.FirstName,
-			LastName:  user.LastName,
-			Roles:     roleNames,
-			Active:    user.Active,
-		},
-	}
-
 	c.JSON(http.StatusCreated, response)
 }
 
-// RefreshToken refreshes an existing JWT token
+// RefreshToken exchanges a refresh token for a new access token
 // @Summary Refresh access token
-// @Description Refresh an existing access token
+// @Description Exchange a refresh token for a new access token and a new refresh token. The refresh token sent is used up: sending it again is taken as a sign it was stolen, revokes every refresh token of its session and is answered with REFRESH_TOKEN_REUSED, so the user has to log in again. No bearer token is needed, as the access token may have expired.
 // @Tags auth
 // @Accept json
 // @Produce json
+// @Param request body models.RefreshRequest true "Refresh token"
 // @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 500 {object} ErrorResponse
-// @Security BearerAuth
 // @Router /api/v1/auth/refresh [post]
 func (h *AuthHandler) RefreshToken(c *gin.Context) {
-	claims, exists := auth.GetClaims(c)
-	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
-			Error: "Invalid token",
-			Code:  "INVALID_TOKEN",
-		})
+	req, ok := h.bindRefreshRequest(c)
+	if !ok {
+		return
+	}
+
+	refreshToken, record, err := h.refreshTokens.Rotate(req.RefreshToken, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrRefreshTokenReused):
+			logger.LogAuditEvent("refresh_token_reuse", "User", record.UserID, map[string]interface{}{
+				"family_id": record.FamilyID,
+				"client_ip": c.ClientIP(),
+			})
+			respondError(c, http.StatusUnauthorized, ErrorResponse{
+				Error:   "Refresh token reused",
+				Message: "the refresh token was already used; every token of its session is revoked",
+				Code:    "REFRESH_TOKEN_REUSED",
+			})
+		case errors.Is(err, auth.ErrRefreshTokenInvalid):
+			respondError(c, http.StatusUnauthorized, ErrorResponse{
+				Error: "Invalid refresh token",
+				Code:  "INVALID_TOKEN",
+			})
+		default:
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to refresh token",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+		}
 		return
 	}
 
-	// Verify user is still active
+	// Verify user is still active; the roles are read afresh into the new token
 	var user models.User
-	if err := h.db.Preload("Roles").Where("id = ? AND active = ?", claims.UserID, true).First(&user).Error; err != nil {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+	if err := h.db.Preload("Roles").Where("id = ? AND active = ?", record.UserID, true).First(&user).Error; err != nil {
+		h.refreshTokens.RevokeFamily(record.FamilyID, models.RefreshRevokedUserInactive)
+		respondError(c, http.StatusUnauthorized, ErrorResponse{
 			Error: "User not found or inactive",
 			Code:  "USER_INACTIVE",
 		})
 		return
 	}
 
-	// Generate new token
+	response, ok := h.authResponse(c, &user, refreshToken, record)
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// Logout ends a session by revoking its access token and refresh tokens
+// @Summary User logout
+// @Description Revoke the access token sent in the Authorization header, and the refresh token in the body with every other refresh token of its session. Either may be left out, but not both.
+// @Tags auth
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body models.LogoutRequest false "Refresh token"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/auth/logout [post]
+func (h *AuthHandler) Logout(c *gin.Context) {
+	var req models.LogoutRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	// An access token that no longer validates cannot be used, so it needs no denying;
+	// expired tokens are common at logout and must not block revoking the refresh token
+	var claims *auth.Claims
+	accessToken := strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")
+	if accessToken != "" && accessToken != c.GetHeader("Authorization") {
+		claims, _ = h.tokenManager.ValidateToken(accessToken)
+	}
+	if claims == nil && req.RefreshToken == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Nothing to log out",
+			Message: "send a valid access token in the Authorization header or a refresh token in the body",
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	var record *models.RefreshToken
+	if req.RefreshToken != "" {
+		var err error
+		record, err = h.refreshTokens.Lookup(req.RefreshToken)
+		if err != nil {
+			if errors.Is(err, auth.ErrRefreshTokenInvalid) {
+				respondError(c, http.StatusUnauthorized, ErrorResponse{
+					Error: "Invalid refresh token",
+					Code:  "INVALID_TOKEN",
+				})
+				return
+			}
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to look up refresh token",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if claims != nil && claims.UserID != record.UserID {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Tokens belong to different users",
+				Message: "the access token and the refresh token must be of the same user",
+				Code:    "INVALID_REQUEST_BODY",
+			})
+			return
+		}
+	}
+
+	userID := ""
+	if claims != nil {
+		userID = claims.UserID
+		// Tokens issued before they carried an ID cannot be denied; they expire shortly
+		if claims.ID != "" && claims.ExpiresAt != nil {
+			if err := h.denylist.Deny(c.Request.Context(), claims.ID, claims.ExpiresAt.Time); err != nil {
+				respondError(c, http.StatusInternalServerError, ErrorResponse{
+					Error:   "Failed to revoke access token",
+					Message: err.Error(),
+					Code:    "INTERNAL_ERROR",
+				})
+				return
+			}
+		}
+	}
+	if record != nil {
+		userID = record.UserID
+		if err := h.refreshTokens.RevokeFamily(record.FamilyID, models.RefreshRevokedLogout); err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to log out",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	logger.LogAuditEvent("logout", "User", userID, map[string]interface{}{
+		"client_ip":             c.ClientIP(),
+		"access_token_revoked":  claims != nil,
+		"refresh_token_revoked": record != nil,
+	})
+
+	c.JSON(http.StatusOK, NewSuccessResponse("Logged out successfully", nil))
+}
+
+// bindRefreshRequest reads the refresh token of a request, writing the error response
+// when it is missing
+func (h *AuthHandler) bindRefreshRequest(c *gin.Context) (models.RefreshRequest, bool) {
+	var req models.RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return req, false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// startSession issues the tokens of a new session of a user who logged in or
+// registered
+func (h *AuthHandler) startSession(c *gin.Context, user *models.User) (models.AuthResponse, bool) {
+	refreshToken, record, err := h.refreshTokens.Issue(user.ID, c.ClientIP(), c.Request.UserAgent())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to generate token",
+			Message: err.Error(),
+			Code:    "TOKEN_GENERATION_FAILED",
+		})
+		return models.AuthResponse{}, false
+	}
+	return h.authResponse(c, user, refreshToken, record)
+}
+
+// authResponse issues an access token for a user and builds the response carrying it
+// with the given refresh token
+func (h *AuthHandler) authResponse(c *gin.Context, user *models.User, refreshToken string, record *models.RefreshToken) (models.AuthResponse, bool) {
 	roleNames := user.GetRoleNames()
 	token, expiresAt, err := h.tokenManager.GenerateToken(user.ID, user.Email, roleNames)
 	if err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to generate token",
 			Message: err.Error(),
 			Code:    "TOKEN_GENERATION_FAILED",
 		})
-		return
+		return models.AuthResponse{}, false
 	}
 
-	response := models.AuthResponse{
-		Token:     token,
-		ExpiresAt: expiresAt,
+	return models.AuthResponse{
+		Token:                 token,
+		ExpiresAt:             expiresAt,
+		RefreshToken:          refreshToken,
+		RefreshTokenExpiresAt: record.ExpiresAt,
 		User: models.UserInfo{
 			ID:        user.ID,
 			Email:     user.Email,
@@ -337,9 +500,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 			Roles:     roleNames,
 			Active:    user.Active,
 		},
-	}
-
-	c.JSON(http.StatusOK, response)
+	}, true
 }
 
 // GetProfile returns the current user's profile
@@ -356,7 +517,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 func (h *AuthHandler) GetProfile(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+		respondError(c, http.StatusUnauthorized, ErrorResponse{
 			Error: "User not authenticated",
 			Code:  "NOT_AUTHENTICATED",
 		})
@@ -365,7 +526,7 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 
 	var user models.User
 	if err := h.db.Preload("Roles").Where("id = ?", userID).First(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch user profile",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -387,7 +548,7 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 
 // ChangePassword changes the current user's password
 // @Summary Change password
-// @Description Change the authenticated user's password
+// @Description Change the authenticated user's password. Every refresh token of the user is revoked, ending all their sessions, this one included: log in again with the new password.
 // @Tags auth
 // @Accept json
 // @Produce json
@@ -401,7 +562,7 @@ func (h *AuthHandler) GetProfile(c *gin.Context) {
 func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	userID, exists := auth.GetUserID(c)
 	if !exists {
-		c.JSON(http.StatusUnauthorized, ErrorResponse{
+		respondError(c, http.StatusUnauthorized, ErrorResponse{
 			Error: "User not authenticated",
 			Code:  "NOT_AUTHENTICATED",
 		})
@@ -410,7 +571,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 
 	var req models.ChangePasswordRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request body",
 			Message: err.Error(),
 			Code:    "INVALID_REQUEST_BODY",
@@ -419,7 +580,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	}
 
 	if err := h.validator.Struct(req); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Validation failed",
 			Message: err.Error(),
 			Code:    "VALIDATION_FAILED",
@@ -430,7 +591,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	// Get current user
 	var user models.User
 	if err := h.db.Where("id = ?", userID).First(&user).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch user",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -440,7 +601,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 
 	// Verify current password
 	if err := user.CheckPassword(req.CurrentPassword); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error: "Current password is incorrect",
 			Code:  "INVALID_CURRENT_PASSWORD",
 		})
@@ -450,7 +611,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	// Update password
 	user.Password = req.NewPassword
 	if err := user.HashPassword(); err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to process new password",
 			Message: err.Error(),
 			Code:    "PASSWORD_HASH_FAILED",
@@ -459,7 +620,7 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 	}
 
 	if err := h.db.Model(&user).Update("password", user.Password).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to update password",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -467,5 +628,15 @@ func (h *AuthHandler) ChangePassword(c *gin.Context) {
 		return
 	}
 
+	// Sessions started with the old password, possibly by whoever learned it, end
+	if err := h.refreshTokens.RevokeUser(user.ID, models.RefreshRevokedPasswordChange); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to end sessions",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
 	c.JSON(http.StatusOK, NewSuccessResponse("Password changed successfully", nil))
 }
@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// errNoMappedRole is returned when a new single sign-on user is in no group mapped
+// to a role
+var errNoMappedRole = errors.New("no role is mapped to the user's groups")
+
+// errUnverifiedEmail is returned when a single sign-on user's unverified email is
+// that of an existing user
+var errUnverifiedEmail = errors.New("the identity provider has not verified the email of an existing user")
+
+// OIDCLogin starts a single sign-on login
+// @Summary Start single sign-on login
+// @Description Redirect the browser to the identity provider to sign in. The provider sends it back to the configured redirect URL with a code and state, to be passed to GET /api/v1/auth/oidc/callback within 10 minutes. Local login with email and password remains available.
+// @Tags auth
+// @Success 302 "Redirect to the identity provider"
+// @Failure 404 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /api/v1/auth/oidc/login [get]
+func (h *AuthHandler) OIDCLogin(c *gin.Context) {
+	if !h.requireOIDC(c) {
+		return
+	}
+
+	location, err := h.oidc.Start(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusBadGateway, ErrorResponse{
+			Error:   "Failed to start single sign-on",
+			Message: err.Error(),
+			Code:    "SSO_PROVIDER_ERROR",
+		})
+		return
+	}
+	c.Redirect(http.StatusFound, location)
+}
+
+// OIDCCallback completes a single sign-on login
+// @Summary Complete single sign-on login
+// @Description Exchange the code the identity provider sent the browser back with for a HealthHub session. A user signing in for the first time is linked to the local user with their email, if the provider verified it, or created with the roles mapped to their provider groups; their roles are managed in HealthHub afterwards.
+// @Tags auth
+// @Produce json
+// @Param code query string true "Authorization code"
+// @Param state query string true "Login state"
+// @Success 200 {object} models.AuthResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Router /api/v1/auth/oidc/callback [get]
+func (h *AuthHandler) OIDCCallback(c *gin.Context) {
+	if !h.requireOIDC(c) {
+		return
+	}
+
+	// The provider reports a login the user cancelled or was refused in the query
+	if providerError := c.Query("error"); providerError != "" {
+		logger.LogAuditEvent("login_failed", "User", "", map[string]interface{}{
+			"client_ip": c.ClientIP(),
+			"method":    "oidc",
+			"reason":    providerError,
+		})
+		respondError(c, http.StatusUnauthorized, ErrorResponse{
+			Error:   "Single sign-on failed",
+			Message: strings.TrimSpace(providerError + " " + c.Query("error_description")),
+			Code:    "SSO_LOGIN_FAILED",
+		})
+		return
+	}
+	code, state := c.Query("code"), c.Query("state")
+	if code == "" || state == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid callback",
+			Message: "code and state are required",
+			Code:    "INVALID_LOGIN_STATE",
+		})
+		return
+	}
+
+	identity, err := h.oidc.Finish(c.Request.Context(), state, code)
+	if err != nil {
+		switch {
+		case errors.Is(err, auth.ErrOIDCStateInvalid):
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid login state",
+				Message: err.Error(),
+				Code:    "INVALID_LOGIN_STATE",
+			})
+		case errors.Is(err, auth.ErrOIDCTokenInvalid):
+			logger.LogAuditEvent("login_failed", "User", "", map[string]interface{}{
+				"client_ip": c.ClientIP(),
+				"method":    "oidc",
+				"reason":    "invalid_id_token",
+			})
+			respondError(c, http.StatusUnauthorized, ErrorResponse{
+				Error:   "Single sign-on failed",
+				Message: err.Error(),
+				Code:    "SSO_LOGIN_FAILED",
+			})
+		default:
+			respondError(c, http.StatusBadGateway, ErrorResponse{
+				Error:   "Failed to complete single sign-on",
+				Message: err.Error(),
+				Code:    "SSO_PROVIDER_ERROR",
+			})
+		}
+		return
+	}
+	if identity.Email == "" {
+		respondError(c, http.StatusUnauthorized, ErrorResponse{
+			Error:   "Single sign-on failed",
+			Message: "the identity provider did not return the user's email; request the email scope",
+			Code:    "SSO_LOGIN_FAILED",
+		})
+		return
+	}
+
+	user, created, err := h.oidcUser(identity)
+	if err != nil {
+		switch {
+		case errors.Is(err, errNoMappedRole):
+			logger.LogAuditEvent("login_failed", "User", "", map[string]interface{}{
+				"email":     identity.Email,
+				"client_ip": c.ClientIP(),
+				"method":    "oidc",
+				"reason":    "no_mapped_role",
+				"groups":    identity.Groups,
+			})
+			respondError(c, http.StatusForbidden, ErrorResponse{
+				Error:   "No role for the user",
+				Message: "none of the user's identity provider groups is mapped to a role",
+				Code:    "NO_MAPPED_ROLE",
+			})
+		case errors.Is(err, errUnverifiedEmail):
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error:   "User with this email already exists",
+				Message: err.Error(),
+				Code:    "USER_ALREADY_EXISTS",
+			})
+		default:
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to sign in user",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+		}
+		return
+	}
+	if !user.Active {
+		logger.LogAuditEvent("login_failed", "User", user.ID, map[string]interface{}{
+			"client_ip": c.ClientIP(),
+			"method":    "oidc",
+			"reason":    "inactive_user",
+		})
+		respondError(c, http.StatusUnauthorized, ErrorResponse{
+			Error: "User not found or inactive",
+			Code:  "USER_INACTIVE",
+		})
+		return
+	}
+
+	response, ok := h.startSession(c, user)
+	if !ok {
+		return
+	}
+
+	logger.LogAuditEvent("login", "User", user.ID, map[string]interface{}{
+		"client_ip": c.ClientIP(),
+		"method":    "oidc",
+		"issuer":    identity.Issuer,
+		"created":   created,
+	})
+
+	c.JSON(http.StatusOK, response)
+}
+
+// requireOIDC responds with 404 when single sign-on is not configured
+func (h *AuthHandler) requireOIDC(c *gin.Context) bool {
+	if h.oidc == nil {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error:   "Single sign-on is not configured",
+			Message: "log in with email and password at POST /api/v1/auth/login",
+			Code:    "SSO_NOT_CONFIGURED",
+		})
+		return false
+	}
+	return true
+}
+
+// oidcUser returns the user an identity provider signed in, with their roles. On
+// their first login they are linked to the user with their email, or created with
+// the roles of their groups. It reports whether the user was created.
+func (h *AuthHandler) oidcUser(identity *auth.OIDCIdentity) (*models.User, bool, error) {
+	var user models.User
+	created := false
+	now := time.Now()
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var link models.UserIdentity
+		err := tx.Where("issuer = ? AND subject = ?", identity.Issuer, identity.Subject).First(&link).Error
+		switch {
+		case err == nil:
+			if err := tx.Where("id = ?", link.UserID).First(&user).Error; err != nil {
+				return err
+			}
+		case errors.Is(err, gorm.ErrRecordNotFound):
+			err := tx.Where("email = ?", identity.Email).First(&user).Error
+			switch {
+			case err == nil:
+				// Whoever controls an unverified address at the provider must not take
+				// over the local user
+				if identity.Unverified {
+					return errUnverifiedEmail
+				}
+			case errors.Is(err, gorm.ErrRecordNotFound):
+				if err := h.createOIDCUser(tx, identity, &user); err != nil {
+					return err
+				}
+				created = true
+			default:
+				return err
+			}
+			link = models.UserIdentity{UserID: user.ID, Issuer: identity.Issuer, Subject: identity.Subject}
+			if err := tx.Create(&link).Error; err != nil {
+				return err
+			}
+		default:
+			return err
+		}
+
+		link.Groups = identity.Groups
+		link.LastLogin = &now
+		if err := tx.Model(&link).Select("groups", "last_login").Updates(&link).Error; err != nil {
+			return err
+		}
+		user.LastLogin = &now
+		return tx.Model(&user).Update("last_login", now).Error
+	})
+	if err != nil {
+		return nil, false, err
+	}
+
+	if err := h.db.Preload("Roles").Where("id = ?", user.ID).First(&user).Error; err != nil {
+		return nil, false, err
+	}
+	return &user, created, nil
+}
+
+// createOIDCUser creates a user signing in for the first time with the roles mapped
+// to their groups. They have no password, so they only sign in at the provider.
+func (h *AuthHandler) createOIDCUser(tx *gorm.DB, identity *auth.OIDCIdentity, user *models.User) error {
+	var roles []models.Role
+	if names := h.oidc.Roles(identity.Groups); len(names) > 0 {
+		if err := tx.Where("name IN ?", names).Find(&roles).Error; err != nil {
+			return err
+		}
+		if len(roles) < len(names) {
+			logger.Warn("Ignoring single sign-on roles that do not exist", zap.Strings("roles", names))
+		}
+	}
+	if len(roles) == 0 {
+		return errNoMappedRole
+	}
+
+	firstName, lastName := identity.FirstName, identity.LastName
+	if firstName == "" && lastName == "" {
+		firstName, _, _ = strings.Cut(identity.Email, "@")
+	}
+	*user = models.User{
+		Email:     identity.Email,
+		FirstName: firstName,
+		LastName:  lastName,
+		Active:    true,
+		CreatedBy: "oidc",
+	}
+	if err := tx.Create(user).Error; err != nil {
+		return err
+	}
+	for _, role := range roles {
+		assignment := models.UserRole{UserID: user.ID, RoleID: role.ID, GrantedBy: "oidc", GrantedAt: time.Now()}
+		if err := tx.Create(&assignment).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,385 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/backup"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"gorm.io/gorm"
+)
+
+// BackupHandler handles database backups and restores into the staging database
+type BackupHandler struct {
+	db      *gorm.DB
+	storage storage.Storage
+	runner  *backup.Runner
+}
+
+// NewBackupHandler creates a new backup handler
+func NewBackupHandler(db *gorm.DB, store storage.Storage, runner *backup.Runner) *BackupHandler {
+	return &BackupHandler{
+		db:      db,
+		storage: store,
+		runner:  runner,
+	}
+}
+
+// CreateBackup queues a logical backup of the database
+// @Summary Create backup
+// @Description Queue a pg_dump backup of the database to object storage. Only one backup runs at a time (admin only)
+// @Tags admin
+// @Produce json
+// @Success 202 {object} models.BackupJob
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/backups [post]
+func (h *BackupHandler) CreateBackup(c *gin.Context) {
+	if err := h.runner.Supported(); err != nil {
+		respondError(c, http.StatusNotImplemented, ErrorResponse{
+			Error:   "Backups are not available",
+			Message: err.Error(),
+			Code:    "BACKUP_UNSUPPORTED",
+		})
+		return
+	}
+
+	var pending int64
+	if err := h.db.Model(&models.BackupJob{}).
+		Where("status IN ?", []string{models.BackupJobQueued, models.BackupJobRunning}).
+		Count(&pending).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check pending backups",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if pending > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "A backup is already in progress",
+			Code:  "BACKUP_IN_PROGRESS",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	job := models.BackupJob{
+		Status:    models.BackupJobQueued,
+		CreatedBy: userID,
+	}
+	if err := h.db.Create(&job).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create backup job",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("backup", "Database", userID, map[string]interface{}{
+		"job_id": job.ID,
+	})
+
+	h.runner.Wake()
+	c.JSON(http.StatusAccepted, job)
+}
+
+// GetBackups lists backups
+// @Summary Get backups
+// @Description List backups, newest first (admin only)
+// @Tags admin
+// @Produce json
+// @Param status query string false "Filter by status (queued, running, completed, failed)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.BackupJob}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/backups [get]
+func (h *BackupHandler) GetBackups(c *gin.Context) {
+	var backups []models.BackupJob
+	h.list(c, h.db.Model(&models.BackupJob{}), &backups, "backups")
+}
+
+// GetBackup retrieves a backup
+// @Summary Get backup
+// @Description Get the status, size and checksum of a backup (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Backup ID"
+// @Success 200 {object} models.BackupJob
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/backups/{id} [get]
+func (h *BackupHandler) GetBackup(c *gin.Context) {
+	var job models.BackupJob
+	if !h.findBackup(c, &job) {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DeleteBackup deletes a finished backup and its dump file
+// @Summary Delete backup
+// @Description Delete a completed or failed backup and remove its dump from storage (admin only)
+// @Tags admin
+// @Param id path string true "Backup ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/backups/{id} [delete]
+func (h *BackupHandler) DeleteBackup(c *gin.Context) {
+	var job models.BackupJob
+	if !h.findBackup(c, &job) {
+		return
+	}
+
+	if job.Status == models.BackupJobQueued || job.Status == models.BackupJobRunning {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Backup is still in progress",
+			Code:  "BACKUP_IN_PROGRESS",
+		})
+		return
+	}
+
+	var restoring int64
+	if err := h.db.Model(&models.RestoreJob{}).
+		Where("backup_id = ? AND status IN ?", job.ID, []string{models.BackupJobQueued, models.BackupJobRunning}).
+		Count(&restoring).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check pending restores",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if restoring > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Backup is being restored",
+			Code:  "RESTORE_IN_PROGRESS",
+		})
+		return
+	}
+
+	if job.StorageKey != "" {
+		if err := h.storage.Delete(job.StorageKey); err != nil && err != storage.ErrNotFound {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to delete backup file",
+				Message: err.Error(),
+				Code:    "STORAGE_ERROR",
+			})
+			return
+		}
+	}
+
+	if err := h.db.Delete(&job).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete backup",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "Backup", userID, map[string]interface{}{
+		"job_id": job.ID,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// RestoreBackup queues a restore of a backup into the staging database
+// @Summary Restore backup to staging
+// @Description Queue a restore of a completed backup into the configured staging database, replacing its contents. The primary database is never a restore target (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Backup ID"
+// @Success 202 {object} models.RestoreJob
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/backups/{id}/restore [post]
+func (h *BackupHandler) RestoreBackup(c *gin.Context) {
+	target, err := h.runner.RestoreTarget()
+	if err != nil {
+		respondError(c, http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Restores are not enabled",
+			Message: err.Error(),
+			Code:    "RESTORE_DISABLED",
+		})
+		return
+	}
+
+	var job models.BackupJob
+	if !h.findBackup(c, &job) {
+		return
+	}
+
+	if job.Status != models.BackupJobCompleted {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Only completed backups can be restored",
+			Code:  "BACKUP_NOT_READY",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	restore := models.RestoreJob{
+		BackupID:  job.ID,
+		Target:    target,
+		Status:    models.BackupJobQueued,
+		CreatedBy: userID,
+	}
+	if err := h.db.Create(&restore).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create restore job",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("restore", "Database", userID, map[string]interface{}{
+		"job_id":    restore.ID,
+		"backup_id": job.ID,
+		"target":    target,
+	})
+
+	h.runner.Wake()
+	c.JSON(http.StatusAccepted, restore)
+}
+
+// GetRestores lists restore jobs
+// @Summary Get restores
+// @Description List restores into the staging database, newest first (admin only)
+// @Tags admin
+// @Produce json
+// @Param status query string false "Filter by status (queued, running, completed, failed)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.RestoreJob}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/restores [get]
+func (h *BackupHandler) GetRestores(c *gin.Context) {
+	var restores []models.RestoreJob
+	h.list(c, h.db.Model(&models.RestoreJob{}), &restores, "restores")
+}
+
+// GetRestore retrieves a restore job
+// @Summary Get restore
+// @Description Get the status of a restore and, once completed, the row counts of the restored tables (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Restore ID"
+// @Success 200 {object} models.RestoreJob
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/restores/{id} [get]
+func (h *BackupHandler) GetRestore(c *gin.Context) {
+	var restore models.RestoreJob
+	if err := h.db.Where("id = ?", c.Param("id")).First(&restore).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Restore not found",
+				Code:  "RESTORE_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch restore",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, restore)
+}
+
+// list writes a page of backup or restore jobs, newest first
+func (h *BackupHandler) list(c *gin.Context, query *gorm.DB, dest interface{}, name string) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count " + name,
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(dest).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch " + name,
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, dest, total, page, limit)
+}
+
+// findBackup loads the backup named by the id path parameter and writes the error
+// response when it cannot be found
+func (h *BackupHandler) findBackup(c *gin.Context, job *models.BackupJob) bool {
+	if err := h.db.Where("id = ?", c.Param("id")).First(job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Backup not found",
+				Code:  "BACKUP_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch backup",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
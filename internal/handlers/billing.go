@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"bytes"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/billing"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Claims export formats
+const (
+	claimFormatJSON = "json"
+	claimFormatX12  = "x12"
+)
+
+// maxClaimPeriod bounds the period one claims extraction may cover
+const maxClaimPeriod = 31 * 24 * time.Hour
+
+// maxFeesPerRequest bounds how many fees one request may load
+const maxFeesPerRequest = 1000
+
+// BillingHandler handles HTTP requests for claims extraction and the fee schedule
+type BillingHandler struct {
+	db        *gorm.DB
+	assembler *billing.Assembler
+	submitter billing.Submitter
+	validator *validator.Validate
+}
+
+// NewBillingHandler creates a new billing handler
+func NewBillingHandler(db *gorm.DB, assembler *billing.Assembler, submitter billing.Submitter) *BillingHandler {
+	return &BillingHandler{
+		db:        db,
+		assembler: assembler,
+		submitter: submitter,
+		validator: validator.New(),
+	}
+}
+
+// ClaimsResponse is the outcome of a claims extraction
+type ClaimsResponse struct {
+	Claims     []billing.Claim `json:"claims"`
+	Incomplete int             `json:"incomplete"` // Claims with issues to resolve before submission
+}
+
+// ExtractClaims assembles claims from performed services
+// @Summary Extract claims
+// @Description Assemble the services of an encounter, or of a period of at most 31 days, into claims: one per encounter, and one per patient and day for services recorded without an encounter. Diagnoses are billed from the conditions the services address and services from their CPT or HCPCS codes, charged from the fee schedule. Claims list the issues that keep them from being submitted. With format=x12 the claims are returned as an X12 837P file for clearinghouse submission, which requires a payer and claims without issues (admin only).
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Produce application/edi-x12
+// @Param format query string false "json (default) or x12"
+// @Param request body models.ClaimRequest true "Services to bill"
+// @Success 200 {object} ClaimsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/claims [post]
+func (h *BillingHandler) ExtractClaims(c *gin.Context) {
+	format := c.DefaultQuery("format", claimFormatJSON)
+	if format != claimFormatJSON && format != claimFormatX12 {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid format",
+			Message: "format must be json or x12",
+			Code:    "INVALID_FORMAT",
+		})
+		return
+	}
+
+	var req models.ClaimRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var q billing.Query
+	if req.Subject != nil && req.Subject.Reference != "" {
+		q.PatientID = strings.TrimPrefix(req.Subject.Reference, "Patient/")
+		var patient models.Patient
+		if err := readDB(c, h.db).Select("id").Where("id = ?", q.PatientID).First(&patient).Error; err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Patient not found",
+				Message: "The referenced patient does not exist",
+				Code:    "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+	}
+	if req.Encounter != nil && req.Encounter.Reference != "" {
+		q.Encounter = req.Encounter.Reference
+	} else {
+		if req.Start == nil || req.End == nil || !req.End.After(*req.Start) || req.End.Sub(*req.Start) > maxClaimPeriod {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid period",
+				Message: "an encounter, or a start before an end at most 31 days later, is required",
+				Code:    "INVALID_PERIOD",
+			})
+			return
+		}
+		q.Start, q.End = *req.Start, *req.End
+	}
+
+	if format == claimFormatX12 && req.Payer == nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "a payer is required for X12 exports",
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	claims, err := h.assembler.Assemble(c.Request.Context(), q)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to assemble claims",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	incomplete := make(map[string]string)
+	for _, claim := range claims {
+		if len(claim.Issues) > 0 {
+			incomplete[claim.ID] = strings.Join(claim.Issues, "; ")
+		}
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("export", "Claim", userID, map[string]interface{}{
+		"format":     format,
+		"claims":     len(claims),
+		"patient_id": q.PatientID,
+		"encounter":  q.Encounter,
+	})
+
+	if format == claimFormatJSON {
+		c.JSON(http.StatusOK, ClaimsResponse{Claims: claims, Incomplete: len(incomplete)})
+		return
+	}
+
+	if len(claims) == 0 {
+		respondError(c, http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "No claims to export",
+			Message: "no billable services were performed in the selection",
+			Code:    "NO_CLAIMS",
+		})
+		return
+	}
+	if len(incomplete) > 0 {
+		respondError(c, http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "Claims are incomplete",
+			Message: strconv.Itoa(len(incomplete)) + " claims have issues to resolve before submission",
+			Code:    "CLAIMS_INCOMPLETE",
+			Details: incomplete,
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	var buf bytes.Buffer
+	if err := billing.WriteX12(&buf, claims, h.submitter, *req.Payer, int(now.Unix()), now); err != nil {
+		if err == billing.ErrSubmitterNotConfigured {
+			respondError(c, http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "Billing is not configured",
+				Message: err.Error(),
+				Code:    "BILLING_NOT_CONFIGURED",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to write claims",
+			Message: err.Error(),
+			Code:    "EXPORT_ERROR",
+		})
+		return
+	}
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{
+		"filename": "claims-" + now.Format("20060102T150405") + ".x12",
+	}))
+	c.Data(http.StatusOK, "application/edi-x12", buf.Bytes())
+}
+
+// GetFees lists the fee schedule
+// @Summary Get fee schedule
+// @Description List the charges billed per unit of CPT and HCPCS services (admin only)
+// @Tags billing
+// @Produce json
+// @Param code query string false "Filter by code"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.FeeScheduleEntry}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/fees [get]
+func (h *BillingHandler) GetFees(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.FeeScheduleEntry{})
+	if code := strings.TrimSpace(c.Query("code")); code != "" {
+		query = query.Where("code = ?", code)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count fees",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var fees []models.FeeScheduleEntry
+	if err := query.Order("code_system, code").Offset((page - 1) * limit).Limit(limit).Find(&fees).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch fees",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, fees, total, page, limit)
+}
+
+// PutFees creates or replaces fee schedule entries
+// @Summary Load fee schedule
+// @Description Create or replace the charges of up to 1000 services, matched on code system and code (admin only)
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param fees body []models.FeeRequest true "Fees"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/fees [post]
+func (h *BillingHandler) PutFees(c *gin.Context) {
+	var reqs []models.FeeRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxFeesPerRequest {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "between 1 and " + strconv.Itoa(maxFeesPerRequest) + " fees are required",
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	// A code given twice keeps its last fee; one statement cannot update a row twice
+	fees := make([]models.FeeScheduleEntry, 0, len(reqs))
+	codes := make(map[string]int, len(reqs))
+	for i, req := range reqs {
+		if err := h.validator.Struct(req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: "fee " + strconv.Itoa(i) + ": " + err.Error(),
+				Code:    "VALIDATION_FAILED",
+			})
+			return
+		}
+		fee := models.FeeScheduleEntry{System: req.System, Code: req.Code, Display: req.Display, Amount: req.Amount}
+		key := req.System + "|" + req.Code
+		if j, ok := codes[key]; ok {
+			fees[j] = fee
+			continue
+		}
+		codes[key] = len(fees)
+		fees = append(fees, fee)
+	}
+
+	err := h.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "code_system"}, {Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{"display", "amount", "updated_at"}),
+	}).CreateInBatches(&fees, 200).Error
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to save fees",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "FeeSchedule", userID, map[string]interface{}{
+		"fees": len(fees),
+	})
+
+	c.JSON(http.StatusOK, NewSuccessResponse("Fees saved", map[string]int{"saved": len(fees)}))
+}
@@ -0,0 +1,397 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// bulkPreviewTTL is how long a preview can be executed before it must be previewed again
+const bulkPreviewTTL = 15 * time.Minute
+
+// bulkSampleSize is how many affected IDs a preview lists
+const bulkSampleSize = 10
+
+// BulkHandler handles previewed bulk updates and deletes of observations
+type BulkHandler struct {
+	db         *gorm.DB
+	validator  *validator.Validate
+	categories *terminology.CategoryService
+	runner     *operation.Runner
+}
+
+// NewBulkHandler creates a new bulk handler
+func NewBulkHandler(db *gorm.DB, categories *terminology.CategoryService, runner *operation.Runner) *BulkHandler {
+	return &BulkHandler{
+		db:         db,
+		validator:  validator.New(),
+		categories: categories,
+		runner:     runner,
+	}
+}
+
+// PreviewBulkUpdate previews a filtered bulk update of observations
+// @Summary Preview bulk observation update
+// @Description Count and sample the observations a filter matches and create a bulk update job that can be executed within 15 minutes (admin only)
+// @Tags bulk
+// @Accept json
+// @Produce json
+// @Param request body models.BulkJobRequest true "Filter and patch"
+// @Success 200 {object} models.BulkJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/observations/_bulk-update [post]
+func (h *BulkHandler) PreviewBulkUpdate(c *gin.Context) {
+	var req models.BulkJobRequest
+	if !h.bindRequest(c, &req) {
+		return
+	}
+
+	if req.Patch == nil || len(req.Patch.Columns()) == 0 {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Patch must set at least one field",
+			Code:  "EMPTY_PATCH",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req.Patch); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	if req.Patch.Category != nil {
+		if err := h.categories.Validate(req.Patch.Category); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid observation category",
+				Message: err.Error(),
+				Code:    "INVALID_CATEGORY",
+			})
+			return
+		}
+	}
+
+	h.createPreview(c, models.BulkOperationUpdate, req)
+}
+
+// PreviewBulkDelete previews a filtered bulk delete of observations
+// @Summary Preview bulk observation delete
+// @Description Count and sample the observations a filter matches and create a bulk delete job that can be executed within 15 minutes (admin only)
+// @Tags bulk
+// @Accept json
+// @Produce json
+// @Param request body models.BulkJobRequest true "Filter"
+// @Success 200 {object} models.BulkJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/observations/_bulk-delete [post]
+func (h *BulkHandler) PreviewBulkDelete(c *gin.Context) {
+	var req models.BulkJobRequest
+	if !h.bindRequest(c, &req) {
+		return
+	}
+
+	if req.Patch != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "A bulk delete does not take a patch",
+			Code:  "UNEXPECTED_PATCH",
+		})
+		return
+	}
+
+	h.createPreview(c, models.BulkOperationDelete, req)
+}
+
+// ExecuteBulkJob queues a previewed bulk job
+// @Summary Execute bulk job
+// @Description Queue a previewed bulk job for background execution as the operation in Location, which can be polled and cancelled. The preview is rejected if it has expired or the filter now matches a different number of observations (admin only).
+// @Tags bulk
+// @Produce json
+// @Param id path string true "Bulk job ID"
+// @Success 202 {object} models.BulkJob
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/bulk-jobs/{id}/execute [post]
+func (h *BulkHandler) ExecuteBulkJob(c *gin.Context) {
+	var job models.BulkJob
+	if !h.findJob(c, &job) {
+		return
+	}
+
+	if job.Status != models.BulkJobPreview {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Bulk job has already been executed",
+			Code:  "JOB_ALREADY_EXECUTED",
+		})
+		return
+	}
+
+	if time.Now().After(job.ExpiresAt) {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Bulk job preview has expired",
+			Code:  "PREVIEW_EXPIRED",
+		})
+		return
+	}
+
+	var affected int64
+	if err := job.Filter.Apply(h.db.Model(&models.Observation{})).Count(&affected).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if affected != job.Affected {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Matching observations changed since the preview",
+			Message: "preview matched " + strconv.FormatInt(job.Affected, 10) + ", filter now matches " + strconv.FormatInt(affected, 10),
+			Code:    "PREVIEW_STALE",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	var op *models.Operation
+	executed := false
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&job).Where("status = ?", models.BulkJobPreview).
+			Updates(map[string]interface{}{"status": models.BulkJobQueued, "executed_by": userID})
+		if result.Error != nil {
+			return result.Error
+		}
+		if executed = result.RowsAffected == 0; executed {
+			return nil
+		}
+		var err error
+		if op, err = h.runner.Queue(tx, models.OperationKindBulk, "BulkJob/"+job.ID, userID); err != nil {
+			return err
+		}
+		return tx.Model(&job).Update("operation_id", op.ID).Error
+	})
+	if executed {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Bulk job has already been executed",
+			Code:  "JOB_ALREADY_EXECUTED",
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to queue bulk job",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	job.Status = models.BulkJobQueued
+	job.ExecutedBy = userID
+	job.OperationID = op.ID
+
+	logger.LogAuditEvent("bulk_"+job.Operation+"_execute", job.ResourceType, userID, map[string]interface{}{
+		"job_id":   job.ID,
+		"affected": job.Affected,
+	})
+
+	h.runner.Wake(models.OperationKindBulk)
+	acceptOperation(c, op, job)
+}
+
+// GetBulkJob retrieves a bulk job and its progress
+// @Summary Get bulk job
+// @Description Get a bulk job with its status and progress (admin only)
+// @Tags bulk
+// @Produce json
+// @Param id path string true "Bulk job ID"
+// @Success 200 {object} models.BulkJob
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/bulk-jobs/{id} [get]
+func (h *BulkHandler) GetBulkJob(c *gin.Context) {
+	var job models.BulkJob
+	if !h.findJob(c, &job) {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// GetBulkJobItems lists the audit records of a bulk job
+// @Summary Get bulk job audit records
+// @Description List the resources a bulk job changed, with their previous values (admin only)
+// @Tags bulk
+// @Produce json
+// @Param id path string true "Bulk job ID"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.BulkJobItem}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/bulk-jobs/{id}/items [get]
+func (h *BulkHandler) GetBulkJobItems(c *gin.Context) {
+	var job models.BulkJob
+	if !h.findJob(c, &job) {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := h.db.Model(&models.BulkJobItem{}).Where("job_id = ?", job.ID)
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count bulk job items",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var items []models.BulkJobItem
+	if err := query.Order("id ASC").Offset((page - 1) * limit).Limit(limit).Find(&items).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch bulk job items",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, items, total, page, limit)
+}
+
+// bindRequest binds a bulk request and rejects filters that would match every observation
+func (h *BulkHandler) bindRequest(c *gin.Context, req *models.BulkJobRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	if req.Filter.IsEmpty() {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Filter must have at least one criterion",
+			Code:  "EMPTY_FILTER",
+		})
+		return false
+	}
+	return checkObservationFilter(c, req.Filter)
+}
+
+// createPreview counts and samples the matching observations and stores the job as a preview
+func (h *BulkHandler) createPreview(c *gin.Context, operation string, req models.BulkJobRequest) {
+	query := req.Filter.Apply(h.db.Model(&models.Observation{}))
+
+	var affected int64
+	if err := query.Count(&affected).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var sample []string
+	if err := query.Order("id ASC").Limit(bulkSampleSize).Pluck("id", &sample).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	job := models.BulkJob{
+		ResourceType: "Observation",
+		Operation:    operation,
+		Filter:       req.Filter,
+		Patch:        req.Patch,
+		Status:       models.BulkJobPreview,
+		Affected:     affected,
+		Sample:       sample,
+		ExpiresAt:    time.Now().Add(bulkPreviewTTL),
+		CreatedBy:    userID,
+	}
+
+	if err := h.db.Create(&job).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create bulk job",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("bulk_"+operation+"_preview", job.ResourceType, userID, map[string]interface{}{
+		"job_id":   job.ID,
+		"affected": affected,
+	})
+
+	c.JSON(http.StatusOK, job)
+}
+
+// findJob loads the bulk job named by the id path parameter and writes the error
+// response when it cannot be found
+func (h *BulkHandler) findJob(c *gin.Context, job *models.BulkJob) bool {
+	if err := h.db.Where("id = ?", c.Param("id")).First(job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Bulk job not found",
+				Code:  "BULK_JOB_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch bulk job",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
+)
+
+// bundleStream writes a searchset Bundle a batch of entries at a time, flushing each
+// batch, so the response is sent chunked and never held in memory whole. The fields
+// come in the order of fhir.Bundle, so a streamed Bundle reads like a rendered one.
+type bundleStream struct {
+	c       *gin.Context
+	buf     []byte
+	written int64 // Entries written so far
+}
+
+// startBundleStream sends the status, headers and opening of a Bundle of total entries
+func startBundleStream(c *gin.Context, total int64) *bundleStream {
+	c.Header("Content-Type", fhir.MediaType+"; charset=utf-8")
+	c.Status(http.StatusOK)
+
+	s := &bundleStream{c: c}
+	s.buf = append(s.buf, `{"resourceType":"Bundle","type":"searchset","total":`...)
+	s.buf = strconv.AppendInt(s.buf, total, 10)
+	s.buf = append(s.buf, `,"entry":[`...)
+	return s
+}
+
+// write sends a batch of entries. It blocks while the client falls behind, which
+// holds up whoever reads the next batch.
+func (s *bundleStream) write(entries []fhir.BundleEntry) error {
+	for i := range entries {
+		encoded, err := json.Marshal(&entries[i])
+		if err != nil {
+			return err
+		}
+		if s.written > 0 {
+			s.buf = append(s.buf, ',')
+		}
+		s.buf = append(s.buf, encoded...)
+		s.written++
+	}
+	return s.flush()
+}
+
+// finish closes the Bundle
+func (s *bundleStream) finish() error {
+	s.buf = append(s.buf, "]}"...)
+	return s.flush()
+}
+
+func (s *bundleStream) flush() error {
+	if _, err := s.c.Writer.Write(s.buf); err != nil {
+		return err
+	}
+	s.buf = s.buf[:0]
+	s.c.Writer.Flush()
+	return nil
+}
@@ -0,0 +1,428 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/capture"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// CaptureHandler manages the sessions recording a user's requests for debugging
+type CaptureHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	recorder  *capture.Recorder
+}
+
+// NewCaptureHandler creates a new capture handler
+func NewCaptureHandler(db *gorm.DB, recorder *capture.Recorder) *CaptureHandler {
+	return &CaptureHandler{
+		db:        db,
+		validator: validator.New(),
+		recorder:  recorder,
+	}
+}
+
+// StartCapture starts recording a user's requests
+// @Summary Start capture session
+// @Description Record the requests and responses of a user, e.g. the service account of a lab interface, for a limited time. Names, contact details, identifiers, free text and credentials are redacted, as are the extra fields named; bodies that cannot be redacted field by field are withheld. Every instance records within 15 seconds (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param session body models.CaptureSessionRequest true "Capture session"
+// @Success 201 {object} models.CaptureSession
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/captures [post]
+func (h *CaptureHandler) StartCapture(c *gin.Context) {
+	var req models.CaptureSessionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var users int64
+	if err := h.db.Model(&models.User{}).Where("id = ?", req.UserID).Count(&users).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch user",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if users == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "User not found",
+			Code:  "USER_NOT_FOUND",
+		})
+		return
+	}
+
+	now := time.Now()
+	var running int64
+	if err := h.db.Model(&models.CaptureSession{}).
+		Where("user_id = ? AND stopped_at IS NULL AND expires_at > ?", req.UserID, now).
+		Count(&running).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check capture sessions",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if running > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "The user's requests are already being captured",
+			Code:  "CAPTURE_ACTIVE",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	session := models.CaptureSession{
+		UserID:       req.UserID,
+		Reason:       req.Reason,
+		RedactFields: req.RedactFields,
+		MaxExchanges: req.MaxExchanges,
+		ExpiresAt:    now.Add(time.Duration(req.DurationMinutes) * time.Minute),
+		CreatedBy:    userID,
+	}
+	if session.MaxExchanges == 0 {
+		session.MaxExchanges = capture.DefaultMaxExchanges
+	}
+	if err := h.db.Create(&session).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create capture session",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("capture_start", "CaptureSession", userID, map[string]interface{}{
+		"session_id":     session.ID,
+		"target_user_id": session.UserID,
+		"expires_at":     session.ExpiresAt,
+		"reason":         session.Reason,
+	})
+
+	h.reload(c)
+	c.JSON(http.StatusCreated, session)
+}
+
+// GetCaptures lists capture sessions
+// @Summary Get capture sessions
+// @Description List capture sessions, newest first (admin only)
+// @Tags admin
+// @Produce json
+// @Param userId query string false "Filter by captured user"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.CaptureSession}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/captures [get]
+func (h *CaptureHandler) GetCaptures(c *gin.Context) {
+	page, limit := capturePage(c)
+
+	query := h.db.Model(&models.CaptureSession{})
+	if userID := c.Query("userId"); userID != "" {
+		query = query.Where("user_id = ?", userID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count capture sessions",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var sessions []models.CaptureSession
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&sessions).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch capture sessions",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if err := h.countExchanges(sessions); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count captured exchanges",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, sessions, total, page, limit)
+}
+
+// GetCapture retrieves a capture session
+// @Summary Get capture session
+// @Description Get a capture session and the number of exchanges it recorded (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Capture session ID"
+// @Success 200 {object} models.CaptureSession
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/captures/{id} [get]
+func (h *CaptureHandler) GetCapture(c *gin.Context) {
+	var session models.CaptureSession
+	if !h.findSession(c, &session) {
+		return
+	}
+
+	c.JSON(http.StatusOK, session)
+}
+
+// StopCapture stops a capture session
+// @Summary Stop capture session
+// @Description Stop recording before the session expires. The recorded exchanges stay available until the retention passes (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Capture session ID"
+// @Success 200 {object} models.CaptureSession
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/captures/{id}/stop [post]
+func (h *CaptureHandler) StopCapture(c *gin.Context) {
+	var session models.CaptureSession
+	if !h.findSession(c, &session) {
+		return
+	}
+	now := time.Now()
+	if !session.Active(now) {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Capture session has already ended",
+			Code:  "CAPTURE_ENDED",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	if err := h.db.Model(&session).Updates(map[string]interface{}{
+		"stopped_at": now,
+		"stopped_by": userID,
+	}).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to stop capture session",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	session.StoppedAt = &now
+	session.StoppedBy = userID
+
+	logger.LogAuditEvent("capture_stop", "CaptureSession", userID, map[string]interface{}{
+		"session_id":     session.ID,
+		"target_user_id": session.UserID,
+	})
+
+	h.reload(c)
+	c.JSON(http.StatusOK, session)
+}
+
+// DeleteCapture deletes a capture session and its exchanges
+// @Summary Delete capture session
+// @Description Delete a capture session and the exchanges it recorded, stopping it when still active (admin only)
+// @Tags admin
+// @Param id path string true "Capture session ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/captures/{id} [delete]
+func (h *CaptureHandler) DeleteCapture(c *gin.Context) {
+	var session models.CaptureSession
+	if !h.findSession(c, &session) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("session_id = ?", session.ID).Delete(&models.CapturedExchange{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&session).Error
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete capture session",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "CaptureSession", userID, map[string]interface{}{
+		"session_id":     session.ID,
+		"target_user_id": session.UserID,
+	})
+
+	h.reload(c)
+	c.Status(http.StatusNoContent)
+}
+
+// GetCapturedExchanges lists the exchanges a session recorded
+// @Summary Get captured exchanges
+// @Description List the requests and responses recorded by a capture session in the order they were served, with PHI redacted. Bodies are recorded up to 64 KiB (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Capture session ID"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.CapturedExchange}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/captures/{id}/exchanges [get]
+func (h *CaptureHandler) GetCapturedExchanges(c *gin.Context) {
+	var session models.CaptureSession
+	if !h.findSession(c, &session) {
+		return
+	}
+	page, limit := capturePage(c)
+
+	var exchanges []models.CapturedExchange
+	if err := h.db.Where("session_id = ?", session.ID).Order("id").
+		Offset((page - 1) * limit).Limit(limit).Find(&exchanges).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch captured exchanges",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("capture_read", "CaptureSession", userID, map[string]interface{}{
+		"session_id":     session.ID,
+		"target_user_id": session.UserID,
+		"page":           page,
+	})
+
+	respondPage(c, exchanges, session.Exchanges, page, limit)
+}
+
+// findSession loads the capture session named by the id path parameter with its
+// exchange count and writes the error response when it cannot be found
+func (h *CaptureHandler) findSession(c *gin.Context, session *models.CaptureSession) bool {
+	if err := h.db.Where("id = ?", c.Param("id")).First(session).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Capture session not found",
+				Code:  "CAPTURE_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch capture session",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	if err := h.db.Model(&models.CapturedExchange{}).Where("session_id = ?", session.ID).
+		Count(&session.Exchanges).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count captured exchanges",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
+
+// countExchanges sets the exchange counts of the sessions
+func (h *CaptureHandler) countExchanges(sessions []models.CaptureSession) error {
+	if len(sessions) == 0 {
+		return nil
+	}
+	ids := make([]string, len(sessions))
+	for i, session := range sessions {
+		ids[i] = session.ID
+	}
+	var rows []struct {
+		SessionID string
+		Count     int64
+	}
+	if err := h.db.Model(&models.CapturedExchange{}).Select("session_id, COUNT(*) AS count").
+		Where("session_id IN ?", ids).Group("session_id").Scan(&rows).Error; err != nil {
+		return err
+	}
+	counts := make(map[string]int64, len(rows))
+	for _, row := range rows {
+		counts[row.SessionID] = row.Count
+	}
+	for i := range sessions {
+		sessions[i].Exchanges = counts[sessions[i].ID]
+	}
+	return nil
+}
+
+// reload makes this instance pick up a session change at once; the others do on
+// their next reload
+func (h *CaptureHandler) reload(c *gin.Context) {
+	if err := h.recorder.Reload(c.Request.Context()); err != nil {
+		logger.Warn("Failed to reload capture sessions", zap.Error(err))
+	}
+}
+
+// capturePage reads the page and limit query parameters
+func capturePage(c *gin.Context) (int, int) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	return page, limit
+}
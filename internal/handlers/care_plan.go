@@ -0,0 +1,688 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// CarePlanHandler handles HTTP requests for care plans and their activities
+type CarePlanHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewCarePlanHandler creates a new care plan handler
+func NewCarePlanHandler(db *gorm.DB) *CarePlanHandler {
+	return &CarePlanHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// CreateCarePlan creates a care plan
+// @Summary Create care plan
+// @Description Create a care plan for a patient with its goals and, optionally, its first activities. The plan is a draft unless created active. Addressed conditions must be the patient's; practitioner performers of activities are resolved as for observations.
+// @Tags care-plans
+// @Accept json
+// @Produce json
+// @Param carePlan body models.CarePlanRequest true "Care plan"
+// @Success 201 {object} models.CarePlan
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/care-plans [post]
+func (h *CarePlanHandler) CreateCarePlan(c *gin.Context) {
+	var req models.CarePlanRequest
+	if !h.bindCarePlan(c, &req) {
+		return
+	}
+
+	h.create(c, strings.TrimPrefix(req.Subject.Reference, "Patient/"), req)
+}
+
+// CreatePatientCarePlan creates a care plan for the patient in the path
+// @Summary Create patient care plan
+// @Description Create a care plan for the patient in the path, as POST /care-plans does. The subject may be left out; one naming another patient is rejected.
+// @Tags care-plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param carePlan body models.CarePlanRequest true "Care plan"
+// @Success 201 {object} models.CarePlan
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/care-plans [post]
+func (h *CarePlanHandler) CreatePatientCarePlan(c *gin.Context) {
+	var req models.CarePlanRequest
+	if !h.bindCarePlan(c, &req) {
+		return
+	}
+
+	patientID := c.Param("id")
+	if req.Subject.Reference != "" && strings.TrimPrefix(req.Subject.Reference, "Patient/") != patientID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "subject must be the patient in the path",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	h.create(c, patientID, req)
+}
+
+// GetCarePlan retrieves a care plan by ID
+// @Summary Get care plan
+// @Description Get a care plan with its goals and activities, oldest activity first
+// @Tags care-plans
+// @Produce json
+// @Param id path string true "Care plan ID"
+// @Success 200 {object} models.CarePlan
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/care-plans/{id} [get]
+func (h *CarePlanHandler) GetCarePlan(c *gin.Context) {
+	var plan models.CarePlan
+	if !findCarePlan(c, readDB(c, h.db), &plan) {
+		return
+	}
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// UpdateCarePlan replaces a care plan
+// @Summary Update care plan
+// @Description Replace the title, description, period, addressed conditions, goals and note of a care plan that has not ended. The subject and activities are kept; activities are added through the activities endpoint and the status is changed through the status endpoint.
+// @Tags care-plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Care plan ID"
+// @Param carePlan body models.CarePlanRequest true "Care plan"
+// @Success 200 {object} models.CarePlan
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/care-plans/{id} [put]
+func (h *CarePlanHandler) UpdateCarePlan(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.CarePlanRequest
+	if !h.bindCarePlan(c, &req) {
+		return
+	}
+
+	var plan models.CarePlan
+	if !findCarePlan(c, db, &plan) {
+		return
+	}
+
+	patientID := strings.TrimPrefix(plan.Subject.Reference, "Patient/")
+	if req.Subject.Reference != "" && strings.TrimPrefix(req.Subject.Reference, "Patient/") != patientID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "the subject of a care plan cannot change",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+	if req.Status != "" && req.Status != plan.Status {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "the status of a care plan is changed through POST /care-plans/{id}/status",
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+	if len(req.Activity) > 0 {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "activities are added through POST /care-plans/{id}/activities",
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+	if plan.Ended() {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Care plan has ended",
+			Message: "the care plan is " + plan.Status,
+			Code:    "CARE_PLAN_ENDED",
+		})
+		return
+	}
+
+	addresses, ok := resolveConditionReferences(c, db, patientID, "addresses", req.Addresses)
+	if !ok {
+		return
+	}
+
+	plan.Title = req.Title
+	plan.Description = req.Description
+	plan.Period = req.Period
+	plan.Addresses = addresses
+	plan.Goal = carePlanGoals(req.Goal)
+	plan.Note = req.Note
+
+	if err := db.Model(&plan).Select("title", "description", "period_start", "period_end", "addresses", "goal",
+		"note", "updated_at").Updates(&plan).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update care plan",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "CarePlan", userID, map[string]interface{}{
+		"care_plan_id": plan.ID,
+		"goals":        len(plan.Goal),
+	})
+
+	c.JSON(http.StatusOK, plan)
+}
+
+// UpdateCarePlanStatus changes the status of a care plan
+// @Summary Update care plan status
+// @Description Activate, hold, resume, complete or revoke a care plan, or mark it entered in error. A draft becomes active or is revoked; an active plan is put on hold, completed or revoked; a plan on hold is resumed or revoked. Completed and revoked plans are final, and their activities that were not completed are cancelled.
+// @Tags care-plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Care plan ID"
+// @Param status body models.CarePlanStatusRequest true "Status"
+// @Success 200 {object} models.CarePlan
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/care-plans/{id}/status [post]
+func (h *CarePlanHandler) UpdateCarePlanStatus(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.CarePlanStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var plan models.CarePlan
+	if !findCarePlan(c, db, &plan) {
+		return
+	}
+	if !models.CarePlanTransitionAllowed(plan.Status, req.Status) {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Invalid status transition",
+			Message: "a " + plan.Status + " care plan cannot become " + req.Status,
+			Code:    "INVALID_STATUS_TRANSITION",
+		})
+		return
+	}
+
+	previous := plan.Status
+	cancelEnded := req.Status == models.CarePlanCompleted || req.Status == models.CarePlanRevoked
+	changed := false
+	err := db.Transaction(func(tx *gorm.DB) error {
+		// Conditional on the status read, so concurrent changes cannot both apply
+		result := tx.Model(&models.CarePlan{}).Where("id = ? AND status = ?", plan.ID, previous).
+			Updates(map[string]interface{}{"status": req.Status, "updated_at": time.Now()})
+		if result.Error != nil {
+			return result.Error
+		}
+		if changed = result.RowsAffected == 0; changed {
+			return nil
+		}
+		if cancelEnded {
+			return tx.Model(&models.CarePlanActivity{}).
+				Where("care_plan_id = ? AND status NOT IN ?", plan.ID, []string{models.ActivityCompleted, models.ActivityCancelled}).
+				Updates(map[string]interface{}{"status": models.ActivityCancelled, "updated_at": time.Now()}).Error
+		}
+		return nil
+	})
+	if changed {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Invalid status transition",
+			Message: "the care plan status changed meanwhile; fetch it and try again",
+			Code:    "INVALID_STATUS_TRANSITION",
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update care plan status",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("status_change", "CarePlan", userID, map[string]interface{}{
+		"care_plan_id": plan.ID,
+		"from":         previous,
+		"to":           req.Status,
+	})
+
+	if !findCarePlan(c, db, &plan) {
+		return
+	}
+	c.JSON(http.StatusOK, plan)
+}
+
+// AddCarePlanActivity adds an activity to a care plan
+// @Summary Add care plan activity
+// @Description Add an activity, such as a referral or a follow-up, to a care plan that has not ended. Practitioner performers must exist and are stored with their name.
+// @Tags care-plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Care plan ID"
+// @Param activity body models.CarePlanActivityRequest true "Activity"
+// @Success 201 {object} models.CarePlanActivity
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/care-plans/{id}/activities [post]
+func (h *CarePlanHandler) AddCarePlanActivity(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.CarePlanActivityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var plan models.CarePlan
+	if !findCarePlan(c, db, &plan) {
+		return
+	}
+	if plan.Ended() {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Care plan has ended",
+			Message: "the care plan is " + plan.Status,
+			Code:    "CARE_PLAN_ENDED",
+		})
+		return
+	}
+
+	activity, ok := newCarePlanActivity(c, db, req)
+	if !ok {
+		return
+	}
+	activity.CarePlanID = plan.ID
+	if err := db.Create(&activity).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to add care plan activity",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "CarePlanActivity", activity.CreatedBy, map[string]interface{}{
+		"care_plan_id": plan.ID,
+		"activity_id":  activity.ID,
+	})
+
+	c.JSON(http.StatusCreated, activity)
+}
+
+// CompleteCarePlanActivity marks an activity of a care plan done
+// @Summary Complete care plan activity
+// @Description Mark an activity of an active care plan completed, with how it went. Activities already completed or cancelled are rejected.
+// @Tags care-plans
+// @Accept json
+// @Produce json
+// @Param id path string true "Care plan ID"
+// @Param activityId path string true "Activity ID"
+// @Param completion body models.CompleteActivityRequest false "Completion"
+// @Success 200 {object} models.CarePlanActivity
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/care-plans/{id}/activities/{activityId}/complete [post]
+func (h *CarePlanHandler) CompleteCarePlanActivity(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.CompleteActivityRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Message: err.Error(),
+				Code:    "INVALID_REQUEST_BODY",
+			})
+			return
+		}
+	}
+
+	var plan models.CarePlan
+	if !findCarePlan(c, db, &plan) {
+		return
+	}
+	var activity *models.CarePlanActivity
+	for i := range plan.Activity {
+		if plan.Activity[i].ID == c.Param("activityId") {
+			activity = &plan.Activity[i]
+		}
+	}
+	if activity == nil {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Care plan activity not found",
+			Code:  "ACTIVITY_NOT_FOUND",
+		})
+		return
+	}
+	if plan.Status != models.CarePlanActive {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Care plan is not active",
+			Message: "activities of a " + plan.Status + " care plan cannot be completed",
+			Code:    "CARE_PLAN_NOT_ACTIVE",
+		})
+		return
+	}
+	if activity.Ended() {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Care plan activity has ended",
+			Message: "the activity is " + activity.Status,
+			Code:    "ACTIVITY_ENDED",
+		})
+		return
+	}
+
+	now := time.Now()
+	completedAt := now.UTC()
+	if req.CompletedAt != nil {
+		completedAt = *req.CompletedAt
+	}
+	userID, _ := auth.GetUserID(c)
+	result := db.Model(&models.CarePlanActivity{}).
+		Where("id = ? AND status NOT IN ?", activity.ID, []string{models.ActivityCompleted, models.ActivityCancelled}).
+		Updates(map[string]interface{}{
+			"status":       models.ActivityCompleted,
+			"outcome":      req.Outcome,
+			"completed_at": completedAt,
+			"completed_by": userID,
+			"updated_at":   now,
+		})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to complete care plan activity",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Care plan activity has ended",
+			Message: "the activity was completed or cancelled meanwhile",
+			Code:    "ACTIVITY_ENDED",
+		})
+		return
+	}
+	activity.Status = models.ActivityCompleted
+	activity.Outcome = req.Outcome
+	activity.CompletedAt = &completedAt
+	activity.CompletedBy = userID
+	activity.UpdatedAt = now
+
+	logger.LogAuditEvent("complete", "CarePlanActivity", userID, map[string]interface{}{
+		"care_plan_id": plan.ID,
+		"activity_id":  activity.ID,
+	})
+
+	c.JSON(http.StatusOK, activity)
+}
+
+// GetPatientCarePlans lists the care plans of a patient
+// @Summary Get patient care plans
+// @Description List the care plans of a patient with their activities, newest first. Plans entered in error are left out unless all is set or they are asked for by status.
+// @Tags care-plans
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param status query string false "Filter by status"
+// @Param all query bool false "Include care plans entered in error"
+// @Success 200 {array} models.CarePlan
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/care-plans [get]
+func (h *CarePlanHandler) GetPatientCarePlans(c *gin.Context) {
+	query := readDB(c, h.db).Where("subject_reference = ?", "Patient/"+c.Param("id"))
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		query = query.Where("status = ?", status)
+	} else if c.Query("all") != "true" {
+		query = query.Where("status <> ?", models.CarePlanEnteredInError)
+	}
+
+	plans := []models.CarePlan{}
+	if err := query.Preload("Activity", orderActivities).Order("created_at DESC").Find(&plans).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch care plans",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, plans)
+}
+
+// bindCarePlan binds and validates a care plan request, writing the error response
+// on failure
+func (h *CarePlanHandler) bindCarePlan(c *gin.Context, req *models.CarePlanRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	if req.Period.Start != nil && req.Period.End != nil && req.Period.End.Before(*req.Period.Start) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "period end must not be before its start",
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	return true
+}
+
+// create creates the care plan of a request for a patient
+func (h *CarePlanHandler) create(c *gin.Context, patientID string, req models.CarePlanRequest) {
+	db := writeDB(c, h.db)
+
+	// Validate that the referenced patient exists
+	var patient models.Patient
+	if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	addresses, ok := resolveConditionReferences(c, db, patientID, "addresses", req.Addresses)
+	if !ok {
+		return
+	}
+
+	plan := models.CarePlan{
+		Subject:     models.Reference{Reference: "Patient/" + patientID, Display: req.Subject.Display},
+		Status:      req.Status,
+		Title:       req.Title,
+		Description: req.Description,
+		Period:      req.Period,
+		Addresses:   addresses,
+		Goal:        carePlanGoals(req.Goal),
+		Note:        req.Note,
+		Activity:    []models.CarePlanActivity{},
+	}
+	for _, activityReq := range req.Activity {
+		activity, ok := newCarePlanActivity(c, db, activityReq)
+		if !ok {
+			return
+		}
+		plan.Activity = append(plan.Activity, activity)
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		plan.CreatedBy = userID
+	}
+
+	// The plan and its activities are created together
+	if err := db.Transaction(func(tx *gorm.DB) error {
+		return tx.Create(&plan).Error
+	}); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create care plan",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "CarePlan", plan.CreatedBy, map[string]interface{}{
+		"care_plan_id": plan.ID,
+		"patient_id":   patientID,
+		"status":       plan.Status,
+		"activities":   len(plan.Activity),
+	})
+
+	c.JSON(http.StatusCreated, plan)
+}
+
+// newCarePlanActivity builds an activity from its request, writing the error response
+// on failure
+func newCarePlanActivity(c *gin.Context, db *gorm.DB, req models.CarePlanActivityRequest) (models.CarePlanActivity, bool) {
+	if len(req.Code.Coding) == 0 && strings.TrimSpace(req.Code.Text) == "" && strings.TrimSpace(req.Description) == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "an activity must have a code or a description",
+			Code:    "VALIDATION_FAILED",
+		})
+		return models.CarePlanActivity{}, false
+	}
+
+	performers, ok := resolvePerformers(c, db, req.Performer)
+	if !ok {
+		return models.CarePlanActivity{}, false
+	}
+
+	activity := models.CarePlanActivity{
+		Code:        req.Code,
+		Description: req.Description,
+		Status:      req.Status,
+		ScheduledAt: req.ScheduledAt,
+		Performer:   performers,
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		activity.CreatedBy = userID
+	}
+	return activity, true
+}
+
+// carePlanGoals returns the goals of a request, active unless another lifecycle
+// status is given
+func carePlanGoals(goals []models.CarePlanGoal) []models.CarePlanGoal {
+	for i := range goals {
+		if goals[i].LifecycleStatus == "" {
+			goals[i].LifecycleStatus = models.GoalActive
+		}
+	}
+	return goals
+}
+
+// orderActivities orders preloaded care plan activities oldest first
+func orderActivities(db *gorm.DB) *gorm.DB {
+	return db.Order("created_at ASC")
+}
+
+// findCarePlan loads the care plan named by the id path parameter with its
+// activities and writes the error response when it cannot be found
+func findCarePlan(c *gin.Context, db *gorm.DB, plan *models.CarePlan) bool {
+	if err := db.Preload("Activity", orderActivities).Where("id = ?", c.Param("id")).First(plan).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Care plan not found",
+				Code:  "CARE_PLAN_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch care plan",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
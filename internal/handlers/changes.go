@@ -0,0 +1,141 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/events"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/cursor"
+	"gorm.io/gorm"
+)
+
+// ChangeHandler serves the change-data-capture feed read by data warehouse pipelines
+type ChangeHandler struct {
+	db      *gorm.DB
+	cursors *cursor.Signer
+}
+
+// NewChangeHandler creates a new change feed handler whose cursors are issued and
+// verified by cursors
+func NewChangeHandler(db *gorm.DB, cursors *cursor.Signer) *ChangeHandler {
+	return &ChangeHandler{db: db, cursors: cursors}
+}
+
+// ChangeFeedResponse is a page of the change feed. Passing NextCursor as since
+// returns the changes that follow this page; the cursor is opaque, expires, and is
+// only accepted from the same user with the same filters.
+type ChangeFeedResponse struct {
+	Data       []models.OutboxEvent `json:"data"`
+	NextCursor string               `json:"nextCursor"`
+	HasMore    bool                 `json:"hasMore"`
+}
+
+// GetChanges lists resource changes after a cursor
+// @Summary Get change feed
+// @Description List created, updated and deleted resource IDs in commit order after the given cursor, so ETL pipelines can sync incrementally. Start without a cursor and pass nextCursor on each following call, by the same user and with the same type and corrected filters; cursors expire, and every page returns a fresh one. Changes that save an amended or corrected result, or retract a released one as entered in error, are marked corrected=true so consumers can re-notify theirs (admin only)
+// @Tags changes
+// @Produce json
+// @Param since query string false "Cursor returned by the previous call (default: start of the feed)"
+// @Param type query string false "Comma-separated resource types (Patient, Observation, MedicationAdministration)"
+// @Param corrected query bool false "Only corrections of results"
+// @Param limit query int false "Changes per page (default: 100, max: 1000)"
+// @Success 200 {object} ChangeFeedResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/changes [get]
+func (h *ChangeHandler) GetChanges(c *gin.Context) {
+	// Cursors are bound to the filters in a canonical form, so listing the same
+	// types in another order keeps the cursor valid
+	var types []string
+	for _, t := range strings.Split(c.Query("type"), ",") {
+		if t = strings.TrimSpace(t); t != "" {
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+	corrected := c.Query("corrected") == "true"
+	userID, _ := auth.GetUserID(c)
+	binding := []string{userID, strings.Join(types, ","), strconv.FormatBool(corrected)}
+
+	var since int64
+	if token := c.Query("since"); token != "" {
+		var err error
+		since, err = h.cursors.Decode(token, binding...)
+		if err == cursor.ErrExpired {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Cursor expired",
+				Message: "resume from a cursor returned more recently, or start again without one",
+				Code:    "CURSOR_EXPIRED",
+			})
+			return
+		}
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid cursor",
+				Message: "cursors are only accepted from the user and with the filters they were returned for",
+				Code:    "INVALID_CURSOR",
+			})
+			return
+		}
+	}
+
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "100"))
+	if limit < 1 || limit > 1000 {
+		limit = 100
+	}
+
+	// Recent events are held back like for outbox consumers, so the cursor never
+	// skips a change whose transaction commits late
+	query := h.db.Model(&models.OutboxEvent{}).
+		Where("sequence > ? AND occurred_at <= ?", since, time.Now().UTC().Add(-events.SettleDelay))
+	if len(types) > 0 {
+		query = query.Where("resource_type IN ?", types)
+	}
+	if corrected {
+		query = query.Where("corrected = ?", true)
+	}
+
+	// One extra row tells whether another page follows
+	var changes []models.OutboxEvent
+	if err := query.Order("sequence ASC").Limit(limit + 1).Find(&changes).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read change feed",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := ChangeFeedResponse{Data: changes}
+	if len(changes) > limit {
+		response.Data = changes[:limit]
+		response.HasMore = true
+	}
+	position := since
+	if len(response.Data) > 0 {
+		position = response.Data[len(response.Data)-1].Sequence
+	} else {
+		response.Data = []models.OutboxEvent{}
+	}
+	next, err := h.cursors.Encode(position, binding...)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to issue cursor",
+			Message: err.Error(),
+			Code:    "INTERNAL_ERROR",
+		})
+		return
+	}
+	response.NextCursor = next
+
+	c.JSON(http.StatusOK, response)
+}
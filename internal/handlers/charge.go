@@ -0,0 +1,342 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/billing"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// ChargeHandler handles HTTP requests for charge rules, captured charges and their
+// reconciliation
+type ChargeHandler struct {
+	db        *gorm.DB
+	capturer  *billing.Capturer
+	validator *validator.Validate
+}
+
+// NewChargeHandler creates a new charge handler
+func NewChargeHandler(db *gorm.DB, capturer *billing.Capturer) *ChargeHandler {
+	return &ChargeHandler{
+		db:        db,
+		capturer:  capturer,
+		validator: validator.New(),
+	}
+}
+
+// GetChargeRules lists the charge rules
+// @Summary Get charge rules
+// @Description List the codes whose observations and medication administrations capture charges (admin only)
+// @Tags billing
+// @Produce json
+// @Success 200 {array} models.ChargeRule
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/charge-rules [get]
+func (h *ChargeHandler) GetChargeRules(c *gin.Context) {
+	var rules []models.ChargeRule
+	if err := readDB(c, h.db).Order("resource_type, code_system, code").Find(&rules).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch charge rules",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// PutChargeRule creates or replaces a charge rule
+// @Summary Set charge rule
+// @Description Make the observations or medication administrations recorded with a code capture a charge of a quantity (default 1). Without a charge code the service code is translated to CPT or HCPCS through the concept maps. A rule for the same resource type and code is replaced. Services recorded before the rule are not charged; they are reported by the reconciliation (admin only).
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param rule body models.ChargeRuleRequest true "Charge rule"
+// @Success 200 {object} models.ChargeRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/charge-rules [post]
+func (h *ChargeHandler) PutChargeRule(c *gin.Context) {
+	var req models.ChargeRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	rule := models.ChargeRule{
+		ResourceType:  req.ResourceType,
+		System:        req.System,
+		Code:          req.Code,
+		ChargeSystem:  req.ChargeSystem,
+		ChargeCode:    req.ChargeCode,
+		ChargeDisplay: req.ChargeDisplay,
+		Quantity:      req.Quantity,
+		Active:        req.Active == nil || *req.Active,
+		CreatedBy:     userID,
+	}
+
+	err := h.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "resource_type"}, {Name: "code_system"}, {Name: "code"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"charge_system", "charge_code", "charge_display", "quantity", "active", "updated_at",
+		}),
+	}).Create(&rule).Error
+	if err == nil {
+		// The stored rule keeps its ID when it was replaced
+		err = h.db.Where("resource_type = ? AND code_system = ? AND code = ?", rule.ResourceType, rule.System, rule.Code).First(&rule).Error
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to save charge rule",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("update", "ChargeRule", userID, map[string]interface{}{
+		"rule_id": rule.ID,
+		"code":    rule.System + "|" + rule.Code,
+	})
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteChargeRule removes a charge rule
+// @Summary Delete charge rule
+// @Description Stop capturing charges for a code; charges already captured are kept (admin only)
+// @Tags billing
+// @Param id path string true "Charge rule ID"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/charge-rules/{id} [delete]
+func (h *ChargeHandler) DeleteChargeRule(c *gin.Context) {
+	result := h.db.Where("id = ?", c.Param("id")).Delete(&models.ChargeRule{})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete charge rule",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Charge rule not found",
+			Code:  "CHARGE_RULE_NOT_FOUND",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "ChargeRule", userID, map[string]interface{}{
+		"rule_id": c.Param("id"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// GetCharges lists captured charges
+// @Summary Get charges
+// @Description List captured charges, newest first, optionally of one patient or status (admin only)
+// @Tags billing
+// @Produce json
+// @Param patient query string false "Filter by patient ID"
+// @Param status query string false "Filter by status (billable, not-billable, billed, entered-in-error)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.ChargeItem}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/charges [get]
+func (h *ChargeHandler) GetCharges(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.ChargeItem{})
+	if patient := strings.TrimSpace(c.Query("patient")); patient != "" {
+		query = query.Where("subject_reference = ?", "Patient/"+strings.TrimPrefix(patient, "Patient/"))
+	}
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count charges",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var charges []models.ChargeItem
+	if err := query.Order("occurred_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&charges).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch charges",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, charges, total, page, limit)
+}
+
+// UpdateChargeStatus changes the status of a charge
+// @Summary Update charge status
+// @Description Change the status of a captured charge, e.g. to void a duplicate as entered-in-error or mark it billed (admin only)
+// @Tags billing
+// @Accept json
+// @Produce json
+// @Param id path string true "Charge ID"
+// @Param request body models.ChargeItemStatusRequest true "New status"
+// @Success 200 {object} models.ChargeItem
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/charges/{id}/status [post]
+func (h *ChargeHandler) UpdateChargeStatus(c *gin.Context) {
+	var req models.ChargeItemStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var charge models.ChargeItem
+	if err := h.db.Where("id = ?", c.Param("id")).First(&charge).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Charge not found",
+				Code:  "CHARGE_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch charge",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	previous := charge.Status
+	charge.Status = req.Status
+	charge.Note = req.Note
+	charge.UpdatedBy = userID
+	if err := h.db.Model(&charge).Select("status", "note", "updated_by").Updates(&charge).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update charge",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("update", "ChargeItem", userID, map[string]interface{}{
+		"charge_id": charge.ID,
+		"from":      previous,
+		"to":        charge.Status,
+	})
+
+	c.JSON(http.StatusOK, charge)
+}
+
+// GetChargeReconciliation reports uncaptured and duplicate charges
+// @Summary Reconcile charges
+// @Description Compare the final observations and completed medication administrations of a period of at most 31 days that match a charge rule with the charges captured for them. Services without a charge are listed as uncaptured, pending or lacking a charge code; billable charges of the same code for a patient on the same day are listed as duplicates (admin only).
+// @Tags billing
+// @Produce json
+// @Param start query string true "Start of the period (RFC 3339)"
+// @Param end query string true "End of the period (RFC 3339)"
+// @Success 200 {object} billing.Reconciliation
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/billing/charges/reconciliation [get]
+func (h *ChargeHandler) GetChargeReconciliation(c *gin.Context) {
+	start, errStart := time.Parse(time.RFC3339, c.Query("start"))
+	end, errEnd := time.Parse(time.RFC3339, c.Query("end"))
+	if errStart != nil || errEnd != nil || !end.After(start) || end.Sub(start) > maxClaimPeriod {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid period",
+			Message: "start and end must be RFC 3339 times at most 31 days apart",
+			Code:    "INVALID_PERIOD",
+		})
+		return
+	}
+
+	reconciliation, err := h.capturer.Reconcile(c.Request.Context(), start, end)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to reconcile charges",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, reconciliation)
+}
@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ChartSnapshotHandler handles HTTP requests for read-only snapshots of patient
+// charts kept for legal discovery
+type ChartSnapshotHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewChartSnapshotHandler creates a new chart snapshot handler
+func NewChartSnapshotHandler(db *gorm.DB) *ChartSnapshotHandler {
+	return &ChartSnapshotHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// CreateChartSnapshot takes a snapshot of a patient's chart
+// @Summary Create chart snapshot
+// @Description Capture the patient's chart as it is now: the patient and their observations, conditions, allergies, procedures, care plans, medications, clinical notes, specimens, questionnaire responses and the metadata of their media and documents, including records entered in error. The chart is read in a single repeatable-read transaction and stored as JSON with its SHA-256 checksum; later edits to the chart do not change it, and snapshots can be neither updated nor deleted. A patient pending deletion can still be captured.
+// @Tags chart-snapshots
+// @Accept json
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param snapshot body models.ChartSnapshotRequest true "Reason for the snapshot"
+// @Success 201 {object} models.ChartSnapshot
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/chart-snapshots [post]
+func (h *ChartSnapshotHandler) CreateChartSnapshot(c *gin.Context) {
+	var req models.ChartSnapshotRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	patientID := c.Param("id")
+	snapshot := models.ChartSnapshot{
+		PatientID: patientID,
+		Reason:    req.Reason,
+		CreatedBy: userID,
+	}
+
+	// Every record is read from the same snapshot of the database, so the chart is
+	// consistent even when it is edited while being captured
+	var opts []*sql.TxOptions
+	if dialect.IsPostgres(h.db) {
+		opts = append(opts, &sql.TxOptions{Isolation: sql.LevelRepeatableRead})
+	}
+	found := true
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		content, err := collectChart(tx, patientID)
+		if err == gorm.ErrRecordNotFound {
+			found = false
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		snapshot.ID = content.SnapshotID
+		snapshot.TakenAt = content.TakenAt
+		snapshot.Records = chartRecords(content)
+		if snapshot.Content, err = json.Marshal(content); err != nil {
+			return err
+		}
+		sum := sha256.Sum256(snapshot.Content)
+		snapshot.SHA256 = hex.EncodeToString(sum[:])
+		snapshot.Size = int64(len(snapshot.Content))
+		return tx.Create(&snapshot).Error
+	}, opts...)
+	if !found {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Patient not found",
+			Code:  "PATIENT_NOT_FOUND",
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create chart snapshot",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "ChartSnapshot", userID, map[string]interface{}{
+		"snapshot_id": snapshot.ID,
+		"patient_id":  patientID,
+		"reason":      snapshot.Reason,
+		"sha256":      snapshot.SHA256,
+	})
+
+	c.JSON(http.StatusCreated, snapshot)
+}
+
+// GetPatientChartSnapshots lists the snapshots of a patient's chart
+// @Summary Get patient chart snapshots
+// @Description List the snapshots taken of a patient's chart, newest first, without their content
+// @Tags chart-snapshots
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Success 200 {array} models.ChartSnapshot
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/chart-snapshots [get]
+func (h *ChartSnapshotHandler) GetPatientChartSnapshots(c *gin.Context) {
+	snapshots := []models.ChartSnapshot{}
+	if err := readDB(c, h.db).Omit("content").Where("patient_id = ?", c.Param("id")).
+		Order("taken_at DESC").Find(&snapshots).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch chart snapshots",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("list", "ChartSnapshot", userID, map[string]interface{}{
+		"patient_id": c.Param("id"),
+		"count":      len(snapshots),
+	})
+
+	c.JSON(http.StatusOK, snapshots)
+}
+
+// GetChartSnapshot returns a chart snapshot
+// @Summary Get chart snapshot
+// @Description Get when a chart snapshot was taken, why, by whom, how many records of each type it holds and its checksum. The content is downloaded separately.
+// @Tags chart-snapshots
+// @Produce json
+// @Param id path string true "Snapshot ID"
+// @Success 200 {object} models.ChartSnapshot
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/chart-snapshots/{id} [get]
+func (h *ChartSnapshotHandler) GetChartSnapshot(c *gin.Context) {
+	var snapshot models.ChartSnapshot
+	if !findChartSnapshot(c, readDB(c, h.db).Omit("content"), &snapshot) {
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("read", "ChartSnapshot", userID, map[string]interface{}{
+		"snapshot_id": snapshot.ID,
+		"patient_id":  snapshot.PatientID,
+	})
+
+	c.JSON(http.StatusOK, snapshot)
+}
+
+// GetChartSnapshotContent downloads the content of a chart snapshot
+// @Summary Download chart snapshot
+// @Description Download the chart captured by a snapshot as the exact JSON it was checksummed over. The checksum is verified before the content is sent and returned in the Digest header; content that no longer matches it is withheld with SNAPSHOT_CORRUPTED.
+// @Tags chart-snapshots
+// @Produce json
+// @Param id path string true "Snapshot ID"
+// @Success 200 {object} models.ChartContent
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/chart-snapshots/{id}/content [get]
+func (h *ChartSnapshotHandler) GetChartSnapshotContent(c *gin.Context) {
+	var snapshot models.ChartSnapshot
+	if !findChartSnapshot(c, readDB(c, h.db), &snapshot) {
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	sum := sha256.Sum256(snapshot.Content)
+	if hex.EncodeToString(sum[:]) != snapshot.SHA256 {
+		logger.Error("Chart snapshot content does not match its checksum",
+			zap.String("snapshot_id", snapshot.ID),
+			zap.String("patient_id", snapshot.PatientID),
+		)
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Chart snapshot is corrupted",
+			Message: "the stored content no longer matches the checksum taken with it",
+			Code:    "SNAPSHOT_CORRUPTED",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("download", "ChartSnapshot", userID, map[string]interface{}{
+		"snapshot_id": snapshot.ID,
+		"patient_id":  snapshot.PatientID,
+		"sha256":      snapshot.SHA256,
+	})
+
+	name := "chart-" + snapshot.PatientID + "-" + snapshot.TakenAt.UTC().Format("20060102T150405Z") + ".json"
+	c.Header("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum[:]))
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.DataFromReader(http.StatusOK, snapshot.Size, "application/json", bytes.NewReader(snapshot.Content), nil)
+}
+
+// collectChart reads the chart of a patient, returning gorm.ErrRecordNotFound when
+// there is no such patient
+func collectChart(tx *gorm.DB, patientID string) (models.ChartContent, error) {
+	content := models.ChartContent{
+		SnapshotID: uuid.New().String(),
+		TakenAt:    time.Now().UTC(),
+	}
+	if err := tx.Unscoped().Where("id = ?", patientID).First(&content.Patient).Error; err != nil {
+		return content, err
+	}
+
+	// The observations of a patient pending deletion were moved to the trash with it
+	subject := "Patient/" + patientID
+	observations := tx.Where(dialect.Of(tx).JSONText("subject", "reference")+" = ?", subject)
+	if content.Patient.DeletedAt.Valid {
+		observations = tx.Unscoped().Where(dialect.Of(tx).JSONText("subject", "reference")+" = ?", subject).
+			Where("deleted_at IS NULL OR deleted_at = ?", content.Patient.DeletedAt)
+	}
+	if err := observations.Order("created_at").Find(&content.Observations).Error; err != nil {
+		return content, err
+	}
+
+	bySubject := func() *gorm.DB {
+		return tx.Where("subject_reference = ?", subject).Order("created_at")
+	}
+	queries := []struct {
+		query *gorm.DB
+		dest  interface{}
+	}{
+		{bySubject(), &content.Conditions},
+		{bySubject(), &content.AllergyIntolerances},
+		{bySubject(), &content.Procedures},
+		{bySubject().Preload("Activity", orderActivities), &content.CarePlans},
+		{bySubject(), &content.MedicationRequests},
+		{bySubject(), &content.MedicationAdministrations},
+		{bySubject().Preload("Addenda", func(db *gorm.DB) *gorm.DB { return db.Order("created_at") }), &content.ClinicalNotes},
+		{bySubject(), &content.Specimens},
+		{bySubject(), &content.QuestionnaireResponses},
+		{bySubject(), &content.Media},
+		{bySubject(), &content.DocumentReferences},
+	}
+	for _, q := range queries {
+		if err := q.query.Find(q.dest).Error; err != nil {
+			return content, err
+		}
+	}
+	return content, nil
+}
+
+// chartRecords counts the records of a chart by resource type
+func chartRecords(content models.ChartContent) map[string]int {
+	return map[string]int{
+		"Patient":                  1,
+		"Observation":              len(content.Observations),
+		"Condition":                len(content.Conditions),
+		"AllergyIntolerance":       len(content.AllergyIntolerances),
+		"Procedure":                len(content.Procedures),
+		"CarePlan":                 len(content.CarePlans),
+		"MedicationRequest":        len(content.MedicationRequests),
+		"MedicationAdministration": len(content.MedicationAdministrations),
+		"ClinicalNote":             len(content.ClinicalNotes),
+		"Specimen":                 len(content.Specimens),
+		"QuestionnaireResponse":    len(content.QuestionnaireResponses),
+		"Media":                    len(content.Media),
+		"DocumentReference":        len(content.DocumentReferences),
+	}
+}
+
+// findChartSnapshot loads a chart snapshot by the ID in the path, writing the error
+// response when it cannot
+func findChartSnapshot(c *gin.Context, db *gorm.DB, snapshot *models.ChartSnapshot) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(snapshot).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Chart snapshot not found",
+				Code:  "CHART_SNAPSHOT_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch chart snapshot",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,593 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+)
+
+// ClinicalNoteHandler handles HTTP requests for clinical note resources
+type ClinicalNoteHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewClinicalNoteHandler creates a new clinical note handler
+func NewClinicalNoteHandler(db *gorm.DB) *ClinicalNoteHandler {
+	return &ClinicalNoteHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// CreateNote creates a new draft clinical note
+// @Summary Create a clinical note
+// @Description Create a new draft clinical note for a patient
+// @Tags clinical-notes
+// @Accept json
+// @Produce json
+// @Param note body models.ClinicalNote true "Clinical note data"
+// @Success 201 {object} models.ClinicalNote
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/clinical-notes [post]
+func (h *ClinicalNoteHandler) CreateNote(c *gin.Context) {
+	var note models.ClinicalNote
+
+	if err := c.ShouldBindJSON(&note); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(note); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	// Validate that the referenced patient exists
+	patientID := strings.TrimPrefix(note.Subject.Reference, "Patient/")
+	if patientID == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Note subject is required",
+			Code:  "MISSING_SUBJECT",
+		})
+		return
+	}
+
+	var patient models.Patient
+	if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var ok bool
+	if note.ReasonReference, ok = resolveReasonReferences(c, h.db, patientID, note.ReasonReference); !ok {
+		return
+	}
+
+	// Notes always start as drafts authored by the current user
+	note.ID = ""
+	note.Status = models.NoteStatusDraft
+	note.Version = 1
+	note.SignedAt = nil
+	note.SignedBy = ""
+	note.Addenda = nil
+	if note.ContentType == "" {
+		note.ContentType = "text/plain"
+	}
+
+	if userID, exists := auth.GetUserID(c); exists {
+		note.CreatedBy = userID
+		note.Author = currentUserReference(c)
+	}
+
+	if err := h.db.Create(&note).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create clinical note",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, note)
+}
+
+// GetNote retrieves a clinical note with its addenda
+// @Summary Get clinical note by ID
+// @Description Get a clinical note and its addenda
+// @Tags clinical-notes
+// @Produce json
+// @Param id path string true "Clinical note ID"
+// @Success 200 {object} models.ClinicalNote
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/clinical-notes/{id} [get]
+func (h *ClinicalNoteHandler) GetNote(c *gin.Context) {
+	note, ok := h.findNote(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, note)
+}
+
+// UpdateNote edits a draft clinical note, recording the previous revision
+// @Summary Update draft clinical note
+// @Description Edit a draft clinical note. Signed notes are immutable and can only be amended through addenda.
+// @Tags clinical-notes
+// @Accept json
+// @Produce json
+// @Param id path string true "Clinical note ID"
+// @Param note body models.ClinicalNoteUpdateRequest true "Updated note content"
+// @Success 200 {object} models.ClinicalNote
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/clinical-notes/{id} [put]
+func (h *ClinicalNoteHandler) UpdateNote(c *gin.Context) {
+	note, ok := h.findNote(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req models.ClinicalNoteUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	if note.Status != models.NoteStatusDraft {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Signed notes cannot be modified; append an addendum instead",
+			Code:  "NOTE_SIGNED",
+		})
+		return
+	}
+
+	if req.Version != note.Version {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Clinical note was modified by another request",
+			Message: "current version is " + strconv.Itoa(note.Version),
+			Code:    "VERSION_CONFLICT",
+		})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"body":    req.Body,
+		"version": note.Version + 1,
+	}
+	if req.ContentType != "" {
+		updates["content_type"] = req.ContentType
+	}
+	if req.Title != nil {
+		updates["title"] = *req.Title
+	}
+	var reasons []models.Reference
+	if req.ReasonReference != nil {
+		if reasons, ok = resolveReasonReferences(c, h.db, strings.TrimPrefix(note.Subject.Reference, "Patient/"), *req.ReasonReference); !ok {
+			return
+		}
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	snapshot := note.Snapshot()
+	if err := tx.Create(&snapshot).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to record note version",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// Guard on status and version so concurrent signing or edits cannot be overwritten
+	result := tx.Model(&models.ClinicalNote{}).
+		Where("id = ? AND status = ? AND version = ?", note.ID, models.NoteStatusDraft, note.Version).
+		Updates(updates)
+	if result.Error != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update clinical note",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		tx.Rollback()
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Clinical note was signed or modified by another request",
+			Code:  "VERSION_CONFLICT",
+		})
+		return
+	}
+
+	if req.ReasonReference != nil {
+		if err := tx.Model(&models.ClinicalNote{ID: note.ID}).Select("reason_reference").
+			Updates(&models.ClinicalNote{ReasonReference: reasons}).Error; err != nil {
+			tx.Rollback()
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to update clinical note",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	if req.Type != nil || req.Encounter != nil {
+		structUpdates := models.ClinicalNote{}
+		if req.Type != nil {
+			structUpdates.Type = *req.Type
+		}
+		structUpdates.Encounter = req.Encounter
+		if err := tx.Model(&models.ClinicalNote{ID: note.ID}).Updates(structUpdates).Error; err != nil {
+			tx.Rollback()
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to update clinical note",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to commit transaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	updated, ok := h.findNote(c, note.ID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// SignNote signs a draft clinical note, making it immutable
+// @Summary Sign clinical note
+// @Description Sign a draft clinical note. Only the author may sign, and signed notes cannot be edited.
+// @Tags clinical-notes
+// @Produce json
+// @Param id path string true "Clinical note ID"
+// @Success 200 {object} models.ClinicalNote
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/clinical-notes/{id}/sign [post]
+func (h *ClinicalNoteHandler) SignNote(c *gin.Context) {
+	note, ok := h.findNote(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	if note.CreatedBy != userID {
+		respondError(c, http.StatusForbidden, ErrorResponse{
+			Error: "Only the author can sign a clinical note",
+			Code:  "NOT_NOTE_AUTHOR",
+		})
+		return
+	}
+
+	if note.Status != models.NoteStatusDraft {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Clinical note is already signed",
+			Code:  "NOTE_SIGNED",
+		})
+		return
+	}
+
+	now := time.Now()
+	result := h.db.Model(&models.ClinicalNote{}).
+		Where("id = ? AND status = ?", note.ID, models.NoteStatusDraft).
+		Updates(map[string]interface{}{
+			"status":    models.NoteStatusSigned,
+			"signed_at": now,
+			"signed_by": userID,
+		})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to sign clinical note",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Clinical note is already signed",
+			Code:  "NOTE_SIGNED",
+		})
+		return
+	}
+
+	signed, ok := h.findNote(c, note.ID)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, signed)
+}
+
+// AddAddendum appends an addendum to a signed clinical note
+// @Summary Append addendum
+// @Description Append an addendum to a signed clinical note
+// @Tags clinical-notes
+// @Accept json
+// @Produce json
+// @Param id path string true "Clinical note ID"
+// @Param addendum body models.AddendumRequest true "Addendum text"
+// @Success 201 {object} models.NoteAddendum
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/clinical-notes/{id}/addenda [post]
+func (h *ClinicalNoteHandler) AddAddendum(c *gin.Context) {
+	note, ok := h.findNote(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var req models.AddendumRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	if !note.IsSigned() {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Addenda can only be appended to signed notes; edit the draft instead",
+			Code:  "NOTE_NOT_SIGNED",
+		})
+		return
+	}
+
+	addendum := models.NoteAddendum{
+		NoteID: note.ID,
+		Author: currentUserReference(c),
+		Body:   req.Body,
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		addendum.CreatedBy = userID
+	}
+
+	if err := h.db.Create(&addendum).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create addendum",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, addendum)
+}
+
+// GetNoteVersions lists prior draft revisions of a clinical note
+// @Summary Get clinical note versions
+// @Description Get the revision history of a clinical note, oldest first
+// @Tags clinical-notes
+// @Produce json
+// @Param id path string true "Clinical note ID"
+// @Success 200 {array} models.ClinicalNoteVersion
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/clinical-notes/{id}/versions [get]
+func (h *ClinicalNoteHandler) GetNoteVersions(c *gin.Context) {
+	note, ok := h.findNote(c, c.Param("id"))
+	if !ok {
+		return
+	}
+
+	var versions []models.ClinicalNoteVersion
+	if err := h.db.Where("note_id = ?", note.ID).Order("version ASC").Find(&versions).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch note versions",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, versions)
+}
+
+// GetPatientNotes retrieves clinical notes for a specific patient
+// @Summary Get patient clinical notes
+// @Description Get clinical notes for a specific patient
+// @Tags clinical-notes
+// @Produce json
+// @Param patientId path string true "Patient ID"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Param status query string false "Filter by status"
+// @Success 200 {object} PaginatedResponse{data=[]models.ClinicalNote}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{patientId}/notes [get]
+func (h *ClinicalNoteHandler) GetPatientNotes(c *gin.Context) {
+	patientID := c.Param("id")
+	if patientID == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Patient ID is required",
+			Code:  "MISSING_PATIENT_ID",
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	status := strings.TrimSpace(c.Query("status"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var notes []models.ClinicalNote
+	query := h.db.Model(&models.ClinicalNote{}).Where("subject_reference = ?", "Patient/"+patientID)
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count clinical notes",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Preload("Addenda").Order("created_at DESC").Offset(offset).Limit(limit).Find(&notes).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch clinical notes",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, notes, total, page, limit)
+}
+
+// findNote loads a clinical note with its addenda, writing an error response on failure
+func (h *ClinicalNoteHandler) findNote(c *gin.Context, id string) (*models.ClinicalNote, bool) {
+	if id == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Clinical note ID is required",
+			Code:  "MISSING_NOTE_ID",
+		})
+		return nil, false
+	}
+
+	var note models.ClinicalNote
+	if err := h.db.Preload("Addenda", func(db *gorm.DB) *gorm.DB {
+		return db.Order("created_at ASC")
+	}).Where("id = ?", id).First(&note).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Clinical note not found",
+				Code:  "NOTE_NOT_FOUND",
+			})
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch clinical note",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return nil, false
+	}
+
+	return &note, true
+}
+
+// currentUserReference builds a reference to the authenticated user
+func currentUserReference(c *gin.Context) models.Reference {
+	reference := models.Reference{Type: "User"}
+	if userID, exists := auth.GetUserID(c); exists {
+		reference.Reference = "User/" + userID
+	}
+	if email, exists := c.Get("user_email"); exists {
+		if display, ok := email.(string); ok {
+			reference.Display = display
+		}
+	}
+	return reference
+}
@@ -0,0 +1,687 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ConditionHandler handles HTTP requests for the problem list
+type ConditionHandler struct {
+	db            *gorm.DB
+	translator    *terminology.Translator
+	billingSystem string
+	localizer     *terminology.Localizer
+	validator     *validator.Validate
+	undo          time.Duration
+}
+
+// NewConditionHandler creates a new condition handler. Conditions coded without a
+// code of billingSystem are given one from the concept maps; an empty billingSystem
+// leaves their codes as recorded. Conditions are read with the displays of their
+// codes in the reader's language. Deleted conditions can be restored until the undo
+// window has passed.
+func NewConditionHandler(db *gorm.DB, translator *terminology.Translator, localizer *terminology.Localizer, billingSystem string, undoWindow time.Duration) *ConditionHandler {
+	return &ConditionHandler{
+		db:            db,
+		translator:    translator,
+		localizer:     localizer,
+		billingSystem: billingSystem,
+		validator:     validator.New(),
+		undo:          undoWindow,
+	}
+}
+
+// ConditionRelated is everything recorded against a problem
+type ConditionRelated struct {
+	Condition          models.Condition           `json:"condition"`
+	Observations       []models.Observation       `json:"observations"`
+	MedicationRequests []models.MedicationRequest `json:"medicationRequests"`
+	ClinicalNotes      []models.ClinicalNote      `json:"clinicalNotes"`
+}
+
+// CreateCondition adds a condition to a patient's problem list
+// @Summary Create condition
+// @Description Add a problem or diagnosis to the patient's problem list. Observations, medication requests and clinical notes reference it in reasonReference as Condition/{id}. A condition coded without a billing code (ICD-10-CM by default) is given the one its clinical code maps to in the concept maps. The current user is recorded as its recorder.
+// @Tags conditions
+// @Accept json
+// @Produce json
+// @Param condition body models.ConditionRequest true "Condition"
+// @Success 201 {object} models.Condition
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/conditions [post]
+func (h *ConditionHandler) CreateCondition(c *gin.Context) {
+	var req models.ConditionRequest
+	if !h.bindCondition(c, &req) {
+		return
+	}
+
+	h.create(c, strings.TrimPrefix(req.Subject.Reference, "Patient/"), req)
+}
+
+// CreatePatientCondition adds a condition to the problem list of the patient in the path
+// @Summary Create patient condition
+// @Description Add a problem or diagnosis to the problem list of the patient in the path, as POST /conditions does. The subject may be left out; one naming another patient is rejected.
+// @Tags conditions
+// @Accept json
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param condition body models.ConditionRequest true "Condition"
+// @Success 201 {object} models.Condition
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/conditions [post]
+func (h *ConditionHandler) CreatePatientCondition(c *gin.Context) {
+	var req models.ConditionRequest
+	if !h.bindCondition(c, &req) {
+		return
+	}
+
+	patientID := c.Param("id")
+	if req.Subject.Reference != "" && strings.TrimPrefix(req.Subject.Reference, "Patient/") != patientID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "subject must be the patient in the path",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	h.create(c, patientID, req)
+}
+
+// GetCondition retrieves a condition by ID
+// @Summary Get condition
+// @Description Get a condition by its ID
+// @Tags conditions
+// @Produce json
+// @Param id path string true "Condition ID"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
+// @Success 200 {object} models.Condition
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/conditions/{id} [get]
+func (h *ConditionHandler) GetCondition(c *gin.Context) {
+	var condition models.Condition
+	if !findCondition(c, readDB(c, h.db), &condition) {
+		return
+	}
+
+	localizeCodings(c, h.localizer, condition.Code.Coding)
+	c.JSON(http.StatusOK, condition)
+}
+
+// UpdateCondition replaces the clinical content of a condition
+// @Summary Update condition
+// @Description Replace the code, statuses, category, severity, onset, abatement and note of a condition; statuses and category left out are kept. The subject cannot change. Resolving a condition records when it abated, now unless given, and reactivating it clears the abatement. The current user becomes its recorder.
+// @Tags conditions
+// @Accept json
+// @Produce json
+// @Param id path string true "Condition ID"
+// @Param condition body models.ConditionRequest true "Condition"
+// @Success 200 {object} models.Condition
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/conditions/{id} [put]
+func (h *ConditionHandler) UpdateCondition(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.ConditionRequest
+	if !h.bindCondition(c, &req) {
+		return
+	}
+
+	var condition models.Condition
+	if !findCondition(c, db, &condition) {
+		return
+	}
+
+	if req.Subject.Reference != "" && strings.TrimPrefix(req.Subject.Reference, "Patient/") != strings.TrimPrefix(condition.Subject.Reference, "Patient/") {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "the subject of a condition cannot change",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	// An unchanged status keeps the recorded abatement unless a new one is given
+	abatement := req.AbatementAt
+	if abatement == nil && (req.ClinicalStatus == "" || req.ClinicalStatus == condition.ClinicalStatus) {
+		abatement = condition.AbatementAt
+	}
+	if req.ClinicalStatus != "" {
+		condition.ClinicalStatus = req.ClinicalStatus
+	}
+	if req.VerificationStatus != "" {
+		condition.VerificationStatus = req.VerificationStatus
+	}
+	if req.Category != "" {
+		condition.Category = req.Category
+	}
+	condition.Code = req.Code
+	condition.Severity = req.Severity
+	condition.OnsetAt = req.OnsetAt
+	condition.AbatementAt = models.ConditionAbatement(condition.ClinicalStatus, abatement)
+	condition.Note = req.Note
+	condition.Recorder = currentUserReference(c)
+	h.addBillingCode(c, &condition)
+
+	if err := db.Model(&condition).Select("code", "clinical_status", "verification_status", "category", "severity",
+		"onset_at", "abatement_at", "note", "recorder_reference", "recorder_type", "recorder_display", "updated_at").
+		Updates(&condition).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update condition",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "Condition", userID, map[string]interface{}{
+		"condition_id":        condition.ID,
+		"clinical_status":     condition.ClinicalStatus,
+		"verification_status": condition.VerificationStatus,
+	})
+
+	c.JSON(http.StatusOK, condition)
+}
+
+// DeleteCondition removes a condition from the problem list
+// @Summary Delete condition
+// @Description Delete a condition recorded by mistake. A condition that observations, medication requests or clinical notes name in reasonReference is kept as their reason; mark it entered-in-error instead (admin only). The deletion can be undone until the undo window passes.
+// @Tags conditions
+// @Param id path string true "Condition ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/conditions/{id} [delete]
+func (h *ConditionHandler) DeleteCondition(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var condition models.Condition
+	if !findCondition(c, db, &condition) {
+		return
+	}
+
+	// Observations pending deletion count too, since undeleting one restores its reasons
+	d := dialect.Of(db)
+	reason := models.ReasonContainment(condition.ID)
+	for _, model := range []interface{}{&models.Observation{}, &models.MedicationRequest{}, &models.ClinicalNote{}} {
+		var count int64
+		if err := db.Unscoped().Model(model).Where(d.ContainsReference("reason_reference"), reason).
+			Count(&count).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to check condition references",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if count > 0 {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error:   "Condition is referenced by other records",
+				Message: "mark the condition entered-in-error instead",
+				Code:    "CONDITION_IN_USE",
+			})
+			return
+		}
+	}
+
+	// Mark the condition as pending deletion; the retention job removes it after the undo window
+	if err := trashRecord(c, db, &condition); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete condition",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "Condition", userID, map[string]interface{}{
+		"condition_id": condition.ID,
+		"patient_id":   strings.TrimPrefix(condition.Subject.Reference, "Patient/"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// UndeleteCondition restores a deleted condition within the undo window
+// @Summary Undelete condition
+// @Description Restore a deleted condition as long as the undo window has not passed. Conditions deleted with their patient are restored by undeleting the patient (admin only).
+// @Tags conditions
+// @Produce json
+// @Param id path string true "Condition ID"
+// @Success 200 {object} models.Condition
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/conditions/{id}/undelete [post]
+func (h *ConditionHandler) UndeleteCondition(c *gin.Context) {
+	var condition models.Condition
+	if !undeleteRecord(c, writeDB(c, h.db), "Condition", &condition, h.undo, ErrorResponse{
+		Error: "Condition not found",
+		Code:  "CONDITION_NOT_FOUND",
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, condition)
+}
+
+// bindCondition binds and validates a condition request, writing the error response
+// on failure
+func (h *ConditionHandler) bindCondition(c *gin.Context, req *models.ConditionRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	if len(req.Code.Coding) == 0 && strings.TrimSpace(req.Code.Text) == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "code must have a coding or text naming the condition",
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	return true
+}
+
+// create adds the condition of a request to the problem list of a patient
+func (h *ConditionHandler) create(c *gin.Context, patientID string, req models.ConditionRequest) {
+	db := writeDB(c, h.db)
+
+	// Validate that the referenced patient exists
+	var patient models.Patient
+	if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	condition := models.Condition{
+		Subject:            models.Reference{Reference: "Patient/" + patientID, Display: req.Subject.Display},
+		Code:               req.Code,
+		ClinicalStatus:     req.ClinicalStatus,
+		VerificationStatus: req.VerificationStatus,
+		Category:           req.Category,
+		Severity:           req.Severity,
+		OnsetAt:            req.OnsetAt,
+		AbatementAt:        models.ConditionAbatement(req.ClinicalStatus, req.AbatementAt),
+		Recorder:           currentUserReference(c),
+		Note:               req.Note,
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		condition.CreatedBy = userID
+	}
+	h.addBillingCode(c, &condition)
+
+	if err := db.Create(&condition).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create condition",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "Condition", condition.CreatedBy, map[string]interface{}{
+		"condition_id": condition.ID,
+		"patient_id":   patientID,
+	})
+
+	c.JSON(http.StatusCreated, condition)
+}
+
+// addBillingCode adds the billing code derived from the clinical code, e.g. SNOMED CT
+// to ICD-10-CM, to a condition without one. A condition without a mapping is recorded
+// as coded and billed manually.
+func (h *ConditionHandler) addBillingCode(c *gin.Context, condition *models.Condition) {
+	if h.billingSystem == "" {
+		return
+	}
+	billing, err := h.translator.BillingCode(c.Request.Context(), condition.Code, h.billingSystem)
+	if err != nil {
+		logger.Warn("Failed to map condition to billing code", zap.String("subject", condition.Subject.Reference), zap.Error(err))
+	} else if billing != nil {
+		condition.Code.Coding = append(condition.Code.Coding, *billing)
+	}
+}
+
+// GetPatientConditions lists a patient's problem list
+// @Summary Get patient conditions
+// @Description List the conditions of a patient, newest first. Refuted conditions and conditions entered in error are left out unless all is set.
+// @Tags conditions
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param clinical-status query string false "Filter by clinical status, e.g. active"
+// @Param all query bool false "Include refuted conditions and conditions entered in error"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
+// @Success 200 {array} models.Condition
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/conditions [get]
+func (h *ConditionHandler) GetPatientConditions(c *gin.Context) {
+	query := readDB(c, h.db).Where("subject_reference = ?", "Patient/"+c.Param("id"))
+	if status := strings.TrimSpace(c.Query("clinical-status")); status != "" {
+		query = query.Where("clinical_status = ?", status)
+	}
+	if c.Query("all") != "true" {
+		query = query.Where("verification_status NOT IN ?", []string{models.ConditionRefuted, models.ConditionEnteredInError})
+	}
+
+	conditions := []models.Condition{}
+	if err := query.Order("created_at DESC").Find(&conditions).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch conditions",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	codings := make([][]models.Coding, len(conditions))
+	for i := range conditions {
+		codings[i] = conditions[i].Code.Coding
+	}
+	localizeCodings(c, h.localizer, codings...)
+	c.JSON(http.StatusOK, conditions)
+}
+
+// UpdateConditionStatus changes the clinical or verification status of a condition
+// @Summary Update condition status
+// @Description Resolve, reactivate, confirm or refute a condition. Resolving a condition records when it abated, now unless given.
+// @Tags conditions
+// @Accept json
+// @Produce json
+// @Param id path string true "Condition ID"
+// @Param request body models.ConditionStatusRequest true "New status"
+// @Success 200 {object} models.Condition
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/conditions/{id}/status [post]
+func (h *ConditionHandler) UpdateConditionStatus(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.ConditionStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var condition models.Condition
+	if !findCondition(c, db, &condition) {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.ClinicalStatus != "" {
+		updates["clinical_status"] = req.ClinicalStatus
+		condition.ClinicalStatus = req.ClinicalStatus
+
+		// Abatement is kept only while the condition is resolved, in remission or inactive
+		condition.AbatementAt = models.ConditionAbatement(req.ClinicalStatus, req.AbatementAt)
+		updates["abatement_at"] = condition.AbatementAt
+	}
+	if req.VerificationStatus != "" {
+		updates["verification_status"] = req.VerificationStatus
+		condition.VerificationStatus = req.VerificationStatus
+	}
+	if err := db.Model(&condition).Updates(updates).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update condition",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "Condition", userID, map[string]interface{}{
+		"condition_id":        condition.ID,
+		"clinical_status":     condition.ClinicalStatus,
+		"verification_status": condition.VerificationStatus,
+	})
+
+	c.JSON(http.StatusOK, condition)
+}
+
+// GetConditionRelated lists everything recorded against a problem
+// @Summary Get records related to a condition
+// @Description Return a condition of the patient with the observations, medication requests and clinical notes whose reasonReference names it, newest first, for problem-oriented review
+// @Tags conditions
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param condId path string true "Condition ID"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
+// @Success 200 {object} ConditionRelated
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/conditions/{condId}/related [get]
+func (h *ConditionHandler) GetConditionRelated(c *gin.Context) {
+	db := readDB(c, h.db)
+	patientRef := "Patient/" + c.Param("id")
+
+	related := ConditionRelated{
+		Observations:       []models.Observation{},
+		MedicationRequests: []models.MedicationRequest{},
+		ClinicalNotes:      []models.ClinicalNote{},
+	}
+	if err := db.Where("id = ? AND subject_reference = ?", c.Param("condId"), patientRef).
+		First(&related.Condition).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Condition not found",
+				Code:  "CONDITION_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch condition",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	d := dialect.Of(db)
+	reason := models.ReasonContainment(related.Condition.ID)
+	fail := func(err error) {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch related records",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+	}
+	if err := db.Where(d.JSONText("subject", "reference")+" = ?", patientRef).
+		Where(d.ContainsReference("reason_reference"), reason).
+		Order("effective_date_time DESC").Find(&related.Observations).Error; err != nil {
+		fail(err)
+		return
+	}
+	if err := db.Where("subject_reference = ?", patientRef).
+		Where(d.ContainsReference("reason_reference"), reason).
+		Order("created_at DESC").Find(&related.MedicationRequests).Error; err != nil {
+		fail(err)
+		return
+	}
+	if err := db.Preload("Addenda").Where("subject_reference = ?", patientRef).
+		Where(d.ContainsReference("reason_reference"), reason).
+		Where("status <> ?", models.NoteStatusEnteredInError).
+		Order("created_at DESC").Find(&related.ClinicalNotes).Error; err != nil {
+		fail(err)
+		return
+	}
+
+	localizeCodings(c, h.localizer, related.Condition.Code.Coding)
+	localizeObservations(c, h.localizer, related.Observations...)
+	c.JSON(http.StatusOK, related)
+}
+
+// resolveReasonReferences checks that every reasonReference names a condition of the
+// patient and returns them normalized to Condition/{id}, writing an error response
+// on failure
+func resolveReasonReferences(c *gin.Context, db *gorm.DB, patientID string, refs []models.Reference) ([]models.Reference, bool) {
+	return resolveConditionReferences(c, db, patientID, "reasonReference", refs)
+}
+
+// resolveConditionReferences checks that every reference of a field names a condition
+// of the patient and returns them normalized to Condition/{id}, writing an error
+// response on failure
+func resolveConditionReferences(c *gin.Context, db *gorm.DB, patientID, field string, refs []models.Reference) ([]models.Reference, bool) {
+	if len(refs) == 0 {
+		return refs, true
+	}
+
+	ids := make([]string, 0, len(refs))
+	seen := map[string]bool{}
+	for _, ref := range refs {
+		if (ref.Type != "" && ref.Type != "Condition") || !strings.HasPrefix(ref.Reference, "Condition/") {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid condition reference",
+				Message: field + " must reference conditions as Condition/{id}",
+				Code:    "INVALID_REASON_REFERENCE",
+			})
+			return nil, false
+		}
+		id := strings.TrimPrefix(ref.Reference, "Condition/")
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	var conditions []models.Condition
+	if err := db.Select("id", "code").Where("id IN ? AND subject_reference = ?", ids, "Patient/"+patientID).
+		Find(&conditions).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate condition references",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return nil, false
+	}
+	if len(conditions) != len(ids) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Referenced condition not found",
+			Message: field + " must only reference conditions of the same patient",
+			Code:    "CONDITION_NOT_FOUND",
+		})
+		return nil, false
+	}
+
+	displays := make(map[string]string, len(conditions))
+	for _, condition := range conditions {
+		display := condition.Code.Text
+		if len(condition.Code.Coding) > 0 && condition.Code.Coding[0].Display != "" {
+			display = condition.Code.Coding[0].Display
+		}
+		displays[condition.ID] = display
+	}
+	normalized := make([]models.Reference, 0, len(ids))
+	for _, id := range ids {
+		normalized = append(normalized, models.Reference{
+			Reference: "Condition/" + id,
+			Type:      "Condition",
+			Display:   displays[id],
+		})
+	}
+	return normalized, true
+}
+
+// findCondition loads the condition in the path, writing the error response when it
+// is not found
+func findCondition(c *gin.Context, db *gorm.DB, condition *models.Condition) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(condition).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Condition not found",
+				Code:  "CONDITION_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch condition",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,93 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ConsistencyHeader carries the consistency token returned by writes. Sending it on
+// a later read guarantees the read sees the write.
+const ConsistencyHeader = "X-Consistency-Token"
+
+// readDBKey is the context key of the connection chosen for a read
+const readDBKey = "read_db"
+
+// ConsistencyMiddleware routes reads through the router and adds a consistency
+// token to successful writes. Without a replica it does nothing.
+func ConsistencyMiddleware(router *database.Router) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !router.HasReplica() {
+			c.Next()
+			return
+		}
+
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead:
+			c.Set(readDBKey, router.Reader(c.Request.Context(), c.GetHeader(ConsistencyHeader)))
+		default:
+			c.Writer = &tokenWriter{ResponseWriter: c.Writer, c: c, router: router}
+		}
+		c.Next()
+	}
+}
+
+// readDB returns the connection chosen for the request's read, or db when reads are
+// not routed
+func readDB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if value, ok := c.Get(readDBKey); ok {
+		return value.(*gorm.DB)
+	}
+	return db
+}
+
+// tokenWriter adds the consistency token to a successful write's response. Handlers
+// commit before responding, so the position read when the status is set covers the
+// write.
+type tokenWriter struct {
+	gin.ResponseWriter
+	c      *gin.Context
+	router *database.Router
+	done   bool
+}
+
+func (w *tokenWriter) WriteHeader(code int) {
+	w.addToken(code)
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *tokenWriter) WriteHeaderNow() {
+	w.addToken(w.ResponseWriter.Status())
+	w.ResponseWriter.WriteHeaderNow()
+}
+
+func (w *tokenWriter) Write(data []byte) (int, error) {
+	w.addToken(w.ResponseWriter.Status())
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *tokenWriter) WriteString(s string) (int, error) {
+	w.addToken(w.ResponseWriter.Status())
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *tokenWriter) addToken(code int) {
+	if w.done || w.ResponseWriter.Written() {
+		return
+	}
+	w.done = true
+	if code >= http.StatusBadRequest {
+		return
+	}
+
+	token, err := w.router.Token(w.c.Request.Context())
+	if err != nil {
+		logger.Warn("Failed to read consistency token", zap.Error(err))
+		return
+	}
+	w.Header().Set(ConsistencyHeader, token)
+}
@@ -0,0 +1,384 @@
+package handlers
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/google/uuid"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/notify"
+	"gorm.io/gorm"
+)
+
+const (
+	verificationCodeTTL      = 10 * time.Minute
+	verificationResendDelay  = time.Minute
+	verificationMaxAttempts  = 5
+	verificationCodeDigits   = 6
+	verificationMessageTitle = "Your HealthHub verification code"
+)
+
+// ContactVerificationHandler handles verification of patient phone numbers and email addresses
+type ContactVerificationHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	email     notify.Sender
+	sms       notify.Sender
+	secret    []byte
+}
+
+// NewContactVerificationHandler creates a new contact verification handler. Codes are
+// stored as HMACs keyed by secret.
+func NewContactVerificationHandler(db *gorm.DB, email, sms notify.Sender, secret string) *ContactVerificationHandler {
+	return &ContactVerificationHandler{
+		db:        db,
+		validator: validator.New(),
+		email:     email,
+		sms:       sms,
+		secret:    []byte(secret),
+	}
+}
+
+// StartVerification sends a one-time code to a patient telecom entry
+// @Summary Start contact verification
+// @Description Send a one-time code by SMS or email to one of the patient's telecom entries
+// @Tags patients
+// @Accept json
+// @Produce json
+// @Param patientId path string true "Patient ID"
+// @Param request body models.ContactVerificationRequest true "Contact to verify"
+// @Success 201 {object} models.ContactVerification
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{patientId}/telecom/verifications [post]
+func (h *ContactVerificationHandler) StartVerification(c *gin.Context) {
+	patientID := c.Param("id")
+
+	var req models.ContactVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var patient models.Patient
+	if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if findContact(patient.Telecom, req.System, req.Value) < 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Contact not found on patient",
+			Code:  "CONTACT_NOT_FOUND",
+		})
+		return
+	}
+
+	// Throttle resends to the same contact
+	var recent int64
+	if err := h.db.Model(&models.ContactVerification{}).
+		Where("patient_id = ? AND system = ? AND value = ? AND created_at > ?",
+			patientID, req.System, req.Value, time.Now().Add(-verificationResendDelay)).
+		Count(&recent).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check recent verifications",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if recent > 0 {
+		respondError(c, http.StatusTooManyRequests, ErrorResponse{
+			Error: "A code was sent recently; wait before requesting another",
+			Code:  "VERIFICATION_THROTTLED",
+		})
+		return
+	}
+
+	code, err := generateVerificationCode()
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to generate verification code",
+			Message: err.Error(),
+			Code:    "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	createdBy, _ := auth.GetUserID(c)
+	verification := models.ContactVerification{
+		ID:        uuid.New().String(),
+		PatientID: patientID,
+		System:    req.System,
+		Value:     req.Value,
+		ExpiresAt: time.Now().UTC().Add(verificationCodeTTL),
+		CreatedBy: createdBy,
+	}
+	verification.CodeHash = h.hashCode(verification.ID, code)
+
+	if err := h.db.Create(&verification).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create verification",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	sender := h.sms
+	if req.System == "email" {
+		sender = h.email
+	}
+	if err := sender.Send(c.Request.Context(), notify.Message{
+		To:      req.Value,
+		Subject: verificationMessageTitle,
+		Body:    fmt.Sprintf("Your HealthHub verification code is %s. It expires in %d minutes.", code, int(verificationCodeTTL.Minutes())),
+	}); err != nil {
+		// Remove the undeliverable code so it does not throttle a retry
+		h.db.Delete(&verification)
+		respondError(c, http.StatusBadGateway, ErrorResponse{
+			Error:   "Failed to send verification code",
+			Message: err.Error(),
+			Code:    "NOTIFICATION_FAILED",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, verification)
+}
+
+// ConfirmVerification checks a one-time code and marks the contact as verified
+// @Summary Confirm contact verification
+// @Description Confirm a one-time code and mark the matching telecom entry as verified
+// @Tags patients
+// @Accept json
+// @Produce json
+// @Param patientId path string true "Patient ID"
+// @Param verificationId path string true "Verification ID"
+// @Param request body models.ContactConfirmationRequest true "Verification code"
+// @Success 200 {object} models.Patient
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{patientId}/telecom/verifications/{verificationId}/confirm [post]
+func (h *ContactVerificationHandler) ConfirmVerification(c *gin.Context) {
+	patientID := c.Param("id")
+
+	var req models.ContactConfirmationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var verification models.ContactVerification
+	if err := h.db.Where("id = ? AND patient_id = ?", c.Param("verificationId"), patientID).First(&verification).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Verification not found",
+				Code:  "VERIFICATION_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch verification",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if verification.VerifiedAt != nil {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Verification has already been confirmed",
+			Code:  "VERIFICATION_ALREADY_CONFIRMED",
+		})
+		return
+	}
+	if time.Now().After(verification.ExpiresAt) || verification.Attempts >= verificationMaxAttempts {
+		respondError(c, http.StatusGone, ErrorResponse{
+			Error: "Verification code has expired; request a new one",
+			Code:  "VERIFICATION_EXPIRED",
+		})
+		return
+	}
+
+	// Count the attempt before comparing so concurrent guesses cannot exceed the limit
+	result := h.db.Model(&models.ContactVerification{}).
+		Where("id = ? AND attempts < ?", verification.ID, verificationMaxAttempts).
+		Update("attempts", gorm.Expr("attempts + 1"))
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to record verification attempt",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusGone, ErrorResponse{
+			Error: "Verification code has expired; request a new one",
+			Code:  "VERIFICATION_EXPIRED",
+		})
+		return
+	}
+
+	if !hmac.Equal([]byte(h.hashCode(verification.ID, req.Code)), []byte(verification.CodeHash)) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Verification code is incorrect",
+			Code:  "VERIFICATION_CODE_INVALID",
+		})
+		return
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	var patient models.Patient
+	if err := tx.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	index := findContact(patient.Telecom, verification.System, verification.Value)
+	if index < 0 {
+		tx.Rollback()
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Contact was removed from the patient after the code was sent",
+			Code:  "CONTACT_NOT_FOUND",
+		})
+		return
+	}
+	patient.Telecom[index].Verified = true
+
+	if err := tx.Model(&patient).Select("telecom").Updates(models.Patient{Telecom: patient.Telecom}).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := tx.Model(&verification).Update("verified_at", time.Now().UTC()).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update verification",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to commit transaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, patient)
+}
+
+// hashCode derives the stored form of a verification code
+func (h *ContactVerificationHandler) hashCode(verificationID, code string) string {
+	mac := hmac.New(sha256.New, h.secret)
+	mac.Write([]byte(verificationID + ":" + code))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// generateVerificationCode returns a random numeric code
+func generateVerificationCode() (string, error) {
+	max := big.NewInt(1)
+	for i := 0; i < verificationCodeDigits; i++ {
+		max.Mul(max, big.NewInt(10))
+	}
+
+	n, err := rand.Int(rand.Reader, max)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%0*d", verificationCodeDigits, n), nil
+}
+
+// findContact returns the index of the telecom entry matching a verification target.
+// SMS verification applies to phone entries as well as sms entries.
+func findContact(telecom []models.Contact, system, value string) int {
+	for i, contact := range telecom {
+		if contact.Value != value {
+			continue
+		}
+		if contact.System == system || (system == "sms" && contact.System == "phone") || (system == "phone" && contact.System == "sms") {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,456 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// subscriberIDPattern matches the member IDs printed on insurance cards
+var subscriberIDPattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*$`)
+
+// CoverageHandler handles HTTP requests for the insurance coverage of patients
+type CoverageHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	undo      time.Duration
+}
+
+// NewCoverageHandler creates a new coverage handler. Deleted coverages can be
+// restored until the undo window has passed.
+func NewCoverageHandler(db *gorm.DB, undoWindow time.Duration) *CoverageHandler {
+	return &CoverageHandler{
+		db:        db,
+		validator: validator.New(),
+		undo:      undoWindow,
+	}
+}
+
+// CreateCoverage records an insurance coverage
+// @Summary Create coverage
+// @Description Record an insurance policy covering a patient: the payor, the subscriber (member) ID, the plan and the period it is in force. The subscriber is the patient unless a relationship is given, in which case the subscriber's name is required. A patient cannot have two active or draft coverages with the same payor and subscriber ID.
+// @Tags coverages
+// @Accept json
+// @Produce json
+// @Param coverage body models.CoverageRequest true "Coverage"
+// @Success 201 {object} models.Coverage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/coverages [post]
+func (h *CoverageHandler) CreateCoverage(c *gin.Context) {
+	var req models.CoverageRequest
+	if !h.bindCoverage(c, &req) {
+		return
+	}
+
+	h.create(c, strings.TrimPrefix(req.Beneficiary.Reference, "Patient/"), req)
+}
+
+// CreatePatientCoverage records an insurance coverage of the patient in the path
+// @Summary Create patient coverage
+// @Description Record an insurance policy covering the patient in the path, as POST /coverages does. The beneficiary may be left out; one naming another patient is rejected.
+// @Tags coverages
+// @Accept json
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param coverage body models.CoverageRequest true "Coverage"
+// @Success 201 {object} models.Coverage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/coverages [post]
+func (h *CoverageHandler) CreatePatientCoverage(c *gin.Context) {
+	var req models.CoverageRequest
+	if !h.bindCoverage(c, &req) {
+		return
+	}
+
+	patientID := c.Param("id")
+	if req.Beneficiary.Reference != "" && strings.TrimPrefix(req.Beneficiary.Reference, "Patient/") != patientID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "beneficiary must be the patient in the path",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	h.create(c, patientID, req)
+}
+
+// GetCoverage retrieves a coverage by ID
+// @Summary Get coverage
+// @Description Get an insurance coverage by its ID
+// @Tags coverages
+// @Produce json
+// @Param id path string true "Coverage ID"
+// @Success 200 {object} models.Coverage
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/coverages/{id} [get]
+func (h *CoverageHandler) GetCoverage(c *gin.Context) {
+	var coverage models.Coverage
+	if !findCoverage(c, readDB(c, h.db), &coverage) {
+		return
+	}
+
+	c.JSON(http.StatusOK, coverage)
+}
+
+// UpdateCoverage replaces a coverage
+// @Summary Update coverage
+// @Description Replace the payor, subscriber, plan, period and note of a coverage, e.g. when the patient presents a new insurance card; the status is kept when left out. The beneficiary cannot change.
+// @Tags coverages
+// @Accept json
+// @Produce json
+// @Param id path string true "Coverage ID"
+// @Param coverage body models.CoverageRequest true "Coverage"
+// @Success 200 {object} models.Coverage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/coverages/{id} [put]
+func (h *CoverageHandler) UpdateCoverage(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.CoverageRequest
+	if !h.bindCoverage(c, &req) {
+		return
+	}
+
+	var coverage models.Coverage
+	if !findCoverage(c, db, &coverage) {
+		return
+	}
+
+	patientID := strings.TrimPrefix(coverage.Beneficiary.Reference, "Patient/")
+	if req.Beneficiary.Reference != "" && strings.TrimPrefix(req.Beneficiary.Reference, "Patient/") != patientID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "the beneficiary of a coverage cannot change",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	if req.Status != "" {
+		coverage.Status = req.Status
+	}
+	coverage.Type = req.Type
+	coverage.Payor = req.Payor
+	coverage.SubscriberID = req.SubscriberID
+	coverage.SubscriberName = req.SubscriberName
+	coverage.Relationship = req.Relationship
+	if coverage.Relationship == "" {
+		coverage.Relationship = models.CoverageSubscriberSelf
+	}
+	coverage.Plan = req.Plan
+	coverage.Group = req.Group
+	coverage.Period = req.Period
+	coverage.Note = req.Note
+
+	if !h.checkDuplicate(c, db, patientID, coverage) {
+		return
+	}
+
+	if err := db.Model(&coverage).Select("status", "type", "payor_name", "payor_identifier", "subscriber_id",
+		"subscriber_name", "relationship", "plan", "group_number", "period_start", "period_end", "note",
+		"updated_at").Updates(&coverage).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update coverage",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "Coverage", userID, map[string]interface{}{
+		"coverage_id": coverage.ID,
+		"patient_id":  patientID,
+		"status":      coverage.Status,
+		"payor":       coverage.Payor.Name,
+	})
+
+	c.JSON(http.StatusOK, coverage)
+}
+
+// DeleteCoverage removes a coverage
+// @Summary Delete coverage
+// @Description Delete a coverage recorded by mistake (admin only). Prefer cancelling a coverage that has ended so it stays on record. The deletion can be undone until the undo window passes.
+// @Tags coverages
+// @Param id path string true "Coverage ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/coverages/{id} [delete]
+func (h *CoverageHandler) DeleteCoverage(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var coverage models.Coverage
+	if !findCoverage(c, db, &coverage) {
+		return
+	}
+
+	// Mark the coverage as pending deletion; the retention job removes it after the undo window
+	if err := trashRecord(c, db, &coverage); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete coverage",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "Coverage", userID, map[string]interface{}{
+		"coverage_id": coverage.ID,
+		"patient_id":  strings.TrimPrefix(coverage.Beneficiary.Reference, "Patient/"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// UndeleteCoverage restores a deleted coverage within the undo window
+// @Summary Undelete coverage
+// @Description Restore a deleted coverage as long as the undo window has not passed. Coverages deleted with their patient are restored by undeleting the patient (admin only).
+// @Tags coverages
+// @Produce json
+// @Param id path string true "Coverage ID"
+// @Success 200 {object} models.Coverage
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/coverages/{id}/undelete [post]
+func (h *CoverageHandler) UndeleteCoverage(c *gin.Context) {
+	var coverage models.Coverage
+	if !undeleteRecord(c, writeDB(c, h.db), "Coverage", &coverage, h.undo, ErrorResponse{
+		Error: "Coverage not found",
+		Code:  "COVERAGE_NOT_FOUND",
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, coverage)
+}
+
+// GetPatientCoverages lists the insurance coverages of a patient
+// @Summary Get patient coverages
+// @Description List the insurance coverages of a patient, newest first. Cancelled coverages and those entered in error are only listed when all is set.
+// @Tags coverages
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param all query bool false "Include cancelled and erroneous coverages"
+// @Success 200 {array} models.Coverage
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/coverages [get]
+func (h *CoverageHandler) GetPatientCoverages(c *gin.Context) {
+	query := readDB(c, h.db).Where("beneficiary_reference = ?", "Patient/"+c.Param("id"))
+	if c.Query("all") != "true" {
+		query = query.Where("status IN ?", []string{models.CoverageActive, models.CoverageDraft})
+	}
+
+	coverages := []models.Coverage{}
+	if err := query.Order("created_at DESC").Find(&coverages).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch coverages",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, coverages)
+}
+
+// bindCoverage binds and validates a coverage request, writing the error response
+// on failure
+func (h *CoverageHandler) bindCoverage(c *gin.Context, req *models.CoverageRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	req.Payor.Name = strings.TrimSpace(req.Payor.Name)
+	req.SubscriberID = strings.TrimSpace(req.SubscriberID)
+	req.SubscriberName = strings.TrimSpace(req.SubscriberName)
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+
+	message := ""
+	switch {
+	case !subscriberIDPattern.MatchString(req.SubscriberID):
+		message = "subscriberId may only contain letters, digits and dashes"
+	case req.Relationship != "" && req.Relationship != models.CoverageSubscriberSelf && req.SubscriberName == "":
+		message = "subscriberName is required when the subscriber is not the patient"
+	case req.Period.Start != nil && req.Period.End != nil && req.Period.End.Before(*req.Period.Start):
+		message = "period end must not be before its start"
+	}
+	if message != "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: message,
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	return true
+}
+
+// checkDuplicate reports whether the patient has no other active or draft coverage
+// with the payor and subscriber ID of a coverage, writing the error response when it
+// does or the check fails
+func (h *CoverageHandler) checkDuplicate(c *gin.Context, db *gorm.DB, patientID string, coverage models.Coverage) bool {
+	if coverage.Status != models.CoverageActive && coverage.Status != models.CoverageDraft {
+		return true
+	}
+
+	query := db.Model(&models.Coverage{}).
+		Where("beneficiary_reference = ? AND status IN ?", "Patient/"+patientID, []string{models.CoverageActive, models.CoverageDraft}).
+		Where("LOWER(payor_name) = LOWER(?) AND subscriber_id = ?", coverage.Payor.Name, coverage.SubscriberID)
+	if coverage.ID != "" {
+		query = query.Where("id <> ?", coverage.ID)
+	}
+	var count int64
+	if err := query.Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check existing coverages",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	if count > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Coverage already recorded",
+			Message: "the patient already has a coverage with this payor and subscriber ID",
+			Code:    "COVERAGE_EXISTS",
+		})
+		return false
+	}
+	return true
+}
+
+// create records the coverage of a request for a patient
+func (h *CoverageHandler) create(c *gin.Context, patientID string, req models.CoverageRequest) {
+	db := writeDB(c, h.db)
+
+	// Validate that the referenced patient exists
+	var patient models.Patient
+	if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	coverage := models.Coverage{
+		Status:         req.Status,
+		Type:           req.Type,
+		Beneficiary:    models.Reference{Reference: "Patient/" + patientID, Display: req.Beneficiary.Display},
+		Payor:          req.Payor,
+		SubscriberID:   req.SubscriberID,
+		SubscriberName: req.SubscriberName,
+		Relationship:   req.Relationship,
+		Plan:           req.Plan,
+		Group:          req.Group,
+		Period:         req.Period,
+		Note:           req.Note,
+	}
+	if coverage.Status == "" {
+		coverage.Status = models.CoverageActive
+	}
+	if !h.checkDuplicate(c, db, patientID, coverage) {
+		return
+	}
+
+	if userID, exists := auth.GetUserID(c); exists {
+		coverage.CreatedBy = userID
+	}
+	if err := db.Create(&coverage).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create coverage",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "Coverage", coverage.CreatedBy, map[string]interface{}{
+		"coverage_id": coverage.ID,
+		"patient_id":  patientID,
+		"payor":       coverage.Payor.Name,
+	})
+
+	c.JSON(http.StatusCreated, coverage)
+}
+
+// findCoverage loads a coverage by the ID in the path, writing the error response
+// when it cannot
+func findCoverage(c *gin.Context, db *gorm.DB, coverage *models.Coverage) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(coverage).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Coverage not found",
+				Code:  "COVERAGE_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch coverage",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
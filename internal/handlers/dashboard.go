@@ -0,0 +1,43 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/dashboard"
+)
+
+// DashboardHandler serves the operational dashboard of the ops UI
+type DashboardHandler struct {
+	collector *dashboard.Collector
+}
+
+// NewDashboardHandler creates a new dashboard handler
+func NewDashboardHandler(collector *dashboard.Collector) *DashboardHandler {
+	return &DashboardHandler{collector: collector}
+}
+
+// GetDashboard returns the operational dashboard
+// @Summary Get operational dashboard
+// @Description Aggregate request and error rates, database pool usage, background queue depths, active sessions and recent security events. Request statistics are those of the instance serving the call (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} dashboard.Dashboard
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/dashboard [get]
+func (h *DashboardHandler) GetDashboard(c *gin.Context) {
+	d, err := h.collector.Collect(c.Request.Context())
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to collect dashboard",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, d)
+}
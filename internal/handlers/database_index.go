@@ -0,0 +1,144 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// DatabaseIndexHandler reports and builds the database indexes the server maintains
+// beyond those created by migration
+type DatabaseIndexHandler struct {
+	db     *gorm.DB
+	runner *operation.Runner
+}
+
+// NewDatabaseIndexHandler creates a new database index handler
+func NewDatabaseIndexHandler(db *gorm.DB, runner *operation.Runner) *DatabaseIndexHandler {
+	return &DatabaseIndexHandler{
+		db:     db,
+		runner: runner,
+	}
+}
+
+// DatabaseIndexesResponse lists the database indexes the server maintains
+type DatabaseIndexesResponse struct {
+	Data []database.IndexStatus `json:"data"`
+}
+
+// GetDatabaseIndexes lists the indexes the server maintains and their status
+// @Summary Get database indexes
+// @Description List the indexes created after migration with whether each is present, missing, invalid or unsupported by the database. An index is left invalid by a concurrent build that failed or was interrupted; PostgreSQL does not use it for queries until it is built again (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} DatabaseIndexesResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/indexes [get]
+func (h *DatabaseIndexHandler) GetDatabaseIndexes(c *gin.Context) {
+	statuses, err := database.IndexStatuses(h.db)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read database indexes",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, DatabaseIndexesResponse{Data: statuses})
+}
+
+// BuildDatabaseIndexes builds every missing or invalid index
+// @Summary Build database indexes
+// @Description Build the indexes that are missing and replace the invalid ones as the operation in Location, which reports how many indexes are done and can be polled and cancelled. On PostgreSQL indexes are built concurrently, so writes go on meanwhile; a cancelled build leaves its index invalid until it is built again (admin only)
+// @Tags admin
+// @Produce json
+// @Success 202 {object} models.Operation
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/indexes/build [post]
+func (h *DatabaseIndexHandler) BuildDatabaseIndexes(c *gin.Context) {
+	h.queue(c, "")
+}
+
+// RebuildDatabaseIndex rebuilds an index
+// @Summary Rebuild database index
+// @Description Rebuild an index, or create it when it is missing, as the operation in Location. On PostgreSQL an existing index keeps serving queries until its replacement is ready (admin only)
+// @Tags admin
+// @Produce json
+// @Param name path string true "Index name"
+// @Success 202 {object} models.Operation
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/indexes/{name}/rebuild [post]
+func (h *DatabaseIndexHandler) RebuildDatabaseIndex(c *gin.Context) {
+	statuses, err := database.IndexStatuses(h.db)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read database indexes",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	name := c.Param("name")
+	for _, status := range statuses {
+		if status.Name != name {
+			continue
+		}
+		if status.Status == database.IndexUnsupported {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error:   "Index is not supported",
+				Message: "the database cannot create " + name,
+				Code:    "INDEX_UNSUPPORTED",
+			})
+			return
+		}
+		h.queue(c, "Index/"+name)
+		return
+	}
+
+	respondError(c, http.StatusNotFound, ErrorResponse{
+		Error: "Index not found",
+		Code:  "INDEX_NOT_FOUND",
+	})
+}
+
+// queue queues an index build operation on target, empty for every missing or invalid
+// index, and answers with 202
+func (h *DatabaseIndexHandler) queue(c *gin.Context, target string) {
+	userID, _ := auth.GetUserID(c)
+	op, err := h.runner.Queue(h.db, models.OperationKindIndexes, target, userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to queue index build",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("index_build_requested", "Index", userID, map[string]interface{}{
+		"operation_id": op.ID,
+		"target":       target,
+	})
+
+	h.runner.Wake(models.OperationKindIndexes)
+	acceptOperation(c, op, op)
+}
@@ -0,0 +1,170 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/ucum"
+	"gorm.io/gorm"
+)
+
+// DeltaCheckHandler handles HTTP requests for delta check rules
+type DeltaCheckHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewDeltaCheckHandler creates a new delta check rule handler
+func NewDeltaCheckHandler(db *gorm.DB) *DeltaCheckHandler {
+	return &DeltaCheckHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// GetRules lists delta check rules
+// @Summary Get delta check rules
+// @Description List the delta check thresholds per observation code (admin only)
+// @Tags delta-checks
+// @Produce json
+// @Success 200 {array} models.DeltaCheckRule
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/delta-check-rules [get]
+func (h *DeltaCheckHandler) GetRules(c *gin.Context) {
+	var rules []models.DeltaCheckRule
+	if err := h.db.Order("code ASC").Find(&rules).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch delta check rules",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// PutRule creates or replaces the delta check rule of a code
+// @Summary Set delta check rule
+// @Description Set how much a quantity result for the code may change from the patient's previous result, as an absolute change, a percentage or both (admin only)
+// @Tags delta-checks
+// @Accept json
+// @Produce json
+// @Param code path string true "Observation code"
+// @Param rule body models.DeltaCheckRuleRequest true "Thresholds"
+// @Success 200 {object} models.DeltaCheckRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/delta-check-rules/{code} [put]
+func (h *DeltaCheckHandler) PutRule(c *gin.Context) {
+	var req models.DeltaCheckRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+	if req.Unit != "" {
+		if _, err := ucum.Parse(req.Unit); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid unit",
+				Message: err.Error(),
+				Code:    "INVALID_UNIT",
+			})
+			return
+		}
+	}
+
+	rule := models.DeltaCheckRule{Code: c.Param("code")}
+	if err := h.db.Where("code = ?", rule.Code).First(&rule).Error; err != nil && err != gorm.ErrRecordNotFound {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch delta check rule",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	rule.System = req.System
+	rule.Display = req.Display
+	rule.AbsoluteChange = req.AbsoluteChange
+	rule.Unit = req.Unit
+	rule.PercentChange = req.PercentChange
+	rule.WindowHours = req.WindowHours
+	rule.RaiseAlert = req.RaiseAlert
+	rule.Active = req.Active == nil || *req.Active
+	if err := h.db.Save(&rule).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to save delta check rule",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "DeltaCheckRule", userID, map[string]interface{}{
+		"code": rule.Code,
+	})
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteRule removes the delta check rule of a code
+// @Summary Delete delta check rule
+// @Description Stop delta checking results for the code (admin only)
+// @Tags delta-checks
+// @Param code path string true "Observation code"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/delta-check-rules/{code} [delete]
+func (h *DeltaCheckHandler) DeleteRule(c *gin.Context) {
+	result := h.db.Where("code = ?", c.Param("code")).Delete(&models.DeltaCheckRule{})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete delta check rule",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Delta check rule not found",
+			Code:  "RULE_NOT_FOUND",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "DeltaCheckRule", userID, map[string]interface{}{
+		"code": c.Param("code"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,417 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// DeviceHandler handles HTTP requests for devices such as lab instruments
+type DeviceHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewDeviceHandler creates a new device handler
+func NewDeviceHandler(db *gorm.DB) *DeviceHandler {
+	return &DeviceHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// CreateDevice registers a device
+// @Summary Create device
+// @Description Register a laboratory instrument or other device that produces results, with its manufacturer, model and serial number. Observations refer to it as Device/{id}. A manufacturer's serial number can only be registered once.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param device body models.DeviceRequest true "Device"
+// @Success 201 {object} models.Device
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/devices [post]
+func (h *DeviceHandler) CreateDevice(c *gin.Context) {
+	var req models.DeviceRequest
+	if !h.bindDevice(c, &req) {
+		return
+	}
+
+	db := writeDB(c, h.db)
+	device := models.Device{Status: models.DeviceActive}
+	applyDeviceRequest(&device, req)
+	if !serialNumberAvailable(c, db, device) {
+		return
+	}
+
+	if userID, exists := auth.GetUserID(c); exists {
+		device.CreatedBy = userID
+	}
+	if err := db.Create(&device).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create device",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "Device", device.CreatedBy, map[string]interface{}{
+		"device_id":     device.ID,
+		"serial_number": device.SerialNumber,
+	})
+
+	c.JSON(http.StatusCreated, device)
+}
+
+// GetDevices lists devices
+// @Summary Get devices
+// @Description List devices by name
+// @Tags devices
+// @Produce json
+// @Param status query string false "Filter by status (active, inactive, entered-in-error, unknown)"
+// @Param department query string false "Filter by department"
+// @Param manufacturer query string false "Filter by manufacturer"
+// @Param serialNumber query string false "Filter by serial number"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.Device}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/devices [get]
+func (h *DeviceHandler) GetDevices(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.Device{})
+	for param, column := range map[string]string{
+		"status":       "status",
+		"department":   "department",
+		"serialNumber": "serial_number",
+	} {
+		if value := strings.TrimSpace(c.Query(param)); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if manufacturer := strings.TrimSpace(c.Query("manufacturer")); manufacturer != "" {
+		query = query.Where("LOWER(manufacturer) = LOWER(?)", manufacturer)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count devices",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var devices []models.Device
+	if err := query.Order("device_name, id").Offset((page - 1) * limit).Limit(limit).Find(&devices).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch devices",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, devices, total, page, limit)
+}
+
+// GetDevice retrieves a device by ID
+// @Summary Get device
+// @Description Get a specific device by its ID
+// @Tags devices
+// @Produce json
+// @Param id path string true "Device ID"
+// @Success 200 {object} models.Device
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/devices/{id} [get]
+func (h *DeviceHandler) GetDevice(c *gin.Context) {
+	var device models.Device
+	if !findDevice(c, readDB(c, h.db), &device) {
+		return
+	}
+
+	c.JSON(http.StatusOK, device)
+}
+
+// UpdateDevice replaces a device
+// @Summary Update device
+// @Description Replace the name, type, manufacturer, model, serial number, department and note of a device, or mark it inactive when it is taken out of service; the status is kept when left out. Results can only be recorded against active devices and those of unknown status.
+// @Tags devices
+// @Accept json
+// @Produce json
+// @Param id path string true "Device ID"
+// @Param device body models.DeviceRequest true "Device"
+// @Success 200 {object} models.Device
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/devices/{id} [put]
+func (h *DeviceHandler) UpdateDevice(c *gin.Context) {
+	var req models.DeviceRequest
+	if !h.bindDevice(c, &req) {
+		return
+	}
+
+	db := writeDB(c, h.db)
+	var device models.Device
+	if !findDevice(c, db, &device) {
+		return
+	}
+
+	applyDeviceRequest(&device, req)
+	if !serialNumberAvailable(c, db, device) {
+		return
+	}
+
+	if err := db.Model(&device).Select("status", "device_name", "type", "manufacturer", "model_number",
+		"serial_number", "department", "note", "updated_at").Updates(&device).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update device",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "Device", userID, map[string]interface{}{
+		"device_id":     device.ID,
+		"serial_number": device.SerialNumber,
+		"status":        device.Status,
+	})
+
+	c.JSON(http.StatusOK, device)
+}
+
+// DeleteDevice removes a device
+// @Summary Delete device
+// @Description Delete a device registered by mistake (admin only). A device observations refer to cannot be deleted; mark it inactive or entered-in-error instead.
+// @Tags devices
+// @Param id path string true "Device ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/devices/{id} [delete]
+func (h *DeviceHandler) DeleteDevice(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var device models.Device
+	if !findDevice(c, db, &device) {
+		return
+	}
+
+	// Observations in the trash count too, so restoring one does not leave it dangling
+	var linked int64
+	if err := db.Unscoped().Model(&models.Observation{}).Where("device_reference = ?", "Device/"+device.ID).
+		Count(&linked).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check device references",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if linked > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Device is in use",
+			Message: fmt.Sprintf("%d observation(s) refer to the device; mark it inactive or entered-in-error instead", linked),
+			Code:    "DEVICE_IN_USE",
+		})
+		return
+	}
+
+	if err := db.Delete(&device).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete device",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "Device", userID, map[string]interface{}{
+		"device_id":     device.ID,
+		"serial_number": device.SerialNumber,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindDevice binds and validates a device request, writing the error response on
+// failure
+func (h *DeviceHandler) bindDevice(c *gin.Context, req *models.DeviceRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	req.DeviceName = strings.TrimSpace(req.DeviceName)
+	req.Manufacturer = strings.TrimSpace(req.Manufacturer)
+	req.SerialNumber = strings.TrimSpace(req.SerialNumber)
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	return true
+}
+
+// applyDeviceRequest sets the fields of a device from a request
+func applyDeviceRequest(device *models.Device, req models.DeviceRequest) {
+	if req.Status != "" {
+		device.Status = req.Status
+	}
+	device.DeviceName = req.DeviceName
+	device.Type = req.Type
+	device.Manufacturer = req.Manufacturer
+	device.ModelNumber = req.ModelNumber
+	device.SerialNumber = req.SerialNumber
+	device.Department = req.Department
+	device.Note = req.Note
+}
+
+// serialNumberAvailable reports whether no other device has the manufacturer and serial
+// number of a device, writing the error response when one does or the check fails
+func serialNumberAvailable(c *gin.Context, db *gorm.DB, device models.Device) bool {
+	query := db.Model(&models.Device{}).
+		Where("LOWER(manufacturer) = LOWER(?) AND serial_number = ?", device.Manufacturer, device.SerialNumber)
+	if device.ID != "" {
+		query = query.Where("id <> ?", device.ID)
+	}
+	var existing int64
+	if err := query.Count(&existing).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check serial number",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	if existing > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "A device of this manufacturer with this serial number already exists",
+			Code:  "DEVICE_EXISTS",
+		})
+		return false
+	}
+	return true
+}
+
+// findDevice loads a device by the ID in the path, writing the error response when it
+// cannot
+func findDevice(c *gin.Context, db *gorm.DB, device *models.Device) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(device).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Device not found",
+				Code:  "DEVICE_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch device",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
+
+// resolveDeviceReference checks the device an observation refers to, writing the error
+// response when it cannot be used. A Device/{id} reference must name a registered
+// device that is active or of unknown status; it is returned with the device's name as
+// its display. References without a reference, e.g. by identifier only, are returned
+// as they are.
+func resolveDeviceReference(c *gin.Context, db *gorm.DB, ref *models.Reference) (*models.Reference, bool) {
+	if ref == nil || ref.Reference == "" {
+		return ref, true
+	}
+	id, ok := strings.CutPrefix(ref.Reference, "Device/")
+	if !ok || id == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid device reference",
+			Message: "device.reference must be of the form Device/{id}",
+			Code:    "INVALID_DEVICE_REFERENCE",
+		})
+		return nil, false
+	}
+
+	var device models.Device
+	if err := db.Where("id = ?", id).First(&device).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Referenced device not found",
+				Message: ref.Reference + " does not exist",
+				Code:    "DEVICE_NOT_FOUND",
+			})
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate device reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return nil, false
+	}
+
+	if device.Status == models.DeviceInactive || device.Status == models.DeviceEnteredInError {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid device reference",
+			Message: "the device is " + device.Status,
+			Code:    "INVALID_DEVICE_REFERENCE",
+		})
+		return nil, false
+	}
+
+	return &models.Reference{
+		Reference:  "Device/" + device.ID,
+		Type:       "Device",
+		Identifier: ref.Identifier,
+		Display:    device.DeviceName,
+	}, true
+}
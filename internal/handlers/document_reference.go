@@ -0,0 +1,559 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DocumentReferenceHandler handles HTTP requests for patient documents, such as
+// scanned lab reports, whose files are kept in attachment storage
+type DocumentReferenceHandler struct {
+	db            *gorm.DB
+	storage       storage.Storage
+	signer        *storage.URLSigner
+	urlTTL        time.Duration
+	maxUploadSize int64
+	validator     *validator.Validate
+	undo          time.Duration
+}
+
+// NewDocumentReferenceHandler creates a new document reference handler. Files are
+// downloaded through URLs signed by signer that expire after urlTTL. Deleted documents
+// can be restored until the undo window has passed.
+func NewDocumentReferenceHandler(db *gorm.DB, store storage.Storage, signer *storage.URLSigner, urlTTL time.Duration, maxUploadSize int64, undoWindow time.Duration) *DocumentReferenceHandler {
+	return &DocumentReferenceHandler{
+		db:            db,
+		storage:       store,
+		signer:        signer,
+		urlTTL:        urlTTL,
+		maxUploadSize: maxUploadSize,
+		validator:     validator.New(),
+		undo:          undoWindow,
+	}
+}
+
+// UploadPatientDocument uploads a document of a patient
+// @Summary Upload patient document
+// @Description Upload a PDF or image, such as a scanned lab report, as a document of the patient. The content type is sniffed from the file rather than taken from the client, and only PDF, JPEG, PNG and GIF files are accepted. The type is a LOINC document code and defaults to 11502-2 (laboratory report); date is when the document was created and defaults to now.
+// @Tags document-references
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param file formData file true "Document file"
+// @Param title formData string false "Title, by default the file name"
+// @Param description formData string false "Description"
+// @Param type formData string false "LOINC document type code"
+// @Param date formData string false "When the document was created (RFC 3339 or YYYY-MM-DD)"
+// @Param author formData string false "Author, e.g. Practitioner/{id}"
+// @Success 201 {object} models.DocumentReference
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/document-references [post]
+func (h *DocumentReferenceHandler) UploadPatientDocument(c *gin.Context) {
+	patientID := c.Param("id")
+	var patient models.Patient
+	if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadSize)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			respondError(c, http.StatusRequestEntityTooLarge, ErrorResponse{
+				Error: "Document file exceeds the maximum upload size",
+				Code:  "DOCUMENT_TOO_LARGE",
+			})
+			return
+		}
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Document file is required",
+			Message: err.Error(),
+			Code:    "MISSING_DOCUMENT_FILE",
+		})
+		return
+	}
+	if fileHeader.Size > h.maxUploadSize {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error: "Document file exceeds the maximum upload size",
+			Code:  "DOCUMENT_TOO_LARGE",
+		})
+		return
+	}
+
+	date := time.Now().UTC()
+	if value := strings.TrimSpace(c.PostForm("date")); value != "" {
+		if date, err = parseDocumentDate(value); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: "date must be an RFC 3339 timestamp or a YYYY-MM-DD date",
+				Code:    "VALIDATION_FAILED",
+			})
+			return
+		}
+	}
+
+	var authors []models.Reference
+	if author := strings.TrimSpace(c.PostForm("author")); author != "" {
+		var ok bool
+		if authors, ok = resolvePerformers(c, h.db, []models.Reference{{Reference: author}}); !ok {
+			return
+		}
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to read document file",
+			Message: err.Error(),
+			Code:    "INVALID_DOCUMENT_FILE",
+		})
+		return
+	}
+	defer file.Close()
+
+	// Validate the sniffed content type rather than trusting the client header
+	head := make([]byte, 512)
+	n, err := io.ReadFull(file, head)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to read document file",
+			Message: err.Error(),
+			Code:    "INVALID_DOCUMENT_FILE",
+		})
+		return
+	}
+	if n == 0 {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Document file is empty",
+			Code:  "INVALID_DOCUMENT_FILE",
+		})
+		return
+	}
+	head = head[:n]
+	contentType := http.DetectContentType(head)
+	if !models.AllowedMediaContentTypes[contentType] {
+		respondError(c, http.StatusUnsupportedMediaType, ErrorResponse{
+			Error:   "Unsupported document content type",
+			Message: contentType,
+			Code:    "UNSUPPORTED_MEDIA_TYPE",
+		})
+		return
+	}
+
+	title := strings.TrimSpace(c.PostForm("title"))
+	if title == "" {
+		title = fileHeader.Filename
+	}
+	typeCode := strings.TrimSpace(c.PostForm("type"))
+	if typeCode == "" {
+		typeCode = models.LabReportDocumentType
+	}
+
+	now := time.Now().UTC()
+	document := models.DocumentReference{
+		Type: models.CodeableConcept{
+			Coding: []models.Coding{{System: models.LOINCSystem, Code: typeCode}},
+		},
+		Subject:     models.Reference{Reference: "Patient/" + patient.ID},
+		Date:        date,
+		Author:      authors,
+		Description: strings.TrimSpace(c.PostForm("description")),
+		Content: models.Attachment{
+			ContentType: contentType,
+			Title:       title,
+			Creation:    &now,
+		},
+	}
+	if typeCode == models.LabReportDocumentType {
+		document.Type.Coding[0].Display = "Laboratory report"
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		document.CreatedBy = userID
+	}
+
+	// Assign the ID up front so the storage key can be derived from it
+	if err := document.BeforeCreate(h.db); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to prepare document record",
+			Message: err.Error(),
+			Code:    "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	// The file is streamed to storage and hashed on the way
+	hash := sha256.New()
+	document.StorageKey = models.DocumentStorageKey(document.ID)
+	size, err := h.storage.Put(document.StorageKey, io.TeeReader(io.MultiReader(bytes.NewReader(head), file), hash))
+	if err != nil {
+		h.removeStoredContent(&document)
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to store document file",
+			Message: err.Error(),
+			Code:    "STORAGE_ERROR",
+		})
+		return
+	}
+	document.Content.Size = size
+	document.Content.Hash = base64.StdEncoding.EncodeToString(hash.Sum(nil))
+	document.Content.URL = "DocumentReference/" + document.ID
+
+	if err := h.db.Create(&document).Error; err != nil {
+		h.removeStoredContent(&document)
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create document record",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "DocumentReference", document.CreatedBy, map[string]interface{}{
+		"document_id":  document.ID,
+		"patient_id":   patient.ID,
+		"content_type": contentType,
+		"size":         size,
+	})
+
+	h.signURL(&document)
+	c.JSON(http.StatusCreated, document)
+}
+
+// GetPatientDocuments lists the documents of a patient
+// @Summary Get patient documents
+// @Description List the documents of a patient with short-lived download URLs, most recent first. Documents entered in error are left out unless all is set or they are asked for by status.
+// @Tags document-references
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param status query string false "Filter by status (current, superseded, entered-in-error)"
+// @Param type query string false "Filter by LOINC document type code"
+// @Param all query bool false "Include documents entered in error"
+// @Success 200 {array} models.DocumentReference
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/document-references [get]
+func (h *DocumentReferenceHandler) GetPatientDocuments(c *gin.Context) {
+	query := readDB(c, h.db).Where("subject_reference = ?", "Patient/"+c.Param("id"))
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		query = query.Where("status = ?", status)
+	} else if c.Query("all") != "true" {
+		query = query.Where("status <> ?", models.DocumentReferenceEnteredInError)
+	}
+
+	documents := []models.DocumentReference{}
+	if err := query.Order("date DESC").Find(&documents).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch documents",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// The type is stored as JSON, so it is matched here rather than in the query
+	if code := strings.TrimSpace(c.Query("type")); code != "" {
+		filtered := documents[:0]
+		for _, document := range documents {
+			for _, coding := range document.Type.Coding {
+				if coding.Code == code {
+					filtered = append(filtered, document)
+					break
+				}
+			}
+		}
+		documents = filtered
+	}
+
+	for i := range documents {
+		h.signURL(&documents[i])
+	}
+
+	c.JSON(http.StatusOK, documents)
+}
+
+// GetDocumentReference retrieves a document's metadata
+// @Summary Get document reference
+// @Description Get a document's metadata with a short-lived download URL
+// @Tags document-references
+// @Produce json
+// @Param id path string true "Document reference ID"
+// @Success 200 {object} models.DocumentReference
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/document-references/{id} [get]
+func (h *DocumentReferenceHandler) GetDocumentReference(c *gin.Context) {
+	var document models.DocumentReference
+	if !findDocumentReference(c, readDB(c, h.db), &document) {
+		return
+	}
+
+	h.signURL(&document)
+	c.JSON(http.StatusOK, document)
+}
+
+// DownloadDocumentContent streams a document's file for a signed URL
+// @Summary Download document content
+// @Description Download a document's file using a signed URL obtained from the document endpoints. The file is streamed from storage with its SHA-256 in the Digest header.
+// @Tags document-references
+// @Produce octet-stream
+// @Param id path string true "Document reference ID"
+// @Param expires query int true "Signature expiry (unix seconds)"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} binary
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /api/v1/document-references/{id}/content [get]
+func (h *DocumentReferenceHandler) DownloadDocumentContent(c *gin.Context) {
+	if !h.signer.Verify(c.Request.URL.Path, c.Query("expires"), c.Query("signature")) {
+		respondError(c, http.StatusForbidden, ErrorResponse{
+			Error: "Invalid or expired download URL",
+			Code:  "INVALID_SIGNATURE",
+		})
+		return
+	}
+
+	var document models.DocumentReference
+	if !findDocumentReference(c, h.db, &document) {
+		return
+	}
+
+	reader, err := h.storage.Get(document.StorageKey)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Document content not found",
+				Code:  "DOCUMENT_CONTENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read document content",
+			Message: err.Error(),
+			Code:    "STORAGE_ERROR",
+		})
+		return
+	}
+	defer reader.Close()
+
+	c.Header("Digest", "sha-256="+document.Content.Hash)
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Disposition", mime.FormatMediaType("inline", map[string]string{"filename": document.Content.Title}))
+	c.DataFromReader(http.StatusOK, document.Content.Size, document.Content.ContentType, reader, nil)
+}
+
+// UpdateDocumentStatus changes the status of a document
+// @Summary Update document status
+// @Description Mark a document superseded, for example by a corrected report, or entered-in-error when it was uploaded for the wrong patient, or make it current again
+// @Tags document-references
+// @Accept json
+// @Produce json
+// @Param id path string true "Document reference ID"
+// @Param request body models.DocumentReferenceStatusRequest true "New status"
+// @Success 200 {object} models.DocumentReference
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/document-references/{id}/status [post]
+func (h *DocumentReferenceHandler) UpdateDocumentStatus(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.DocumentReferenceStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var document models.DocumentReference
+	if !findDocumentReference(c, db, &document) {
+		return
+	}
+
+	if err := db.Model(&document).Update("status", req.Status).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update document",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "DocumentReference", userID, map[string]interface{}{
+		"document_id": document.ID,
+		"status":      document.Status,
+	})
+
+	h.signURL(&document)
+	c.JSON(http.StatusOK, document)
+}
+
+// DeleteDocumentReference deletes a document
+// @Summary Delete document reference
+// @Description Delete a document uploaded by mistake (admin only). The deletion can be undone until the undo window passes; the stored file is then removed.
+// @Tags document-references
+// @Param id path string true "Document reference ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/document-references/{id} [delete]
+func (h *DocumentReferenceHandler) DeleteDocumentReference(c *gin.Context) {
+	var document models.DocumentReference
+	if !findDocumentReference(c, h.db, &document) {
+		return
+	}
+
+	// Mark the document as pending deletion; the retention job removes it and its file
+	// after the undo window
+	if err := trashRecord(c, h.db, &document); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete document",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "DocumentReference", userID, map[string]interface{}{
+		"document_id": document.ID,
+		"patient_id":  strings.TrimPrefix(document.Subject.Reference, "Patient/"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// UndeleteDocumentReference restores a deleted document within the undo window
+// @Summary Undelete document reference
+// @Description Restore a deleted document as long as the undo window has not passed. Documents deleted with their patient are restored by undeleting the patient (admin only).
+// @Tags document-references
+// @Produce json
+// @Param id path string true "Document reference ID"
+// @Success 200 {object} models.DocumentReference
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/document-references/{id}/undelete [post]
+func (h *DocumentReferenceHandler) UndeleteDocumentReference(c *gin.Context) {
+	var document models.DocumentReference
+	if !undeleteRecord(c, h.db, "DocumentReference", &document, h.undo, ErrorResponse{
+		Error: "Document reference not found",
+		Code:  "DOCUMENT_REFERENCE_NOT_FOUND",
+	}) {
+		return
+	}
+
+	h.signURL(&document)
+	c.JSON(http.StatusOK, document)
+}
+
+// signURL replaces the stored content URL with a short-lived signed download URL
+func (h *DocumentReferenceHandler) signURL(document *models.DocumentReference) {
+	document.Content.URL = h.signer.SignedURL("/api/v1/document-references/"+document.ID+"/content", h.urlTTL)
+}
+
+// removeStoredContent deletes the stored file of a document, logging failures
+func (h *DocumentReferenceHandler) removeStoredContent(document *models.DocumentReference) {
+	if err := h.storage.Delete(document.StorageKey); err != nil && err != storage.ErrNotFound {
+		logger.Warn("Failed to delete stored document content",
+			zap.String("document_id", document.ID),
+			zap.String("key", document.StorageKey),
+			zap.Error(err),
+		)
+	}
+}
+
+// parseDocumentDate parses the date of an uploaded document, an RFC 3339 timestamp
+// or a calendar date
+func parseDocumentDate(value string) (time.Time, error) {
+	if date, err := time.Parse(time.RFC3339, value); err == nil {
+		return date.UTC(), nil
+	}
+	return time.Parse("2006-01-02", value)
+}
+
+// findDocumentReference loads the document reference in the path, writing the error
+// response when it is not found
+func findDocumentReference(c *gin.Context, db *gorm.DB, document *models.DocumentReference) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(document).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Document reference not found",
+				Code:  "DOCUMENT_REFERENCE_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch document reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// DrugInteractionHandler handles HTTP requests for the local drug interaction table
+type DrugInteractionHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewDrugInteractionHandler creates a new drug interaction handler
+func NewDrugInteractionHandler(db *gorm.DB) *DrugInteractionHandler {
+	return &DrugInteractionHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// GetInteractions lists drug interactions
+// @Summary Get drug interactions
+// @Description List the interactions of the local knowledge base, optionally those of one medication code
+// @Tags medications
+// @Produce json
+// @Param code query string false "Filter by medication code"
+// @Success 200 {array} models.DrugInteraction
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/drug-interactions [get]
+func (h *DrugInteractionHandler) GetInteractions(c *gin.Context) {
+	query := readDB(c, h.db).Model(&models.DrugInteraction{})
+	if code := strings.TrimSpace(c.Query("code")); code != "" {
+		query = query.Where("code_a = ? OR code_b = ?", code, code)
+	}
+
+	var interactions []models.DrugInteraction
+	if err := query.Order("code_a ASC, code_b ASC").Find(&interactions).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch drug interactions",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, interactions)
+}
+
+// PutInteraction creates or replaces the interaction between two medication codes
+// @Summary Set drug interaction
+// @Description Record that two medication codes interact, replacing any interaction already recorded for the pair. New prescriptions are checked against it when the interaction provider is the local table. Severe interactions require an override reason to prescribe (admin only).
+// @Tags medications
+// @Accept json
+// @Produce json
+// @Param interaction body models.DrugInteractionRequest true "Interaction"
+// @Success 200 {object} models.DrugInteraction
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/drug-interactions [post]
+func (h *DrugInteractionHandler) PutInteraction(c *gin.Context) {
+	var req models.DrugInteractionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	// Each pair is stored once, in code order
+	codeA, codeB := req.CodeA, req.CodeB
+	if codeB < codeA {
+		codeA, codeB = codeB, codeA
+	}
+
+	interaction := models.DrugInteraction{CodeA: codeA, CodeB: codeB}
+	if err := h.db.Where("code_a = ? AND code_b = ?", codeA, codeB).First(&interaction).Error; err != nil && err != gorm.ErrRecordNotFound {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch drug interaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	interaction.Severity = req.Severity
+	interaction.Description = req.Description
+	if interaction.CreatedBy == "" {
+		interaction.CreatedBy = userID
+	}
+	if err := h.db.Save(&interaction).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to save drug interaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("update", "DrugInteraction", userID, map[string]interface{}{
+		"interaction_id": interaction.ID,
+		"code_a":         codeA,
+		"code_b":         codeB,
+		"severity":       interaction.Severity,
+	})
+
+	c.JSON(http.StatusOK, interaction)
+}
+
+// DeleteInteraction removes a drug interaction
+// @Summary Delete drug interaction
+// @Description Remove an interaction from the local knowledge base (admin only)
+// @Tags medications
+// @Param id path string true "Interaction ID"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/drug-interactions/{id} [delete]
+func (h *DrugInteractionHandler) DeleteInteraction(c *gin.Context) {
+	result := h.db.Where("id = ?", c.Param("id")).Delete(&models.DrugInteraction{})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete drug interaction",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Drug interaction not found",
+			Code:  "INTERACTION_NOT_FOUND",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "DrugInteraction", userID, map[string]interface{}{
+		"interaction_id": c.Param("id"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
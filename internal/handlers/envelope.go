@@ -0,0 +1,341 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/jsonenc"
+)
+
+// Envelopes of list responses
+const (
+	EnvelopePaginated = "paginated" // PaginatedResponse
+	EnvelopeBare      = "bare"      // A JSON array
+	EnvelopeBundle    = "bundle"    // A FHIR searchset Bundle
+)
+
+// envelopeKey is the context key of the configured default envelope
+const envelopeKey = "response_envelope"
+
+// fastEncodingKey is the context key set when lists are encoded with the hand-written
+// encoders of their models
+const fastEncodingKey = "fast_list_encoding"
+
+// maxPooledBuffer bounds the encoding buffers kept for reuse, so one very large
+// response does not hold on to its memory
+const maxPooledBuffer = 1 << 20
+
+// listBuffers are reused between list responses written by the hand-written encoders
+var listBuffers = sync.Pool{
+	New: func() interface{} {
+		b := make([]byte, 0, 64<<10)
+		return &b
+	},
+}
+
+// ResponseEnvelopeMiddleware sets the envelope of list responses for requests that do
+// not choose one with the _envelope parameter or by asking for FHIR JSON
+func ResponseEnvelopeMiddleware(defaultEnvelope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Set(envelopeKey, defaultEnvelope)
+		c.Next()
+	}
+}
+
+// FastListEncodingMiddleware makes list responses of observations and patients be
+// encoded by the models' hand-written JSON encoders, which write the same JSON as
+// encoding/json without reflection and into reused buffers
+func FastListEncodingMiddleware(enabled bool) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if enabled {
+			c.Set(fastEncodingKey, true)
+		}
+		c.Next()
+	}
+}
+
+// responseEnvelope returns the envelope negotiated for a list response: the _envelope
+// parameter, then a Bundle for clients asking for FHIR JSON, then the configured default
+func responseEnvelope(c *gin.Context) (string, bool) {
+	switch envelope := strings.ToLower(c.Query("_envelope")); envelope {
+	case EnvelopePaginated, EnvelopeBare, EnvelopeBundle:
+		return envelope, true
+	case "":
+	default:
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid envelope",
+			Message: "_envelope must be paginated, bare or bundle",
+			Code:    "INVALID_ENVELOPE",
+		})
+		return "", false
+	}
+
+	if wantsFHIR(c) {
+		return EnvelopeBundle, true
+	}
+	if envelope := c.GetString(envelopeKey); envelope != "" {
+		return envelope, true
+	}
+	return EnvelopePaginated, true
+}
+
+// respondPage writes a page of a list in the envelope negotiated for the request.
+// items must be a slice; a Bundle is only available for slices of FHIR resources, and
+// lists of anything else fall back to PaginatedResponse unless the client asked for
+// a Bundle explicitly.
+func respondPage(c *gin.Context, items interface{}, total int64, page, limit int) {
+	envelope, ok := responseEnvelope(c)
+	if !ok {
+		return
+	}
+
+	pages := pageLinks(c, total, page, limit)
+	var entries []fhir.BundleEntry
+	if envelope == EnvelopeBundle {
+		if entries, ok = bundleEntries(items); !ok {
+			if strings.EqualFold(c.Query("_envelope"), EnvelopeBundle) {
+				respondError(c, http.StatusNotAcceptable, ErrorResponse{
+					Error:   "Envelope not available",
+					Message: "only lists of FHIR resources can be returned as a Bundle",
+					Code:    "ENVELOPE_NOT_AVAILABLE",
+				})
+				return
+			}
+			envelope = EnvelopePaginated
+		}
+	}
+
+	// Every envelope carries the page in headers, so generic clients can page through a
+	// list without reading its body. The envelope depends on Accept, which caches must
+	// take into account.
+	setPageHeaders(c, pages, total)
+	c.Writer.Header().Add("Vary", "Accept")
+
+	if envelope != EnvelopeBundle && c.GetBool(fastEncodingKey) && writeFastPage(c, envelope, items, total, page, limit) {
+		return
+	}
+
+	switch envelope {
+	case EnvelopeBundle:
+		renderFHIR(c, http.StatusOK, pageBundle(entries, total, pages))
+		return
+	case EnvelopeBare:
+		// An empty page is an empty array rather than null
+		if value := reflect.Indirect(reflect.ValueOf(items)); value.Kind() == reflect.Slice && value.IsNil() {
+			items = reflect.MakeSlice(value.Type(), 0, 0).Interface()
+		}
+		c.JSON(http.StatusOK, items)
+		return
+	}
+
+	c.JSON(http.StatusOK, PaginatedResponse{
+		Data:       items,
+		Total:      total,
+		Page:       page,
+		Limit:      limit,
+		TotalPages: (total + int64(limit) - 1) / int64(limit),
+	})
+}
+
+// RespondPage writes a page of a list as respondPage does, for callers outside the
+// handlers such as the loadtest benchmarks
+func RespondPage(c *gin.Context, items interface{}, total int64, page, limit int) {
+	respondPage(c, items, total, page, limit)
+}
+
+// writeFastPage writes a page in the paginated or bare envelope with the hand-written
+// encoders of its models, reporting whether the items' type has them
+func writeFastPage(c *gin.Context, envelope string, items interface{}, total int64, page, limit int) bool {
+	buffer := listBuffers.Get().(*[]byte)
+	defer func() {
+		if cap(*buffer) <= maxPooledBuffer {
+			listBuffers.Put(buffer)
+		}
+	}()
+
+	b := (*buffer)[:0]
+	var obj jsonenc.Object
+	if envelope != EnvelopeBare {
+		obj = jsonenc.Begin(b)
+		b = obj.Key("data")
+	}
+	switch list := items.(type) {
+	case []models.Observation:
+		if list == nil && envelope == EnvelopeBare {
+			list = []models.Observation{}
+		}
+		b = jsonenc.AppendArray(b, list, func(b []byte, o *models.Observation) []byte { return o.AppendJSON(b) })
+	case []models.Patient:
+		if list == nil && envelope == EnvelopeBare {
+			list = []models.Patient{}
+		}
+		b = jsonenc.AppendArray(b, list, func(b []byte, p *models.Patient) []byte { return p.AppendJSON(b) })
+	default:
+		return false
+	}
+	if envelope != EnvelopeBare {
+		// The fields of PaginatedResponse
+		obj.Set(b)
+		obj.Int("total", total)
+		obj.Int("page", int64(page))
+		obj.Int("limit", int64(limit))
+		obj.Int("totalPages", (total+int64(limit)-1)/int64(limit))
+		b = obj.End()
+	}
+
+	*buffer = b
+	c.Data(http.StatusOK, "application/json; charset=utf-8", b)
+	return true
+}
+
+// pageLink is a link to a page of a list
+type pageLink struct {
+	relation string
+	url      string
+}
+
+// linkRelations are the RFC 5988 relations of the page links sent in the Link header
+var linkRelations = map[string]string{
+	"first":    "first",
+	"previous": "prev",
+	"next":     "next",
+	"last":     "last",
+}
+
+// pageLinks returns the links to the current, first, previous, next and last pages
+// of a list, as the request's URL with its page parameter replaced
+func pageLinks(c *gin.Context, total int64, page, limit int) []pageLink {
+	last := int((total + int64(limit) - 1) / int64(limit))
+	if last < 1 {
+		last = 1
+	}
+	at := func(relation string, n int) pageLink {
+		query := c.Request.URL.Query()
+		query.Set("page", strconv.Itoa(n))
+		query.Set("limit", strconv.Itoa(limit))
+		return pageLink{relation: relation, url: c.Request.URL.Path + "?" + query.Encode()}
+	}
+
+	links := []pageLink{at("self", page), at("first", 1)}
+	if page > 1 {
+		links = append(links, at("previous", page-1))
+	}
+	if page < last {
+		links = append(links, at("next", page+1))
+	}
+	return append(links, at("last", last))
+}
+
+// setPageHeaders sends the total of a list in X-Total-Count and the links to its
+// pages in an RFC 5988 Link header
+func setPageHeaders(c *gin.Context, pages []pageLink, total int64) {
+	links := make([]string, 0, len(pages))
+	for _, link := range pages {
+		if relation := linkRelations[link.relation]; relation != "" {
+			links = append(links, fmt.Sprintf(`<%s>; rel="%s"`, link.url, relation))
+		}
+	}
+	c.Header("Link", strings.Join(links, ", "))
+	c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+}
+
+// pageBundle returns a searchset Bundle of a page of a list with the links to its pages
+func pageBundle(entries []fhir.BundleEntry, total int64, pages []pageLink) *fhir.Bundle {
+	bundle := fhir.NewSearchBundle(total, entries)
+	for _, link := range pages {
+		bundle.Link = append(bundle.Link, fhir.BundleLink{Relation: link.relation, URL: link.url})
+	}
+	return bundle
+}
+
+// bundleEntries returns the entries of a Bundle of a slice of FHIR resources, or false
+// when the slice holds anything else
+func bundleEntries(items interface{}) ([]fhir.BundleEntry, bool) {
+	value := reflect.Indirect(reflect.ValueOf(items))
+	if value.Kind() != reflect.Slice {
+		return nil, false
+	}
+	if value.Len() == 0 {
+		// An empty list is a Bundle when its element type is a resource
+		_, _, ok := bundleResource(reflect.New(value.Type().Elem()).Interface())
+		return nil, ok
+	}
+
+	entries := make([]fhir.BundleEntry, 0, value.Len())
+	for i := 0; i < value.Len(); i++ {
+		item := value.Index(i)
+		if item.Kind() != reflect.Ptr {
+			item = item.Addr()
+		}
+		fullURL, resource, ok := bundleResource(item.Interface())
+		if !ok {
+			return nil, false
+		}
+		entries = append(entries, fhir.BundleEntry{FullURL: fullURL, Resource: resource})
+	}
+	return entries, true
+}
+
+// bundleResource returns the full URL and FHIR representation of a resource. Patients
+// have a FHIR mapping of their own; the other FHIR-inspired models are already shaped
+// as their resource and only gain its resourceType.
+func bundleResource(item interface{}) (string, interface{}, bool) {
+	var resourceType, id string
+	switch r := item.(type) {
+	case *models.Patient:
+		return "Patient/" + r.ID, fhir.FromPatient(r), true
+	case *models.Observation:
+		resourceType, id = "Observation", r.ID
+	case *models.Condition:
+		resourceType, id = "Condition", r.ID
+	case *models.AllergyIntolerance:
+		resourceType, id = "AllergyIntolerance", r.ID
+	case *models.MedicationRequest:
+		resourceType, id = "MedicationRequest", r.ID
+	case *models.MedicationAdministration:
+		resourceType, id = "MedicationAdministration", r.ID
+	case *models.Practitioner:
+		resourceType, id = "Practitioner", r.ID
+	case *models.Procedure:
+		resourceType, id = "Procedure", r.ID
+	case *models.CarePlan:
+		resourceType, id = "CarePlan", r.ID
+	case *models.Specimen:
+		resourceType, id = "Specimen", r.ID
+	case *models.Questionnaire:
+		resourceType, id = "Questionnaire", r.ID
+	case *models.QuestionnaireResponse:
+		resourceType, id = "QuestionnaireResponse", r.ID
+	case *models.Media:
+		resourceType, id = "Media", r.ID
+	case *models.DocumentReference:
+		resourceType, id = "DocumentReference", r.ID
+	case *models.Coverage:
+		resourceType, id = "Coverage", r.ID
+	case *models.RelatedPerson:
+		resourceType, id = "RelatedPerson", r.ID
+	case *models.Device:
+		resourceType, id = "Device", r.ID
+	default:
+		return "", nil, false
+	}
+
+	encoded, err := json.Marshal(item)
+	if err != nil {
+		return "", nil, false
+	}
+	var resource map[string]json.RawMessage
+	if err := json.Unmarshal(encoded, &resource); err != nil {
+		return "", nil, false
+	}
+	resource["resourceType"], _ = json.Marshal(resourceType)
+	return resourceType + "/" + id, resource, true
+}
@@ -0,0 +1,52 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+)
+
+// GetErrorCodes lists the documented error codes
+// @Summary Get error codes
+// @Description List every machine-readable code an ErrorResponse can carry, with what it means, what a client can do about it and whether the same request may succeed when sent again later. Each error also carries when it happened and the requestId echoed in the X-Request-ID header, to quote when reporting it.
+// @Tags errors
+// @Produce json
+// @Success 200 {array} apierror.Code
+// @Router /api/v1/errors [get]
+func GetErrorCodes(c *gin.Context) {
+	c.JSON(http.StatusOK, apierror.Codes())
+}
+
+// RedirectErrorCodes sends clients of the catalog's former path to /api/v1/errors
+// @Summary Get error codes (deprecated)
+// @Description Deprecated path of the error catalog, permanently redirected to /api/v1/errors
+// @Tags errors
+// @Success 308 "Permanent Redirect"
+// @Router /api/v1/error-codes [get]
+func RedirectErrorCodes(c *gin.Context) {
+	c.Redirect(http.StatusPermanentRedirect, "/api/v1/errors")
+}
+
+// GetErrorCode describes an error code
+// @Summary Get error code
+// @Description Get the description, remediation and retryability of an error code
+// @Tags errors
+// @Produce json
+// @Param code path string true "Error code, e.g. PATIENT_NOT_FOUND"
+// @Success 200 {object} apierror.Code
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/errors/{code} [get]
+func GetErrorCode(c *gin.Context) {
+	code, ok := apierror.Lookup(c.Param("code"))
+	if !ok {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error:   "Error code not found",
+			Message: c.Param("code") + " is not a documented error code",
+			Code:    "ERROR_CODE_NOT_FOUND",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, code)
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+)
+
+// DepartmentEscalations summarizes how a department's critical alerts were handled
+type DepartmentEscalations struct {
+	Department               string   `json:"department"`
+	CriticalAlerts           int64    `json:"criticalAlerts"`
+	Acknowledged             int64    `json:"acknowledged"`
+	Escalated                int64    `json:"escalated"` // Notified beyond the first on-call contact
+	Exhausted                int64    `json:"exhausted"` // Still unacknowledged after the whole chain
+	FailedNotifications      int64    `json:"failedNotifications"`
+	MeanMinutesToAcknowledge *float64 `json:"meanMinutesToAcknowledge,omitempty"`
+	MaxMinutesToAcknowledge  *float64 `json:"maxMinutesToAcknowledge,omitempty"`
+	acknowledgeMinutesTotal  float64
+}
+
+// EscalationReport represents the escalation of critical alerts per department
+type EscalationReport struct {
+	From        string                  `json:"from,omitempty"`
+	To          string                  `json:"to,omitempty"`
+	Departments []DepartmentEscalations `json:"departments"`
+	GeneratedAt time.Time               `json:"generatedAt"`
+}
+
+// GetEscalationReport reports how critical alerts were acknowledged and escalated
+// @Summary Critical alert escalation report
+// @Description Per department, the critical alerts raised in the period, how many were acknowledged, escalated beyond the first on-call contact or left unacknowledged after the whole chain, failed notifications and the time to acknowledge
+// @Tags analytics
+// @Produce json
+// @Param department query string false "Filter by department"
+// @Param from query string false "Alerts raised from (ISO 8601)"
+// @Param to query string false "Alerts raised until (ISO 8601)"
+// @Success 200 {object} EscalationReport
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/analytics/escalations [get]
+func (h *AnalyticsHandler) GetEscalationReport(c *gin.Context) {
+	report := EscalationReport{
+		From:        strings.TrimSpace(c.Query("from")),
+		To:          strings.TrimSpace(c.Query("to")),
+		Departments: []DepartmentEscalations{},
+		GeneratedAt: time.Now().UTC(),
+	}
+
+	base := func() *gorm.DB {
+		query := h.db.Model(&models.Alert{}).Where("severity = ? AND department <> ''", models.AlertSeverityCritical)
+		if department := strings.TrimSpace(c.Query("department")); department != "" {
+			query = query.Where("department = ?", department)
+		}
+		if report.From != "" {
+			query = query.Where("created_at >= ?", report.From)
+		}
+		if report.To != "" {
+			query = query.Where("created_at <= ?", report.To)
+		}
+		return query
+	}
+
+	var alerts []models.Alert
+	if err := base().Select("id", "department", "escalation_level", "created_at", "acknowledged_at").
+		Find(&alerts).Error; err != nil {
+		h.escalationError(c, err)
+		return
+	}
+
+	var outcomes []struct {
+		AlertID string
+		Status  string
+	}
+	if err := h.db.Model(&models.AlertEscalation{}).Select("alert_id", "status").
+		Where("status IN ? AND alert_id IN (?)", []string{models.EscalationExhausted, models.EscalationFailed},
+			base().Select("id")).
+		Scan(&outcomes).Error; err != nil {
+		h.escalationError(c, err)
+		return
+	}
+
+	stats := map[string]*DepartmentEscalations{}
+	byAlert := map[string]*DepartmentEscalations{}
+	for _, alert := range alerts {
+		s, ok := stats[alert.Department]
+		if !ok {
+			s = &DepartmentEscalations{Department: alert.Department}
+			stats[alert.Department] = s
+		}
+		byAlert[alert.ID] = s
+
+		s.CriticalAlerts++
+		if alert.EscalationLevel > 1 {
+			s.Escalated++
+		}
+		if alert.AcknowledgedAt != nil {
+			s.Acknowledged++
+			minutes := alert.AcknowledgedAt.Sub(alert.CreatedAt).Minutes()
+			s.acknowledgeMinutesTotal += minutes
+			if s.MaxMinutesToAcknowledge == nil || minutes > *s.MaxMinutesToAcknowledge {
+				s.MaxMinutesToAcknowledge = &minutes
+			}
+		}
+	}
+	for _, outcome := range outcomes {
+		s, ok := byAlert[outcome.AlertID]
+		if !ok {
+			continue
+		}
+		if outcome.Status == models.EscalationExhausted {
+			s.Exhausted++
+		} else {
+			s.FailedNotifications++
+		}
+	}
+
+	for _, s := range stats {
+		if s.Acknowledged > 0 {
+			mean := s.acknowledgeMinutesTotal / float64(s.Acknowledged)
+			s.MeanMinutesToAcknowledge = &mean
+		}
+		report.Departments = append(report.Departments, *s)
+	}
+	sort.Slice(report.Departments, func(i, j int) bool {
+		return report.Departments[i].Department < report.Departments[j].Department
+	})
+
+	c.JSON(http.StatusOK, report)
+}
+
+// escalationError writes a database error response for the escalation report
+func (h *AnalyticsHandler) escalationError(c *gin.Context, err error) {
+	respondError(c, http.StatusInternalServerError, ErrorResponse{
+		Error:   "Failed to aggregate alert escalations",
+		Message: err.Error(),
+		Code:    "DATABASE_ERROR",
+	})
+}
@@ -0,0 +1,357 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/events"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// EventReplayHandler replays outbox events to consumers that missed them
+type EventReplayHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	runner    *operation.Runner
+	replayer  *events.Replayer
+}
+
+// NewEventReplayHandler creates a new event replay handler
+func NewEventReplayHandler(db *gorm.DB, runner *operation.Runner, replayer *events.Replayer) *EventReplayHandler {
+	return &EventReplayHandler{
+		db:        db,
+		validator: validator.New(),
+		runner:    runner,
+		replayer:  replayer,
+	}
+}
+
+// EventConsumer is how far a consumer has read the outbox
+type EventConsumer struct {
+	Name      string     `json:"name"`
+	Position  int64      `json:"position"` // Sequence of the last event it handled
+	Lag       int64      `json:"lag"`      // Events recorded after its position
+	UpdatedAt *time.Time `json:"updatedAt,omitempty"`
+}
+
+// EventConsumersResponse lists the outbox consumers
+type EventConsumersResponse struct {
+	Data []EventConsumer `json:"data"`
+}
+
+// GetEventConsumers lists the consumers events can be replayed to
+// @Summary Get event consumers
+// @Description List the outbox consumers running on the server, which events can be replayed to, with the last event each handled and how many events it trails by (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} EventConsumersResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/event-consumers [get]
+func (h *EventReplayHandler) GetEventConsumers(c *gin.Context) {
+	db := readDB(c, h.db)
+
+	var latest int64
+	if err := db.Model(&models.OutboxEvent{}).Select("COALESCE(MAX(sequence), 0)").Scan(&latest).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read outbox",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	var checkpoints []models.EventCheckpoint
+	if err := db.Find(&checkpoints).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read consumer positions",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	positions := make(map[string]models.EventCheckpoint, len(checkpoints))
+	for _, checkpoint := range checkpoints {
+		positions[checkpoint.Consumer] = checkpoint
+	}
+
+	consumers := []EventConsumer{}
+	for _, name := range h.replayer.Consumers() {
+		consumer := EventConsumer{Name: name, Lag: latest}
+		if checkpoint, ok := positions[name]; ok {
+			updatedAt := checkpoint.UpdatedAt
+			consumer.Position = checkpoint.Position
+			consumer.Lag = latest - checkpoint.Position
+			consumer.UpdatedAt = &updatedAt
+		}
+		consumers = append(consumers, consumer)
+	}
+
+	c.JSON(http.StatusOK, EventConsumersResponse{Data: consumers})
+}
+
+// CreateEventReplay replays a range of events to a consumer
+// @Summary Replay events
+// @Description Send the outbox events of a period, optionally of one resource type, to a consumer again as the operation in Location, e.g. to rebuild what a search index or integration lost during an outage. Events are replayed in order up to when the replay was requested; consumers read the resource's current state, so replaying an event twice is harmless. The consumer's position is not moved (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body models.EventReplayRequest true "Consumer and events to replay"
+// @Success 202 {object} models.EventReplay
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/event-replays [post]
+func (h *EventReplayHandler) CreateEventReplay(c *gin.Context) {
+	var req models.EventReplayRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+	if !h.replayer.Has(req.Consumer) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid consumer",
+			Message: req.Consumer + " is not a consumer running on the server; see GET /api/v1/admin/event-consumers",
+			Code:    "INVALID_CONSUMER",
+		})
+		return
+	}
+	if req.From != nil && req.To != nil && !req.To.After(*req.From) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid date range",
+			Message: "to must be after from",
+			Code:    "INVALID_DATE_RANGE",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	replay := models.EventReplay{
+		Consumer:     req.Consumer,
+		ResourceType: req.ResourceType,
+		From:         req.From,
+		To:           req.To,
+		CreatedBy:    userID,
+		CreatedAt:    time.Now().UTC(),
+	}
+	var op *models.Operation
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&replay).Error; err != nil {
+			return err
+		}
+		var err error
+		if op, err = h.runner.Queue(tx, models.OperationKindReplay, "EventReplay/"+replay.ID, userID); err != nil {
+			return err
+		}
+		replay.OperationID = op.ID
+		return tx.Model(&replay).Update("operation_id", op.ID).Error
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to queue event replay",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("event_replay_requested", "EventReplay", userID, map[string]interface{}{
+		"replay_id":     replay.ID,
+		"operation_id":  op.ID,
+		"consumer":      replay.Consumer,
+		"resource_type": replay.ResourceType,
+		"from":          replay.From,
+		"to":            replay.To,
+	})
+
+	h.runner.Wake(models.OperationKindReplay)
+	acceptOperation(c, op, replay)
+}
+
+// GetEventReplays lists event replays, most recent first
+// @Summary Get event replays
+// @Description List event replays with how many events each has replayed; their operations report their status (admin only)
+// @Tags admin
+// @Produce json
+// @Param consumer query string false "Filter by consumer"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.EventReplay}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/event-replays [get]
+func (h *EventReplayHandler) GetEventReplays(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.EventReplay{})
+	if consumer := c.Query("consumer"); consumer != "" {
+		query = query.Where("consumer = ?", consumer)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count event replays",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	replays := []models.EventReplay{}
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&replays).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch event replays",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, replays, total, page, limit)
+}
+
+// GetEventReplay retrieves an event replay
+// @Summary Get event replay
+// @Description Get an event replay and how many events it has replayed (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Event replay ID"
+// @Success 200 {object} models.EventReplay
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/event-replays/{id} [get]
+func (h *EventReplayHandler) GetEventReplay(c *gin.Context) {
+	replay, ok := h.findReplay(c, readDB(c, h.db))
+	if !ok {
+		return
+	}
+	c.JSON(http.StatusOK, replay)
+}
+
+// ResumeEventReplay runs a failed or cancelled event replay again
+// @Summary Resume event replay
+// @Description Run a replay that failed, such as when its consumer's downstream system was still unavailable, or that was cancelled, again as the operation in Location. It continues after the last event it replayed (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Event replay ID"
+// @Success 202 {object} models.EventReplay
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/event-replays/{id}/resume [post]
+func (h *EventReplayHandler) ResumeEventReplay(c *gin.Context) {
+	replay, ok := h.findReplay(c, h.db)
+	if !ok {
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	var op *models.Operation
+	var conflict string
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		var previous models.Operation
+		if err := tx.Where("id = ?", replay.OperationID).First(&previous).Error; err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return err
+		}
+		switch previous.Status {
+		case models.OperationCompleted:
+			conflict = "the replay has completed"
+			return nil
+		case models.OperationQueued, models.OperationRunning:
+			conflict = "the replay is already running"
+			return nil
+		}
+		var err error
+		if op, err = h.runner.Queue(tx, models.OperationKindReplay, "EventReplay/"+replay.ID, userID); err != nil {
+			return err
+		}
+		replay.OperationID = op.ID
+		return tx.Model(replay).Update("operation_id", op.ID).Error
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to queue event replay",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if conflict != "" {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Event replay cannot be resumed",
+			Message: conflict,
+			Code:    "REPLAY_NOT_RESUMABLE",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("event_replay_resumed", "EventReplay", userID, map[string]interface{}{
+		"replay_id":    replay.ID,
+		"operation_id": op.ID,
+		"replayed":     replay.Replayed,
+	})
+
+	h.runner.Wake(models.OperationKindReplay)
+	acceptOperation(c, op, replay)
+}
+
+// findReplay loads the replay of the :id parameter, responding with 404 when there
+// is none
+func (h *EventReplayHandler) findReplay(c *gin.Context, db *gorm.DB) (*models.EventReplay, bool) {
+	var replay models.EventReplay
+	if err := db.Where("id = ?", c.Param("id")).First(&replay).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Event replay not found",
+				Code:  "REPLAY_NOT_FOUND",
+			})
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch event replay",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return nil, false
+	}
+	return &replay, true
+}
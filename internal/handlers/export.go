@@ -0,0 +1,332 @@
+package handlers
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/deid"
+	"github.com/hillmatthew2000/HealthHub/internal/export"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/encryption"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// exportBatchSize is how many rows are loaded at a time while streaming an export
+const exportBatchSize = 500
+
+// ExportHandler handles bulk data exports
+type ExportHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	deid      *deid.Deidentifier
+	storage   storage.Storage
+	runner    *operation.Runner
+}
+
+// NewExportHandler creates a new export handler
+func NewExportHandler(db *gorm.DB, deidentifier *deid.Deidentifier, store storage.Storage, runner *operation.Runner) *ExportHandler {
+	return &ExportHandler{
+		db:        db,
+		validator: validator.New(),
+		deid:      deidentifier,
+		storage:   store,
+		runner:    runner,
+	}
+}
+
+// ExportDeidentifiedPatients streams all patients as de-identified NDJSON
+// @Summary Export de-identified patients
+// @Description Stream patients as newline-delimited JSON with direct identifiers removed (HIPAA Safe Harbor)
+// @Tags exports
+// @Produce application/x-ndjson
+// @Param active query bool false "Only export active patients"
+// @Success 200 {array} deid.Patient
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/patients/deidentified [get]
+func (h *ExportHandler) ExportDeidentifiedPatients(c *gin.Context) {
+	query := h.db.Model(&models.Patient{})
+	if c.Query("active") == "true" {
+		query = query.Where("active = ?", true)
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("export", "Patient", userID, map[string]interface{}{
+		"format":       "ndjson",
+		"deidentified": true,
+	})
+
+	c.Header("Content-Type", "application/x-ndjson")
+	c.Header("Content-Disposition", "attachment; filename=patients-deidentified.ndjson")
+	c.Status(http.StatusOK)
+
+	now := time.Now()
+	encoder := json.NewEncoder(c.Writer)
+	exported := 0
+
+	var patients []models.Patient
+	err := query.Order("id ASC").FindInBatches(&patients, exportBatchSize, func(tx *gorm.DB, batch int) error {
+		for i := range patients {
+			if err := encoder.Encode(h.deid.Patient(&patients[i], now)); err != nil {
+				return err
+			}
+		}
+		exported += len(patients)
+		c.Writer.Flush()
+		return nil
+	}).Error
+
+	// Headers are already sent, so a failure can only be logged and the stream truncated
+	if err != nil {
+		logger.Error("De-identified patient export failed",
+			zap.Int("exported", exported),
+			zap.Error(err),
+		)
+	}
+}
+
+// CreateExportJob starts a background export
+// @Summary Create export job
+// @Description Export patients or observations to NDJSON files with SHA-256 checksums and a manifest. Files can be encrypted to an age or OpenPGP public key supplied with the request. The export runs as the operation in Location, which can be polled and cancelled (admin only).
+// @Tags exports
+// @Accept json
+// @Produce json
+// @Param request body models.ExportJobRequest true "Export options"
+// @Success 202 {object} models.ExportJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/jobs [post]
+func (h *ExportHandler) CreateExportJob(c *gin.Context) {
+	var req models.ExportJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	if req.ResourceType == "Observation" && req.Deidentified {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "De-identified exports are only available for patients",
+			Code:  "DEIDENTIFICATION_UNSUPPORTED",
+		})
+		return
+	}
+	if req.ResourceType == "Patient" && req.Filter != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Filters are only available for observation exports",
+			Code:  "UNEXPECTED_FILTER",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	job := models.ExportJob{
+		ResourceType: req.ResourceType,
+		Deidentified: req.Deidentified,
+		ActiveOnly:   req.ActiveOnly,
+		Filter:       req.Filter,
+		Status:       models.ExportJobQueued,
+		CreatedBy:    userID,
+	}
+
+	if req.Encryption != nil {
+		recipient, err := encryption.ParseRecipient(req.Encryption.Format, req.Encryption.PublicKey)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid encryption key",
+				Message: err.Error(),
+				Code:    "INVALID_PUBLIC_KEY",
+			})
+			return
+		}
+		job.EncryptionFormat = req.Encryption.Format
+		job.RecipientKey = req.Encryption.PublicKey
+		job.RecipientFingerprint = recipient.Fingerprint()
+	}
+
+	var op *models.Operation
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&job).Error; err != nil {
+			return err
+		}
+		var err error
+		if op, err = h.runner.Queue(tx, models.OperationKindExport, "ExportJob/"+job.ID, userID); err != nil {
+			return err
+		}
+		job.OperationID = op.ID
+		return tx.Model(&job).Update("operation_id", op.ID).Error
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create export job",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("export", job.ResourceType, userID, map[string]interface{}{
+		"job_id":       job.ID,
+		"format":       "ndjson",
+		"deidentified": job.Deidentified,
+		"encryption":   job.EncryptionFormat,
+	})
+
+	h.runner.Wake(models.OperationKindExport)
+	acceptOperation(c, op, job)
+}
+
+// GetExportJob retrieves an export job and its files
+// @Summary Get export job
+// @Description Get the status of an export job and, once completed, its files with checksums (admin only)
+// @Tags exports
+// @Produce json
+// @Param id path string true "Export job ID"
+// @Success 200 {object} models.ExportJob
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/jobs/{id} [get]
+func (h *ExportHandler) GetExportJob(c *gin.Context) {
+	var job models.ExportJob
+	if !h.findJob(c, &job) {
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// DownloadExportFile streams a file of a completed export job
+// @Summary Download export file
+// @Description Download an export file or the manifest of a completed export job (admin only)
+// @Tags exports
+// @Produce application/octet-stream
+// @Param id path string true "Export job ID"
+// @Param name path string true "File name"
+// @Success 200 {file} binary
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/jobs/{id}/files/{name} [get]
+func (h *ExportHandler) DownloadExportFile(c *gin.Context) {
+	var job models.ExportJob
+	if !h.findJob(c, &job) {
+		return
+	}
+
+	if job.Status != models.ExportJobCompleted {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Export job has not completed",
+			Code:  "EXPORT_NOT_READY",
+		})
+		return
+	}
+
+	// Only names recorded on the job are served, so the path cannot reach other objects
+	name := c.Param("name")
+	contentType := "application/json"
+	if name != export.ManifestName {
+		contentType = ""
+		for _, file := range job.Files {
+			if file.Name == name {
+				contentType = "application/x-ndjson"
+				if job.EncryptionFormat != "" {
+					contentType = "application/octet-stream"
+				}
+				if sum, err := hex.DecodeString(file.SHA256); err == nil {
+					c.Header("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum))
+				}
+				break
+			}
+		}
+	}
+	if contentType == "" {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Export file not found",
+			Code:  "EXPORT_FILE_NOT_FOUND",
+		})
+		return
+	}
+
+	reader, err := h.storage.Get(models.ExportStorageKey(job.ID, name))
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Export file not found",
+				Code:  "EXPORT_FILE_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read export file",
+			Message: err.Error(),
+			Code:    "STORAGE_ERROR",
+		})
+		return
+	}
+	defer reader.Close()
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("export_download", job.ResourceType, userID, map[string]interface{}{
+		"job_id": job.ID,
+		"file":   name,
+	})
+
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": name}))
+	c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
+
+// findJob loads the export job named by the id path parameter and writes the error
+// response when it cannot be found
+func (h *ExportHandler) findJob(c *gin.Context, job *models.ExportJob) bool {
+	if err := h.db.Where("id = ?", c.Param("id")).First(job).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Export job not found",
+				Code:  "EXPORT_JOB_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch export job",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
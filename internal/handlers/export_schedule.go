@@ -0,0 +1,491 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/export"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/encryption"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// scheduleNamePattern restricts schedule names to characters that are safe in the
+// remote directory each run is written to
+var scheduleNamePattern = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9._-]{0,99}$`)
+
+// ExportScheduleHandler handles admin management of recurring exports
+type ExportScheduleHandler struct {
+	db          *gorm.DB
+	validator   *validator.Validate
+	credentials *encryption.Encryptor
+	scheduler   *export.Scheduler
+}
+
+// NewExportScheduleHandler creates a new export schedule handler
+func NewExportScheduleHandler(db *gorm.DB, credentials *encryption.Encryptor, scheduler *export.Scheduler) *ExportScheduleHandler {
+	return &ExportScheduleHandler{
+		db:          db,
+		validator:   validator.New(),
+		credentials: credentials,
+		scheduler:   scheduler,
+	}
+}
+
+// CreateExportSchedule creates a recurring export
+// @Summary Create export schedule
+// @Description Schedule a recurring NDJSON or CSV export pushed to an SFTP server or S3 bucket (admin only)
+// @Tags exports
+// @Accept json
+// @Produce json
+// @Param schedule body models.ExportScheduleRequest true "Schedule"
+// @Success 201 {object} models.ExportSchedule
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/schedules [post]
+func (h *ExportScheduleHandler) CreateExportSchedule(c *gin.Context) {
+	var req models.ExportScheduleRequest
+	if !h.bindRequest(c, &req) {
+		return
+	}
+
+	if req.Credential == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "A destination credential is required",
+			Code:  "CREDENTIAL_REQUIRED",
+		})
+		return
+	}
+
+	schedule := models.ExportSchedule{Active: true}
+	if userID, exists := auth.GetUserID(c); exists {
+		schedule.CreatedBy = userID
+	}
+	if !h.applyRequest(c, &req, &schedule) {
+		return
+	}
+
+	if err := h.db.Create(&schedule).Error; err != nil {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Failed to create export schedule",
+			Message: err.Error(),
+			Code:    "SCHEDULE_EXISTS",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "ExportSchedule", schedule.CreatedBy, map[string]interface{}{
+		"schedule_id": schedule.ID,
+		"destination": schedule.Destination.Type,
+	})
+
+	h.scheduler.Wake()
+	c.JSON(http.StatusCreated, schedule)
+}
+
+// GetExportSchedules lists recurring exports
+// @Summary Get export schedules
+// @Description List recurring exports with their last run status (admin only)
+// @Tags exports
+// @Produce json
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.ExportSchedule}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/schedules [get]
+func (h *ExportScheduleHandler) GetExportSchedules(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var total int64
+	if err := h.db.Model(&models.ExportSchedule{}).Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count export schedules",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var schedules []models.ExportSchedule
+	if err := h.db.Order("name ASC").Offset((page - 1) * limit).Limit(limit).Find(&schedules).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch export schedules",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, schedules, total, page, limit)
+}
+
+// GetExportSchedule retrieves a recurring export
+// @Summary Get export schedule
+// @Description Get a recurring export by ID (admin only)
+// @Tags exports
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 200 {object} models.ExportSchedule
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/schedules/{id} [get]
+func (h *ExportScheduleHandler) GetExportSchedule(c *gin.Context) {
+	var schedule models.ExportSchedule
+	if !h.findSchedule(c, &schedule) {
+		return
+	}
+
+	c.JSON(http.StatusOK, schedule)
+}
+
+// UpdateExportSchedule replaces the settings of a recurring export
+// @Summary Update export schedule
+// @Description Replace a recurring export's settings. The stored credential is kept when none is supplied and the destination type is unchanged (admin only)
+// @Tags exports
+// @Accept json
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Param schedule body models.ExportScheduleRequest true "Schedule"
+// @Success 200 {object} models.ExportSchedule
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/schedules/{id} [put]
+func (h *ExportScheduleHandler) UpdateExportSchedule(c *gin.Context) {
+	var schedule models.ExportSchedule
+	if !h.findSchedule(c, &schedule) {
+		return
+	}
+
+	var req models.ExportScheduleRequest
+	if !h.bindRequest(c, &req) {
+		return
+	}
+
+	if req.Credential == "" && req.Destination.Type != schedule.Destination.Type {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "A credential is required when changing the destination type",
+			Code:  "CREDENTIAL_REQUIRED",
+		})
+		return
+	}
+
+	// The update window of an incremental export only carries over for the same records
+	if req.ResourceType != schedule.ResourceType {
+		schedule.Watermark = nil
+	}
+	if !h.applyRequest(c, &req, &schedule) {
+		return
+	}
+
+	if err := h.db.Select("*").Omit("created_at", "created_by").Save(&schedule).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update export schedule",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "ExportSchedule", userID, map[string]interface{}{
+		"schedule_id": schedule.ID,
+		"destination": schedule.Destination.Type,
+	})
+
+	h.scheduler.Wake()
+	c.JSON(http.StatusOK, schedule)
+}
+
+// DeleteExportSchedule deletes a recurring export and its run history
+// @Summary Delete export schedule
+// @Description Delete a recurring export and its run history (admin only)
+// @Tags exports
+// @Param id path string true "Schedule ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/schedules/{id} [delete]
+func (h *ExportScheduleHandler) DeleteExportSchedule(c *gin.Context) {
+	var schedule models.ExportSchedule
+	if !h.findSchedule(c, &schedule) {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("schedule_id = ?", schedule.ID).Delete(&models.ExportScheduleRun{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&schedule).Error
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete export schedule",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "ExportSchedule", userID, map[string]interface{}{
+		"schedule_id": schedule.ID,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// RunExportSchedule triggers a run of a recurring export now
+// @Summary Run export schedule now
+// @Description Make an active recurring export due immediately; the next regular run time is recomputed afterwards (admin only)
+// @Tags exports
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Success 202 {object} models.ExportSchedule
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/schedules/{id}/run [post]
+func (h *ExportScheduleHandler) RunExportSchedule(c *gin.Context) {
+	var schedule models.ExportSchedule
+	if !h.findSchedule(c, &schedule) {
+		return
+	}
+
+	if !schedule.Active {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Export schedule is not active",
+			Code:  "SCHEDULE_INACTIVE",
+		})
+		return
+	}
+
+	now := time.Now()
+	if err := h.db.Model(&schedule).UpdateColumn("next_run_at", now).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to trigger export schedule",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	schedule.NextRunAt = &now
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("export_trigger", "ExportSchedule", userID, map[string]interface{}{
+		"schedule_id": schedule.ID,
+	})
+
+	h.scheduler.Wake()
+	c.JSON(http.StatusAccepted, schedule)
+}
+
+// GetExportScheduleRuns lists the run history of a recurring export
+// @Summary Get export schedule runs
+// @Description List the runs of a recurring export, newest first, with their files and errors (admin only)
+// @Tags exports
+// @Produce json
+// @Param id path string true "Schedule ID"
+// @Param status query string false "Filter by run status (running, completed, failed)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.ExportScheduleRun}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/exports/schedules/{id}/runs [get]
+func (h *ExportScheduleHandler) GetExportScheduleRuns(c *gin.Context) {
+	var schedule models.ExportSchedule
+	if !h.findSchedule(c, &schedule) {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	query := h.db.Model(&models.ExportScheduleRun{}).Where("schedule_id = ?", schedule.ID)
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count export runs",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var runs []models.ExportScheduleRun
+	if err := query.Order("started_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&runs).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch export runs",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, runs, total, page, limit)
+}
+
+// bindRequest binds and validates a schedule request and writes the error response on failure
+func (h *ExportScheduleHandler) bindRequest(c *gin.Context, req *models.ExportScheduleRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+
+	if !scheduleNamePattern.MatchString(req.Name) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Schedule names may only contain letters, digits, '.', '_' and '-'",
+			Code:  "INVALID_SCHEDULE_NAME",
+		})
+		return false
+	}
+	if req.ResourceType == "Observation" && req.Deidentified {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "De-identified exports are only available for patients",
+			Code:  "DEIDENTIFICATION_UNSUPPORTED",
+		})
+		return false
+	}
+	if req.ResourceType == "Patient" && req.Filter != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Filters are only available for observation exports",
+			Code:  "UNEXPECTED_FILTER",
+		})
+		return false
+	}
+
+	return true
+}
+
+// applyRequest copies a validated request onto a schedule, encrypting the credential
+// and computing the next run time
+func (h *ExportScheduleHandler) applyRequest(c *gin.Context, req *models.ExportScheduleRequest, schedule *models.ExportSchedule) bool {
+	schedule.Name = req.Name
+	schedule.ResourceType = req.ResourceType
+	schedule.Format = req.Format
+	schedule.Deidentified = req.Deidentified
+	schedule.ActiveOnly = req.ActiveOnly
+	schedule.Filter = req.Filter
+	schedule.Incremental = req.Incremental
+	schedule.Frequency = req.Frequency
+	schedule.RunAt = req.RunAt
+	schedule.Weekday = req.Weekday
+	schedule.Destination = req.Destination
+	schedule.AlertEmails = req.AlertEmails
+	if req.Active != nil {
+		schedule.Active = *req.Active
+	}
+
+	if req.Credential != "" {
+		credential, err := h.credentials.Encrypt(req.Credential)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to encrypt destination credential",
+				Message: err.Error(),
+				Code:    "ENCRYPTION_ERROR",
+			})
+			return false
+		}
+		schedule.Credential = credential
+	}
+
+	schedule.EncryptionFormat = ""
+	schedule.RecipientKey = ""
+	schedule.RecipientFingerprint = ""
+	if req.Encryption != nil {
+		recipient, err := encryption.ParseRecipient(req.Encryption.Format, req.Encryption.PublicKey)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid encryption key",
+				Message: err.Error(),
+				Code:    "INVALID_PUBLIC_KEY",
+			})
+			return false
+		}
+		schedule.EncryptionFormat = req.Encryption.Format
+		schedule.RecipientKey = req.Encryption.PublicKey
+		schedule.RecipientFingerprint = recipient.Fingerprint()
+	}
+
+	next := schedule.NextRun(time.Now())
+	schedule.NextRunAt = &next
+	return true
+}
+
+// findSchedule loads the schedule named by the id path parameter and writes the
+// error response when it cannot be found
+func (h *ExportScheduleHandler) findSchedule(c *gin.Context, schedule *models.ExportSchedule) bool {
+	if err := h.db.Where("id = ?", c.Param("id")).First(schedule).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Export schedule not found",
+				Code:  "SCHEDULE_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch export schedule",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/gin-gonic/gin/render"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
+)
+
+// wantsFHIR reports whether the client asked for FHIR JSON, either with the _format
+// query parameter or an Accept header of application/fhir+json
+func wantsFHIR(c *gin.Context) bool {
+	switch strings.ToLower(c.Query("_format")) {
+	case "fhir", "json+fhir", fhir.MediaType:
+		return true
+	}
+	return strings.Contains(c.GetHeader("Accept"), fhir.MediaType)
+}
+
+// renderFHIR writes a FHIR resource with the FHIR media type
+func renderFHIR(c *gin.Context, status int, resource interface{}) {
+	c.Header("Content-Type", fhir.MediaType+"; charset=utf-8")
+	c.Render(status, render.JSON{Data: resource})
+}
+
+// sentFHIR reports whether the request body is FHIR JSON
+func sentFHIR(c *gin.Context) bool {
+	return c.ContentType() == fhir.MediaType
+}
+
+// respondConformance validates a resource sent as FHIR JSON against the bundled
+// profile of its type and writes an OperationOutcome of the issues when it does not
+// conform. Nothing is checked when profile validation is disabled.
+func respondConformance(c *gin.Context, conformance *fhir.Validator, resourceType string, resource interface{}) bool {
+	if conformance == nil || !sentFHIR(c) {
+		return false
+	}
+
+	issues, err := conformance.Validate(resourceType, resource)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to apply FHIR profile",
+			Message: err.Error(),
+			Code:    "PROFILE_VALIDATION_ERROR",
+		})
+		return true
+	}
+	if len(issues) == 0 {
+		return false
+	}
+	renderFHIR(c, http.StatusUnprocessableEntity, fhir.NewOperationOutcome(issues...))
+	return true
+}
+
+// zeroJSON are the encodings of zero values, which partial updates do not save
+var zeroJSON = map[string]bool{
+	"null": true, `""`: true, "[]": true, "{}": true, "false": true, "0": true, `"0001-01-01T00:00:00Z"`: true,
+}
+
+// mergeUpdate stores in merged the result of applying a partial update to an
+// existing resource. Top-level fields omitted from the update keep their existing
+// values, as they do when the update is saved.
+func mergeUpdate(existing, update, merged interface{}) error {
+	doc, err := jsonFields(existing)
+	if err != nil {
+		return err
+	}
+	changes, err := jsonFields(update)
+	if err != nil {
+		return err
+	}
+	for key, value := range changes {
+		if !zeroJSON[string(value)] {
+			doc[key] = value
+		}
+	}
+
+	data, err := json.Marshal(doc)
+	if err != nil {
+		return fmt.Errorf("failed to encode resource: %w", err)
+	}
+	if err := json.Unmarshal(data, merged); err != nil {
+		return fmt.Errorf("failed to decode resource: %w", err)
+	}
+	return nil
+}
+
+// jsonFields encodes a resource as its top-level JSON fields
+func jsonFields(resource interface{}) (map[string]json.RawMessage, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resource: %w", err)
+	}
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(data, &fields); err != nil {
+		return nil, fmt.Errorf("failed to decode resource: %w", err)
+	}
+	return fields, nil
+}
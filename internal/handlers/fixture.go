@@ -0,0 +1,193 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/fixtures"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/validation"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+)
+
+// maxFixtureTenantLength bounds the tenant names fixtures accept
+const maxFixtureTenantLength = 64
+
+// FixtureHandler handles contract-test fixtures. Its routes are only registered
+// outside production.
+type FixtureHandler struct {
+	loader    *fixtures.Loader
+	validator *validator.Validate
+}
+
+// NewFixtureHandler creates a new fixture handler
+func NewFixtureHandler(loader *fixtures.Loader) *FixtureHandler {
+	return &FixtureHandler{
+		loader:    loader,
+		validator: validator.New(),
+	}
+}
+
+// FixtureEntitiesResponse lists a tenant's fixture entities
+type FixtureEntitiesResponse struct {
+	Tenant string                 `json:"tenant"`
+	Data   []models.FixtureEntity `json:"data"`
+}
+
+// FixtureEntityResponse is a created fixture entity with its resource
+type FixtureEntityResponse struct {
+	models.FixtureEntity
+	Resource interface{} `json:"resource"`
+}
+
+// GetFixtures lists the tenant's fixture entities
+// @Summary Get fixtures
+// @Description List the fixture entities of the tenant named by X-Tenant-ID with their IDs. Only available outside production
+// @Tags fixtures
+// @Produce json
+// @Param X-Tenant-ID header string true "Tenant"
+// @Success 200 {object} FixtureEntitiesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/_fixtures [get]
+func (h *FixtureHandler) GetFixtures(c *gin.Context) {
+	tenant, ok := fixtureTenant(c)
+	if !ok {
+		return
+	}
+
+	entities, err := h.loader.Entities(c.Request.Context(), tenant)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to retrieve fixtures",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, FixtureEntitiesResponse{Tenant: tenant, Data: entities})
+}
+
+// ResetFixtures resets the tenant to the fixture dataset
+// @Summary Reset fixtures
+// @Description Remove the fixture entities of the tenant named by X-Tenant-ID, with everything recorded against its fixture patients, and load the fixture dataset. Entity IDs are derived from the tenant and key, so they are the same after every reset. Only available outside production
+// @Tags fixtures
+// @Produce json
+// @Param X-Tenant-ID header string true "Tenant"
+// @Success 200 {object} fixtures.ResetResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/_fixtures/reset [post]
+func (h *FixtureHandler) ResetFixtures(c *gin.Context) {
+	tenant, ok := fixtureTenant(c)
+	if !ok {
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	result, err := h.loader.Reset(c.Request.Context(), tenant, userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to reset fixtures",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("fixtures_reset", "Fixture", userID, map[string]interface{}{
+		"tenant":   tenant,
+		"removed":  result.Removed,
+		"entities": len(result.Entities),
+	})
+
+	c.JSON(http.StatusOK, result)
+}
+
+// CreateFixtureEntity creates a deterministic test entity
+// @Summary Create fixture entity
+// @Description Create a patient, observation, condition, allergy or specimen for the tenant named by X-Tenant-ID. Its ID is derived from the tenant, resource type and key; creating the same key again replaces it. Resources other than patients must reference a fixture patient of the tenant. Only available outside production
+// @Tags fixtures
+// @Accept json
+// @Produce json
+// @Param X-Tenant-ID header string true "Tenant"
+// @Param request body models.FixtureEntityRequest true "Entity"
+// @Success 201 {object} FixtureEntityResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/_fixtures/entities [post]
+func (h *FixtureHandler) CreateFixtureEntity(c *gin.Context) {
+	tenant, ok := fixtureTenant(c)
+	if !ok {
+		return
+	}
+
+	var req models.FixtureEntityRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	entity, resource, err := h.loader.Create(c.Request.Context(), tenant, req, userID)
+	var entityErr *fixtures.EntityError
+	if errors.As(err, &entityErr) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid fixture entity",
+			Message: entityErr.Reason,
+			Code:    "INVALID_FIXTURE",
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create fixture entity",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, FixtureEntityResponse{FixtureEntity: *entity, Resource: resource})
+}
+
+// fixtureTenant returns the tenant named by the request, writing the error
+// response when there is none
+func fixtureTenant(c *gin.Context) (string, bool) {
+	tenant := tenantID(c)
+	if tenant == "" || len(tenant) > maxFixtureTenantLength {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid tenant",
+			Message: validation.TenantHeader + " must name a tenant of at most 64 characters",
+			Code:    "INVALID_TENANT",
+		})
+		return "", false
+	}
+	return tenant, true
+}
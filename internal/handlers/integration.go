@@ -0,0 +1,213 @@
+package handlers
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/inbound"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// IntegrationCredentialHandler handles the credentials inbound integrations sign
+// their requests with
+type IntegrationCredentialHandler struct {
+	db        *gorm.DB
+	verifier  *inbound.Verifier
+	validator *validator.Validate
+}
+
+// NewIntegrationCredentialHandler creates a new integration credential handler
+func NewIntegrationCredentialHandler(db *gorm.DB, verifier *inbound.Verifier) *IntegrationCredentialHandler {
+	return &IntegrationCredentialHandler{
+		db:        db,
+		verifier:  verifier,
+		validator: validator.New(),
+	}
+}
+
+// IntegrationCredentialResponse is a created credential with its secret, which is
+// only returned once
+type IntegrationCredentialResponse struct {
+	models.IntegrationCredential
+	Secret string `json:"secret"`
+}
+
+// CreateIntegrationCredential creates a credential for an inbound integration
+// @Summary Create integration credential
+// @Description Create a signing credential for an inbound integration acting as the given service account. Requests to /api/v1/inbound are signed with the returned secret, which is not shown again (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body models.IntegrationCredentialRequest true "Credential"
+// @Success 201 {object} IntegrationCredentialResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integration-credentials [post]
+func (h *IntegrationCredentialHandler) CreateIntegrationCredential(c *gin.Context) {
+	var req models.IntegrationCredentialRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var users int64
+	if err := h.db.Model(&models.User{}).Where("id = ?", req.UserID).Count(&users).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch user",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if users == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "User not found",
+			Code:  "USER_NOT_FOUND",
+		})
+		return
+	}
+
+	var existing int64
+	if err := h.db.Model(&models.IntegrationCredential{}).Where("name = ?", req.Name).Count(&existing).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check credential name",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if existing > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "An integration credential with this name already exists",
+			Code:  "CREDENTIAL_EXISTS",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	credential, secret, err := h.verifier.Issue(c.Request.Context(), req, userID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create integration credential",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "IntegrationCredential", userID, map[string]interface{}{
+		"credential_id": credential.ID,
+		"name":          credential.Name,
+		"user_id":       credential.UserID,
+	})
+
+	c.JSON(http.StatusCreated, IntegrationCredentialResponse{IntegrationCredential: *credential, Secret: secret})
+}
+
+// GetIntegrationCredentials lists the integration credentials
+// @Summary Get integration credentials
+// @Description List inbound integration credentials, newest first. Secrets are never returned (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {array} models.IntegrationCredential
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integration-credentials [get]
+func (h *IntegrationCredentialHandler) GetIntegrationCredentials(c *gin.Context) {
+	var credentials []models.IntegrationCredential
+	if err := h.db.Order("created_at DESC").Find(&credentials).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to retrieve integration credentials",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, credentials)
+}
+
+// RevokeIntegrationCredential revokes an integration credential
+// @Summary Revoke integration credential
+// @Description Stop accepting requests signed with the credential (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Success 200 {object} models.IntegrationCredential
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integration-credentials/{id} [delete]
+func (h *IntegrationCredentialHandler) RevokeIntegrationCredential(c *gin.Context) {
+	var credential models.IntegrationCredential
+	if err := h.db.Where("id = ?", c.Param("id")).First(&credential).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Integration credential not found",
+				Code:  "CREDENTIAL_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch integration credential",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if credential.Revoked() {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Integration credential already revoked",
+			Code:  "CREDENTIAL_REVOKED",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	now := time.Now()
+	if err := h.db.Model(&credential).Updates(map[string]interface{}{"revoked_at": now, "revoked_by": userID}).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to revoke integration credential",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	credential.RevokedAt = &now
+	credential.RevokedBy = userID
+
+	logger.LogAuditEvent("revoke", "IntegrationCredential", userID, map[string]interface{}{
+		"credential_id": credential.ID,
+		"name":          credential.Name,
+	})
+
+	c.JSON(http.StatusOK, credential)
+}
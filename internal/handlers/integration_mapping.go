@@ -0,0 +1,330 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/inbound"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// IntegrationMappingHandler handles the field mappings applied to the messages of
+// inbound integrations
+type IntegrationMappingHandler struct {
+	db        *gorm.DB
+	mapper    *inbound.Mapper
+	validator *validator.Validate
+}
+
+// NewIntegrationMappingHandler creates a new integration mapping handler
+func NewIntegrationMappingHandler(db *gorm.DB, mapper *inbound.Mapper) *IntegrationMappingHandler {
+	return &IntegrationMappingHandler{
+		db:        db,
+		mapper:    mapper,
+		validator: validator.New(),
+	}
+}
+
+// GetIntegrationMappings lists the mappings of an integration
+// @Summary Get integration mappings
+// @Description List the code system and sending facility mappings of an integration credential (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Param kind query string false "Filter by kind (code_system, facility)"
+// @Success 200 {array} models.IntegrationMapping
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integration-credentials/{id}/mappings [get]
+func (h *IntegrationMappingHandler) GetIntegrationMappings(c *gin.Context) {
+	if !h.findCredential(c) {
+		return
+	}
+
+	query := h.db.Where("credential_id = ?", c.Param("id"))
+	if kind := c.Query("kind"); kind != "" {
+		query = query.Where("kind = ?", kind)
+	}
+
+	var mappings []models.IntegrationMapping
+	if err := query.Order("kind ASC, source ASC").Find(&mappings).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to retrieve integration mappings",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, mappings)
+}
+
+// CreateIntegrationMapping creates a mapping for an integration
+// @Summary Create integration mapping
+// @Description Map a coding system the integration sends (e.g. an OBX-3 system such as "LN" or a local one) to an internal code system, or a sending facility (X-Sending-Facility) to a tenant. Inbound messages pick it up within 15 seconds (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Param request body models.IntegrationMappingRequest true "Mapping"
+// @Success 201 {object} models.IntegrationMapping
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integration-credentials/{id}/mappings [post]
+func (h *IntegrationMappingHandler) CreateIntegrationMapping(c *gin.Context) {
+	req, ok := h.bindMapping(c)
+	if !ok || !h.findCredential(c) {
+		return
+	}
+
+	var existing int64
+	if err := h.db.Model(&models.IntegrationMapping{}).
+		Where("credential_id = ? AND kind = ? AND source = ?", c.Param("id"), req.Kind, req.Source).
+		Count(&existing).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check integration mapping",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if existing > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "The integration already maps this source",
+			Code:  "MAPPING_EXISTS",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	mapping := models.IntegrationMapping{
+		CredentialID: c.Param("id"),
+		Kind:         req.Kind,
+		Source:       req.Source,
+		Target:       req.Target,
+		Description:  req.Description,
+		CreatedBy:    userID,
+	}
+	if err := h.db.Create(&mapping).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create integration mapping",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "IntegrationMapping", userID, map[string]interface{}{
+		"mapping_id":    mapping.ID,
+		"credential_id": mapping.CredentialID,
+		"kind":          mapping.Kind,
+		"source":        mapping.Source,
+		"target":        mapping.Target,
+	})
+
+	h.reload(c)
+	c.JSON(http.StatusCreated, mapping)
+}
+
+// UpdateIntegrationMapping replaces a mapping of an integration
+// @Summary Update integration mapping
+// @Description Replace the kind, source, target and description of a mapping (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Credential ID"
+// @Param mappingId path string true "Mapping ID"
+// @Param request body models.IntegrationMappingRequest true "Mapping"
+// @Success 200 {object} models.IntegrationMapping
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integration-credentials/{id}/mappings/{mappingId} [put]
+func (h *IntegrationMappingHandler) UpdateIntegrationMapping(c *gin.Context) {
+	req, ok := h.bindMapping(c)
+	if !ok {
+		return
+	}
+	var mapping models.IntegrationMapping
+	if !h.findMapping(c, &mapping) {
+		return
+	}
+
+	var existing int64
+	if err := h.db.Model(&models.IntegrationMapping{}).
+		Where("credential_id = ? AND kind = ? AND source = ? AND id <> ?", mapping.CredentialID, req.Kind, req.Source, mapping.ID).
+		Count(&existing).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check integration mapping",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if existing > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "The integration already maps this source",
+			Code:  "MAPPING_EXISTS",
+		})
+		return
+	}
+
+	if err := h.db.Model(&mapping).Updates(map[string]interface{}{
+		"kind":        req.Kind,
+		"source":      req.Source,
+		"target":      req.Target,
+		"description": req.Description,
+	}).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update integration mapping",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	mapping.Kind, mapping.Source, mapping.Target, mapping.Description = req.Kind, req.Source, req.Target, req.Description
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "IntegrationMapping", userID, map[string]interface{}{
+		"mapping_id":    mapping.ID,
+		"credential_id": mapping.CredentialID,
+		"kind":          mapping.Kind,
+		"source":        mapping.Source,
+		"target":        mapping.Target,
+	})
+
+	h.reload(c)
+	c.JSON(http.StatusOK, mapping)
+}
+
+// DeleteIntegrationMapping deletes a mapping of an integration
+// @Summary Delete integration mapping
+// @Description Stop applying a mapping to the integration's messages (admin only)
+// @Tags admin
+// @Param id path string true "Credential ID"
+// @Param mappingId path string true "Mapping ID"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integration-credentials/{id}/mappings/{mappingId} [delete]
+func (h *IntegrationMappingHandler) DeleteIntegrationMapping(c *gin.Context) {
+	var mapping models.IntegrationMapping
+	if !h.findMapping(c, &mapping) {
+		return
+	}
+
+	if err := h.db.Delete(&mapping).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete integration mapping",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "IntegrationMapping", userID, map[string]interface{}{
+		"mapping_id":    mapping.ID,
+		"credential_id": mapping.CredentialID,
+		"kind":          mapping.Kind,
+		"source":        mapping.Source,
+	})
+
+	h.reload(c)
+	c.Status(http.StatusNoContent)
+}
+
+// bindMapping binds and validates a mapping request, writing the error response
+// when it is invalid
+func (h *IntegrationMappingHandler) bindMapping(c *gin.Context) (models.IntegrationMappingRequest, bool) {
+	var req models.IntegrationMappingRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return req, false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return req, false
+	}
+	return req, true
+}
+
+// findCredential checks that the credential in the path exists, writing the error
+// response when it does not
+func (h *IntegrationMappingHandler) findCredential(c *gin.Context) bool {
+	var credentials int64
+	if err := h.db.Model(&models.IntegrationCredential{}).Where("id = ?", c.Param("id")).Count(&credentials).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch integration credential",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	if credentials == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Integration credential not found",
+			Code:  "CREDENTIAL_NOT_FOUND",
+		})
+		return false
+	}
+	return true
+}
+
+// findMapping loads the mapping in the path, writing the error response when it
+// is not found
+func (h *IntegrationMappingHandler) findMapping(c *gin.Context, mapping *models.IntegrationMapping) bool {
+	if err := h.db.Where("id = ? AND credential_id = ?", c.Param("mappingId"), c.Param("id")).First(mapping).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Integration mapping not found",
+				Code:  "MAPPING_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch integration mapping",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
+
+// reload makes this instance apply a mapping change at once; the others do on
+// their next reload
+func (h *IntegrationMappingHandler) reload(c *gin.Context) {
+	if err := h.mapper.Reload(c.Request.Context()); err != nil {
+		logger.Warn("Failed to reload integration mappings", zap.Error(err))
+	}
+}
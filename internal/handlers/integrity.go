@@ -0,0 +1,257 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/integrity"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// IntegrityHandler handles checks of the patient references of clinical records
+type IntegrityHandler struct {
+	db      *gorm.DB
+	checker *integrity.Checker
+}
+
+// NewIntegrityHandler creates a new integrity handler
+func NewIntegrityHandler(db *gorm.DB, checker *integrity.Checker) *IntegrityHandler {
+	return &IntegrityHandler{
+		db:      db,
+		checker: checker,
+	}
+}
+
+// CreateIntegrityRun queues a reference integrity check
+// @Summary Start integrity run
+// @Description Queue a check of the patient references of clinical records for patients that are missing, pending deletion or merged into another record. References to merged patients are re-pointed to the surviving record unless repoint is false or the record is locked, such as a signed note (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param request body models.IntegrityRunRequest false "Run options"
+// @Success 202 {object} models.IntegrityRun
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integrity/runs [post]
+func (h *IntegrityHandler) CreateIntegrityRun(c *gin.Context) {
+	var req models.IntegrityRunRequest
+	if c.Request.ContentLength != 0 {
+		if err := c.ShouldBindJSON(&req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid request body",
+				Message: err.Error(),
+				Code:    "INVALID_REQUEST_BODY",
+			})
+			return
+		}
+	}
+
+	userID, _ := auth.GetUserID(c)
+	run := models.IntegrityRun{
+		Status:    models.IntegrityRunQueued,
+		Repoint:   h.checker.Repoints(),
+		CreatedBy: userID,
+	}
+	if req.Repoint != nil {
+		run.Repoint = *req.Repoint
+	}
+
+	if err := h.db.Create(&run).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create integrity run",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "IntegrityRun", userID, map[string]interface{}{
+		"run_id":  run.ID,
+		"repoint": run.Repoint,
+	})
+
+	h.checker.Wake()
+	c.JSON(http.StatusAccepted, run)
+}
+
+// GetIntegrityRuns lists integrity runs
+// @Summary Get integrity runs
+// @Description List scheduled and requested integrity runs, newest first (admin only)
+// @Tags admin
+// @Produce json
+// @Param status query string false "Filter by status (queued, running, completed, failed)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.IntegrityRun}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integrity/runs [get]
+func (h *IntegrityHandler) GetIntegrityRuns(c *gin.Context) {
+	page, limit := capturePage(c)
+
+	query := h.db.Model(&models.IntegrityRun{})
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count integrity runs",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var runs []models.IntegrityRun
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&runs).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch integrity runs",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, runs, total, page, limit)
+}
+
+// GetIntegrityRun retrieves the report of an integrity run
+// @Summary Get integrity run
+// @Description Get the status of an integrity run and, once completed, the records checked, dangling and re-pointed, with dangling references counted by resource type and problem (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Integrity run ID"
+// @Success 200 {object} models.IntegrityRun
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integrity/runs/{id} [get]
+func (h *IntegrityHandler) GetIntegrityRun(c *gin.Context) {
+	var run models.IntegrityRun
+	if !h.findRun(c, &run) {
+		return
+	}
+
+	c.JSON(http.StatusOK, run)
+}
+
+// GetIntegrityFindings lists the findings of an integrity run
+// @Summary Get integrity findings
+// @Description List the records found with a patient reference problem, with the record a merged patient's reference was re-pointed to or why it was not (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Integrity run ID"
+// @Param problem query string false "Filter by problem (missing, deleted, replaced)"
+// @Param resourceType query string false "Filter by resource type, e.g. ClinicalNote"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.IntegrityFinding}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integrity/runs/{id}/findings [get]
+func (h *IntegrityHandler) GetIntegrityFindings(c *gin.Context) {
+	var run models.IntegrityRun
+	if !h.findRun(c, &run) {
+		return
+	}
+	page, limit := capturePage(c)
+
+	query := h.db.Model(&models.IntegrityFinding{}).Where("run_id = ?", run.ID)
+	if problem := c.Query("problem"); problem != "" {
+		query = query.Where("problem = ?", problem)
+	}
+	if resourceType := c.Query("resourceType"); resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count integrity findings",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var findings []models.IntegrityFinding
+	if err := query.Order("resource_type ASC, reference ASC, resource_id ASC").
+		Offset((page - 1) * limit).Limit(limit).Find(&findings).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch integrity findings",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, findings, total, page, limit)
+}
+
+// GetIntegrityReport reports orphan and malformed records
+// @Summary Database integrity report
+// @Description Scan for observations whose subject patient is missing or pending deletion, users granted roles that no longer exist, and JSON payloads of patients and clinical records that do not decode, with counts and up to 10 sample IDs of each for remediation. The scan reads every clinical record, so it can take a while on large databases (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} integrity.Report
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/integrity-report [get]
+func (h *IntegrityHandler) GetIntegrityReport(c *gin.Context) {
+	report, err := integrity.BuildReport(c.Request.Context(), readDB(c, h.db))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to build integrity report",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("read", "IntegrityReport", userID, map[string]interface{}{
+		"orphan_observations": report.OrphanObservations.Count,
+		"orphan_roles":        report.OrphanRoles.Count,
+		"malformed_columns":   len(report.MalformedJSON),
+	})
+
+	c.JSON(http.StatusOK, report)
+}
+
+// findRun loads the integrity run in the path, writing the error response when it
+// is not found
+func (h *IntegrityHandler) findRun(c *gin.Context, run *models.IntegrityRun) bool {
+	if err := h.db.Where("id = ?", c.Param("id")).First(run).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Integrity run not found",
+				Code:  "INTEGRITY_RUN_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch integrity run",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,336 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/retention"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// LegalHoldHandler handles HTTP requests for legal holds, which keep patients and
+// their records from being purged
+type LegalHoldHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewLegalHoldHandler creates a new legal hold handler
+func NewLegalHoldHandler(db *gorm.DB) *LegalHoldHandler {
+	return &LegalHoldHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// holdNotFound are the responses for a held resource that does not exist, by resource type
+var holdNotFound = map[string]ErrorResponse{
+	"Patient":            {Error: "Patient not found", Code: "PATIENT_NOT_FOUND"},
+	"Observation":        {Error: "Observation not found", Code: "OBSERVATION_NOT_FOUND"},
+	"Condition":          {Error: "Condition not found", Code: "CONDITION_NOT_FOUND"},
+	"AllergyIntolerance": {Error: "Allergy not found", Code: "ALLERGY_NOT_FOUND"},
+	"Procedure":          {Error: "Procedure not found", Code: "PROCEDURE_NOT_FOUND"},
+	"Coverage":           {Error: "Coverage not found", Code: "COVERAGE_NOT_FOUND"},
+	"Media":              {Error: "Media not found", Code: "MEDIA_NOT_FOUND"},
+	"DocumentReference":  {Error: "Document reference not found", Code: "DOCUMENT_REFERENCE_NOT_FOUND"},
+	"RelatedPerson":      {Error: "Related person not found", Code: "RELATED_PERSON_NOT_FOUND"},
+}
+
+// CreateLegalHold places a legal hold on a patient or one of their records
+// @Summary Place legal hold
+// @Description Place a legal hold on a patient, observation, condition, allergy, procedure, coverage, media, document reference or related person, including one pending deletion. The retention purge keeps a held resource until every hold on it is released, and a held related person cannot be deleted; a hold on a patient also keeps all their records, and a held record keeps its patient (compliance only).
+// @Tags legal-holds
+// @Accept json
+// @Produce json
+// @Param hold body models.LegalHoldRequest true "Resource to hold and the reason"
+// @Success 201 {object} models.LegalHold
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/legal-holds [post]
+func (h *LegalHoldHandler) CreateLegalHold(c *gin.Context) {
+	var req models.LegalHoldRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	db := writeDB(c, h.db)
+
+	// Deleted resources can be held too; keeping them from the purge is the point
+	var model interface{}
+	switch req.ResourceType {
+	case "Patient":
+		model = &models.Patient{}
+	case "Observation":
+		model = &models.Observation{}
+	default:
+		record, _ := retention.RecordOf(req.ResourceType)
+		model = record.New()
+	}
+	notFound := holdNotFound[req.ResourceType]
+	var count int64
+	if err := db.Unscoped().Model(model).Where("id = ?", req.ResourceID).Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch resource",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if count == 0 {
+		respondError(c, http.StatusNotFound, notFound)
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	hold := models.LegalHold{
+		ResourceType: req.ResourceType,
+		ResourceID:   req.ResourceID,
+		Reason:       req.Reason,
+		PlacedBy:     userID,
+		PlacedAt:     time.Now().UTC(),
+		Active:       true,
+	}
+	if err := db.Create(&hold).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to place legal hold",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "LegalHold", userID, map[string]interface{}{
+		"hold_id":       hold.ID,
+		"resource_type": hold.ResourceType,
+		"resource_id":   hold.ResourceID,
+		"reason":        hold.Reason,
+	})
+
+	c.JSON(http.StatusCreated, hold)
+}
+
+// GetLegalHolds lists legal holds
+// @Summary List legal holds
+// @Description List legal holds, most recently placed first (compliance or admin)
+// @Tags legal-holds
+// @Produce json
+// @Param resourceType query string false "Filter by resource type (Patient, Observation)"
+// @Param resourceId query string false "Filter by resource ID"
+// @Param active query bool false "Filter by whether the hold is still in force"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.LegalHold}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/legal-holds [get]
+func (h *LegalHoldHandler) GetLegalHolds(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.LegalHold{})
+	if resourceType := strings.TrimSpace(c.Query("resourceType")); resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+	if resourceID := strings.TrimSpace(c.Query("resourceId")); resourceID != "" {
+		query = query.Where("resource_id = ?", resourceID)
+	}
+	if active, err := strconv.ParseBool(c.Query("active")); err == nil {
+		if active {
+			query = query.Where("released_at IS NULL")
+		} else {
+			query = query.Where("released_at IS NOT NULL")
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count legal holds",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var holds []models.LegalHold
+	if err := query.Order("placed_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&holds).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch legal holds",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("list", "LegalHold", userID, map[string]interface{}{
+		"resource_type": c.Query("resourceType"),
+		"resource_id":   c.Query("resourceId"),
+		"count":         len(holds),
+	})
+
+	respondPage(c, holds, total, page, limit)
+}
+
+// GetLegalHold retrieves a legal hold
+// @Summary Get legal hold
+// @Description Get a legal hold by ID (compliance or admin)
+// @Tags legal-holds
+// @Produce json
+// @Param id path string true "Legal hold ID"
+// @Success 200 {object} models.LegalHold
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/legal-holds/{id} [get]
+func (h *LegalHoldHandler) GetLegalHold(c *gin.Context) {
+	var hold models.LegalHold
+	if !findLegalHold(c, readDB(c, h.db), &hold) {
+		return
+	}
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("read", "LegalHold", userID, map[string]interface{}{
+		"hold_id":     hold.ID,
+		"resource_id": hold.ResourceID,
+	})
+	c.JSON(http.StatusOK, hold)
+}
+
+// ReleaseLegalHold releases a legal hold
+// @Summary Release legal hold
+// @Description Release a legal hold. The resource can be purged again once no other hold keeps it; a deleted resource past its undo window is purged on the next run (compliance only).
+// @Tags legal-holds
+// @Accept json
+// @Produce json
+// @Param id path string true "Legal hold ID"
+// @Param release body models.LegalHoldReleaseRequest true "Reason for the release"
+// @Success 200 {object} models.LegalHold
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/legal-holds/{id}/release [post]
+func (h *LegalHoldHandler) ReleaseLegalHold(c *gin.Context) {
+	var req models.LegalHoldReleaseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	db := writeDB(c, h.db)
+	var hold models.LegalHold
+	if !findLegalHold(c, db, &hold) {
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	now := time.Now().UTC()
+	result := db.Model(&models.LegalHold{}).
+		Where("id = ? AND released_at IS NULL", hold.ID).
+		Updates(map[string]interface{}{
+			"released_by":    userID,
+			"released_at":    now,
+			"release_reason": req.Reason,
+			"updated_at":     now,
+		})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to release legal hold",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Legal hold has already been released",
+			Code:  "LEGAL_HOLD_RELEASED",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("release", "LegalHold", userID, map[string]interface{}{
+		"hold_id":       hold.ID,
+		"resource_type": hold.ResourceType,
+		"resource_id":   hold.ResourceID,
+		"reason":        req.Reason,
+	})
+
+	hold.ReleasedBy = userID
+	hold.ReleasedAt = &now
+	hold.ReleaseReason = req.Reason
+	hold.UpdatedAt = now
+	hold.Active = false
+	c.JSON(http.StatusOK, hold)
+}
+
+// findLegalHold loads a legal hold by the ID in the path, writing the error response
+// when it cannot
+func findLegalHold(c *gin.Context, db *gorm.DB, hold *models.LegalHold) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(hold).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Legal hold not found",
+				Code:  "LEGAL_HOLD_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch legal hold",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,537 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"io"
+	"mime"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/imaging"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// MediaHandler handles HTTP requests for observation media attachments
+type MediaHandler struct {
+	db            *gorm.DB
+	storage       storage.Storage
+	signer        *storage.URLSigner
+	urlTTL        time.Duration
+	maxUploadSize int64
+	undo          time.Duration
+}
+
+// NewMediaHandler creates a new media handler. Deleted media can be restored until the
+// undo window has passed.
+func NewMediaHandler(db *gorm.DB, store storage.Storage, signer *storage.URLSigner, urlTTL time.Duration, maxUploadSize int64, undoWindow time.Duration) *MediaHandler {
+	return &MediaHandler{
+		db:            db,
+		storage:       store,
+		signer:        signer,
+		urlTTL:        urlTTL,
+		maxUploadSize: maxUploadSize,
+		undo:          undoWindow,
+	}
+}
+
+// UploadObservationMedia uploads a media file and attaches it to an observation
+// @Summary Upload observation media
+// @Description Upload an image or PDF (ECG strip, wound photo) and attach it to an observation
+// @Tags media
+// @Accept multipart/form-data
+// @Produce json
+// @Param id path string true "Observation ID"
+// @Param file formData file true "Media file"
+// @Param title formData string false "Media title"
+// @Success 201 {object} models.Media
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/observations/{id}/media [post]
+func (h *MediaHandler) UploadObservationMedia(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Observation ID is required",
+			Code:  "MISSING_OBSERVATION_ID",
+		})
+		return
+	}
+
+	var observation models.Observation
+	if err := h.db.Where("id = ?", id).First(&observation).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Observation not found",
+				Code:  "OBSERVATION_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch observation",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxUploadSize)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Media file is required",
+			Message: err.Error(),
+			Code:    "MISSING_MEDIA_FILE",
+		})
+		return
+	}
+
+	if fileHeader.Size > h.maxUploadSize {
+		respondError(c, http.StatusRequestEntityTooLarge, ErrorResponse{
+			Error: "Media file exceeds the maximum upload size",
+			Code:  "MEDIA_TOO_LARGE",
+		})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to read media file",
+			Message: err.Error(),
+			Code:    "INVALID_MEDIA_FILE",
+		})
+		return
+	}
+	defer file.Close()
+
+	content, err := io.ReadAll(file)
+	if err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Failed to read media file",
+			Message: err.Error(),
+			Code:    "INVALID_MEDIA_FILE",
+		})
+		return
+	}
+
+	// Validate the sniffed content type rather than trusting the client header
+	contentType := http.DetectContentType(content)
+	if !models.AllowedMediaContentTypes[contentType] {
+		respondError(c, http.StatusUnsupportedMediaType, ErrorResponse{
+			Error:   "Unsupported media content type",
+			Message: contentType,
+			Code:    "UNSUPPORTED_MEDIA_TYPE",
+		})
+		return
+	}
+
+	hash := sha256.Sum256(content)
+	now := time.Now()
+
+	title := c.PostForm("title")
+	if title == "" {
+		title = fileHeader.Filename
+	}
+
+	media := models.Media{
+		Status:        "completed",
+		Subject:       observation.Subject,
+		PartOf:        models.Reference{Reference: "Observation/" + observation.ID, Type: "Observation"},
+		ObservationID: observation.ID,
+		Content: models.Attachment{
+			ContentType: contentType,
+			Size:        int64(len(content)),
+			Hash:        base64.StdEncoding.EncodeToString(hash[:]),
+			Title:       title,
+			Creation:    &now,
+		},
+	}
+
+	if userID, exists := auth.GetUserID(c); exists {
+		media.CreatedBy = userID
+	}
+
+	// Assign the ID up front so storage keys can be derived from it
+	if err := media.BeforeCreate(h.db); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to prepare media record",
+			Message: err.Error(),
+			Code:    "INTERNAL_ERROR",
+		})
+		return
+	}
+
+	media.StorageKey = "media/" + media.ID + "/original"
+	if _, err := h.storage.Put(media.StorageKey, bytes.NewReader(content)); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to store media file",
+			Message: err.Error(),
+			Code:    "STORAGE_ERROR",
+		})
+		return
+	}
+
+	if media.IsImage() {
+		thumbnail, width, height, err := imaging.Thumbnail(bytes.NewReader(content), imaging.DefaultThumbnailSize)
+		if err != nil {
+			// A broken thumbnail should not prevent the original from being stored
+			logger.Warn("Failed to generate media thumbnail", zap.String("media_id", media.ID), zap.Error(err))
+		} else {
+			media.Width = width
+			media.Height = height
+			media.ThumbnailKey = "media/" + media.ID + "/thumbnail.jpg"
+			if _, err := h.storage.Put(media.ThumbnailKey, bytes.NewReader(thumbnail)); err != nil {
+				logger.Warn("Failed to store media thumbnail", zap.String("media_id", media.ID), zap.Error(err))
+				media.ThumbnailKey = ""
+			}
+		}
+	}
+
+	media.Content.URL = "Media/" + media.ID
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&media).Error; err != nil {
+		tx.Rollback()
+		h.removeStoredContent(&media)
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create media record",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// The first attachment becomes the observation's valueAttachment
+	if observation.ValueAttachment == nil || observation.ValueAttachment.URL == "" {
+		attachment := media.Content
+		if err := tx.Model(&observation).Updates(models.Observation{ValueAttachment: &attachment}).Error; err != nil {
+			tx.Rollback()
+			h.removeStoredContent(&media)
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to attach media to observation",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		h.removeStoredContent(&media)
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to commit transaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.signURLs(&media)
+	c.JSON(http.StatusCreated, media)
+}
+
+// GetObservationMedia lists the media attached to an observation
+// @Summary Get observation media
+// @Description Get all media attached to an observation with short-lived download URLs
+// @Tags media
+// @Produce json
+// @Param id path string true "Observation ID"
+// @Success 200 {array} models.Media
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/observations/{id}/media [get]
+func (h *MediaHandler) GetObservationMedia(c *gin.Context) {
+	id := c.Param("id")
+	if id == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Observation ID is required",
+			Code:  "MISSING_OBSERVATION_ID",
+		})
+		return
+	}
+
+	var media []models.Media
+	if err := h.db.Where("observation_id = ?", id).Order("created_at ASC").Find(&media).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch media",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	for i := range media {
+		h.signURLs(&media[i])
+	}
+
+	c.JSON(http.StatusOK, media)
+}
+
+// GetMedia retrieves media metadata by ID
+// @Summary Get media by ID
+// @Description Get media metadata with short-lived download URLs
+// @Tags media
+// @Produce json
+// @Param id path string true "Media ID"
+// @Success 200 {object} models.Media
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/media/{id} [get]
+func (h *MediaHandler) GetMedia(c *gin.Context) {
+	media, ok := h.findMedia(c)
+	if !ok {
+		return
+	}
+
+	h.signURLs(media)
+	c.JSON(http.StatusOK, media)
+}
+
+// DownloadMediaContent streams the original media content for a signed URL
+// @Summary Download media content
+// @Description Download media content using a signed URL obtained from the media endpoints. Images are served inline and other content, such as PDFs, as an attachment.
+// @Tags media
+// @Produce octet-stream
+// @Param id path string true "Media ID"
+// @Param expires query int true "Signature expiry (unix seconds)"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} binary
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/media/{id}/content [get]
+func (h *MediaHandler) DownloadMediaContent(c *gin.Context) {
+	h.download(c, false)
+}
+
+// DownloadMediaThumbnail streams the media thumbnail for a signed URL
+// @Summary Download media thumbnail
+// @Description Download an image thumbnail using a signed URL obtained from the media endpoints
+// @Tags media
+// @Produce image/jpeg
+// @Param id path string true "Media ID"
+// @Param expires query int true "Signature expiry (unix seconds)"
+// @Param signature query string true "URL signature"
+// @Success 200 {file} binary
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /api/v1/media/{id}/thumbnail [get]
+func (h *MediaHandler) DownloadMediaThumbnail(c *gin.Context) {
+	h.download(c, true)
+}
+
+// DeleteMedia deletes a media record
+// @Summary Delete media
+// @Description Delete a media record (admin only). The deletion can be undone until the undo window passes; the stored content is then removed and the media detached from its observation.
+// @Tags media
+// @Param id path string true "Media ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/media/{id} [delete]
+func (h *MediaHandler) DeleteMedia(c *gin.Context) {
+	media, ok := h.findMedia(c)
+	if !ok {
+		return
+	}
+
+	// Mark the media as pending deletion; the retention job removes it and its content
+	// after the undo window
+	if err := trashRecord(c, h.db, media); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete media",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "Media", userID, map[string]interface{}{
+		"media_id":       media.ID,
+		"observation_id": media.ObservationID,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// UndeleteMedia restores a deleted media record within the undo window
+// @Summary Undelete media
+// @Description Restore a deleted media record as long as the undo window has not passed. Media deleted with their patient are restored by undeleting the patient (admin only).
+// @Tags media
+// @Produce json
+// @Param id path string true "Media ID"
+// @Success 200 {object} models.Media
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/media/{id}/undelete [post]
+func (h *MediaHandler) UndeleteMedia(c *gin.Context) {
+	var media models.Media
+	if !undeleteRecord(c, h.db, "Media", &media, h.undo, ErrorResponse{
+		Error: "Media not found",
+		Code:  "MEDIA_NOT_FOUND",
+	}) {
+		return
+	}
+
+	h.signURLs(&media)
+	c.JSON(http.StatusOK, media)
+}
+
+// download verifies the URL signature and streams the requested object
+func (h *MediaHandler) download(c *gin.Context, thumbnail bool) {
+	id := c.Param("id")
+	if !h.signer.Verify(c.Request.URL.Path, c.Query("expires"), c.Query("signature")) {
+		respondError(c, http.StatusForbidden, ErrorResponse{
+			Error: "Invalid or expired download URL",
+			Code:  "INVALID_SIGNATURE",
+		})
+		return
+	}
+
+	var media models.Media
+	if err := h.db.Where("id = ?", id).First(&media).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Media not found",
+				Code:  "MEDIA_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch media",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	key := media.StorageKey
+	contentType := media.Content.ContentType
+	if thumbnail {
+		key = media.ThumbnailKey
+		contentType = "image/jpeg"
+	}
+
+	if key == "" {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Media content not found",
+			Code:  "MEDIA_CONTENT_NOT_FOUND",
+		})
+		return
+	}
+
+	reader, err := h.storage.Get(key)
+	if err != nil {
+		if err == storage.ErrNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Media content not found",
+				Code:  "MEDIA_CONTENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to read media content",
+			Message: err.Error(),
+			Code:    "STORAGE_ERROR",
+		})
+		return
+	}
+	defer reader.Close()
+
+	// Uploads are served from a public URL, so browsers must not sniff them into HTML,
+	// and only images are shown in place rather than saved
+	disposition := "attachment"
+	if thumbnail || media.IsImage() {
+		disposition = "inline"
+	}
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": media.Content.Title}))
+	c.DataFromReader(http.StatusOK, -1, contentType, reader, nil)
+}
+
+// findMedia loads the media identified by the id path parameter, writing an error response on failure
+func (h *MediaHandler) findMedia(c *gin.Context) (*models.Media, bool) {
+	id := c.Param("id")
+	if id == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Media ID is required",
+			Code:  "MISSING_MEDIA_ID",
+		})
+		return nil, false
+	}
+
+	var media models.Media
+	if err := h.db.Where("id = ?", id).First(&media).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Media not found",
+				Code:  "MEDIA_NOT_FOUND",
+			})
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch media",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return nil, false
+	}
+
+	return &media, true
+}
+
+// signURLs replaces the stored resource URLs with short-lived signed download URLs
+func (h *MediaHandler) signURLs(media *models.Media) {
+	media.Content.URL = h.signer.SignedURL("/api/v1/media/"+media.ID+"/content", h.urlTTL)
+	if media.ThumbnailKey != "" {
+		media.ThumbnailURL = h.signer.SignedURL("/api/v1/media/"+media.ID+"/thumbnail", h.urlTTL)
+	}
+}
+
+// removeStoredContent deletes stored objects for media, logging failures
+func (h *MediaHandler) removeStoredContent(media *models.Media) {
+	for _, key := range []string{media.StorageKey, media.ThumbnailKey} {
+		if key == "" {
+			continue
+		}
+		if err := h.storage.Delete(key); err != nil && err != storage.ErrNotFound {
+			logger.Warn("Failed to delete stored media content", zap.String("media_id", media.ID), zap.String("key", key), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,801 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/interaction"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+const (
+	// administrationWindow is how far from its scheduled time a dose counts as on
+	// time; later doses are overdue
+	administrationWindow = 30 * time.Minute
+
+	// overdueLookback bounds how far back unrecorded doses are reported overdue
+	overdueLookback = 24 * time.Hour
+)
+
+// Due dose statuses
+const (
+	DoseDue     = "due"
+	DoseOverdue = "overdue"
+)
+
+// MedicationHandler handles HTTP requests for the medication administration record
+type MedicationHandler struct {
+	db             *gorm.DB
+	interactions   *interaction.Checker
+	blockAllergies bool
+	validator      *validator.Validate
+}
+
+// NewMedicationHandler creates a new medication handler. When blockAllergies is set,
+// prescribing a medication the patient is allergic to requires an override reason;
+// otherwise the allergy is only returned as a warning.
+func NewMedicationHandler(db *gorm.DB, interactions *interaction.Checker, blockAllergies bool) *MedicationHandler {
+	return &MedicationHandler{
+		db:             db,
+		interactions:   interactions,
+		blockAllergies: blockAllergies,
+		validator:      validator.New(),
+	}
+}
+
+// OverrideRequired is returned when a prescription has a severe interaction or an
+// allergy that needs an override reason
+type OverrideRequired struct {
+	ErrorResponse
+	InteractionWarnings []models.InteractionWarning `json:"interactionWarnings,omitempty"`
+	AllergyWarnings     []models.AllergyWarning     `json:"allergyWarnings,omitempty"`
+}
+
+// DueDose is a scheduled dose that has not been recorded yet
+type DueDose struct {
+	RequestID      string                  `json:"requestId"`
+	Medication     models.CodeableConcept  `json:"medication"`
+	Dose           models.Quantity         `json:"dose"`
+	Route          *models.CodeableConcept `json:"route,omitempty"`
+	ScheduledAt    time.Time               `json:"scheduledAt"`
+	Status         string                  `json:"status"` // due or overdue
+	MinutesOverdue int                     `json:"minutesOverdue,omitempty"`
+}
+
+// CreateRequest prescribes a medication
+// @Summary Create medication request
+// @Description Prescribe a medication whose doses are due every day at the given times, in the request's time zone. Without times the medication is given as needed. The medication codings should include the code printed as barcode on the package, which is checked when a dose is recorded. The medication is checked for interactions with the patient's active and on-hold medications, and it and its ingredients against the patient's active allergies. Both are returned as warnings; severe interactions require an interaction override reason, and allergies an allergy override reason unless allergy checks only warn.
+// @Tags medications
+// @Accept json
+// @Produce json
+// @Param request body models.MedicationRequestRequest true "Medication request"
+// @Success 201 {object} models.MedicationRequest
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} OverrideRequired
+// @Failure 500 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/medication-requests [post]
+func (h *MedicationHandler) CreateRequest(c *gin.Context) {
+	var req models.MedicationRequestRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+	invalid := func(message string) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: message,
+			Code:    "VALIDATION_FAILED",
+		})
+	}
+	if len(req.Medication.Coding) == 0 || req.Medication.Coding[0].Code == "" {
+		invalid("medication must have a coding with a code")
+		return
+	}
+	if req.Dose.Value <= 0 || req.Dose.Unit == "" {
+		invalid("dose must have a positive value and a unit")
+		return
+	}
+	if req.Subject.Reference == "" {
+		invalid("subject must reference a patient")
+		return
+	}
+
+	request := models.MedicationRequest{
+		Subject:       req.Subject,
+		Medication:    req.Medication,
+		Dose:          req.Dose,
+		Route:         req.Route,
+		DailySchedule: models.DailySchedule{Times: req.Times, Timezone: req.Timezone, StartsAt: time.Now().UTC(), EndsAt: req.EndsAt},
+		Ward:          req.Ward,
+		Note:          req.Note,
+	}
+	if req.StartsAt != nil {
+		request.StartsAt = req.StartsAt.UTC()
+	}
+	if request.EndsAt != nil && !request.EndsAt.After(request.StartsAt) {
+		invalid("endsAt must be after startsAt")
+		return
+	}
+	if request.Timezone == "" {
+		request.Timezone = "UTC"
+	}
+
+	// Validate that the referenced patient exists
+	patientID := strings.TrimPrefix(req.Subject.Reference, "Patient/")
+	var patient models.Patient
+	if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	request.Subject.Reference = "Patient/" + patientID
+	var ok bool
+	if request.ReasonReference, ok = resolveReasonReferences(c, h.db, patientID, req.ReasonReference); !ok {
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	interactionOverride := strings.TrimSpace(req.InteractionOverride)
+	warnings, checkErr := h.interactions.Check(c.Request.Context(), request.Subject.Reference, request.Medication)
+	if checkErr != nil {
+		// Without an override the prescriber must wait for the check to succeed
+		if interactionOverride == "" {
+			respondError(c, http.StatusServiceUnavailable, ErrorResponse{
+				Error:   "Drug interaction check unavailable",
+				Message: checkErr.Error() + "; retry later or give an interaction override reason",
+				Code:    "INTERACTION_CHECK_UNAVAILABLE",
+			})
+			return
+		}
+		logger.Warn("Prescribing without drug interaction check", zap.String("patient_id", patientID), zap.Error(checkErr))
+	}
+	allergies, err := h.interactions.CheckAllergies(c.Request.Context(), request.Subject.Reference, request.Medication, request.Ingredients)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check allergies",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	allergyOverride := strings.TrimSpace(req.AllergyOverride)
+	needInteractionOverride := interaction.Severe(warnings) && interactionOverride == ""
+	needAllergyOverride := h.blockAllergies && len(allergies) > 0 && allergyOverride == ""
+	if needInteractionOverride || needAllergyOverride {
+		response := OverrideRequired{
+			ErrorResponse: ErrorResponse{
+				Error:   "Severe drug interaction",
+				Message: "the medication has a severe interaction with the patient's current medications; give an interaction override reason to prescribe it",
+				Code:    "INTERACTION_OVERRIDE_REQUIRED",
+			},
+			InteractionWarnings: warnings,
+			AllergyWarnings:     allergies,
+		}
+		if needAllergyOverride {
+			response.ErrorResponse = ErrorResponse{
+				Error:   "Patient is allergic to the medication",
+				Message: "the patient has a recorded allergy to the medication or one of its ingredients; give an allergy override reason to prescribe it",
+				Code:    "ALLERGY_OVERRIDE_REQUIRED",
+			}
+			if needInteractionOverride {
+				response.Details = map[string]string{"interactionOverride": "required"}
+			}
+		}
+		apierror.Stamp(c, &response.ErrorResponse)
+		c.JSON(http.StatusConflict, response)
+		return
+	}
+	request.InteractionWarnings = warnings
+	if interactionOverride != "" && (checkErr != nil || interaction.Severe(warnings)) {
+		request.InteractionOverride = interactionOverride
+	}
+	request.AllergyWarnings = allergies
+	if len(allergies) > 0 {
+		request.AllergyOverride = allergyOverride
+	}
+
+	request.CreatedBy = userID
+	request.Prescriber = userID
+	if err := h.db.Create(&request).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create medication request",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "MedicationRequest", request.CreatedBy, map[string]interface{}{
+		"request_id": request.ID,
+		"patient_id": patientID,
+		"medication": request.Medication.Coding[0].Code,
+	})
+	if request.InteractionOverride != "" {
+		logger.LogAuditEvent("interaction_override", "MedicationRequest", request.CreatedBy, map[string]interface{}{
+			"request_id": request.ID,
+			"patient_id": patientID,
+			"warnings":   len(warnings),
+			"reason":     request.InteractionOverride,
+		})
+	}
+	if len(allergies) > 0 {
+		allergyIDs := make([]string, len(allergies))
+		for i := range allergies {
+			allergyIDs[i] = allergies[i].AllergyID
+		}
+		// Allergies only warned about are audited too, as are their overrides
+		action := "allergy_override"
+		if request.AllergyOverride == "" {
+			action = "allergy_warning"
+		}
+		logger.LogAuditEvent(action, "MedicationRequest", request.CreatedBy, map[string]interface{}{
+			"request_id":  request.ID,
+			"patient_id":  patientID,
+			"allergy_ids": allergyIDs,
+			"reason":      request.AllergyOverride,
+		})
+	}
+
+	c.JSON(http.StatusCreated, request)
+}
+
+// GetRequests lists medication requests
+// @Summary Get medication requests
+// @Description List medication requests, newest first
+// @Tags medications
+// @Produce json
+// @Param patient query string false "Filter by patient ID"
+// @Param status query string false "Filter by status (active, on-hold, stopped, completed)"
+// @Param ward query string false "Filter by ward"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.MedicationRequest}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/medication-requests [get]
+func (h *MedicationHandler) GetRequests(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.MedicationRequest{})
+	if patient := strings.TrimSpace(c.Query("patient")); patient != "" {
+		query = query.Where("subject_reference = ?", "Patient/"+patient)
+	}
+	for _, column := range []string{"status", "ward"} {
+		if value := c.Query(column); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count medication requests",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var requests []models.MedicationRequest
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&requests).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch medication requests",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, requests, total, page, limit)
+}
+
+// UpdateRequestStatus puts a medication request on hold, stops, completes or resumes it
+// @Summary Update medication request status
+// @Description Change the status of a medication request. Only active requests have due doses and can be administered.
+// @Tags medications
+// @Accept json
+// @Produce json
+// @Param id path string true "Medication request ID"
+// @Param request body models.MedicationRequestStatusRequest true "New status"
+// @Success 200 {object} models.MedicationRequest
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/medication-requests/{id}/status [post]
+func (h *MedicationHandler) UpdateRequestStatus(c *gin.Context) {
+	var req models.MedicationRequestStatusRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var request models.MedicationRequest
+	if err := h.db.Where("id = ?", c.Param("id")).First(&request).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Medication request not found",
+				Code:  "MEDICATION_REQUEST_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch medication request",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// Stopped and completed requests are final; a new prescription is needed
+	if request.Status == models.MedicationRequestStopped || request.Status == models.MedicationRequestCompleted {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Medication request has ended",
+			Code:  "MEDICATION_REQUEST_ENDED",
+		})
+		return
+	}
+
+	updates := map[string]interface{}{"status": req.Status}
+	if req.Status == models.MedicationRequestStopped || req.Status == models.MedicationRequestCompleted {
+		now := time.Now().UTC()
+		updates["ends_at"] = now
+		request.EndsAt = &now
+	}
+	if err := h.db.Model(&request).Updates(updates).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update medication request",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	request.Status = req.Status
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "MedicationRequest", userID, map[string]interface{}{
+		"request_id": request.ID,
+		"status":     request.Status,
+	})
+
+	c.JSON(http.StatusOK, request)
+}
+
+// GetDueDoses lists the due and overdue doses of a patient
+// @Summary Get due medication doses
+// @Description List the scheduled doses of the patient's active medication requests that have not been recorded, from 24 hours ago until the given number of hours ahead. A dose is overdue once it is more than 30 minutes past its scheduled time.
+// @Tags medications
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param hours query int false "Hours ahead to include (default: 4, max: 48)"
+// @Success 200 {array} DueDose
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/medication-doses [get]
+func (h *MedicationHandler) GetDueDoses(c *gin.Context) {
+	hours, err := strconv.Atoi(c.DefaultQuery("hours", "4"))
+	if err != nil || hours < 0 || hours > 48 {
+		hours = 4
+	}
+
+	db := readDB(c, h.db)
+	var requests []models.MedicationRequest
+	if err := db.Where("subject_reference = ? AND status = ?", "Patient/"+c.Param("id"), models.MedicationRequestActive).
+		Find(&requests).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch medication requests",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	doses := []DueDose{}
+	if len(requests) == 0 {
+		c.JSON(http.StatusOK, doses)
+		return
+	}
+
+	now := time.Now().UTC()
+	from, to := now.Add(-overdueLookback), now.Add(time.Duration(hours)*time.Hour)
+	ids := make([]string, len(requests))
+	for i := range requests {
+		ids[i] = requests[i].ID
+	}
+	var recorded []models.MedicationAdministration
+	if err := db.Select("request_id", "scheduled_at").
+		Where("request_id IN ? AND scheduled_at BETWEEN ? AND ? AND status <> ?", ids, from, to, models.AdministrationEnteredInError).
+		Find(&recorded).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch medication administrations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	done := make(map[string]bool, len(recorded))
+	for _, administration := range recorded {
+		done[administration.RequestID+"|"+administration.ScheduledAt.UTC().Format(time.RFC3339)] = true
+	}
+
+	for i := range requests {
+		request := &requests[i]
+		for _, due := range request.DueTimes(from, to) {
+			if done[request.ID+"|"+due.Format(time.RFC3339)] {
+				continue
+			}
+			dose := DueDose{
+				RequestID:   request.ID,
+				Medication:  request.Medication,
+				Dose:        request.Dose,
+				Route:       request.Route,
+				ScheduledAt: due,
+				Status:      DoseDue,
+			}
+			if late := now.Sub(due); late > administrationWindow {
+				dose.Status = DoseOverdue
+				dose.MinutesOverdue = int(late.Minutes())
+			}
+			doses = append(doses, dose)
+		}
+	}
+	sort.Slice(doses, func(i, j int) bool { return doses[i].ScheduledAt.Before(doses[j].ScheduledAt) })
+
+	c.JSON(http.StatusOK, doses)
+}
+
+// RecordAdministration records a dose given to or withheld from a patient
+// @Summary Record medication administration
+// @Description Record a dose of an active medication request after scanning the patient's wristband and the medication package. The patient barcode must be the patient's ID or one of their identifiers and the medication barcode one of the medication's codes; a mismatch is refused and audited. Without scheduledAt the dose is matched to an unrecorded scheduled dose within 30 minutes of its time, if any.
+// @Tags medications
+// @Accept json
+// @Produce json
+// @Param administration body models.MedicationAdministrationRequest true "Administration"
+// @Success 201 {object} models.MedicationAdministration
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/medication-administrations [post]
+func (h *MedicationHandler) RecordAdministration(c *gin.Context) {
+	var req models.MedicationAdministrationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var request models.MedicationRequest
+	if err := h.db.Where("id = ?", req.RequestID).First(&request).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Medication request not found",
+				Code:  "MEDICATION_REQUEST_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch medication request",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if request.Status != models.MedicationRequestActive {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Medication request is not active",
+			Code:  "MEDICATION_REQUEST_NOT_ACTIVE",
+		})
+		return
+	}
+
+	patientID := strings.TrimPrefix(request.Subject.Reference, "Patient/")
+	var patient models.Patient
+	if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// Barcode verification
+	userID, _ := auth.GetUserID(c)
+	mismatch := func(what, code string) {
+		logger.LogAuditEvent("barcode_mismatch", "MedicationAdministration", userID, map[string]interface{}{
+			"request_id": request.ID,
+			"patient_id": patientID,
+			"barcode":    what,
+		})
+		respondError(c, http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "Barcode does not match",
+			Message: "The scanned " + what + " barcode does not match the medication request",
+			Code:    code,
+		})
+	}
+	if !patientMatchesBarcode(&patient, req.PatientBarcode) {
+		mismatch("patient", "PATIENT_BARCODE_MISMATCH")
+		return
+	}
+	if req.MedicationBarcode != "" && !request.MatchesBarcode(req.MedicationBarcode) {
+		mismatch("medication", "MEDICATION_BARCODE_MISMATCH")
+		return
+	}
+
+	now := time.Now().UTC()
+	administration := models.MedicationAdministration{
+		RequestID:       request.ID,
+		Status:          req.Status,
+		StatusReason:    req.StatusReason,
+		Subject:         request.Subject,
+		Medication:      request.Medication,
+		Dose:            request.Dose,
+		Route:           request.Route,
+		EffectiveAt:     now,
+		Performer:       userID,
+		BarcodeVerified: req.MedicationBarcode != "",
+		Note:            req.Note,
+	}
+	if req.Dose != nil {
+		administration.Dose = *req.Dose
+	}
+	if req.EffectiveAt != nil {
+		administration.EffectiveAt = req.EffectiveAt.UTC()
+	}
+
+	if req.ScheduledAt != nil {
+		scheduled := req.ScheduledAt.UTC()
+		if len(request.DueTimes(scheduled, scheduled.Add(time.Minute))) == 0 {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: "scheduledAt is not a scheduled time of the medication request",
+				Code:    "VALIDATION_FAILED",
+			})
+			return
+		}
+		administration.ScheduledAt = &scheduled
+	} else {
+		scheduled, err := h.nearestDueDose(&request, administration.EffectiveAt)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to fetch medication administrations",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		administration.ScheduledAt = scheduled
+	}
+
+	if administration.ScheduledAt != nil {
+		var existing int64
+		if err := h.db.Model(&models.MedicationAdministration{}).
+			Where("request_id = ? AND scheduled_at = ? AND status <> ?", request.ID, *administration.ScheduledAt, models.AdministrationEnteredInError).
+			Count(&existing).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to check recorded doses",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if existing > 0 {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error: "Dose already recorded",
+				Code:  "DOSE_ALREADY_RECORDED",
+			})
+			return
+		}
+	}
+
+	if err := h.db.Create(&administration).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to record medication administration",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("administer", "MedicationAdministration", userID, map[string]interface{}{
+		"administration_id": administration.ID,
+		"request_id":        request.ID,
+		"patient_id":        patientID,
+		"status":            administration.Status,
+	})
+
+	c.JSON(http.StatusCreated, administration)
+}
+
+// GetPatientAdministrations lists the doses recorded for a patient
+// @Summary Get patient medication administrations
+// @Description List the doses given to or withheld from a patient, newest first
+// @Tags medications
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param from query string false "Filter by administration time from (ISO 8601)"
+// @Param to query string false "Filter by administration time to (ISO 8601)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.MedicationAdministration}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/medication-administrations [get]
+func (h *MedicationHandler) GetPatientAdministrations(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.MedicationAdministration{}).
+		Where("subject_reference = ?", "Patient/"+c.Param("id"))
+	if from := strings.TrimSpace(c.Query("from")); from != "" {
+		query = query.Where("effective_at >= ?", from)
+	}
+	if to := strings.TrimSpace(c.Query("to")); to != "" {
+		query = query.Where("effective_at <= ?", to)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count medication administrations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var administrations []models.MedicationAdministration
+	if err := query.Order("effective_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&administrations).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch medication administrations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, administrations, total, page, limit)
+}
+
+// nearestDueDose returns the unrecorded scheduled dose of a request closest to a
+// time and within the administration window of it, or nil if there is none
+func (h *MedicationHandler) nearestDueDose(request *models.MedicationRequest, at time.Time) (*time.Time, error) {
+	candidates := request.DueTimes(at.Add(-administrationWindow), at.Add(administrationWindow+time.Second))
+	if len(candidates) == 0 {
+		return nil, nil
+	}
+
+	var recorded []time.Time
+	if err := h.db.Model(&models.MedicationAdministration{}).
+		Where("request_id = ? AND scheduled_at IN ? AND status <> ?", request.ID, candidates, models.AdministrationEnteredInError).
+		Pluck("scheduled_at", &recorded).Error; err != nil {
+		return nil, err
+	}
+
+	var nearest *time.Time
+	for i := range candidates {
+		taken := false
+		for _, t := range recorded {
+			if t.Equal(candidates[i]) {
+				taken = true
+				break
+			}
+		}
+		if !taken && (nearest == nil || absDuration(candidates[i].Sub(at)) < absDuration(nearest.Sub(at))) {
+			nearest = &candidates[i]
+		}
+	}
+	return nearest, nil
+}
+
+// patientMatchesBarcode reports whether a scanned wristband barcode identifies the
+// patient, by ID or by one of their identifiers
+func patientMatchesBarcode(patient *models.Patient, barcode string) bool {
+	if barcode == patient.ID {
+		return true
+	}
+	for _, identifier := range patient.Identifier {
+		if identifier.Value != "" && identifier.Value == barcode {
+			return true
+		}
+	}
+	return false
+}
+
+// absDuration returns the absolute value of a duration
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
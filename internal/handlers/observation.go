@@ -1,34 +1,61 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/deltacheck"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
 	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/internal/turnaround"
+	"github.com/hillmatthew2000/HealthHub/internal/validation"
+	"github.com/hillmatthew2000/HealthHub/internal/writecheck"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
 	"gorm.io/gorm"
 )
 
 // ObservationHandler handles HTTP requests for observation resources
 type ObservationHandler struct {
-	db        *gorm.DB
-	validator *validator.Validate
+	db          *gorm.DB
+	validator   *validator.Validate
+	categories  *terminology.CategoryService
+	profiles    *validation.ProfileService
+	conformance *fhir.Validator
+	checks      *writecheck.Registry
+	localizer   *terminology.Localizer
+	undo        time.Duration
+	units       *quantityUnits
 }
 
-// NewObservationHandler creates a new observation handler
-func NewObservationHandler(db *gorm.DB) *ObservationHandler {
+// NewObservationHandler creates a new observation handler. New results pass the
+// deployment's write checks, such as a delta check against the patient's previous
+// ones. Observations sent as FHIR JSON are validated against US Core unless
+// conformance is nil. Coded values are read with their displays in the reader's
+// language. Deleted observations can be restored until the undo window has passed.
+func NewObservationHandler(db *gorm.DB, categories *terminology.CategoryService, profiles *validation.ProfileService, conformance *fhir.Validator, checks *writecheck.Registry, localizer *terminology.Localizer, undoWindow time.Duration) *ObservationHandler {
 	return &ObservationHandler{
-		db:        db,
-		validator: validator.New(),
+		db:          db,
+		validator:   validator.New(),
+		categories:  categories,
+		profiles:    profiles,
+		conformance: conformance,
+		checks:      checks,
+		localizer:   localizer,
+		undo:        undoWindow,
+		units:       &quantityUnits{},
 	}
 }
 
 // CreateObservation creates a new observation
 // @Summary Create a new observation
-// @Description Create a new lab result observation
+// @Description Create a new lab result observation. A Specimen/{id} specimen must be a specimen of the same patient that was neither rejected nor entered in error. A result flagged critical (HH, LL or AA) raises a critical alert, which is escalated along the on-call chain of the observation's category until acknowledged. A quantity result whose code has a delta check rule is compared with the patient's previous result; a change beyond the rule's thresholds adds a significant change up (U) or down (D) interpretation and, if the rule says so, raises an alert. A final, amended or corrected result without issued is issued now. When FHIR profile validation is enabled, a body sent as application/fhir+json must conform to US Core, or an OperationOutcome listing the issues is returned with 422.
 // @Tags observations
 // @Accept json
 // @Produce json
@@ -37,14 +64,16 @@ func NewObservationHandler(db *gorm.DB) *ObservationHandler {
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
+// @Failure 422 {object} fhir.OperationOutcome
 // @Failure 500 {object} ErrorResponse
 // @Security BearerAuth
 // @Router /api/v1/observations [post]
 func (h *ObservationHandler) CreateObservation(c *gin.Context) {
+	db := writeDB(c, h.db)
 	var observation models.Observation
 
 	if err := c.ShouldBindJSON(&observation); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request body",
 			Message: err.Error(),
 			Code:    "INVALID_REQUEST_BODY",
@@ -53,7 +82,7 @@ func (h *ObservationHandler) CreateObservation(c *gin.Context) {
 	}
 
 	if err := h.validator.Struct(observation); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Validation failed",
 			Message: err.Error(),
 			Code:    "VALIDATION_FAILED",
@@ -61,19 +90,28 @@ func (h *ObservationHandler) CreateObservation(c *gin.Context) {
 		return
 	}
 
+	violations, err := h.profiles.Validate(tenantID(c), "Observation", observation)
+	if respondProfileViolations(c, violations, err) {
+		return
+	}
+
+	if respondConformance(c, h.conformance, "Observation", observation) {
+		return
+	}
+
 	// Validate that the referenced patient exists
+	patientID := strings.TrimPrefix(observation.Subject.Reference, "Patient/")
 	if observation.Subject.Reference != "" {
-		patientID := strings.TrimPrefix(observation.Subject.Reference, "Patient/")
 		var patient models.Patient
-		if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusBadRequest, ErrorResponse{
+				respondError(c, http.StatusBadRequest, ErrorResponse{
 					Error: "Referenced patient not found",
 					Code:  "PATIENT_NOT_FOUND",
 				})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
 				Error:   "Failed to validate patient reference",
 				Message: err.Error(),
 				Code:    "DATABASE_ERROR",
@@ -81,20 +119,75 @@ func (h *ObservationHandler) CreateObservation(c *gin.Context) {
 			return
 		}
 	}
+	var ok bool
+	if observation.ReasonReference, ok = resolveReasonReferences(c, db, patientID, observation.ReasonReference); !ok {
+		return
+	}
+	if observation.Performer, ok = resolvePerformers(c, db, observation.Performer); !ok {
+		return
+	}
+	if observation.Specimen, ok = resolveSpecimenReference(c, db, patientID, observation.Specimen); !ok {
+		return
+	}
+	if observation.Device, ok = resolveDeviceReference(c, db, observation.Device); !ok {
+		return
+	}
 
 	// Set created by user
 	if userID, exists := auth.GetUserID(c); exists {
 		observation.CreatedBy = userID
 	}
 
-	if err := h.db.Create(&observation).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+	turnaround.Issue(&observation, time.Now().UTC())
+
+	results, err := h.checks.Run(c.Request.Context(), "Observation", &observation)
+	if respondCheckFailure(c, err) {
+		return
+	}
+	failure, _ := results[writecheck.ObservationDeltaCheck].(*deltacheck.Failure)
+
+	var alert *models.Alert
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&observation).Error; err != nil {
+			return err
+		}
+		if observation.IsCritical() {
+			if err := tx.Create(criticalResultAlert(&observation)).Error; err != nil {
+				return err
+			}
+		}
+		if failure == nil {
+			return nil
+		}
+		if alert = failure.Alert(&observation); alert != nil {
+			return tx.Create(alert).Error
+		}
+		return nil
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to create observation",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
 		})
 		return
 	}
+	// Simulated sandbox writes stay out of the turnaround metrics
+	if !simulated(c) {
+		turnaround.ObserveCreated(&observation)
+	}
+
+	if failure != nil {
+		details := map[string]interface{}{
+			"observation_id": observation.ID,
+			"previous_id":    failure.PreviousID,
+			"change":         failure.Change,
+		}
+		if alert != nil {
+			details["alert_id"] = alert.ID
+		}
+		logger.LogAuditEvent("delta_check_failed", "Observation", observation.CreatedBy, details)
+	}
 
 	c.JSON(http.StatusCreated, observation)
 }
@@ -109,10 +202,12 @@ func (h *ObservationHandler) CreateObservation(c *gin.Context) {
 // @Param limit query int false "Items per page (default: 10, max: 100)"
 // @Param patient query string false "Filter by patient ID"
 // @Param status query string false "Filter by status"
-// @Param category query string false "Filter by category"
+// @Param category query string false "Filter by category code or system|code"
 // @Param code query string false "Filter by observation code"
 // @Param from query string false "Filter by effective date from (ISO 8601)"
 // @Param to query string false "Filter by effective date to (ISO 8601)"
+// @Param value-quantity query string false "Filter by value quantity with an optional eq, ne, gt, lt, ge or le prefix and unit, e.g. gt5.5|mmol/L; UCUM units match commensurable units (repeatable)"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
 // @Success 200 {object} PaginatedResponse{data=[]models.Observation}
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
@@ -124,12 +219,14 @@ func (h *ObservationHandler) GetObservations(c *gin.Context) {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	patientID := strings.TrimSpace(c.Query("patient"))
-	status := strings.TrimSpace(c.Query("status"))
-	category := strings.TrimSpace(c.Query("category"))
-	code := strings.TrimSpace(c.Query("code"))
-	fromDate := strings.TrimSpace(c.Query("from"))
-	toDate := strings.TrimSpace(c.Query("to"))
+	filter := models.ObservationFilter{
+		Patient:  strings.TrimSpace(c.Query("patient")),
+		Status:   strings.TrimSpace(c.Query("status")),
+		Category: strings.TrimSpace(c.Query("category")),
+		Code:     strings.TrimSpace(c.Query("code")),
+		From:     strings.TrimSpace(c.Query("from")),
+		To:       strings.TrimSpace(c.Query("to")),
+	}
 
 	// Validate pagination parameters
 	if page < 1 {
@@ -138,41 +235,20 @@ func (h *ObservationHandler) GetObservations(c *gin.Context) {
 	if limit < 1 || limit > 100 {
 		limit = 10
 	}
-
-	var observations []models.Observation
-	query := h.db.Model(&models.Observation{})
-
-	// Apply filters
-	if patientID != "" {
-		patientRef := "Patient/" + patientID
-		query = query.Where("subject->>'reference' = ?", patientRef)
-	}
-
-	if status != "" {
-		query = query.Where("status = ?", status)
-	}
-
-	if category != "" {
-		query = query.Where("category::text ILIKE ?", "%"+category+"%")
-	}
-
-	if code != "" {
-		query = query.Where("code->>'text' ILIKE ? OR code->'coding'->0->>'code' ILIKE ? OR code->'coding'->0->>'display' ILIKE ?",
-			"%"+code+"%", "%"+code+"%", "%"+code+"%")
-	}
-
-	if fromDate != "" {
-		query = query.Where("effective_date_time >= ?", fromDate)
+	if !checkObservationFilter(c, filter) {
+		return
 	}
 
-	if toDate != "" {
-		query = query.Where("effective_date_time <= ?", toDate)
+	var observations []models.Observation
+	query, ok := h.applyValueQuantity(c, filter.Apply(readDB(c, h.db).Model(&models.Observation{})))
+	if !ok {
+		return
 	}
 
 	// Get total count
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to count observations",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -183,7 +259,7 @@ func (h *ObservationHandler) GetObservations(c *gin.Context) {
 	// Get observations with pagination
 	offset := (page - 1) * limit
 	if err := query.Order("effective_date_time DESC").Offset(offset).Limit(limit).Find(&observations).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch observations",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -191,15 +267,8 @@ func (h *ObservationHandler) GetObservations(c *gin.Context) {
 		return
 	}
 
-	response := PaginatedResponse{
-		Data:       observations,
-		Total:      total,
-		Page:       page,
-		Limit:      limit,
-		TotalPages: (total + int64(limit) - 1) / int64(limit),
-	}
-
-	c.JSON(http.StatusOK, response)
+	localizeObservations(c, h.localizer, observations...)
+	respondPage(c, observations, total, page, limit)
 }
 
 // GetObservation retrieves a specific observation by ID
@@ -209,6 +278,7 @@ func (h *ObservationHandler) GetObservations(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Observation ID"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
 // @Success 200 {object} models.Observation
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
@@ -220,7 +290,7 @@ func (h *ObservationHandler) GetObservations(c *gin.Context) {
 func (h *ObservationHandler) GetObservation(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error: "Observation ID is required",
 			Code:  "MISSING_OBSERVATION_ID",
 		})
@@ -228,15 +298,15 @@ func (h *ObservationHandler) GetObservation(c *gin.Context) {
 	}
 
 	var observation models.Observation
-	if err := h.db.Where("id = ?", id).First(&observation).Error; err != nil {
+	if err := readDB(c, h.db).Where("id = ?", id).First(&observation).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
+			respondError(c, http.StatusNotFound, ErrorResponse{
 				Error: "Observation not found",
 				Code:  "OBSERVATION_NOT_FOUND",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch observation",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -244,12 +314,13 @@ func (h *ObservationHandler) GetObservation(c *gin.Context) {
 		return
 	}
 
+	localizeObservations(c, h.localizer, observation)
 	c.JSON(http.StatusOK, observation)
 }
 
 // UpdateObservation updates an existing observation
 // @Summary Update observation
-// @Description Update an existing observation record
+// @Description Update an existing observation record. A new specimen, or the kept one when the subject changes, is checked as on create. A result first made final, amended or corrected without issued is issued now. When FHIR profile validation is enabled, a body sent as application/fhir+json must conform to US Core, or an OperationOutcome listing the issues is returned with 422.
 // @Tags observations
 // @Accept json
 // @Produce json
@@ -260,13 +331,15 @@ func (h *ObservationHandler) GetObservation(c *gin.Context) {
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} fhir.OperationOutcome
 // @Failure 500 {object} ErrorResponse
 // @Security BearerAuth
 // @Router /api/v1/observations/{id} [put]
 func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
+	db := writeDB(c, h.db)
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error: "Observation ID is required",
 			Code:  "MISSING_OBSERVATION_ID",
 		})
@@ -274,15 +347,15 @@ func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
 	}
 
 	var observation models.Observation
-	if err := h.db.Where("id = ?", id).First(&observation).Error; err != nil {
+	if err := db.Where("id = ?", id).First(&observation).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
+			respondError(c, http.StatusNotFound, ErrorResponse{
 				Error: "Observation not found",
 				Code:  "OBSERVATION_NOT_FOUND",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch observation",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -292,7 +365,7 @@ func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
 
 	var updateData models.Observation
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request body",
 			Message: err.Error(),
 			Code:    "INVALID_REQUEST_BODY",
@@ -301,7 +374,7 @@ func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
 	}
 
 	if err := h.validator.Struct(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Validation failed",
 			Message: err.Error(),
 			Code:    "VALIDATION_FAILED",
@@ -309,19 +382,51 @@ func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
 		return
 	}
 
+	// Categories are only replaced when supplied
+	if updateData.Category != nil {
+		if err := h.categories.Validate(updateData.Category); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid observation category",
+				Message: err.Error(),
+				Code:    "INVALID_CATEGORY",
+			})
+			return
+		}
+	}
+
+	violations, err := h.profiles.ValidateUpdate(tenantID(c), "Observation", observation, updateData)
+	if respondProfileViolations(c, violations, err) {
+		return
+	}
+
+	if h.conformance != nil && sentFHIR(c) {
+		var merged models.Observation
+		if err := mergeUpdate(observation, updateData, &merged); err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to apply FHIR profile",
+				Message: err.Error(),
+				Code:    "PROFILE_VALIDATION_ERROR",
+			})
+			return
+		}
+		if respondConformance(c, h.conformance, "Observation", merged) {
+			return
+		}
+	}
+
 	// Validate patient reference if changed
 	if updateData.Subject.Reference != "" && updateData.Subject.Reference != observation.Subject.Reference {
 		patientID := strings.TrimPrefix(updateData.Subject.Reference, "Patient/")
 		var patient models.Patient
-		if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
 			if err == gorm.ErrRecordNotFound {
-				c.JSON(http.StatusBadRequest, ErrorResponse{
+				respondError(c, http.StatusBadRequest, ErrorResponse{
 					Error: "Referenced patient not found",
 					Code:  "PATIENT_NOT_FOUND",
 				})
 				return
 			}
-			c.JSON(http.StatusInternalServerError, ErrorResponse{
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
 				Error:   "Failed to validate patient reference",
 				Message: err.Error(),
 				Code:    "DATABASE_ERROR",
@@ -330,13 +435,72 @@ func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
 		}
 	}
 
+	// Reason references are replaced when supplied and must be conditions of the
+	// observation's patient
+	if updateData.ReasonReference != nil {
+		subject := updateData.Subject.Reference
+		if subject == "" {
+			subject = observation.Subject.Reference
+		}
+		var ok bool
+		if updateData.ReasonReference, ok = resolveReasonReferences(c, db, strings.TrimPrefix(subject, "Patient/"), updateData.ReasonReference); !ok {
+			return
+		}
+	}
+
+	// Performers are replaced when supplied; practitioner references must exist
+	if updateData.Performer != nil {
+		var ok bool
+		if updateData.Performer, ok = resolvePerformers(c, db, updateData.Performer); !ok {
+			return
+		}
+	}
+
+	// A new specimen is checked as on create, and a kept one when the patient changes
+	if updateData.Specimen != nil && (observation.Specimen == nil || updateData.Specimen.Reference != observation.Specimen.Reference) {
+		subject := updateData.Subject.Reference
+		if subject == "" {
+			subject = observation.Subject.Reference
+		}
+		var ok bool
+		if updateData.Specimen, ok = resolveSpecimenReference(c, db, strings.TrimPrefix(subject, "Patient/"), updateData.Specimen); !ok {
+			return
+		}
+	} else if updateData.Subject.Reference != "" && updateData.Subject.Reference != observation.Subject.Reference &&
+		observation.Specimen != nil {
+		if _, ok := resolveSpecimenReference(c, db, strings.TrimPrefix(updateData.Subject.Reference, "Patient/"), observation.Specimen); !ok {
+			return
+		}
+	}
+
+	// A new device must be registered and in service; a kept one may since have retired
+	if updateData.Device != nil && (observation.Device == nil || updateData.Device.Reference != observation.Device.Reference) {
+		var ok bool
+		if updateData.Device, ok = resolveDeviceReference(c, db, updateData.Device); !ok {
+			return
+		}
+	}
+
 	// Preserve ID and audit fields
 	updateData.ID = id
 	updateData.CreatedAt = observation.CreatedAt
 	updateData.CreatedBy = observation.CreatedBy
 
-	if err := h.db.Model(&observation).Updates(updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+	// A result is issued when it is first verified
+	issuing := observation.Issued == nil
+	if issuing && updateData.Issued == nil {
+		verified := observation
+		if updateData.Status != "" {
+			verified.Status = updateData.Status
+		}
+		if turnaround.Verified(&verified) {
+			now := time.Now().UTC()
+			updateData.Issued = &now
+		}
+	}
+
+	if err := db.Model(&observation).Updates(updateData).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to update observation",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -345,21 +509,24 @@ func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
 	}
 
 	// Fetch updated observation
-	if err := h.db.Where("id = ?", id).First(&observation).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+	if err := db.Where("id = ?", id).First(&observation).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch updated observation",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
 		})
 		return
 	}
+	if issuing && !simulated(c) {
+		turnaround.ObserveVerified(&observation)
+	}
 
 	c.JSON(http.StatusOK, observation)
 }
 
 // DeleteObservation deletes an observation
 // @Summary Delete observation
-// @Description Delete an observation record (admin only)
+// @Description Delete an observation record (admin only). The deletion can be undone until the undo window passes.
 // @Tags observations
 // @Accept json
 // @Produce json
@@ -373,9 +540,10 @@ func (h *ObservationHandler) UpdateObservation(c *gin.Context) {
 // @Security BearerAuth
 // @Router /api/v1/observations/{id} [delete]
 func (h *ObservationHandler) DeleteObservation(c *gin.Context) {
+	db := writeDB(c, h.db)
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error: "Observation ID is required",
 			Code:  "MISSING_OBSERVATION_ID",
 		})
@@ -384,15 +552,15 @@ func (h *ObservationHandler) DeleteObservation(c *gin.Context) {
 
 	// Check if observation exists
 	var observation models.Observation
-	if err := h.db.Where("id = ?", id).First(&observation).Error; err != nil {
+	if err := db.Where("id = ?", id).First(&observation).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
+			respondError(c, http.StatusNotFound, ErrorResponse{
 				Error: "Observation not found",
 				Code:  "OBSERVATION_NOT_FOUND",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch observation",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -400,9 +568,19 @@ func (h *ObservationHandler) DeleteObservation(c *gin.Context) {
 		return
 	}
 
-	// Delete the observation
-	if err := h.db.Delete(&observation).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+	// Mark the observation as pending deletion; the retention job removes it after the undo window
+	userID, _ := auth.GetUserID(c)
+	if err := db.Model(&observation).UpdateColumn("deleted_by", userID).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete observation",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := db.Delete(&observation).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to delete observation",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -413,6 +591,91 @@ func (h *ObservationHandler) DeleteObservation(c *gin.Context) {
 	c.Status(http.StatusNoContent)
 }
 
+// UndeleteObservation restores a deleted observation within the undo window
+// @Summary Undelete observation
+// @Description Restore a deleted observation as long as the undo window has not passed. Observations deleted with their patient are restored by undeleting the patient (admin only).
+// @Tags observations
+// @Produce json
+// @Param id path string true "Observation ID"
+// @Success 200 {object} models.Observation
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/observations/{id}/undelete [post]
+func (h *ObservationHandler) UndeleteObservation(c *gin.Context) {
+	var observation models.Observation
+	if err := h.db.Unscoped().Where("id = ?", c.Param("id")).First(&observation).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Observation not found",
+				Code:  "OBSERVATION_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch observation",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if !observation.DeletedAt.Valid {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Observation is not deleted",
+			Code:  "OBSERVATION_NOT_DELETED",
+		})
+		return
+	}
+
+	if time.Since(observation.DeletedAt.Time) > h.undo {
+		respondError(c, http.StatusGone, ErrorResponse{
+			Error: "Undo window has passed",
+			Code:  "UNDO_WINDOW_EXPIRED",
+		})
+		return
+	}
+
+	// The subject must still exist, otherwise the observation would be orphaned
+	patientID := strings.TrimPrefix(observation.Subject.Reference, "Patient/")
+	var count int64
+	if err := h.db.Model(&models.Patient{}).Where("id = ?", patientID).Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if count == 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Referenced patient is deleted; undelete the patient instead",
+			Code:  "PATIENT_DELETED",
+		})
+		return
+	}
+
+	if err := h.db.Unscoped().Model(&observation).Updates(map[string]interface{}{"deleted_at": nil, "deleted_by": ""}).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to restore observation",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("undelete", "Observation", userID, map[string]interface{}{
+		"observation_id": observation.ID,
+	})
+
+	c.JSON(http.StatusOK, observation)
+}
+
 // GetPatientObservations retrieves all observations for a specific patient
 // @Summary Get patient observations
 // @Description Get all observations for a specific patient
@@ -423,7 +686,9 @@ func (h *ObservationHandler) DeleteObservation(c *gin.Context) {
 // @Param page query int false "Page number (default: 1)"
 // @Param limit query int false "Items per page (default: 10, max: 100)"
 // @Param status query string false "Filter by status"
-// @Param category query string false "Filter by category"
+// @Param category query string false "Filter by category code or system|code"
+// @Param value-quantity query string false "Filter by value quantity with an optional prefix and unit, e.g. gt5.5|mmol/L (repeatable)"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
 // @Success 200 {object} PaginatedResponse{data=[]models.Observation}
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
@@ -433,9 +698,10 @@ func (h *ObservationHandler) DeleteObservation(c *gin.Context) {
 // @Security BearerAuth
 // @Router /api/v1/patients/{patientId}/observations [get]
 func (h *ObservationHandler) GetPatientObservations(c *gin.Context) {
-	patientID := c.Param("patientId")
+	// Patient sub-routes share the :id wildcard with the patient routes
+	patientID := c.Param("id")
 	if patientID == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error: "Patient ID is required",
 			Code:  "MISSING_PATIENT_ID",
 		})
@@ -444,15 +710,15 @@ func (h *ObservationHandler) GetPatientObservations(c *gin.Context) {
 
 	// Verify patient exists
 	var patient models.Patient
-	if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+	if err := readDB(c, h.db).Where("id = ?", patientID).First(&patient).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
+			respondError(c, http.StatusNotFound, ErrorResponse{
 				Error: "Patient not found",
 				Code:  "PATIENT_NOT_FOUND",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to verify patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -463,8 +729,11 @@ func (h *ObservationHandler) GetPatientObservations(c *gin.Context) {
 	// Parse query parameters
 	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
 	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
-	status := strings.TrimSpace(c.Query("status"))
-	category := strings.TrimSpace(c.Query("category"))
+	filter := models.ObservationFilter{
+		Patient:  patientID,
+		Status:   strings.TrimSpace(c.Query("status")),
+		Category: strings.TrimSpace(c.Query("category")),
+	}
 
 	// Validate pagination parameters
 	if page < 1 {
@@ -473,24 +742,20 @@ func (h *ObservationHandler) GetPatientObservations(c *gin.Context) {
 	if limit < 1 || limit > 100 {
 		limit = 10
 	}
-
-	var observations []models.Observation
-	patientRef := "Patient/" + patientID
-	query := h.db.Model(&models.Observation{}).Where("subject->>'reference' = ?", patientRef)
-
-	// Apply additional filters
-	if status != "" {
-		query = query.Where("status = ?", status)
+	if !checkObservationFilter(c, filter) {
+		return
 	}
 
-	if category != "" {
-		query = query.Where("category::text ILIKE ?", "%"+category+"%")
+	var observations []models.Observation
+	query, ok := h.applyValueQuantity(c, filter.Apply(readDB(c, h.db).Model(&models.Observation{})))
+	if !ok {
+		return
 	}
 
 	// Get total count
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to count observations",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -501,7 +766,7 @@ func (h *ObservationHandler) GetPatientObservations(c *gin.Context) {
 	// Get observations with pagination
 	offset := (page - 1) * limit
 	if err := query.Order("effective_date_time DESC").Offset(offset).Limit(limit).Find(&observations).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch observations",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -509,13 +774,56 @@ func (h *ObservationHandler) GetPatientObservations(c *gin.Context) {
 		return
 	}
 
-	response := PaginatedResponse{
-		Data:       observations,
-		Total:      total,
-		Page:       page,
-		Limit:      limit,
-		TotalPages: (total + int64(limit) - 1) / int64(limit),
+	localizeObservations(c, h.localizer, observations...)
+	respondPage(c, observations, total, page, limit)
+}
+
+// checkObservationFilter validates an observation filter built from query parameters,
+// responding with 400 when it is malformed
+func checkObservationFilter(c *gin.Context, filter models.ObservationFilter) bool {
+	if err := filter.Validate(); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid filter",
+			Message: err.Error(),
+			Code:    "INVALID_FILTER",
+		})
+		return false
+	}
+	return true
+}
+
+// applyValueQuantity adds the value-quantity search parameters to an observation
+// query, responding with 400 when one is invalid
+func (h *ObservationHandler) applyValueQuantity(c *gin.Context, query *gorm.DB) (*gorm.DB, bool) {
+	for _, value := range queryValues(c.QueryArray("value-quantity")) {
+		filtered, err := applyValueQuantityFilter(query, value, h.units)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid value-quantity parameter",
+				Message: err.Error(),
+				Code:    "INVALID_VALUE_QUANTITY_PARAMETER",
+			})
+			return nil, false
+		}
+		query = filtered
 	}
+	return query, true
+}
 
-	c.JSON(http.StatusOK, response)
+// criticalResultAlert returns the alert raised for a critically abnormal result
+func criticalResultAlert(observation *models.Observation) *models.Alert {
+	return &models.Alert{
+		Type:         models.AlertTypeCriticalResult,
+		Severity:     models.AlertSeverityCritical,
+		ResourceType: "Observation",
+		ResourceID:   observation.ID,
+		Department:   observation.Department(),
+		Message: fmt.Sprintf("Critical result for %s: %s %s",
+			observation.Subject.Reference, observation.GetCodeDisplay(), observation.GetDisplayValue()),
+		Details: map[string]interface{}{
+			"patient": observation.Subject.Reference,
+			"code":    observation.GetCodeDisplay(),
+			"value":   observation.GetDisplayValue(),
+		},
+	}
 }
@@ -0,0 +1,204 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+)
+
+// defaultVolumeDays is the number of days, up to today, an observation volume report
+// covers by default
+const defaultVolumeDays = 30
+
+// viewRetryAfter is the Retry-After of a report whose materialized view has not been
+// refreshed yet, in seconds
+const viewRetryAfter = 60
+
+// ObservationVolume counts the observations of one day and category
+type ObservationVolume struct {
+	Day            string `json:"day"`
+	CategorySystem string `json:"categorySystem,omitempty"`
+	Category       string `json:"category"` // Empty for observations without a category
+	Observations   int64  `json:"observations"`
+	Patients       int64  `json:"patients"`
+}
+
+// ObservationVolumeReport represents the observations recorded per day and category
+type ObservationVolumeReport struct {
+	From        string              `json:"from"`
+	To          string              `json:"to"`
+	Data        []ObservationVolume `json:"data"`
+	RefreshedAt *time.Time          `json:"refreshedAt,omitempty"` // When the counts were computed
+	GeneratedAt time.Time           `json:"generatedAt"`
+}
+
+// LatestObservation is a patient's latest observation of a code
+type LatestObservation struct {
+	Subject           string    `json:"subject"`
+	System            string    `json:"system,omitempty"`
+	Code              string    `json:"code"`
+	Display           string    `json:"display,omitempty"`
+	ObservationID     string    `json:"observationId"`
+	Status            string    `json:"status"`
+	EffectiveDateTime time.Time `json:"effectiveDateTime"`
+	Value             *float64  `json:"value,omitempty"`
+	Unit              string    `json:"unit,omitempty"`
+	ValueString       string    `json:"valueString,omitempty"`
+}
+
+// GetObservationVolume reports the observations recorded per day and category
+// @Summary Observation volume report
+// @Description Observations and distinct patients per day of effective time, in UTC, and category, for dashboards. Counts are read from a materialized view refreshed on a schedule, so they trail recent changes by up to the refresh interval; refreshedAt and Last-Modified say when they were computed. An observation of several categories is counted in each, one without a category under an empty category; observations entered in error are left out.
+// @Tags analytics
+// @Produce json
+// @Param from query string false "First day (YYYY-MM-DD); 30 days before to by default"
+// @Param to query string false "Last day (YYYY-MM-DD); today by default"
+// @Param category query string false "Filter by category code, e.g. vital-signs"
+// @Success 200 {object} ObservationVolumeReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/analytics/observation-volume [get]
+func (h *AnalyticsHandler) GetObservationVolume(c *gin.Context) {
+	refreshedAt, ok := h.readyView(c, database.ObservationDailyCountsView)
+	if !ok {
+		return
+	}
+	from, to, ok := reportDays(c, defaultVolumeDays)
+	if !ok {
+		return
+	}
+
+	query := h.db.Table(database.ObservationDailyCountsView).Where("day BETWEEN ? AND ?", from, to)
+	if category := strings.TrimSpace(c.Query("category")); category != "" {
+		query = query.Where("category_code = ?", category)
+	}
+
+	report := ObservationVolumeReport{
+		From:        from,
+		To:          to,
+		Data:        []ObservationVolume{},
+		RefreshedAt: refreshedAt,
+		GeneratedAt: time.Now().UTC(),
+	}
+	if err := query.Select("to_char(day, 'YYYY-MM-DD') AS day, category_system, category_code AS category, observations, patients").
+		Order("day, category_system, category_code").Scan(&report.Data).Error; err != nil {
+		h.viewError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetLatestObservations lists the latest observation of each patient for each code
+// @Summary Latest observations report
+// @Description The latest observation by effective time of each patient for each code, such as the last HbA1c of every patient, for dashboards. Read from a materialized view refreshed on a schedule, so results trail recent changes by up to the refresh interval; Last-Modified says when they were computed. Observations entered in error or cancelled are left out.
+// @Tags analytics
+// @Produce json
+// @Param patient query string false "Filter by patient ID"
+// @Param code query string false "Filter by observation code"
+// @Param system query string false "Filter by code system"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]LatestObservation}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/analytics/latest-observations [get]
+func (h *AnalyticsHandler) GetLatestObservations(c *gin.Context) {
+	if _, ok := h.readyView(c, database.LatestObservationsView); !ok {
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := h.db.Table(database.LatestObservationsView)
+	if patient := strings.TrimSpace(c.Query("patient")); patient != "" {
+		query = query.Where("subject_reference = ?", "Patient/"+strings.TrimPrefix(patient, "Patient/"))
+	}
+	if code := strings.TrimSpace(c.Query("code")); code != "" {
+		query = query.Where("code = ?", code)
+	}
+	if system := strings.TrimSpace(c.Query("system")); system != "" {
+		query = query.Where("code_system = ?", system)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		h.viewError(c, err)
+		return
+	}
+
+	results := []LatestObservation{}
+	if err := query.Select(`subject_reference AS subject, code_system AS system, code, display, observation_id,
+			status, effective_date_time, value_quantity_value AS value, value_quantity_unit AS unit, value_string`).
+		Order("subject_reference, code_system, code").Offset((page - 1) * limit).Limit(limit).
+		Scan(&results).Error; err != nil {
+		h.viewError(c, err)
+		return
+	}
+
+	respondPage(c, results, total, page, limit)
+}
+
+// readyView checks that a report's materialized view can be read, writing the error
+// response when it cannot, and returns when it was last refreshed, also sent as
+// Last-Modified
+func (h *AnalyticsHandler) readyView(c *gin.Context, name string) (*time.Time, bool) {
+	if !dialect.IsPostgres(h.db) {
+		respondError(c, http.StatusNotImplemented, ErrorResponse{
+			Error:   "Report not available",
+			Message: "The report is read from materialized views, which require a PostgreSQL database",
+			Code:    "UNSUPPORTED_DATABASE",
+		})
+		return nil, false
+	}
+
+	refreshedAt, err := database.ViewReady(h.db, name)
+	if errors.Is(err, database.ErrViewNotPopulated) {
+		c.Header("Retry-After", strconv.Itoa(viewRetryAfter))
+		respondError(c, http.StatusServiceUnavailable, ErrorResponse{
+			Error:   "Report not ready",
+			Message: "the report's data is being computed for the first time",
+			Code:    "ANALYTICS_VIEW_NOT_READY",
+		})
+		return nil, false
+	}
+	if err != nil {
+		h.viewError(c, err)
+		return nil, false
+	}
+	if refreshedAt != nil {
+		c.Header("Last-Modified", refreshedAt.UTC().Format(http.TimeFormat))
+	}
+	return refreshedAt, true
+}
+
+// viewError writes the response of a failed materialized view read
+func (h *AnalyticsHandler) viewError(c *gin.Context, err error) {
+	respondError(c, http.StatusInternalServerError, ErrorResponse{
+		Error:   "Failed to read report",
+		Message: err.Error(),
+		Code:    "DATABASE_ERROR",
+	})
+}
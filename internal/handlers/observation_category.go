@@ -0,0 +1,188 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"gorm.io/gorm"
+)
+
+// ObservationCategoryHandler handles HTTP requests for the managed observation category code system
+type ObservationCategoryHandler struct {
+	db         *gorm.DB
+	validator  *validator.Validate
+	categories *terminology.CategoryService
+}
+
+// NewObservationCategoryHandler creates a new observation category handler
+func NewObservationCategoryHandler(db *gorm.DB, categories *terminology.CategoryService) *ObservationCategoryHandler {
+	return &ObservationCategoryHandler{
+		db:         db,
+		validator:  validator.New(),
+		categories: categories,
+	}
+}
+
+// GetCategories lists the managed observation categories
+// @Summary Get observation categories
+// @Description List the codes of the managed observation category code system
+// @Tags observation-categories
+// @Produce json
+// @Param active query bool false "Only list active categories"
+// @Success 200 {array} models.ObservationCategory
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/observation-categories [get]
+func (h *ObservationCategoryHandler) GetCategories(c *gin.Context) {
+	query := h.db.Model(&models.ObservationCategory{})
+	if c.Query("active") == "true" {
+		query = query.Where("active = ?", true)
+	}
+
+	var categories []models.ObservationCategory
+	if err := query.Order("code ASC").Find(&categories).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch observation categories",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, categories)
+}
+
+// CreateCategory adds a code to the managed observation category code system
+// @Summary Create observation category
+// @Description Add a local code to the managed observation category code system (admin only)
+// @Tags observation-categories
+// @Accept json
+// @Produce json
+// @Param category body models.ObservationCategory true "Category data"
+// @Success 201 {object} models.ObservationCategory
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/observation-categories [post]
+func (h *ObservationCategoryHandler) CreateCategory(c *gin.Context) {
+	var category models.ObservationCategory
+	if err := c.ShouldBindJSON(&category); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(category); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	category.Active = true
+	if err := h.db.Create(&category).Error; err != nil {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Failed to create observation category",
+			Message: err.Error(),
+			Code:    "CATEGORY_EXISTS",
+		})
+		return
+	}
+
+	h.reload(c)
+	c.JSON(http.StatusCreated, category)
+}
+
+// UpdateCategory updates the display, definition or active flag of a managed category
+// @Summary Update observation category
+// @Description Update a managed observation category. Inactive categories are rejected on new writes but remain on existing observations.
+// @Tags observation-categories
+// @Accept json
+// @Produce json
+// @Param code path string true "Category code"
+// @Param category body models.ObservationCategoryUpdateRequest true "Category data"
+// @Success 200 {object} models.ObservationCategory
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/observation-categories/{code} [put]
+func (h *ObservationCategoryHandler) UpdateCategory(c *gin.Context) {
+	var category models.ObservationCategory
+	if err := h.db.Where("code = ?", c.Param("code")).First(&category).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Observation category not found",
+				Code:  "CATEGORY_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch observation category",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var req models.ObservationCategoryUpdateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	updates := map[string]interface{}{
+		"display":    req.Display,
+		"definition": req.Definition,
+	}
+	if req.Active != nil {
+		updates["active"] = *req.Active
+	}
+
+	if err := h.db.Model(&category).Updates(updates).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update observation category",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.reload(c)
+	c.JSON(http.StatusOK, category)
+}
+
+// reload refreshes the category cache after a change. A failure leaves the previous
+// cache in place and is reported in a response header rather than failing the write.
+func (h *ObservationCategoryHandler) reload(c *gin.Context) {
+	if err := h.categories.Reload(); err != nil {
+		c.Header("Warning", `199 - "category cache not refreshed"`)
+	}
+}
@@ -0,0 +1,127 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+)
+
+// GetLatestPatientObservations lists a patient's latest observation of each code
+// @Summary Get latest patient observations
+// @Description The latest observation by effective time of each code for a patient, such as the last result of every lab test, in one request instead of paging through the patient's history. Observations are grouped by the system and code of their first coding; those without a coded code, entered in error or cancelled are left out. Results are ordered most recent first.
+// @Tags observations
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param code query string false "Comma-separated codes, each optionally as system|code, to limit the results to"
+// @Param category query string false "Filter by category code, e.g. laboratory"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Param Accept-Language header string false "Languages to show coded values in, falling back to the recorded displays"
+// @Success 200 {object} PaginatedResponse{data=[]models.Observation}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/observations/latest [get]
+func (h *ObservationHandler) GetLatestPatientObservations(c *gin.Context) {
+	// Patient sub-routes share the :id wildcard with the patient routes
+	patientID := c.Param("id")
+
+	var patient models.Patient
+	if err := readDB(c, h.db).Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to verify patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+	filter := models.ObservationFilter{
+		Patient:  patientID,
+		Category: strings.TrimSpace(c.Query("category")),
+	}
+	if !checkObservationFilter(c, filter) {
+		return
+	}
+
+	db := readDB(c, h.db)
+	d := dialect.Of(db)
+	system, code := d.JSONText("code", "coding", "0", "system"), d.JSONText("code", "coding", "0", "code")
+
+	query := filter.Apply(db.Model(&models.Observation{})).
+		Where("status NOT IN ?", []string{"entered-in-error", "cancelled"}).
+		Where(code + " IS NOT NULL")
+	if codes := queryValues(strings.Split(c.Query("code"), ",")); len(codes) > 0 {
+		var conditions []string
+		var args []interface{}
+		for _, token := range codes {
+			if tokenSystem, tokenCode, ok := strings.Cut(token, "|"); ok {
+				conditions = append(conditions, "("+system+" = ? AND "+code+" = ?)")
+				args = append(args, tokenSystem, tokenCode)
+			} else {
+				conditions = append(conditions, code+" = ?")
+				args = append(args, token)
+			}
+		}
+		query = query.Where("("+strings.Join(conditions, " OR ")+")", args...)
+	}
+
+	// PostgreSQL picks each code's latest row with DISTINCT ON, walking the latest
+	// observation index; other databases rank the patient's rows by code instead
+	var latest *gorm.DB
+	if d.Name() == dialect.Postgres {
+		latest = query.Select("DISTINCT ON (" + system + ", " + code + ") observations.*").
+			Order(system + ", " + code + ", effective_date_time DESC, id DESC")
+	} else {
+		ranked := query.Select("observations.*, ROW_NUMBER() OVER (PARTITION BY " + system + ", " + code +
+			" ORDER BY effective_date_time DESC, id DESC) AS latest_rank")
+		latest = db.Unscoped().Table("(?) AS ranked", ranked).Where("latest_rank = 1")
+	}
+
+	var total int64
+	if err := db.Unscoped().Table("(?) AS latest", latest).Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var observations []models.Observation
+	if err := db.Unscoped().Table("(?) AS latest", latest).Order("effective_date_time DESC, id DESC").
+		Offset((page - 1) * limit).Limit(limit).Find(&observations).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	localizeObservations(c, h.localizer, observations...)
+	respondPage(c, observations, total, page, limit)
+}
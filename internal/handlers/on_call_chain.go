@@ -0,0 +1,156 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// OnCallChainHandler handles HTTP requests for the on-call chains critical alerts
+// are escalated along
+type OnCallChainHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewOnCallChainHandler creates a new on-call chain handler
+func NewOnCallChainHandler(db *gorm.DB) *OnCallChainHandler {
+	return &OnCallChainHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// GetChains lists on-call chains
+// @Summary Get on-call chains
+// @Description List the on-call chain of each department (admin only)
+// @Tags alerts
+// @Produce json
+// @Success 200 {array} models.OnCallChain
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/on-call-chains [get]
+func (h *OnCallChainHandler) GetChains(c *gin.Context) {
+	var chains []models.OnCallChain
+	if err := h.db.Order("department ASC").Find(&chains).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch on-call chains",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, chains)
+}
+
+// PutChain creates or replaces the on-call chain of a department
+// @Summary Set on-call chain
+// @Description Set who is notified, in order, while a critical alert of the department is unacknowledged. The first contact is notified when the alert is raised and each next one after another acknowledgeMinutes. Alerts on observations belong to the department named by their category code; QC alerts to laboratory. (admin only)
+// @Tags alerts
+// @Accept json
+// @Produce json
+// @Param department path string true "Department"
+// @Param chain body models.OnCallChainRequest true "On-call chain"
+// @Success 200 {object} models.OnCallChain
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/on-call-chains/{department} [put]
+func (h *OnCallChainHandler) PutChain(c *gin.Context) {
+	var req models.OnCallChainRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	chain := models.OnCallChain{Department: c.Param("department")}
+	if err := h.db.Where("department = ?", chain.Department).First(&chain).Error; err != nil && err != gorm.ErrRecordNotFound {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch on-call chain",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	chain.AcknowledgeMinutes = req.AcknowledgeMinutes
+	chain.Contacts = req.Contacts
+	chain.Active = req.Active == nil || *req.Active
+	if err := h.db.Save(&chain).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to save on-call chain",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "OnCallChain", userID, map[string]interface{}{
+		"department": chain.Department,
+		"contacts":   len(chain.Contacts),
+	})
+
+	c.JSON(http.StatusOK, chain)
+}
+
+// DeleteChain removes the on-call chain of a department
+// @Summary Delete on-call chain
+// @Description Stop escalating the department's critical alerts (admin only)
+// @Tags alerts
+// @Param department path string true "Department"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/on-call-chains/{department} [delete]
+func (h *OnCallChainHandler) DeleteChain(c *gin.Context) {
+	result := h.db.Where("department = ?", c.Param("department")).Delete(&models.OnCallChain{})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete on-call chain",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "On-call chain not found",
+			Code:  "CHAIN_NOT_FOUND",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "OnCallChain", userID, map[string]interface{}{
+		"department": c.Param("department"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// operationRetryAfter is how many seconds clients are asked to wait between polls of a
+// pending operation
+const operationRetryAfter = "5"
+
+// OperationHandler handles the status and cancellation of background operations
+type OperationHandler struct {
+	db     *gorm.DB
+	runner *operation.Runner
+}
+
+// NewOperationHandler creates a new operation handler
+func NewOperationHandler(db *gorm.DB, runner *operation.Runner) *OperationHandler {
+	return &OperationHandler{
+		db:     db,
+		runner: runner,
+	}
+}
+
+// GetOperation retrieves the status of an operation
+// @Summary Get operation
+// @Description Poll an operation accepted with 202, such as an export or bulk job. While it is queued or running the response carries Retry-After; once completed its result holds the finished record. Operations are visible to the user who started them and to admins.
+// @Tags operations
+// @Produce json
+// @Param id path string true "Operation ID"
+// @Success 200 {object} models.Operation
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/operations/{id} [get]
+func (h *OperationHandler) GetOperation(c *gin.Context) {
+	var op models.Operation
+	if !h.findOperation(c, &op) {
+		return
+	}
+
+	if !op.Finished() {
+		c.Header("Retry-After", operationRetryAfter)
+	}
+	c.JSON(http.StatusOK, op)
+}
+
+// CancelOperation cancels an operation
+// @Summary Cancel operation
+// @Description Cancel a queued or running operation. A queued operation is cancelled at once (200); a running one stops at its next checkpoint and is accepted for cancellation (202), so poll it until it is cancelled. Work a running operation completed before stopping, such as the chunks of a bulk job, is kept.
+// @Tags operations
+// @Produce json
+// @Param id path string true "Operation ID"
+// @Success 200 {object} models.Operation
+// @Success 202 {object} models.Operation
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/operations/{id}/cancel [post]
+func (h *OperationHandler) CancelOperation(c *gin.Context) {
+	var op models.Operation
+	if !h.findOperation(c, &op) {
+		return
+	}
+
+	if err := h.runner.Cancel(&op); err != nil {
+		if err == operation.ErrFinished {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error:   "Operation has finished",
+				Message: "the operation is " + op.Status,
+				Code:    "OPERATION_FINISHED",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to cancel operation",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("operation_cancel", op.Kind, userID, map[string]interface{}{
+		"operation_id": op.ID,
+		"target":       op.Target,
+		"status":       op.Status,
+	})
+
+	if !op.Finished() {
+		c.Header("Retry-After", operationRetryAfter)
+		c.JSON(http.StatusAccepted, op)
+		return
+	}
+	c.JSON(http.StatusOK, op)
+}
+
+// findOperation loads the operation named by the id path parameter and writes the
+// error response when it cannot be found. Operations of other users are only found
+// by admins.
+func (h *OperationHandler) findOperation(c *gin.Context, op *models.Operation) bool {
+	query := h.db.Where("id = ?", c.Param("id"))
+	if roles, _ := auth.GetUserRoles(c); !containsRole(roles, "admin") {
+		userID, _ := auth.GetUserID(c)
+		query = query.Where("created_by = ?", userID)
+	}
+
+	if err := query.First(op).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Operation not found",
+				Code:  "OPERATION_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch operation",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
+
+// acceptOperation answers a request whose work runs as an operation with 202 and the
+// operation's URL in Location
+func acceptOperation(c *gin.Context, op *models.Operation, body interface{}) {
+	c.Header("Location", "/api/v1/operations/"+op.ID)
+	c.Header("Retry-After", operationRetryAfter)
+	c.JSON(http.StatusAccepted, body)
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// fhirResourceTypes are the FHIR resource types of route segments; the expressions
+// of an error's issues start from the resource type of its route
+var fhirResourceTypes = map[string]string{
+	"patients":             "Patient",
+	"observations":         "Observation",
+	"conditions":           "Condition",
+	"allergy-intolerances": "AllergyIntolerance",
+	"medication-requests":  "MedicationRequest",
+	"practitioners":        "Practitioner",
+	"procedures":           "Procedure",
+	"care-plans":           "CarePlan",
+	"specimens":            "Specimen",
+	"devices":              "Device",
+	"questionnaires":       "Questionnaire",
+	"media":                "Media",
+	"document-references":  "DocumentReference",
+	"coverages":            "Coverage",
+	"related-persons":      "RelatedPerson",
+}
+
+// fieldErrorPattern matches a field error of the request validator
+var fieldErrorPattern = regexp.MustCompile(`Key: '([^']+)' Error:Field validation for '[^']*' failed on the '([^']+)' tag`)
+
+// decodeErrorPattern names the field of a request body that failed to decode
+var decodeErrorPattern = regexp.MustCompile(`Go struct field ([\w.]+) of type`)
+
+// OperationOutcomeMiddleware returns the errors of FHIR requests, sent as FHIR JSON or
+// asking for it, as OperationOutcome resources instead of ErrorResponse. It must run
+// outside the recovery middleware so recovered panics are rewritten too.
+func OperationOutcomeMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if !sentFHIR(c) && !wantsFHIR(c) {
+			c.Next()
+			return
+		}
+
+		original := c.Writer
+		writer := &outcomeWriter{ResponseWriter: original}
+		c.Writer = writer
+		c.Next()
+		c.Writer = original
+
+		if !writer.held {
+			return
+		}
+		body := writer.body.Bytes()
+		if outcome := operationOutcome(writer.Status(), body, routeResourceType(c.FullPath())); outcome != nil {
+			if encoded, err := json.Marshal(outcome); err == nil {
+				body = encoded
+				original.Header().Set("Content-Type", fhir.MediaType+"; charset=utf-8")
+			}
+		}
+		original.Header().Del("Content-Length")
+		_, _ = original.Write(body)
+	}
+}
+
+// outcomeWriter holds back the body of an error response to rewrite it
+type outcomeWriter struct {
+	gin.ResponseWriter
+	body bytes.Buffer
+	held bool
+}
+
+func (w *outcomeWriter) Write(data []byte) (int, error) {
+	if w.Status() >= http.StatusBadRequest {
+		w.held = true
+		return w.body.Write(data)
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *outcomeWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush does not send a held body, which is written once rewritten
+func (w *outcomeWriter) Flush() {
+	if !w.held {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// Written reports a held body as written, so later handlers do not add another
+func (w *outcomeWriter) Written() bool {
+	return w.held || w.ResponseWriter.Written()
+}
+
+// operationOutcome converts an ErrorResponse to an OperationOutcome. It returns nil
+// for a body that is not an ErrorResponse, such as a FHIR resource.
+func operationOutcome(status int, body []byte, resourceType string) *fhir.OperationOutcome {
+	var resp struct {
+		ResourceType string          `json:"resourceType"`
+		Error        string          `json:"error"`
+		Message      string          `json:"message"`
+		Code         string          `json:"code"`
+		Details      json.RawMessage `json:"details"`
+		Reference    string          `json:"reference"` // Of a recovered panic, for support
+	}
+	if err := json.Unmarshal(body, &resp); err != nil || resp.ResourceType != "" || resp.Error == "" {
+		return nil
+	}
+
+	severity := fhir.SeverityError
+	if status >= http.StatusInternalServerError {
+		severity = fhir.SeverityFatal
+	}
+	var details *models.CodeableConcept
+	if resp.Code != "" {
+		details = &models.CodeableConcept{Coding: []models.Coding{{Code: resp.Code}}, Text: resp.Error}
+	}
+	issue := func(code, diagnostics string, expression ...string) fhir.Issue {
+		return fhir.Issue{Severity: severity, Code: code, Details: details, Diagnostics: diagnostics, Expression: expression}
+	}
+
+	var issues []fhir.Issue
+	// Failed field validations each point at their field
+	for _, match := range fieldErrorPattern.FindAllStringSubmatch(resp.Message, -1) {
+		code := fhir.IssueValue
+		if strings.HasPrefix(match[2], "required") {
+			code = fhir.IssueRequired
+		}
+		expression := fhirPath(resourceType, match[1])
+		issues = append(issues, issue(code, expression+" failed the '"+match[2]+"' rule", expression))
+	}
+	// Validation profile violations are keyed by the path of their rule
+	var violations map[string]string
+	if len(resp.Details) > 0 && json.Unmarshal(resp.Details, &violations) == nil {
+		paths := make([]string, 0, len(violations))
+		for path := range violations {
+			paths = append(paths, path)
+		}
+		sort.Strings(paths)
+		for _, path := range paths {
+			expression := path
+			if resourceType != "" {
+				expression = resourceType + "." + path
+			}
+			issues = append(issues, issue(fhir.IssueValue, path+" "+violations[path], expression))
+		}
+	}
+	if len(issues) > 0 {
+		return fhir.NewOperationOutcome(issues...)
+	}
+
+	diagnostics := resp.Error
+	if resp.Message != "" && resp.Message != resp.Error {
+		diagnostics += ": " + resp.Message
+	}
+	if resp.Reference != "" {
+		diagnostics += " (reference " + resp.Reference + ")"
+	}
+	base := issue(issueType(status, resp.Code), diagnostics)
+	if match := decodeErrorPattern.FindStringSubmatch(resp.Message); match != nil {
+		base.Expression = []string{fhirPath(resourceType, match[1])}
+	}
+	return fhir.NewOperationOutcome(base)
+}
+
+// issueType returns the FHIR issue type of an error
+func issueType(status int, code string) string {
+	if code == "INVALID_REQUEST_BODY" {
+		return fhir.IssueStructure
+	}
+	switch status {
+	case http.StatusBadRequest:
+		return fhir.IssueInvalid
+	case http.StatusUnauthorized:
+		return fhir.IssueLogin
+	case http.StatusForbidden:
+		return fhir.IssueForbidden
+	case http.StatusNotFound:
+		return fhir.IssueNotFound
+	case http.StatusMethodNotAllowed, http.StatusNotImplemented:
+		return fhir.IssueNotSupported
+	case http.StatusConflict, http.StatusPreconditionFailed:
+		return fhir.IssueConflict
+	case http.StatusGone:
+		return fhir.IssueDeleted
+	case http.StatusRequestEntityTooLarge:
+		return fhir.IssueTooCostly
+	case http.StatusTooManyRequests:
+		return fhir.IssueThrottled
+	case http.StatusServiceUnavailable, http.StatusGatewayTimeout:
+		return fhir.IssueTransient
+	}
+	if status >= http.StatusInternalServerError {
+		return fhir.IssueException
+	}
+	return fhir.IssueProcessing
+}
+
+// fhirPath converts the path of a request field, such as Patient.Name[0].Family, to a
+// FHIRPath expression from the resource type, such as Patient.name[0].family. The
+// path's first segment names the request type and is replaced by the resource type,
+// or dropped when the route has none.
+func fhirPath(resourceType, path string) string {
+	segments := strings.Split(path, ".")
+	if len(segments) > 1 {
+		segments = segments[1:]
+	}
+	for i, segment := range segments {
+		if segment != "" {
+			segments[i] = strings.ToLower(segment[:1]) + segment[1:]
+		}
+	}
+	if resourceType != "" {
+		segments = append([]string{resourceType}, segments...)
+	}
+	return strings.Join(segments, ".")
+}
+
+// routeResourceType returns the FHIR resource type of a route, from its last segment
+// naming one
+func routeResourceType(route string) string {
+	segments := strings.Split(route, "/")
+	for i := len(segments) - 1; i >= 0; i-- {
+		if resourceType, ok := fhirResourceTypes[segments[i]]; ok {
+			return resourceType
+		}
+	}
+	return ""
+}
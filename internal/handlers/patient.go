@@ -1,34 +1,54 @@
 package handlers
 
 import (
+	"context"
+	"fmt"
 	"net/http"
 	"strconv"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/go-playground/validator/v10"
 	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
 	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/validation"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/geocoding"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
 	"gorm.io/gorm"
 )
 
 // PatientHandler handles HTTP requests for patient resources
 type PatientHandler struct {
-	db        *gorm.DB
-	validator *validator.Validate
+	db          *gorm.DB
+	validator   *validator.Validate
+	geocoder    geocoding.Provider
+	profiles    *validation.ProfileService
+	conformance *fhir.Validator
+	undo        time.Duration
 }
 
-// NewPatientHandler creates a new patient handler
-func NewPatientHandler(db *gorm.DB) *PatientHandler {
+// NewPatientHandler creates a new patient handler. The geocoder may be nil, in which
+// case addresses are stored as submitted and never verified. Patients sent as FHIR
+// JSON are validated against US Core unless conformance is nil. Deleted patients
+// can be restored until the undo window has passed.
+func NewPatientHandler(db *gorm.DB, geocoder geocoding.Provider, profiles *validation.ProfileService, conformance *fhir.Validator, undoWindow time.Duration) *PatientHandler {
 	return &PatientHandler{
-		db:        db,
-		validator: validator.New(),
+		db:          db,
+		validator:   validator.New(),
+		geocoder:    geocoder,
+		profiles:    profiles,
+		conformance: conformance,
+		undo:        undoWindow,
 	}
 }
 
 // CreatePatient creates a new patient
 // @Summary Create a new patient
-// @Description Create a new patient record
+// @Description Create a new patient record. When FHIR profile validation is enabled, a body sent as application/fhir+json must conform to US Core, or an OperationOutcome listing the issues is returned with 422.
 // @Tags patients
 // @Accept json
 // @Produce json
@@ -37,14 +57,16 @@ func NewPatientHandler(db *gorm.DB) *PatientHandler {
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
+// @Failure 422 {object} fhir.OperationOutcome
 // @Failure 500 {object} ErrorResponse
 // @Security BearerAuth
 // @Router /api/v1/patients [post]
 func (h *PatientHandler) CreatePatient(c *gin.Context) {
+	db := writeDB(c, h.db)
 	var patient models.Patient
 
 	if err := c.ShouldBindJSON(&patient); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request body",
 			Message: err.Error(),
 			Code:    "INVALID_REQUEST_BODY",
@@ -53,7 +75,7 @@ func (h *PatientHandler) CreatePatient(c *gin.Context) {
 	}
 
 	if err := h.validator.Struct(patient); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Validation failed",
 			Message: err.Error(),
 			Code:    "VALIDATION_FAILED",
@@ -61,21 +83,43 @@ func (h *PatientHandler) CreatePatient(c *gin.Context) {
 		return
 	}
 
+	if err := patient.ValidateDemographics(); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	violations, err := h.profiles.Validate(tenantID(c), "Patient", patient)
+	if respondProfileViolations(c, violations, err) {
+		return
+	}
+
+	if respondConformance(c, h.conformance, "Patient", fhir.FromPatient(&patient)) {
+		return
+	}
+
+	h.verifyAddresses(c.Request.Context(), patient.Address, nil)
+	carryOverContactVerification(patient.Telecom, nil)
+
 	// Set created by user
 	if userID, exists := auth.GetUserID(c); exists {
 		patient.CreatedBy = userID
 	}
 
-	if err := h.db.Create(&patient).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+	if err := db.Create(&patient).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to create patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
 		})
 		return
 	}
+	patient.Age = patient.AgeAt(time.Now())
 
-	c.JSON(http.StatusCreated, patient)
+	h.respondPatient(c, http.StatusCreated, &patient)
 }
 
 // GetPatients retrieves patients with pagination and filtering
@@ -89,6 +133,11 @@ func (h *PatientHandler) CreatePatient(c *gin.Context) {
 // @Param search query string false "Search term for name or contact info"
 // @Param gender query string false "Filter by gender"
 // @Param active query bool false "Filter by active status"
+// @Param language query string false "Filter by communication language (BCP-47), including regional variants"
+// @Param interpreterRequired query bool false "Filter by interpreter requirement"
+// @Param age query string false "Filter by age in years with optional prefix, e.g. gt65 (repeatable)"
+// @Param birthdate query string false "Filter by birth date with optional prefix, e.g. ge1950-01-01 (repeatable)"
+// @Param near query string false "Filter by distance from a point as lat,lon[,km] (default radius: 10 km)"
 // @Success 200 {object} PaginatedResponse{data=[]models.Patient}
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
@@ -103,6 +152,9 @@ func (h *PatientHandler) GetPatients(c *gin.Context) {
 	search := strings.TrimSpace(c.Query("search"))
 	gender := strings.TrimSpace(c.Query("gender"))
 	activeStr := strings.TrimSpace(c.Query("active"))
+	near := strings.TrimSpace(c.Query("near"))
+	language := strings.TrimSpace(c.Query("language"))
+	interpreterStr := strings.TrimSpace(c.Query("interpreterRequired"))
 
 	// Validate pagination parameters
 	if page < 1 {
@@ -113,12 +165,35 @@ func (h *PatientHandler) GetPatients(c *gin.Context) {
 	}
 
 	var patients []models.Patient
-	query := h.db.Model(&models.Patient{})
+	db := readDB(c, h.db)
+	query := db.Model(&models.Patient{})
+	d := dialect.Of(db)
+
+	for name, value := range map[string]string{"search": search, "language": language} {
+		if err := dialect.CheckFilterText(name, value); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid filter",
+				Message: err.Error(),
+				Code:    "INVALID_FILTER",
+			})
+			return
+		}
+	}
 
 	// Apply filters
 	if search != "" {
-		searchPattern := "%" + search + "%"
-		query = query.Where("name::text ILIKE ? OR telecom::text ILIKE ?", searchPattern, searchPattern)
+		condition, args := dialect.ContainsAny(d, search, d.JSONString("name"), d.JSONString("telecom"))
+		query = query.Where(condition, args...)
+	}
+
+	// Language and distance searches expand JSON arrays with PostgreSQL functions
+	if (language != "" || near != "") && !dialect.IsPostgres(db) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Unsupported search parameter",
+			Message: "language and near searches require a PostgreSQL database",
+			Code:    "UNSUPPORTED_SEARCH_PARAMETER",
+		})
+		return
 	}
 
 	if gender != "" {
@@ -131,10 +206,72 @@ func (h *PatientHandler) GetPatients(c *gin.Context) {
 		}
 	}
 
+	// A language matches its regional variants, so "es" also finds "es-MX"
+	if language != "" {
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM jsonb_array_elements(COALESCE(patients.communication, '[]'::jsonb)) AS comm,
+				jsonb_array_elements(COALESCE(comm->'language'->'coding', '[]'::jsonb)) AS coding
+			WHERE lower(coding->>'code') = lower(?) OR lower(coding->>'code') LIKE lower(?) || '-%')`,
+			language, dialect.EscapeLike(language))
+	}
+
+	if interpreterStr != "" {
+		if interpreterRequired, err := strconv.ParseBool(interpreterStr); err == nil {
+			query = query.Where("interpreter_required = ?", interpreterRequired)
+		}
+	}
+
+	for _, age := range queryValues(c.QueryArray("age")) {
+		filtered, err := applyAgeFilter(query, age)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid age parameter",
+				Message: err.Error(),
+				Code:    "INVALID_AGE_PARAMETER",
+			})
+			return
+		}
+		query = filtered
+	}
+
+	for _, birthDate := range queryValues(c.QueryArray("birthdate")) {
+		filtered, err := applyBirthDateFilter(query, birthDate)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid birthdate parameter",
+				Message: err.Error(),
+				Code:    "INVALID_BIRTHDATE_PARAMETER",
+			})
+			return
+		}
+		query = filtered
+	}
+
+	if near != "" {
+		lat, lon, radius, err := parseNear(near)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid near parameter",
+				Message: err.Error(),
+				Code:    "INVALID_NEAR_PARAMETER",
+			})
+			return
+		}
+		// Great-circle distance in km against any geocoded address
+		query = query.Where(`EXISTS (
+			SELECT 1 FROM jsonb_array_elements(COALESCE(patients.address, '[]'::jsonb)) AS a
+			WHERE a->'geolocation' IS NOT NULL
+			AND 12742 * asin(sqrt(
+				power(sin(radians((a->'geolocation'->>'latitude')::float8 - ?) / 2), 2) +
+				cos(radians(?)) * cos(radians((a->'geolocation'->>'latitude')::float8)) *
+				power(sin(radians((a->'geolocation'->>'longitude')::float8 - ?) / 2), 2)
+			)) <= ?)`, lat, lat, lon, radius)
+	}
+
 	// Get total count
 	var total int64
 	if err := query.Count(&total).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to count patients",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -145,7 +282,7 @@ func (h *PatientHandler) GetPatients(c *gin.Context) {
 	// Get patients with pagination
 	offset := (page - 1) * limit
 	if err := query.Order("created_at DESC").Offset(offset).Limit(limit).Find(&patients).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch patients",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -153,15 +290,7 @@ func (h *PatientHandler) GetPatients(c *gin.Context) {
 		return
 	}
 
-	response := PaginatedResponse{
-		Data:       patients,
-		Total:      total,
-		Page:       page,
-		Limit:      limit,
-		TotalPages: (total + int64(limit) - 1) / int64(limit),
-	}
-
-	c.JSON(http.StatusOK, response)
+	respondPage(c, patients, total, page, limit)
 }
 
 // GetPatient retrieves a specific patient by ID
@@ -171,6 +300,7 @@ func (h *PatientHandler) GetPatients(c *gin.Context) {
 // @Accept json
 // @Produce json
 // @Param id path string true "Patient ID"
+// @Param follow query bool false "Follow replaced-by links to the surviving record (default: true)"
 // @Success 200 {object} models.Patient
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
@@ -182,23 +312,41 @@ func (h *PatientHandler) GetPatients(c *gin.Context) {
 func (h *PatientHandler) GetPatient(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error: "Patient ID is required",
 			Code:  "MISSING_PATIENT_ID",
 		})
 		return
 	}
 
+	// Follow replaced-by links to the surviving record unless the caller opts out
+	if c.DefaultQuery("follow", "true") != "false" {
+		survivorID, _, err := h.resolveSurvivor(id)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to resolve patient links",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if survivorID != id {
+			c.Header("Content-Location", "/api/v1/patients/"+survivorID)
+			id = survivorID
+		}
+	}
+
+	db := readDB(c, h.db)
 	var patient models.Patient
-	if err := h.db.Where("id = ?", id).First(&patient).Error; err != nil {
+	if err := db.Where("id = ?", id).First(&patient).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
+			respondError(c, http.StatusNotFound, ErrorResponse{
 				Error: "Patient not found",
 				Code:  "PATIENT_NOT_FOUND",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -206,12 +354,21 @@ func (h *PatientHandler) GetPatient(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, patient)
+	if err := db.Scopes(models.LiveLinks).Where("patient_id = ?", id).Order("created_at ASC").Find(&patient.Link).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient links",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.respondPatient(c, http.StatusOK, &patient)
 }
 
 // UpdatePatient updates an existing patient
 // @Summary Update patient
-// @Description Update an existing patient record
+// @Description Update an existing patient record. When FHIR profile validation is enabled, a body sent as application/fhir+json must conform to US Core, or an OperationOutcome listing the issues is returned with 422.
 // @Tags patients
 // @Accept json
 // @Produce json
@@ -222,13 +379,15 @@ func (h *PatientHandler) GetPatient(c *gin.Context) {
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
 // @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} fhir.OperationOutcome
 // @Failure 500 {object} ErrorResponse
 // @Security BearerAuth
 // @Router /api/v1/patients/{id} [put]
 func (h *PatientHandler) UpdatePatient(c *gin.Context) {
+	db := writeDB(c, h.db)
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error: "Patient ID is required",
 			Code:  "MISSING_PATIENT_ID",
 		})
@@ -236,15 +395,15 @@ func (h *PatientHandler) UpdatePatient(c *gin.Context) {
 	}
 
 	var patient models.Patient
-	if err := h.db.Where("id = ?", id).First(&patient).Error; err != nil {
+	if err := db.Where("id = ?", id).First(&patient).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
+			respondError(c, http.StatusNotFound, ErrorResponse{
 				Error: "Patient not found",
 				Code:  "PATIENT_NOT_FOUND",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -254,7 +413,7 @@ func (h *PatientHandler) UpdatePatient(c *gin.Context) {
 
 	var updateData models.Patient
 	if err := c.ShouldBindJSON(&updateData); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Invalid request body",
 			Message: err.Error(),
 			Code:    "INVALID_REQUEST_BODY",
@@ -263,7 +422,16 @@ func (h *PatientHandler) UpdatePatient(c *gin.Context) {
 	}
 
 	if err := h.validator.Struct(updateData); err != nil {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	if err := updateData.ValidateDemographics(); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error:   "Validation failed",
 			Message: err.Error(),
 			Code:    "VALIDATION_FAILED",
@@ -271,13 +439,36 @@ func (h *PatientHandler) UpdatePatient(c *gin.Context) {
 		return
 	}
 
+	violations, err := h.profiles.ValidateUpdate(tenantID(c), "Patient", patient, updateData)
+	if respondProfileViolations(c, violations, err) {
+		return
+	}
+
+	if h.conformance != nil && sentFHIR(c) {
+		var merged models.Patient
+		if err := mergeUpdate(patient, updateData, &merged); err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to apply FHIR profile",
+				Message: err.Error(),
+				Code:    "PROFILE_VALIDATION_ERROR",
+			})
+			return
+		}
+		if respondConformance(c, h.conformance, "Patient", fhir.FromPatient(&merged)) {
+			return
+		}
+	}
+
+	h.verifyAddresses(c.Request.Context(), updateData.Address, patient.Address)
+	carryOverContactVerification(updateData.Telecom, patient.Telecom)
+
 	// Preserve ID and audit fields
 	updateData.ID = id
 	updateData.CreatedAt = patient.CreatedAt
 	updateData.CreatedBy = patient.CreatedBy
 
-	if err := h.db.Model(&patient).Updates(updateData).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+	if err := db.Model(&patient).Updates(updateData).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to update patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -286,8 +477,8 @@ func (h *PatientHandler) UpdatePatient(c *gin.Context) {
 	}
 
 	// Fetch updated patient
-	if err := h.db.Where("id = ?", id).First(&patient).Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+	if err := db.Where("id = ?", id).First(&patient).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch updated patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -295,12 +486,12 @@ func (h *PatientHandler) UpdatePatient(c *gin.Context) {
 		return
 	}
 
-	c.JSON(http.StatusOK, patient)
+	h.respondPatient(c, http.StatusOK, &patient)
 }
 
 // DeletePatient deletes a patient
 // @Summary Delete patient
-// @Description Delete a patient record (admin only)
+// @Description Delete a patient record with its observations, conditions, allergies, procedures, coverages, media and documents (admin only). The deletion can be undone until the undo window passes.
 // @Tags patients
 // @Accept json
 // @Produce json
@@ -316,7 +507,7 @@ func (h *PatientHandler) UpdatePatient(c *gin.Context) {
 func (h *PatientHandler) DeletePatient(c *gin.Context) {
 	id := c.Param("id")
 	if id == "" {
-		c.JSON(http.StatusBadRequest, ErrorResponse{
+		respondError(c, http.StatusBadRequest, ErrorResponse{
 			Error: "Patient ID is required",
 			Code:  "MISSING_PATIENT_ID",
 		})
@@ -327,13 +518,13 @@ func (h *PatientHandler) DeletePatient(c *gin.Context) {
 	var patient models.Patient
 	if err := h.db.Where("id = ?", id).First(&patient).Error; err != nil {
 		if err == gorm.ErrRecordNotFound {
-			c.JSON(http.StatusNotFound, ErrorResponse{
+			respondError(c, http.StatusNotFound, ErrorResponse{
 				Error: "Patient not found",
 				Code:  "PATIENT_NOT_FOUND",
 			})
 			return
 		}
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to fetch patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -349,21 +540,21 @@ func (h *PatientHandler) DeletePatient(c *gin.Context) {
 		}
 	}()
 
-	// Delete related observations first (cascade delete)
-	if err := tx.Where("subject->>'reference' = ?", "Patient/"+id).Delete(&models.Observation{}).Error; err != nil {
+	userID, _ := auth.GetUserID(c)
+	if err := tx.Model(&patient).UpdateColumn("deleted_by", userID).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
-			Error:   "Failed to delete related observations",
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
 		})
 		return
 	}
 
-	// Delete the patient
+	// Mark the patient as pending deletion; the retention job removes it after the undo window
 	if err := tx.Delete(&patient).Error; err != nil {
 		tx.Rollback()
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to delete patient",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -371,8 +562,56 @@ func (h *PatientHandler) DeletePatient(c *gin.Context) {
 		return
 	}
 
+	// Related observations share the patient's deletion time so an undelete restores
+	// exactly those, and not observations that were deleted on their own. Links are
+	// kept until the deletion is purged.
+	var observationIDs []string
+	if err := tx.Model(&models.Observation{}).Where(dialect.Of(tx).JSONText("subject", "reference")+" = ?", "Patient/"+id).
+		Pluck("id", &observationIDs).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch related observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := tx.Model(&models.Observation{}).Where("id IN ?", observationIDs).
+		UpdateColumns(map[string]interface{}{"deleted_at": patient.DeletedAt, "deleted_by": userID}).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete related observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// The batch update bypasses the observation hooks, so publish the deletions here
+	if err := models.RecordEvents(tx, "Observation", observationIDs, models.EventActionDeleted); err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to record change events",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// Conditions, allergies and the patient's other records go with it the same way
+	if err := trashPatientRecords(tx, &patient, userID); err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete related records",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
 	if err := tx.Commit().Error; err != nil {
-		c.JSON(http.StatusInternalServerError, ErrorResponse{
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
 			Error:   "Failed to commit transaction",
 			Message: err.Error(),
 			Code:    "DATABASE_ERROR",
@@ -382,3 +621,236 @@ func (h *PatientHandler) DeletePatient(c *gin.Context) {
 
 	c.Status(http.StatusNoContent)
 }
+
+// UndeletePatient restores a deleted patient within the undo window
+// @Summary Undelete patient
+// @Description Restore a deleted patient and the observations and other records deleted with it, as long as the undo window has not passed (admin only)
+// @Tags patients
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Success 200 {object} models.Patient
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/undelete [post]
+func (h *PatientHandler) UndeletePatient(c *gin.Context) {
+	id := c.Param("id")
+
+	var patient models.Patient
+	if err := h.db.Unscoped().Where("id = ?", id).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if !patient.DeletedAt.Valid {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Patient is not deleted",
+			Code:  "PATIENT_NOT_DELETED",
+		})
+		return
+	}
+
+	if time.Since(patient.DeletedAt.Time) > h.undo {
+		respondError(c, http.StatusGone, ErrorResponse{
+			Error: "Undo window has passed",
+			Code:  "UNDO_WINDOW_EXPIRED",
+		})
+		return
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	// Observations are restored one at a time so each one publishes a change event
+	var observations []models.Observation
+	if err := tx.Unscoped().Where(dialect.Of(tx).JSONText("subject", "reference")+" = ? AND deleted_at = ?", "Patient/"+id, patient.DeletedAt).
+		Find(&observations).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch related observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	restore := map[string]interface{}{"deleted_at": nil, "deleted_by": ""}
+	for i := range observations {
+		if err := tx.Unscoped().Model(&observations[i]).Updates(restore).Error; err != nil {
+			tx.Rollback()
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to restore related observations",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	records, err := restorePatientRecords(tx, &patient)
+	if err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to restore related records",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := tx.Unscoped().Model(&patient).Updates(restore).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to restore patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to commit transaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("undelete", "Patient", userID, map[string]interface{}{
+		"patient_id":   id,
+		"observations": len(observations),
+		"records":      records,
+	})
+
+	h.respondPatient(c, http.StatusOK, &patient)
+}
+
+// respondPatient writes a patient as FHIR JSON when requested, otherwise in the API's native format
+func (h *PatientHandler) respondPatient(c *gin.Context, status int, patient *models.Patient) {
+	if wantsFHIR(c) {
+		renderFHIR(c, status, fhir.FromPatient(patient))
+		return
+	}
+	c.JSON(status, patient)
+}
+
+// verifyAddresses normalizes and geocodes addresses in place. Addresses matching an
+// already verified entry in previous are reused without calling the provider. Provider
+// failures are logged and leave the address unverified rather than rejecting the write.
+func (h *PatientHandler) verifyAddresses(ctx context.Context, addresses []models.Address, previous []models.Address) {
+	for i := range addresses {
+		address := &addresses[i]
+		address.Verified = false
+		address.Geolocation = nil
+
+		if h.geocoder == nil {
+			continue
+		}
+
+		reused := false
+		for _, prev := range previous {
+			if prev.Verified && prev.SameLocation(*address) {
+				address.Verified = true
+				address.Geolocation = prev.Geolocation
+				reused = true
+				break
+			}
+		}
+		if reused {
+			continue
+		}
+
+		result, err := h.geocoder.Verify(ctx, geocoding.Address{
+			Line:       address.Line,
+			City:       address.City,
+			State:      address.State,
+			PostalCode: address.PostalCode,
+			Country:    address.Country,
+		})
+		if err != nil {
+			if err != geocoding.ErrNoMatch {
+				logger.Warn("Address verification failed",
+					zap.String("provider", h.geocoder.Name()),
+					zap.Error(err),
+				)
+			}
+			continue
+		}
+
+		address.Line = result.Address.Line
+		address.City = result.Address.City
+		address.State = result.Address.State
+		address.PostalCode = result.Address.PostalCode
+		address.Country = result.Address.Country
+		if result.Formatted != "" {
+			address.Text = result.Formatted
+		}
+		address.Verified = true
+		address.Geolocation = &models.Geolocation{
+			Latitude:  result.Latitude,
+			Longitude: result.Longitude,
+		}
+	}
+}
+
+// carryOverContactVerification keeps the verified flag only for contacts that were already
+// verified with the same system and value; any other contact must be verified again
+func carryOverContactVerification(telecom []models.Contact, previous []models.Contact) {
+	for i := range telecom {
+		telecom[i].Verified = false
+		for _, prev := range previous {
+			if prev.Verified && prev.System == telecom[i].System && prev.Value == telecom[i].Value {
+				telecom[i].Verified = true
+				break
+			}
+		}
+	}
+}
+
+// parseNear parses a "lat,lon[,km]" geo search parameter
+func parseNear(near string) (float64, float64, float64, error) {
+	parts := strings.Split(near, ",")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, 0, 0, fmt.Errorf("expected lat,lon[,km]")
+	}
+
+	lat, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil || lat < -90 || lat > 90 {
+		return 0, 0, 0, fmt.Errorf("latitude must be between -90 and 90")
+	}
+	lon, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil || lon < -180 || lon > 180 {
+		return 0, 0, 0, fmt.Errorf("longitude must be between -180 and 180")
+	}
+
+	radius := 10.0
+	if len(parts) == 3 {
+		radius, err = strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+		if err != nil || radius <= 0 || radius > 500 {
+			return 0, 0, 0, fmt.Errorf("radius must be between 0 and 500 km")
+		}
+	}
+
+	return lat, lon, radius, nil
+}
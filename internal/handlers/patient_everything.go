@@ -0,0 +1,361 @@
+package handlers
+
+import (
+	"database/sql"
+	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/fhir"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// everythingSection is a resource type of a patient's record returned by $everything
+type everythingSection struct {
+	resourceType string
+	// records returns a pointer to an empty slice of the type's model
+	records func() interface{}
+	// query selects the records of the patient with the given reference
+	query func(tx *gorm.DB, subject string) *gorm.DB
+}
+
+// bySubjectReference selects records by their subject_reference column
+func bySubjectReference(tx *gorm.DB, subject string) *gorm.DB {
+	return tx.Where("subject_reference = ?", subject)
+}
+
+// everythingSections are the resource types of a patient's record, in the order
+// $everything returns them after the patient. A resource type that refers to patients
+// joins the operation by being listed here.
+var everythingSections = []everythingSection{
+	{"Observation", func() interface{} { return &[]models.Observation{} }, func(tx *gorm.DB, subject string) *gorm.DB {
+		return tx.Where(dialect.Of(tx).JSONText("subject", "reference")+" = ?", subject)
+	}},
+	{"Condition", func() interface{} { return &[]models.Condition{} }, bySubjectReference},
+	{"AllergyIntolerance", func() interface{} { return &[]models.AllergyIntolerance{} }, bySubjectReference},
+	{"Procedure", func() interface{} { return &[]models.Procedure{} }, bySubjectReference},
+	{"CarePlan", func() interface{} { return &[]models.CarePlan{} }, func(tx *gorm.DB, subject string) *gorm.DB {
+		return bySubjectReference(tx, subject).Preload("Activity", orderActivities)
+	}},
+	{"MedicationRequest", func() interface{} { return &[]models.MedicationRequest{} }, bySubjectReference},
+	{"MedicationAdministration", func() interface{} { return &[]models.MedicationAdministration{} }, bySubjectReference},
+	{"Specimen", func() interface{} { return &[]models.Specimen{} }, bySubjectReference},
+	{"QuestionnaireResponse", func() interface{} { return &[]models.QuestionnaireResponse{} }, bySubjectReference},
+	{"Media", func() interface{} { return &[]models.Media{} }, bySubjectReference},
+	{"DocumentReference", func() interface{} { return &[]models.DocumentReference{} }, bySubjectReference},
+	{"Coverage", func() interface{} { return &[]models.Coverage{} }, func(tx *gorm.DB, subject string) *gorm.DB {
+		return tx.Where("beneficiary_reference = ?", subject)
+	}},
+	{"RelatedPerson", func() interface{} { return &[]models.RelatedPerson{} }, func(tx *gorm.DB, subject string) *gorm.DB {
+		return tx.Where("patient_reference = ?", subject)
+	}},
+}
+
+// GetPatientEverything returns a patient's whole record as a Bundle
+// @Summary Get everything for a patient
+// @Description Return a FHIR searchset Bundle of the patient followed by every observation, condition, allergy, procedure, care plan, medication request and administration, specimen, questionnaire response, media, document reference, coverage and related person of theirs, for record transfer requests. Entries are paged across resource types in that order, oldest first within each, and the bundle links to the other pages. Replaced-by links are followed to the surviving record unless follow=false. With stream=true the whole record is sent as one Bundle, written as it is read.
+// @Tags patients
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param _type query string false "Comma-separated resource types to return besides the patient (default: all)"
+// @Param follow query bool false "Follow replaced-by links to the surviving record (default: true)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Entries per page (default: 50, max: 200)"
+// @Param stream query bool false "Stream the whole record as one Bundle instead of a page of it (default: false)"
+// @Success 200 {object} fhir.Bundle
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/$everything [get]
+func (h *PatientHandler) GetPatientEverything(c *gin.Context) {
+	id := c.Param("id")
+
+	sections := everythingSections
+	if types := strings.TrimSpace(c.Query("_type")); types != "" {
+		sections = nil
+		seen := make(map[string]bool)
+		for _, t := range strings.Split(types, ",") {
+			t = strings.TrimSpace(t)
+			if t == "" || strings.EqualFold(t, "Patient") {
+				continue
+			}
+			section, ok := everythingSectionOf(t)
+			if !ok {
+				respondError(c, http.StatusBadRequest, ErrorResponse{
+					Error:   "Invalid resource type",
+					Message: t + " is not part of a patient's record",
+					Code:    "INVALID_RESOURCE_TYPE",
+				})
+				return
+			}
+			if !seen[section.resourceType] {
+				seen[section.resourceType] = true
+				sections = append(sections, section)
+			}
+		}
+	}
+
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "50"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 200 {
+		limit = 50
+	}
+
+	stream := c.Query("stream") == "true"
+
+	if c.DefaultQuery("follow", "true") != "false" {
+		survivorID, _, err := h.resolveSurvivor(id)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to resolve patient links",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if survivorID != id {
+			c.Header("Content-Location", "/api/v1/patients/"+survivorID+"/$everything")
+			id = survivorID
+		}
+	}
+
+	if stream {
+		h.streamPatientEverything(c, id, sections)
+		return
+	}
+
+	// The total and the page are read from the same snapshot of the database, so
+	// entries are neither skipped nor repeated on a page when the record changes
+	var total int64
+	var entries []fhir.BundleEntry
+	found := true
+	err := readDB(c, h.db).Transaction(func(tx *gorm.DB) error {
+		var patient models.Patient
+		if err := tx.Where("id = ?", id).First(&patient).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				found = false
+				return nil
+			}
+			return err
+		}
+		if err := tx.Scopes(models.LiveLinks).Where("patient_id = ?", id).Order("created_at ASC").Find(&patient.Link).Error; err != nil {
+			return err
+		}
+
+		// The patient is the first entry of the first page; the page then continues
+		// through the sections in order, skipping the entries of earlier pages
+		subject := "Patient/" + id
+		offset, remaining := int64((page-1)*limit), int64(limit)
+		total = 1
+		if offset == 0 {
+			fullURL, resource, _ := bundleResource(&patient)
+			entries = append(entries, fhir.BundleEntry{FullURL: fullURL, Resource: resource})
+			remaining--
+		} else {
+			offset--
+		}
+		for _, section := range sections {
+			var count int64
+			if err := section.query(tx, subject).Model(section.records()).Count(&count).Error; err != nil {
+				return err
+			}
+			total += count
+			if offset >= count {
+				offset -= count
+				continue
+			}
+			if remaining == 0 {
+				continue
+			}
+
+			records := section.records()
+			if err := section.query(tx, subject).Order("created_at, id").
+				Offset(int(offset)).Limit(int(remaining)).Find(records).Error; err != nil {
+				return err
+			}
+			sectionEntries, _ := bundleEntries(records)
+			entries = append(entries, sectionEntries...)
+			remaining -= int64(len(sectionEntries))
+			offset = 0
+		}
+		return nil
+	}, snapshotOptions(h.db)...)
+	if !found {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Patient not found",
+			Code:  "PATIENT_NOT_FOUND",
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient record",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("export", "Patient", userID, map[string]interface{}{
+		"patient_id": id,
+		"operation":  "$everything",
+		"page":       page,
+		"entries":    len(entries),
+	})
+
+	pages := pageLinks(c, total, page, limit)
+	setPageHeaders(c, pages, total)
+	renderFHIR(c, http.StatusOK, pageBundle(entries, total, pages))
+}
+
+// everythingSectionOf returns the $everything section of a resource type
+func everythingSectionOf(resourceType string) (everythingSection, bool) {
+	for _, section := range everythingSections {
+		if strings.EqualFold(section.resourceType, resourceType) {
+			return section, true
+		}
+	}
+	return everythingSection{}, false
+}
+
+// everythingStreamBatch is how many records of a section are read at a time while
+// streaming a patient's record
+const everythingStreamBatch = 500
+
+// streamPatientEverything sends a patient's whole record as one Bundle, reading each
+// section in batches and writing every batch before reading the next. A slow client
+// holds up the reads rather than letting them pile up in memory, so a record of any
+// size is sent with one batch in memory. The record is read from one snapshot; on
+// PostgreSQL the snapshot's transaction stays open while the client reads.
+func (h *PatientHandler) streamPatientEverything(c *gin.Context, id string, sections []everythingSection) {
+	var stream *bundleStream
+	found := true
+	err := readDB(c, h.db).Transaction(func(tx *gorm.DB) error {
+		var patient models.Patient
+		if err := tx.Where("id = ?", id).First(&patient).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				found = false
+				return nil
+			}
+			return err
+		}
+		if err := tx.Scopes(models.LiveLinks).Where("patient_id = ?", id).Order("created_at ASC").Find(&patient.Link).Error; err != nil {
+			return err
+		}
+
+		subject := "Patient/" + id
+		total := int64(1)
+		for _, section := range sections {
+			var count int64
+			if err := section.query(tx, subject).Model(section.records()).Count(&count).Error; err != nil {
+				return err
+			}
+			total += count
+		}
+
+		userID, _ := auth.GetUserID(c)
+		logger.LogAuditEvent("export", "Patient", userID, map[string]interface{}{
+			"patient_id": id,
+			"operation":  "$everything",
+			"stream":     true,
+			"entries":    total,
+		})
+
+		// Nothing is written before this point, so errors until here are answered
+		// as usual; after it they can only cut the Bundle short
+		c.Header("X-Total-Count", strconv.FormatInt(total, 10))
+		stream = startBundleStream(c, total)
+		fullURL, resource, _ := bundleResource(&patient)
+		if err := stream.write([]fhir.BundleEntry{{FullURL: fullURL, Resource: resource}}); err != nil {
+			return err
+		}
+
+		for _, section := range sections {
+			// Batches continue after the last record of the previous one, in the order
+			// of the paged operation
+			var lastCreatedAt time.Time
+			var lastID string
+			for {
+				query := section.query(tx, subject)
+				if lastID != "" {
+					query = query.Where("created_at > ? OR (created_at = ? AND id > ?)", lastCreatedAt, lastCreatedAt, lastID)
+				}
+				records := section.records()
+				if err := query.Order("created_at, id").Limit(everythingStreamBatch).Find(records).Error; err != nil {
+					return err
+				}
+				entries, _ := bundleEntries(records)
+				if len(entries) == 0 {
+					break
+				}
+				if err := stream.write(entries); err != nil {
+					return err
+				}
+				if len(entries) < everythingStreamBatch {
+					break
+				}
+				lastCreatedAt, lastID = lastRecordKey(records)
+			}
+		}
+		return nil
+	}, snapshotOptions(h.db)...)
+
+	if stream == nil {
+		if !found {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient record",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if err != nil {
+		logger.Error("Streaming patient record failed",
+			zap.String("patient_id", id),
+			zap.Int64("written", stream.written),
+			zap.Error(err),
+		)
+		return
+	}
+	if err := stream.finish(); err != nil {
+		logger.Warn("Failed to finish patient record stream", zap.String("patient_id", id), zap.Error(err))
+	}
+}
+
+// lastRecordKey returns the created_at and id of the last record of a slice of models
+func lastRecordKey(records interface{}) (time.Time, string) {
+	value := reflect.Indirect(reflect.ValueOf(records))
+	last := reflect.Indirect(value.Index(value.Len() - 1))
+	createdAt, _ := last.FieldByName("CreatedAt").Interface().(time.Time)
+	return createdAt, last.FieldByName("ID").String()
+}
+
+// snapshotOptions returns the options of a read-only transaction that reads one
+// snapshot of the database, where the database supports them
+func snapshotOptions(db *gorm.DB) []*sql.TxOptions {
+	if dialect.IsPostgres(db) {
+		return []*sql.TxOptions{{Isolation: sql.LevelRepeatableRead, ReadOnly: true}}
+	}
+	return nil
+}
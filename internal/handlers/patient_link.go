@@ -0,0 +1,327 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+)
+
+// maxLinkDepth bounds how many replaced-by links are followed when resolving a patient
+const maxLinkDepth = 10
+
+// LinkPatient links a patient record to another record
+// @Summary Link patient records
+// @Description Record that a patient is replaced by, replaces, refers to or should be seen alongside another record. Replaced records are deactivated.
+// @Tags patients
+// @Accept json
+// @Produce json
+// @Param patientId path string true "Patient ID"
+// @Param link body models.PatientLinkRequest true "Link data"
+// @Success 201 {object} models.PatientLink
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{patientId}/links [post]
+func (h *PatientHandler) LinkPatient(c *gin.Context) {
+	patientID := c.Param("id")
+
+	var req models.PatientLinkRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	otherID := strings.TrimPrefix(req.Other, "Patient/")
+	if otherID == patientID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "A patient cannot be linked to itself",
+			Code:  "INVALID_PATIENT_LINK",
+		})
+		return
+	}
+
+	// Both records must exist
+	for _, id := range []string{patientID, otherID} {
+		var patient models.Patient
+		if err := h.db.Select("id").Where("id = ?", id).First(&patient).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				respondError(c, http.StatusNotFound, ErrorResponse{
+					Error:   "Patient not found",
+					Message: "Patient/" + id,
+					Code:    "PATIENT_NOT_FOUND",
+				})
+				return
+			}
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to fetch patient",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	// Normalize "replaces" so the replaced record is always the link owner
+	replacedID, survivorID := "", ""
+	switch req.Type {
+	case models.PatientLinkReplacedBy:
+		replacedID, survivorID = patientID, otherID
+	case models.PatientLinkReplaces:
+		replacedID, survivorID = otherID, patientID
+	}
+
+	if replacedID != "" {
+		var existing int64
+		if err := h.db.Model(&models.PatientLink{}).
+			Where("patient_id = ? AND type = ?", replacedID, models.PatientLinkReplacedBy).
+			Count(&existing).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to check existing links",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if existing > 0 {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error:   "Patient is already replaced by another record",
+				Message: "Patient/" + replacedID,
+				Code:    "PATIENT_ALREADY_REPLACED",
+			})
+			return
+		}
+
+		// The survivor must not itself resolve back to the replaced record
+		resolved, _, err := h.resolveSurvivor(survivorID)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to resolve patient links",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if resolved == replacedID {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error: "Link would create a replacement cycle",
+				Code:  "PATIENT_LINK_CYCLE",
+			})
+			return
+		}
+	}
+
+	createdBy, _ := auth.GetUserID(c)
+	link := models.PatientLink{
+		PatientID: patientID,
+		OtherID:   otherID,
+		Type:      req.Type,
+		CreatedBy: createdBy,
+	}
+	inverse := models.PatientLink{
+		PatientID: otherID,
+		OtherID:   patientID,
+		Type:      models.InversePatientLinkType(req.Type),
+		CreatedBy: createdBy,
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	for _, l := range []*models.PatientLink{&link, &inverse} {
+		if err := tx.Create(l).Error; err != nil {
+			tx.Rollback()
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error:   "Failed to create patient link",
+				Message: err.Error(),
+				Code:    "PATIENT_LINK_EXISTS",
+			})
+			return
+		}
+	}
+
+	// Replaced records stay readable through their links but are no longer active
+	if replacedID != "" {
+		if err := tx.Model(&models.Patient{ID: replacedID}).Update("active", false).Error; err != nil {
+			tx.Rollback()
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to deactivate replaced patient",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to commit transaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	link.Other = models.Reference{Reference: "Patient/" + otherID, Type: "Patient"}
+	c.JSON(http.StatusCreated, link)
+}
+
+// GetPatientLinks retrieves the links recorded for a patient
+// @Summary Get patient links
+// @Description Get all links from a patient record to other records
+// @Tags patients
+// @Produce json
+// @Param patientId path string true "Patient ID"
+// @Success 200 {array} models.PatientLink
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{patientId}/links [get]
+func (h *PatientHandler) GetPatientLinks(c *gin.Context) {
+	var links []models.PatientLink
+	if err := h.db.Scopes(models.LiveLinks).Where("patient_id = ?", c.Param("id")).Order("created_at ASC").Find(&links).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient links",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, links)
+}
+
+// UnlinkPatient removes a link and its reciprocal
+// @Summary Unlink patient records
+// @Description Remove a link between two patient records. Removing a replaced-by link reactivates the replaced record.
+// @Tags patients
+// @Produce json
+// @Param patientId path string true "Patient ID"
+// @Param linkId path string true "Link ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{patientId}/links/{linkId} [delete]
+func (h *PatientHandler) UnlinkPatient(c *gin.Context) {
+	patientID := c.Param("id")
+
+	var link models.PatientLink
+	if err := h.db.Where("id = ? AND patient_id = ?", c.Param("linkId"), patientID).First(&link).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Patient link not found",
+				Code:  "PATIENT_LINK_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient link",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Where("(patient_id = ? AND other_id = ?) OR (patient_id = ? AND other_id = ?)",
+		link.PatientID, link.OtherID, link.OtherID, link.PatientID).Delete(&models.PatientLink{}).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete patient link",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	replacedID := ""
+	switch link.Type {
+	case models.PatientLinkReplacedBy:
+		replacedID = link.PatientID
+	case models.PatientLinkReplaces:
+		replacedID = link.OtherID
+	}
+	if replacedID != "" {
+		if err := tx.Model(&models.Patient{ID: replacedID}).Update("active", true).Error; err != nil {
+			tx.Rollback()
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to reactivate patient",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to commit transaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
+
+// resolveSurvivor follows replaced-by links from a patient to the surviving record.
+// It returns the surviving ID and the chain of replaced IDs that were followed.
+func (h *PatientHandler) resolveSurvivor(id string) (string, []string, error) {
+	var followed []string
+	seen := map[string]bool{id: true}
+
+	for depth := 0; depth < maxLinkDepth; depth++ {
+		var link models.PatientLink
+		err := h.db.Scopes(models.LiveLinks).Where("patient_id = ? AND type = ?", id, models.PatientLinkReplacedBy).First(&link).Error
+		if err == gorm.ErrRecordNotFound {
+			break
+		}
+		if err != nil {
+			return "", nil, err
+		}
+		if seen[link.OtherID] {
+			break
+		}
+
+		followed = append(followed, id)
+		seen[link.OtherID] = true
+		id = link.OtherID
+	}
+
+	return id, followed, nil
+}
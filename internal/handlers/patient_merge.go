@@ -0,0 +1,220 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// errMergeConflict aborts a merge whose patients changed since they were checked
+var errMergeConflict = errors.New("patient modified during merge")
+
+// MergePatient merges a duplicate patient into the record that survives it
+// @Summary Merge patient records
+// @Description Merge a duplicate patient into a surviving record in one transaction: the subject of every observation of the duplicate, including those in the trash, is re-pointed to the survivor, the duplicate is linked as replaced-by the survivor and deactivated, and the merge is recorded. Other records of the duplicate are re-pointed by the next integrity check (admin only).
+// @Tags patients
+// @Accept json
+// @Produce json
+// @Param id path string true "Duplicate patient ID"
+// @Param merge body models.PatientMergeRequest true "Merge data"
+// @Success 200 {object} models.PatientMerge
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/merge [post]
+func (h *PatientHandler) MergePatient(c *gin.Context) {
+	duplicateID := c.Param("id")
+
+	var req models.PatientMergeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	survivorID := strings.TrimPrefix(req.Survivor, "Patient/")
+	if survivorID == duplicateID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "A patient cannot be merged into itself",
+			Code:  "INVALID_PATIENT_MERGE",
+		})
+		return
+	}
+
+	db := writeDB(c, h.db)
+	var duplicate, survivor models.Patient
+	for _, p := range []struct {
+		id      string
+		patient *models.Patient
+	}{{duplicateID, &duplicate}, {survivorID, &survivor}} {
+		if err := db.Select("id", "active", "updated_at").Where("id = ?", p.id).First(p.patient).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				respondError(c, http.StatusNotFound, ErrorResponse{
+					Error:   "Patient not found",
+					Message: "Patient/" + p.id,
+					Code:    "PATIENT_NOT_FOUND",
+				})
+				return
+			}
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to fetch patient",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	// The duplicate must not already be replaced, and the survivor must be the end of
+	// its own replacement chain so references are not re-pointed to a replaced record
+	var replaced int64
+	if err := db.Model(&models.PatientLink{}).Scopes(models.LiveLinks).
+		Where("patient_id = ? AND type = ?", duplicateID, models.PatientLinkReplacedBy).
+		Count(&replaced).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check existing links",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if replaced > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Patient is already replaced by another record",
+			Message: "Patient/" + duplicateID,
+			Code:    "PATIENT_ALREADY_REPLACED",
+		})
+		return
+	}
+	resolved, _, err := h.resolveSurvivor(survivorID)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to resolve patient links",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if resolved != survivorID {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Survivor is replaced by another record",
+			Message: "Patient/" + survivorID + " is replaced by Patient/" + resolved + "; merge into that record instead",
+			Code:    "SURVIVOR_REPLACED",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	merge := models.PatientMerge{
+		PatientID:      duplicateID,
+		SurvivorID:     survivorID,
+		Reason:         req.Reason,
+		ObservationIDs: []string{},
+		CreatedBy:      userID,
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		// Both records are touched only if unchanged since they were checked, so of
+		// concurrent merges of either the first wins
+		now := time.Now()
+		for _, p := range []struct {
+			patient *models.Patient
+			values  map[string]interface{}
+		}{
+			{&duplicate, map[string]interface{}{"active": false, "updated_at": now}},
+			{&survivor, map[string]interface{}{"updated_at": now}},
+		} {
+			result := tx.Model(&models.Patient{}).Where("id = ? AND updated_at = ?", p.patient.ID, p.patient.UpdatedAt).
+				Updates(p.values)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errMergeConflict
+			}
+		}
+
+		// Observations in the trash move too, so restoring one does not leave it on
+		// the duplicate. They are saved one at a time so their hooks publish the change.
+		var observations []models.Observation
+		if err := tx.Unscoped().Where(dialect.Of(tx).JSONText("subject", "reference")+" = ?", "Patient/"+duplicateID).
+			Find(&observations).Error; err != nil {
+			return err
+		}
+		for i := range observations {
+			subject := observations[i].Subject
+			subject.Reference = "Patient/" + survivorID
+			if err := tx.Unscoped().Model(&observations[i]).Select("subject", "updated_at").
+				Updates(&models.Observation{Subject: subject, UpdatedAt: now}).Error; err != nil {
+				return err
+			}
+			merge.ObservationIDs = append(merge.ObservationIDs, observations[i].ID)
+		}
+
+		// Links already recorded between the records, e.g. seealso, give way to the
+		// replacement
+		if err := tx.Where("(patient_id = ? AND other_id = ?) OR (patient_id = ? AND other_id = ?)",
+			duplicateID, survivorID, survivorID, duplicateID).Delete(&models.PatientLink{}).Error; err != nil {
+			return err
+		}
+		for _, link := range []*models.PatientLink{
+			{PatientID: duplicateID, OtherID: survivorID, Type: models.PatientLinkReplacedBy, CreatedBy: userID},
+			{PatientID: survivorID, OtherID: duplicateID, Type: models.PatientLinkReplaces, CreatedBy: userID},
+		} {
+			if err := tx.Create(link).Error; err != nil {
+				return err
+			}
+		}
+
+		return tx.Create(&merge).Error
+	})
+	if err == errMergeConflict {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Patient was modified by another request",
+			Message: "retry the merge",
+			Code:    "VERSION_CONFLICT",
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to merge patients",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("merge", "Patient", userID, map[string]interface{}{
+		"merge_id":     merge.ID,
+		"patient_id":   duplicateID,
+		"survivor_id":  survivorID,
+		"observations": len(merge.ObservationIDs),
+	})
+
+	merge.Patient = models.Reference{Reference: "Patient/" + duplicateID, Type: "Patient"}
+	merge.Survivor = models.Reference{Reference: "Patient/" + survivorID, Type: "Patient"}
+	c.JSON(http.StatusOK, merge)
+}
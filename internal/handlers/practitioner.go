@@ -0,0 +1,465 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// PractitionerHandler handles HTTP requests for practitioners
+type PractitionerHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewPractitionerHandler creates a new practitioner handler
+func NewPractitionerHandler(db *gorm.DB) *PractitionerHandler {
+	return &PractitionerHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// CreatePractitioner records a practitioner
+// @Summary Create practitioner
+// @Description Record a practitioner with their NPI, qualifications, specialties and contact details, optionally linked to the user account they sign in with. An NPI must have a valid check digit; NPIs and user accounts belong to one practitioner each (admin only).
+// @Tags practitioners
+// @Accept json
+// @Produce json
+// @Param practitioner body models.PractitionerRequest true "Practitioner"
+// @Success 201 {object} models.Practitioner
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/practitioners [post]
+func (h *PractitionerHandler) CreatePractitioner(c *gin.Context) {
+	var req models.PractitionerRequest
+	if !h.bindPractitioner(c, &req) {
+		return
+	}
+
+	practitioner := models.Practitioner{Active: true}
+	if !h.apply(c, &practitioner, req) {
+		return
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		practitioner.CreatedBy = userID
+	}
+
+	if err := h.db.Create(&practitioner).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create practitioner",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "Practitioner", practitioner.CreatedBy, map[string]interface{}{
+		"practitioner_id": practitioner.ID,
+		"linked_user_id":  practitioner.UserID,
+	})
+
+	c.JSON(http.StatusCreated, practitioner)
+}
+
+// GetPractitioners lists practitioners
+// @Summary Get practitioners
+// @Description List practitioners by name, NPI, specialty, linked user or active status
+// @Tags practitioners
+// @Produce json
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Param search query string false "Search by name"
+// @Param npi query string false "Filter by NPI"
+// @Param specialty query string false "Filter by specialty code or display"
+// @Param user query string false "Filter by linked user ID"
+// @Param active query bool false "Filter by active status"
+// @Success 200 {object} PaginatedResponse{data=[]models.Practitioner}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/practitioners [get]
+func (h *PractitionerHandler) GetPractitioners(c *gin.Context) {
+	page, limit := capturePage(c)
+	db := readDB(c, h.db)
+	d := dialect.Of(db)
+	query := db.Model(&models.Practitioner{})
+
+	search, specialty := strings.TrimSpace(c.Query("search")), strings.TrimSpace(c.Query("specialty"))
+	for name, value := range map[string]string{"search": search, "specialty": specialty} {
+		if err := dialect.CheckFilterText(name, value); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid filter",
+				Message: err.Error(),
+				Code:    "INVALID_FILTER",
+			})
+			return
+		}
+	}
+
+	if search != "" {
+		query = query.Where(d.ILike(d.JSONString("name")), dialect.Contains(search))
+	}
+	if npi := strings.TrimSpace(c.Query("npi")); npi != "" {
+		query = query.Where("npi = ?", npi)
+	}
+	if specialty != "" {
+		query = query.Where(d.ILike(d.JSONString("specialty")), dialect.Contains(specialty))
+	}
+	if user := strings.TrimSpace(c.Query("user")); user != "" {
+		query = query.Where("user_id = ?", user)
+	}
+	if active, err := strconv.ParseBool(c.Query("active")); err == nil {
+		query = query.Where("active = ?", active)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count practitioners",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	practitioners := []models.Practitioner{}
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&practitioners).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch practitioners",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, practitioners, total, page, limit)
+}
+
+// GetPractitioner retrieves a practitioner by ID
+// @Summary Get practitioner
+// @Description Get a practitioner by their ID
+// @Tags practitioners
+// @Produce json
+// @Param id path string true "Practitioner ID"
+// @Success 200 {object} models.Practitioner
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/practitioners/{id} [get]
+func (h *PractitionerHandler) GetPractitioner(c *gin.Context) {
+	var practitioner models.Practitioner
+	if !findPractitioner(c, readDB(c, h.db), &practitioner) {
+		return
+	}
+
+	c.JSON(http.StatusOK, practitioner)
+}
+
+// UpdatePractitioner replaces a practitioner
+// @Summary Update practitioner
+// @Description Replace the NPI, name, qualifications, specialties, contact details and linked user of a practitioner; active is kept when left out. Performer references to the practitioner keep their display name (admin only).
+// @Tags practitioners
+// @Accept json
+// @Produce json
+// @Param id path string true "Practitioner ID"
+// @Param practitioner body models.PractitionerRequest true "Practitioner"
+// @Success 200 {object} models.Practitioner
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/practitioners/{id} [put]
+func (h *PractitionerHandler) UpdatePractitioner(c *gin.Context) {
+	var req models.PractitionerRequest
+	if !h.bindPractitioner(c, &req) {
+		return
+	}
+
+	var practitioner models.Practitioner
+	if !findPractitioner(c, h.db, &practitioner) {
+		return
+	}
+	if !h.apply(c, &practitioner, req) {
+		return
+	}
+
+	if err := h.db.Model(&practitioner).Select("active", "npi", "name", "qualification", "specialty", "telecom",
+		"address", "user_id", "updated_at").Updates(&practitioner).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update practitioner",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "Practitioner", userID, map[string]interface{}{
+		"practitioner_id": practitioner.ID,
+		"active":          practitioner.Active,
+		"linked_user_id":  practitioner.UserID,
+	})
+
+	c.JSON(http.StatusOK, practitioner)
+}
+
+// DeletePractitioner removes a practitioner
+// @Summary Delete practitioner
+// @Description Delete a practitioner recorded by mistake. A practitioner observations or procedures name as their performer, or specimens as their collector, is kept for them; deactivate the practitioner instead (admin only).
+// @Tags practitioners
+// @Param id path string true "Practitioner ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/practitioners/{id} [delete]
+func (h *PractitionerHandler) DeletePractitioner(c *gin.Context) {
+	var practitioner models.Practitioner
+	if !findPractitioner(c, h.db, &practitioner) {
+		return
+	}
+
+	// Observations pending deletion count too, since undeleting one restores its performers
+	pattern := `%"Practitioner/` + dialect.EscapeLike(practitioner.ID) + `"%`
+	var count int64
+	for _, model := range []interface{}{&models.Observation{}, &models.Procedure{}} {
+		var referencing int64
+		if err := h.db.Unscoped().Model(model).Where("performer LIKE ?", pattern).Count(&referencing).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to check practitioner references",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		count += referencing
+	}
+	var collected int64
+	if err := h.db.Model(&models.Specimen{}).Where("collector LIKE ?", pattern).Count(&collected).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check practitioner references",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	count += collected
+	if count > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Practitioner is referenced by other records",
+			Message: "deactivate the practitioner instead",
+			Code:    "PRACTITIONER_IN_USE",
+		})
+		return
+	}
+
+	if err := h.db.Delete(&practitioner).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete practitioner",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "Practitioner", userID, map[string]interface{}{
+		"practitioner_id": practitioner.ID,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindPractitioner binds and validates a practitioner request, writing the error
+// response on failure
+func (h *PractitionerHandler) bindPractitioner(c *gin.Context, req *models.PractitionerRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	if req.NPI != "" && !models.ValidNPI(req.NPI) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid NPI",
+			Message: "the NPI's check digit does not match",
+			Code:    "INVALID_NPI",
+		})
+		return false
+	}
+	return true
+}
+
+// apply sets the fields of a request on a practitioner, checking that its NPI and
+// linked user are not another practitioner's and writing the error response on failure
+func (h *PractitionerHandler) apply(c *gin.Context, practitioner *models.Practitioner, req models.PractitionerRequest) bool {
+	if req.UserID != "" {
+		var user models.User
+		if err := h.db.Select("id").Where("id = ?", req.UserID).First(&user).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				respondError(c, http.StatusBadRequest, ErrorResponse{
+					Error: "Linked user not found",
+					Code:  "USER_NOT_FOUND",
+				})
+				return false
+			}
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to validate linked user",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return false
+		}
+	}
+
+	for _, check := range []struct{ column, value, code, message string }{
+		{"npi", req.NPI, "NPI_EXISTS", "A practitioner with the NPI already exists"},
+		{"user_id", req.UserID, "USER_ALREADY_LINKED", "The user is already linked to another practitioner"},
+	} {
+		if check.value == "" {
+			continue
+		}
+		var count int64
+		if err := h.db.Model(&models.Practitioner{}).Where(check.column+" = ? AND id <> ?", check.value, practitioner.ID).
+			Count(&count).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to check practitioner",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return false
+		}
+		if count > 0 {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error: check.message,
+				Code:  check.code,
+			})
+			return false
+		}
+	}
+
+	if req.Active != nil {
+		practitioner.Active = *req.Active
+	}
+	practitioner.NPI = optionalString(req.NPI)
+	practitioner.Name = req.Name
+	practitioner.Qualification = req.Qualification
+	practitioner.Specialty = req.Specialty
+	practitioner.Telecom = req.Telecom
+	practitioner.Address = req.Address
+	practitioner.UserID = optionalString(req.UserID)
+	return true
+}
+
+// optionalString returns nil for an empty string, for unique columns that may be unset
+func optionalString(s string) *string {
+	if s == "" {
+		return nil
+	}
+	return &s
+}
+
+// resolvePerformers checks that every performer referencing a practitioner names an
+// existing one and returns the performers with practitioner references normalized to
+// Practitioner/{id} and displayed by name. A User/{id} reference to a user linked to a
+// practitioner is replaced by the practitioner; other references, such as
+// organizations, are kept as given. It writes an error response on failure.
+func resolvePerformers(c *gin.Context, db *gorm.DB, refs []models.Reference) ([]models.Reference, bool) {
+	resolved := make([]models.Reference, 0, len(refs))
+	for _, ref := range refs {
+		var practitioner models.Practitioner
+		var query *gorm.DB
+		switch {
+		case ref.Type == "Practitioner" || strings.HasPrefix(ref.Reference, "Practitioner/"):
+			query = db.Where("id = ?", strings.TrimPrefix(ref.Reference, "Practitioner/"))
+		case ref.Type == "User" || strings.HasPrefix(ref.Reference, "User/"):
+			query = db.Where("user_id = ?", strings.TrimPrefix(ref.Reference, "User/"))
+		default:
+			resolved = append(resolved, ref)
+			continue
+		}
+
+		if err := query.First(&practitioner).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				respondError(c, http.StatusInternalServerError, ErrorResponse{
+					Error:   "Failed to validate performer references",
+					Message: err.Error(),
+					Code:    "DATABASE_ERROR",
+				})
+				return nil, false
+			}
+			if strings.HasPrefix(ref.Reference, "User/") {
+				// Users without a practitioner record stay referenced as users
+				resolved = append(resolved, ref)
+				continue
+			}
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Referenced practitioner not found",
+				Message: ref.Reference + " does not exist",
+				Code:    "PRACTITIONER_NOT_FOUND",
+			})
+			return nil, false
+		}
+
+		resolved = append(resolved, models.Reference{
+			Reference: "Practitioner/" + practitioner.ID,
+			Type:      "Practitioner",
+			Display:   practitioner.GetFullName(),
+		})
+	}
+	return resolved, true
+}
+
+// findPractitioner loads the practitioner in the path, writing the error response when
+// it is not found
+func findPractitioner(c *gin.Context, db *gorm.DB, practitioner *models.Practitioner) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(practitioner).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Practitioner not found",
+				Code:  "PRACTITIONER_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch practitioner",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
@@ -0,0 +1,37 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/loadshed"
+)
+
+// PriorityHandler reports the load of request priority classes
+type PriorityHandler struct {
+	limiter *loadshed.Limiter
+}
+
+// NewPriorityHandler creates a new priority handler
+func NewPriorityHandler(limiter *loadshed.Limiter) *PriorityHandler {
+	return &PriorityHandler{limiter: limiter}
+}
+
+// PriorityClassesResponse lists the load of request priority classes
+type PriorityClassesResponse struct {
+	Data []loadshed.ClassStatus `json:"data"`
+}
+
+// GetPriorityClasses lists the load of every priority class
+// @Summary Get priority classes
+// @Description List the request priority classes of this instance, highest priority first, with their concurrency limit, requests in flight, whether they are saturated and how many of their requests were shed with 503 since the instance started. Lower classes are shed while a higher one is saturated (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} PriorityClassesResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/priority-classes [get]
+func (h *PriorityHandler) GetPriorityClasses(c *gin.Context) {
+	c.JSON(http.StatusOK, PriorityClassesResponse{Data: h.limiter.Statuses()})
+}
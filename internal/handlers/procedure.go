@@ -0,0 +1,391 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// ProcedureHandler handles HTTP requests for patient procedures
+type ProcedureHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	undo      time.Duration
+}
+
+// NewProcedureHandler creates a new procedure handler. Deleted procedures can be
+// restored until the undo window has passed.
+func NewProcedureHandler(db *gorm.DB, undoWindow time.Duration) *ProcedureHandler {
+	return &ProcedureHandler{
+		db:        db,
+		validator: validator.New(),
+		undo:      undoWindow,
+	}
+}
+
+// CreateProcedure records a procedure
+// @Summary Create procedure
+// @Description Record a procedure performed on a patient, completed unless another status is given. Practitioner performers must exist and are stored with their name; a performer naming a user linked to a practitioner is recorded as the practitioner.
+// @Tags procedures
+// @Accept json
+// @Produce json
+// @Param procedure body models.ProcedureRequest true "Procedure"
+// @Success 201 {object} models.Procedure
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/procedures [post]
+func (h *ProcedureHandler) CreateProcedure(c *gin.Context) {
+	var req models.ProcedureRequest
+	if !h.bindProcedure(c, &req) {
+		return
+	}
+
+	h.create(c, strings.TrimPrefix(req.Subject.Reference, "Patient/"), req)
+}
+
+// CreatePatientProcedure records a procedure of the patient in the path
+// @Summary Create patient procedure
+// @Description Record a procedure performed on the patient in the path, as POST /procedures does. The subject may be left out; one naming another patient is rejected.
+// @Tags procedures
+// @Accept json
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param procedure body models.ProcedureRequest true "Procedure"
+// @Success 201 {object} models.Procedure
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/procedures [post]
+func (h *ProcedureHandler) CreatePatientProcedure(c *gin.Context) {
+	var req models.ProcedureRequest
+	if !h.bindProcedure(c, &req) {
+		return
+	}
+
+	patientID := c.Param("id")
+	if req.Subject.Reference != "" && strings.TrimPrefix(req.Subject.Reference, "Patient/") != patientID {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "subject must be the patient in the path",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	h.create(c, patientID, req)
+}
+
+// GetProcedure retrieves a procedure by ID
+// @Summary Get procedure
+// @Description Get a procedure by its ID
+// @Tags procedures
+// @Produce json
+// @Param id path string true "Procedure ID"
+// @Success 200 {object} models.Procedure
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/procedures/{id} [get]
+func (h *ProcedureHandler) GetProcedure(c *gin.Context) {
+	var procedure models.Procedure
+	if !findProcedure(c, readDB(c, h.db), &procedure) {
+		return
+	}
+
+	c.JSON(http.StatusOK, procedure)
+}
+
+// UpdateProcedure replaces a procedure
+// @Summary Update procedure
+// @Description Replace the code, performed time, performers, outcome, body sites and note of a procedure; the status is kept when left out. The subject cannot change. Mark a procedure recorded for the wrong patient entered-in-error.
+// @Tags procedures
+// @Accept json
+// @Produce json
+// @Param id path string true "Procedure ID"
+// @Param procedure body models.ProcedureRequest true "Procedure"
+// @Success 200 {object} models.Procedure
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/procedures/{id} [put]
+func (h *ProcedureHandler) UpdateProcedure(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.ProcedureRequest
+	if !h.bindProcedure(c, &req) {
+		return
+	}
+
+	var procedure models.Procedure
+	if !findProcedure(c, db, &procedure) {
+		return
+	}
+
+	if req.Subject.Reference != "" && strings.TrimPrefix(req.Subject.Reference, "Patient/") != strings.TrimPrefix(procedure.Subject.Reference, "Patient/") {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "the subject of a procedure cannot change",
+			Code:    "SUBJECT_MISMATCH",
+		})
+		return
+	}
+
+	performers, ok := resolvePerformers(c, db, req.Performer)
+	if !ok {
+		return
+	}
+
+	if req.Status != "" {
+		procedure.Status = req.Status
+	}
+	procedure.Code = req.Code
+	procedure.PerformedAt = req.PerformedAt
+	procedure.Performer = performers
+	procedure.Outcome = req.Outcome
+	procedure.BodySite = req.BodySite
+	procedure.Note = req.Note
+
+	if err := db.Model(&procedure).Select("status", "code", "performed_at", "performer", "outcome", "body_site",
+		"note", "updated_at").Updates(&procedure).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update procedure",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "Procedure", userID, map[string]interface{}{
+		"procedure_id": procedure.ID,
+		"status":       procedure.Status,
+	})
+
+	c.JSON(http.StatusOK, procedure)
+}
+
+// DeleteProcedure removes a procedure
+// @Summary Delete procedure
+// @Description Delete a procedure recorded by mistake (admin only). Prefer marking it entered-in-error so the correction stays on record. The deletion can be undone until the undo window passes.
+// @Tags procedures
+// @Param id path string true "Procedure ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/procedures/{id} [delete]
+func (h *ProcedureHandler) DeleteProcedure(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var procedure models.Procedure
+	if !findProcedure(c, db, &procedure) {
+		return
+	}
+
+	// Mark the procedure as pending deletion; the retention job removes it after the undo window
+	if err := trashRecord(c, db, &procedure); err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete procedure",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "Procedure", userID, map[string]interface{}{
+		"procedure_id": procedure.ID,
+		"patient_id":   strings.TrimPrefix(procedure.Subject.Reference, "Patient/"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// UndeleteProcedure restores a deleted procedure within the undo window
+// @Summary Undelete procedure
+// @Description Restore a deleted procedure as long as the undo window has not passed. Procedures deleted with their patient are restored by undeleting the patient (admin only).
+// @Tags procedures
+// @Produce json
+// @Param id path string true "Procedure ID"
+// @Success 200 {object} models.Procedure
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/procedures/{id}/undelete [post]
+func (h *ProcedureHandler) UndeleteProcedure(c *gin.Context) {
+	var procedure models.Procedure
+	if !undeleteRecord(c, writeDB(c, h.db), "Procedure", &procedure, h.undo, ErrorResponse{
+		Error: "Procedure not found",
+		Code:  "PROCEDURE_NOT_FOUND",
+	}) {
+		return
+	}
+
+	c.JSON(http.StatusOK, procedure)
+}
+
+// GetPatientProcedures lists the procedures of a patient
+// @Summary Get patient procedures
+// @Description List the procedures of a patient, most recently performed first; procedures without a performed time count as performed when recorded. Procedures entered in error are left out unless all is set or they are asked for by status.
+// @Tags procedures
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param status query string false "Filter by status"
+// @Param all query bool false "Include procedures entered in error"
+// @Success 200 {array} models.Procedure
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/procedures [get]
+func (h *ProcedureHandler) GetPatientProcedures(c *gin.Context) {
+	query := readDB(c, h.db).Where("subject_reference = ?", "Patient/"+c.Param("id"))
+	if status := strings.TrimSpace(c.Query("status")); status != "" {
+		query = query.Where("status = ?", status)
+	} else if c.Query("all") != "true" {
+		query = query.Where("status <> ?", models.ProcedureEnteredInError)
+	}
+
+	procedures := []models.Procedure{}
+	if err := query.Order("COALESCE(performed_at, created_at) DESC").Find(&procedures).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch procedures",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, procedures)
+}
+
+// bindProcedure binds and validates a procedure request, writing the error response
+// on failure
+func (h *ProcedureHandler) bindProcedure(c *gin.Context, req *models.ProcedureRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	if len(req.Code.Coding) == 0 && strings.TrimSpace(req.Code.Text) == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "code must have a coding or text naming the procedure",
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	return true
+}
+
+// create records the procedure of a request for a patient
+func (h *ProcedureHandler) create(c *gin.Context, patientID string, req models.ProcedureRequest) {
+	db := writeDB(c, h.db)
+
+	// Validate that the referenced patient exists
+	var patient models.Patient
+	if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	performers, ok := resolvePerformers(c, db, req.Performer)
+	if !ok {
+		return
+	}
+
+	procedure := models.Procedure{
+		Subject:     models.Reference{Reference: "Patient/" + patientID, Display: req.Subject.Display},
+		Status:      req.Status,
+		Code:        req.Code,
+		PerformedAt: req.PerformedAt,
+		Performer:   performers,
+		Outcome:     req.Outcome,
+		BodySite:    req.BodySite,
+		Note:        req.Note,
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		procedure.CreatedBy = userID
+	}
+	if err := db.Create(&procedure).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create procedure",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "Procedure", procedure.CreatedBy, map[string]interface{}{
+		"procedure_id": procedure.ID,
+		"patient_id":   patientID,
+		"status":       procedure.Status,
+	})
+
+	c.JSON(http.StatusCreated, procedure)
+}
+
+// findProcedure loads the procedure in the path, writing the error response when it
+// is not found
+func findProcedure(c *gin.Context, db *gorm.DB, procedure *models.Procedure) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(procedure).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Procedure not found",
+				Code:  "PROCEDURE_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch procedure",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
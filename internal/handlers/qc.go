@@ -0,0 +1,346 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/qc"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// QCHandler handles HTTP requests for laboratory quality control
+type QCHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	recorder  *qc.Recorder
+}
+
+// NewQCHandler creates a new QC handler
+func NewQCHandler(db *gorm.DB, recorder *qc.Recorder) *QCHandler {
+	return &QCHandler{
+		db:        db,
+		validator: validator.New(),
+		recorder:  recorder,
+	}
+}
+
+// LeveyJenningsLimits are the control limits drawn on a Levey-Jennings chart
+type LeveyJenningsLimits struct {
+	Lower3SD float64 `json:"lower3sd"`
+	Lower2SD float64 `json:"lower2sd"`
+	Lower1SD float64 `json:"lower1sd"`
+	Upper1SD float64 `json:"upper1sd"`
+	Upper2SD float64 `json:"upper2sd"`
+	Upper3SD float64 `json:"upper3sd"`
+}
+
+// LeveyJenningsSeries is the chartable series of one control material. Mean, SD and
+// limits are absent while the control's statistics are being established.
+type LeveyJenningsSeries struct {
+	Control models.QCControl     `json:"control"`
+	Mean    *float64             `json:"mean,omitempty"`
+	SD      *float64             `json:"sd,omitempty"`
+	Limits  *LeveyJenningsLimits `json:"limits,omitempty"`
+	Points  []models.QCResult    `json:"points"`
+}
+
+// CreateControl registers a control material
+// @Summary Create QC control
+// @Description Register a control material: one level of a lot measured for a test on an instrument. Without an assigned mean and SD they are established from the first baselineSize results (default 20).
+// @Tags qc
+// @Accept json
+// @Produce json
+// @Param control body models.QCControlRequest true "Control data"
+// @Success 201 {object} models.QCControl
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/qc/controls [post]
+func (h *QCHandler) CreateControl(c *gin.Context) {
+	var req models.QCControlRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+	if req.SD != nil && *req.SD <= 0 {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "sd must be greater than zero",
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	control := models.QCControl{
+		Instrument:   req.Instrument,
+		Code:         req.Code,
+		Display:      req.Display,
+		Lot:          req.Lot,
+		Level:        req.Level,
+		Unit:         req.Unit,
+		Mean:         req.Mean,
+		SD:           req.SD,
+		BaselineSize: req.BaselineSize,
+		ExpiresAt:    req.ExpiresAt,
+		Active:       true,
+		CreatedBy:    userID,
+	}
+	if control.BaselineSize == 0 {
+		control.BaselineSize = qc.DefaultBaselineSize
+	}
+
+	if err := h.db.Create(&control).Error; err != nil {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Failed to create QC control",
+			Message: err.Error(),
+			Code:    "CONTROL_EXISTS",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "QCControl", userID, map[string]interface{}{
+		"control_id": control.ID,
+		"instrument": control.Instrument,
+		"lot":        control.Lot,
+	})
+
+	c.JSON(http.StatusCreated, control)
+}
+
+// GetControls lists control materials
+// @Summary Get QC controls
+// @Description List registered control materials
+// @Tags qc
+// @Produce json
+// @Param instrument query string false "Filter by instrument"
+// @Param code query string false "Filter by test code"
+// @Param lot query string false "Filter by lot"
+// @Param active query bool false "Only list active controls"
+// @Success 200 {array} models.QCControl
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/qc/controls [get]
+func (h *QCHandler) GetControls(c *gin.Context) {
+	query := h.controlQuery(c)
+	if c.Query("active") == "true" {
+		query = query.Where("active = ?", true)
+	}
+
+	var controls []models.QCControl
+	if err := query.Order("instrument, code, lot, level").Find(&controls).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch QC controls",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, controls)
+}
+
+// RecordResult records a measurement of a control material
+// @Summary Record QC result
+// @Description Record a control measurement. It is judged with the Westgard rules 1-2s (warning), 1-3s, 2-2s, R-4s, 4-1s and 10-x against the control's mean and SD; a rejected run raises an alert.
+// @Tags qc
+// @Accept json
+// @Produce json
+// @Param id path string true "Control ID"
+// @Param result body models.QCResultRequest true "Measurement"
+// @Success 201 {object} models.QCResult
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/qc/controls/{id}/results [post]
+func (h *QCHandler) RecordResult(c *gin.Context) {
+	var control models.QCControl
+	if err := h.db.Where("id = ?", c.Param("id")).First(&control).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "QC control not found",
+				Code:  "CONTROL_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch QC control",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if !control.Active || (control.ExpiresAt != nil && control.ExpiresAt.Before(time.Now())) {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "QC control not in use",
+			Message: "The control is inactive or its lot has expired",
+			Code:    "CONTROL_INACTIVE",
+		})
+		return
+	}
+
+	var req models.QCResultRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	result := models.QCResult{
+		Value:      *req.Value,
+		Comment:    req.Comment,
+		MeasuredAt: time.Now().UTC(),
+		CreatedBy:  userID,
+	}
+	if req.MeasuredAt != nil {
+		result.MeasuredAt = req.MeasuredAt.UTC()
+	}
+
+	alert, err := h.recorder.Record(c.Request.Context(), &control, &result)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to record QC result",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	details := map[string]interface{}{
+		"control_id": control.ID,
+		"result_id":  result.ID,
+		"status":     result.Status,
+	}
+	if alert != nil {
+		details["alert_id"] = alert.ID
+	}
+	logger.LogAuditEvent("create", "QCResult", userID, details)
+
+	c.JSON(http.StatusCreated, result)
+}
+
+// GetLeveyJennings returns Levey-Jennings chart series
+// @Summary Levey-Jennings chart
+// @Description Chartable series of control results with their mean, SD and ±1/2/3 SD limits, one series per control material
+// @Tags qc
+// @Produce json
+// @Param control query string false "Control ID"
+// @Param instrument query string false "Filter by instrument"
+// @Param code query string false "Filter by test code"
+// @Param lot query string false "Filter by lot"
+// @Param from query string false "Results measured from (ISO 8601)"
+// @Param to query string false "Results measured until (ISO 8601)"
+// @Success 200 {array} LeveyJenningsSeries
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/qc/levey-jennings [get]
+func (h *QCHandler) GetLeveyJennings(c *gin.Context) {
+	if c.Query("control") == "" && c.Query("instrument") == "" && c.Query("code") == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Missing filter",
+			Message: "Give a control, instrument or code",
+			Code:    "MISSING_FILTER",
+		})
+		return
+	}
+
+	query := h.controlQuery(c)
+	if id := strings.TrimSpace(c.Query("control")); id != "" {
+		query = query.Where("id = ?", id)
+	}
+	var controls []models.QCControl
+	if err := query.Order("instrument, code, lot, level").Find(&controls).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch QC controls",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	series := make([]LeveyJenningsSeries, 0, len(controls))
+	for _, control := range controls {
+		points := h.db.Where("control_id = ?", control.ID)
+		if from := strings.TrimSpace(c.Query("from")); from != "" {
+			points = points.Where("measured_at >= ?", from)
+		}
+		if to := strings.TrimSpace(c.Query("to")); to != "" {
+			points = points.Where("measured_at <= ?", to)
+		}
+
+		s := LeveyJenningsSeries{Control: control, Mean: control.Mean, SD: control.SD, Points: []models.QCResult{}}
+		if err := points.Order("measured_at ASC").Find(&s.Points).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to fetch QC results",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if control.Mean != nil && control.SD != nil {
+			mean, sd := *control.Mean, *control.SD
+			s.Limits = &LeveyJenningsLimits{
+				Lower3SD: mean - 3*sd,
+				Lower2SD: mean - 2*sd,
+				Lower1SD: mean - sd,
+				Upper1SD: mean + sd,
+				Upper2SD: mean + 2*sd,
+				Upper3SD: mean + 3*sd,
+			}
+		}
+		series = append(series, s)
+	}
+
+	c.JSON(http.StatusOK, series)
+}
+
+// controlQuery applies the instrument, code and lot filters to a control query
+func (h *QCHandler) controlQuery(c *gin.Context) *gorm.DB {
+	query := h.db.Model(&models.QCControl{})
+	for _, column := range []string{"instrument", "code", "lot"} {
+		if value := strings.TrimSpace(c.Query(column)); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	return query
+}
@@ -0,0 +1,270 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/quarantine"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// QuarantineHandler handles inbound messages quarantined because they failed
+// validation
+type QuarantineHandler struct {
+	db        *gorm.DB
+	queue     *quarantine.Queue
+	validator *validator.Validate
+}
+
+// NewQuarantineHandler creates a new quarantine handler
+func NewQuarantineHandler(db *gorm.DB, queue *quarantine.Queue) *QuarantineHandler {
+	return &QuarantineHandler{
+		db:        db,
+		queue:     queue,
+		validator: validator.New(),
+	}
+}
+
+// GetQuarantinedMessages lists quarantined inbound messages
+// @Summary Get quarantined messages
+// @Description List inbound messages rejected by validation, newest first, without their bodies (admin only)
+// @Tags admin
+// @Produce json
+// @Param status query string false "Filter by status (quarantined, reprocessing, reprocessed, discarded; default: quarantined)"
+// @Param route query string false "Filter by route, e.g. /api/v1/inbound/observations"
+// @Param credentialId query string false "Filter by integration credential"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.QuarantinedMessage}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/quarantine [get]
+func (h *QuarantineHandler) GetQuarantinedMessages(c *gin.Context) {
+	page, limit := capturePage(c)
+
+	query := h.db.Model(&models.QuarantinedMessage{}).Where("status = ?", c.DefaultQuery("status", models.QuarantineHeld))
+	if route := c.Query("route"); route != "" {
+		query = query.Where("route = ?", route)
+	}
+	if credentialID := c.Query("credentialId"); credentialID != "" {
+		query = query.Where("credential_id = ?", credentialID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count quarantined messages",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var messages []models.QuarantinedMessage
+	if err := query.Omit("body").Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&messages).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch quarantined messages",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, messages, total, page, limit)
+}
+
+// GetQuarantinedMessage retrieves a quarantined message
+// @Summary Get quarantined message
+// @Description Get a quarantined inbound message with its body and error (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {object} models.QuarantinedMessage
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/quarantine/{id} [get]
+func (h *QuarantineHandler) GetQuarantinedMessage(c *gin.Context) {
+	var msg models.QuarantinedMessage
+	if err := h.db.Where("id = ?", c.Param("id")).First(&msg).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Quarantined message not found",
+				Code:  "MESSAGE_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch quarantined message",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// The body may carry PHI
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("read", "QuarantinedMessage", userID, map[string]interface{}{
+		"message_id": msg.ID,
+	})
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// UpdateMappingHints replaces the mapping hints of a quarantined message
+// @Summary Update mapping hints
+// @Description Replace the values set at dotted JSON paths of the message body when it is reprocessed, e.g. {"code.coding.0.system": "http://loinc.org"}. Numeric segments index arrays; a null value removes the field (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "Message ID"
+// @Param request body models.MappingHintsRequest true "Mapping hints"
+// @Success 200 {object} models.QuarantinedMessage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/quarantine/{id}/hints [put]
+func (h *QuarantineHandler) UpdateMappingHints(c *gin.Context) {
+	var req models.MappingHintsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	msg, err := h.queue.SetHints(c.Request.Context(), c.Param("id"), req.MappingHints)
+	if err != nil {
+		quarantineError(c, err, "Failed to update mapping hints")
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update_hints", "QuarantinedMessage", userID, map[string]interface{}{
+		"message_id": msg.ID,
+		"hints":      len(req.MappingHints),
+	})
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// ReprocessQuarantinedMessage resubmits a quarantined message
+// @Summary Reprocess quarantined message
+// @Description Resubmit the message with its mapping hints applied, signed with the integration's credential. It is resolved when accepted; otherwise it stays quarantined with the new error (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {object} models.QuarantinedMessage
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/quarantine/{id}/reprocess [post]
+func (h *QuarantineHandler) ReprocessQuarantinedMessage(c *gin.Context) {
+	userID, _ := auth.GetUserID(c)
+	msg, err := h.queue.Reprocess(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		quarantineError(c, err, "Failed to reprocess quarantined message")
+		return
+	}
+
+	logger.LogAuditEvent("reprocess", "QuarantinedMessage", userID, map[string]interface{}{
+		"message_id":  msg.ID,
+		"status":      msg.Status,
+		"status_code": msg.StatusCode,
+		"resource_id": msg.ResourceID,
+	})
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// DiscardQuarantinedMessage drops a quarantined message
+// @Summary Discard quarantined message
+// @Description Resolve the message without reprocessing it (admin only)
+// @Tags admin
+// @Produce json
+// @Param id path string true "Message ID"
+// @Success 200 {object} models.QuarantinedMessage
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/quarantine/{id}/discard [post]
+func (h *QuarantineHandler) DiscardQuarantinedMessage(c *gin.Context) {
+	userID, _ := auth.GetUserID(c)
+	msg, err := h.queue.Discard(c.Request.Context(), c.Param("id"), userID)
+	if err != nil {
+		quarantineError(c, err, "Failed to discard quarantined message")
+		return
+	}
+
+	logger.LogAuditEvent("discard", "QuarantinedMessage", userID, map[string]interface{}{
+		"message_id": msg.ID,
+	})
+
+	c.JSON(http.StatusOK, msg)
+}
+
+// quarantineError writes the response for an error of the quarantine queue
+func quarantineError(c *gin.Context, err error, message string) {
+	var hintErr *quarantine.HintError
+	switch {
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Quarantined message not found",
+			Code:  "MESSAGE_NOT_FOUND",
+		})
+	case errors.Is(err, quarantine.ErrNotQuarantined):
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Message is no longer quarantined",
+			Code:  "MESSAGE_NOT_QUARANTINED",
+		})
+	case errors.Is(err, quarantine.ErrCredentialUnavailable):
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Message cannot be resubmitted",
+			Message: err.Error(),
+			Code:    "CREDENTIAL_UNAVAILABLE",
+		})
+	case errors.As(err, &hintErr):
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid mapping hints",
+			Message: hintErr.Reason,
+			Code:    "INVALID_MAPPING_HINTS",
+		})
+	default:
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   message,
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+	}
+}
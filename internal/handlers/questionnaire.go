@@ -0,0 +1,582 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+)
+
+// QuestionnaireHandler handles HTTP requests for questionnaires and their responses
+type QuestionnaireHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewQuestionnaireHandler creates a new questionnaire handler
+func NewQuestionnaireHandler(db *gorm.DB) *QuestionnaireHandler {
+	return &QuestionnaireHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// CreateQuestionnaire creates a new questionnaire definition
+// @Summary Create a questionnaire
+// @Description Define a new questionnaire or assessment with optional scoring
+// @Tags questionnaires
+// @Accept json
+// @Produce json
+// @Param questionnaire body models.Questionnaire true "Questionnaire definition"
+// @Success 201 {object} models.Questionnaire
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/questionnaires [post]
+func (h *QuestionnaireHandler) CreateQuestionnaire(c *gin.Context) {
+	var questionnaire models.Questionnaire
+
+	if err := c.ShouldBindJSON(&questionnaire); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(questionnaire); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	if questionnaire.Scoring != nil {
+		if err := h.validator.Struct(questionnaire.Scoring); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: err.Error(),
+				Code:    "VALIDATION_FAILED",
+			})
+			return
+		}
+	}
+
+	var existing models.Questionnaire
+	if err := h.db.Where("name = ?", questionnaire.Name).First(&existing).Error; err == nil {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Questionnaire with this name already exists",
+			Code:  "QUESTIONNAIRE_ALREADY_EXISTS",
+		})
+		return
+	}
+
+	if userID, exists := auth.GetUserID(c); exists {
+		questionnaire.CreatedBy = userID
+	}
+
+	if err := h.db.Create(&questionnaire).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create questionnaire",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, questionnaire)
+}
+
+// GetQuestionnaires lists questionnaire definitions
+// @Summary Get questionnaires
+// @Description Get a list of questionnaires with optional status filtering
+// @Tags questionnaires
+// @Produce json
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Param status query string false "Filter by status"
+// @Success 200 {object} PaginatedResponse{data=[]models.Questionnaire}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/questionnaires [get]
+func (h *QuestionnaireHandler) GetQuestionnaires(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	status := strings.TrimSpace(c.Query("status"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var questionnaires []models.Questionnaire
+	query := h.db.Model(&models.Questionnaire{})
+
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count questionnaires",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("name ASC").Offset(offset).Limit(limit).Find(&questionnaires).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch questionnaires",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, questionnaires, total, page, limit)
+}
+
+// GetQuestionnaire retrieves a questionnaire definition by ID
+// @Summary Get questionnaire by ID
+// @Description Get a questionnaire definition
+// @Tags questionnaires
+// @Produce json
+// @Param id path string true "Questionnaire ID"
+// @Success 200 {object} models.Questionnaire
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/questionnaires/{id} [get]
+func (h *QuestionnaireHandler) GetQuestionnaire(c *gin.Context) {
+	questionnaire, ok := h.findQuestionnaire(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, questionnaire)
+}
+
+// UpdateQuestionnaire updates a questionnaire definition
+// @Summary Update questionnaire
+// @Description Update a questionnaire definition. Existing responses keep their recorded scores.
+// @Tags questionnaires
+// @Accept json
+// @Produce json
+// @Param id path string true "Questionnaire ID"
+// @Param questionnaire body models.Questionnaire true "Updated questionnaire definition"
+// @Success 200 {object} models.Questionnaire
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/questionnaires/{id} [put]
+func (h *QuestionnaireHandler) UpdateQuestionnaire(c *gin.Context) {
+	questionnaire, ok := h.findQuestionnaire(c)
+	if !ok {
+		return
+	}
+
+	var updateData models.Questionnaire
+	if err := c.ShouldBindJSON(&updateData); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(updateData); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	if updateData.Scoring != nil {
+		if err := h.validator.Struct(updateData.Scoring); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: err.Error(),
+				Code:    "VALIDATION_FAILED",
+			})
+			return
+		}
+	}
+
+	// Preserve ID and audit fields
+	updateData.ID = questionnaire.ID
+	updateData.CreatedAt = questionnaire.CreatedAt
+	updateData.CreatedBy = questionnaire.CreatedBy
+
+	if err := h.db.Model(questionnaire).Updates(updateData).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update questionnaire",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// Fetch updated questionnaire
+	updated, ok := h.findQuestionnaire(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, updated)
+}
+
+// SubmitResponse administers a questionnaire by recording a response, scoring it
+// and storing the score as a derived observation
+// @Summary Submit questionnaire response
+// @Description Record answers to a questionnaire. Completed responses to scored questionnaires produce a derived score observation.
+// @Tags questionnaires
+// @Accept json
+// @Produce json
+// @Param id path string true "Questionnaire ID"
+// @Param response body models.QuestionnaireResponse true "Questionnaire answers"
+// @Success 201 {object} models.QuestionnaireResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 422 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/questionnaires/{id}/responses [post]
+func (h *QuestionnaireHandler) SubmitResponse(c *gin.Context) {
+	questionnaire, ok := h.findQuestionnaire(c)
+	if !ok {
+		return
+	}
+
+	if questionnaire.Status != "active" {
+		respondError(c, http.StatusUnprocessableEntity, ErrorResponse{
+			Error: "Questionnaire is not active",
+			Code:  "QUESTIONNAIRE_NOT_ACTIVE",
+		})
+		return
+	}
+
+	var response models.QuestionnaireResponse
+	if err := c.ShouldBindJSON(&response); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if response.Status == "" {
+		response.Status = "completed"
+	}
+
+	if err := h.validator.Struct(response); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	patientID := strings.TrimPrefix(response.Subject.Reference, "Patient/")
+	if patientID == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Response subject is required",
+			Code:  "MISSING_SUBJECT",
+		})
+		return
+	}
+
+	var patient models.Patient
+	if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := questionnaire.ValidateResponse(&response); err != nil {
+		respondError(c, http.StatusUnprocessableEntity, ErrorResponse{
+			Error:   "Response does not match questionnaire",
+			Message: err.Error(),
+			Code:    "INVALID_QUESTIONNAIRE_RESPONSE",
+		})
+		return
+	}
+
+	response.ID = ""
+	response.QuestionnaireID = questionnaire.ID
+	response.Author = currentUserReference(c)
+	response.DerivedObservationID = ""
+	if response.Authored.IsZero() {
+		response.Authored = time.Now()
+	}
+	if userID, exists := auth.GetUserID(c); exists {
+		response.CreatedBy = userID
+	}
+
+	var observation *models.Observation
+	if response.Status == "completed" && questionnaire.Scoring != nil {
+		score, band, err := questionnaire.Score(&response)
+		if err != nil {
+			respondError(c, http.StatusUnprocessableEntity, ErrorResponse{
+				Error:   "Failed to score response",
+				Message: err.Error(),
+				Code:    "SCORING_FAILED",
+			})
+			return
+		}
+
+		response.Score = &score
+		if band != nil {
+			response.ScoreInterpretation = band.Display
+		}
+		observation = scoreObservation(questionnaire, &response, score, band)
+	}
+
+	tx := h.db.Begin()
+	defer func() {
+		if r := recover(); r != nil {
+			tx.Rollback()
+		}
+	}()
+
+	if err := tx.Create(&response).Error; err != nil {
+		tx.Rollback()
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create questionnaire response",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if observation != nil {
+		observation.DerivedFrom = []models.Reference{{
+			Reference: "QuestionnaireResponse/" + response.ID,
+			Type:      "QuestionnaireResponse",
+		}}
+
+		if err := tx.Create(observation).Error; err != nil {
+			tx.Rollback()
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to create score observation",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+
+		response.DerivedObservationID = observation.ID
+		if err := tx.Model(&response).Update("derived_observation_id", observation.ID).Error; err != nil {
+			tx.Rollback()
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to link score observation",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	if err := tx.Commit().Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to commit transaction",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, response)
+}
+
+// GetResponse retrieves a questionnaire response by ID
+// @Summary Get questionnaire response by ID
+// @Description Get a recorded questionnaire response including its score
+// @Tags questionnaires
+// @Produce json
+// @Param id path string true "Questionnaire response ID"
+// @Success 200 {object} models.QuestionnaireResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/questionnaire-responses/{id} [get]
+func (h *QuestionnaireHandler) GetResponse(c *gin.Context) {
+	var response models.QuestionnaireResponse
+	if err := h.db.Where("id = ?", c.Param("id")).First(&response).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Questionnaire response not found",
+				Code:  "QUESTIONNAIRE_RESPONSE_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch questionnaire response",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// GetPatientResponses retrieves questionnaire responses for a specific patient
+// @Summary Get patient questionnaire responses
+// @Description Get questionnaire responses recorded for a patient
+// @Tags questionnaires
+// @Produce json
+// @Param patientId path string true "Patient ID"
+// @Param questionnaire query string false "Filter by questionnaire ID"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.QuestionnaireResponse}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{patientId}/questionnaire-responses [get]
+func (h *QuestionnaireHandler) GetPatientResponses(c *gin.Context) {
+	patientID := c.Param("id")
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	questionnaireID := strings.TrimSpace(c.Query("questionnaire"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var responses []models.QuestionnaireResponse
+	query := h.db.Model(&models.QuestionnaireResponse{}).Where("subject_reference = ?", "Patient/"+patientID)
+
+	if questionnaireID != "" {
+		query = query.Where("questionnaire_id = ?", questionnaireID)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count questionnaire responses",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	offset := (page - 1) * limit
+	if err := query.Order("authored DESC").Offset(offset).Limit(limit).Find(&responses).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch questionnaire responses",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, responses, total, page, limit)
+}
+
+// findQuestionnaire loads the questionnaire identified by the id path parameter
+func (h *QuestionnaireHandler) findQuestionnaire(c *gin.Context) (*models.Questionnaire, bool) {
+	id := c.Param("id")
+
+	var questionnaire models.Questionnaire
+	if err := h.db.Where("id = ?", id).First(&questionnaire).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Questionnaire not found",
+				Code:  "QUESTIONNAIRE_NOT_FOUND",
+			})
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch questionnaire",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return nil, false
+	}
+
+	return &questionnaire, true
+}
+
+// scoreObservation builds the survey observation that records a questionnaire score
+func scoreObservation(questionnaire *models.Questionnaire, response *models.QuestionnaireResponse, score float64, band *models.ScoreBand) *models.Observation {
+	observation := &models.Observation{
+		Status: "final",
+		Category: []models.Category{{
+			Coding: []models.Coding{{
+				System:  "http://terminology.hl7.org/CodeSystem/observation-category",
+				Code:    "survey",
+				Display: "Survey",
+			}},
+		}},
+		Code:              questionnaire.Scoring.Code,
+		Subject:           response.Subject,
+		Encounter:         response.Encounter,
+		EffectiveDateTime: response.Authored,
+		ValueQuantity: &models.Quantity{
+			Value: score,
+			Unit:  questionnaire.Scoring.Unit,
+		},
+		CreatedBy: response.CreatedBy,
+	}
+
+	if observation.Code.Text == "" {
+		observation.Code.Text = questionnaire.Title + " score"
+	}
+
+	if band != nil {
+		observation.Interpretation = []models.CodeableConcept{{
+			Coding: []models.Coding{{Code: band.Code, Display: band.Display}},
+			Text:   band.Display,
+		}}
+	}
+
+	return observation
+}
@@ -0,0 +1,194 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/disclosure"
+)
+
+// referenceRangePeriods are the reporting periods accepted by date_trunc
+var referenceRangePeriods = map[string]bool{"day": true, "week": true, "month": true}
+
+// referenceRangeSources are the columns results can be broken down by
+var referenceRangeSources = map[string][2]string{
+	"performer": {
+		"COALESCE(NULLIF(observations.performer, '')::jsonb->0->>'reference', '')",
+		"NULLIF(observations.performer, '')::jsonb->0->>'display'",
+	},
+	"device": {
+		"COALESCE(observations.device_reference, '')",
+		"observations.device_display",
+	},
+}
+
+// reportedStatuses are the statuses included when no status filter is given;
+// preliminary, cancelled and entered-in-error results would skew QC figures
+var reportedStatuses = []string{"final", "amended", "corrected"}
+
+// ReferenceRangeBreach counts the results of one code, period and performer or
+// device falling outside their reference range
+type ReferenceRangeBreach struct {
+	System        string    `json:"system,omitempty"`
+	Code          string    `json:"code"`
+	Display       string    `json:"display,omitempty"`
+	Period        time.Time `json:"period"`
+	Source        string    `json:"source,omitempty"` // Performer or device reference
+	SourceDisplay string    `json:"sourceDisplay,omitempty"`
+	Total         int64     `json:"total"`
+	Below         int64     `json:"below"`
+	Above         int64     `json:"above"`
+	Outside       int64     `json:"outside"`
+	Percentage    float64   `json:"percentage"`
+	Patients      int64     `json:"-"`
+	Suppressed    bool      `json:"suppressed,omitempty"` // Counts withheld by disclosure control
+}
+
+// ReferenceRangeReport represents the share of results outside reference range
+type ReferenceRangeReport struct {
+	Period      string                 `json:"period"`
+	By          string                 `json:"by"`
+	From        string                 `json:"from,omitempty"`
+	To          string                 `json:"to,omitempty"`
+	Results     []ReferenceRangeBreach `json:"results"`
+	Disclosure  *disclosure.Policy     `json:"disclosure,omitempty"` // Disclosure control applied to the counts
+	GeneratedAt time.Time              `json:"generatedAt"`
+}
+
+// GetReferenceRangeBreaches reports the percentage of results outside reference range
+// @Summary Reference range breach report
+// @Description Percentage of quantity results outside their reference range per code and period, broken down by performer or device, for laboratory QC monitoring. Only results with a numeric value and a reference range with a low or high bound are counted; the bounds of the first reference range are compared in the result's unit. For research users, rows of fewer patients than the endpoint's minimum cell size, or with fewer results below or above range, are suppressed, and released counts may carry noise.
+// @Tags analytics
+// @Produce json
+// @Param code query string false "Filter by observation code"
+// @Param status query string false "Filter by status; final, amended and corrected results by default"
+// @Param from query string false "Filter by effective date from (ISO 8601)"
+// @Param to query string false "Filter by effective date to (ISO 8601)"
+// @Param period query string false "Reporting period: day, week or month" default(month)
+// @Param by query string false "Break down by performer or device" default(performer)
+// @Success 200 {object} ReferenceRangeReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/analytics/reference-range-breaches [get]
+func (h *AnalyticsHandler) GetReferenceRangeBreaches(c *gin.Context) {
+	// Reading the serialized reference ranges relies on PostgreSQL JSON functions
+	if !dialect.IsPostgres(h.db) {
+		respondError(c, http.StatusNotImplemented, ErrorResponse{
+			Error:   "Report not available",
+			Message: "The reference range report requires a PostgreSQL database",
+			Code:    "UNSUPPORTED_DATABASE",
+		})
+		return
+	}
+
+	report := ReferenceRangeReport{
+		Period:      c.DefaultQuery("period", "month"),
+		By:          c.DefaultQuery("by", "performer"),
+		From:        strings.TrimSpace(c.Query("from")),
+		To:          strings.TrimSpace(c.Query("to")),
+		Results:     []ReferenceRangeBreach{},
+		GeneratedAt: time.Now().UTC(),
+	}
+	if !referenceRangePeriods[report.Period] {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid period",
+			Message: "period must be day, week or month",
+			Code:    "INVALID_PERIOD",
+		})
+		return
+	}
+	source, ok := referenceRangeSources[report.By]
+	if !ok {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid breakdown",
+			Message: "by must be performer or device",
+			Code:    "INVALID_BREAKDOWN",
+		})
+		return
+	}
+
+	filter := models.ObservationFilter{
+		Status: strings.TrimSpace(c.Query("status")),
+		Code:   strings.TrimSpace(c.Query("code")),
+		From:   report.From,
+		To:     report.To,
+	}
+	if !checkObservationFilter(c, filter) {
+		return
+	}
+	query := filter.Apply(readDB(c, h.db).Model(&models.Observation{}))
+	if filter.Status == "" {
+		query = query.Where("observations.status IN ?", reportedStatuses)
+	}
+
+	const value = "observations.value_quantity_value"
+	low, high := referenceRangeBound("low"), referenceRangeBound("high")
+
+	err := query.Select(`observations.code->'coding'->0->>'system' AS system,
+			observations.code->'coding'->0->>'code' AS code,
+			MAX(COALESCE(observations.code->'coding'->0->>'display', observations.code->>'text')) AS display,
+			date_trunc(?, observations.effective_date_time AT TIME ZONE 'UTC') AS period,
+			`+source[0]+` AS source,
+			MAX(`+source[1]+`) AS source_display,
+			COUNT(*) AS total,
+			COUNT(DISTINCT observations.subject->>'reference') AS patients,
+			COUNT(*) FILTER (WHERE `+value+` < `+low+`) AS below,
+			COUNT(*) FILTER (WHERE `+value+` > `+high+`) AS above`, report.Period).
+		Where(value + " IS NOT NULL").
+		Where("(" + low + " IS NOT NULL OR " + high + " IS NOT NULL)").
+		Group("1, 2, 4, 5").Order("1, 2, 4, 5").
+		Scan(&report.Results).Error
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to aggregate observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	policy := h.disclosurePolicy(c, "reference-range-breaches")
+	if policy != nil {
+		report.Disclosure = policy
+	}
+	for i := range report.Results {
+		result := &report.Results[i]
+		if policy != nil {
+			result.applyDisclosure(*policy)
+		}
+		result.Outside = result.Below + result.Above
+		if result.Total > 0 {
+			result.Percentage = math.Round(float64(result.Outside)*10000/float64(result.Total)) / 100
+		}
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// applyDisclosure suppresses a row of too few patients, or with too few results below
+// or above range, and perturbs the counts of the rest
+func (r *ReferenceRangeBreach) applyDisclosure(policy disclosure.Policy) {
+	if policy.Suppressed(r.Patients) || policy.Suppressed(r.Below) || policy.Suppressed(r.Above) {
+		r.Total, r.Below, r.Above = 0, 0, 0
+		r.Suppressed = true
+		return
+	}
+	r.Total = policy.Perturb(r.Total)
+	r.Below = min(policy.Perturb(r.Below), r.Total)
+	r.Above = min(policy.Perturb(r.Above), r.Total-r.Below)
+}
+
+// referenceRangeBound selects the low or high value of an observation's first
+// reference range
+func referenceRangeBound(bound string) string {
+	return "(NULLIF(observations.reference_range, '')::jsonb->0->'" + bound + "'->>'value')::double precision"
+}
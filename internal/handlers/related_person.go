@@ -0,0 +1,379 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/retention"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// RelatedPersonHandler handles HTTP requests for the related persons of patients,
+// such as their next-of-kin and emergency contacts
+type RelatedPersonHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewRelatedPersonHandler creates a new related person handler
+func NewRelatedPersonHandler(db *gorm.DB) *RelatedPersonHandler {
+	return &RelatedPersonHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// CreateRelatedPerson records a related person of a patient
+// @Summary Create related person
+// @Description Record someone with a personal relationship to the patient, such as their next-of-kin or an emergency contact. Relationships are coded with HL7 v2 table 0131 contact roles, e.g. N (next-of-kin) or C (emergency contact), and HL7 v3 RoleCode relationships, e.g. MTH or SPS. Related persons are active unless stated otherwise.
+// @Tags related-persons
+// @Accept json
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param relatedPerson body models.RelatedPersonRequest true "Related person"
+// @Success 201 {object} models.RelatedPerson
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/related-persons [post]
+func (h *RelatedPersonHandler) CreateRelatedPerson(c *gin.Context) {
+	var req models.RelatedPersonRequest
+	if !h.bindRelatedPerson(c, &req) {
+		return
+	}
+
+	db := writeDB(c, h.db)
+	patientID := c.Param("id")
+	var patient models.Patient
+	if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch patient",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	person := models.RelatedPerson{
+		Active:  true,
+		Patient: models.Reference{Reference: "Patient/" + patientID},
+	}
+	applyRelatedPersonRequest(&person, req)
+	if userID, exists := auth.GetUserID(c); exists {
+		person.CreatedBy = userID
+	}
+	if err := db.Create(&person).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create related person",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "RelatedPerson", person.CreatedBy, map[string]interface{}{
+		"related_person_id": person.ID,
+		"patient_id":        patientID,
+	})
+
+	c.JSON(http.StatusCreated, person)
+}
+
+// GetRelatedPersons lists the related persons of a patient
+// @Summary Get patient related persons
+// @Description List the related persons of a patient in the order to contact them: by rank, those without one last, then as recorded
+// @Tags related-persons
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param relationship query string false "Filter by relationship code, e.g. C for emergency contacts or N for next-of-kin"
+// @Param active query bool false "Filter by whether the relationship is active"
+// @Success 200 {array} models.RelatedPerson
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/related-persons [get]
+func (h *RelatedPersonHandler) GetRelatedPersons(c *gin.Context) {
+	query := readDB(c, h.db).Where("patient_reference = ?", "Patient/"+c.Param("id"))
+	if active, err := strconv.ParseBool(c.Query("active")); err == nil {
+		query = query.Where("active = ?", active)
+	}
+
+	var persons []models.RelatedPerson
+	if err := query.Order("created_at").Find(&persons).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch related persons",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	// Relationships are serialized JSON, so they are matched once loaded
+	matched := []models.RelatedPerson{}
+	relationship := strings.TrimSpace(c.Query("relationship"))
+	for i := range persons {
+		if relationship == "" || persons[i].HasRelationship(relationship) {
+			matched = append(matched, persons[i])
+		}
+	}
+	sort.SliceStable(matched, func(i, j int) bool {
+		a, b := matched[i].Rank, matched[j].Rank
+		if a == 0 || b == 0 {
+			return b == 0 && a != 0
+		}
+		return a < b
+	})
+
+	c.JSON(http.StatusOK, matched)
+}
+
+// GetRelatedPerson retrieves a related person of a patient
+// @Summary Get related person
+// @Description Get a related person of the patient by its ID
+// @Tags related-persons
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param relatedPersonId path string true "Related person ID"
+// @Success 200 {object} models.RelatedPerson
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/related-persons/{relatedPersonId} [get]
+func (h *RelatedPersonHandler) GetRelatedPerson(c *gin.Context) {
+	var person models.RelatedPerson
+	if !findRelatedPerson(c, readDB(c, h.db), &person) {
+		return
+	}
+
+	c.JSON(http.StatusOK, person)
+}
+
+// UpdateRelatedPerson replaces a related person of a patient
+// @Summary Update related person
+// @Description Replace the relationships, name, contact details, period and rank of a related person, e.g. when an emergency contact's phone number changes. Set active to false when the relationship ends so it stays on record; active is kept when left out.
+// @Tags related-persons
+// @Accept json
+// @Produce json
+// @Param id path string true "Patient ID"
+// @Param relatedPersonId path string true "Related person ID"
+// @Param relatedPerson body models.RelatedPersonRequest true "Related person"
+// @Success 200 {object} models.RelatedPerson
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/related-persons/{relatedPersonId} [put]
+func (h *RelatedPersonHandler) UpdateRelatedPerson(c *gin.Context) {
+	var req models.RelatedPersonRequest
+	if !h.bindRelatedPerson(c, &req) {
+		return
+	}
+
+	db := writeDB(c, h.db)
+	var person models.RelatedPerson
+	if !findRelatedPerson(c, db, &person) {
+		return
+	}
+
+	applyRelatedPersonRequest(&person, req)
+	if err := db.Model(&person).Select("active", "relationship", "name", "gender", "telecom", "address",
+		"period_start", "period_end", "rank", "updated_at").Updates(&person).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update related person",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "RelatedPerson", userID, map[string]interface{}{
+		"related_person_id": person.ID,
+		"patient_id":        c.Param("id"),
+		"active":            person.Active,
+	})
+
+	c.JSON(http.StatusOK, person)
+}
+
+// DeleteRelatedPerson removes a related person of a patient
+// @Summary Delete related person
+// @Description Delete a related person recorded by mistake (admin only). Prefer marking a relationship that has ended inactive so it stays on record. A related person under a legal hold, or of a patient under one, cannot be deleted.
+// @Tags related-persons
+// @Param id path string true "Patient ID"
+// @Param relatedPersonId path string true "Related person ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/patients/{id}/related-persons/{relatedPersonId} [delete]
+func (h *RelatedPersonHandler) DeleteRelatedPerson(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var person models.RelatedPerson
+	if !findRelatedPerson(c, db, &person) {
+		return
+	}
+
+	// Related persons are removed for good rather than kept in the trash, so a hold
+	// keeps them from being deleted at all
+	var held bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		holds, err := retention.Held(tx, "RelatedPerson", []string{person.ID})
+		if err != nil {
+			return err
+		}
+		if held = holds[person.ID]; held {
+			return nil
+		}
+		return tx.Delete(&person).Error
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete related person",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if held {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Related person is under a legal hold",
+			Message: "it cannot be deleted until every legal hold on it or its patient is released",
+			Code:    "LEGAL_HOLD_ACTIVE",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "RelatedPerson", userID, map[string]interface{}{
+		"related_person_id": person.ID,
+		"patient_id":        c.Param("id"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// bindRelatedPerson binds and validates a related person request, writing the error
+// response on failure
+func (h *RelatedPersonHandler) bindRelatedPerson(c *gin.Context, req *models.RelatedPersonRequest) bool {
+	if err := c.ShouldBindJSON(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return false
+	}
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+
+	message := ""
+	if req.Period.Start != nil && req.Period.End != nil && req.Period.End.Before(*req.Period.Start) {
+		message = "period end must not be before its start"
+	}
+	for i := range req.Relationship {
+		relationship := &req.Relationship[i]
+		if len(relationship.Coding) == 0 && strings.TrimSpace(relationship.Text) == "" {
+			message = "each relationship needs a coding or text"
+		}
+		for j := range relationship.Coding {
+			coding := &relationship.Coding[j]
+			if coding.Code == "" {
+				message = "relationship codings need a code"
+				continue
+			}
+			if coding.System != models.RelatedPersonContactRoleSystem {
+				continue
+			}
+			display, ok := models.RelatedPersonContactRoles[coding.Code]
+			if !ok {
+				message = "unknown contact role " + coding.Code + " in " + models.RelatedPersonContactRoleSystem
+				continue
+			}
+			if coding.Display == "" {
+				coding.Display = display
+			}
+		}
+	}
+	if message != "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: message,
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	return true
+}
+
+// applyRelatedPersonRequest sets the fields of a related person from a request
+func applyRelatedPersonRequest(person *models.RelatedPerson, req models.RelatedPersonRequest) {
+	if req.Active != nil {
+		person.Active = *req.Active
+	}
+	person.Relationship = req.Relationship
+	person.Name = req.Name
+	person.Gender = req.Gender
+	// Related persons' contact details are not verified; claims of it are ignored
+	for i := range req.Telecom {
+		req.Telecom[i].Verified = false
+	}
+	person.Telecom = req.Telecom
+	person.Address = req.Address
+	person.Period = req.Period
+	person.Rank = req.Rank
+}
+
+// findRelatedPerson loads the related person in the path of the patient in the path,
+// writing the error response when it cannot
+func findRelatedPerson(c *gin.Context, db *gorm.DB, person *models.RelatedPerson) bool {
+	err := db.Where("id = ? AND patient_reference = ?", c.Param("relatedPersonId"), "Patient/"+c.Param("id")).
+		First(person).Error
+	if err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Related person not found",
+				Code:  "RELATED_PERSON_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch related person",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
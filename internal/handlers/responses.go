@@ -1,15 +1,15 @@
 package handlers
 
-import "time"
-
-// ErrorResponse represents an error response
-type ErrorResponse struct {
-	Error     string            `json:"error"`
-	Message   string            `json:"message,omitempty"`
-	Code      string            `json:"code,omitempty"`
-	Details   map[string]string `json:"details,omitempty"`
-	Timestamp time.Time         `json:"timestamp"`
-}
+import (
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+)
+
+// ErrorResponse represents an error response. Its code is one of the documented
+// codes listed by GET /api/v1/errors.
+type ErrorResponse = apierror.Response
 
 // PaginatedResponse represents a paginated response
 type PaginatedResponse struct {
@@ -53,6 +53,17 @@ func NewErrorResponse(error, message, code string) ErrorResponse {
 	}
 }
 
+// respondError writes an error response stamped with when it happened and the ID of
+// the request
+func respondError(c *gin.Context, status int, resp ErrorResponse) {
+	apierror.Respond(c, status, resp)
+}
+
+// abortError writes a stamped error response and stops the handler chain
+func abortError(c *gin.Context, status int, resp ErrorResponse) {
+	apierror.Abort(c, status, resp)
+}
+
 // NewSuccessResponse creates a new success response
 func NewSuccessResponse(message string, data interface{}) SuccessResponse {
 	return SuccessResponse{
@@ -0,0 +1,222 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// SandboxHeader marks the response of a write that was simulated for a sandbox user
+const SandboxHeader = "X-Sandbox"
+
+// writeDBKey is the context key of the transaction a simulated write runs in
+const writeDBKey = "write_db"
+
+// simulatedWrites are the writes a sandbox user may make. Their handlers write
+// through writeDB and only through Transaction, whose savepoints nest in the
+// simulation's transaction; a handler calling Begin and Commit would commit it.
+var simulatedWrites = map[string]bool{
+	"POST /api/v1/patients":                                       true,
+	"PUT /api/v1/patients/:id":                                    true,
+	"POST /api/v1/observations":                                   true,
+	"PUT /api/v1/observations/:id":                                true,
+	"DELETE /api/v1/observations/:id":                             true,
+	"POST /api/v1/specimens":                                      true,
+	"PUT /api/v1/specimens/:id":                                   true,
+	"POST /api/v1/devices":                                        true,
+	"PUT /api/v1/devices/:id":                                     true,
+	"POST /api/v1/conditions":                                     true,
+	"POST /api/v1/conditions/:id/status":                          true,
+	"POST /api/v1/allergy-intolerances":                           true,
+	"POST /api/v1/allergy-intolerances/:id/status":                true,
+	"PUT /api/v1/allergy-intolerances/:id":                        true,
+	"POST /api/v1/patients/:id/allergy-intolerances":              true,
+	"POST /api/v1/procedures":                                     true,
+	"PUT /api/v1/procedures/:id":                                  true,
+	"POST /api/v1/patients/:id/procedures":                        true,
+	"POST /api/v1/coverages":                                      true,
+	"PUT /api/v1/coverages/:id":                                   true,
+	"POST /api/v1/patients/:id/coverages":                         true,
+	"POST /api/v1/patients/:id/related-persons":                   true,
+	"PUT /api/v1/patients/:id/related-persons/:relatedPersonId":   true,
+	"POST /api/v1/care-plans":                                     true,
+	"PUT /api/v1/care-plans/:id":                                  true,
+	"POST /api/v1/care-plans/:id/status":                          true,
+	"POST /api/v1/care-plans/:id/activities":                      true,
+	"POST /api/v1/care-plans/:id/activities/:activityId/complete": true,
+	"POST /api/v1/patients/:id/care-plans":                        true,
+	"POST /api/v1/inbound/observations":                           true,
+	"POST /api/v1/inbound/specimens":                              true,
+}
+
+// sandboxExempt are paths that write the user's own session and run normally
+const sandboxExempt = "/api/v1/auth/"
+
+// SandboxMiddleware simulates the writes of sandbox users, such as integration
+// partners testing against production. A simulated write is validated and runs as
+// usual, so the response echoes the generated IDs, but in a transaction that is
+// rolled back once the handler responded. Writes that cannot be simulated are
+// refused. It must follow the authentication middleware.
+func SandboxMiddleware(db *gorm.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		switch c.Request.Method {
+		case http.MethodGet, http.MethodHead, http.MethodOptions:
+			c.Next()
+			return
+		}
+		userID, _ := auth.GetUserID(c)
+		if userID == "" || strings.HasPrefix(c.Request.URL.Path, sandboxExempt) {
+			c.Next()
+			return
+		}
+
+		var sandbox bool
+		if err := db.Model(&models.User{}).Select("sandbox").Where("id = ?", userID).Scan(&sandbox).Error; err != nil {
+			abortError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to check sandbox mode",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if !sandbox {
+			c.Next()
+			return
+		}
+
+		if !simulatedWrites[c.Request.Method+" "+c.FullPath()] {
+			abortError(c, http.StatusForbidden, ErrorResponse{
+				Error:   "Write not available in sandbox mode",
+				Message: "This endpoint cannot simulate writes; no changes were made",
+				Code:    "SANDBOX_UNSUPPORTED",
+			})
+			return
+		}
+
+		tx := db.WithContext(c.Request.Context()).Begin()
+		if tx.Error != nil {
+			abortError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to start sandbox transaction",
+				Message: tx.Error.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		defer func() {
+			if err := tx.Rollback().Error; err != nil {
+				logger.Warn("Failed to roll back sandbox transaction", zap.String("user_id", userID), zap.Error(err))
+			}
+		}()
+
+		c.Set(writeDBKey, tx)
+		c.Header(SandboxHeader, "simulated")
+		c.Next()
+	}
+}
+
+// writeDB returns the transaction of a simulated write, or db
+func writeDB(c *gin.Context, db *gorm.DB) *gorm.DB {
+	if value, ok := c.Get(writeDBKey); ok {
+		return value.(*gorm.DB)
+	}
+	return db
+}
+
+// simulated reports whether the request's write is simulated and will not persist
+func simulated(c *gin.Context) bool {
+	_, ok := c.Get(writeDBKey)
+	return ok
+}
+
+// SandboxHandler handles the sandbox mode of users
+type SandboxHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+}
+
+// NewSandboxHandler creates a new sandbox handler
+func NewSandboxHandler(db *gorm.DB) *SandboxHandler {
+	return &SandboxHandler{
+		db:        db,
+		validator: validator.New(),
+	}
+}
+
+// SetUserSandbox turns sandbox mode on or off for a user
+// @Summary Set user sandbox mode
+// @Description Turn sandbox mode on or off for a user, such as an integration partner's account. Writes by a sandbox user are validated and answered as usual, with generated IDs, but not persisted; responses carry X-Sandbox: simulated. Writes that cannot be simulated are refused with 403 (admin only)
+// @Tags admin
+// @Accept json
+// @Produce json
+// @Param id path string true "User ID"
+// @Param request body models.SandboxRequest true "Sandbox mode"
+// @Success 200 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/users/{id}/sandbox [put]
+func (h *SandboxHandler) SetUserSandbox(c *gin.Context) {
+	var req models.SandboxRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var user models.User
+	if err := h.db.Where("id = ?", c.Param("id")).First(&user).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "User not found",
+				Code:  "USER_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch user",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if err := h.db.Model(&user).Update("sandbox", *req.Sandbox).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update user",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	user.Sandbox = *req.Sandbox
+
+	adminID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("sandbox", "User", adminID, map[string]interface{}{
+		"user_id": user.ID,
+		"sandbox": user.Sandbox,
+	})
+
+	c.JSON(http.StatusOK, user)
+}
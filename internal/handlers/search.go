@@ -0,0 +1,333 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/indexing"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+)
+
+// searchCandidateLimit bounds how many rows per resource type are ranked for a query
+const searchCandidateLimit = 50
+
+// SearchHandler handles global search across resource types
+type SearchHandler struct {
+	db      *gorm.DB
+	indexer *indexing.Indexer
+}
+
+// NewSearchHandler creates a new search handler. The indexer may be nil when the
+// search index is disabled.
+func NewSearchHandler(db *gorm.DB, indexer *indexing.Indexer) *SearchHandler {
+	return &SearchHandler{
+		db:      db,
+		indexer: indexer,
+	}
+}
+
+// SearchResult represents a single ranked search hit
+type SearchResult struct {
+	ResourceType string      `json:"resourceType"`
+	ID           string      `json:"id"`
+	Display      string      `json:"display"`
+	Score        float64     `json:"score"`
+	Resource     interface{} `json:"resource"`
+}
+
+// SearchBucket holds the ranked results for one resource type
+type SearchBucket struct {
+	Total   int            `json:"total"`
+	Results []SearchResult `json:"results"`
+}
+
+// GlobalSearchResponse represents the typed result buckets of a global search
+type GlobalSearchResponse struct {
+	Query        string        `json:"query"`
+	Patients     SearchBucket  `json:"patients"`
+	Observations SearchBucket  `json:"observations"`
+	Users        *SearchBucket `json:"users,omitempty"`
+}
+
+// Search searches patients, observations and (for admins) users in one call
+// @Summary Global search
+// @Description Search patients by name or identifier, observations by code display and, for admins, users by name or email
+// @Tags search
+// @Produce json
+// @Param q query string true "Search term (at least 2 characters)"
+// @Param limit query int false "Results per bucket (default: 5, max: 25)"
+// @Success 200 {object} GlobalSearchResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/search [get]
+func (h *SearchHandler) Search(c *gin.Context) {
+	q := strings.TrimSpace(c.Query("q"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "5"))
+
+	if len(q) < 2 {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Search term must be at least 2 characters",
+			Code:  "INVALID_SEARCH_TERM",
+		})
+		return
+	}
+	if err := dialect.CheckFilterText("q", q); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid search term",
+			Message: err.Error(),
+			Code:    "INVALID_SEARCH_TERM",
+		})
+		return
+	}
+	if limit < 1 || limit > 25 {
+		limit = 5
+	}
+
+	pattern := dialect.Contains(q)
+	response := GlobalSearchResponse{Query: q}
+	d := dialect.Of(h.db)
+
+	// Patients by name or identifier
+	var patients []models.Patient
+	if err := h.db.Where(d.ILike(d.JSONString("name"))+" OR "+d.ILike(d.JSONString("identifier")), pattern, pattern).
+		Order("created_at DESC").Limit(searchCandidateLimit).Find(&patients).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to search patients",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var patientResults []SearchResult
+	for i := range patients {
+		patient := patients[i]
+		terms := []string{patient.GetFullName()}
+		for _, name := range patient.Name {
+			terms = append(terms, name.Family)
+			terms = append(terms, name.Given...)
+		}
+		for _, identifier := range patient.Identifier {
+			terms = append(terms, identifier.Value)
+		}
+
+		if score := relevance(q, terms); score > 0 {
+			patientResults = append(patientResults, SearchResult{
+				ResourceType: "Patient",
+				ID:           patient.ID,
+				Display:      patient.GetFullName(),
+				Score:        score,
+				Resource:     patient,
+			})
+		}
+	}
+	response.Patients = rankResults(patientResults, limit)
+
+	// Observations by code display; candidates are matched loosely in SQL
+	// and then ranked on code text and display only
+	var observations []models.Observation
+	if err := h.db.Where(d.ILike(d.JSONText("code", "text"))+" OR "+d.ILike(d.JSONString("code", "coding")), pattern, pattern).
+		Order("effective_date_time DESC").Limit(searchCandidateLimit).Find(&observations).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to search observations",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var observationResults []SearchResult
+	for i := range observations {
+		observation := observations[i]
+		terms := []string{observation.Code.Text}
+		for _, coding := range observation.Code.Coding {
+			terms = append(terms, coding.Display)
+		}
+
+		if score := relevance(q, terms); score > 0 {
+			observationResults = append(observationResults, SearchResult{
+				ResourceType: "Observation",
+				ID:           observation.ID,
+				Display:      observation.GetCodeDisplay(),
+				Score:        score,
+				Resource:     observation,
+			})
+		}
+	}
+	response.Observations = rankResults(observationResults, limit)
+
+	// Users are only searchable by administrators
+	if roles, exists := auth.GetUserRoles(c); exists && containsRole(roles, "admin") {
+		var users []models.User
+		if err := h.db.Preload("Roles").
+			Where(d.ILike("email")+" OR "+d.ILike("first_name")+" OR "+d.ILike("last_name"), pattern, pattern, pattern).
+			Order("created_at DESC").Limit(searchCandidateLimit).Find(&users).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to search users",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+
+		var userResults []SearchResult
+		for i := range users {
+			user := users[i]
+			if score := relevance(q, []string{user.GetFullName(), user.FirstName, user.LastName, user.Email}); score > 0 {
+				userResults = append(userResults, SearchResult{
+					ResourceType: "User",
+					ID:           user.ID,
+					Display:      user.GetFullName(),
+					Score:        score,
+					Resource: models.UserInfo{
+						ID:        user.ID,
+						Email:     user.Email,
+						FirstName: user.FirstName,
+						LastName:  user.LastName,
+						Roles:     user.GetRoleNames(),
+						Active:    user.Active,
+					},
+				})
+			}
+		}
+		bucket := rankResults(userResults, limit)
+		response.Users = &bucket
+	}
+
+	c.JSON(http.StatusOK, response)
+}
+
+// IndexedSearch runs a full-text search against the OpenSearch index
+// @Summary Full-text search
+// @Description Fuzzy full-text search of patients and observations backed by the search index
+// @Tags search
+// @Produce json
+// @Param q query string true "Search term"
+// @Param type query string false "Comma-separated resource types (patient, observation)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]indexing.Hit}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 502 {object} ErrorResponse
+// @Failure 503 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/search/index [get]
+func (h *SearchHandler) IndexedSearch(c *gin.Context) {
+	if h.indexer == nil {
+		respondError(c, http.StatusServiceUnavailable, ErrorResponse{
+			Error: "Search index is not enabled",
+			Code:  "SEARCH_INDEX_DISABLED",
+		})
+		return
+	}
+
+	q := strings.TrimSpace(c.Query("q"))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if q == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Search term is required",
+			Code:  "INVALID_SEARCH_TERM",
+		})
+		return
+	}
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var types []string
+	if t := strings.TrimSpace(c.Query("type")); t != "" {
+		types = strings.Split(t, ",")
+	}
+
+	hits, total, err := h.indexer.Search(c.Request.Context(), q, types, (page-1)*limit, limit)
+	if err != nil {
+		respondError(c, http.StatusBadGateway, ErrorResponse{
+			Error:   "Search index query failed",
+			Message: err.Error(),
+			Code:    "SEARCH_INDEX_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, hits, total, page, limit)
+}
+
+// relevance scores how well the query matches the best of the given terms.
+// Exact matches rank above prefix matches, which rank above substring matches.
+func relevance(query string, terms []string) float64 {
+	query = strings.ToLower(query)
+	best := 0.0
+
+	for _, term := range terms {
+		term = strings.ToLower(strings.TrimSpace(term))
+		if term == "" {
+			continue
+		}
+
+		score := 0.0
+		switch {
+		case term == query:
+			score = 100
+		case strings.HasPrefix(term, query):
+			score = 75
+		case strings.Contains(" "+term, " "+query):
+			score = 60 // Word prefix within a multi-word term
+		case strings.Contains(term, query):
+			score = 25
+		}
+
+		// Prefer shorter terms so closer matches rank higher within a tier
+		if score > 0 {
+			score += 10 * float64(len(query)) / float64(len(term))
+		}
+
+		if score > best {
+			best = score
+		}
+	}
+
+	return best
+}
+
+// rankResults sorts results by descending score and truncates them to limit
+func rankResults(results []SearchResult, limit int) SearchBucket {
+	sort.SliceStable(results, func(i, j int) bool {
+		return results[i].Score > results[j].Score
+	})
+
+	bucket := SearchBucket{Total: len(results), Results: results}
+	if len(bucket.Results) > limit {
+		bucket.Results = bucket.Results[:limit]
+	}
+	if bucket.Results == nil {
+		bucket.Results = []SearchResult{}
+	}
+
+	return bucket
+}
+
+// containsRole reports whether role is in roles
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,242 @@
+package handlers
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/ucum"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// searchPrefixes maps FHIR search comparison prefixes to SQL operators
+var searchPrefixes = map[string]string{
+	"eq": "=",
+	"ne": "<>",
+	"gt": ">",
+	"lt": "<",
+	"ge": ">=",
+	"le": "<=",
+}
+
+// splitSearchPrefix splits a FHIR prefixed search value such as "ge1950-01-01" into its
+// prefix and value. Values without a prefix compare for equality.
+func splitSearchPrefix(value string) (string, string) {
+	if len(value) > 2 {
+		if _, ok := searchPrefixes[value[:2]]; ok {
+			return value[:2], value[2:]
+		}
+	}
+	return "eq", value
+}
+
+// applyBirthDateFilter filters patients by a prefixed birth date (YYYY-MM-DD)
+func applyBirthDateFilter(query *gorm.DB, value string) (*gorm.DB, error) {
+	prefix, date := splitSearchPrefix(value)
+	if _, err := time.Parse("2006-01-02", date); err != nil {
+		return nil, fmt.Errorf("birthdate must be a YYYY-MM-DD date with an optional eq, ne, gt, lt, ge or le prefix")
+	}
+
+	d := dialect.Of(query)
+	return query.Where(d.Date("birth_date")+" "+searchPrefixes[prefix]+" "+d.Date("?"), date), nil
+}
+
+// applyAgeFilter filters patients by a prefixed age in whole years. Ages are converted to
+// birth date bounds relative to the current date, so "ge65" matches anyone whose 65th
+// birthday is today or earlier.
+func applyAgeFilter(query *gorm.DB, value string) (*gorm.DB, error) {
+	prefix, years := splitSearchPrefix(value)
+	age, err := strconv.Atoi(years)
+	if err != nil || age < 0 || age > 150 {
+		return nil, fmt.Errorf("age must be a whole number of years with an optional eq, ne, gt, lt, ge or le prefix")
+	}
+
+	// The bounds are computed here rather than in SQL, where interval arithmetic
+	// differs between databases
+	d := dialect.Of(query)
+	today := time.Now().UTC()
+	yearsAgo := func(n int) string {
+		return today.AddDate(-n, 0, 0).Format("2006-01-02")
+	}
+
+	// Born on or before this bound means at least n years old
+	atLeast := d.Date("birth_date") + " <= " + d.Date("?")
+	// Born after this bound means at most n years old, i.e. younger than n+1
+	atMost := d.Date("birth_date") + " > " + d.Date("?")
+
+	switch prefix {
+	case "ge":
+		return query.Where(atLeast, yearsAgo(age)), nil
+	case "gt":
+		return query.Where(atLeast, yearsAgo(age+1)), nil
+	case "le":
+		return query.Where(atMost, yearsAgo(age+1)), nil
+	case "lt":
+		return query.Where(atMost, yearsAgo(age)), nil
+	case "ne":
+		return query.Where("NOT ("+atLeast+" AND "+atMost+")", yearsAgo(age), yearsAgo(age+1)), nil
+	default:
+		return query.Where(atLeast+" AND "+atMost, yearsAgo(age), yearsAgo(age+1)), nil
+	}
+}
+
+// ucumSystem is the code system of UCUM units
+const ucumSystem = "http://unitsofmeasure.org"
+
+// unitCacheTTL is how long the list of stored quantity units is reused
+const unitCacheTTL = time.Minute
+
+// quantityUnits caches the distinct units observations store value quantities in,
+// which a unit-aware search expands to
+type quantityUnits struct {
+	mu     sync.Mutex
+	units  []string
+	loaded time.Time
+}
+
+// list returns the stored units, reading them through the value quantity index
+// when the cache has expired
+func (q *quantityUnits) list(db *gorm.DB) ([]string, error) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	if q.units != nil && time.Since(q.loaded) < unitCacheTTL {
+		return q.units, nil
+	}
+	units := []string{}
+	if err := db.Session(&gorm.Session{NewDB: true}).Raw("SELECT DISTINCT " + models.QuantityUnitExpression +
+		" FROM observations WHERE " + models.QuantityUnitExpression + " IS NOT NULL").Scan(&units).Error; err != nil {
+		return nil, err
+	}
+	q.units = units
+	q.loaded = time.Now()
+	return units, nil
+}
+
+// applyValueQuantityFilter filters observations by a prefixed value quantity in the
+// FHIR form [prefix]number[|system|code] or [prefix]number|unit, e.g. "gt5.5|mmol/L".
+// A UCUM unit also matches values recorded in any commensurable unit, converted for
+// the comparison, so "ge1|g/L" matches 100 mg/dL. Other units match exactly.
+func applyValueQuantityFilter(query *gorm.DB, value string, units *quantityUnits) (*gorm.DB, error) {
+	parts := strings.Split(value, "|")
+	if len(parts) > 3 {
+		return nil, fmt.Errorf("value-quantity must be [prefix]number, [prefix]number|unit or [prefix]number|system|code")
+	}
+	prefix, number := splitSearchPrefix(parts[0])
+	amount, err := strconv.ParseFloat(number, 64)
+	if err != nil || math.IsNaN(amount) || math.IsInf(amount, 0) {
+		return nil, fmt.Errorf("value-quantity must start with a number with an optional eq, ne, gt, lt, ge or le prefix")
+	}
+
+	var system, code string
+	switch len(parts) {
+	case 2:
+		code = strings.TrimSpace(parts[1])
+	case 3:
+		system, code = strings.TrimSpace(parts[1]), strings.TrimSpace(parts[2])
+	}
+
+	if code == "" {
+		if system != "" {
+			return nil, fmt.Errorf("value-quantity with a system must also give a code")
+		}
+		condition, args := quantityComparison(prefix, number, amount, 1, 0)
+		return query.Where(condition, args...), nil
+	}
+	if system != "" && system != ucumSystem {
+		condition, args := quantityComparison(prefix, number, amount, 1, 0)
+		return query.Where("value_quantity_system = ? AND value_quantity_code = ? AND ("+condition+")",
+			append([]interface{}{system, code}, args...)...), nil
+	}
+
+	searched, err := ucum.Parse(code)
+	if err != nil {
+		// Not a UCUM code; compare values recorded in exactly this unit
+		condition, args := quantityComparison(prefix, number, amount, 1, 0)
+		return query.Where(models.QuantityUnitExpression+" = ? AND ("+condition+")",
+			append([]interface{}{code}, args...)...), nil
+	}
+
+	stored, err := units.list(query)
+	if err != nil {
+		// Still match values recorded in the searched unit itself
+		logger.Warn("Failed to list quantity units", zap.Error(err))
+	}
+
+	// One disjunct per commensurable unit, each served by the value quantity index
+	var conditions []string
+	var args []interface{}
+	seen := map[string]bool{}
+	for _, unit := range append([]string{code}, stored...) {
+		if seen[unit] {
+			continue
+		}
+		seen[unit] = true
+
+		target, err := ucum.Parse(unit)
+		if err != nil || !searched.Compatible(target) {
+			continue
+		}
+		scale, _ := searched.ConvertTo(1, target)
+		origin, _ := searched.ConvertTo(0, target)
+		condition, unitArgs := quantityComparison(prefix, number, amount, scale-origin, origin)
+		conditions = append(conditions, "("+models.QuantityUnitExpression+" = ? AND ("+condition+"))")
+		args = append(append(args, unit), unitArgs...)
+	}
+	return query.Where(strings.Join(conditions, " OR "), args...), nil
+}
+
+// quantityComparison returns the condition comparing value_quantity_value to amount
+// converted by scale and offset. Equality follows FHIR and matches the range implied
+// by the precision the number was written with, so "eq5.5" matches [5.45, 5.55).
+func quantityComparison(prefix, number string, amount, scale, offset float64) (string, []interface{}) {
+	convert := func(v float64) float64 {
+		// Rounded so conversion error does not move a bound past an exact value
+		converted, _ := strconv.ParseFloat(strconv.FormatFloat(v*scale+offset, 'g', 12, 64), 64)
+		return converted
+	}
+	switch prefix {
+	case "eq", "ne":
+		half := 0.5 * math.Pow(10, -float64(decimalPlaces(number)))
+		low, high := convert(amount-half), convert(amount+half)
+		if prefix == "ne" {
+			return "value_quantity_value < ? OR value_quantity_value >= ?", []interface{}{low, high}
+		}
+		return "value_quantity_value >= ? AND value_quantity_value < ?", []interface{}{low, high}
+	default:
+		return "value_quantity_value " + searchPrefixes[prefix] + " ?", []interface{}{convert(amount)}
+	}
+}
+
+// decimalPlaces returns the number of decimal places a number was written with,
+// negative for numbers in exponent form such as 5e2
+func decimalPlaces(number string) int {
+	mantissa, exponent := number, 0
+	if i := strings.IndexAny(number, "eE"); i >= 0 {
+		mantissa = number[:i]
+		exponent, _ = strconv.Atoi(number[i+1:])
+	}
+	places := 0
+	if i := strings.Index(mantissa, "."); i >= 0 {
+		places = len(mantissa) - i - 1
+	}
+	return places - exponent
+}
+
+// queryValues returns all non-empty values of a repeatable query parameter
+func queryValues(values []string) []string {
+	var result []string
+	for _, value := range values {
+		if value = strings.TrimSpace(value); value != "" {
+			result = append(result, value)
+		}
+	}
+	return result
+}
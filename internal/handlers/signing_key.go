@@ -0,0 +1,100 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// SigningKeyHandler handles JWT signing key rotation
+type SigningKeyHandler struct {
+	db      *gorm.DB
+	rotator *auth.KeyRotator
+}
+
+// NewSigningKeyHandler creates a new signing key handler
+func NewSigningKeyHandler(db *gorm.DB, rotator *auth.KeyRotator) *SigningKeyHandler {
+	return &SigningKeyHandler{
+		db:      db,
+		rotator: rotator,
+	}
+}
+
+// SigningKeysResponse lists the signing keys created by rotations
+type SigningKeysResponse struct {
+	CurrentKeyID          string              `json:"currentKeyId"`
+	RotationWindowSeconds int64               `json:"rotationWindowSeconds"`
+	Keys                  []models.SigningKey `json:"keys"`
+}
+
+// GetSigningKeys lists the signing keys
+// @Summary Get signing keys
+// @Description List JWT signing keys and their status, newest first. Secrets are never returned (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} SigningKeysResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/signing-keys [get]
+func (h *SigningKeyHandler) GetSigningKeys(c *gin.Context) {
+	var keys []models.SigningKey
+	if err := h.db.Order("created_at DESC").Find(&keys).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to retrieve signing keys",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, SigningKeysResponse{
+		CurrentKeyID:          h.rotator.CurrentKeyID(),
+		RotationWindowSeconds: int64(h.rotator.Window().Seconds()),
+		Keys:                  keys,
+	})
+}
+
+// RotateSigningKey rotates the JWT signing key
+// @Summary Rotate signing key
+// @Description Sign new tokens with a new random key. Tokens signed with the replaced key stay valid for the rotation window, after which the key is retired and its tokens are rejected (admin only)
+// @Tags admin
+// @Produce json
+// @Success 201 {object} models.SigningKey
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/signing-keys/rotate [post]
+func (h *SigningKeyHandler) RotateSigningKey(c *gin.Context) {
+	userID, _ := auth.GetUserID(c)
+	key, err := h.rotator.Rotate(c.Request.Context(), userID)
+	if errors.Is(err, auth.ErrRotationInProgress) {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: err.Error(),
+			Code:  "ROTATION_IN_PROGRESS",
+		})
+		return
+	}
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to rotate signing key",
+			Message: err.Error(),
+			Code:    "ROTATION_FAILED",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("rotate", "SigningKey", userID, map[string]interface{}{
+		"key_id": key.ID,
+	})
+
+	c.JSON(http.StatusCreated, key)
+}
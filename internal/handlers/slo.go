@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/metrics"
+)
+
+// SLOHandler reports the compliance of routes with their service level objectives
+type SLOHandler struct {
+	tracker *metrics.SLOTracker
+}
+
+// NewSLOHandler creates a new SLO handler
+func NewSLOHandler(tracker *metrics.SLOTracker) *SLOHandler {
+	return &SLOHandler{tracker: tracker}
+}
+
+// SLOResponse lists the compliance of routes with their objectives
+type SLOResponse struct {
+	Data []metrics.RouteStatus `json:"data"`
+}
+
+// GetSLOs lists the burn rates of every route
+// @Summary Get SLO burn rates
+// @Description List the latency and error budget burn rates of every route served by this instance over the 5m, 30m, 1h and 6h windows, with the objective of its route group. A burn rate of 1 spends the budget exactly over the SLO period (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} SLOResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/slos [get]
+func (h *SLOHandler) GetSLOs(c *gin.Context) {
+	c.JSON(http.StatusOK, SLOResponse{Data: h.tracker.Statuses()})
+}
+
+// GetSLOViolations lists the routes violating their objective
+// @Summary Get SLO violations
+// @Description List the routes burning their error budget faster than 14.4 over both the last hour and 5 minutes, or faster than 6 over both the last 6 hours and 30 minutes, on this instance (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} SLOResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/slos/violations [get]
+func (h *SLOHandler) GetSLOViolations(c *gin.Context) {
+	c.JSON(http.StatusOK, SLOResponse{Data: h.tracker.Violations()})
+}
@@ -0,0 +1,726 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/notify"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// pendingObservationStatuses are the statuses of results not yet verified, which are
+// cancelled when their specimen is rejected
+var pendingObservationStatuses = []string{"registered", "preliminary", "unknown"}
+
+// SpecimenHandler handles HTTP requests for specimens
+type SpecimenHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	email     notify.Sender
+}
+
+// NewSpecimenHandler creates a new specimen handler. Ordering practitioners are
+// notified of rejected specimens by email.
+func NewSpecimenHandler(db *gorm.DB, email notify.Sender) *SpecimenHandler {
+	return &SpecimenHandler{
+		db:        db,
+		validator: validator.New(),
+		email:     email,
+	}
+}
+
+// SpecimenRejection is the outcome of rejecting a specimen
+type SpecimenRejection struct {
+	Specimen              models.Specimen `json:"specimen"`
+	CancelledObservations []string        `json:"cancelledObservations"`
+	Notified              bool            `json:"notified"` // Whether the ordering practitioner was emailed
+}
+
+// CreateSpecimen registers a received specimen
+// @Summary Create specimen
+// @Description Register a specimen received by the laboratory with how, when and by whom it was collected and the containers it came in. A Practitioner/{id} or User/{id} collector is resolved as the performers of observations are. Observations refer to it as Specimen/{id}.
+// @Tags specimens
+// @Accept json
+// @Produce json
+// @Param specimen body models.Specimen true "Specimen"
+// @Success 201 {object} models.Specimen
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/specimens [post]
+func (h *SpecimenHandler) CreateSpecimen(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var specimen models.Specimen
+	if err := c.ShouldBindJSON(&specimen); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(specimen); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	// Rejection goes through the reject endpoint, so the linked results are handled
+	if specimen.Status == models.SpecimenStatusUnsatisfactory {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "specimens are rejected with POST /specimens/{id}/reject",
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+	specimen.RejectionReason, specimen.RejectionComment = "", ""
+	specimen.RejectedAt, specimen.RejectedBy = nil, ""
+	if !validCollectionTime(c, &specimen) {
+		return
+	}
+
+	// Validate that the referenced patient exists
+	if specimen.Subject.Reference != "" {
+		patientID := strings.TrimPrefix(specimen.Subject.Reference, "Patient/")
+		var patient models.Patient
+		if err := db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				respondError(c, http.StatusBadRequest, ErrorResponse{
+					Error: "Referenced patient not found",
+					Code:  "PATIENT_NOT_FOUND",
+				})
+				return
+			}
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to validate patient reference",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+	}
+
+	var ok bool
+	if specimen.Collector, ok = resolveCollector(c, db, specimen.Collector); !ok {
+		return
+	}
+	if !accessionAvailable(c, db, specimen.Accession, "") {
+		return
+	}
+
+	specimen.ID = ""
+	if userID, exists := auth.GetUserID(c); exists {
+		specimen.CreatedBy = userID
+	}
+	if err := db.Create(&specimen).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create specimen",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	logger.LogAuditEvent("create", "Specimen", specimen.CreatedBy, map[string]interface{}{
+		"specimen_id": specimen.ID,
+		"accession":   specimen.Accession,
+	})
+
+	c.JSON(http.StatusCreated, specimen)
+}
+
+// GetSpecimens lists specimens
+// @Summary Get specimens
+// @Description List specimens, most recently received first
+// @Tags specimens
+// @Produce json
+// @Param patient query string false "Filter by patient ID"
+// @Param status query string false "Filter by status (available, unavailable, unsatisfactory, entered-in-error)"
+// @Param department query string false "Filter by department"
+// @Param accession query string false "Filter by accession"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.Specimen}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/specimens [get]
+func (h *SpecimenHandler) GetSpecimens(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.Specimen{})
+	for _, column := range []string{"status", "department", "accession"} {
+		if value := c.Query(column); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+	if patient := strings.TrimSpace(c.Query("patient")); patient != "" {
+		query = query.Where("subject_reference = ?", "Patient/"+patient)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count specimens",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var specimens []models.Specimen
+	if err := query.Order("received_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&specimens).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch specimens",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, specimens, total, page, limit)
+}
+
+// GetSpecimen retrieves a specimen by ID
+// @Summary Get specimen
+// @Description Get a specific specimen by its ID
+// @Tags specimens
+// @Produce json
+// @Param id path string true "Specimen ID"
+// @Success 200 {object} models.Specimen
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/specimens/{id} [get]
+func (h *SpecimenHandler) GetSpecimen(c *gin.Context) {
+	var specimen models.Specimen
+	if !findSpecimen(c, readDB(c, h.db), &specimen) {
+		return
+	}
+
+	c.JSON(http.StatusOK, specimen)
+}
+
+// UpdateSpecimen replaces a specimen
+// @Summary Update specimen
+// @Description Replace the accession, type, department, ordering practitioner, collection time, collector and containers of a specimen, or mark it unavailable or entered-in-error. Specimens are rejected with POST /specimens/{id}/reject, and a rejected specimen keeps its status. The subject of a specimen cannot change once set.
+// @Tags specimens
+// @Accept json
+// @Produce json
+// @Param id path string true "Specimen ID"
+// @Param specimen body models.Specimen true "Specimen"
+// @Success 200 {object} models.Specimen
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/specimens/{id} [put]
+func (h *SpecimenHandler) UpdateSpecimen(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var req models.Specimen
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var specimen models.Specimen
+	if !findSpecimen(c, db, &specimen) {
+		return
+	}
+
+	status := specimen.Status
+	if req.Status != "" && req.Status != specimen.Status {
+		if specimen.Status == models.SpecimenStatusUnsatisfactory {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error: "Specimen already rejected",
+				Code:  "SPECIMEN_REJECTED",
+			})
+			return
+		}
+		if req.Status == models.SpecimenStatusUnsatisfactory {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: "specimens are rejected with POST /specimens/{id}/reject",
+				Code:    "VALIDATION_FAILED",
+			})
+			return
+		}
+		specimen.Status = req.Status
+	}
+
+	columns := []string{"accession", "status", "type", "department", "ordered_by", "collected_at", "collector", "container", "updated_at"}
+	subject := strings.TrimPrefix(req.Subject.Reference, "Patient/")
+	if subject != "" && subject != strings.TrimPrefix(specimen.Subject.Reference, "Patient/") {
+		if specimen.Subject.Reference != "" {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: "the subject of a specimen cannot change",
+				Code:    "SUBJECT_MISMATCH",
+			})
+			return
+		}
+		var patient models.Patient
+		if err := db.Where("id = ?", subject).First(&patient).Error; err != nil {
+			if err == gorm.ErrRecordNotFound {
+				respondError(c, http.StatusBadRequest, ErrorResponse{
+					Error: "Referenced patient not found",
+					Code:  "PATIENT_NOT_FOUND",
+				})
+				return
+			}
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to validate patient reference",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		specimen.Subject = req.Subject
+		columns = append(columns, "subject_reference", "subject_type", "subject_display")
+	}
+
+	collector, ok := resolveCollector(c, db, req.Collector)
+	if !ok {
+		return
+	}
+	if req.Accession != specimen.Accession && !accessionAvailable(c, db, req.Accession, specimen.ID) {
+		return
+	}
+
+	specimen.Accession = req.Accession
+	specimen.Type = req.Type
+	specimen.Department = req.Department
+	specimen.OrderedBy = req.OrderedBy
+	specimen.CollectedAt = req.CollectedAt
+	specimen.Collector = collector
+	specimen.Container = req.Container
+	if !validCollectionTime(c, &specimen) {
+		return
+	}
+
+	// Only a specimen still in the status it was read in is updated, so a rejection
+	// made meanwhile is not undone
+	result := db.Model(&specimen).Where("status = ?", status).Select(columns).Updates(&specimen)
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update specimen",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Specimen was rejected or modified by another request",
+			Code:  "VERSION_CONFLICT",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "Specimen", userID, map[string]interface{}{
+		"specimen_id": specimen.ID,
+		"accession":   specimen.Accession,
+		"status":      specimen.Status,
+	})
+
+	c.JSON(http.StatusOK, specimen)
+}
+
+// DeleteSpecimen removes a specimen
+// @Summary Delete specimen
+// @Description Delete a specimen registered by mistake (admin only). A specimen observations refer to cannot be deleted; mark it entered-in-error instead.
+// @Tags specimens
+// @Param id path string true "Specimen ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/specimens/{id} [delete]
+func (h *SpecimenHandler) DeleteSpecimen(c *gin.Context) {
+	db := writeDB(c, h.db)
+	var specimen models.Specimen
+	if !findSpecimen(c, db, &specimen) {
+		return
+	}
+
+	// Observations in the trash count too, so restoring one does not leave it dangling
+	var linked int64
+	if err := db.Unscoped().Model(&models.Observation{}).Where("specimen_reference = ?", "Specimen/"+specimen.ID).
+		Count(&linked).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check specimen references",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if linked > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Specimen is in use",
+			Message: fmt.Sprintf("%d observation(s) refer to the specimen; mark it entered-in-error instead", linked),
+			Code:    "SPECIMEN_IN_USE",
+		})
+		return
+	}
+
+	if err := db.Delete(&specimen).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete specimen",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "Specimen", userID, map[string]interface{}{
+		"specimen_id": specimen.ID,
+		"accession":   specimen.Accession,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// RejectSpecimen rejects a specimen as unsuitable for testing
+// @Summary Reject specimen
+// @Description Reject a specimen with a reason from the HL7 v2 table 0490, such as RH (hemolysis), QS (quantity not sufficient) or RM (labeling). Results on the specimen that are not yet verified are cancelled and the ordering practitioner is emailed so a new specimen can be collected. Verified results are left for review.
+// @Tags specimens
+// @Accept json
+// @Produce json
+// @Param id path string true "Specimen ID"
+// @Param request body models.SpecimenRejectRequest true "Rejection reason"
+// @Success 200 {object} SpecimenRejection
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/specimens/{id}/reject [post]
+func (h *SpecimenHandler) RejectSpecimen(c *gin.Context) {
+	var req models.SpecimenRejectRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	var specimen models.Specimen
+	if !findSpecimen(c, h.db, &specimen) {
+		return
+	}
+
+	alreadyRejected := func() {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Specimen already rejected",
+			Code:  "SPECIMEN_REJECTED",
+		})
+	}
+	if specimen.Status == models.SpecimenStatusUnsatisfactory {
+		alreadyRejected()
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	now := time.Now().UTC()
+	reason := models.SpecimenRejectReasons[req.Reason]
+	cancelled := []string{}
+	rejected := false
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		// Only a specimen not yet rejected is updated, so of concurrent rejections the first wins
+		result := tx.Model(&models.Specimen{}).
+			Where("id = ? AND status <> ?", specimen.ID, models.SpecimenStatusUnsatisfactory).
+			Updates(map[string]interface{}{
+				"status":            models.SpecimenStatusUnsatisfactory,
+				"rejection_reason":  req.Reason,
+				"rejection_comment": req.Comment,
+				"rejected_at":       now,
+				"rejected_by":       userID,
+			})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return nil
+		}
+		rejected = true
+
+		if err := tx.Model(&models.Observation{}).
+			Where("specimen_reference = ? AND status IN ?", "Specimen/"+specimen.ID, pendingObservationStatuses).
+			Pluck("id", &cancelled).Error; err != nil {
+			return err
+		}
+		if len(cancelled) == 0 {
+			return nil
+		}
+		// A batch update bypasses the observation hooks, so the events are recorded here
+		if err := tx.Model(&models.Observation{}).Where("id IN ?", cancelled).
+			Updates(map[string]interface{}{
+				"status":             "cancelled",
+				"absent_reason_text": "Specimen rejected: " + reason,
+			}).Error; err != nil {
+			return err
+		}
+		return models.RecordEvents(tx, "Observation", cancelled, models.EventActionUpdated)
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to reject specimen",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if !rejected {
+		alreadyRejected()
+		return
+	}
+
+	specimen.Status = models.SpecimenStatusUnsatisfactory
+	specimen.RejectionReason = req.Reason
+	specimen.RejectionComment = req.Comment
+	specimen.RejectedAt = &now
+	specimen.RejectedBy = userID
+
+	logger.LogAuditEvent("reject", "Specimen", userID, map[string]interface{}{
+		"specimen_id":            specimen.ID,
+		"reason":                 req.Reason,
+		"cancelled_observations": cancelled,
+	})
+
+	c.JSON(http.StatusOK, SpecimenRejection{
+		Specimen:              specimen,
+		CancelledObservations: cancelled,
+		Notified:              h.notifyRejection(c, &specimen, reason),
+	})
+}
+
+// notifyRejection emails the ordering practitioner of a rejected specimen. A failed
+// notification is logged but does not undo the rejection.
+func (h *SpecimenHandler) notifyRejection(c *gin.Context, specimen *models.Specimen, reason string) bool {
+	if specimen.OrderedBy == "" {
+		return false
+	}
+
+	var practitioner models.User
+	if err := h.db.Where("id = ?", specimen.OrderedBy).First(&practitioner).Error; err != nil {
+		logger.Warn("Failed to find ordering practitioner of rejected specimen",
+			zap.String("specimen_id", specimen.ID),
+			zap.String("ordered_by", specimen.OrderedBy),
+			zap.Error(err),
+		)
+		return false
+	}
+
+	body := fmt.Sprintf("Specimen %s has been rejected by the laboratory: %s.\n", specimen.Accession, reason)
+	if specimen.RejectionComment != "" {
+		body += "\n" + specimen.RejectionComment + "\n"
+	}
+	body += "\nResults that were pending on this specimen have been cancelled. Please arrange for a new specimen to be collected.\n"
+
+	if err := h.email.Send(c.Request.Context(), notify.Message{
+		To:      practitioner.Email,
+		Subject: "Specimen rejected: " + specimen.Accession,
+		Body:    body,
+	}); err != nil {
+		logger.Warn("Failed to notify ordering practitioner of rejected specimen",
+			zap.String("specimen_id", specimen.ID),
+			zap.Error(err),
+		)
+		return false
+	}
+	return true
+}
+
+// findSpecimen loads the specimen in the path, writing the error response when it is
+// not found
+func findSpecimen(c *gin.Context, db *gorm.DB, specimen *models.Specimen) bool {
+	if err := db.Where("id = ?", c.Param("id")).First(specimen).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Specimen not found",
+				Code:  "SPECIMEN_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch specimen",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
+
+// accessionAvailable reports whether no specimen other than the one with the given ID
+// has an accession, writing the error response when another has
+func accessionAvailable(c *gin.Context, db *gorm.DB, accession, id string) bool {
+	var existing int64
+	if err := db.Model(&models.Specimen{}).Where("accession = ? AND id <> ?", accession, id).Count(&existing).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to check accession",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	if existing > 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "A specimen with this accession already exists",
+			Code:  "ACCESSION_EXISTS",
+		})
+		return false
+	}
+	return true
+}
+
+// validCollectionTime reports whether a specimen was collected before it was
+// received, writing the error response when it was not
+func validCollectionTime(c *gin.Context, specimen *models.Specimen) bool {
+	if specimen.CollectedAt != nil && !specimen.ReceivedAt.IsZero() && specimen.CollectedAt.After(specimen.ReceivedAt) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "collectedAt must not be after receivedAt",
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+	return true
+}
+
+// resolveCollector resolves the collector of a specimen as the performers of an
+// observation are, writing the error response when a practitioner does not exist
+func resolveCollector(c *gin.Context, db *gorm.DB, collector *models.Reference) (*models.Reference, bool) {
+	if collector == nil {
+		return nil, true
+	}
+	resolved, ok := resolvePerformers(c, db, []models.Reference{*collector})
+	if !ok {
+		return nil, false
+	}
+	return &resolved[0], true
+}
+
+// resolveSpecimenReference checks the specimen an observation of a patient refers to,
+// writing the error response when it cannot be used. A Specimen/{id} reference must
+// name a specimen of the same patient that is neither rejected nor entered in error;
+// it is returned with the specimen's accession as its display. References without a
+// reference, e.g. by identifier only, are returned as they are.
+func resolveSpecimenReference(c *gin.Context, db *gorm.DB, patientID string, ref *models.Reference) (*models.Reference, bool) {
+	if ref == nil || ref.Reference == "" {
+		return ref, true
+	}
+	id, ok := strings.CutPrefix(ref.Reference, "Specimen/")
+	if !ok || id == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid specimen reference",
+			Message: "specimen.reference must be of the form Specimen/{id}",
+			Code:    "INVALID_SPECIMEN_REFERENCE",
+		})
+		return nil, false
+	}
+
+	var specimen models.Specimen
+	if err := db.Where("id = ?", id).First(&specimen).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Referenced specimen not found",
+				Message: ref.Reference + " does not exist",
+				Code:    "SPECIMEN_NOT_FOUND",
+			})
+			return nil, false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate specimen reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return nil, false
+	}
+
+	var problem string
+	switch {
+	case specimen.Subject.Reference != "" && patientID != "" && specimen.Subject.Reference != "Patient/"+patientID:
+		problem = "the specimen was collected from another patient"
+	case specimen.Status == models.SpecimenStatusUnsatisfactory:
+		problem = "the specimen was rejected by the laboratory"
+	case specimen.Status == models.SpecimenStatusEnteredInError:
+		problem = "the specimen was entered in error"
+	}
+	if problem != "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid specimen reference",
+			Message: problem,
+			Code:    "INVALID_SPECIMEN_REFERENCE",
+		})
+		return nil, false
+	}
+
+	return &models.Reference{
+		Reference:  "Specimen/" + specimen.ID,
+		Type:       "Specimen",
+		Identifier: ref.Identifier,
+		Display:    specimen.Accession,
+	}, true
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/disclosure"
+	"gorm.io/gorm"
+)
+
+// SpecimenRejectionCount counts the specimens rejected for one reason
+type SpecimenRejectionCount struct {
+	Reason     string `json:"reason"`
+	Display    string `json:"display"`
+	Count      int64  `json:"count"`
+	Suppressed bool   `json:"suppressed,omitempty"` // Count withheld by disclosure control
+}
+
+// SpecimenRejectionRate summarizes the rejected specimens of a department
+type SpecimenRejectionRate struct {
+	Department string                   `json:"department"`
+	Total      int64                    `json:"total"`
+	Rejected   int64                    `json:"rejected"`
+	Percentage float64                  `json:"percentage"`
+	Reasons    []SpecimenRejectionCount `json:"reasons" gorm:"-"`
+	Patients   int64                    `json:"-"`
+	Suppressed bool                     `json:"suppressed,omitempty" gorm:"-"` // Counts withheld by disclosure control
+}
+
+// SpecimenRejectionReport represents specimen rejection rates
+type SpecimenRejectionReport struct {
+	From        string                  `json:"from,omitempty"`
+	To          string                  `json:"to,omitempty"`
+	Total       int64                   `json:"total"`
+	Rejected    int64                   `json:"rejected"`
+	Percentage  float64                 `json:"percentage"`
+	Departments []SpecimenRejectionRate `json:"departments"`
+	Disclosure  *disclosure.Policy      `json:"disclosure,omitempty"` // Disclosure control applied to the counts
+	GeneratedAt time.Time               `json:"generatedAt"`
+}
+
+// GetSpecimenRejections reports specimen rejection rates
+// @Summary Specimen rejection report
+// @Description Share of received specimens rejected per department, broken down by rejection reason. Specimens entered in error are not counted. For research users, departments of fewer patients than the endpoint's minimum cell size, or with fewer rejections, are suppressed and left out of the totals, small reason counts are suppressed, and released counts may carry noise.
+// @Tags analytics
+// @Produce json
+// @Param department query string false "Filter by department"
+// @Param from query string false "Filter by received date from (ISO 8601)"
+// @Param to query string false "Filter by received date to (ISO 8601)"
+// @Success 200 {object} SpecimenRejectionReport
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/analytics/specimen-rejections [get]
+func (h *AnalyticsHandler) GetSpecimenRejections(c *gin.Context) {
+	report := SpecimenRejectionReport{
+		From:        strings.TrimSpace(c.Query("from")),
+		To:          strings.TrimSpace(c.Query("to")),
+		Departments: []SpecimenRejectionRate{},
+		GeneratedAt: time.Now().UTC(),
+	}
+	department := strings.TrimSpace(c.Query("department"))
+
+	db := readDB(c, h.db)
+	base := func() *gorm.DB {
+		query := db.Model(&models.Specimen{}).Where("status <> ?", models.SpecimenStatusEnteredInError)
+		if department != "" {
+			query = query.Where("department = ?", department)
+		}
+		if report.From != "" {
+			query = query.Where("received_at >= ?", report.From)
+		}
+		if report.To != "" {
+			query = query.Where("received_at <= ?", report.To)
+		}
+		return query
+	}
+
+	failed := func(err error) {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to aggregate specimens",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+	}
+
+	if err := base().
+		Select("COALESCE(department, '') AS department, COUNT(*) AS total, "+
+			"COUNT(DISTINCT subject_reference) AS patients, "+
+			"SUM(CASE WHEN status = ? THEN 1 ELSE 0 END) AS rejected", models.SpecimenStatusUnsatisfactory).
+		Group("COALESCE(department, '')").Order("department").
+		Scan(&report.Departments).Error; err != nil {
+		failed(err)
+		return
+	}
+
+	var reasons []struct {
+		Department string
+		Reason     string
+		Count      int64
+	}
+	if err := base().Where("status = ?", models.SpecimenStatusUnsatisfactory).
+		Select("COALESCE(department, '') AS department, rejection_reason AS reason, COUNT(*) AS count").
+		Group("COALESCE(department, ''), rejection_reason").Order("count DESC, reason").
+		Scan(&reasons).Error; err != nil {
+		failed(err)
+		return
+	}
+
+	byDepartment := make(map[string]*SpecimenRejectionRate, len(report.Departments))
+	for i := range report.Departments {
+		rate := &report.Departments[i]
+		rate.Reasons = []SpecimenRejectionCount{}
+		byDepartment[rate.Department] = rate
+	}
+	for _, r := range reasons {
+		if rate, ok := byDepartment[r.Department]; ok {
+			rate.Reasons = append(rate.Reasons, SpecimenRejectionCount{
+				Reason:  r.Reason,
+				Display: models.SpecimenRejectReasons[r.Reason],
+				Count:   r.Count,
+			})
+		}
+	}
+
+	// The totals add up the released departments, so a suppressed one cannot be
+	// recovered from them
+	policy := h.disclosurePolicy(c, "specimen-rejections")
+	if policy != nil {
+		report.Disclosure = policy
+	}
+	for i := range report.Departments {
+		rate := &report.Departments[i]
+		if policy != nil {
+			rate.applyDisclosure(*policy)
+		}
+		rate.Percentage = percentage(rate.Rejected, rate.Total)
+		report.Total += rate.Total
+		report.Rejected += rate.Rejected
+	}
+	report.Percentage = percentage(report.Rejected, report.Total)
+
+	c.JSON(http.StatusOK, report)
+}
+
+// applyDisclosure suppresses a department of too few patients or rejections, and
+// otherwise suppresses its small reason counts and perturbs the rest
+func (r *SpecimenRejectionRate) applyDisclosure(policy disclosure.Policy) {
+	if policy.Suppressed(r.Patients) || policy.Suppressed(r.Rejected) {
+		r.Total, r.Rejected = 0, 0
+		r.Reasons = []SpecimenRejectionCount{}
+		r.Suppressed = true
+		return
+	}
+
+	// The reason counts add up to the rejections
+	counts := make([]int64, len(r.Reasons))
+	for i := range r.Reasons {
+		counts[i] = r.Reasons[i].Count
+	}
+	suppressed := policy.SuppressPartition(counts)
+	for i := range r.Reasons {
+		if suppressed[i] {
+			r.Reasons[i].Count = 0
+			r.Reasons[i].Suppressed = true
+			continue
+		}
+		r.Reasons[i].Count = policy.Perturb(r.Reasons[i].Count)
+	}
+	r.Total = policy.Perturb(r.Total)
+	r.Rejected = min(policy.Perturb(r.Rejected), r.Total)
+}
+
+// percentage returns part as a percentage of total, rounded to two decimals
+func percentage(part, total int64) float64 {
+	if total == 0 {
+		return 0
+	}
+	return math.Round(float64(part)*10000/float64(total)) / 100
+}
@@ -0,0 +1,446 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/standingorder"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// maxComplianceDays bounds the date range of a compliance report
+const maxComplianceDays = 31
+
+// StandingOrderHandler handles HTTP requests for standing orders and their
+// expected observations
+type StandingOrderHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	scheduler *standingorder.Scheduler
+}
+
+// NewStandingOrderHandler creates a new standing order handler
+func NewStandingOrderHandler(db *gorm.DB, scheduler *standingorder.Scheduler) *StandingOrderHandler {
+	return &StandingOrderHandler{
+		db:        db,
+		validator: validator.New(),
+		scheduler: scheduler,
+	}
+}
+
+// ComplianceDay summarizes the expected observations of one patient on one day
+type ComplianceDay struct {
+	PatientID   string                   `json:"patientId"`
+	Date        string                   `json:"date"`
+	Expected    int                      `json:"expected"`
+	Collected   int                      `json:"collected"`
+	Missed      int                      `json:"missed"`
+	Pending     int                      `json:"pending"`
+	MissedSlots []models.ObservationSlot `json:"missedSlots"`
+}
+
+// ComplianceReport represents missed collections per patient per day
+type ComplianceReport struct {
+	From        string          `json:"from"`
+	To          string          `json:"to"`
+	Ward        string          `json:"ward,omitempty"`
+	Days        []ComplianceDay `json:"days"`
+	GeneratedAt time.Time       `json:"generatedAt"`
+}
+
+// CreateOrder creates a standing order
+// @Summary Create standing order
+// @Description Order an observation at fixed times every day, e.g. a glucose at 06:00. Each time becomes an expected observation slot, generated 48 hours ahead, which is collected by an observation of the patient with the order's code taken within toleranceMinutes (default 60) of it and missed otherwise.
+// @Tags standing-orders
+// @Accept json
+// @Produce json
+// @Param order body models.StandingOrderRequest true "Standing order"
+// @Success 201 {object} models.StandingOrder
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/standing-orders [post]
+func (h *StandingOrderHandler) CreateOrder(c *gin.Context) {
+	var req models.StandingOrderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+	invalid := func(message string) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: message,
+			Code:    "VALIDATION_FAILED",
+		})
+	}
+	if len(req.Code.Coding) == 0 || req.Code.Coding[0].Code == "" {
+		invalid("code must have a coding with a code")
+		return
+	}
+	if req.Subject.Reference == "" {
+		invalid("subject must reference a patient")
+		return
+	}
+	now := time.Now().UTC()
+	order := models.StandingOrder{
+		Subject:          req.Subject,
+		Code:             req.Code,
+		DailySchedule:    models.DailySchedule{Times: req.Times, Timezone: req.Timezone, StartsAt: now, EndsAt: req.EndsAt},
+		ToleranceMinutes: req.ToleranceMinutes,
+		Ward:             req.Ward,
+		OrderedBy:        req.OrderedBy,
+		Active:           true,
+	}
+	if req.StartsAt != nil {
+		order.StartsAt = req.StartsAt.UTC()
+	}
+	if order.EndsAt != nil && !order.EndsAt.After(order.StartsAt) {
+		invalid("endsAt must be after startsAt")
+		return
+	}
+	if order.Timezone == "" {
+		order.Timezone = "UTC"
+	}
+	if order.ToleranceMinutes == 0 {
+		order.ToleranceMinutes = models.DefaultSlotToleranceMinutes
+	}
+
+	// Validate that the referenced patient exists
+	patientID := strings.TrimPrefix(req.Subject.Reference, "Patient/")
+	var patient models.Patient
+	if err := h.db.Where("id = ?", patientID).First(&patient).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error: "Referenced patient not found",
+				Code:  "PATIENT_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	order.Subject.Reference = "Patient/" + patientID
+
+	if userID, exists := auth.GetUserID(c); exists {
+		order.CreatedBy = userID
+		if order.OrderedBy == "" {
+			order.OrderedBy = userID
+		}
+	}
+	if err := h.db.Create(&order).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to create standing order",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	h.scheduler.Wake()
+
+	logger.LogAuditEvent("create", "StandingOrder", order.CreatedBy, map[string]interface{}{
+		"order_id":   order.ID,
+		"patient_id": patientID,
+		"code":       order.Code.Coding[0].Code,
+	})
+
+	c.JSON(http.StatusCreated, order)
+}
+
+// GetOrders lists standing orders
+// @Summary Get standing orders
+// @Description List standing orders, newest first
+// @Tags standing-orders
+// @Produce json
+// @Param patient query string false "Filter by patient ID"
+// @Param ward query string false "Filter by ward"
+// @Param active query bool false "Filter by whether the order is active"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.StandingOrder}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/standing-orders [get]
+func (h *StandingOrderHandler) GetOrders(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.StandingOrder{})
+	if patient := strings.TrimSpace(c.Query("patient")); patient != "" {
+		query = query.Where("subject_reference = ?", "Patient/"+patient)
+	}
+	if ward := strings.TrimSpace(c.Query("ward")); ward != "" {
+		query = query.Where("ward = ?", ward)
+	}
+	if active, err := strconv.ParseBool(c.Query("active")); err == nil {
+		query = query.Where("active = ?", active)
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count standing orders",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var orders []models.StandingOrder
+	if err := query.Order("created_at DESC").Offset((page - 1) * limit).Limit(limit).Find(&orders).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch standing orders",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, orders, total, page, limit)
+}
+
+// GetOrderSlots lists the expected observations of a standing order
+// @Summary Get standing order slots
+// @Description List the expected observation slots of a standing order, by due time
+// @Tags standing-orders
+// @Produce json
+// @Param id path string true "Standing order ID"
+// @Param status query string false "Filter by status (pending, collected, missed, cancelled)"
+// @Success 200 {array} models.ObservationSlot
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/standing-orders/{id}/slots [get]
+func (h *StandingOrderHandler) GetOrderSlots(c *gin.Context) {
+	db := readDB(c, h.db)
+	var count int64
+	if err := db.Model(&models.StandingOrder{}).Where("id = ?", c.Param("id")).Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch standing order",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if count == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Standing order not found",
+			Code:  "ORDER_NOT_FOUND",
+		})
+		return
+	}
+
+	query := db.Where("order_id = ?", c.Param("id"))
+	if status := c.Query("status"); status != "" {
+		query = query.Where("status = ?", status)
+	}
+	var slots []models.ObservationSlot
+	if err := query.Order("due_at ASC").Find(&slots).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch observation slots",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, slots)
+}
+
+// DiscontinueOrder stops a standing order
+// @Summary Discontinue standing order
+// @Description Stop a standing order. Its pending slots are cancelled; collected and missed ones are kept for compliance reporting.
+// @Tags standing-orders
+// @Produce json
+// @Param id path string true "Standing order ID"
+// @Success 200 {object} models.StandingOrder
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/standing-orders/{id} [delete]
+func (h *StandingOrderHandler) DiscontinueOrder(c *gin.Context) {
+	var order models.StandingOrder
+	if err := h.db.Where("id = ?", c.Param("id")).First(&order).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Standing order not found",
+				Code:  "ORDER_NOT_FOUND",
+			})
+			return
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch standing order",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if !order.Active {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Standing order already discontinued",
+			Code:  "ORDER_DISCONTINUED",
+		})
+		return
+	}
+
+	now := time.Now().UTC()
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.StandingOrder{}).Where("id = ?", order.ID).
+			Updates(map[string]interface{}{"active": false, "ends_at": now}).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ObservationSlot{}).
+			Where("order_id = ? AND status = ?", order.ID, models.SlotStatusPending).
+			Update("status", models.SlotStatusCancelled).Error
+	})
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to discontinue standing order",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	order.Active = false
+	order.EndsAt = &now
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("discontinue", "StandingOrder", userID, map[string]interface{}{
+		"order_id": order.ID,
+	})
+
+	c.JSON(http.StatusOK, order)
+}
+
+// GetCompliance reports missed collections per patient per day
+// @Summary Standing order compliance
+// @Description Expected, collected, missed and pending observations of standing orders per patient per day, with the missed collections, for ward nurses. Days are local to each order's time zone.
+// @Tags standing-orders
+// @Produce json
+// @Param ward query string false "Filter by ward"
+// @Param patient query string false "Filter by patient ID"
+// @Param from query string false "First day (YYYY-MM-DD, default today)"
+// @Param to query string false "Last day (YYYY-MM-DD, default from); at most 31 days after from"
+// @Success 200 {object} ComplianceReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/standing-orders/compliance [get]
+func (h *StandingOrderHandler) GetCompliance(c *gin.Context) {
+	report := ComplianceReport{
+		From:        c.DefaultQuery("from", time.Now().UTC().Format("2006-01-02")),
+		Ward:        strings.TrimSpace(c.Query("ward")),
+		Days:        []ComplianceDay{},
+		GeneratedAt: time.Now().UTC(),
+	}
+	report.To = c.DefaultQuery("to", report.From)
+
+	from, fromErr := time.Parse("2006-01-02", report.From)
+	to, toErr := time.Parse("2006-01-02", report.To)
+	if fromErr != nil || toErr != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid date",
+			Message: "from and to must be YYYY-MM-DD dates",
+			Code:    "INVALID_DATE",
+		})
+		return
+	}
+	if to.Before(from) || to.Sub(from) > maxComplianceDays*24*time.Hour {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid date range",
+			Message: "to must be on or after from and at most 31 days later",
+			Code:    "INVALID_DATE_RANGE",
+		})
+		return
+	}
+
+	query := readDB(c, h.db).Where("date BETWEEN ? AND ? AND status <> ?", report.From, report.To, models.SlotStatusCancelled)
+	if report.Ward != "" {
+		query = query.Where("ward = ?", report.Ward)
+	}
+	if patient := strings.TrimSpace(c.Query("patient")); patient != "" {
+		query = query.Where("patient_id = ?", patient)
+	}
+
+	var slots []models.ObservationSlot
+	if err := query.Order("due_at ASC").Find(&slots).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch observation slots",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	days := make(map[[2]string]*ComplianceDay)
+	for _, slot := range slots {
+		key := [2]string{slot.PatientID, slot.Date}
+		day, ok := days[key]
+		if !ok {
+			day = &ComplianceDay{PatientID: slot.PatientID, Date: slot.Date, MissedSlots: []models.ObservationSlot{}}
+			days[key] = day
+		}
+		day.Expected++
+		switch slot.Status {
+		case models.SlotStatusCollected:
+			day.Collected++
+		case models.SlotStatusMissed:
+			day.Missed++
+			day.MissedSlots = append(day.MissedSlots, slot)
+		default:
+			day.Pending++
+		}
+	}
+	for _, day := range days {
+		report.Days = append(report.Days, *day)
+	}
+	sort.Slice(report.Days, func(i, j int) bool {
+		if report.Days[i].Date != report.Days[j].Date {
+			return report.Days[i].Date < report.Days[j].Date
+		}
+		return report.Days[i].PatientID < report.Days[j].PatientID
+	})
+
+	c.JSON(http.StatusOK, report)
+}
@@ -0,0 +1,284 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// maxMappingsPerRequest bounds how many mappings one request may load
+const maxMappingsPerRequest = 1000
+
+// TerminologyHandler handles HTTP requests for concept maps, code translation and
+// localized displays
+type TerminologyHandler struct {
+	db         *gorm.DB
+	translator *terminology.Translator
+	localizer  *terminology.Localizer
+	validator  *validator.Validate
+}
+
+// NewTerminologyHandler creates a new terminology handler
+func NewTerminologyHandler(db *gorm.DB, translator *terminology.Translator, localizer *terminology.Localizer) *TerminologyHandler {
+	return &TerminologyHandler{
+		db:         db,
+		translator: translator,
+		localizer:  localizer,
+		validator:  validator.New(),
+	}
+}
+
+// TranslateResponse is the outcome of a $translate request
+type TranslateResponse struct {
+	Result  bool                `json:"result"` // Whether any match was found
+	Message string              `json:"message,omitempty"`
+	Matches []terminology.Match `json:"matches"`
+}
+
+// Translate maps a code to another code system
+// @Summary Translate code
+// @Description Map a code to the codes of the target system using the stored concept maps, e.g. a SNOMED CT code to ICD-10-CM. Matches are ordered by preference and carry their relationship to the source code; mappings stored from the target system back to the source system are used as well.
+// @Tags terminology
+// @Accept json
+// @Produce json
+// @Param request body models.TranslateRequest true "Code to translate"
+// @Success 200 {object} TranslateResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/terminology/$translate [post]
+func (h *TerminologyHandler) Translate(c *gin.Context) {
+	var req models.TranslateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if err := h.validator.Struct(req); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	matches, err := h.translator.Translate(c.Request.Context(), req.System, req.Code, req.TargetSystem)
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to translate code",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	response := TranslateResponse{Result: len(matches) > 0, Matches: matches}
+	if !response.Result {
+		response.Message = "no mapping from " + req.System + "|" + req.Code + " to " + req.TargetSystem
+	}
+	c.JSON(http.StatusOK, response)
+}
+
+// GetMappings lists concept mappings
+// @Summary Get concept mappings
+// @Description List the stored concept mappings, optionally of one source system, code or target system
+// @Tags terminology
+// @Produce json
+// @Param source-system query string false "Filter by source system"
+// @Param source-code query string false "Filter by source code"
+// @Param target-system query string false "Filter by target system"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.ConceptMapping}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/terminology/mappings [get]
+func (h *TerminologyHandler) GetMappings(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.ConceptMapping{})
+	for param, column := range map[string]string{
+		"source-system": "source_system",
+		"source-code":   "source_code",
+		"target-system": "target_system",
+	} {
+		if value := strings.TrimSpace(c.Query(param)); value != "" {
+			query = query.Where(column+" = ?", value)
+		}
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count concept mappings",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var mappings []models.ConceptMapping
+	if err := query.Order("source_system, source_code, priority, target_code").
+		Offset((page - 1) * limit).Limit(limit).Find(&mappings).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch concept mappings",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, mappings, total, page, limit)
+}
+
+// PutMappings creates or replaces concept mappings
+// @Summary Load concept mappings
+// @Description Create or replace up to 1000 mappings, matched on source and target system and code (admin only)
+// @Tags terminology
+// @Accept json
+// @Produce json
+// @Param mappings body []models.ConceptMappingRequest true "Mappings"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/terminology/mappings [post]
+func (h *TerminologyHandler) PutMappings(c *gin.Context) {
+	var reqs []models.ConceptMappingRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxMappingsPerRequest {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "between 1 and " + strconv.Itoa(maxMappingsPerRequest) + " mappings are required",
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	// A pair given twice keeps its last mapping; one statement cannot update a row twice
+	mappings := make([]models.ConceptMapping, 0, len(reqs))
+	pairs := make(map[string]int, len(reqs))
+	for i, req := range reqs {
+		if err := h.validator.Struct(req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: "mapping " + strconv.Itoa(i) + ": " + err.Error(),
+				Code:    "VALIDATION_FAILED",
+			})
+			return
+		}
+		mapping := models.ConceptMapping{
+			SourceSystem:  req.SourceSystem,
+			SourceCode:    req.SourceCode,
+			SourceDisplay: req.SourceDisplay,
+			TargetSystem:  req.TargetSystem,
+			TargetCode:    req.TargetCode,
+			TargetDisplay: req.TargetDisplay,
+			Relationship:  req.Relationship,
+			Priority:      req.Priority,
+			Comment:       req.Comment,
+			Active:        req.Active == nil || *req.Active,
+		}
+		pair := strings.Join([]string{req.SourceSystem, req.SourceCode, req.TargetSystem, req.TargetCode}, "|")
+		if j, ok := pairs[pair]; ok {
+			mappings[j] = mapping
+			continue
+		}
+		pairs[pair] = len(mappings)
+		mappings = append(mappings, mapping)
+	}
+
+	// Mappings already stored for a pair are replaced
+	err := h.db.Clauses(clause.OnConflict{
+		Columns: []clause.Column{{Name: "source_system"}, {Name: "source_code"}, {Name: "target_system"}, {Name: "target_code"}},
+		DoUpdates: clause.AssignmentColumns([]string{
+			"source_display", "target_display", "relationship", "priority", "comment", "active", "updated_at",
+		}),
+	}).CreateInBatches(&mappings, 200).Error
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to save concept mappings",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "ConceptMapping", userID, map[string]interface{}{
+		"mappings": len(mappings),
+	})
+
+	c.JSON(http.StatusOK, NewSuccessResponse("Concept mappings saved", map[string]int{"saved": len(mappings)}))
+}
+
+// DeleteMapping removes a concept mapping
+// @Summary Delete concept mapping
+// @Description Remove a mapping from the concept maps (admin only)
+// @Tags terminology
+// @Param id path string true "Mapping ID"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/terminology/mappings/{id} [delete]
+func (h *TerminologyHandler) DeleteMapping(c *gin.Context) {
+	result := h.db.Where("id = ?", c.Param("id")).Delete(&models.ConceptMapping{})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete concept mapping",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Concept mapping not found",
+			Code:  "MAPPING_NOT_FOUND",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "ConceptMapping", userID, map[string]interface{}{
+		"mapping_id": c.Param("id"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
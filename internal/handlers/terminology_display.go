@@ -0,0 +1,287 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm/clause"
+)
+
+// readerLanguages returns the languages of the Accept-Language header, or of the
+// displayLanguage query parameter, and marks the response as varying on the header
+func readerLanguages(c *gin.Context) []string {
+	c.Header("Vary", "Accept-Language")
+	header := c.GetHeader("Accept-Language")
+	if displayLanguage := c.Query("displayLanguage"); displayLanguage != "" {
+		header = displayLanguage
+	}
+	return terminology.ParseAcceptLanguage(header)
+}
+
+// localizeCodings gives codings their displays in the reader's languages before they
+// are served. Codings without one keep their own, as do all of them when the
+// displays cannot be read, so a read never fails over its displays.
+func localizeCodings(c *gin.Context, localizer *terminology.Localizer, codings ...[]models.Coding) {
+	if err := localizer.Localize(c.Request.Context(), readerLanguages(c), codings...); err != nil {
+		logger.Warn("Failed to localize displays", zap.Error(err))
+	}
+}
+
+// localizeObservations gives the codings of observations their displays in the
+// reader's languages
+func localizeObservations(c *gin.Context, localizer *terminology.Localizer, observations ...models.Observation) {
+	var codings [][]models.Coding
+	for i := range observations {
+		codings = append(codings, observations[i].Codings()...)
+	}
+	localizeCodings(c, localizer, codings...)
+}
+
+// LookupResponse is the display of a code in the language it was looked up in
+type LookupResponse struct {
+	System   string `json:"system"`
+	Code     string `json:"code"`
+	Display  string `json:"display"`
+	Language string `json:"language"`
+}
+
+// Lookup returns the display of a code in the reader's language
+// @Summary Look up display
+// @Description Get the display of a code, such as an interpretation flag, status or category, in the first language of the Accept-Language header, or of displayLanguage, that has one, so patient-facing clients show "Alto" rather than H. Regional languages fall back to their base language and every language to English; Content-Language names the language returned.
+// @Tags terminology
+// @Produce json
+// @Param system query string true "Code system"
+// @Param code query string true "Code"
+// @Param displayLanguage query string false "Languages to use instead of Accept-Language, in the header's format"
+// @Param Accept-Language header string false "Languages the reader prefers"
+// @Success 200 {object} LookupResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/terminology/$lookup [get]
+func (h *TerminologyHandler) Lookup(c *gin.Context) {
+	system, code := strings.TrimSpace(c.Query("system")), strings.TrimSpace(c.Query("code"))
+	if system == "" || code == "" {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid lookup",
+			Message: "system and code are required",
+			Code:    "INVALID_LOOKUP",
+		})
+		return
+	}
+
+	display, ok, err := h.localizer.Display(c.Request.Context(), system, code, readerLanguages(c))
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to look up display",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if !ok {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error:   "Display not found",
+			Message: "no display of " + system + "|" + code + " in the requested languages or " + terminology.DefaultLanguage,
+			Code:    "DISPLAY_NOT_FOUND",
+		})
+		return
+	}
+
+	c.Header("Content-Language", display.Language)
+	c.JSON(http.StatusOK, LookupResponse{
+		System:   display.System,
+		Code:     display.Code,
+		Display:  display.Display,
+		Language: display.Language,
+	})
+}
+
+// GetDisplays lists concept displays
+// @Summary Get concept displays
+// @Description List the stored displays of codes by language, optionally of one system, code or language
+// @Tags terminology
+// @Produce json
+// @Param system query string false "Filter by code system"
+// @Param code query string false "Filter by code"
+// @Param language query string false "Filter by language"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]models.ConceptDisplay}
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/terminology/displays [get]
+func (h *TerminologyHandler) GetDisplays(c *gin.Context) {
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	query := readDB(c, h.db).Model(&models.ConceptDisplay{})
+	if system := strings.TrimSpace(c.Query("system")); system != "" {
+		query = query.Where("system = ?", system)
+	}
+	if code := strings.TrimSpace(c.Query("code")); code != "" {
+		query = query.Where("code = ?", code)
+	}
+	if language := strings.TrimSpace(c.Query("language")); language != "" {
+		query = query.Where("language = ?", strings.ToLower(language))
+	}
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count concept displays",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	var displays []models.ConceptDisplay
+	if err := query.Order("system, code, language").
+		Offset((page - 1) * limit).Limit(limit).Find(&displays).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch concept displays",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	respondPage(c, displays, total, page, limit)
+}
+
+// PutDisplays creates or replaces concept displays
+// @Summary Load concept displays
+// @Description Create or replace up to 1000 displays, matched on system, code and language (admin only)
+// @Tags terminology
+// @Accept json
+// @Produce json
+// @Param displays body []models.ConceptDisplayRequest true "Displays"
+// @Success 200 {object} SuccessResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/terminology/displays [post]
+func (h *TerminologyHandler) PutDisplays(c *gin.Context) {
+	var reqs []models.ConceptDisplayRequest
+	if err := c.ShouldBindJSON(&reqs); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+	if len(reqs) == 0 || len(reqs) > maxMappingsPerRequest {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: "between 1 and " + strconv.Itoa(maxMappingsPerRequest) + " displays are required",
+			Code:    "VALIDATION_FAILED",
+		})
+		return
+	}
+
+	// A code given twice in a language keeps its last display
+	displays := make([]models.ConceptDisplay, 0, len(reqs))
+	keys := make(map[string]int, len(reqs))
+	for i, req := range reqs {
+		if err := h.validator.Struct(req); err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Validation failed",
+				Message: "display " + strconv.Itoa(i) + ": " + err.Error(),
+				Code:    "VALIDATION_FAILED",
+			})
+			return
+		}
+		display := models.ConceptDisplay{
+			System:   req.System,
+			Code:     req.Code,
+			Language: strings.ToLower(req.Language),
+			Display:  req.Display,
+		}
+		key := strings.Join([]string{display.System, display.Code, display.Language}, "|")
+		if j, ok := keys[key]; ok {
+			displays[j] = display
+			continue
+		}
+		keys[key] = len(displays)
+		displays = append(displays, display)
+	}
+
+	err := h.db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "system"}, {Name: "code"}, {Name: "language"}},
+		DoUpdates: clause.AssignmentColumns([]string{"display", "updated_at"}),
+	}).CreateInBatches(&displays, 200).Error
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to save concept displays",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("update", "ConceptDisplay", userID, map[string]interface{}{
+		"displays": len(displays),
+	})
+
+	c.JSON(http.StatusOK, NewSuccessResponse("Concept displays saved", map[string]int{"saved": len(displays)}))
+}
+
+// DeleteDisplay removes a concept display
+// @Summary Delete concept display
+// @Description Remove a display; lookups of the code in its language fall back to the base language or English (admin only)
+// @Tags terminology
+// @Param id path string true "Display ID"
+// @Success 204
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/terminology/displays/{id} [delete]
+func (h *TerminologyHandler) DeleteDisplay(c *gin.Context) {
+	result := h.db.Where("id = ?", c.Param("id")).Delete(&models.ConceptDisplay{})
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete concept display",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Concept display not found",
+			Code:  "DISPLAY_NOT_FOUND",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("delete", "ConceptDisplay", userID, map[string]interface{}{
+		"display_id": c.Param("id"),
+	})
+
+	c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,276 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/retention"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// TrashHandler lists deleted resources that are still within the undo window and
+// purges them on request
+type TrashHandler struct {
+	db     *gorm.DB
+	purger *retention.Purger
+	undo   time.Duration
+}
+
+// NewTrashHandler creates a new trash handler
+func NewTrashHandler(db *gorm.DB, purger *retention.Purger, undoWindow time.Duration) *TrashHandler {
+	return &TrashHandler{
+		db:     db,
+		purger: purger,
+		undo:   undoWindow,
+	}
+}
+
+// TrashItem represents a deleted resource awaiting purge
+type TrashItem struct {
+	ResourceType string       `json:"resourceType"`
+	ID           string       `json:"id"`
+	Display      string       `json:"display"`
+	DeletedAt    time.Time    `json:"deletedAt"`
+	DeletedBy    string       `json:"deletedBy,omitempty"`
+	PurgeAt      time.Time    `json:"purgeAt"`
+	LegalHold    bool         `json:"legalHold,omitempty"` // Kept past purgeAt until the hold is released
+	Actions      TrashActions `json:"actions"`
+}
+
+// TrashActions links the operations available for a deleted resource. Restore is omitted
+// for observations that were deleted with their patient; those return with the patient.
+type TrashActions struct {
+	Restore string `json:"restore,omitempty"`
+	Purge   string `json:"purge"`
+}
+
+// GetTrash lists recently deleted resources
+// @Summary List deleted resources
+// @Description List deleted patients or observations that can still be restored, newest first (admin only)
+// @Tags admin
+// @Produce json
+// @Param type query string true "Resource type (patient, observation)"
+// @Param page query int false "Page number (default: 1)"
+// @Param limit query int false "Items per page (default: 10, max: 100)"
+// @Success 200 {object} PaginatedResponse{data=[]TrashItem}
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/trash [get]
+func (h *TrashHandler) GetTrash(c *gin.Context) {
+	resourceType := strings.ToLower(strings.TrimSpace(c.Query("type")))
+	page, _ := strconv.Atoi(c.DefaultQuery("page", "1"))
+	limit, _ := strconv.Atoi(c.DefaultQuery("limit", "10"))
+
+	if page < 1 {
+		page = 1
+	}
+	if limit < 1 || limit > 100 {
+		limit = 10
+	}
+
+	var model interface{}
+	var typeName string
+	switch resourceType {
+	case "patient":
+		model, typeName = &models.Patient{}, "Patient"
+	case "observation":
+		model, typeName = &models.Observation{}, "Observation"
+	default:
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Type must be patient or observation",
+			Code:  "INVALID_RESOURCE_TYPE",
+		})
+		return
+	}
+
+	query := h.db.Unscoped().Model(model).Where("deleted_at IS NOT NULL")
+
+	var total int64
+	if err := query.Count(&total).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to count deleted resources",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	query = query.Order("deleted_at DESC").Offset((page - 1) * limit).Limit(limit)
+	items := []TrashItem{}
+
+	switch resourceType {
+	case "patient":
+		var patients []models.Patient
+		if err := query.Find(&patients).Error; err != nil {
+			h.respondFetchError(c, err)
+			return
+		}
+		for i := range patients {
+			patient := &patients[i]
+			item := h.newItem("Patient", patient.ID, patient.GetFullName(), patient.DeletedAt, patient.DeletedBy)
+			item.Actions.Restore = "/api/v1/patients/" + patient.ID + "/undelete"
+			items = append(items, item)
+		}
+
+	case "observation":
+		var observations []models.Observation
+		if err := query.Find(&observations).Error; err != nil {
+			h.respondFetchError(c, err)
+			return
+		}
+
+		// Observations deleted with their patient can only be restored through the patient
+		patientIDs := make([]string, 0, len(observations))
+		for i := range observations {
+			patientIDs = append(patientIDs, strings.TrimPrefix(observations[i].Subject.Reference, "Patient/"))
+		}
+		var livePatients []string
+		if err := h.db.Model(&models.Patient{}).Where("id IN ?", patientIDs).Pluck("id", &livePatients).Error; err != nil {
+			h.respondFetchError(c, err)
+			return
+		}
+		live := make(map[string]bool, len(livePatients))
+		for _, id := range livePatients {
+			live[id] = true
+		}
+
+		for i := range observations {
+			observation := &observations[i]
+			item := h.newItem("Observation", observation.ID, observation.GetCodeDisplay(), observation.DeletedAt, observation.DeletedBy)
+			if live[strings.TrimPrefix(observation.Subject.Reference, "Patient/")] {
+				item.Actions.Restore = "/api/v1/observations/" + observation.ID + "/undelete"
+			}
+			items = append(items, item)
+		}
+	}
+
+	ids := make([]string, len(items))
+	for i := range items {
+		ids[i] = items[i].ID
+	}
+	held, err := h.purger.Held(c.Request.Context(), typeName, ids)
+	if err != nil {
+		h.respondFetchError(c, err)
+		return
+	}
+	for i := range items {
+		items[i].LegalHold = held[items[i].ID]
+	}
+
+	respondPage(c, items, total, page, limit)
+}
+
+// PurgeTrashItem permanently removes a deleted resource before its undo window passes
+// @Summary Purge deleted resource
+// @Description Permanently remove a deleted patient (with its observations, links, conditions, allergies, procedures, coverages, related persons, media and documents) or observation. This cannot be undone (admin only). Resources under a legal hold, including patients with a held observation and observations of a held patient, cannot be purged.
+// @Tags admin
+// @Param type path string true "Resource type (patient, observation)"
+// @Param id path string true "Resource ID"
+// @Success 204 "No Content"
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/trash/{type}/{id} [delete]
+func (h *TrashHandler) PurgeTrashItem(c *gin.Context) {
+	id := c.Param("id")
+	ids := []string{id}
+
+	var purged retention.Purged
+	var typeName string
+	var err error
+	switch c.Param("type") {
+	case "patient":
+		typeName = "Patient"
+		purged, err = h.purger.PurgePatients(c.Request.Context(), ids)
+	case "observation":
+		typeName = "Observation"
+		purged.Observations, err = h.purger.PurgeObservations(c.Request.Context(), ids)
+	default:
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error: "Type must be patient or observation",
+			Code:  "INVALID_RESOURCE_TYPE",
+		})
+		return
+	}
+
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to purge resource",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	if purged.Patients == 0 && purged.Observations == 0 {
+		// Purges skip resources under a legal hold as well as those that are not deleted
+		held, err := h.purger.Held(c.Request.Context(), typeName, ids)
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to check legal holds",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		if held[id] {
+			respondError(c, http.StatusConflict, ErrorResponse{
+				Error:   "Resource is under a legal hold",
+				Message: "it cannot be purged until every legal hold on it is released",
+				Code:    "LEGAL_HOLD_ACTIVE",
+			})
+			return
+		}
+		respondError(c, http.StatusNotFound, ErrorResponse{
+			Error: "Deleted resource not found",
+			Code:  "TRASH_ITEM_NOT_FOUND",
+		})
+		return
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("purge", c.Param("type"), userID, map[string]interface{}{
+		"resource_id":  id,
+		"observations": purged.Observations,
+		"records":      purged.Records,
+	})
+
+	c.Status(http.StatusNoContent)
+}
+
+// newItem builds a trash item with its purge time and purge action
+func (h *TrashHandler) newItem(resourceType, id, display string, deletedAt gorm.DeletedAt, deletedBy string) TrashItem {
+	return TrashItem{
+		ResourceType: resourceType,
+		ID:           id,
+		Display:      display,
+		DeletedAt:    deletedAt.Time,
+		DeletedBy:    deletedBy,
+		PurgeAt:      deletedAt.Time.Add(h.undo),
+		Actions: TrashActions{
+			Purge: "/api/v1/admin/trash/" + strings.ToLower(resourceType) + "/" + id,
+		},
+	}
+}
+
+// respondFetchError writes the response for a failed trash query
+func (h *TrashHandler) respondFetchError(c *gin.Context, err error) {
+	respondError(c, http.StatusInternalServerError, ErrorResponse{
+		Error:   "Failed to fetch deleted resources",
+		Message: err.Error(),
+		Code:    "DATABASE_ERROR",
+	})
+}
@@ -0,0 +1,198 @@
+package handlers
+
+import (
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/turnaround"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+)
+
+// turnaroundIntervals are the measured intervals in minutes, with the column that
+// must be set for an observation to be counted
+var turnaroundIntervals = []struct {
+	name, minutes, requires string
+}{
+	{
+		turnaround.OrderToResult,
+		"EXTRACT(EPOCH FROM observations.created_at - observations.ordered_at) / 60",
+		"observations.ordered_at",
+	},
+	{
+		turnaround.CollectionToVerification,
+		"EXTRACT(EPOCH FROM observations.issued - observations.effective_date_time) / 60",
+		"observations.issued",
+	},
+}
+
+// observationDepartment selects the managed category code of an observation
+const observationDepartment = `(SELECT coding->>'code'
+	FROM jsonb_array_elements(COALESCE(observations.category, '[]'::jsonb)) AS cat,
+		jsonb_array_elements(COALESCE(cat->'coding', '[]'::jsonb)) AS coding
+	WHERE coding->>'system' = ? LIMIT 1)`
+
+// TurnaroundStats summarizes one turnaround interval of a code and department
+type TurnaroundStats struct {
+	Interval      string   `json:"interval"`
+	System        string   `json:"system,omitempty"`
+	Code          string   `json:"code"`
+	Display       string   `json:"display,omitempty"`
+	Department    string   `json:"department,omitempty"`
+	Count         int64    `json:"count"`
+	MeanMinutes   float64  `json:"meanMinutes"`
+	MedianMinutes float64  `json:"medianMinutes"`
+	P90Minutes    float64  `json:"p90Minutes"`
+	MaxMinutes    float64  `json:"maxMinutes"`
+	TargetMinutes *int     `json:"targetMinutes,omitempty" gorm:"-"`
+	WithinTarget  *int64   `json:"withinTarget,omitempty"`
+	Percentage    *float64 `json:"percentage,omitempty" gorm:"-"` // Share within target
+}
+
+// TurnaroundReport represents laboratory turnaround times
+type TurnaroundReport struct {
+	From        string            `json:"from,omitempty"`
+	To          string            `json:"to,omitempty"`
+	Results     []TurnaroundStats `json:"results"`
+	GeneratedAt time.Time         `json:"generatedAt"`
+}
+
+// GetTurnaround reports laboratory turnaround times
+// @Summary Turnaround time report
+// @Description Order-to-result and collection-to-verification turnaround per code and department, for monitoring SLA compliance. Order-to-result runs from orderedAt until the result is recorded, collection-to-verification from effectiveDateTime until issued; results are issued when first saved as final, amended or corrected unless issued is given. With a target the share of results within it is reported. Negative intervals from clock skew are left out.
+// @Tags analytics
+// @Produce json
+// @Param code query string false "Filter by observation code"
+// @Param department query string false "Filter by department (managed category code)"
+// @Param status query string false "Filter by status; final, amended and corrected results by default"
+// @Param from query string false "Filter by effective date from (ISO 8601)"
+// @Param to query string false "Filter by effective date to (ISO 8601)"
+// @Param order-to-result-target query int false "Order-to-result target in minutes"
+// @Param collection-to-verification-target query int false "Collection-to-verification target in minutes"
+// @Success 200 {object} TurnaroundReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Failure 501 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/analytics/turnaround [get]
+func (h *AnalyticsHandler) GetTurnaround(c *gin.Context) {
+	// Percentiles and reading the serialized categories rely on PostgreSQL
+	if !dialect.IsPostgres(h.db) {
+		respondError(c, http.StatusNotImplemented, ErrorResponse{
+			Error:   "Report not available",
+			Message: "The turnaround report requires a PostgreSQL database",
+			Code:    "UNSUPPORTED_DATABASE",
+		})
+		return
+	}
+
+	targets := make(map[string]int, len(turnaroundIntervals))
+	for _, interval := range turnaroundIntervals {
+		param := strings.ReplaceAll(interval.name, "_", "-") + "-target"
+		value := strings.TrimSpace(c.Query(param))
+		if value == "" {
+			continue
+		}
+		minutes, err := strconv.Atoi(value)
+		if err != nil || minutes <= 0 {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid target",
+				Message: param + " must be a positive number of minutes",
+				Code:    "INVALID_TARGET",
+			})
+			return
+		}
+		targets[interval.name] = minutes
+	}
+
+	report := TurnaroundReport{
+		From:        strings.TrimSpace(c.Query("from")),
+		To:          strings.TrimSpace(c.Query("to")),
+		Results:     []TurnaroundStats{},
+		GeneratedAt: time.Now().UTC(),
+	}
+	filter := models.ObservationFilter{
+		Status: strings.TrimSpace(c.Query("status")),
+		Code:   strings.TrimSpace(c.Query("code")),
+		From:   report.From,
+		To:     report.To,
+	}
+	department := strings.TrimSpace(c.Query("department"))
+	if !checkObservationFilter(c, filter) {
+		return
+	}
+
+	for _, interval := range turnaroundIntervals {
+		query := filter.Apply(readDB(c, h.db).Model(&models.Observation{}))
+		if filter.Status == "" {
+			query = query.Where("observations.status IN ?", reportedStatuses)
+		}
+		if department != "" {
+			query = query.Where(observationDepartment+" = ?", models.ObservationCategorySystem, department)
+		}
+
+		// Without a target nothing is within it
+		target, hasTarget := targets[interval.name]
+		if !hasTarget {
+			target = -1
+		}
+
+		var stats []TurnaroundStats
+		minutes := interval.minutes
+		err := query.Select(`observations.code->'coding'->0->>'system' AS system,
+				observations.code->'coding'->0->>'code' AS code,
+				MAX(COALESCE(observations.code->'coding'->0->>'display', observations.code->>'text')) AS display,
+				`+observationDepartment+` AS department,
+				COUNT(*) AS count,
+				AVG(`+minutes+`) AS mean_minutes,
+				percentile_cont(0.5) WITHIN GROUP (ORDER BY `+minutes+`) AS median_minutes,
+				percentile_cont(0.9) WITHIN GROUP (ORDER BY `+minutes+`) AS p90_minutes,
+				MAX(`+minutes+`) AS max_minutes,
+				COUNT(*) FILTER (WHERE `+minutes+` <= ?) AS within_target`,
+			models.ObservationCategorySystem, target).
+			Where(interval.requires + " IS NOT NULL").
+			Where(minutes + " >= 0").
+			Group("1, 2, 4").Order("1, 2, 4").
+			Scan(&stats).Error
+		if err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to aggregate observations",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+
+		for i := range stats {
+			s := &stats[i]
+			s.Interval = interval.name
+			s.MeanMinutes = roundMinutes(s.MeanMinutes)
+			s.MedianMinutes = roundMinutes(s.MedianMinutes)
+			s.P90Minutes = roundMinutes(s.P90Minutes)
+			s.MaxMinutes = roundMinutes(s.MaxMinutes)
+			if !hasTarget {
+				s.WithinTarget = nil
+				continue
+			}
+			s.TargetMinutes = &target
+			if s.Count > 0 && s.WithinTarget != nil {
+				percentage := math.Round(float64(*s.WithinTarget)*10000/float64(s.Count)) / 100
+				s.Percentage = &percentage
+			}
+		}
+		report.Results = append(report.Results, stats...)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// roundMinutes rounds a duration in minutes to two decimals
+func roundMinutes(minutes float64) float64 {
+	return math.Round(minutes*100) / 100
+}
@@ -0,0 +1,146 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/retention"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+)
+
+// trashRecord marks a record as deleted by the current user; the retention job removes
+// it after the undo window
+func trashRecord(c *gin.Context, db *gorm.DB, model interface{}) error {
+	userID, _ := auth.GetUserID(c)
+	return db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(model).UpdateColumn("deleted_by", userID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(model).Error
+	})
+}
+
+// undeleteRecord restores the deleted record of a resource type named in the path and
+// loads it into model, as long as the undo window has not passed, writing the error
+// response on failure. Records deleted with their patient come back with the patient.
+func undeleteRecord(c *gin.Context, db *gorm.DB, resourceType string, model interface{}, undo time.Duration, notFound ErrorResponse) bool {
+	record, _ := retention.RecordOf(resourceType)
+	id := c.Param("id")
+
+	var deleted struct {
+		DeletedAt gorm.DeletedAt
+		Reference string
+	}
+	result := db.Unscoped().Model(model).Select("deleted_at, "+record.Column+" AS reference").
+		Where("id = ?", id).Scan(&deleted)
+	if result.Error != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch deleted record",
+			Message: result.Error.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	if result.RowsAffected == 0 {
+		respondError(c, http.StatusNotFound, notFound)
+		return false
+	}
+
+	if !deleted.DeletedAt.Valid {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Record is not deleted",
+			Code:  "RECORD_NOT_DELETED",
+		})
+		return false
+	}
+
+	if time.Since(deleted.DeletedAt.Time) > undo {
+		respondError(c, http.StatusGone, ErrorResponse{
+			Error: "Undo window has passed",
+			Code:  "UNDO_WINDOW_EXPIRED",
+		})
+		return false
+	}
+
+	// The patient must still exist, otherwise the record would be orphaned
+	patientID := strings.TrimPrefix(deleted.Reference, "Patient/")
+	var count int64
+	if err := db.Model(&models.Patient{}).Where("id = ?", patientID).Count(&count).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to validate patient reference",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	if count == 0 {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error: "Referenced patient is deleted; undelete the patient instead",
+			Code:  "PATIENT_DELETED",
+		})
+		return false
+	}
+
+	if err := db.Unscoped().Model(model).Where("id = ?", id).
+		Updates(map[string]interface{}{"deleted_at": nil, "deleted_by": ""}).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to restore record",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	if err := db.Where("id = ?", id).First(model).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch restored record",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+
+	userID, _ := auth.GetUserID(c)
+	logger.LogAuditEvent("undelete", resourceType, userID, map[string]interface{}{
+		"resource_id": id,
+		"patient_id":  patientID,
+	})
+	return true
+}
+
+// trashPatientRecords marks the records of a patient being deleted as deleted with
+// them, sharing the patient's deletion time so an undelete restores exactly those
+func trashPatientRecords(tx *gorm.DB, patient *models.Patient, userID string) error {
+	for _, record := range retention.Records {
+		if !record.Trash {
+			continue
+		}
+		if err := tx.Model(record.New()).Where(record.Column+" = ?", "Patient/"+patient.ID).
+			UpdateColumns(map[string]interface{}{"deleted_at": patient.DeletedAt, "deleted_by": userID}).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restorePatientRecords undeletes the records deleted with a patient
+func restorePatientRecords(tx *gorm.DB, patient *models.Patient) (int64, error) {
+	var restored int64
+	for _, record := range retention.Records {
+		if !record.Trash {
+			continue
+		}
+		result := tx.Unscoped().Model(record.New()).
+			Where(record.Column+" = ? AND deleted_at = ?", "Patient/"+patient.ID, patient.DeletedAt).
+			UpdateColumns(map[string]interface{}{"deleted_at": nil, "deleted_by": ""})
+		if result.Error != nil {
+			return restored, result.Error
+		}
+		restored += result.RowsAffected
+	}
+	return restored, nil
+}
@@ -0,0 +1,205 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/usage"
+	"gorm.io/gorm"
+)
+
+// defaultUsageDays is the number of days, up to today, a usage report covers by default
+const defaultUsageDays = 30
+
+// UsageHandler reports which clients use the API's endpoints and deprecated fields
+type UsageHandler struct {
+	db      *gorm.DB
+	tracker *usage.Tracker
+}
+
+// NewUsageHandler creates a new usage handler reporting the counts of tracker
+func NewUsageHandler(db *gorm.DB, tracker *usage.Tracker) *UsageHandler {
+	return &UsageHandler{db: db, tracker: tracker}
+}
+
+// EndpointUsage is the requests made to an endpoint, or with one of its deprecated
+// fields, in total or by one client
+type EndpointUsage struct {
+	Method      string `json:"method"`
+	Route       string `json:"route"`
+	Field       string `json:"field,omitempty"`
+	UserID      string `json:"userId,omitempty"`
+	APIKey      string `json:"apiKey,omitempty"`
+	UserAgent   string `json:"userAgent,omitempty"`
+	Requests    int64  `json:"requests"`
+	FirstSeenOn string `json:"firstSeenOn"`
+	LastSeenOn  string `json:"lastSeenOn"`
+}
+
+// UsageReport lists the usage of endpoints over a range of days
+type UsageReport struct {
+	From string          `json:"from"`
+	To   string          `json:"to"`
+	Data []EndpointUsage `json:"data"`
+}
+
+// DeprecationUsage is the usage of a deprecated endpoint or field by each client
+type DeprecationUsage struct {
+	usage.Deprecation
+	Requests   int64           `json:"requests"`
+	LastSeenOn string          `json:"lastSeenOn,omitempty"`
+	Clients    []EndpointUsage `json:"clients"`
+}
+
+// DeprecationReport lists the usage of the deprecated endpoints and fields over a
+// range of days
+type DeprecationReport struct {
+	From string             `json:"from"`
+	To   string             `json:"to"`
+	Data []DeprecationUsage `json:"data"`
+}
+
+// GetEndpointUsage reports the requests made to each endpoint
+// @Summary Get endpoint usage
+// @Description Report the requests made to each endpoint over a range of days, most used first, as counted by every instance; counts are stored every minute. With by=client the requests are broken down by client: the user, the integration credential of signed requests and the user agent (admin only)
+// @Tags admin
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default: 29 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default: today)"
+// @Param method query string false "Filter by HTTP method"
+// @Param route query string false "Filter by route template, e.g. /api/v1/patients/:id"
+// @Param by query string false "client to break the requests down by client"
+// @Success 200 {object} UsageReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/usage [get]
+func (h *UsageHandler) GetEndpointUsage(c *gin.Context) {
+	from, to, ok := reportDays(c, defaultUsageDays)
+	if !ok {
+		return
+	}
+	byClient := c.Query("by") == "client"
+
+	columns := "method, route"
+	if byClient {
+		columns += ", user_id, api_key, user_agent"
+	}
+	query := readDB(c, h.db).Model(&models.APIUsage{}).
+		Select(columns+", SUM(requests) AS requests, MIN(day) AS first_seen_on, MAX(day) AS last_seen_on").
+		Where("field = '' AND day BETWEEN ? AND ?", from, to)
+	if method := strings.TrimSpace(c.Query("method")); method != "" {
+		query = query.Where("method = ?", strings.ToUpper(method))
+	}
+	if route := strings.TrimSpace(c.Query("route")); route != "" {
+		query = query.Where("route = ?", route)
+	}
+
+	report := UsageReport{From: from, To: to, Data: []EndpointUsage{}}
+	if err := query.Group(columns).Order("requests DESC, " + columns).Scan(&report.Data).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch API usage",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// GetDeprecationUsage reports which clients use the deprecated endpoints and fields
+// @Summary Get deprecation usage
+// @Description Report, for every deprecated endpoint and field configured in DEPRECATIONS, the requests that used it over a range of days and the clients that made them, most active first. A deprecation without requests is listed with none, so it can be removed without breaking a client seen in the range (admin only)
+// @Tags admin
+// @Produce json
+// @Param from query string false "First day, YYYY-MM-DD (default: 29 days before to)"
+// @Param to query string false "Last day, YYYY-MM-DD (default: today)"
+// @Success 200 {object} DeprecationReport
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/usage/deprecations [get]
+func (h *UsageHandler) GetDeprecationUsage(c *gin.Context) {
+	from, to, ok := reportDays(c, defaultUsageDays)
+	if !ok {
+		return
+	}
+
+	db := readDB(c, h.db)
+	report := DeprecationReport{From: from, To: to, Data: []DeprecationUsage{}}
+	for _, deprecation := range h.tracker.Deprecations() {
+		entry := DeprecationUsage{Deprecation: deprecation, Clients: []EndpointUsage{}}
+		if err := db.Model(&models.APIUsage{}).
+			Select("method, route, field, user_id, api_key, user_agent, SUM(requests) AS requests, MIN(day) AS first_seen_on, MAX(day) AS last_seen_on").
+			Where("method = ? AND route = ? AND field = ? AND day BETWEEN ? AND ?",
+				deprecation.Method, deprecation.Route, deprecation.Field, from, to).
+			Group("method, route, field, user_id, api_key, user_agent").
+			Order("requests DESC, user_id, api_key, user_agent").
+			Scan(&entry.Clients).Error; err != nil {
+			respondError(c, http.StatusInternalServerError, ErrorResponse{
+				Error:   "Failed to fetch API usage",
+				Message: err.Error(),
+				Code:    "DATABASE_ERROR",
+			})
+			return
+		}
+		for _, client := range entry.Clients {
+			entry.Requests += client.Requests
+			if client.LastSeenOn > entry.LastSeenOn {
+				entry.LastSeenOn = client.LastSeenOn
+			}
+		}
+		report.Data = append(report.Data, entry)
+	}
+
+	c.JSON(http.StatusOK, report)
+}
+
+// reportDays returns the range of days of a report, by default the given number of
+// days up to today, writing the error response when the from and to parameters are
+// invalid
+func reportDays(c *gin.Context, days int) (string, string, bool) {
+	to := time.Now().UTC()
+	if value := c.Query("to"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid date",
+				Message: "to must be a YYYY-MM-DD date",
+				Code:    "INVALID_DATE",
+			})
+			return "", "", false
+		}
+		to = parsed
+	}
+	from := to.AddDate(0, 0, 1-days)
+	if value := c.Query("from"); value != "" {
+		parsed, err := time.Parse("2006-01-02", value)
+		if err != nil {
+			respondError(c, http.StatusBadRequest, ErrorResponse{
+				Error:   "Invalid date",
+				Message: "from must be a YYYY-MM-DD date",
+				Code:    "INVALID_DATE",
+			})
+			return "", "", false
+		}
+		from = parsed
+	}
+	if to.Before(from) {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid date range",
+			Message: "to must be on or after from",
+			Code:    "INVALID_DATE_RANGE",
+		})
+		return "", "", false
+	}
+	return from.Format("2006-01-02"), to.Format("2006-01-02"), true
+}
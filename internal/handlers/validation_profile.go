@@ -0,0 +1,281 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/go-playground/validator/v10"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/validation"
+	"gorm.io/gorm"
+)
+
+// ValidationProfileHandler handles HTTP requests for configurable validation profiles
+type ValidationProfileHandler struct {
+	db        *gorm.DB
+	validator *validator.Validate
+	profiles  *validation.ProfileService
+}
+
+// NewValidationProfileHandler creates a new validation profile handler
+func NewValidationProfileHandler(db *gorm.DB, profiles *validation.ProfileService) *ValidationProfileHandler {
+	return &ValidationProfileHandler{
+		db:        db,
+		validator: validator.New(),
+		profiles:  profiles,
+	}
+}
+
+// CreateProfile creates a validation profile
+// @Summary Create validation profile
+// @Description Create a set of required, forbidden and pattern field rules for a resource type (admin only)
+// @Tags validation-profiles
+// @Accept json
+// @Produce json
+// @Param profile body models.ValidationProfile true "Profile data"
+// @Success 201 {object} models.ValidationProfile
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/validation-profiles [post]
+func (h *ValidationProfileHandler) CreateProfile(c *gin.Context) {
+	var profile models.ValidationProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if !h.checkProfile(c, &profile) {
+		return
+	}
+
+	profile.ID = ""
+	profile.Active = true
+	if userID, exists := auth.GetUserID(c); exists {
+		profile.CreatedBy = userID
+	}
+
+	if err := h.db.Create(&profile).Error; err != nil {
+		respondError(c, http.StatusConflict, ErrorResponse{
+			Error:   "Failed to create validation profile",
+			Message: err.Error(),
+			Code:    "PROFILE_EXISTS",
+		})
+		return
+	}
+
+	h.reload(c)
+	c.JSON(http.StatusCreated, profile)
+}
+
+// GetProfiles lists validation profiles
+// @Summary Get validation profiles
+// @Description List validation profiles, optionally filtered by tenant and resource type (admin only)
+// @Tags validation-profiles
+// @Produce json
+// @Param tenant query string false "Filter by tenant"
+// @Param resourceType query string false "Filter by resource type"
+// @Success 200 {array} models.ValidationProfile
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/validation-profiles [get]
+func (h *ValidationProfileHandler) GetProfiles(c *gin.Context) {
+	query := h.db.Model(&models.ValidationProfile{})
+	if tenant, ok := c.GetQuery("tenant"); ok {
+		query = query.Where("tenant = ?", tenant)
+	}
+	if resourceType := c.Query("resourceType"); resourceType != "" {
+		query = query.Where("resource_type = ?", resourceType)
+	}
+
+	var profiles []models.ValidationProfile
+	if err := query.Order("tenant ASC, resource_type ASC, name ASC").Find(&profiles).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch validation profiles",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, profiles)
+}
+
+// UpdateProfile replaces the rules and settings of a validation profile
+// @Summary Update validation profile
+// @Description Replace a validation profile's rules, description and active flag (admin only)
+// @Tags validation-profiles
+// @Accept json
+// @Produce json
+// @Param id path string true "Profile ID"
+// @Param profile body models.ValidationProfile true "Profile data"
+// @Success 200 {object} models.ValidationProfile
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/validation-profiles/{id} [put]
+func (h *ValidationProfileHandler) UpdateProfile(c *gin.Context) {
+	var existing models.ValidationProfile
+	if !h.findProfile(c, &existing) {
+		return
+	}
+
+	var profile models.ValidationProfile
+	if err := c.ShouldBindJSON(&profile); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid request body",
+			Message: err.Error(),
+			Code:    "INVALID_REQUEST_BODY",
+		})
+		return
+	}
+
+	if !h.checkProfile(c, &profile) {
+		return
+	}
+
+	// Preserve ID and audit fields
+	profile.ID = existing.ID
+	profile.CreatedAt = existing.CreatedAt
+	profile.CreatedBy = existing.CreatedBy
+
+	if err := h.db.Select("*").Omit("created_at", "created_by").Save(&profile).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to update validation profile",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.reload(c)
+	c.JSON(http.StatusOK, profile)
+}
+
+// DeleteProfile deletes a validation profile
+// @Summary Delete validation profile
+// @Description Delete a validation profile (admin only)
+// @Tags validation-profiles
+// @Param id path string true "Profile ID"
+// @Success 204 "No Content"
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/validation-profiles/{id} [delete]
+func (h *ValidationProfileHandler) DeleteProfile(c *gin.Context) {
+	var profile models.ValidationProfile
+	if !h.findProfile(c, &profile) {
+		return
+	}
+
+	if err := h.db.Delete(&profile).Error; err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to delete validation profile",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return
+	}
+
+	h.reload(c)
+	c.Status(http.StatusNoContent)
+}
+
+// findProfile loads the profile named by the id path parameter and writes the
+// error response when it cannot be found
+func (h *ValidationProfileHandler) findProfile(c *gin.Context, profile *models.ValidationProfile) bool {
+	if err := h.db.Where("id = ?", c.Param("id")).First(profile).Error; err != nil {
+		if err == gorm.ErrRecordNotFound {
+			respondError(c, http.StatusNotFound, ErrorResponse{
+				Error: "Validation profile not found",
+				Code:  "PROFILE_NOT_FOUND",
+			})
+			return false
+		}
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to fetch validation profile",
+			Message: err.Error(),
+			Code:    "DATABASE_ERROR",
+		})
+		return false
+	}
+	return true
+}
+
+// checkProfile validates a profile and its rules and writes the error response on failure
+func (h *ValidationProfileHandler) checkProfile(c *gin.Context, profile *models.ValidationProfile) bool {
+	if err := h.validator.Struct(profile); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Validation failed",
+			Message: err.Error(),
+			Code:    "VALIDATION_FAILED",
+		})
+		return false
+	}
+
+	if err := h.profiles.Check(profile); err != nil {
+		respondError(c, http.StatusBadRequest, ErrorResponse{
+			Error:   "Invalid validation rule",
+			Message: err.Error(),
+			Code:    "INVALID_PROFILE_RULE",
+		})
+		return false
+	}
+	return true
+}
+
+// reload refreshes the cached rules after a change
+func (h *ValidationProfileHandler) reload(c *gin.Context) {
+	if err := h.profiles.Reload(); err != nil {
+		c.Header("Warning", `199 - "validation profile cache not refreshed"`)
+	}
+}
+
+// tenantID returns the tenant selected for the request, if any
+func tenantID(c *gin.Context) string {
+	return strings.TrimSpace(c.GetHeader(validation.TenantHeader))
+}
+
+// respondProfileViolations writes the error response for profile rule violations.
+// It returns false when there was nothing to report.
+func respondProfileViolations(c *gin.Context, violations map[string]string, err error) bool {
+	if err != nil {
+		respondError(c, http.StatusInternalServerError, ErrorResponse{
+			Error:   "Failed to apply validation profile",
+			Message: err.Error(),
+			Code:    "PROFILE_VALIDATION_ERROR",
+		})
+		return true
+	}
+	if len(violations) == 0 {
+		return false
+	}
+
+	var messages []string
+	for _, path := range validation.SortedPaths(violations) {
+		messages = append(messages, fmt.Sprintf("%s %s", path, violations[path]))
+	}
+	respondError(c, http.StatusBadRequest, ErrorResponse{
+		Error:   "Validation failed",
+		Message: strings.Join(messages, "; "),
+		Code:    "PROFILE_VALIDATION_FAILED",
+		Details: violations,
+	})
+	return true
+}
@@ -0,0 +1,50 @@
+package handlers
+
+import (
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/warmup"
+	"gorm.io/gorm"
+)
+
+// warmupID matches no record; the warm-up queries look it up so their statements are
+// prepared without reading any
+const warmupID = "00000000-0000-0000-0000-000000000000"
+
+// WarmupQueries returns the hottest queries of the handlers, built the way the handlers
+// build them so the statements the warm-up prepares are the ones requests run: signing
+// in, reading a patient, their links and the first page of their observations, and
+// reading an observation. Unfiltered lists are left out, as counting them reads whole
+// tables.
+func WarmupQueries() []warmup.Query {
+	return []warmup.Query{
+		{Name: "login", Run: func(tx *gorm.DB) error {
+			var user models.User
+			return tx.Preload("Roles").Where("email = ? AND active = ?", "warmup@healthhub.invalid", true).First(&user).Error
+		}},
+		{Name: "patient survivor", Run: func(tx *gorm.DB) error {
+			var link models.PatientLink
+			return tx.Scopes(models.LiveLinks).Where("patient_id = ? AND type = ?", warmupID, models.PatientLinkReplacedBy).First(&link).Error
+		}},
+		{Name: "patient", Run: func(tx *gorm.DB) error {
+			var patient models.Patient
+			return tx.Where("id = ?", warmupID).First(&patient).Error
+		}},
+		{Name: "patient links", Run: func(tx *gorm.DB) error {
+			var links []models.PatientLink
+			return tx.Scopes(models.LiveLinks).Where("patient_id = ?", warmupID).Order("created_at ASC").Find(&links).Error
+		}},
+		{Name: "observation", Run: func(tx *gorm.DB) error {
+			var observation models.Observation
+			return tx.Where("id = ?", warmupID).First(&observation).Error
+		}},
+		{Name: "patient observations", Run: func(tx *gorm.DB) error {
+			var total int64
+			var observations []models.Observation
+			query := models.ObservationFilter{Patient: warmupID}.Apply(tx.Model(&models.Observation{}))
+			if err := query.Count(&total).Error; err != nil {
+				return err
+			}
+			return query.Order("effective_date_time DESC").Offset(0).Limit(10).Find(&observations).Error
+		}},
+	}
+}
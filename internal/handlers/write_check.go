@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/writecheck"
+)
+
+// WriteCheckHandler reports the checks resources pass before they are written
+type WriteCheckHandler struct {
+	checks *writecheck.Registry
+}
+
+// NewWriteCheckHandler creates a new write check handler
+func NewWriteCheckHandler(checks *writecheck.Registry) *WriteCheckHandler {
+	return &WriteCheckHandler{checks: checks}
+}
+
+// WriteChecksResponse lists the registered write checks
+type WriteChecksResponse struct {
+	Data []writecheck.CheckStatus `json:"data"`
+}
+
+// GetWriteChecks lists the write checks and whether each runs
+// @Summary Get write checks
+// @Description List the checks each resource type passes before it is created, in the order they run, with whether the deployment runs each. Checks are turned on and off with WRITE_CHECKS_ENABLED and WRITE_CHECKS_DISABLED (admin only)
+// @Tags admin
+// @Produce json
+// @Success 200 {object} WriteChecksResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Security BearerAuth
+// @Router /api/v1/admin/write-checks [get]
+func (h *WriteCheckHandler) GetWriteChecks(c *gin.Context) {
+	c.JSON(http.StatusOK, WriteChecksResponse{Data: h.checks.Checks()})
+}
+
+// respondCheckFailure writes the response of a write that a check rejected with 400,
+// or 409 when it conflicts with a stored resource, or that a check could not run on.
+// It reports whether a response was written.
+func respondCheckFailure(c *gin.Context, err error) bool {
+	if err == nil {
+		return false
+	}
+
+	var rejection *writecheck.Rejection
+	if errors.As(err, &rejection) {
+		status := http.StatusBadRequest
+		if rejection.Conflict {
+			status = http.StatusConflict
+		}
+		respondError(c, status, ErrorResponse{
+			Error:   rejection.Reason,
+			Message: rejection.Message,
+			Code:    rejection.Code,
+		})
+		return true
+	}
+
+	respondError(c, http.StatusInternalServerError, ErrorResponse{
+		Error:   "Failed to run write check",
+		Message: err.Error(),
+		Code:    "WRITE_CHECK_ERROR",
+	})
+	return true
+}
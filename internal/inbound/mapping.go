@@ -0,0 +1,173 @@
+package inbound
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/validation"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// FacilityHeader names the facility that sent a message, such as the HL7 MSH-4
+// sending facility; facility mappings select its tenant
+const FacilityHeader = "X-Sending-Facility"
+
+// reloadInterval is how often mappings changed on other instances are picked up
+const reloadInterval = 15 * time.Second
+
+// mappings are the mappings of one integration
+type mappings struct {
+	systems    map[string]string // Coding system sent → internal code system
+	facilities map[string]string // Sending facility → tenant
+}
+
+// Mapper applies the field mappings of integrations to their messages
+type Mapper struct {
+	db *gorm.DB
+
+	mu       sync.RWMutex
+	mappings map[string]*mappings // By credential ID
+}
+
+// NewMapper creates a new mapper. Mappings apply once loaded by Reload or Run.
+func NewMapper(db *gorm.DB) *Mapper {
+	return &Mapper{db: db}
+}
+
+// Run reloads the mappings until the context is cancelled
+func (m *Mapper) Run(ctx context.Context) {
+	ticker := time.NewTicker(reloadInterval)
+	defer ticker.Stop()
+
+	for {
+		if err := m.Reload(ctx); err != nil {
+			logger.Warn("Failed to load integration mappings", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Reload loads the mappings
+func (m *Mapper) Reload(ctx context.Context) error {
+	var rows []models.IntegrationMapping
+	if err := m.db.WithContext(ctx).Find(&rows).Error; err != nil {
+		return fmt.Errorf("failed to load integration mappings: %w", err)
+	}
+
+	loaded := make(map[string]*mappings)
+	for _, row := range rows {
+		mapped := loaded[row.CredentialID]
+		if mapped == nil {
+			mapped = &mappings{systems: make(map[string]string), facilities: make(map[string]string)}
+			loaded[row.CredentialID] = mapped
+		}
+		switch row.Kind {
+		case models.MappingCodeSystem:
+			mapped.systems[row.Source] = row.Target
+		case models.MappingFacility:
+			mapped.facilities[row.Source] = row.Target
+		}
+	}
+
+	m.mu.Lock()
+	m.mappings = loaded
+	m.mu.Unlock()
+	return nil
+}
+
+// Middleware applies the mappings of the integration that signed the request: it
+// selects the tenant of the sending facility and rewrites mapped coding systems in
+// a JSON body. It must follow the verifier's middleware.
+func (m *Mapper) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		m.mu.RLock()
+		mapped := m.mappings[c.GetString(CredentialKey)]
+		m.mu.RUnlock()
+		if mapped == nil {
+			c.Next()
+			return
+		}
+
+		if facility := strings.TrimSpace(c.GetHeader(FacilityHeader)); facility != "" {
+			if tenant, ok := mapped.facilities[facility]; ok {
+				c.Request.Header.Set(validation.TenantHeader, tenant)
+			}
+		}
+
+		if len(mapped.systems) > 0 && c.Request.Body != nil && strings.Contains(c.ContentType(), "json") {
+			body, err := io.ReadAll(c.Request.Body)
+			if err == nil {
+				body = mapped.rewrite(body)
+			}
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+			c.Request.ContentLength = int64(len(body))
+		}
+		c.Next()
+	}
+}
+
+// rewrite replaces the mapped systems of the codings in a JSON body. A body that is
+// not valid JSON is returned unchanged for the handler to reject.
+func (m *mappings) rewrite(body []byte) []byte {
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return body
+	}
+	if !m.rewriteNode(doc) {
+		return body
+	}
+	rewritten, err := json.Marshal(doc)
+	if err != nil {
+		return body
+	}
+	return rewritten
+}
+
+// rewriteNode replaces the mapped systems of the codings below a node and reports
+// whether any was replaced
+func (m *mappings) rewriteNode(node interface{}) bool {
+	changed := false
+	switch n := node.(type) {
+	case map[string]interface{}:
+		if codings, ok := n["coding"].([]interface{}); ok {
+			for _, coding := range codings {
+				fields, ok := coding.(map[string]interface{})
+				if !ok {
+					continue
+				}
+				system, _ := fields["system"].(string)
+				if target, ok := m.systems[system]; ok {
+					fields["system"] = target
+					changed = true
+				}
+			}
+		}
+		for _, child := range n {
+			if m.rewriteNode(child) {
+				changed = true
+			}
+		}
+	case []interface{}:
+		for _, child := range n {
+			if m.rewriteNode(child) {
+				changed = true
+			}
+		}
+	}
+	return changed
+}
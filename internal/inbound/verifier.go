@@ -0,0 +1,280 @@
+// Package inbound authenticates inbound integrations, such as lab interfaces and
+// devices pushing results, by HMAC-signed requests. A signature covers the request's
+// timestamp, nonce, method, URI and body; requests outside the credential's
+// timestamp tolerance are refused, and a nonce is accepted once, so a captured
+// request cannot be replayed.
+package inbound
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+	"github.com/hillmatthew2000/HealthHub/pkg/encryption"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Request headers of a signed request
+const (
+	KeyIDHeader     = "X-Signature-Key-Id"
+	TimestampHeader = "X-Signature-Timestamp" // Unix seconds
+	NonceHeader     = "X-Signature-Nonce"
+	SignatureHeader = "X-Signature" // sha256=<hex HMAC>
+)
+
+// CredentialKey is the context key of the ID of the credential a request was
+// signed with
+const CredentialKey = "integration_id"
+
+// DefaultTolerance is the timestamp tolerance of credentials that name none
+const DefaultTolerance = 5 * time.Minute
+
+// maxBodyBytes bounds the body of a signed request
+const maxBodyBytes = 10 << 20
+
+// Nonce length bounds, in characters
+const (
+	minNonceLength = 16
+	maxNonceLength = 128
+)
+
+// purgeInterval is how often expired nonces are removed
+const purgeInterval = time.Minute
+
+// Verifier verifies signed requests against integration credentials
+type Verifier struct {
+	db        *gorm.DB
+	encryptor *encryption.Encryptor
+}
+
+// NewVerifier creates a new verifier. Credential secrets are encrypted with the
+// encryptor.
+func NewVerifier(db *gorm.DB, encryptor *encryption.Encryptor) *Verifier {
+	return &Verifier{db: db, encryptor: encryptor}
+}
+
+// Issue creates a credential with a new random secret and returns it with the
+// secret, which is not retrievable later
+func (v *Verifier) Issue(ctx context.Context, req models.IntegrationCredentialRequest, createdBy string) (*models.IntegrationCredential, string, error) {
+	raw := make([]byte, 32)
+	if _, err := rand.Read(raw); err != nil {
+		return nil, "", fmt.Errorf("failed to generate secret: %w", err)
+	}
+	secret := base64.RawURLEncoding.EncodeToString(raw)
+	encrypted, err := v.encryptor.Encrypt(secret)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to encrypt secret: %w", err)
+	}
+
+	tolerance := req.ToleranceSeconds
+	if tolerance == 0 {
+		tolerance = int(DefaultTolerance.Seconds())
+	}
+	credential := &models.IntegrationCredential{
+		Name:             req.Name,
+		Secret:           encrypted,
+		UserID:           req.UserID,
+		ToleranceSeconds: tolerance,
+		CreatedBy:        createdBy,
+	}
+	if err := v.db.WithContext(ctx).Create(credential).Error; err != nil {
+		return nil, "", err
+	}
+	return credential, secret, nil
+}
+
+// Run removes expired nonces until the context is cancelled
+func (v *Verifier) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := v.db.WithContext(ctx).Where("expires_at < ?", time.Now()).Delete(&models.IntegrationNonce{}).Error; err != nil {
+				logger.Warn("Failed to purge integration nonces", zap.Error(err))
+			}
+		}
+	}
+}
+
+// Middleware authenticates signed requests and lets them act as the credential's
+// service account, so the role checks of the routes apply as for a token
+func (v *Verifier) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		keyID := c.GetHeader(KeyIDHeader)
+		timestamp := c.GetHeader(TimestampHeader)
+		nonce := c.GetHeader(NonceHeader)
+		signature := c.GetHeader(SignatureHeader)
+		if keyID == "" || timestamp == "" || nonce == "" || signature == "" {
+			reject(c, "Signed request required", "SIGNATURE_REQUIRED")
+			return
+		}
+		if len(nonce) < minNonceLength || len(nonce) > maxNonceLength {
+			reject(c, fmt.Sprintf("Nonce must be %d to %d characters", minNonceLength, maxNonceLength), "INVALID_NONCE")
+			return
+		}
+		unix, err := strconv.ParseInt(timestamp, 10, 64)
+		if err != nil {
+			reject(c, "Invalid signature timestamp", "INVALID_TIMESTAMP")
+			return
+		}
+		signedAt := time.Unix(unix, 0)
+
+		var credential models.IntegrationCredential
+		if err := v.db.Where("id = ? AND revoked_at IS NULL", keyID).First(&credential).Error; err != nil {
+			if err != gorm.ErrRecordNotFound {
+				logger.Error("Failed to load integration credential", zap.String("key_id", keyID), zap.Error(err))
+			}
+			reject(c, "Invalid signature", "INVALID_SIGNATURE")
+			return
+		}
+		tolerance := time.Duration(credential.ToleranceSeconds) * time.Second
+		if skew := time.Since(signedAt); skew > tolerance || skew < -tolerance {
+			reject(c, "Signature timestamp outside tolerance", "SIGNATURE_EXPIRED")
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(c.Request.Body, maxBodyBytes+1))
+		if err != nil {
+			reject(c, "Failed to read request body", "INVALID_REQUEST_BODY")
+			return
+		}
+		if len(body) > maxBodyBytes {
+			apierror.Abort(c, http.StatusRequestEntityTooLarge, apierror.Response{
+				Error: "Request body too large",
+				Code:  "REQUEST_TOO_LARGE",
+			})
+			return
+		}
+		c.Request.Body = io.NopCloser(bytes.NewReader(body))
+
+		secret, err := v.encryptor.Decrypt(credential.Secret)
+		if err != nil {
+			logger.Error("Failed to decrypt integration credential", zap.String("key_id", keyID), zap.Error(err))
+			reject(c, "Invalid signature", "INVALID_SIGNATURE")
+			return
+		}
+		expected := Sign(secret, timestamp, nonce, c.Request.Method, c.Request.URL.RequestURI(), body)
+		if !hmac.Equal([]byte(expected), []byte(signature)) {
+			logger.LogAuditEvent("signature_invalid", "IntegrationCredential", credential.UserID, map[string]interface{}{
+				"credential_id": credential.ID,
+				"path":          c.Request.URL.Path,
+				"client_ip":     c.ClientIP(),
+			})
+			reject(c, "Invalid signature", "INVALID_SIGNATURE")
+			return
+		}
+
+		// The nonce is kept until the timestamp leaves the tolerance; a second
+		// request with it is a replay
+		result := v.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&models.IntegrationNonce{
+			CredentialID: credential.ID,
+			Nonce:        nonce,
+			ExpiresAt:    signedAt.Add(tolerance),
+		})
+		if result.Error != nil {
+			logger.Error("Failed to record integration nonce", zap.String("key_id", keyID), zap.Error(result.Error))
+			apierror.Abort(c, http.StatusInternalServerError, apierror.Response{
+				Error: "Failed to verify request",
+				Code:  "DATABASE_ERROR",
+			})
+			return
+		}
+		if result.RowsAffected == 0 {
+			logger.LogAuditEvent("replay_rejected", "IntegrationCredential", credential.UserID, map[string]interface{}{
+				"credential_id": credential.ID,
+				"path":          c.Request.URL.Path,
+				"client_ip":     c.ClientIP(),
+			})
+			reject(c, "Request already received", "REPLAYED_REQUEST")
+			return
+		}
+
+		var user models.User
+		if err := v.db.Preload("Roles").Where("id = ? AND active = ?", credential.UserID, true).First(&user).Error; err != nil {
+			reject(c, "Integration account is not active", "ACCOUNT_INACTIVE")
+			return
+		}
+		roles := make([]string, len(user.Roles))
+		for i, role := range user.Roles {
+			roles[i] = role.Name
+		}
+
+		now := time.Now()
+		if err := v.db.Model(&credential).UpdateColumn("last_used_at", now).Error; err != nil {
+			logger.Warn("Failed to record integration credential use", zap.String("key_id", keyID), zap.Error(err))
+		}
+
+		c.Set("user_id", user.ID)
+		c.Set("user_email", user.Email)
+		c.Set("user_roles", roles)
+		c.Set("claims", &auth.Claims{UserID: user.ID, Email: user.Email, Roles: roles})
+		c.Set(CredentialKey, credential.ID)
+		c.Next()
+	}
+}
+
+// SignRequest signs a request with the credential, as its integration would, so
+// a message can be resubmitted on the integration's behalf
+func (v *Verifier) SignRequest(ctx context.Context, req *http.Request, credentialID string, body []byte) error {
+	var credential models.IntegrationCredential
+	if err := v.db.WithContext(ctx).Where("id = ? AND revoked_at IS NULL", credentialID).First(&credential).Error; err != nil {
+		return fmt.Errorf("failed to load integration credential: %w", err)
+	}
+	secret, err := v.encryptor.Decrypt(credential.Secret)
+	if err != nil {
+		return fmt.Errorf("failed to decrypt integration credential: %w", err)
+	}
+
+	raw := make([]byte, 24)
+	if _, err := rand.Read(raw); err != nil {
+		return fmt.Errorf("failed to generate nonce: %w", err)
+	}
+	nonce := base64.RawURLEncoding.EncodeToString(raw)
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set(KeyIDHeader, credential.ID)
+	req.Header.Set(TimestampHeader, timestamp)
+	req.Header.Set(NonceHeader, nonce)
+	req.Header.Set(SignatureHeader, Sign(secret, timestamp, nonce, req.Method, req.URL.RequestURI(), body))
+	return nil
+}
+
+// Sign returns the signature of a request: the hex HMAC-SHA256, keyed by the
+// credential's secret, of the timestamp, nonce, method, URI and body joined by
+// newlines, prefixed with "sha256="
+func Sign(secret, timestamp, nonce, method, uri string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strings.Join([]string{timestamp, nonce, method, uri}, "\n")))
+	mac.Write([]byte{'\n'})
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// reject refuses an unauthenticated request
+func reject(c *gin.Context, message, code string) {
+	apierror.Abort(c, http.StatusUnauthorized, apierror.Response{
+		Error: message,
+		Code:  code,
+	})
+}
@@ -0,0 +1,325 @@
+package indexing
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/opensearch"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ConsumerName is the outbox consumer name used by the indexer
+const ConsumerName = "search-indexer"
+
+// Indexer mirrors patients and observations into OpenSearch and serves searches against them
+type Indexer struct {
+	db     *gorm.DB
+	client *opensearch.Client
+	prefix string
+}
+
+// PatientDocument is the indexed representation of a patient
+type PatientDocument struct {
+	ID         string     `json:"id"`
+	Active     bool       `json:"active"`
+	Name       string     `json:"name"`
+	Family     []string   `json:"family"`
+	Given      []string   `json:"given"`
+	Identifier []string   `json:"identifier"`
+	Gender     string     `json:"gender"`
+	BirthDate  *time.Time `json:"birthDate,omitempty"`
+	Telecom    []string   `json:"telecom"`
+	City       []string   `json:"city"`
+	PostalCode []string   `json:"postalCode"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+}
+
+// ObservationDocument is the indexed representation of an observation
+type ObservationDocument struct {
+	ID                string    `json:"id"`
+	Status            string    `json:"status"`
+	Display           string    `json:"display"`
+	CodeText          string    `json:"codeText"`
+	Codes             []string  `json:"codes"`
+	Category          []string  `json:"category"`
+	Subject           string    `json:"subject"`
+	PatientID         string    `json:"patientId"`
+	EffectiveDateTime time.Time `json:"effectiveDateTime"`
+	Value             *float64  `json:"value,omitempty"`
+	Unit              string    `json:"unit,omitempty"`
+	ValueDisplay      string    `json:"valueDisplay"`
+	UpdatedAt         time.Time `json:"updatedAt"`
+}
+
+// Hit represents a single search hit against the index
+type Hit struct {
+	ResourceType string          `json:"resourceType"`
+	ID           string          `json:"id"`
+	Score        float64         `json:"score"`
+	Document     json.RawMessage `json:"document"`
+}
+
+// NewIndexer creates a new indexer writing to indices named after prefix
+func NewIndexer(db *gorm.DB, client *opensearch.Client, prefix string) *Indexer {
+	return &Indexer{
+		db:     db,
+		client: client,
+		prefix: prefix,
+	}
+}
+
+// PatientIndex returns the name of the patient index
+func (i *Indexer) PatientIndex() string {
+	return i.prefix + "-patients"
+}
+
+// ObservationIndex returns the name of the observation index
+func (i *Indexer) ObservationIndex() string {
+	return i.prefix + "-observations"
+}
+
+// EnsureIndices creates missing indices and brings existing mappings up to date. It
+// reports whether a backfill is needed because an index was created or its mapping changed.
+func (i *Indexer) EnsureIndices(ctx context.Context) (bool, error) {
+	needsBackfill := false
+
+	for index, properties := range map[string]map[string]interface{}{
+		i.PatientIndex():     patientProperties,
+		i.ObservationIndex(): observationProperties,
+	} {
+		mapping := map[string]interface{}{
+			"_meta":      map[string]interface{}{"mappingVersion": MappingVersion},
+			"dynamic":    "strict",
+			"properties": properties,
+		}
+
+		exists, err := i.client.IndexExists(ctx, index)
+		if err != nil {
+			return false, fmt.Errorf("failed to check index %s: %w", index, err)
+		}
+
+		if !exists {
+			if err := i.client.CreateIndex(ctx, index, map[string]interface{}{
+				"settings": indexSettings,
+				"mappings": mapping,
+			}); err != nil {
+				return false, fmt.Errorf("failed to create index %s: %w", index, err)
+			}
+			logger.Info("Created search index", zap.String("index", index), zap.Int("mapping_version", MappingVersion))
+			needsBackfill = true
+			continue
+		}
+
+		meta, err := i.client.GetMappingMeta(ctx, index)
+		if err != nil {
+			return false, fmt.Errorf("failed to read mapping for index %s: %w", index, err)
+		}
+		if version, _ := meta["mappingVersion"].(float64); int(version) >= MappingVersion {
+			continue
+		}
+
+		if err := i.client.PutMapping(ctx, index, mapping); err != nil {
+			return false, fmt.Errorf("failed to update mapping for index %s (incompatible changes require dropping the index): %w", index, err)
+		}
+		logger.Info("Updated search index mapping", zap.String("index", index), zap.Int("mapping_version", MappingVersion))
+		needsBackfill = true
+	}
+
+	return needsBackfill, nil
+}
+
+// Backfill indexes every patient and observation currently in the database
+func (i *Indexer) Backfill(ctx context.Context) error {
+	var patients []models.Patient
+	if err := i.db.WithContext(ctx).FindInBatches(&patients, 500, func(tx *gorm.DB, batch int) error {
+		for idx := range patients {
+			if err := i.indexPatient(ctx, &patients[idx]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error; err != nil {
+		return fmt.Errorf("failed to backfill patients: %w", err)
+	}
+
+	var observations []models.Observation
+	if err := i.db.WithContext(ctx).FindInBatches(&observations, 500, func(tx *gorm.DB, batch int) error {
+		for idx := range observations {
+			if err := i.indexObservation(ctx, &observations[idx]); err != nil {
+				return err
+			}
+		}
+		return nil
+	}).Error; err != nil {
+		return fmt.Errorf("failed to backfill observations: %w", err)
+	}
+
+	return nil
+}
+
+// HandleEvent applies an outbox event to the index. The current row is always reloaded,
+// so replaying or reordering events converges on the database state.
+func (i *Indexer) HandleEvent(ctx context.Context, event models.OutboxEvent) error {
+	switch event.ResourceType {
+	case "Patient":
+		var patient models.Patient
+		err := i.db.WithContext(ctx).Where("id = ?", event.ResourceID).First(&patient).Error
+		if err == gorm.ErrRecordNotFound {
+			if err := i.client.DeleteDocument(ctx, i.PatientIndex(), event.ResourceID); err != nil {
+				return err
+			}
+			// Observations are removed with their patient in a single statement, which
+			// does not emit per-row events
+			return i.client.DeleteByQuery(ctx, i.ObservationIndex(), map[string]interface{}{
+				"term": map[string]interface{}{"patientId": event.ResourceID},
+			})
+		}
+		if err != nil {
+			return err
+		}
+		return i.indexPatient(ctx, &patient)
+
+	case "Observation":
+		var observation models.Observation
+		err := i.db.WithContext(ctx).Where("id = ?", event.ResourceID).First(&observation).Error
+		if err == gorm.ErrRecordNotFound {
+			return i.client.DeleteDocument(ctx, i.ObservationIndex(), event.ResourceID)
+		}
+		if err != nil {
+			return err
+		}
+		return i.indexObservation(ctx, &observation)
+	}
+
+	return nil
+}
+
+// Search runs a full-text query against the given resource types ("patient", "observation")
+func (i *Indexer) Search(ctx context.Context, q string, types []string, from, size int) ([]Hit, int64, error) {
+	indexTypes := map[string]string{
+		i.PatientIndex():     "Patient",
+		i.ObservationIndex(): "Observation",
+	}
+
+	var indices []string
+	for _, t := range types {
+		switch strings.ToLower(t) {
+		case "patient":
+			indices = append(indices, i.PatientIndex())
+		case "observation":
+			indices = append(indices, i.ObservationIndex())
+		}
+	}
+	if len(indices) == 0 {
+		indices = []string{i.PatientIndex(), i.ObservationIndex()}
+	}
+
+	body := map[string]interface{}{
+		"from": from,
+		"size": size,
+		"query": map[string]interface{}{
+			"bool": map[string]interface{}{
+				"should": []interface{}{
+					map[string]interface{}{
+						"multi_match": map[string]interface{}{
+							"query":     q,
+							"fields":    []string{"name^3", "family^2", "given^2", "display^3", "codeText^2", "valueDisplay", "city"},
+							"fuzziness": "AUTO",
+						},
+					},
+					map[string]interface{}{
+						"multi_match": map[string]interface{}{
+							"query":  q,
+							"fields": []string{"identifier^5", "codes^4", "telecom^2", "postalCode"},
+						},
+					},
+				},
+				"minimum_should_match": 1,
+			},
+		},
+	}
+
+	response, err := i.client.Search(ctx, indices, body)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	hits := make([]Hit, 0, len(response.Hits.Hits))
+	for _, hit := range response.Hits.Hits {
+		hits = append(hits, Hit{
+			ResourceType: indexTypes[hit.Index],
+			ID:           hit.ID,
+			Score:        hit.Score,
+			Document:     hit.Source,
+		})
+	}
+
+	return hits, response.Hits.Total.Value, nil
+}
+
+// indexPatient writes a patient document, versioned by its last update time
+func (i *Indexer) indexPatient(ctx context.Context, patient *models.Patient) error {
+	doc := PatientDocument{
+		ID:        patient.ID,
+		Active:    patient.Active,
+		Name:      patient.GetFullName(),
+		Gender:    patient.Gender,
+		UpdatedAt: patient.UpdatedAt,
+	}
+	if !patient.BirthDate.IsZero() {
+		birthDate := patient.BirthDate
+		doc.BirthDate = &birthDate
+	}
+	for _, name := range patient.Name {
+		doc.Family = append(doc.Family, name.Family)
+		doc.Given = append(doc.Given, name.Given...)
+	}
+	for _, identifier := range patient.Identifier {
+		doc.Identifier = append(doc.Identifier, identifier.Value)
+	}
+	for _, contact := range patient.Telecom {
+		doc.Telecom = append(doc.Telecom, contact.Value)
+	}
+	for _, address := range patient.Address {
+		doc.City = append(doc.City, address.City)
+		doc.PostalCode = append(doc.PostalCode, address.PostalCode)
+	}
+
+	return i.client.IndexDocument(ctx, i.PatientIndex(), patient.ID, patient.UpdatedAt.UnixNano(), doc)
+}
+
+// indexObservation writes an observation document, versioned by its last update time
+func (i *Indexer) indexObservation(ctx context.Context, observation *models.Observation) error {
+	doc := ObservationDocument{
+		ID:                observation.ID,
+		Status:            observation.Status,
+		Display:           observation.GetCodeDisplay(),
+		CodeText:          observation.Code.Text,
+		Subject:           observation.Subject.Reference,
+		PatientID:         strings.TrimPrefix(observation.Subject.Reference, "Patient/"),
+		EffectiveDateTime: observation.EffectiveDateTime,
+		ValueDisplay:      observation.GetDisplayValue(),
+		UpdatedAt:         observation.UpdatedAt,
+	}
+	for _, coding := range observation.Code.Coding {
+		doc.Codes = append(doc.Codes, coding.Code)
+	}
+	for _, category := range observation.Category {
+		for _, coding := range category.Coding {
+			doc.Category = append(doc.Category, coding.Code)
+		}
+	}
+	if observation.ValueQuantity != nil {
+		value := observation.ValueQuantity.Value
+		doc.Value = &value
+		doc.Unit = observation.ValueQuantity.Unit
+	}
+
+	return i.client.IndexDocument(ctx, i.ObservationIndex(), observation.ID, observation.UpdatedAt.UnixNano(), doc)
+}
@@ -0,0 +1,64 @@
+package indexing
+
+// MappingVersion is stored in each index's _meta. Bump it whenever the mappings below change
+// so existing indices are updated and backfilled on the next start.
+const MappingVersion = 1
+
+// indexSettings configures analysis shared by all indices
+var indexSettings = map[string]interface{}{
+	"analysis": map[string]interface{}{
+		"normalizer": map[string]interface{}{
+			"lowercase": map[string]interface{}{
+				"type":   "custom",
+				"filter": []string{"lowercase", "asciifolding"},
+			},
+		},
+	},
+}
+
+// textField is analyzed for full-text search and keeps a keyword subfield for sorting and exact matches
+var textField = map[string]interface{}{
+	"type": "text",
+	"fields": map[string]interface{}{
+		"keyword": map[string]interface{}{"type": "keyword", "ignore_above": 256},
+	},
+}
+
+// keywordField is matched exactly, ignoring case
+var keywordField = map[string]interface{}{
+	"type":       "keyword",
+	"normalizer": "lowercase",
+}
+
+// patientProperties maps PatientDocument fields
+var patientProperties = map[string]interface{}{
+	"id":         map[string]interface{}{"type": "keyword"},
+	"active":     map[string]interface{}{"type": "boolean"},
+	"name":       textField,
+	"family":     textField,
+	"given":      textField,
+	"identifier": keywordField,
+	"gender":     map[string]interface{}{"type": "keyword"},
+	"birthDate":  map[string]interface{}{"type": "date"},
+	"telecom":    keywordField,
+	"city":       textField,
+	"postalCode": keywordField,
+	"updatedAt":  map[string]interface{}{"type": "date"},
+}
+
+// observationProperties maps ObservationDocument fields
+var observationProperties = map[string]interface{}{
+	"id":                map[string]interface{}{"type": "keyword"},
+	"status":            map[string]interface{}{"type": "keyword"},
+	"display":           textField,
+	"codeText":          textField,
+	"codes":             keywordField,
+	"category":          map[string]interface{}{"type": "keyword"},
+	"subject":           map[string]interface{}{"type": "keyword"},
+	"patientId":         map[string]interface{}{"type": "keyword"},
+	"effectiveDateTime": map[string]interface{}{"type": "date"},
+	"value":             map[string]interface{}{"type": "double"},
+	"unit":              map[string]interface{}{"type": "keyword"},
+	"valueDisplay":      map[string]interface{}{"type": "text"},
+	"updatedAt":         map[string]interface{}{"type": "date"},
+}
@@ -0,0 +1,463 @@
+// Package integrity checks the patient references of clinical records. Patient
+// deletes and merges can leave records pointing at a patient that no longer exists,
+// is pending deletion, or was replaced by another record; the checker reports them
+// and re-points references to merged patients to the surviving record where that is
+// safe.
+package integrity
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// maxFindings bounds the findings kept per run; further problems are only counted
+const maxFindings = 10000
+
+// maxLinkDepth bounds how many replaced-by links are followed to a surviving record
+const maxLinkDepth = 10
+
+// lookupBatchSize bounds the patient IDs looked up per query
+const lookupBatchSize = 500
+
+// target is a kind of record that references a patient
+type target struct {
+	resourceType string
+	model        interface{}
+	column       string // Reference column; empty for the JSON subject of observations
+	bare         bool   // The column holds patient IDs rather than "Patient/{id}" references
+	locked       string // Condition of records that must not be changed, with the reason below
+	lockedReason string
+	events       bool // Batch updates must publish outbox events
+}
+
+// targets are the records whose patient references are checked
+var targets = []target{
+	{resourceType: "Observation", model: &models.Observation{}},
+	{resourceType: "ClinicalNote", model: &models.ClinicalNote{}, column: "subject_reference",
+		locked: "signed_at IS NOT NULL", lockedReason: "signed notes are immutable"},
+	{resourceType: "Condition", model: &models.Condition{}, column: "subject_reference"},
+	{resourceType: "AllergyIntolerance", model: &models.AllergyIntolerance{}, column: "subject_reference"},
+	{resourceType: "Procedure", model: &models.Procedure{}, column: "subject_reference"},
+	{resourceType: "CarePlan", model: &models.CarePlan{}, column: "subject_reference"},
+	{resourceType: "MedicationRequest", model: &models.MedicationRequest{}, column: "subject_reference"},
+	{resourceType: "MedicationAdministration", model: &models.MedicationAdministration{}, column: "subject_reference", events: true},
+	{resourceType: "Specimen", model: &models.Specimen{}, column: "subject_reference"},
+	{resourceType: "StandingOrder", model: &models.StandingOrder{}, column: "subject_reference"},
+	{resourceType: "ObservationSlot", model: &models.ObservationSlot{}, column: "patient_id", bare: true},
+	{resourceType: "QuestionnaireResponse", model: &models.QuestionnaireResponse{}, column: "subject_reference"},
+	{resourceType: "Media", model: &models.Media{}, column: "subject_reference"},
+	{resourceType: "DocumentReference", model: &models.DocumentReference{}, column: "subject_reference"},
+	{resourceType: "Coverage", model: &models.Coverage{}, column: "beneficiary_reference"},
+	{resourceType: "RelatedPerson", model: &models.RelatedPerson{}, column: "patient_reference"},
+}
+
+// problem is what is wrong with the reference to a patient
+type problem struct {
+	kind     string
+	survivor string // Record a replaced patient resolves to; empty when there is no safe one
+}
+
+// Checker runs queued integrity runs and queues one every interval
+type Checker struct {
+	db       *gorm.DB
+	interval time.Duration
+	repoint  bool
+	poll     time.Duration
+	wake     chan struct{}
+}
+
+// NewChecker creates a new checker. Scheduled runs are queued every interval, or
+// never when it is zero, and re-point references when repoint is set.
+func NewChecker(db *gorm.DB, interval time.Duration, repoint bool) *Checker {
+	return &Checker{
+		db:       db,
+		interval: interval,
+		repoint:  repoint,
+		poll:     time.Minute,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Repoints reports whether runs re-point references unless they say otherwise
+func (c *Checker) Repoints() bool {
+	return c.repoint
+}
+
+// Wake signals the checker that a run has been queued
+func (c *Checker) Wake() {
+	select {
+	case c.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run processes queued runs until the context is cancelled. Runs interrupted by a
+// restart are run again; re-pointing a reference twice has no further effect.
+func (c *Checker) Run(ctx context.Context) {
+	ticker := time.NewTicker(c.poll)
+	defer ticker.Stop()
+
+	for {
+		if err := c.schedule(ctx); err != nil {
+			logger.Warn("Failed to schedule integrity run", zap.Error(err))
+		}
+		for {
+			var run models.IntegrityRun
+			err := c.db.WithContext(ctx).Where("status IN ?", []string{models.IntegrityRunQueued, models.IntegrityRunRunning}).
+				Order("created_at ASC").First(&run).Error
+			if err != nil {
+				if err != gorm.ErrRecordNotFound {
+					logger.Warn("Failed to fetch integrity runs", zap.Error(err))
+				}
+				break
+			}
+			if err := c.process(ctx, &run); err != nil {
+				if ctx.Err() != nil {
+					return
+				}
+				c.fail(&run, err)
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-c.wake:
+		case <-ticker.C:
+		}
+	}
+}
+
+// schedule queues a run when none was queued within the interval
+func (c *Checker) schedule(ctx context.Context) error {
+	if c.interval <= 0 {
+		return nil
+	}
+	var recent int64
+	if err := c.db.WithContext(ctx).Model(&models.IntegrityRun{}).
+		Where("scheduled = ? AND created_at > ?", true, time.Now().Add(-c.interval)).
+		Count(&recent).Error; err != nil {
+		return err
+	}
+	if recent > 0 {
+		return nil
+	}
+	return c.db.WithContext(ctx).Create(&models.IntegrityRun{
+		Status:    models.IntegrityRunQueued,
+		Repoint:   c.repoint,
+		Scheduled: true,
+	}).Error
+}
+
+// process checks every target and records the run's findings
+func (c *Checker) process(ctx context.Context, run *models.IntegrityRun) error {
+	db := c.db.WithContext(ctx)
+	now := time.Now()
+	run.Status = models.IntegrityRunRunning
+	run.StartedAt = &now
+	run.Checked, run.Dangling, run.Repointed, run.Truncated = 0, 0, 0, false
+	run.Summary = make(map[string]int64)
+	if err := db.Model(run).Select("status", "started_at").Updates(run).Error; err != nil {
+		return err
+	}
+	// Findings of an interrupted attempt are replaced
+	if err := db.Where("run_id = ?", run.ID).Delete(&models.IntegrityFinding{}).Error; err != nil {
+		return fmt.Errorf("failed to clear findings: %w", err)
+	}
+
+	kept := 0
+	for _, t := range targets {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		findings, err := c.check(ctx, run, t)
+		if err != nil {
+			return fmt.Errorf("%s: %w", t.resourceType, err)
+		}
+		if room := maxFindings - kept; len(findings) > room {
+			findings, run.Truncated = findings[:room], true
+		}
+		if len(findings) > 0 {
+			if err := db.CreateInBatches(&findings, 500).Error; err != nil {
+				return fmt.Errorf("failed to record findings: %w", err)
+			}
+			kept += len(findings)
+		}
+	}
+
+	completed := time.Now()
+	run.Status = models.IntegrityRunCompleted
+	run.CompletedAt = &completed
+	if err := db.Model(run).Select("status", "checked", "dangling", "repointed", "summary", "truncated", "completed_at").
+		Updates(run).Error; err != nil {
+		return err
+	}
+
+	logger.Info("Integrity run completed",
+		zap.String("run_id", run.ID),
+		zap.Int64("checked", run.Checked),
+		zap.Int64("dangling", run.Dangling),
+		zap.Int64("repointed", run.Repointed),
+	)
+	return nil
+}
+
+// check finds the records of a target with a reference problem, re-pointing them
+// when the run says so and it is safe
+func (c *Checker) check(ctx context.Context, run *models.IntegrityRun, t target) ([]models.IntegrityFinding, error) {
+	db := c.db.WithContext(ctx)
+	column := t.column
+	if column == "" {
+		column = dialect.Of(db).JSONText("subject", "reference")
+	}
+
+	var checked int64
+	if err := db.Model(t.model).Count(&checked).Error; err != nil {
+		return nil, err
+	}
+	run.Checked += checked
+
+	var refs []string
+	if err := db.Model(t.model).Distinct(column).Pluck(column, &refs).Error; err != nil {
+		return nil, fmt.Errorf("failed to load references: %w", err)
+	}
+	patients := make(map[string]string, len(refs)) // Patient ID → stored reference
+	for _, ref := range refs {
+		id := ref
+		if !t.bare {
+			if !strings.HasPrefix(ref, "Patient/") {
+				continue
+			}
+			id = strings.TrimPrefix(ref, "Patient/")
+		}
+		if id != "" {
+			patients[id] = ref
+		}
+	}
+
+	problems, err := classify(db, patients)
+	if err != nil {
+		return nil, err
+	}
+
+	var findings []models.IntegrityFinding
+	for id, p := range problems {
+		ref := patients[id]
+		var ids []string
+		if err := db.Model(t.model).Where(column+" = ?", ref).Pluck("id", &ids).Error; err != nil {
+			return nil, fmt.Errorf("failed to load records: %w", err)
+		}
+		if len(ids) == 0 {
+			continue
+		}
+		run.Dangling += int64(len(ids))
+		run.Summary[t.resourceType+"/"+p.kind] += int64(len(ids))
+
+		repointed := map[string]bool{}
+		reasons := map[string]string{}
+		if p.kind == models.IntegrityReplaced {
+			switch {
+			case !run.Repoint:
+			case p.survivor == "":
+				for _, rid := range ids {
+					reasons[rid] = "the replacement chain has no surviving record"
+				}
+			default:
+				moved, locked, err := c.repointRecords(ctx, run, t, ids, id, p.survivor)
+				if err != nil {
+					return nil, err
+				}
+				for _, rid := range moved {
+					repointed[rid] = true
+				}
+				for _, rid := range locked {
+					reasons[rid] = t.lockedReason
+				}
+				run.Repointed += int64(len(moved))
+			}
+		}
+
+		for _, rid := range ids {
+			finding := models.IntegrityFinding{
+				RunID:        run.ID,
+				ResourceType: t.resourceType,
+				ResourceID:   rid,
+				Reference:    "Patient/" + id,
+				Problem:      p.kind,
+				Reason:       reasons[rid],
+			}
+			if repointed[rid] {
+				finding.RepointedTo = "Patient/" + p.survivor
+			}
+			findings = append(findings, finding)
+		}
+	}
+	return findings, nil
+}
+
+// classify returns the problems of the referenced patients that have one
+func classify(db *gorm.DB, patients map[string]string) (map[string]problem, error) {
+	ids := make([]string, 0, len(patients))
+	for id := range patients {
+		ids = append(ids, id)
+	}
+
+	problems := make(map[string]problem)
+	for start := 0; start < len(ids); start += lookupBatchSize {
+		end := start + lookupBatchSize
+		if end > len(ids) {
+			end = len(ids)
+		}
+		batch := ids[start:end]
+
+		var found []models.Patient
+		if err := db.Unscoped().Select("id", "deleted_at").Where("id IN ?", batch).Find(&found).Error; err != nil {
+			return nil, fmt.Errorf("failed to look up patients: %w", err)
+		}
+		existing := make(map[string]bool, len(found))
+		for _, patient := range found {
+			existing[patient.ID] = true
+			if patient.DeletedAt.Valid {
+				problems[patient.ID] = problem{kind: models.IntegrityDeleted}
+			}
+		}
+		for _, id := range batch {
+			if !existing[id] {
+				problems[id] = problem{kind: models.IntegrityMissing}
+			}
+		}
+
+		var replaced []string
+		if err := db.Model(&models.PatientLink{}).Scopes(models.LiveLinks).
+			Where("patient_id IN ? AND type = ?", batch, models.PatientLinkReplacedBy).
+			Pluck("patient_id", &replaced).Error; err != nil {
+			return nil, fmt.Errorf("failed to look up patient links: %w", err)
+		}
+		for _, id := range replaced {
+			if _, ok := problems[id]; ok {
+				continue
+			}
+			survivor, err := survivor(db, id)
+			if err != nil {
+				return nil, err
+			}
+			problems[id] = problem{kind: models.IntegrityReplaced, survivor: survivor}
+		}
+	}
+	return problems, nil
+}
+
+// survivor follows the replaced-by links of a patient to the record that replaces
+// it. It returns an empty ID when the chain loops or is longer than allowed.
+func survivor(db *gorm.DB, id string) (string, error) {
+	seen := map[string]bool{id: true}
+	for depth := 0; depth < maxLinkDepth; depth++ {
+		var link models.PatientLink
+		err := db.Scopes(models.LiveLinks).
+			Where("patient_id = ? AND type = ?", id, models.PatientLinkReplacedBy).First(&link).Error
+		if err == gorm.ErrRecordNotFound {
+			return id, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("failed to resolve patient links: %w", err)
+		}
+		if seen[link.OtherID] {
+			return "", nil
+		}
+		seen[link.OtherID] = true
+		id = link.OtherID
+	}
+	return "", nil
+}
+
+// repointRecords moves the records that may be changed from a replaced patient to its
+// survivor and returns the moved records and those left because they are locked
+func (c *Checker) repointRecords(ctx context.Context, run *models.IntegrityRun, t target, ids []string, from, to string) ([]string, []string, error) {
+	db := c.db.WithContext(ctx)
+	var locked []string
+	if t.locked != "" {
+		if err := db.Model(t.model).Where("id IN ?", ids).Where(t.locked).Pluck("id", &locked).Error; err != nil {
+			return nil, nil, fmt.Errorf("failed to load locked records: %w", err)
+		}
+	}
+	skip := make(map[string]bool, len(locked))
+	for _, id := range locked {
+		skip[id] = true
+	}
+	moved := make([]string, 0, len(ids))
+	for _, id := range ids {
+		if !skip[id] {
+			moved = append(moved, id)
+		}
+	}
+	if len(moved) == 0 {
+		return nil, locked, nil
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if t.column == "" {
+			// Observations are saved one at a time so their hooks publish the change
+			var observations []models.Observation
+			if err := tx.Where("id IN ?", moved).Find(&observations).Error; err != nil {
+				return err
+			}
+			for i := range observations {
+				subject := observations[i].Subject
+				subject.Reference = "Patient/" + to
+				if err := tx.Model(&observations[i]).Select("subject", "updated_at").
+					Updates(&models.Observation{Subject: subject, UpdatedAt: time.Now()}).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		value := "Patient/" + to
+		if t.bare {
+			value = to
+		}
+		if err := tx.Model(t.model).Where("id IN ?", moved).UpdateColumn(t.column, value).Error; err != nil {
+			return err
+		}
+		if t.events {
+			return models.RecordEvents(tx, t.resourceType, moved, models.EventActionUpdated)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to re-point records: %w", err)
+	}
+
+	userID := run.CreatedBy
+	if userID == "" {
+		userID = "system"
+	}
+	logger.LogAuditEvent("repoint", t.resourceType, userID, map[string]interface{}{
+		"run_id":  run.ID,
+		"from":    "Patient/" + from,
+		"to":      "Patient/" + to,
+		"records": len(moved),
+	})
+	return moved, locked, nil
+}
+
+// fail marks a run as failed
+func (c *Checker) fail(run *models.IntegrityRun, err error) {
+	logger.Error("Integrity run failed", zap.String("run_id", run.ID), zap.Error(err))
+	now := time.Now()
+	if err := c.db.Model(run).Updates(map[string]interface{}{
+		"status":       models.IntegrityRunFailed,
+		"error":        err.Error(),
+		"completed_at": now,
+	}).Error; err != nil {
+		logger.Error("Failed to record integrity run failure", zap.String("run_id", run.ID), zap.Error(err))
+	}
+}
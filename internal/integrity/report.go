@@ -0,0 +1,194 @@
+package integrity
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+)
+
+// sampleSize bounds the IDs listed per finding of a report
+const sampleSize = 10
+
+// scanBatchSize bounds the rows read per query when checking JSON columns
+const scanBatchSize = 500
+
+// Finding counts the records with one problem, with the IDs of some of them
+type Finding struct {
+	Count     int64    `json:"count"`
+	SampleIDs []string `json:"sampleIds"`
+}
+
+// JSONFinding counts the records whose JSON column does not decode as its field
+type JSONFinding struct {
+	Table  string `json:"table"`
+	Column string `json:"column"`
+	Finding
+}
+
+// Report is a database integrity report
+type Report struct {
+	OrphanObservations Finding       `json:"orphanObservations"` // Subject patient missing or pending deletion
+	OrphanRoles        Finding       `json:"orphanRoles"`        // Users granted a role that no longer exists
+	MalformedJSON      []JSONFinding `json:"malformedJson"`
+	GeneratedAt        time.Time     `json:"generatedAt"`
+}
+
+// reportedModels are the records whose JSON columns are checked
+var reportedModels = func() []interface{} {
+	values := []interface{}{&models.Patient{}}
+	for _, t := range targets {
+		values = append(values, t.model)
+	}
+	return values
+}()
+
+// BuildReport scans the database for orphan observations, users granted deleted
+// roles and JSON columns that do not decode
+func BuildReport(ctx context.Context, db *gorm.DB) (*Report, error) {
+	db = db.WithContext(ctx)
+	report := &Report{GeneratedAt: time.Now()}
+
+	var err error
+	if report.OrphanObservations, err = orphanObservations(db); err != nil {
+		return nil, err
+	}
+	if report.OrphanRoles, err = orphanRoles(db); err != nil {
+		return nil, err
+	}
+	if report.MalformedJSON, err = malformedJSON(db); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
+
+// orphanObservations finds the observations whose subject patient is missing or
+// pending deletion
+func orphanObservations(db *gorm.DB) (Finding, error) {
+	finding := Finding{SampleIDs: []string{}}
+	column := dialect.Of(db).JSONText("subject", "reference")
+
+	var refs []string
+	if err := db.Model(&models.Observation{}).Distinct(column).Pluck(column, &refs).Error; err != nil {
+		return finding, fmt.Errorf("failed to load observation subjects: %w", err)
+	}
+	patients := make(map[string]string, len(refs))
+	for _, ref := range refs {
+		if id := strings.TrimPrefix(ref, "Patient/"); id != ref && id != "" {
+			patients[id] = ref
+		}
+	}
+
+	problems, err := classify(db, patients)
+	if err != nil {
+		return finding, err
+	}
+	orphaned := make([]string, 0, len(problems))
+	for id, p := range problems {
+		if p.kind != models.IntegrityReplaced {
+			orphaned = append(orphaned, patients[id])
+		}
+	}
+	if len(orphaned) == 0 {
+		return finding, nil
+	}
+
+	for start := 0; start < len(orphaned); start += lookupBatchSize {
+		end := start + lookupBatchSize
+		if end > len(orphaned) {
+			end = len(orphaned)
+		}
+		query := db.Model(&models.Observation{}).Where(column+" IN ?", orphaned[start:end])
+		var count int64
+		if err := query.Count(&count).Error; err != nil {
+			return finding, fmt.Errorf("failed to count orphan observations: %w", err)
+		}
+		finding.Count += count
+		if room := sampleSize - len(finding.SampleIDs); room > 0 && count > 0 {
+			var ids []string
+			if err := query.Order("id").Limit(room).Pluck("id", &ids).Error; err != nil {
+				return finding, fmt.Errorf("failed to load orphan observations: %w", err)
+			}
+			finding.SampleIDs = append(finding.SampleIDs, ids...)
+		}
+	}
+	return finding, nil
+}
+
+// orphanRoles finds the users granted a role that has been deleted
+func orphanRoles(db *gorm.DB) (Finding, error) {
+	finding := Finding{SampleIDs: []string{}}
+	query := db.Model(&models.UserRole{}).Where("role_id NOT IN (?)", db.Model(&models.Role{}).Select("id"))
+
+	if err := query.Distinct("user_id").Count(&finding.Count).Error; err != nil {
+		return finding, fmt.Errorf("failed to count orphan role grants: %w", err)
+	}
+	if finding.Count > 0 {
+		if err := query.Distinct("user_id").Order("user_id").Limit(sampleSize).Pluck("user_id", &finding.SampleIDs).Error; err != nil {
+			return finding, fmt.Errorf("failed to load orphan role grants: %w", err)
+		}
+	}
+	return finding, nil
+}
+
+// malformedJSON finds the records whose JSON columns do not decode as the field
+// they are serialized from, such as payloads written outside the API
+func malformedJSON(db *gorm.DB) ([]JSONFinding, error) {
+	findings := []JSONFinding{}
+	for _, value := range reportedModels {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(value); err != nil {
+			return nil, err
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.Serializer == nil || field.DBName == "" {
+				continue
+			}
+			finding, err := malformedColumn(db, value, stmt.Schema.Table, field.DBName, field.FieldType)
+			if err != nil {
+				return nil, err
+			}
+			if finding.Count > 0 {
+				findings = append(findings, *finding)
+			}
+		}
+	}
+	return findings, nil
+}
+
+// malformedColumn checks one JSON column, reading it in batches by ID
+func malformedColumn(db *gorm.DB, model interface{}, table, column string, fieldType reflect.Type) (*JSONFinding, error) {
+	finding := &JSONFinding{Table: table, Column: column, Finding: Finding{SampleIDs: []string{}}}
+	last := ""
+	for {
+		var rows []struct {
+			ID  string
+			Raw *string
+		}
+		if err := db.Model(model).Unscoped().Select("id", column+" AS raw").
+			Where("id > ?", last).Order("id").Limit(scanBatchSize).Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read %s.%s: %w", table, column, err)
+		}
+		for _, row := range rows {
+			if row.Raw == nil || *row.Raw == "" {
+				continue
+			}
+			if err := json.Unmarshal([]byte(*row.Raw), reflect.New(fieldType).Interface()); err != nil {
+				finding.Count++
+				if len(finding.SampleIDs) < sampleSize {
+					finding.SampleIDs = append(finding.SampleIDs, row.ID)
+				}
+			}
+		}
+		if len(rows) < scanBatchSize {
+			return finding, nil
+		}
+		last = rows[len(rows)-1].ID
+	}
+}
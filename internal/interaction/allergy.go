@@ -0,0 +1,86 @@
+package interaction
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// CheckAllergies returns the patient's active allergies to a medication or any of
+// its ingredients. An allergy matches when one of its codes, or its name, is the
+// medication's or an ingredient's. Refuted allergies and allergies entered in error
+// are ignored.
+func (c *Checker) CheckAllergies(ctx context.Context, patientRef string, medication models.CodeableConcept, ingredients []models.CodeableConcept) ([]models.AllergyWarning, error) {
+	if c == nil {
+		return nil, nil
+	}
+
+	var allergies []models.AllergyIntolerance
+	if err := c.db.WithContext(ctx).
+		Where("subject_reference = ? AND clinical_status = ?", patientRef, models.AllergyActive).
+		Where("verification_status NOT IN ?", []string{models.AllergyRefuted, models.AllergyEnteredInError}).
+		Find(&allergies).Error; err != nil {
+		return nil, fmt.Errorf("failed to load allergies: %w", err)
+	}
+	if len(allergies) == 0 {
+		return nil, nil
+	}
+
+	codes, names := map[string]bool{}, map[string]bool{}
+	for _, concept := range append([]models.CodeableConcept{medication}, ingredients...) {
+		for _, code := range codesOf(concept) {
+			codes[code] = true
+		}
+		for _, name := range namesOf(concept) {
+			names[name] = true
+		}
+	}
+
+	var warnings []models.AllergyWarning
+	for _, allergy := range allergies {
+		matched, found := "", false
+		for _, code := range codesOf(allergy.Code) {
+			if codes[code] {
+				matched, found = code, true
+				break
+			}
+		}
+		if !found {
+			for _, name := range namesOf(allergy.Code) {
+				if names[name] {
+					found = true
+					break
+				}
+			}
+		}
+		if !found {
+			continue
+		}
+
+		warnings = append(warnings, models.AllergyWarning{
+			AllergyID:          allergy.ID,
+			Substance:          displayOf(allergy.Code),
+			Code:               matched,
+			Criticality:        allergy.Criticality,
+			VerificationStatus: allergy.VerificationStatus,
+			Reaction:           allergy.Reaction,
+		})
+	}
+	return warnings, nil
+}
+
+// namesOf returns the normalized text and display names of a concept
+func namesOf(concept models.CodeableConcept) []string {
+	var names []string
+	if name := strings.ToLower(strings.TrimSpace(concept.Text)); name != "" {
+		names = append(names, name)
+	}
+	for _, coding := range concept.Coding {
+		if name := strings.ToLower(strings.TrimSpace(coding.Display)); name != "" {
+			names = append(names, name)
+		}
+	}
+	return names
+}
@@ -0,0 +1,94 @@
+package interaction
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// api looks up interactions with an external interaction service. It posts the
+// codes to {baseURL}/interactions and expects the interactions found in return:
+//
+//	{"codes": ["..."], "others": ["..."]}
+//	{"interactions": [{"codeA": "...", "codeB": "...", "severity": "severe", "description": "..."}]}
+type api struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+func newAPI(client *http.Client, opts Options) *api {
+	return &api{client: client, baseURL: strings.TrimRight(opts.BaseURL, "/"), apiKey: opts.APIKey}
+}
+
+// Name returns the knowledge base identifier
+func (a *api) Name() string {
+	return "api"
+}
+
+// Interactions asks the external service for the interactions between codes and
+// others. Major and contraindicated interactions count as severe; severities it does
+// not know are reported as moderate.
+func (a *api) Interactions(ctx context.Context, codes, others []string) ([]Interaction, error) {
+	body, err := json.Marshal(map[string][]string{"codes": codes, "others": others})
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, a.baseURL+"/interactions", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "HealthHub API")
+	if a.apiKey != "" {
+		req.Header.Set("Authorization", "Bearer "+a.apiKey)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("interaction api request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("interaction api returned status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Interactions []struct {
+			CodeA       string `json:"codeA"`
+			CodeB       string `json:"codeB"`
+			Severity    string `json:"severity"`
+			Description string `json:"description"`
+		} `json:"interactions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, fmt.Errorf("failed to decode interaction api response: %w", err)
+	}
+
+	interactions := make([]Interaction, 0, len(result.Interactions))
+	for _, in := range result.Interactions {
+		var severity string
+		switch strings.ToLower(in.Severity) {
+		case "severe", "major", "contraindicated":
+			severity = models.InteractionSevere
+		case "minor":
+			severity = models.InteractionMinor
+		default:
+			severity = models.InteractionModerate
+		}
+		interactions = append(interactions, Interaction{
+			CodeA:       in.CodeA,
+			CodeB:       in.CodeB,
+			Severity:    severity,
+			Description: in.Description,
+		})
+	}
+	return interactions, nil
+}
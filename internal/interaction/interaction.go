@@ -0,0 +1,178 @@
+// Package interaction checks a new prescription against the medications the patient
+// already takes, using a pluggable drug interaction knowledge base, and against the
+// patient's recorded allergies.
+package interaction
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+	"gorm.io/gorm"
+)
+
+// Interaction is an interaction between two medication codes
+type Interaction struct {
+	CodeA       string
+	CodeB       string
+	Severity    string // minor, moderate or severe
+	Description string
+}
+
+// KnowledgeBase looks up drug interactions
+type KnowledgeBase interface {
+	// Name returns the knowledge base identifier, e.g. "table"
+	Name() string
+	// Interactions returns the known interactions between any of codes and any of
+	// others
+	Interactions(ctx context.Context, codes, others []string) ([]Interaction, error)
+}
+
+// Options configures a knowledge base
+type Options struct {
+	BaseURL string // Endpoint of the external interaction API
+	APIKey  string // Sent as bearer token to the external API
+}
+
+// NewKnowledgeBase returns the named knowledge base, or nil when name is empty or
+// "none". The "table" knowledge base reads the drug_interactions table.
+func NewKnowledgeBase(name string, db *gorm.DB, opts Options) (KnowledgeBase, error) {
+	name = strings.ToLower(name)
+	switch name {
+	case "", "none":
+		return nil, nil
+	case "table":
+		return newTable(db), nil
+	case "api":
+		if opts.BaseURL == "" {
+			return nil, fmt.Errorf("interaction: api knowledge base requires a URL")
+		}
+		client := egress.NewClient(resilience.Get("interactions_api"), 10*time.Second)
+		return newAPI(client, opts), nil
+	default:
+		return nil, fmt.Errorf("interaction: unknown knowledge base %q", name)
+	}
+}
+
+// Checker checks prescriptions for interactions
+type Checker struct {
+	db *gorm.DB
+	kb KnowledgeBase
+}
+
+// NewChecker creates a new interaction checker. A nil knowledge base disables
+// drug interaction checking; allergies are still checked.
+func NewChecker(db *gorm.DB, kb KnowledgeBase) *Checker {
+	return &Checker{db: db, kb: kb}
+}
+
+// Check returns the interactions between a medication and the patient's active and
+// on-hold medication requests, most severe first
+func (c *Checker) Check(ctx context.Context, patientRef string, medication models.CodeableConcept) ([]models.InteractionWarning, error) {
+	if c == nil || c.kb == nil {
+		return nil, nil
+	}
+	codes := codesOf(medication)
+	if len(codes) == 0 {
+		return nil, nil
+	}
+
+	var current []models.MedicationRequest
+	if err := c.db.WithContext(ctx).
+		Where("subject_reference = ? AND status IN ?", patientRef, []string{models.MedicationRequestActive, models.MedicationRequestOnHold}).
+		Find(&current).Error; err != nil {
+		return nil, fmt.Errorf("failed to load current medications: %w", err)
+	}
+
+	// The first request of each code names the interacting medication; a code shared
+	// with the new medication is the same drug, not an interaction
+	own := make(map[string]bool, len(codes))
+	for _, code := range codes {
+		own[code] = true
+	}
+	requests := map[string]*models.MedicationRequest{}
+	var others []string
+	for i := range current {
+		for _, code := range codesOf(current[i].Medication) {
+			if own[code] || requests[code] != nil {
+				continue
+			}
+			requests[code] = &current[i]
+			others = append(others, code)
+		}
+	}
+	if len(others) == 0 {
+		return nil, nil
+	}
+
+	interactions, err := c.kb.Interactions(ctx, codes, others)
+	if err != nil {
+		return nil, fmt.Errorf("%s interaction check failed: %w", c.kb.Name(), err)
+	}
+
+	seen := map[string]bool{}
+	var warnings []models.InteractionWarning
+	for _, in := range interactions {
+		code, interacting := in.CodeA, in.CodeB
+		if !own[code] {
+			code, interacting = interacting, code
+		}
+		request := requests[interacting]
+		if !own[code] || request == nil {
+			continue
+		}
+		key := request.ID + "|" + in.Description
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+
+		warnings = append(warnings, models.InteractionWarning{
+			Severity:    in.Severity,
+			Description: in.Description,
+			Code:        code,
+			Interacting: interacting,
+			RequestID:   request.ID,
+			Display:     displayOf(request.Medication),
+		})
+	}
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return models.InteractionRank(warnings[i].Severity) > models.InteractionRank(warnings[j].Severity)
+	})
+	return warnings, nil
+}
+
+// Severe reports whether any warning is severe
+func Severe(warnings []models.InteractionWarning) bool {
+	for _, w := range warnings {
+		if w.Severity == models.InteractionSevere {
+			return true
+		}
+	}
+	return false
+}
+
+// codesOf returns the codes of a concept's codings
+func codesOf(concept models.CodeableConcept) []string {
+	var codes []string
+	for _, coding := range concept.Coding {
+		if coding.Code != "" {
+			codes = append(codes, coding.Code)
+		}
+	}
+	return codes
+}
+
+func displayOf(concept models.CodeableConcept) string {
+	for _, coding := range concept.Coding {
+		if coding.Display != "" {
+			return coding.Display
+		}
+	}
+	return concept.Text
+}
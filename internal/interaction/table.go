@@ -0,0 +1,44 @@
+package interaction
+
+import (
+	"context"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+)
+
+// table looks up interactions in the drug_interactions table, maintained by admins
+type table struct {
+	db *gorm.DB
+}
+
+func newTable(db *gorm.DB) *table {
+	return &table{db: db}
+}
+
+// Name returns the knowledge base identifier
+func (t *table) Name() string {
+	return "table"
+}
+
+// Interactions returns the stored interactions between codes and others. Pairs are
+// stored in code order, so either code may be the new medication's.
+func (t *table) Interactions(ctx context.Context, codes, others []string) ([]Interaction, error) {
+	var rows []models.DrugInteraction
+	if err := t.db.WithContext(ctx).
+		Where("(code_a IN ? AND code_b IN ?) OR (code_b IN ? AND code_a IN ?)", codes, others, codes, others).
+		Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	interactions := make([]Interaction, 0, len(rows))
+	for _, row := range rows {
+		interactions = append(interactions, Interaction{
+			CodeA:       row.CodeA,
+			CodeB:       row.CodeB,
+			Severity:    row.Severity,
+			Description: row.Description,
+		})
+	}
+	return interactions, nil
+}
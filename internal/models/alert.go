@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Alert statuses
+const (
+	AlertStatusOpen         = "open"
+	AlertStatusAcknowledged = "acknowledged"
+)
+
+// Alert severities
+const (
+	AlertSeverityWarning  = "warning"
+	AlertSeverityCritical = "critical"
+)
+
+// Alert types
+const (
+	AlertTypeQCViolation    = "qc-violation"
+	AlertTypeDeltaCheck     = "delta-check" // A result differs too much from the patient's previous one
+	AlertTypeCriticalResult = "critical-result"
+)
+
+// DepartmentLaboratory is the department of laboratory quality control alerts.
+// Alerts on observations belong to the department named by the observation's
+// category code.
+const DepartmentLaboratory = "laboratory"
+
+// Alert is raised for a condition that needs attention from staff, such as a
+// rejected quality control run. It stays open until someone acknowledges it; open
+// critical alerts are escalated along the on-call chain of their department.
+type Alert struct {
+	ID               string                 `json:"id" gorm:"primaryKey"`
+	Type             string                 `json:"type" gorm:"index"`
+	Severity         string                 `json:"severity"`
+	Status           string                 `json:"status" gorm:"index"`
+	ResourceType     string                 `json:"resourceType" gorm:"index:idx_alerts_resource"`
+	ResourceID       string                 `json:"resourceId" gorm:"index:idx_alerts_resource"`
+	Department       string                 `json:"department,omitempty" gorm:"index"`
+	Message          string                 `json:"message"`
+	Details          map[string]interface{} `json:"details,omitempty" gorm:"type:jsonb;serializer:json"`
+	CreatedAt        time.Time              `json:"createdAt" gorm:"index"`
+	EscalationLevel  int                    `json:"escalationLevel"` // On-call contacts notified so far
+	NextEscalationAt *time.Time             `json:"nextEscalationAt,omitempty" gorm:"index"`
+	AcknowledgedAt   *time.Time             `json:"acknowledgedAt,omitempty"`
+	AcknowledgedBy   string                 `json:"acknowledgedBy,omitempty"`
+	Comment          string                 `json:"comment,omitempty" sanitize:"note"`
+}
+
+// AlertAcknowledgeRequest represents a request to acknowledge an alert
+type AlertAcknowledgeRequest struct {
+	Comment string `json:"comment,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating an alert
+func (a *Alert) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	if a.Status == "" {
+		a.Status = AlertStatusOpen
+	}
+	return nil
+}
+
+// TableName returns the table name for the Alert model
+func (Alert) TableName() string {
+	return "alerts"
+}
@@ -0,0 +1,98 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Allergy clinical statuses
+const (
+	AllergyActive   = "active"
+	AllergyInactive = "inactive"
+	AllergyResolved = "resolved"
+)
+
+// Allergy verification statuses
+const (
+	AllergyUnconfirmed    = "unconfirmed"
+	AllergyConfirmed      = "confirmed"
+	AllergyRefuted        = "refuted"
+	AllergyEnteredInError = "entered-in-error"
+)
+
+// Allergy criticalities
+const (
+	AllergyCriticalityLow  = "low"
+	AllergyCriticalityHigh = "high"
+	AllergyUnableToAssess  = "unable-to-assess"
+)
+
+// AllergyIntolerance represents a FHIR-inspired AllergyIntolerance resource: a
+// substance the patient reacts to
+type AllergyIntolerance struct {
+	ID                 string          `json:"id" gorm:"primaryKey"`
+	Subject            Reference       `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Code               CodeableConcept `json:"code" gorm:"type:jsonb;serializer:json"` // The substance, e.g. an ingredient code
+	ClinicalStatus     string          `json:"clinicalStatus" gorm:"index"`
+	VerificationStatus string          `json:"verificationStatus"`
+	Category           string          `json:"category,omitempty"` // food, medication, environment or biologic
+	Criticality        string          `json:"criticality,omitempty"`
+	Reaction           string          `json:"reaction,omitempty"` // e.g. anaphylaxis, rash
+	Note               string          `json:"note,omitempty" sanitize:"note"`
+	CreatedAt          time.Time       `json:"createdAt"`
+	UpdatedAt          time.Time       `json:"updatedAt"`
+	CreatedBy          string          `json:"createdBy"`
+	DeletedAt          gorm.DeletedAt  `json:"-" gorm:"index"` // Set while a delete can still be undone
+	DeletedBy          string          `json:"-"`
+}
+
+// AllergyIntoleranceRequest represents a request to record an allergy
+type AllergyIntoleranceRequest struct {
+	Subject            Reference       `json:"subject"`
+	Code               CodeableConcept `json:"code"`
+	ClinicalStatus     string          `json:"clinicalStatus,omitempty" validate:"omitempty,oneof=active inactive resolved"`
+	VerificationStatus string          `json:"verificationStatus,omitempty" validate:"omitempty,oneof=unconfirmed confirmed refuted entered-in-error"`
+	Category           string          `json:"category,omitempty" validate:"omitempty,oneof=food medication environment biologic"`
+	Criticality        string          `json:"criticality,omitempty" validate:"omitempty,oneof=low high unable-to-assess"`
+	Reaction           string          `json:"reaction,omitempty"`
+	Note               string          `json:"note,omitempty"`
+}
+
+// AllergyIntoleranceStatusRequest represents a request to change the status of an
+// allergy, e.g. to resolve or refute it
+type AllergyIntoleranceStatusRequest struct {
+	ClinicalStatus     string `json:"clinicalStatus,omitempty" validate:"required_without=VerificationStatus,omitempty,oneof=active inactive resolved"`
+	VerificationStatus string `json:"verificationStatus,omitempty" validate:"omitempty,oneof=unconfirmed confirmed refuted entered-in-error"`
+}
+
+// AllergyWarning is a recorded allergy of the patient to a prescribed medication or
+// one of its ingredients
+type AllergyWarning struct {
+	AllergyID          string `json:"allergyId"`
+	Substance          string `json:"substance"` // Name of the allergen
+	Code               string `json:"code,omitempty"`
+	Criticality        string `json:"criticality,omitempty"`
+	VerificationStatus string `json:"verificationStatus"`
+	Reaction           string `json:"reaction,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating an allergy
+func (a *AllergyIntolerance) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	if a.ClinicalStatus == "" {
+		a.ClinicalStatus = AllergyActive
+	}
+	if a.VerificationStatus == "" {
+		a.VerificationStatus = AllergyUnconfirmed
+	}
+	return nil
+}
+
+// TableName returns the table name for the AllergyIntolerance model
+func (AllergyIntolerance) TableName() string {
+	return "allergy_intolerances"
+}
@@ -0,0 +1,20 @@
+package models
+
+// APIUsage counts the requests a client made to an endpoint on a day, or the requests
+// among them that used a deprecated field. A client is the user, the integration
+// credential and the user agent a request came with.
+type APIUsage struct {
+	Day       string `json:"day" gorm:"primaryKey"` // YYYY-MM-DD, UTC
+	Method    string `json:"method" gorm:"primaryKey"`
+	Route     string `json:"route" gorm:"primaryKey"`               // Route template, e.g. /api/v1/patients/:id
+	Field     string `json:"field,omitempty" gorm:"primaryKey"`     // Deprecated field, e.g. query.follow; empty for the endpoint itself
+	UserID    string `json:"userId,omitempty" gorm:"primaryKey"`    // Empty for unauthenticated requests
+	APIKey    string `json:"apiKey,omitempty" gorm:"primaryKey"`    // Integration credential ID of signed requests
+	UserAgent string `json:"userAgent,omitempty" gorm:"primaryKey"` // Truncated to 200 characters
+	Requests  int64  `json:"requests"`
+}
+
+// TableName returns the table name for the APIUsage model
+func (APIUsage) TableName() string {
+	return "api_usage"
+}
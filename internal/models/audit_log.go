@@ -0,0 +1,72 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// AuditLog is a persisted audit event. Entries are append-only; they are shipped to
+// write-once storage in archives of consecutive IDs.
+type AuditLog struct {
+	ID        uint64                 `json:"id" gorm:"primaryKey;autoIncrement"`
+	Action    string                 `json:"action" gorm:"index"`
+	Resource  string                 `json:"resource" gorm:"index"`
+	UserID    string                 `json:"userId" gorm:"index"`
+	Details   map[string]interface{} `json:"details,omitempty" gorm:"type:jsonb;serializer:json"`
+	CreatedAt time.Time              `json:"createdAt" gorm:"index"`
+}
+
+// AuditArchive records an archive of audit log entries written to write-once
+// storage. Archives form a chain: each manifest names the digest of the archive
+// before it, so a missing archive is detected on verification.
+type AuditArchive struct {
+	ID             string    `json:"id" gorm:"primaryKey"`
+	FirstEntryID   uint64    `json:"firstEntryId"`
+	LastEntryID    uint64    `json:"lastEntryId" gorm:"uniqueIndex"`
+	Entries        int       `json:"entries"`
+	ObjectKey      string    `json:"objectKey"`
+	ManifestKey    string    `json:"manifestKey"`
+	SHA256         string    `json:"sha256"`
+	PreviousSHA256 string    `json:"previousSha256,omitempty"`
+	Bytes          int64     `json:"bytes"`
+	RetainUntil    time.Time `json:"retainUntil"`
+	CreatedAt      time.Time `json:"createdAt"`
+}
+
+// AuditArchiveManifest describes an audit archive. It is stored signed next to the
+// archive so the archive can be verified without access to the database.
+type AuditArchiveManifest struct {
+	ArchiveID      string            `json:"archiveId"`
+	Object         string            `json:"object"`
+	FirstEntryID   uint64            `json:"firstEntryId"`
+	LastEntryID    uint64            `json:"lastEntryId"`
+	Entries        int               `json:"entries"`
+	FirstEntryAt   time.Time         `json:"firstEntryAt"`
+	LastEntryAt    time.Time         `json:"lastEntryAt"`
+	SHA256         string            `json:"sha256"`        // Of the archive object as stored
+	EntriesSHA256  string            `json:"entriesSha256"` // Of the uncompressed JSON Lines entries
+	PreviousSHA256 string            `json:"previousSha256,omitempty"`
+	Encryption     *ExportEncryption `json:"encryption,omitempty"`
+	LockMode       string            `json:"lockMode"`
+	RetainUntil    time.Time         `json:"retainUntil"`
+	GeneratedAt    time.Time         `json:"generatedAt"`
+}
+
+// SignedAuditManifest is the stored form of a manifest. The signature is an Ed25519
+// signature over the exact manifest bytes.
+type SignedAuditManifest struct {
+	Manifest  json.RawMessage `json:"manifest"`
+	KeyID     string          `json:"keyId"`
+	Signature string          `json:"signature"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating an audit archive
+func (a *AuditArchive) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Backup and restore job statuses
+const (
+	BackupJobQueued    = "queued"
+	BackupJobRunning   = "running"
+	BackupJobCompleted = "completed"
+	BackupJobFailed    = "failed"
+)
+
+// BackupJob is a logical pg_dump backup of the database written to object storage
+type BackupJob struct {
+	ID          string     `json:"id" gorm:"primaryKey"`
+	Status      string     `json:"status" gorm:"index"`
+	StorageKey  string     `json:"-"`
+	Bytes       int64      `json:"bytes"`
+	SHA256      string     `json:"sha256,omitempty"`
+	Error       string     `json:"error,omitempty"`
+	CreatedBy   string     `json:"createdBy"`
+	CreatedAt   time.Time  `json:"createdAt"`
+	StartedAt   *time.Time `json:"startedAt,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"`
+}
+
+// RestoreJob restores a completed backup into the staging database. Row counts of
+// the restored tables are recorded so operators can sanity-check the result.
+type RestoreJob struct {
+	ID          string           `json:"id" gorm:"primaryKey"`
+	BackupID    string           `json:"backupId" gorm:"index"`
+	Target      string           `json:"target"` // Staging database URL without credentials
+	Status      string           `json:"status" gorm:"index"`
+	RowCounts   map[string]int64 `json:"rowCounts,omitempty" gorm:"type:jsonb;serializer:json"`
+	Error       string           `json:"error,omitempty"`
+	CreatedBy   string           `json:"createdBy"`
+	CreatedAt   time.Time        `json:"createdAt"`
+	StartedAt   *time.Time       `json:"startedAt,omitempty"`
+	CompletedAt *time.Time       `json:"completedAt,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a backup job
+func (j *BackupJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating a restore job
+func (j *RestoreJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the BackupJob model
+func (BackupJob) TableName() string {
+	return "backup_jobs"
+}
+
+// TableName returns the table name for the RestoreJob model
+func (RestoreJob) TableName() string {
+	return "restore_jobs"
+}
+
+// BackupStorageKey returns the storage key of a backup's dump file
+func BackupStorageKey(jobID string) string {
+	return "backups/" + jobID + ".dump"
+}
@@ -0,0 +1,56 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// FeeScheduleEntry is the charge billed for one unit of a CPT or HCPCS service
+type FeeScheduleEntry struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	System    string    `json:"system" gorm:"column:code_system;uniqueIndex:idx_fee_schedule_code"`
+	Code      string    `json:"code" gorm:"uniqueIndex:idx_fee_schedule_code"`
+	Display   string    `json:"display,omitempty" sanitize:"display"`
+	Amount    float64   `json:"amount"` // In US dollars
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// FeeRequest represents a request to set the charge of a service
+type FeeRequest struct {
+	System  string  `json:"system" validate:"required,oneof=http://www.ama-assn.org/go/cpt https://www.cms.gov/Medicare/Coding/HCPCSReleaseCodeSets"`
+	Code    string  `json:"code" validate:"required,max=48"`
+	Display string  `json:"display,omitempty"`
+	Amount  float64 `json:"amount" validate:"gte=0"`
+}
+
+// ClaimPayer identifies the insurer claims are submitted to
+type ClaimPayer struct {
+	Name string `json:"name" validate:"required,max=60"`
+	ID   string `json:"id" validate:"required,max=80"` // Payer ID assigned by the clearinghouse
+}
+
+// ClaimRequest represents a request to extract claims, either for one encounter or
+// for the services performed in a period, optionally of one patient
+type ClaimRequest struct {
+	Subject   *Reference  `json:"subject,omitempty"`
+	Encounter *Reference  `json:"encounter,omitempty"`
+	Start     *time.Time  `json:"start,omitempty" validate:"required_without=Encounter"`
+	End       *time.Time  `json:"end,omitempty" validate:"required_without=Encounter"`
+	Payer     *ClaimPayer `json:"payer,omitempty"` // Required for X12 exports
+}
+
+// BeforeCreate is a GORM hook that runs before creating a fee schedule entry
+func (f *FeeScheduleEntry) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the FeeScheduleEntry model
+func (FeeScheduleEntry) TableName() string {
+	return "fee_schedule"
+}
@@ -0,0 +1,234 @@
+package models
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+)
+
+// Bulk job operations
+const (
+	BulkOperationUpdate = "update"
+	BulkOperationDelete = "delete"
+)
+
+// Bulk job statuses. A job starts as a preview and only runs once it is executed.
+const (
+	BulkJobPreview   = "preview"
+	BulkJobQueued    = "queued"
+	BulkJobRunning   = "running"
+	BulkJobCompleted = "completed"
+	BulkJobFailed    = "failed"
+	BulkJobCancelled = "cancelled"
+)
+
+// BulkJob is a filtered update or delete of observations, previewed before it runs
+// in chunks in the background
+type BulkJob struct {
+	ID           string            `json:"id" gorm:"primaryKey"`
+	ResourceType string            `json:"resourceType"`
+	Operation    string            `json:"operation"`
+	Filter       ObservationFilter `json:"filter" gorm:"type:jsonb;serializer:json"`
+	Patch        *ObservationPatch `json:"patch,omitempty" gorm:"type:jsonb;serializer:json"`
+	Status       string            `json:"status" gorm:"index"`
+	OperationID  string            `json:"operationId,omitempty"` // Operation running the job once executed
+	Affected     int64             `json:"affected"`
+	Sample       []string          `json:"sample,omitempty" gorm:"type:jsonb;serializer:json"`
+	Processed    int64             `json:"processed"`
+	Cursor       string            `json:"-"` // Last processed resource ID, so interrupted jobs resume
+	Error        string            `json:"error,omitempty"`
+	ExpiresAt    time.Time         `json:"expiresAt"`
+	CreatedBy    string            `json:"createdBy"`
+	ExecutedBy   string            `json:"executedBy,omitempty"`
+	CreatedAt    time.Time         `json:"createdAt"`
+	StartedAt    *time.Time        `json:"startedAt,omitempty"`
+	CompletedAt  *time.Time        `json:"completedAt,omitempty"`
+}
+
+// BulkJobItem is the audit record of one resource changed by a bulk job. Previous holds
+// the patched fields before an update, or the full resource before a delete.
+type BulkJobItem struct {
+	ID         uint            `json:"-" gorm:"primaryKey;autoIncrement"`
+	JobID      string          `json:"jobId" gorm:"index"`
+	ResourceID string          `json:"resourceId"`
+	Previous   json.RawMessage `json:"previous" gorm:"type:jsonb"`
+	CreatedAt  time.Time       `json:"createdAt"`
+}
+
+// ObservationFilter selects observations by the same criteria as the observation search
+type ObservationFilter struct {
+	IDs      []string `json:"ids,omitempty"`
+	Patient  string   `json:"patient,omitempty"`
+	Status   string   `json:"status,omitempty"`
+	Category string   `json:"category,omitempty"` // Managed code or "system|code"
+	Code     string   `json:"code,omitempty"`
+	From     string   `json:"from,omitempty"`
+	To       string   `json:"to,omitempty"`
+}
+
+// ObservationPatch lists the fields a bulk update may set
+type ObservationPatch struct {
+	Status   *string    `json:"status,omitempty" validate:"omitempty,oneof=registered preliminary final amended corrected cancelled entered-in-error unknown"`
+	Category []Category `json:"category,omitempty" validate:"omitempty,dive"`
+}
+
+// BulkJobRequest represents a request to preview a bulk update or delete
+type BulkJobRequest struct {
+	Filter ObservationFilter `json:"filter"`
+	Patch  *ObservationPatch `json:"patch,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a bulk job
+func (j *BulkJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the BulkJob model
+func (BulkJob) TableName() string {
+	return "bulk_jobs"
+}
+
+// TableName returns the table name for the BulkJobItem model
+func (BulkJobItem) TableName() string {
+	return "bulk_job_items"
+}
+
+// filterIDPattern matches the resource IDs an observation filter may name
+var filterIDPattern = regexp.MustCompile(`^[A-Za-z0-9-]{1,64}$`)
+
+// filterDateLayouts are the ISO 8601 forms accepted for the date range of a filter
+var filterDateLayouts = []string{time.RFC3339Nano, "2006-01-02T15:04:05", "2006-01-02"}
+
+// observationStatuses are the statuses an observation may have
+var observationStatuses = map[string]bool{
+	"registered": true, "preliminary": true, "final": true, "amended": true,
+	"corrected": true, "cancelled": true, "entered-in-error": true, "unknown": true,
+}
+
+// Validate returns an error describing the first criterion of the filter that is
+// malformed. Free text is restricted to the characters dialect.CheckFilterText allows.
+func (f ObservationFilter) Validate() error {
+	for _, id := range f.IDs {
+		if !filterIDPattern.MatchString(id) {
+			return fmt.Errorf("ids must be resource IDs of letters, digits and dashes")
+		}
+	}
+	if f.Patient != "" && !filterIDPattern.MatchString(f.Patient) {
+		return fmt.Errorf("patient must be a resource ID of letters, digits and dashes")
+	}
+	if f.Status != "" && !observationStatuses[f.Status] {
+		return fmt.Errorf("status %q is not an observation status", f.Status)
+	}
+	if err := dialect.CheckFilterText("category", f.Category); err != nil {
+		return err
+	}
+	if err := dialect.CheckFilterText("code", f.Code); err != nil {
+		return err
+	}
+	for name, value := range map[string]string{"from": f.From, "to": f.To} {
+		if value != "" && !validFilterDate(value) {
+			return fmt.Errorf("%s must be an ISO 8601 date or date-time", name)
+		}
+	}
+	return nil
+}
+
+// validFilterDate reports whether a value is in one of the filter date layouts
+func validFilterDate(value string) bool {
+	for _, layout := range filterDateLayouts {
+		if _, err := time.Parse(layout, value); err == nil {
+			return true
+		}
+	}
+	return false
+}
+
+// IsEmpty reports whether the filter has no criteria and would match every observation
+func (f ObservationFilter) IsEmpty() bool {
+	return len(f.IDs) == 0 && f.Patient == "" && f.Status == "" && f.Category == "" &&
+		f.Code == "" && f.From == "" && f.To == ""
+}
+
+// Apply adds the filter criteria to an observation query
+func (f ObservationFilter) Apply(query *gorm.DB) *gorm.DB {
+	d := dialect.Of(query)
+
+	if len(f.IDs) > 0 {
+		query = query.Where("id IN ?", f.IDs)
+	}
+
+	if f.Patient != "" {
+		query = query.Where(d.JSONText("subject", "reference")+" = ?", "Patient/"+f.Patient)
+	}
+
+	if f.Status != "" {
+		query = query.Where("status = ?", f.Status)
+	}
+
+	if f.Category != "" {
+		query = query.Where(d.ContainsCoding("category"), categoryContainment(f.Category))
+	}
+
+	if f.Code != "" {
+		condition, args := dialect.ContainsAny(d, f.Code,
+			d.JSONText("code", "text"), d.JSONText("code", "coding", "0", "code"), d.JSONText("code", "coding", "0", "display"))
+		query = query.Where(condition, args...)
+	}
+
+	if f.From != "" {
+		query = query.Where("effective_date_time >= ?", f.From)
+	}
+
+	if f.To != "" {
+		query = query.Where("effective_date_time <= ?", f.To)
+	}
+
+	return query
+}
+
+// Columns returns the observation columns the patch writes
+func (p *ObservationPatch) Columns() []string {
+	var columns []string
+	if p.Status != nil {
+		columns = append(columns, "status")
+	}
+	if p.Category != nil {
+		columns = append(columns, "category")
+	}
+	return columns
+}
+
+// ApplyTo sets the patched fields on an observation
+func (p *ObservationPatch) ApplyTo(observation *Observation) {
+	if p.Status != nil {
+		observation.Status = *p.Status
+	}
+	if p.Category != nil {
+		observation.Category = p.Category
+	}
+}
+
+// categoryContainment builds a JSON containment filter for a category search value,
+// given as a managed code or as "system|code"
+func categoryContainment(value string) string {
+	system, code := ObservationCategorySystem, value
+	if i := strings.Index(value, "|"); i >= 0 {
+		system, code = value[:i], value[i+1:]
+	}
+
+	coding := map[string]string{"code": code}
+	if system != "" {
+		coding["system"] = system
+	}
+	filter, _ := json.Marshal([]map[string]interface{}{{"coding": []map[string]string{coding}}})
+	return string(filter)
+}
@@ -0,0 +1,84 @@
+package models
+
+import (
+	"strings"
+	"testing"
+
+	"gorm.io/driver/postgres"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+func FuzzObservationFilterValidate(f *testing.F) {
+	f.Add("", "", "", "", "", "", "")
+	f.Add("0b6c8a4e-1f7d-4c2a-9e5b-3d2f1a0c9b8e", "patient-1", "final", "vital-signs", "8480-6", "2024-01-01", "2024-12-31T23:59:59Z")
+	f.Add("a", "b", "amended", "http://loinc.org|8480-6", "Blood pressure", "2024-01-01T00:00:00", "")
+	f.Add("1' OR '1'='1", "x;DROP TABLE observations", "final' --", `laboratory"}]`, "100%_", "2024-13-01", "now()")
+	f.Add("", "", "", "|", `\`, "", "")
+
+	// Statements are only rendered, never sent, so no database is needed
+	db, err := gorm.Open(postgres.New(postgres.Config{DSN: "host=localhost"}), &gorm.Config{
+		DryRun:               true,
+		DisableAutomaticPing: true,
+		Logger:               logger.Discard,
+	})
+	if err != nil {
+		f.Fatal(err)
+	}
+
+	f.Fuzz(func(t *testing.T, id, patient, status, category, code, from, to string) {
+		filter := ObservationFilter{
+			Patient:  patient,
+			Status:   status,
+			Category: category,
+			Code:     code,
+			From:     from,
+			To:       to,
+		}
+		if id != "" {
+			filter.IDs = []string{id}
+		}
+		if err := filter.Validate(); err != nil {
+			return
+		}
+
+		if id != "" && !filterIDPattern.MatchString(id) {
+			t.Fatalf("accepted id %q", id)
+		}
+		if patient != "" && !filterIDPattern.MatchString(patient) {
+			t.Fatalf("accepted patient %q", patient)
+		}
+		if status != "" && !observationStatuses[status] {
+			t.Fatalf("accepted status %q", status)
+		}
+		for _, value := range []string{category, code} {
+			if strings.ContainsAny(value, "\"\\;%<>=*\x00") {
+				t.Fatalf("accepted %q with a character outside the whitelist", value)
+			}
+		}
+
+		// Accepted values are bound as arguments, so the SQL is that of any other filter
+		// with the same criteria
+		canonical := ObservationFilter{}
+		if id != "" {
+			canonical.IDs = []string{"id"}
+		}
+		for value, field := range map[*string]*string{
+			&patient: &canonical.Patient, &status: &canonical.Status, &category: &canonical.Category,
+			&code: &canonical.Code, &from: &canonical.From, &to: &canonical.To,
+		} {
+			if *value != "" {
+				*field = "final"
+			}
+		}
+		if sql, want := renderFilter(db, filter), renderFilter(db, canonical); sql != want {
+			t.Fatalf("SQL %q depends on the filter values, want %q", sql, want)
+		}
+	})
+}
+
+// renderFilter returns the SQL of an observation search with the filter
+func renderFilter(db *gorm.DB, filter ObservationFilter) string {
+	var observations []Observation
+	return filter.Apply(db.Model(&Observation{})).Find(&observations).Statement.SQL.String()
+}
@@ -0,0 +1,78 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// CaptureSession records the requests of one user, such as the service account of a
+// lab interface, for a limited time to debug an integration
+type CaptureSession struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	UserID       string     `json:"userId" gorm:"index"`
+	Reason       string     `json:"reason"`
+	RedactFields []string   `json:"redactFields,omitempty" gorm:"type:jsonb;serializer:json"` // Redacted in addition to the default PHI fields
+	MaxExchanges int        `json:"maxExchanges"`
+	Exchanges    int64      `json:"exchanges" gorm:"-"`
+	ExpiresAt    time.Time  `json:"expiresAt" gorm:"index"`
+	StoppedAt    *time.Time `json:"stoppedAt,omitempty"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	CreatedBy    string     `json:"createdBy"`
+	StoppedBy    string     `json:"stoppedBy,omitempty"`
+}
+
+// CaptureSessionRequest represents a request to start recording a user's requests
+type CaptureSessionRequest struct {
+	UserID          string   `json:"userId" validate:"required"`
+	Reason          string   `json:"reason" validate:"required,max=500"`
+	DurationMinutes int      `json:"durationMinutes" validate:"required,min=1,max=240"`
+	MaxExchanges    int      `json:"maxExchanges,omitempty" validate:"min=0,max=5000"`
+	RedactFields    []string `json:"redactFields,omitempty" validate:"max=50,dive,required,max=64"`
+}
+
+// CapturedExchange is a request and its response recorded by a capture session, with
+// PHI redacted
+type CapturedExchange struct {
+	ID                uint64            `json:"id" gorm:"primaryKey;autoIncrement"`
+	SessionID         string            `json:"sessionId" gorm:"index"`
+	UserID            string            `json:"userId"`
+	Method            string            `json:"method"`
+	Path              string            `json:"path"`
+	Query             string            `json:"query,omitempty"`
+	Route             string            `json:"route"`
+	StatusCode        int               `json:"statusCode"`
+	DurationMs        int64             `json:"durationMs"`
+	ClientIP          string            `json:"clientIp"`
+	RequestHeaders    map[string]string `json:"requestHeaders" gorm:"type:jsonb;serializer:json"`
+	RequestBody       string            `json:"requestBody,omitempty"`
+	RequestTruncated  bool              `json:"requestTruncated,omitempty"`
+	ResponseHeaders   map[string]string `json:"responseHeaders" gorm:"type:jsonb;serializer:json"`
+	ResponseBody      string            `json:"responseBody,omitempty"`
+	ResponseTruncated bool              `json:"responseTruncated,omitempty"`
+	CreatedAt         time.Time         `json:"createdAt" gorm:"index"`
+}
+
+// Active reports whether the session records requests at the given time
+func (s *CaptureSession) Active(now time.Time) bool {
+	return s.StoppedAt == nil && now.Before(s.ExpiresAt)
+}
+
+// BeforeCreate is a GORM hook that runs before creating a capture session
+func (s *CaptureSession) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the CaptureSession model
+func (CaptureSession) TableName() string {
+	return "capture_sessions"
+}
+
+// TableName returns the table name for the CapturedExchange model
+func (CapturedExchange) TableName() string {
+	return "captured_exchanges"
+}
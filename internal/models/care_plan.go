@@ -0,0 +1,185 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Care plan statuses
+const (
+	CarePlanDraft          = "draft"
+	CarePlanActive         = "active"
+	CarePlanOnHold         = "on-hold"
+	CarePlanRevoked        = "revoked"
+	CarePlanCompleted      = "completed"
+	CarePlanEnteredInError = "entered-in-error"
+)
+
+// Care plan goal lifecycle statuses
+const (
+	GoalProposed  = "proposed"
+	GoalActive    = "active"
+	GoalAchieved  = "achieved"
+	GoalCancelled = "cancelled"
+)
+
+// Care plan activity statuses
+const (
+	ActivityNotStarted = "not-started"
+	ActivityScheduled  = "scheduled"
+	ActivityInProgress = "in-progress"
+	ActivityOnHold     = "on-hold"
+	ActivityCompleted  = "completed"
+	ActivityCancelled  = "cancelled"
+)
+
+// carePlanTransitions are the statuses a care plan may move to from each status.
+// Revoked and completed plans are final, though either may still be marked entered
+// in error.
+var carePlanTransitions = map[string][]string{
+	CarePlanDraft:     {CarePlanActive, CarePlanRevoked, CarePlanEnteredInError},
+	CarePlanActive:    {CarePlanOnHold, CarePlanCompleted, CarePlanRevoked, CarePlanEnteredInError},
+	CarePlanOnHold:    {CarePlanActive, CarePlanRevoked, CarePlanEnteredInError},
+	CarePlanRevoked:   {CarePlanEnteredInError},
+	CarePlanCompleted: {CarePlanEnteredInError},
+}
+
+// CarePlan represents a FHIR-inspired CarePlan resource: how the care team intends
+// to manage the patient, with the goals it works towards and the activities it
+// plans
+type CarePlan struct {
+	ID          string             `json:"id" gorm:"primaryKey"`
+	Subject     Reference          `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Status      string             `json:"status" gorm:"index"`
+	Title       string             `json:"title"`
+	Description string             `json:"description,omitempty"`
+	Period      Period             `json:"period" gorm:"embedded;embeddedPrefix:period_"`
+	Addresses   []Reference        `json:"addresses,omitempty" gorm:"serializer:json"` // Conditions the plan manages
+	Goal        []CarePlanGoal     `json:"goal,omitempty" gorm:"serializer:json"`
+	Activity    []CarePlanActivity `json:"activity,omitempty" gorm:"foreignKey:CarePlanID"`
+	Note        string             `json:"note,omitempty" sanitize:"note"`
+	CreatedAt   time.Time          `json:"createdAt"`
+	UpdatedAt   time.Time          `json:"updatedAt"`
+	CreatedBy   string             `json:"createdBy"`
+}
+
+// CarePlanGoal is an outcome a care plan works towards, e.g. an HbA1c below 7%
+type CarePlanGoal struct {
+	Description     string     `json:"description" validate:"required"`
+	LifecycleStatus string     `json:"lifecycleStatus,omitempty" validate:"omitempty,oneof=proposed active achieved cancelled"`
+	Due             *time.Time `json:"due,omitempty"`
+}
+
+// CarePlanActivity is an action a care plan plans, such as a referral, an education
+// session or a follow-up appointment
+type CarePlanActivity struct {
+	ID          string          `json:"id" gorm:"primaryKey"`
+	CarePlanID  string          `json:"carePlanId" gorm:"index"`
+	Code        CodeableConcept `json:"code" gorm:"type:jsonb;serializer:json"`
+	Description string          `json:"description,omitempty"`
+	Status      string          `json:"status" gorm:"index"`
+	ScheduledAt *time.Time      `json:"scheduledDateTime,omitempty"`
+	Performer   []Reference     `json:"performer,omitempty" gorm:"serializer:json"`
+	Outcome     string          `json:"outcome,omitempty"` // How it went, once completed
+	CompletedAt *time.Time      `json:"completedAt,omitempty"`
+	CompletedBy string          `json:"completedBy,omitempty"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	UpdatedAt   time.Time       `json:"updatedAt"`
+	CreatedBy   string          `json:"createdBy"`
+}
+
+// CarePlanRequest represents a request to create a care plan or to replace one. The
+// subject is taken from the path when posted under a patient; the status of an
+// existing plan is changed through its status endpoint.
+type CarePlanRequest struct {
+	Subject     Reference                 `json:"subject"`
+	Status      string                    `json:"status,omitempty" validate:"omitempty,oneof=draft active"`
+	Title       string                    `json:"title" validate:"required,max=200"`
+	Description string                    `json:"description,omitempty"`
+	Period      Period                    `json:"period"`
+	Addresses   []Reference               `json:"addresses,omitempty" validate:"max=20"`
+	Goal        []CarePlanGoal            `json:"goal,omitempty" validate:"max=50,dive"`
+	Activity    []CarePlanActivityRequest `json:"activity,omitempty" validate:"max=100,dive"` // Only when creating a plan
+	Note        string                    `json:"note,omitempty"`
+}
+
+// CarePlanActivityRequest represents a request to add an activity to a care plan
+type CarePlanActivityRequest struct {
+	Code        CodeableConcept `json:"code"`
+	Description string          `json:"description,omitempty"`
+	Status      string          `json:"status,omitempty" validate:"omitempty,oneof=not-started scheduled in-progress on-hold"`
+	ScheduledAt *time.Time      `json:"scheduledDateTime,omitempty"`
+	Performer   []Reference     `json:"performer,omitempty" validate:"max=20"`
+}
+
+// CarePlanStatusRequest represents a request to change the status of a care plan,
+// e.g. to activate or complete it
+type CarePlanStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=draft active on-hold revoked completed entered-in-error"`
+}
+
+// CompleteActivityRequest represents a request to mark a care plan activity done
+type CompleteActivityRequest struct {
+	Outcome     string     `json:"outcome,omitempty"`
+	CompletedAt *time.Time `json:"completedAt,omitempty"` // Defaults to now
+}
+
+// CarePlanTransitionAllowed reports whether a care plan may move from one status to
+// another
+func CarePlanTransitionAllowed(from, to string) bool {
+	for _, status := range carePlanTransitions[from] {
+		if status == to {
+			return true
+		}
+	}
+	return false
+}
+
+// Ended reports whether the plan was revoked, completed or entered in error, after
+// which its activities no longer change
+func (p *CarePlan) Ended() bool {
+	switch p.Status {
+	case CarePlanRevoked, CarePlanCompleted, CarePlanEnteredInError:
+		return true
+	}
+	return false
+}
+
+// Ended reports whether the activity was completed or cancelled
+func (a *CarePlanActivity) Ended() bool {
+	return a.Status == ActivityCompleted || a.Status == ActivityCancelled
+}
+
+// BeforeCreate is a GORM hook that runs before creating a care plan
+func (p *CarePlan) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	if p.Status == "" {
+		p.Status = CarePlanDraft
+	}
+	return nil
+}
+
+// TableName returns the table name for the CarePlan model
+func (CarePlan) TableName() string {
+	return "care_plans"
+}
+
+// BeforeCreate is a GORM hook that runs before creating a care plan activity
+func (a *CarePlanActivity) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	if a.Status == "" {
+		a.Status = ActivityNotStarted
+	}
+	return nil
+}
+
+// TableName returns the table name for the CarePlanActivity model
+func (CarePlanActivity) TableName() string {
+	return "care_plan_activities"
+}
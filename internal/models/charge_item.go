@@ -0,0 +1,107 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Charge item statuses
+const (
+	ChargeBillable       = "billable"
+	ChargeNotBillable    = "not-billable"
+	ChargeBilled         = "billed"
+	ChargeEnteredInError = "entered-in-error"
+)
+
+// ChargeRule makes the services recorded with a code capture a charge. The charge
+// code is the rule's CPT or HCPCS code, or the one the concept maps translate the
+// service code to when the rule names none.
+type ChargeRule struct {
+	ID            string    `json:"id" gorm:"primaryKey"`
+	ResourceType  string    `json:"resourceType" gorm:"uniqueIndex:idx_charge_rules_code"` // Observation or MedicationAdministration
+	System        string    `json:"system" gorm:"column:code_system;uniqueIndex:idx_charge_rules_code"`
+	Code          string    `json:"code" gorm:"uniqueIndex:idx_charge_rules_code"`
+	ChargeSystem  string    `json:"chargeSystem,omitempty"`
+	ChargeCode    string    `json:"chargeCode,omitempty"`
+	ChargeDisplay string    `json:"chargeDisplay,omitempty" sanitize:"display"`
+	Quantity      int       `json:"quantity"`
+	Active        bool      `json:"active"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+	CreatedBy     string    `json:"createdBy"`
+}
+
+// ChargeRuleRequest represents a request to create or replace a charge rule
+type ChargeRuleRequest struct {
+	ResourceType  string `json:"resourceType" validate:"required,oneof=Observation MedicationAdministration"`
+	System        string `json:"system" validate:"required"`
+	Code          string `json:"code" validate:"required,max=64"`
+	ChargeSystem  string `json:"chargeSystem,omitempty" validate:"required_with=ChargeCode,omitempty,oneof=http://www.ama-assn.org/go/cpt https://www.cms.gov/Medicare/Coding/HCPCSReleaseCodeSets"`
+	ChargeCode    string `json:"chargeCode,omitempty" validate:"required_with=ChargeSystem,max=48"`
+	ChargeDisplay string `json:"chargeDisplay,omitempty"`
+	Quantity      int    `json:"quantity,omitempty" validate:"min=0,max=999"`
+	Active        *bool  `json:"active,omitempty"`
+}
+
+// ChargeItem represents a FHIR-inspired ChargeItem resource: a charge captured for a
+// performed service
+type ChargeItem struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	Status     string     `json:"status" gorm:"index"`
+	Subject    Reference  `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Encounter  *Reference `json:"encounter,omitempty" gorm:"embedded;embeddedPrefix:encounter_"`
+	Code       Coding     `json:"code" gorm:"embedded;embeddedPrefix:code_"`
+	Quantity   int        `json:"quantity"`
+	OccurredAt time.Time  `json:"occurrenceDateTime" gorm:"index"`
+	Service    Reference  `json:"service" gorm:"embedded;embeddedPrefix:service_"`
+	RuleID     string     `json:"ruleId"`
+	CaptureKey string     `json:"-" gorm:"uniqueIndex"` // Service and rule; a service captures each rule's charge once
+	Note       string     `json:"note,omitempty" sanitize:"note"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	UpdatedAt  time.Time  `json:"updatedAt"`
+	UpdatedBy  string     `json:"updatedBy,omitempty"`
+}
+
+// ChargeItemStatusRequest represents a request to change the status of a charge,
+// e.g. to void a duplicate
+type ChargeItemStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=billable not-billable billed entered-in-error"`
+	Note   string `json:"note,omitempty" validate:"max=500"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a charge rule
+func (r *ChargeRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	if r.Quantity == 0 {
+		r.Quantity = 1
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating a charge item
+func (i *ChargeItem) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	if i.Status == "" {
+		i.Status = ChargeBillable
+	}
+	if i.CaptureKey == "" {
+		i.CaptureKey = i.Service.Reference + "|" + i.RuleID
+	}
+	return nil
+}
+
+// TableName returns the table name for the ChargeRule model
+func (ChargeRule) TableName() string {
+	return "charge_rules"
+}
+
+// TableName returns the table name for the ChargeItem model
+func (ChargeItem) TableName() string {
+	return "charge_items"
+}
@@ -0,0 +1,75 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ErrSnapshotImmutable is returned when a chart snapshot is updated or deleted
+var ErrSnapshotImmutable = errors.New("chart snapshots cannot be changed once taken")
+
+// ChartSnapshot is a read-only copy of a patient's chart as it was at a point in
+// time, kept for legal discovery. The content is stored as the exact bytes it was
+// checksummed over, so later edits to the chart do not change it.
+type ChartSnapshot struct {
+	ID        string         `json:"id" gorm:"primaryKey"`
+	PatientID string         `json:"patientId" gorm:"index"`
+	Reason    string         `json:"reason"`
+	TakenAt   time.Time      `json:"takenAt"`
+	Records   map[string]int `json:"records" gorm:"serializer:json"` // Records captured by resource type
+	Size      int64          `json:"size"`
+	SHA256    string         `json:"sha256"`
+	Content   []byte         `json:"-"`
+	CreatedBy string         `json:"createdBy"`
+	CreatedAt time.Time      `json:"createdAt"`
+}
+
+// ChartSnapshotRequest represents a request to take a snapshot of a patient's chart
+type ChartSnapshotRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"` // e.g. the matter or request it was taken for
+}
+
+// ChartContent is the chart captured by a snapshot
+type ChartContent struct {
+	SnapshotID                string                     `json:"snapshotId"`
+	TakenAt                   time.Time                  `json:"takenAt"`
+	Patient                   Patient                    `json:"patient"`
+	Observations              []Observation              `json:"observations"`
+	Conditions                []Condition                `json:"conditions"`
+	AllergyIntolerances       []AllergyIntolerance       `json:"allergyIntolerances"`
+	Procedures                []Procedure                `json:"procedures"`
+	CarePlans                 []CarePlan                 `json:"carePlans"`
+	MedicationRequests        []MedicationRequest        `json:"medicationRequests"`
+	MedicationAdministrations []MedicationAdministration `json:"medicationAdministrations"`
+	ClinicalNotes             []ClinicalNote             `json:"clinicalNotes"`
+	Specimens                 []Specimen                 `json:"specimens"`
+	QuestionnaireResponses    []QuestionnaireResponse    `json:"questionnaireResponses"`
+	Media                     []Media                    `json:"media"` // Metadata; the files of media and documents stay in storage
+	DocumentReferences        []DocumentReference        `json:"documentReferences"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a chart snapshot
+func (s *ChartSnapshot) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeUpdate is a GORM hook that keeps chart snapshots from being changed
+func (s *ChartSnapshot) BeforeUpdate(tx *gorm.DB) error {
+	return ErrSnapshotImmutable
+}
+
+// BeforeDelete is a GORM hook that keeps chart snapshots from being deleted
+func (s *ChartSnapshot) BeforeDelete(tx *gorm.DB) error {
+	return ErrSnapshotImmutable
+}
+
+// TableName returns the table name for the ChartSnapshot model
+func (ChartSnapshot) TableName() string {
+	return "chart_snapshots"
+}
@@ -0,0 +1,132 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ClinicalNote represents a free-text clinical note authored against a patient
+type ClinicalNote struct {
+	ID              string          `json:"id" gorm:"primaryKey"`
+	Status          string          `json:"status" gorm:"index"`
+	Type            CodeableConcept `json:"type" gorm:"embedded;embeddedPrefix:type_"`
+	Subject         Reference       `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Encounter       *Reference      `json:"encounter,omitempty" gorm:"embedded;embeddedPrefix:encounter_"`
+	Author          Reference       `json:"author" gorm:"embedded;embeddedPrefix:author_"`
+	Title           string          `json:"title,omitempty"`
+	ContentType     string          `json:"contentType" validate:"omitempty,oneof=text/plain text/markdown"`
+	Body            string          `json:"body" validate:"required" sanitize:"note"`
+	ReasonReference []Reference     `json:"reasonReference,omitempty" gorm:"type:jsonb;serializer:json"` // Conditions the note addresses
+	Version         int             `json:"version"`
+	SignedAt        *time.Time      `json:"signedAt,omitempty"`
+	SignedBy        string          `json:"signedBy,omitempty"`
+	Addenda         []NoteAddendum  `json:"addenda,omitempty" gorm:"foreignKey:NoteID"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+	CreatedBy       string          `json:"createdBy"`
+}
+
+// NoteAddendum represents text appended to a signed clinical note
+type NoteAddendum struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	NoteID    string    `json:"noteId" gorm:"index"`
+	Author    Reference `json:"author" gorm:"embedded;embeddedPrefix:author_"`
+	Body      string    `json:"body" validate:"required" sanitize:"note"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy"`
+}
+
+// ClinicalNoteVersion stores a prior revision of a draft clinical note
+type ClinicalNoteVersion struct {
+	ID          string          `json:"id" gorm:"primaryKey"`
+	NoteID      string          `json:"noteId" gorm:"uniqueIndex:idx_note_version"`
+	Version     int             `json:"version" gorm:"uniqueIndex:idx_note_version"`
+	Type        CodeableConcept `json:"type" gorm:"embedded;embeddedPrefix:type_"`
+	Title       string          `json:"title,omitempty"`
+	ContentType string          `json:"contentType"`
+	Body        string          `json:"body"`
+	CreatedAt   time.Time       `json:"createdAt"`
+	CreatedBy   string          `json:"createdBy"`
+}
+
+// Clinical note statuses
+const (
+	NoteStatusDraft          = "draft"
+	NoteStatusSigned         = "signed"
+	NoteStatusEnteredInError = "entered-in-error"
+)
+
+// BeforeCreate is a GORM hook that runs before creating a clinical note
+func (n *ClinicalNote) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating a note addendum
+func (a *NoteAddendum) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating a note version
+func (v *ClinicalNoteVersion) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the ClinicalNote model
+func (ClinicalNote) TableName() string {
+	return "clinical_notes"
+}
+
+// TableName returns the table name for the NoteAddendum model
+func (NoteAddendum) TableName() string {
+	return "clinical_note_addenda"
+}
+
+// TableName returns the table name for the ClinicalNoteVersion model
+func (ClinicalNoteVersion) TableName() string {
+	return "clinical_note_versions"
+}
+
+// IsSigned reports whether the note has been signed and is therefore immutable
+func (n *ClinicalNote) IsSigned() bool {
+	return n.Status == NoteStatusSigned
+}
+
+// Snapshot returns the current content of the note as a version record
+func (n *ClinicalNote) Snapshot() ClinicalNoteVersion {
+	return ClinicalNoteVersion{
+		NoteID:      n.ID,
+		Version:     n.Version,
+		Type:        n.Type,
+		Title:       n.Title,
+		ContentType: n.ContentType,
+		Body:        n.Body,
+		CreatedBy:   n.CreatedBy,
+	}
+}
+
+// ClinicalNoteUpdateRequest represents an edit to a draft clinical note
+type ClinicalNoteUpdateRequest struct {
+	Type            *CodeableConcept `json:"type,omitempty"`
+	Encounter       *Reference       `json:"encounter,omitempty"`
+	Title           *string          `json:"title,omitempty"`
+	ContentType     string           `json:"contentType,omitempty" validate:"omitempty,oneof=text/plain text/markdown"`
+	Body            string           `json:"body" validate:"required"`
+	ReasonReference *[]Reference     `json:"reasonReference,omitempty"`         // Replaces the conditions the note addresses
+	Version         int              `json:"version" validate:"required,min=1"` // Version being edited, for optimistic locking
+}
+
+// AddendumRequest represents a request to append an addendum to a signed note
+type AddendumRequest struct {
+	Body string `json:"body" validate:"required"`
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ObservationStatusSystem is the code system of observation statuses
+const ObservationStatusSystem = "http://hl7.org/fhir/observation-status"
+
+// ConceptDisplay is the display of a code in a language, such as "Alto" for the
+// interpretation H in Spanish, shown to patients in place of the code. Languages are
+// lower-case BCP 47 tags, e.g. es or es-mx.
+type ConceptDisplay struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	System    string    `json:"system" gorm:"uniqueIndex:idx_concept_displays_language"`
+	Code      string    `json:"code" gorm:"uniqueIndex:idx_concept_displays_language"`
+	Language  string    `json:"language" gorm:"uniqueIndex:idx_concept_displays_language"`
+	Display   string    `json:"display" sanitize:"display"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// ConceptDisplayRequest represents a request to create or replace a display
+type ConceptDisplayRequest struct {
+	System   string `json:"system" validate:"required,url"`
+	Code     string `json:"code" validate:"required,max=64"`
+	Language string `json:"language" validate:"required,bcp47_language_tag"`
+	Display  string `json:"display" validate:"required,max=256"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a concept display
+func (d *ConceptDisplay) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the ConceptDisplay model
+func (ConceptDisplay) TableName() string {
+	return "concept_displays"
+}
@@ -0,0 +1,95 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Billing code systems that concept maps translate SNOMED CT and LOINC codes into:
+// ICD-10 for diagnoses, CPT and HCPCS for performed services
+const (
+	ICD10System   = "http://hl7.org/fhir/sid/icd-10"
+	ICD10CMSystem = "http://hl7.org/fhir/sid/icd-10-cm"
+	CPTSystem     = "http://www.ama-assn.org/go/cpt"
+	HCPCSSystem   = "https://www.cms.gov/Medicare/Coding/HCPCSReleaseCodeSets"
+)
+
+// Concept map relationships, read from source to target
+const (
+	MappingEquivalent     = "equivalent"
+	MappingSourceNarrower = "source-is-narrower-than-target"
+	MappingSourceBroader  = "source-is-broader-than-target"
+	MappingRelatedTo      = "related-to"
+	MappingNotRelatedTo   = "not-related-to"
+)
+
+// ConceptMapping is one entry of a concept map stored in the database: a code in
+// one system and the code it maps to in another. A source code may have several
+// targets, tried in priority order.
+type ConceptMapping struct {
+	ID            string    `json:"id" gorm:"primaryKey"`
+	SourceSystem  string    `json:"sourceSystem" gorm:"uniqueIndex:idx_concept_mappings_pair;index:idx_concept_mappings_source"`
+	SourceCode    string    `json:"sourceCode" gorm:"uniqueIndex:idx_concept_mappings_pair;index:idx_concept_mappings_source"`
+	SourceDisplay string    `json:"sourceDisplay,omitempty" sanitize:"display"`
+	TargetSystem  string    `json:"targetSystem" gorm:"uniqueIndex:idx_concept_mappings_pair;index:idx_concept_mappings_target"`
+	TargetCode    string    `json:"targetCode" gorm:"uniqueIndex:idx_concept_mappings_pair;index:idx_concept_mappings_target"`
+	TargetDisplay string    `json:"targetDisplay,omitempty" sanitize:"display"`
+	Relationship  string    `json:"relationship"`
+	Priority      int       `json:"priority"` // Lower is preferred
+	Comment       string    `json:"comment,omitempty" sanitize:"note"`
+	Active        bool      `json:"active"`
+	CreatedAt     time.Time `json:"createdAt"`
+	UpdatedAt     time.Time `json:"updatedAt"`
+}
+
+// ConceptMappingRequest represents a request to create or replace a mapping
+type ConceptMappingRequest struct {
+	SourceSystem  string `json:"sourceSystem" validate:"required,url"`
+	SourceCode    string `json:"sourceCode" validate:"required,max=64"`
+	SourceDisplay string `json:"sourceDisplay,omitempty"`
+	TargetSystem  string `json:"targetSystem" validate:"required,url,nefield=SourceSystem"`
+	TargetCode    string `json:"targetCode" validate:"required,max=64"`
+	TargetDisplay string `json:"targetDisplay,omitempty"`
+	Relationship  string `json:"relationship,omitempty" validate:"omitempty,oneof=equivalent source-is-narrower-than-target source-is-broader-than-target related-to not-related-to"`
+	Priority      int    `json:"priority,omitempty" validate:"min=0"`
+	Comment       string `json:"comment,omitempty"`
+	Active        *bool  `json:"active,omitempty"`
+}
+
+// TranslateRequest represents a $translate request: the code to map and the system
+// to map it into
+type TranslateRequest struct {
+	System       string `json:"system" validate:"required"`
+	Code         string `json:"code" validate:"required"`
+	TargetSystem string `json:"targetSystem" validate:"required"`
+}
+
+// InverseRelationship returns the relationship read from target to source
+func InverseRelationship(relationship string) string {
+	switch relationship {
+	case MappingSourceNarrower:
+		return MappingSourceBroader
+	case MappingSourceBroader:
+		return MappingSourceNarrower
+	default:
+		return relationship
+	}
+}
+
+// BeforeCreate is a GORM hook that runs before creating a concept mapping
+func (m *ConceptMapping) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	if m.Relationship == "" {
+		m.Relationship = MappingEquivalent
+	}
+	return nil
+}
+
+// TableName returns the table name for the ConceptMapping model
+func (ConceptMapping) TableName() string {
+	return "concept_mappings"
+}
@@ -0,0 +1,116 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Condition clinical statuses
+const (
+	ConditionActive     = "active"
+	ConditionRecurrence = "recurrence"
+	ConditionRelapse    = "relapse"
+	ConditionInactive   = "inactive"
+	ConditionRemission  = "remission"
+	ConditionResolved   = "resolved"
+)
+
+// Condition verification statuses
+const (
+	ConditionProvisional    = "provisional"
+	ConditionDifferential   = "differential"
+	ConditionConfirmed      = "confirmed"
+	ConditionRefuted        = "refuted"
+	ConditionEnteredInError = "entered-in-error"
+)
+
+// Condition represents a FHIR-inspired Condition resource: a problem, diagnosis or
+// concern on the patient's problem list. Observations, medication requests and
+// clinical notes name the conditions they address in their reasonReference.
+type Condition struct {
+	ID                 string          `json:"id" gorm:"primaryKey"`
+	Subject            Reference       `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Code               CodeableConcept `json:"code" gorm:"type:jsonb;serializer:json"`
+	ClinicalStatus     string          `json:"clinicalStatus" gorm:"index"`
+	VerificationStatus string          `json:"verificationStatus"`
+	Category           string          `json:"category,omitempty"` // problem-list-item or encounter-diagnosis
+	Severity           string          `json:"severity,omitempty"` // mild, moderate or severe
+	OnsetAt            *time.Time      `json:"onsetDateTime,omitempty"`
+	AbatementAt        *time.Time      `json:"abatementDateTime,omitempty"`
+	Recorder           Reference       `json:"recorder" gorm:"embedded;embeddedPrefix:recorder_"` // User who last recorded the condition
+	Note               string          `json:"note,omitempty" sanitize:"note"`
+	CreatedAt          time.Time       `json:"createdAt"`
+	UpdatedAt          time.Time       `json:"updatedAt"`
+	CreatedBy          string          `json:"createdBy"`
+	DeletedAt          gorm.DeletedAt  `json:"-" gorm:"index"` // Set while a delete can still be undone
+	DeletedBy          string          `json:"-"`
+}
+
+// ConditionRequest represents a request to add a condition to the problem list or
+// to replace one. The subject is taken from the path when posted under a patient.
+type ConditionRequest struct {
+	Subject            Reference       `json:"subject"`
+	Code               CodeableConcept `json:"code"`
+	ClinicalStatus     string          `json:"clinicalStatus,omitempty" validate:"omitempty,oneof=active recurrence relapse inactive remission resolved"`
+	VerificationStatus string          `json:"verificationStatus,omitempty" validate:"omitempty,oneof=provisional differential confirmed refuted entered-in-error"`
+	Category           string          `json:"category,omitempty" validate:"omitempty,oneof=problem-list-item encounter-diagnosis"`
+	Severity           string          `json:"severity,omitempty" validate:"omitempty,oneof=mild moderate severe"`
+	OnsetAt            *time.Time      `json:"onsetDateTime,omitempty"`
+	AbatementAt        *time.Time      `json:"abatementDateTime,omitempty"` // Kept only while the condition is resolved, in remission or inactive
+	Note               string          `json:"note,omitempty"`
+}
+
+// ConditionStatusRequest represents a request to change the status of a condition,
+// e.g. to resolve it
+type ConditionStatusRequest struct {
+	ClinicalStatus     string     `json:"clinicalStatus,omitempty" validate:"required_without=VerificationStatus,omitempty,oneof=active recurrence relapse inactive remission resolved"`
+	VerificationStatus string     `json:"verificationStatus,omitempty" validate:"omitempty,oneof=provisional differential confirmed refuted entered-in-error"`
+	AbatementAt        *time.Time `json:"abatementDateTime,omitempty"` // Defaults to now when the condition is resolved
+}
+
+// ConditionAbatement returns when a condition with the clinical status abated: at,
+// or now when not given, for resolved, remitted and inactive conditions and nil for
+// those that are still active
+func ConditionAbatement(status string, at *time.Time) *time.Time {
+	switch status {
+	case ConditionResolved, ConditionRemission, ConditionInactive:
+		abatement := time.Now().UTC()
+		if at != nil {
+			abatement = at.UTC()
+		}
+		return &abatement
+	}
+	return nil
+}
+
+// ReasonContainment returns the document matching a reference to the condition in a
+// reasonReference array, for dialect.ContainsReference
+func ReasonContainment(conditionID string) string {
+	filter, _ := json.Marshal([]map[string]string{{"reference": "Condition/" + conditionID}})
+	return string(filter)
+}
+
+// BeforeCreate is a GORM hook that runs before creating a condition
+func (c *Condition) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	if c.ClinicalStatus == "" {
+		c.ClinicalStatus = ConditionActive
+	}
+	if c.VerificationStatus == "" {
+		c.VerificationStatus = ConditionConfirmed
+	}
+	if c.Category == "" {
+		c.Category = "problem-list-item"
+	}
+	return nil
+}
+
+// TableName returns the table name for the Condition model
+func (Condition) TableName() string {
+	return "conditions"
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ContactVerification tracks a one-time code sent to a patient phone number or email address
+type ContactVerification struct {
+	ID         string     `json:"id" gorm:"primaryKey"`
+	PatientID  string     `json:"patientId" gorm:"index"`
+	System     string     `json:"system"`
+	Value      string     `json:"value"`
+	CodeHash   string     `json:"-"`
+	Attempts   int        `json:"attempts"`
+	ExpiresAt  time.Time  `json:"expiresAt"`
+	VerifiedAt *time.Time `json:"verifiedAt,omitempty"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	CreatedBy  string     `json:"createdBy"`
+}
+
+// ContactVerificationRequest represents a request to start verifying a telecom entry
+type ContactVerificationRequest struct {
+	System string `json:"system" validate:"oneof=phone sms email"`
+	Value  string `json:"value" validate:"required"`
+}
+
+// ContactConfirmationRequest represents a request to confirm a verification code
+type ContactConfirmationRequest struct {
+	Code string `json:"code" validate:"required,len=6,numeric"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a contact verification
+func (v *ContactVerification) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the ContactVerification model
+func (ContactVerification) TableName() string {
+	return "contact_verifications"
+}
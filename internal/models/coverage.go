@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Coverage statuses
+const (
+	CoverageActive         = "active"
+	CoverageCancelled      = "cancelled"
+	CoverageDraft          = "draft"
+	CoverageEnteredInError = "entered-in-error"
+)
+
+// Relationships of a subscriber to the covered patient
+const (
+	CoverageSubscriberSelf   = "self"
+	CoverageSubscriberSpouse = "spouse"
+	CoverageSubscriberChild  = "child"
+	CoverageSubscriberParent = "parent"
+	CoverageSubscriberOther  = "other"
+)
+
+// Coverage represents a FHIR-inspired Coverage resource: an insurance policy that
+// pays for the care of the patient it covers
+type Coverage struct {
+	ID             string           `json:"id" gorm:"primaryKey"`
+	Status         string           `json:"status" gorm:"index"`
+	Type           *CodeableConcept `json:"type,omitempty" gorm:"type:jsonb;serializer:json"` // e.g. medical, dental or vision
+	Beneficiary    Reference        `json:"beneficiary" gorm:"embedded;embeddedPrefix:beneficiary_"`
+	Payor          CoveragePayor    `json:"payor" gorm:"embedded;embeddedPrefix:payor_"`
+	SubscriberID   string           `json:"subscriberId" gorm:"index"` // Member ID on the insurance card
+	SubscriberName string           `json:"subscriberName,omitempty"`  // Policy holder, when not the patient
+	Relationship   string           `json:"relationship"`              // Of the subscriber to the patient
+	Plan           string           `json:"plan"`
+	Group          string           `json:"group,omitempty" gorm:"column:group_number"` // Group number of an employer plan
+	Period         Period           `json:"period" gorm:"embedded;embeddedPrefix:period_"`
+	Note           string           `json:"note,omitempty" sanitize:"note"`
+	CreatedAt      time.Time        `json:"createdAt"`
+	UpdatedAt      time.Time        `json:"updatedAt"`
+	CreatedBy      string           `json:"createdBy"`
+	DeletedAt      gorm.DeletedAt   `json:"-" gorm:"index"` // Set while a delete can still be undone
+	DeletedBy      string           `json:"-"`
+}
+
+// CoveragePayor is the insurer that pays under a coverage
+type CoveragePayor struct {
+	Name       string `json:"name" validate:"required,max=200"`
+	Identifier string `json:"identifier,omitempty" validate:"max=50"` // e.g. the payer ID used for electronic claims
+}
+
+// CoverageRequest represents a request to record a coverage or to replace one. The
+// beneficiary is taken from the path when posted under a patient.
+type CoverageRequest struct {
+	Beneficiary    Reference        `json:"beneficiary"`
+	Status         string           `json:"status,omitempty" validate:"omitempty,oneof=active cancelled draft entered-in-error"`
+	Type           *CodeableConcept `json:"type,omitempty"`
+	Payor          CoveragePayor    `json:"payor"`
+	SubscriberID   string           `json:"subscriberId" validate:"required,max=64"`
+	SubscriberName string           `json:"subscriberName,omitempty" validate:"max=200"`
+	Relationship   string           `json:"relationship,omitempty" validate:"omitempty,oneof=self spouse child parent other"`
+	Plan           string           `json:"plan" validate:"required,max=200"`
+	Group          string           `json:"group,omitempty" validate:"max=64"`
+	Period         Period           `json:"period"`
+	Note           string           `json:"note,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a coverage
+func (c *Coverage) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	if c.Status == "" {
+		c.Status = CoverageActive
+	}
+	if c.Relationship == "" {
+		c.Relationship = CoverageSubscriberSelf
+	}
+	return nil
+}
+
+// TableName returns the table name for the Coverage model
+func (Coverage) TableName() string {
+	return "coverages"
+}
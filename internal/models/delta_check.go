@@ -0,0 +1,39 @@
+package models
+
+import "time"
+
+// InterpretationSystem is the code system for observation interpretation flags
+const InterpretationSystem = "http://terminology.hl7.org/CodeSystem/v3-ObservationInterpretation"
+
+// DeltaCheckRule sets how much a result for a code may differ from the patient's
+// previous result before it is flagged. A result exceeding either threshold fails.
+type DeltaCheckRule struct {
+	Code           string    `json:"code" gorm:"primaryKey"`
+	System         string    `json:"system,omitempty" gorm:"column:code_system"` // Any system when empty
+	Display        string    `json:"display,omitempty" sanitize:"display"`
+	AbsoluteChange *float64  `json:"absoluteChange,omitempty"`
+	Unit           string    `json:"unit,omitempty"`          // UCUM unit of absoluteChange; the result's unit when empty
+	PercentChange  *float64  `json:"percentChange,omitempty"` // Relative to the previous result
+	WindowHours    int       `json:"windowHours,omitempty"`   // Only compare with results this recent; no limit when 0
+	RaiseAlert     bool      `json:"raiseAlert"`
+	Active         bool      `json:"active"`
+	CreatedAt      time.Time `json:"createdAt"`
+	UpdatedAt      time.Time `json:"updatedAt"`
+}
+
+// DeltaCheckRuleRequest represents a request to create or replace a delta check rule
+type DeltaCheckRuleRequest struct {
+	System         string   `json:"system,omitempty"`
+	Display        string   `json:"display,omitempty"`
+	AbsoluteChange *float64 `json:"absoluteChange,omitempty" validate:"required_without=PercentChange,omitempty,gt=0"`
+	Unit           string   `json:"unit,omitempty"`
+	PercentChange  *float64 `json:"percentChange,omitempty" validate:"required_without=AbsoluteChange,omitempty,gt=0"`
+	WindowHours    int      `json:"windowHours,omitempty" validate:"min=0"`
+	RaiseAlert     bool     `json:"raiseAlert"`
+	Active         *bool    `json:"active,omitempty"`
+}
+
+// TableName returns the table name for the DeltaCheckRule model
+func (DeltaCheckRule) TableName() string {
+	return "delta_check_rules"
+}
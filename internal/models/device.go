@@ -0,0 +1,61 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Device statuses
+const (
+	DeviceActive         = "active"
+	DeviceInactive       = "inactive" // Decommissioned or out of service
+	DeviceEnteredInError = "entered-in-error"
+	DeviceUnknown        = "unknown"
+)
+
+// Device represents a FHIR-inspired Device resource: a laboratory instrument or
+// other device that produces results. Observations refer to it as Device/{id}.
+type Device struct {
+	ID           string           `json:"id" gorm:"primaryKey"`
+	Status       string           `json:"status" gorm:"index"`
+	DeviceName   string           `json:"deviceName"` // e.g. the instrument's name on the lab floor
+	Type         *CodeableConcept `json:"type,omitempty" gorm:"type:jsonb;serializer:json"`
+	Manufacturer string           `json:"manufacturer"`
+	ModelNumber  string           `json:"modelNumber,omitempty"`
+	SerialNumber string           `json:"serialNumber" gorm:"index"`
+	Department   string           `json:"department,omitempty" gorm:"index"` // Managed observation category code
+	Note         string           `json:"note,omitempty" sanitize:"note"`
+	CreatedAt    time.Time        `json:"createdAt"`
+	UpdatedAt    time.Time        `json:"updatedAt"`
+	CreatedBy    string           `json:"createdBy"`
+}
+
+// DeviceRequest represents a request to register a device or to replace one
+type DeviceRequest struct {
+	Status       string           `json:"status,omitempty" validate:"omitempty,oneof=active inactive entered-in-error unknown"`
+	DeviceName   string           `json:"deviceName" validate:"required,max=200"`
+	Type         *CodeableConcept `json:"type,omitempty"`
+	Manufacturer string           `json:"manufacturer" validate:"required,max=200"`
+	ModelNumber  string           `json:"modelNumber,omitempty" validate:"max=100"`
+	SerialNumber string           `json:"serialNumber" validate:"required,max=100"`
+	Department   string           `json:"department,omitempty" validate:"max=64"`
+	Note         string           `json:"note,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a device
+func (d *Device) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if d.Status == "" {
+		d.Status = DeviceActive
+	}
+	return nil
+}
+
+// TableName returns the table name for the Device model
+func (Device) TableName() string {
+	return "devices"
+}
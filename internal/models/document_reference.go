@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// DocumentReference statuses
+const (
+	DocumentReferenceCurrent        = "current"
+	DocumentReferenceSuperseded     = "superseded"
+	DocumentReferenceEnteredInError = "entered-in-error"
+)
+
+// LabReportDocumentType is the LOINC code of laboratory reports, the type of
+// uploaded documents that do not name one
+const LabReportDocumentType = "11502-2"
+
+// DocumentReference represents a FHIR-inspired DocumentReference resource: a
+// document about a patient, such as a scanned lab report, whose file is kept in
+// attachment storage. Only its metadata is stored in the database.
+type DocumentReference struct {
+	ID          string            `json:"id" gorm:"primaryKey"`
+	Status      string            `json:"status" gorm:"index"`
+	Type        CodeableConcept   `json:"type" gorm:"type:jsonb;serializer:json"`
+	Category    []CodeableConcept `json:"category,omitempty" gorm:"type:jsonb;serializer:json"`
+	Subject     Reference         `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Date        time.Time         `json:"date" gorm:"index"` // When the document was created, e.g. the report date
+	Author      []Reference       `json:"author,omitempty" gorm:"serializer:json"`
+	Description string            `json:"description,omitempty"`
+	Content     Attachment        `json:"content" gorm:"embedded;embeddedPrefix:content_"`
+	StorageKey  string            `json:"-"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	CreatedBy   string            `json:"createdBy"`
+	DeletedAt   gorm.DeletedAt    `json:"-" gorm:"index"` // Set while a delete can still be undone
+	DeletedBy   string            `json:"-"`
+}
+
+// DocumentReferenceStatusRequest represents a request to change the status of a
+// document, e.g. to mark one uploaded for the wrong patient entered-in-error
+type DocumentReferenceStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=current superseded entered-in-error"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a document reference
+func (d *DocumentReference) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	if d.Status == "" {
+		d.Status = DocumentReferenceCurrent
+	}
+	return nil
+}
+
+// TableName returns the table name for the DocumentReference model
+func (DocumentReference) TableName() string {
+	return "document_references"
+}
+
+// DocumentStorageKey returns the storage key of a document's file
+func DocumentStorageKey(id string) string {
+	return "documents/" + id + "/content"
+}
@@ -0,0 +1,76 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Drug interaction severities, from least to most serious
+const (
+	InteractionMinor    = "minor"
+	InteractionModerate = "moderate"
+	InteractionSevere   = "severe" // Prescribing requires an override reason
+)
+
+// DrugInteraction is an entry of the local drug interaction knowledge base: two
+// medication codes that interact when given together. Each pair is stored once,
+// with CodeA sorting before CodeB.
+type DrugInteraction struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	CodeA       string    `json:"codeA" gorm:"uniqueIndex:idx_drug_interactions_pair"`
+	CodeB       string    `json:"codeB" gorm:"uniqueIndex:idx_drug_interactions_pair;index"`
+	Severity    string    `json:"severity"`
+	Description string    `json:"description"`
+	CreatedAt   time.Time `json:"createdAt"`
+	UpdatedAt   time.Time `json:"updatedAt"`
+	CreatedBy   string    `json:"createdBy"`
+}
+
+// DrugInteractionRequest represents a request to create or replace the interaction
+// between two medication codes
+type DrugInteractionRequest struct {
+	CodeA       string `json:"codeA" validate:"required"`
+	CodeB       string `json:"codeB" validate:"required,nefield=CodeA"`
+	Severity    string `json:"severity" validate:"required,oneof=minor moderate severe"`
+	Description string `json:"description" validate:"required"`
+}
+
+// InteractionWarning is an interaction between a prescribed medication and one the
+// patient already takes
+type InteractionWarning struct {
+	Severity    string `json:"severity"`
+	Description string `json:"description"`
+	Code        string `json:"code"`                                 // Code of the prescribed medication
+	Interacting string `json:"interacting"`                          // Code of the medication it interacts with
+	RequestID   string `json:"requestId,omitempty"`                  // Medication request of the interacting medication
+	Display     string `json:"display,omitempty" sanitize:"display"` // Name of the interacting medication
+}
+
+// InteractionRank orders severities so the most serious compares highest
+func InteractionRank(severity string) int {
+	switch severity {
+	case InteractionSevere:
+		return 3
+	case InteractionModerate:
+		return 2
+	case InteractionMinor:
+		return 1
+	default:
+		return 0
+	}
+}
+
+// BeforeCreate is a GORM hook that runs before creating a drug interaction
+func (d *DrugInteraction) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the DrugInteraction model
+func (DrugInteraction) TableName() string {
+	return "drug_interactions"
+}
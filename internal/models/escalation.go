@@ -0,0 +1,73 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Alert escalation statuses
+const (
+	EscalationSent      = "sent"
+	EscalationFailed    = "failed"
+	EscalationExhausted = "exhausted" // Every contact of the chain was notified
+)
+
+// OnCallChain lists who is notified, in order, while a critical alert of a
+// department stays unacknowledged. The first contact is notified when the alert is
+// raised and each following one after another AcknowledgeMinutes.
+type OnCallChain struct {
+	Department         string          `json:"department" gorm:"primaryKey"`
+	AcknowledgeMinutes int             `json:"acknowledgeMinutes"`
+	Contacts           []OnCallContact `json:"contacts" gorm:"type:jsonb;serializer:json"`
+	Active             bool            `json:"active"`
+	CreatedAt          time.Time       `json:"createdAt"`
+	UpdatedAt          time.Time       `json:"updatedAt"`
+}
+
+// OnCallContact is one step of an on-call chain, reached by email, SMS or both
+type OnCallContact struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email,omitempty" validate:"required_without=Phone,omitempty,email"`
+	Phone string `json:"phone,omitempty" validate:"required_without=Email,omitempty,e164"`
+}
+
+// OnCallChainRequest represents a request to create or replace an on-call chain
+type OnCallChainRequest struct {
+	AcknowledgeMinutes int             `json:"acknowledgeMinutes" validate:"required,min=1,max=1440"`
+	Contacts           []OnCallContact `json:"contacts" validate:"required,min=1,dive"`
+	Active             *bool           `json:"active,omitempty"`
+}
+
+// AlertEscalation records one notification sent while escalating an alert
+type AlertEscalation struct {
+	ID          string    `json:"id" gorm:"primaryKey"`
+	AlertID     string    `json:"alertId" gorm:"index"`
+	Department  string    `json:"department" gorm:"index:idx_alert_escalations_department"`
+	Level       int       `json:"level"` // Position of the contact in the chain, from 1
+	Contact     string    `json:"contact,omitempty"`
+	Channel     string    `json:"channel,omitempty"` // email or sms
+	Destination string    `json:"destination,omitempty"`
+	Status      string    `json:"status"`
+	Error       string    `json:"error,omitempty"`
+	CreatedAt   time.Time `json:"createdAt" gorm:"index:idx_alert_escalations_department"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating an alert escalation
+func (e *AlertEscalation) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the OnCallChain model
+func (OnCallChain) TableName() string {
+	return "on_call_chains"
+}
+
+// TableName returns the table name for the AlertEscalation model
+func (AlertEscalation) TableName() string {
+	return "alert_escalations"
+}
@@ -0,0 +1,130 @@
+package models
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Domain event actions
+const (
+	EventActionCreated = "created"
+	EventActionUpdated = "updated"
+	EventActionDeleted = "deleted"
+)
+
+// OutboxEvent records a change to a resource. Events are written in the same transaction
+// as the change itself, so consumers never see a change that was rolled back and never
+// miss one that was committed.
+type OutboxEvent struct {
+	Sequence     int64     `json:"sequence" gorm:"primaryKey;autoIncrement"`
+	ResourceType string    `json:"resourceType" gorm:"index:idx_outbox_events_resource"`
+	ResourceID   string    `json:"resourceId" gorm:"index:idx_outbox_events_resource"`
+	Action       string    `json:"action"`
+	Corrected    bool      `json:"corrected,omitempty"` // The change amends or retracts a released result
+	OccurredAt   time.Time `json:"occurredAt" gorm:"index"`
+}
+
+// EventCheckpoint tracks how far a named consumer has read the outbox
+type EventCheckpoint struct {
+	Consumer  string    `json:"consumer" gorm:"primaryKey"`
+	Position  int64     `json:"position"`
+	UpdatedAt time.Time `json:"updatedAt"`
+}
+
+// TableName returns the table name for the OutboxEvent model
+func (OutboxEvent) TableName() string {
+	return "outbox_events"
+}
+
+// TableName returns the table name for the EventCheckpoint model
+func (EventCheckpoint) TableName() string {
+	return "event_checkpoints"
+}
+
+// recordEvent appends an outbox event using the transaction of the calling hook.
+// Batch statements without a primary key on the model are not recorded.
+func recordEvent(tx *gorm.DB, resourceType, resourceID, action string) error {
+	if resourceID == "" {
+		return nil
+	}
+
+	return tx.Session(&gorm.Session{NewDB: true}).Create(&OutboxEvent{
+		ResourceType: resourceType,
+		ResourceID:   resourceID,
+		Action:       action,
+		OccurredAt:   time.Now().UTC(),
+	}).Error
+}
+
+// recordObservationEvent appends an observation event, flagged as a correction when
+// the observation amends or retracts a released result
+func recordObservationEvent(tx *gorm.DB, o *Observation, action string) error {
+	if o.ID == "" {
+		return nil
+	}
+
+	return tx.Session(&gorm.Session{NewDB: true}).Create(&OutboxEvent{
+		ResourceType: "Observation",
+		ResourceID:   o.ID,
+		Action:       action,
+		Corrected:    action != EventActionDeleted && o.IsCorrection(),
+		OccurredAt:   time.Now().UTC(),
+	}).Error
+}
+
+// RecordEvents appends one outbox event per resource for a change made by a batch
+// statement, which bypasses the model hooks
+func RecordEvents(tx *gorm.DB, resourceType string, resourceIDs []string, action string) error {
+	if len(resourceIDs) == 0 {
+		return nil
+	}
+
+	now := time.Now().UTC()
+	events := make([]OutboxEvent, len(resourceIDs))
+	for i, id := range resourceIDs {
+		events[i] = OutboxEvent{
+			ResourceType: resourceType,
+			ResourceID:   id,
+			Action:       action,
+			OccurredAt:   now,
+		}
+	}
+
+	return tx.Session(&gorm.Session{NewDB: true}).CreateInBatches(&events, 500).Error
+}
+
+// AfterCreate is a GORM hook that records a patient created event
+func (p *Patient) AfterCreate(tx *gorm.DB) error {
+	return recordEvent(tx, "Patient", p.ID, EventActionCreated)
+}
+
+// AfterUpdate is a GORM hook that records a patient updated event
+func (p *Patient) AfterUpdate(tx *gorm.DB) error {
+	return recordEvent(tx, "Patient", p.ID, EventActionUpdated)
+}
+
+// AfterDelete is a GORM hook that records a patient deleted event
+func (p *Patient) AfterDelete(tx *gorm.DB) error {
+	return recordEvent(tx, "Patient", p.ID, EventActionDeleted)
+}
+
+// AfterCreate is a GORM hook that records an observation created event
+func (o *Observation) AfterCreate(tx *gorm.DB) error {
+	return recordObservationEvent(tx, o, EventActionCreated)
+}
+
+// AfterUpdate is a GORM hook that records an observation updated event
+func (o *Observation) AfterUpdate(tx *gorm.DB) error {
+	return recordObservationEvent(tx, o, EventActionUpdated)
+}
+
+// AfterDelete is a GORM hook that records an observation deleted event
+func (o *Observation) AfterDelete(tx *gorm.DB) error {
+	return recordEvent(tx, "Observation", o.ID, EventActionDeleted)
+}
+
+// AfterCreate is a GORM hook that records a medication administration created event
+func (a *MedicationAdministration) AfterCreate(tx *gorm.DB) error {
+	return recordEvent(tx, "MedicationAdministration", a.ID, EventActionCreated)
+}
@@ -0,0 +1,45 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// EventReplay sends a range of outbox events again to one consumer, such as a search
+// index or integration that lost changes during an outage. The consumer's own
+// position is left alone; replayed events are handled again on top of it.
+type EventReplay struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	Consumer     string     `json:"consumer" gorm:"index"`
+	ResourceType string     `json:"resourceType,omitempty"` // Every resource type when empty
+	From         *time.Time `json:"from,omitempty"`         // Events that occurred at or after
+	To           *time.Time `json:"to,omitempty"`           // Events that occurred before
+	OperationID  string     `json:"operationId"`
+	Replayed     int64      `json:"replayed"`
+	Cursor       int64      `json:"-"` // Sequence of the last replayed event, so interrupted replays resume
+	CreatedBy    string     `json:"createdBy"`
+	CreatedAt    time.Time  `json:"createdAt"`
+}
+
+// EventReplayRequest represents a request to replay events to a consumer
+type EventReplayRequest struct {
+	Consumer     string     `json:"consumer" validate:"required"`
+	ResourceType string     `json:"resourceType,omitempty"`
+	From         *time.Time `json:"from,omitempty"`
+	To           *time.Time `json:"to,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating an event replay
+func (r *EventReplay) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the EventReplay model
+func (EventReplay) TableName() string {
+	return "event_replays"
+}
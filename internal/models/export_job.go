@@ -0,0 +1,103 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Export job statuses
+const (
+	ExportJobQueued    = "queued"
+	ExportJobRunning   = "running"
+	ExportJobCompleted = "completed"
+	ExportJobFailed    = "failed"
+	ExportJobCancelled = "cancelled"
+)
+
+// ExportJob is a background bulk export written to storage as NDJSON files with a
+// checksum manifest, optionally encrypted to a public key supplied with the request
+type ExportJob struct {
+	ID                   string             `json:"id" gorm:"primaryKey"`
+	ResourceType         string             `json:"resourceType"`
+	Deidentified         bool               `json:"deidentified"`
+	ActiveOnly           bool               `json:"activeOnly,omitempty"`
+	Filter               *ObservationFilter `json:"filter,omitempty" gorm:"type:jsonb;serializer:json"`
+	EncryptionFormat     string             `json:"encryptionFormat,omitempty"`
+	RecipientKey         string             `json:"-"`
+	RecipientFingerprint string             `json:"recipientFingerprint,omitempty"`
+	Status               string             `json:"status" gorm:"index"`
+	OperationID          string             `json:"operationId,omitempty"` // Operation running the job
+	Records              int64              `json:"records"`
+	Files                []ExportFile       `json:"files,omitempty" gorm:"type:jsonb;serializer:json"`
+	Error                string             `json:"error,omitempty"`
+	CreatedBy            string             `json:"createdBy"`
+	CreatedAt            time.Time          `json:"createdAt"`
+	StartedAt            *time.Time         `json:"startedAt,omitempty"`
+	CompletedAt          *time.Time         `json:"completedAt,omitempty"`
+}
+
+// ExportFile describes one file of an export. The checksum is taken over the stored
+// bytes, i.e. the ciphertext when the export is encrypted.
+type ExportFile struct {
+	Name    string `json:"name"`
+	Records int64  `json:"records"`
+	Bytes   int64  `json:"bytes"`
+	SHA256  string `json:"sha256"`
+}
+
+// ExportManifest is written alongside the export files
+type ExportManifest struct {
+	JobID        string             `json:"jobId,omitempty"`
+	ScheduleID   string             `json:"scheduleId,omitempty"`
+	RunID        string             `json:"runId,omitempty"`
+	ResourceType string             `json:"resourceType"`
+	Deidentified bool               `json:"deidentified"`
+	ActiveOnly   bool               `json:"activeOnly,omitempty"`
+	Filter       *ObservationFilter `json:"filter,omitempty"`
+	UpdatedAfter *time.Time         `json:"updatedAfter,omitempty"`
+	UpdatedUntil *time.Time         `json:"updatedUntil,omitempty"`
+	Format       string             `json:"format"`
+	Encryption   *ExportEncryption  `json:"encryption,omitempty"`
+	Records      int64              `json:"records"`
+	Files        []ExportFile       `json:"files"`
+	Corrected    []string           `json:"corrected,omitempty"` // Exported results corrected within the update window
+	CreatedBy    string             `json:"createdBy"`
+	GeneratedAt  time.Time          `json:"generatedAt"`
+}
+
+// ExportEncryption describes the payload encryption of an export. In a request the
+// public key is supplied; in a manifest only its fingerprint is recorded.
+type ExportEncryption struct {
+	Format      string `json:"format" validate:"required,oneof=age gpg"`
+	PublicKey   string `json:"publicKey,omitempty" validate:"required"`
+	Fingerprint string `json:"fingerprint,omitempty"`
+}
+
+// ExportJobRequest represents a request to start an export job
+type ExportJobRequest struct {
+	ResourceType string             `json:"resourceType" validate:"required,oneof=Patient Observation"`
+	Deidentified bool               `json:"deidentified"`
+	ActiveOnly   bool               `json:"activeOnly"`
+	Filter       *ObservationFilter `json:"filter,omitempty"`
+	Encryption   *ExportEncryption  `json:"encryption,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating an export job
+func (j *ExportJob) BeforeCreate(tx *gorm.DB) error {
+	if j.ID == "" {
+		j.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the ExportJob model
+func (ExportJob) TableName() string {
+	return "export_jobs"
+}
+
+// ExportStorageKey returns the storage key of a file of an export job
+func ExportStorageKey(jobID, name string) string {
+	return "exports/" + jobID + "/" + name
+}
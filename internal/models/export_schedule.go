@@ -0,0 +1,151 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Export schedule run statuses
+const (
+	ExportRunRunning   = "running"
+	ExportRunCompleted = "completed"
+	ExportRunFailed    = "failed"
+)
+
+// ExportSchedule is a recurring export pushed to an SFTP server or S3 bucket. Runs
+// are due at RunAt (UTC) every hour, day or week. Incremental schedules only export
+// records updated since the last successful run.
+type ExportSchedule struct {
+	ID                   string             `json:"id" gorm:"primaryKey"`
+	Name                 string             `json:"name" gorm:"uniqueIndex"`
+	ResourceType         string             `json:"resourceType"`
+	Format               string             `json:"format"`
+	Deidentified         bool               `json:"deidentified"`
+	ActiveOnly           bool               `json:"activeOnly,omitempty"`
+	Filter               *ObservationFilter `json:"filter,omitempty" gorm:"type:jsonb;serializer:json"`
+	Incremental          bool               `json:"incremental"`
+	Frequency            string             `json:"frequency"`
+	RunAt                string             `json:"runAt"`             // HH:MM; only the minute is used for hourly schedules
+	Weekday              int                `json:"weekday,omitempty"` // 0 = Sunday; weekly schedules only
+	Destination          ExportDestination  `json:"destination" gorm:"type:jsonb;serializer:json"`
+	Credential           string             `json:"-"` // Encrypted password, private key or secret access key
+	EncryptionFormat     string             `json:"encryptionFormat,omitempty"`
+	RecipientKey         string             `json:"-"`
+	RecipientFingerprint string             `json:"recipientFingerprint,omitempty"`
+	AlertEmails          []string           `json:"alertEmails,omitempty" gorm:"type:jsonb;serializer:json"`
+	Active               bool               `json:"active"`
+	NextRunAt            *time.Time         `json:"nextRunAt,omitempty" gorm:"index"`
+	LastRunAt            *time.Time         `json:"lastRunAt,omitempty"`
+	LastSuccessAt        *time.Time         `json:"lastSuccessAt,omitempty"`
+	Watermark            *time.Time         `json:"watermark,omitempty"` // Upper bound of the last exported update window
+	ConsecutiveFailures  int                `json:"consecutiveFailures"`
+	CreatedAt            time.Time          `json:"createdAt"`
+	UpdatedAt            time.Time          `json:"updatedAt"`
+	CreatedBy            string             `json:"createdBy"`
+}
+
+// ExportDestination describes where scheduled export files are pushed. Credentials
+// are kept on the schedule, encrypted, and never returned.
+type ExportDestination struct {
+	Type string `json:"type" validate:"required,oneof=sftp s3"`
+
+	// SFTP
+	Host     string `json:"host,omitempty" validate:"required_if=Type sftp"`
+	Port     int    `json:"port,omitempty" validate:"omitempty,min=1,max=65535"`
+	Username string `json:"username,omitempty" validate:"required_if=Type sftp"`
+	HostKey  string `json:"hostKey,omitempty" validate:"required_if=Type sftp"` // authorized_keys line or SHA256 fingerprint
+	Path     string `json:"path,omitempty"`
+
+	// S3
+	Bucket      string `json:"bucket,omitempty" validate:"required_if=Type s3"`
+	Region      string `json:"region,omitempty" validate:"required_if=Type s3"`
+	Endpoint    string `json:"endpoint,omitempty" validate:"omitempty,url"`
+	Prefix      string `json:"prefix,omitempty"`
+	AccessKeyID string `json:"accessKeyId,omitempty" validate:"required_if=Type s3"`
+}
+
+// ExportScheduleRequest represents a request to create or replace an export schedule.
+// The credential may be omitted on update to keep the stored one.
+type ExportScheduleRequest struct {
+	Name         string             `json:"name" validate:"required"`
+	ResourceType string             `json:"resourceType" validate:"required,oneof=Patient Observation"`
+	Format       string             `json:"format" validate:"required,oneof=ndjson csv"`
+	Deidentified bool               `json:"deidentified"`
+	ActiveOnly   bool               `json:"activeOnly"`
+	Filter       *ObservationFilter `json:"filter,omitempty"`
+	Incremental  bool               `json:"incremental"`
+	Frequency    string             `json:"frequency" validate:"required,oneof=hourly daily weekly"`
+	RunAt        string             `json:"runAt" validate:"required,datetime=15:04"`
+	Weekday      int                `json:"weekday" validate:"min=0,max=6"`
+	Destination  ExportDestination  `json:"destination"`
+	Credential   string             `json:"credential,omitempty"`
+	Encryption   *ExportEncryption  `json:"encryption,omitempty"`
+	AlertEmails  []string           `json:"alertEmails,omitempty" validate:"dive,email"`
+	Active       *bool              `json:"active,omitempty"`
+}
+
+// ExportScheduleRun records one run of an export schedule
+type ExportScheduleRun struct {
+	ID           string       `json:"id" gorm:"primaryKey"`
+	ScheduleID   string       `json:"scheduleId" gorm:"index"`
+	Status       string       `json:"status"`
+	UpdatedAfter *time.Time   `json:"updatedAfter,omitempty"`
+	UpdatedUntil *time.Time   `json:"updatedUntil,omitempty"`
+	Location     string       `json:"location,omitempty"` // Directory the files were pushed to
+	Records      int64        `json:"records"`
+	Files        []ExportFile `json:"files,omitempty" gorm:"type:jsonb;serializer:json"`
+	Error        string       `json:"error,omitempty"`
+	StartedAt    time.Time    `json:"startedAt"`
+	CompletedAt  *time.Time   `json:"completedAt,omitempty"`
+}
+
+// NextRun returns the first time after t at which the schedule is due
+func (s *ExportSchedule) NextRun(t time.Time) time.Time {
+	t = t.UTC()
+	at, err := time.Parse("15:04", s.RunAt)
+	if err != nil {
+		at = time.Time{}
+	}
+
+	if s.Frequency == "hourly" {
+		next := t.Truncate(time.Hour).Add(time.Duration(at.Minute()) * time.Minute)
+		if !next.After(t) {
+			next = next.Add(time.Hour)
+		}
+		return next
+	}
+
+	next := time.Date(t.Year(), t.Month(), t.Day(), at.Hour(), at.Minute(), 0, 0, time.UTC)
+	for !next.After(t) || (s.Frequency == "weekly" && int(next.Weekday()) != s.Weekday) {
+		next = next.AddDate(0, 0, 1)
+	}
+	return next
+}
+
+// BeforeCreate is a GORM hook that runs before creating an export schedule
+func (s *ExportSchedule) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating an export schedule run
+func (r *ExportScheduleRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the ExportSchedule model
+func (ExportSchedule) TableName() string {
+	return "export_schedules"
+}
+
+// TableName returns the table name for the ExportScheduleRun model
+func (ExportScheduleRun) TableName() string {
+	return "export_schedule_runs"
+}
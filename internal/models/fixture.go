@@ -0,0 +1,30 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// FixtureEntity records a resource created for a tenant's contract tests, so a reset
+// can remove it. Its ID is derived from the tenant, resource type and key.
+type FixtureEntity struct {
+	Tenant       string    `json:"tenant" gorm:"primaryKey"`
+	ResourceType string    `json:"resourceType" gorm:"primaryKey"`
+	Key          string    `json:"key" gorm:"primaryKey;column:entity_key"`
+	ResourceID   string    `json:"resourceId" gorm:"index"`
+	Dataset      bool      `json:"dataset"` // Part of the dataset a reset loads, rather than created on request
+	CreatedAt    time.Time `json:"createdAt"`
+	CreatedBy    string    `json:"createdBy"`
+}
+
+// FixtureEntityRequest represents a request to create a deterministic test entity
+type FixtureEntityRequest struct {
+	ResourceType string          `json:"resourceType" validate:"required,oneof=Patient Observation Condition AllergyIntolerance Specimen"`
+	Key          string          `json:"key" validate:"required,max=100"`
+	Resource     json.RawMessage `json:"resource" validate:"required"`
+}
+
+// TableName returns the table name for the FixtureEntity model
+func (FixtureEntity) TableName() string {
+	return "fixture_entities"
+}
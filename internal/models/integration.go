@@ -0,0 +1,105 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// IntegrationCredential authenticates an inbound integration, such as a lab
+// interface posting over HTTPS or a device pushing results, by HMAC-signed requests.
+// The ID is the key ID sent with each request; the secret is stored encrypted.
+type IntegrationCredential struct {
+	ID               string     `json:"id" gorm:"primaryKey"`
+	Name             string     `json:"name" gorm:"uniqueIndex"`
+	Secret           string     `json:"-"`
+	UserID           string     `json:"userId" gorm:"index"` // Service account the integration's requests act as
+	ToleranceSeconds int        `json:"toleranceSeconds"`    // Allowed clock skew of a request's timestamp
+	LastUsedAt       *time.Time `json:"lastUsedAt,omitempty"`
+	CreatedAt        time.Time  `json:"createdAt"`
+	CreatedBy        string     `json:"createdBy"`
+	RevokedAt        *time.Time `json:"revokedAt,omitempty"`
+	RevokedBy        string     `json:"revokedBy,omitempty"`
+}
+
+// IntegrationCredentialRequest represents a request to create an integration credential
+type IntegrationCredentialRequest struct {
+	Name             string `json:"name" validate:"required,max=100"`
+	UserID           string `json:"userId" validate:"required"`
+	ToleranceSeconds int    `json:"toleranceSeconds,omitempty" validate:"min=0,max=900"`
+}
+
+// IntegrationNonce is a nonce a credential has signed a request with. It is kept
+// while the request's timestamp is within tolerance, so the request cannot be
+// replayed.
+type IntegrationNonce struct {
+	CredentialID string    `gorm:"primaryKey"`
+	Nonce        string    `gorm:"primaryKey"`
+	ExpiresAt    time.Time `gorm:"index"`
+}
+
+// Revoked reports whether the credential no longer authenticates requests
+func (c *IntegrationCredential) Revoked() bool {
+	return c.RevokedAt != nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating an integration credential
+func (c *IntegrationCredential) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the IntegrationCredential model
+func (IntegrationCredential) TableName() string {
+	return "integration_credentials"
+}
+
+// TableName returns the table name for the IntegrationNonce model
+func (IntegrationNonce) TableName() string {
+	return "integration_nonces"
+}
+
+// Integration mapping kinds
+const (
+	MappingCodeSystem = "code_system" // A coding system the integration sends → the code system used internally
+	MappingFacility   = "facility"    // A sending facility → the tenant its messages belong to
+)
+
+// IntegrationMapping translates a value an integration sends into the one used
+// internally, e.g. a lab's local OBX-3 coding system into LOINC, or its sending
+// facility into a tenant
+type IntegrationMapping struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	CredentialID string    `json:"credentialId" gorm:"uniqueIndex:idx_integration_mappings_source"`
+	Kind         string    `json:"kind" gorm:"uniqueIndex:idx_integration_mappings_source"`
+	Source       string    `json:"source" gorm:"uniqueIndex:idx_integration_mappings_source"`
+	Target       string    `json:"target"`
+	Description  string    `json:"description,omitempty"`
+	CreatedAt    time.Time `json:"createdAt"`
+	UpdatedAt    time.Time `json:"updatedAt"`
+	CreatedBy    string    `json:"createdBy"`
+}
+
+// IntegrationMappingRequest represents a request to create or replace an integration mapping
+type IntegrationMappingRequest struct {
+	Kind        string `json:"kind" validate:"required,oneof=code_system facility"`
+	Source      string `json:"source" validate:"required,max=200"`
+	Target      string `json:"target" validate:"required,max=200"`
+	Description string `json:"description,omitempty" validate:"max=500"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating an integration mapping
+func (m *IntegrationMapping) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the IntegrationMapping model
+func (IntegrationMapping) TableName() string {
+	return "integration_mappings"
+}
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Integrity run statuses
+const (
+	IntegrityRunQueued    = "queued"
+	IntegrityRunRunning   = "running"
+	IntegrityRunCompleted = "completed"
+	IntegrityRunFailed    = "failed"
+)
+
+// Reference integrity problems
+const (
+	IntegrityMissing  = "missing"  // The referenced patient does not exist
+	IntegrityDeleted  = "deleted"  // The referenced patient is pending deletion
+	IntegrityReplaced = "replaced" // The referenced patient was merged into another record
+)
+
+// IntegrityRun is a check of the patient references of clinical records. References
+// to a merged patient are re-pointed to the surviving record when the run repoints
+// and the record can safely be changed; the other problems are reported.
+type IntegrityRun struct {
+	ID          string           `json:"id" gorm:"primaryKey"`
+	Status      string           `json:"status" gorm:"index"`
+	Repoint     bool             `json:"repoint"`
+	Scheduled   bool             `json:"scheduled"`
+	Checked     int64            `json:"checked"`  // Records whose references were checked
+	Dangling    int64            `json:"dangling"` // Records with a problem, including re-pointed ones
+	Repointed   int64            `json:"repointed"`
+	Summary     map[string]int64 `json:"summary,omitempty" gorm:"type:jsonb;serializer:json"` // Problems by "resourceType/problem"
+	Truncated   bool             `json:"truncated,omitempty"`                                 // Findings beyond the limit were counted but not kept
+	Error       string           `json:"error,omitempty"`
+	CreatedBy   string           `json:"createdBy,omitempty"`
+	CreatedAt   time.Time        `json:"createdAt" gorm:"index"`
+	StartedAt   *time.Time       `json:"startedAt,omitempty"`
+	CompletedAt *time.Time       `json:"completedAt,omitempty"`
+}
+
+// IntegrityFinding is a record found with a patient reference problem
+type IntegrityFinding struct {
+	ID           string `json:"id" gorm:"primaryKey"`
+	RunID        string `json:"runId" gorm:"index"`
+	ResourceType string `json:"resourceType" gorm:"index"`
+	ResourceID   string `json:"resourceId"`
+	Reference    string `json:"reference"`
+	Problem      string `json:"problem" gorm:"index"`
+	RepointedTo  string `json:"repointedTo,omitempty"`
+	Reason       string `json:"reason,omitempty"` // Why a reference to a merged patient was not re-pointed
+}
+
+// IntegrityRunRequest represents a request to start an integrity run
+type IntegrityRunRequest struct {
+	Repoint *bool `json:"repoint,omitempty"` // Defaults to INTEGRITY_AUTO_REPOINT
+}
+
+// BeforeCreate is a GORM hook that runs before creating an integrity run
+func (r *IntegrityRun) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating an integrity finding
+func (f *IntegrityFinding) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the IntegrityRun model
+func (IntegrityRun) TableName() string {
+	return "integrity_runs"
+}
+
+// TableName returns the table name for the IntegrityFinding model
+func (IntegrityFinding) TableName() string {
+	return "integrity_findings"
+}
@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// LegalHold marks a patient, observation or other record of a patient as subject to
+// litigation or an investigation. A held resource is kept by the retention purge,
+// even once deleted, until every hold on it is released. A hold on a patient also
+// keeps its observations, links and other records.
+type LegalHold struct {
+	ID            string     `json:"id" gorm:"primaryKey"`
+	ResourceType  string     `json:"resourceType" gorm:"index:idx_legal_hold_resource"`
+	ResourceID    string     `json:"resourceId" gorm:"index:idx_legal_hold_resource"`
+	Reason        string     `json:"reason"` // e.g. the matter the hold was placed for
+	PlacedBy      string     `json:"placedBy"`
+	PlacedAt      time.Time  `json:"placedAt"`
+	ReleasedBy    string     `json:"releasedBy,omitempty"`
+	ReleasedAt    *time.Time `json:"releasedAt,omitempty" gorm:"index"`
+	ReleaseReason string     `json:"releaseReason,omitempty"`
+	Active        bool       `json:"active" gorm:"-"`
+	UpdatedAt     time.Time  `json:"updatedAt"`
+}
+
+// LegalHoldRequest represents a request to place a legal hold
+type LegalHoldRequest struct {
+	ResourceType string `json:"resourceType" validate:"required,oneof=Patient Observation Condition AllergyIntolerance Procedure Coverage Media DocumentReference RelatedPerson"`
+	ResourceID   string `json:"resourceId" validate:"required"`
+	Reason       string `json:"reason" validate:"required,max=500"`
+}
+
+// LegalHoldReleaseRequest represents a request to release a legal hold
+type LegalHoldReleaseRequest struct {
+	Reason string `json:"reason" validate:"required,max=500"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a legal hold
+func (h *LegalHold) BeforeCreate(tx *gorm.DB) error {
+	if h.ID == "" {
+		h.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// AfterFind is a GORM hook that reports whether a loaded hold is still in force
+func (h *LegalHold) AfterFind(tx *gorm.DB) error {
+	h.Active = h.ReleasedAt == nil
+	return nil
+}
+
+// TableName returns the table name for the LegalHold model
+func (LegalHold) TableName() string {
+	return "legal_holds"
+}
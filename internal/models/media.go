@@ -0,0 +1,59 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Media represents a FHIR-inspired Media resource (photos, ECG strips, scans)
+type Media struct {
+	ID            string           `json:"id" gorm:"primaryKey"`
+	Status        string           `json:"status" validate:"oneof=preparation in-progress not-done on-hold stopped completed entered-in-error unknown"`
+	Type          *CodeableConcept `json:"type,omitempty" gorm:"embedded;embeddedPrefix:type_"`
+	Subject       Reference        `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	PartOf        Reference        `json:"partOf" gorm:"embedded;embeddedPrefix:part_of_"`
+	ObservationID string           `json:"-" gorm:"index"`
+	Content       Attachment       `json:"content" gorm:"embedded;embeddedPrefix:content_"`
+	Width         int              `json:"width,omitempty"`
+	Height        int              `json:"height,omitempty"`
+	StorageKey    string           `json:"-"`
+	ThumbnailKey  string           `json:"-"`
+	ThumbnailURL  string           `json:"thumbnailUrl,omitempty" gorm:"-"`
+	CreatedAt     time.Time        `json:"createdAt"`
+	UpdatedAt     time.Time        `json:"updatedAt"`
+	CreatedBy     string           `json:"createdBy"`
+	DeletedAt     gorm.DeletedAt   `json:"-" gorm:"index"` // Set while a delete can still be undone
+	DeletedBy     string           `json:"-"`
+}
+
+// AllowedMediaContentTypes lists the content types accepted for media uploads
+var AllowedMediaContentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"application/pdf": true,
+}
+
+// BeforeCreate is a GORM hook that runs before creating a media record
+func (m *Media) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the Media model
+func (Media) TableName() string {
+	return "media"
+}
+
+// IsImage reports whether the media content is an image that can be thumbnailed
+func (m *Media) IsImage() bool {
+	switch m.Content.ContentType {
+	case "image/jpeg", "image/png", "image/gif":
+		return true
+	}
+	return false
+}
@@ -0,0 +1,148 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Medication request statuses
+const (
+	MedicationRequestActive    = "active"
+	MedicationRequestOnHold    = "on-hold"
+	MedicationRequestStopped   = "stopped"
+	MedicationRequestCompleted = "completed"
+)
+
+// Medication administration statuses
+const (
+	AdministrationCompleted      = "completed"
+	AdministrationNotDone        = "not-done" // e.g. refused or withheld; StatusReason says why
+	AdministrationEnteredInError = "entered-in-error"
+)
+
+// MedicationRequest represents a FHIR-inspired MedicationRequest resource: a
+// prescription whose doses are due at the times of its schedule. Requests without
+// times are given as needed and never fall due.
+type MedicationRequest struct {
+	ID                  string            `json:"id" gorm:"primaryKey"`
+	Status              string            `json:"status" gorm:"index"`
+	Subject             Reference         `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Medication          CodeableConcept   `json:"medication" gorm:"type:jsonb;serializer:json"`            // Codings include the barcode on the package
+	Ingredients         []CodeableConcept `json:"ingredients,omitempty" gorm:"type:jsonb;serializer:json"` // Active ingredients, checked against allergies
+	Dose                Quantity          `json:"dose" gorm:"embedded;embeddedPrefix:dose_"`
+	Route               *CodeableConcept  `json:"route,omitempty" gorm:"type:jsonb;serializer:json"`
+	DailySchedule       `gorm:"embedded"`
+	Ward                string               `json:"ward,omitempty" gorm:"index"`
+	Prescriber          string               `json:"prescriber,omitempty"`                                        // User ID of the prescribing practitioner
+	ReasonReference     []Reference          `json:"reasonReference,omitempty" gorm:"type:jsonb;serializer:json"` // Conditions the medication treats
+	Note                string               `json:"note,omitempty" sanitize:"note"`
+	InteractionWarnings []InteractionWarning `json:"interactionWarnings,omitempty" gorm:"type:jsonb;serializer:json"` // Found when prescribed
+	InteractionOverride string               `json:"interactionOverride,omitempty"`                                   // Why the prescriber accepted a severe interaction
+	AllergyWarnings     []AllergyWarning     `json:"allergyWarnings,omitempty" gorm:"type:jsonb;serializer:json"`     // Found when prescribed
+	AllergyOverride     string               `json:"allergyOverride,omitempty"`                                       // Why the prescriber accepted a recorded allergy
+	CreatedAt           time.Time            `json:"createdAt"`
+	UpdatedAt           time.Time            `json:"updatedAt"`
+	CreatedBy           string               `json:"createdBy"`
+}
+
+// MedicationRequestRequest represents a request to prescribe a medication
+type MedicationRequestRequest struct {
+	Subject             Reference         `json:"subject"`
+	Medication          CodeableConcept   `json:"medication"`
+	Ingredients         []CodeableConcept `json:"ingredients,omitempty" validate:"max=50"`
+	Dose                Quantity          `json:"dose"`
+	Route               *CodeableConcept  `json:"route,omitempty"`
+	Times               []string          `json:"times,omitempty" validate:"max=24,dive,datetime=15:04"`
+	Timezone            string            `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	StartsAt            *time.Time        `json:"startsAt,omitempty"`
+	EndsAt              *time.Time        `json:"endsAt,omitempty"`
+	Ward                string            `json:"ward,omitempty"`
+	Note                string            `json:"note,omitempty"`
+	ReasonReference     []Reference       `json:"reasonReference,omitempty" validate:"max=20"`
+	InteractionOverride string            `json:"interactionOverride,omitempty" validate:"max=500"` // Required to prescribe despite a severe interaction
+	AllergyOverride     string            `json:"allergyOverride,omitempty" validate:"max=500"`     // Required to prescribe despite an allergy when allergy checks block
+}
+
+// MedicationRequestStatusRequest represents a request to change the status of a
+// medication request
+type MedicationRequestStatusRequest struct {
+	Status string `json:"status" validate:"required,oneof=active on-hold stopped completed"`
+}
+
+// MedicationAdministration represents a FHIR-inspired MedicationAdministration
+// resource: one dose given to, or withheld from, a patient
+type MedicationAdministration struct {
+	ID              string           `json:"id" gorm:"primaryKey"`
+	RequestID       string           `json:"requestId" gorm:"index:idx_medication_administrations_dose"`
+	Status          string           `json:"status"`
+	StatusReason    string           `json:"statusReason,omitempty"`
+	Subject         Reference        `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Medication      CodeableConcept  `json:"medication" gorm:"type:jsonb;serializer:json"`
+	Dose            Quantity         `json:"dose" gorm:"embedded;embeddedPrefix:dose_"`
+	Route           *CodeableConcept `json:"route,omitempty" gorm:"type:jsonb;serializer:json"`
+	ScheduledAt     *time.Time       `json:"scheduledAt,omitempty" gorm:"index:idx_medication_administrations_dose"` // The due dose this is; empty for as-needed doses
+	EffectiveAt     time.Time        `json:"effectiveAt" gorm:"index"`
+	Performer       string           `json:"performer" gorm:"index"` // User ID of the administering nurse
+	BarcodeVerified bool             `json:"barcodeVerified"`
+	Note            string           `json:"note,omitempty" sanitize:"note"`
+	CreatedAt       time.Time        `json:"createdAt"`
+}
+
+// MedicationAdministrationRequest represents a request to record a dose. The
+// patient's wristband and the medication package are scanned to verify the dose.
+type MedicationAdministrationRequest struct {
+	RequestID         string     `json:"requestId" validate:"required"`
+	Status            string     `json:"status,omitempty" validate:"omitempty,oneof=completed not-done"`
+	StatusReason      string     `json:"statusReason,omitempty" validate:"required_if=Status not-done"`
+	ScheduledAt       *time.Time `json:"scheduledAt,omitempty"`
+	EffectiveAt       *time.Time `json:"effectiveAt,omitempty"`
+	Dose              *Quantity  `json:"dose,omitempty"`
+	PatientBarcode    string     `json:"patientBarcode" validate:"required"`
+	MedicationBarcode string     `json:"medicationBarcode,omitempty" validate:"required_unless=Status not-done"`
+	Note              string     `json:"note,omitempty"`
+}
+
+// MatchesBarcode reports whether a scanned medication barcode is one of the
+// medication's codes
+func (r *MedicationRequest) MatchesBarcode(barcode string) bool {
+	for _, coding := range r.Medication.Coding {
+		if coding.Code != "" && coding.Code == barcode {
+			return true
+		}
+	}
+	return false
+}
+
+// BeforeCreate is a GORM hook that runs before creating a medication request
+func (r *MedicationRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	if r.Status == "" {
+		r.Status = MedicationRequestActive
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating a medication administration
+func (a *MedicationAdministration) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	if a.Status == "" {
+		a.Status = AdministrationCompleted
+	}
+	return nil
+}
+
+// TableName returns the table name for the MedicationRequest model
+func (MedicationRequest) TableName() string {
+	return "medication_requests"
+}
+
+// TableName returns the table name for the MedicationAdministration model
+func (MedicationAdministration) TableName() string {
+	return "medication_administrations"
+}
@@ -12,12 +12,13 @@ import (
 type Observation struct {
 	ID                string            `json:"id" gorm:"primaryKey"`
 	Status            string            `json:"status" validate:"oneof=registered preliminary final amended corrected cancelled entered-in-error unknown"`
-	Category          []Category        `json:"category" gorm:"serializer:json"`
-	Code              CodeableConcept   `json:"code" gorm:"embedded"`
-	Subject           Reference         `json:"subject" gorm:"embedded"`
+	Category          []Category        `json:"category" gorm:"type:jsonb;serializer:json"`
+	Code              CodeableConcept   `json:"code" gorm:"type:jsonb;serializer:json"`
+	Subject           Reference         `json:"subject" gorm:"type:jsonb;serializer:json"`
 	Encounter         *Reference        `json:"encounter,omitempty" gorm:"embedded;embeddedPrefix:encounter_"`
 	EffectiveDateTime time.Time         `json:"effectiveDateTime"`
-	Issued            *time.Time        `json:"issued,omitempty"`
+	OrderedAt         *time.Time        `json:"orderedAt,omitempty"` // When the test was ordered, for turnaround times
+	Issued            *time.Time        `json:"issued,omitempty"`    // When the result was verified and released
 	Performer         []Reference       `json:"performer,omitempty" gorm:"serializer:json"`
 	ValueQuantity     *Quantity         `json:"valueQuantity,omitempty" gorm:"embedded;embeddedPrefix:value_quantity_"`
 	ValueCodeable     *CodeableConcept  `json:"valueCodeableConcept,omitempty" gorm:"embedded;embeddedPrefix:value_codeable_"`
@@ -29,6 +30,7 @@ type Observation struct {
 	ValueTime         *time.Time        `json:"valueTime,omitempty"`
 	ValueDateTime     *time.Time        `json:"valueDateTime,omitempty"`
 	ValuePeriod       *Period           `json:"valuePeriod,omitempty" gorm:"embedded;embeddedPrefix:value_period_"`
+	ValueAttachment   *Attachment       `json:"valueAttachment,omitempty" gorm:"embedded;embeddedPrefix:value_attachment_"`
 	DataAbsentReason  *CodeableConcept  `json:"dataAbsentReason,omitempty" gorm:"embedded;embeddedPrefix:absent_reason_"`
 	Interpretation    []CodeableConcept `json:"interpretation,omitempty" gorm:"serializer:json"`
 	Note              []Annotation      `json:"note,omitempty" gorm:"serializer:json"`
@@ -37,22 +39,26 @@ type Observation struct {
 	Specimen          *Reference        `json:"specimen,omitempty" gorm:"embedded;embeddedPrefix:specimen_"`
 	Device            *Reference        `json:"device,omitempty" gorm:"embedded;embeddedPrefix:device_"`
 	ReferenceRange    []ReferenceRange  `json:"referenceRange,omitempty" gorm:"serializer:json"`
+	DerivedFrom       []Reference       `json:"derivedFrom,omitempty" gorm:"serializer:json"`
 	Component         []Component       `json:"component,omitempty" gorm:"serializer:json"`
+	ReasonReference   []Reference       `json:"reasonReference,omitempty" gorm:"type:jsonb;serializer:json"` // Conditions the observation addresses
 	CreatedAt         time.Time         `json:"createdAt"`
 	UpdatedAt         time.Time         `json:"updatedAt"`
 	CreatedBy         string            `json:"createdBy"`
+	DeletedAt         gorm.DeletedAt    `json:"-" gorm:"index"` // Set while a delete can still be undone
+	DeletedBy         string            `json:"-"`
 }
 
 // Category represents an observation category
 type Category struct {
 	Coding []Coding `json:"coding" validate:"required,min=1"`
-	Text   string   `json:"text,omitempty"`
+	Text   string   `json:"text,omitempty" sanitize:"display"`
 }
 
 // CodeableConcept represents a concept that may be coded
 type CodeableConcept struct {
 	Coding []Coding `json:"coding,omitempty" gorm:"serializer:json"`
-	Text   string   `json:"text,omitempty"`
+	Text   string   `json:"text,omitempty" sanitize:"display"`
 }
 
 // Coding represents a code from a coding system
@@ -60,7 +66,7 @@ type Coding struct {
 	System       string `json:"system,omitempty"`
 	Version      string `json:"version,omitempty"`
 	Code         string `json:"code,omitempty"`
-	Display      string `json:"display,omitempty"`
+	Display      string `json:"display,omitempty" sanitize:"display"`
 	UserSelected *bool  `json:"userSelected,omitempty"`
 }
 
@@ -69,7 +75,7 @@ type Reference struct {
 	Reference  string      `json:"reference,omitempty"`
 	Type       string      `json:"type,omitempty"`
 	Identifier *Identifier `json:"identifier,omitempty" gorm:"embedded;embeddedPrefix:identifier_"`
-	Display    string      `json:"display,omitempty"`
+	Display    string      `json:"display,omitempty" sanitize:"display"`
 }
 
 // Identifier represents an identifier for a resource
@@ -79,7 +85,7 @@ type Identifier struct {
 	System   string           `json:"system,omitempty"`
 	Value    string           `json:"value,omitempty"`
 	Period   *Period          `json:"period,omitempty" gorm:"embedded;embeddedPrefix:period_"`
-	Assigner *Reference       `json:"assigner,omitempty" gorm:"embedded;embeddedPrefix:assigner_"`
+	Assigner *Reference       `json:"assigner,omitempty" gorm:"serializer:json"` // Stored as JSON; embedding would recurse through Reference.Identifier
 }
 
 // Quantity represents a measured amount
@@ -91,6 +97,11 @@ type Quantity struct {
 	Code       string  `json:"code,omitempty"`
 }
 
+// QuantityUnitExpression is the SQL expression of the unit a value quantity is
+// searched by: its coded unit, or the human-readable unit when no code is recorded.
+// The value quantity index is built over the same expression.
+const QuantityUnitExpression = "COALESCE(NULLIF(value_quantity_code, ''), value_quantity_unit)"
+
 // Range represents a range of values
 type Range struct {
 	Low  *Quantity `json:"low,omitempty" gorm:"embedded;embeddedPrefix:low_"`
@@ -103,12 +114,23 @@ type Ratio struct {
 	Denominator *Quantity `json:"denominator,omitempty" gorm:"embedded;embeddedPrefix:denominator_"`
 }
 
+// Attachment represents content stored outside the resource, such as an image or document
+type Attachment struct {
+	ContentType string     `json:"contentType,omitempty"`
+	Language    string     `json:"language,omitempty"`
+	URL         string     `json:"url,omitempty"`
+	Size        int64      `json:"size,omitempty"`
+	Hash        string     `json:"hash,omitempty"` // Base64 encoded SHA-256 of the content
+	Title       string     `json:"title,omitempty"`
+	Creation    *time.Time `json:"creation,omitempty"`
+}
+
 // Annotation represents a text note
 type Annotation struct {
 	AuthorReference *Reference `json:"authorReference,omitempty"`
 	AuthorString    string     `json:"authorString,omitempty"`
 	Time            *time.Time `json:"time,omitempty"`
-	Text            string     `json:"text" validate:"required"`
+	Text            string     `json:"text" validate:"required" sanitize:"annotation"`
 }
 
 // ReferenceRange represents the reference range for an observation
@@ -152,6 +174,18 @@ func (Observation) TableName() string {
 	return "observations"
 }
 
+// IsCorrection reports whether the observation amends or corrects a result, or
+// retracts one that was released
+func (o *Observation) IsCorrection() bool {
+	switch o.Status {
+	case "amended", "corrected":
+		return true
+	case "entered-in-error":
+		return o.Issued != nil
+	}
+	return false
+}
+
 // IsAbnormal checks if the observation result is abnormal
 func (o *Observation) IsAbnormal() bool {
 	for _, interp := range o.Interpretation {
@@ -165,6 +199,32 @@ func (o *Observation) IsAbnormal() bool {
 	return false
 }
 
+// IsCritical checks if the observation result is flagged critically abnormal
+func (o *Observation) IsCritical() bool {
+	for _, interp := range o.Interpretation {
+		for _, coding := range interp.Coding {
+			switch coding.Code {
+			case "AA", "HH", "LL":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// Department returns the department responsible for the observation: the code of
+// its managed category
+func (o *Observation) Department() string {
+	for _, category := range o.Category {
+		for _, coding := range category.Coding {
+			if coding.System == ObservationCategorySystem {
+				return coding.Code
+			}
+		}
+	}
+	return ""
+}
+
 // GetDisplayValue returns a human-readable display of the observation value
 func (o *Observation) GetDisplayValue() string {
 	if o.ValueQuantity != nil {
@@ -218,3 +278,35 @@ func (o *Observation) GetCodeDisplay() string {
 
 	return "Unknown"
 }
+
+// Codings returns the codings of the observation's code, categories, coded value and
+// interpretations, those of its components included. They share the observation's
+// storage, so displays replaced in them are served with it.
+func (o *Observation) Codings() [][]Coding {
+	codings := [][]Coding{o.Code.Coding}
+	for i := range o.Category {
+		codings = append(codings, o.Category[i].Coding)
+	}
+	codings = appendConcepts(codings, o.ValueCodeable, o.DataAbsentReason, o.BodySite, o.Method)
+	for i := range o.Interpretation {
+		codings = append(codings, o.Interpretation[i].Coding)
+	}
+	for i := range o.Component {
+		component := &o.Component[i]
+		codings = appendConcepts(codings, &component.Code, component.ValueCodeable, component.DataAbsentReason)
+		for j := range component.Interpretation {
+			codings = append(codings, component.Interpretation[j].Coding)
+		}
+	}
+	return codings
+}
+
+// appendConcepts appends the codings of the concepts that are set
+func appendConcepts(codings [][]Coding, concepts ...*CodeableConcept) [][]Coding {
+	for _, concept := range concepts {
+		if concept != nil {
+			codings = append(codings, concept.Coding)
+		}
+	}
+	return codings
+}
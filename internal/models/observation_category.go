@@ -0,0 +1,37 @@
+package models
+
+import "time"
+
+// ObservationCategorySystem is the code system for managed observation categories
+const ObservationCategorySystem = "http://terminology.hl7.org/CodeSystem/observation-category"
+
+// ObservationCategory is a managed entry in the observation category code system
+type ObservationCategory struct {
+	Code       string    `json:"code" gorm:"primaryKey" validate:"required,max=64"`
+	Display    string    `json:"display" validate:"required" sanitize:"display"`
+	Definition string    `json:"definition,omitempty"`
+	Active     bool      `json:"active" gorm:"default:true"`
+	CreatedAt  time.Time `json:"createdAt"`
+	UpdatedAt  time.Time `json:"updatedAt"`
+}
+
+// ObservationCategoryUpdateRequest represents a request to update a managed category
+type ObservationCategoryUpdateRequest struct {
+	Display    string `json:"display" validate:"required"`
+	Definition string `json:"definition,omitempty"`
+	Active     *bool  `json:"active,omitempty"`
+}
+
+// TableName returns the table name for the ObservationCategory model
+func (ObservationCategory) TableName() string {
+	return "observation_categories"
+}
+
+// Coding returns the category as a coding in the managed system
+func (c *ObservationCategory) Coding() Coding {
+	return Coding{
+		System:  ObservationCategorySystem,
+		Code:    c.Code,
+		Display: c.Display,
+	}
+}
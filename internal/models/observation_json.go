@@ -0,0 +1,225 @@
+package models
+
+import (
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/jsonenc"
+)
+
+// Hand-written JSON encoders of the observation types. They write the same JSON as
+// encoding/json does from the struct tags, without reflection, for list responses
+// large enough for encoding to matter; a field added to one of these types must be
+// added to its encoder too.
+
+// AppendJSON appends the observation as JSON
+func (o *Observation) AppendJSON(b []byte) []byte {
+	obj := jsonenc.Begin(b)
+	obj.String("id", o.ID)
+	obj.String("status", o.Status)
+	obj.Set(jsonenc.AppendArray(obj.Key("category"), o.Category, appendCategory))
+	obj.Set(appendCodeableConcept(obj.Key("code"), &o.Code))
+	obj.Set(appendReference(obj.Key("subject"), &o.Subject))
+	optReference(&obj, "encounter", o.Encounter)
+	obj.Time("effectiveDateTime", o.EffectiveDateTime)
+	obj.OptTime("orderedAt", o.OrderedAt)
+	obj.OptTime("issued", o.Issued)
+	optArray(&obj, "performer", o.Performer, appendReference)
+	appendValueX(&obj, o.ValueQuantity, o.ValueCodeable, o.ValueString, o.ValueBoolean, o.ValueInteger,
+		o.ValueRange, o.ValueRatio, o.ValueTime, o.ValueDateTime, o.ValuePeriod)
+	if o.ValueAttachment != nil {
+		obj.Set(appendAttachment(obj.Key("valueAttachment"), o.ValueAttachment))
+	}
+	optCodeableConcept(&obj, "dataAbsentReason", o.DataAbsentReason)
+	optArray(&obj, "interpretation", o.Interpretation, appendCodeableConcept)
+	optArray(&obj, "note", o.Note, appendAnnotation)
+	optCodeableConcept(&obj, "bodySite", o.BodySite)
+	optCodeableConcept(&obj, "method", o.Method)
+	optReference(&obj, "specimen", o.Specimen)
+	optReference(&obj, "device", o.Device)
+	optArray(&obj, "referenceRange", o.ReferenceRange, appendReferenceRange)
+	optArray(&obj, "derivedFrom", o.DerivedFrom, appendReference)
+	optArray(&obj, "component", o.Component, appendComponent)
+	optArray(&obj, "reasonReference", o.ReasonReference, appendReference)
+	obj.Time("createdAt", o.CreatedAt)
+	obj.Time("updatedAt", o.UpdatedAt)
+	obj.String("createdBy", o.CreatedBy)
+	return obj.End()
+}
+
+// appendValueX appends the value[x] fields an observation and its components share
+func appendValueX(obj *jsonenc.Object, quantity *Quantity, codeable *CodeableConcept, str string, boolean *bool,
+	integer *int, valueRange *Range, ratio *Ratio, valueTime, dateTime *time.Time, period *Period) {
+	optQuantity(obj, "valueQuantity", quantity)
+	optCodeableConcept(obj, "valueCodeableConcept", codeable)
+	obj.OptString("valueString", str)
+	if boolean != nil {
+		obj.Bool("valueBoolean", *boolean)
+	}
+	if integer != nil {
+		obj.Int("valueInteger", int64(*integer))
+	}
+	if valueRange != nil {
+		obj.Set(appendRange(obj.Key("valueRange"), valueRange))
+	}
+	if ratio != nil {
+		obj.Set(appendRatio(obj.Key("valueRatio"), ratio))
+	}
+	obj.OptTime("valueTime", valueTime)
+	obj.OptTime("valueDateTime", dateTime)
+	optPeriod(obj, "valuePeriod", period)
+}
+
+// optArray appends an array field unless it is empty, as omitempty does
+func optArray[T any](obj *jsonenc.Object, name string, values []T, appendValue func([]byte, *T) []byte) {
+	if len(values) > 0 {
+		obj.Set(jsonenc.AppendArray(obj.Key(name), values, appendValue))
+	}
+}
+
+func optReference(obj *jsonenc.Object, name string, r *Reference) {
+	if r != nil {
+		obj.Set(appendReference(obj.Key(name), r))
+	}
+}
+
+func optCodeableConcept(obj *jsonenc.Object, name string, c *CodeableConcept) {
+	if c != nil {
+		obj.Set(appendCodeableConcept(obj.Key(name), c))
+	}
+}
+
+func optQuantity(obj *jsonenc.Object, name string, q *Quantity) {
+	if q != nil {
+		obj.Set(appendQuantity(obj.Key(name), q))
+	}
+}
+
+func optPeriod(obj *jsonenc.Object, name string, p *Period) {
+	if p != nil {
+		obj.Set(appendPeriod(obj.Key(name), p))
+	}
+}
+
+func appendCategory(b []byte, c *Category) []byte {
+	obj := jsonenc.Begin(b)
+	obj.Set(jsonenc.AppendArray(obj.Key("coding"), c.Coding, appendCoding))
+	obj.OptString("text", c.Text)
+	return obj.End()
+}
+
+func appendCodeableConcept(b []byte, c *CodeableConcept) []byte {
+	obj := jsonenc.Begin(b)
+	optArray(&obj, "coding", c.Coding, appendCoding)
+	obj.OptString("text", c.Text)
+	return obj.End()
+}
+
+func appendCoding(b []byte, c *Coding) []byte {
+	obj := jsonenc.Begin(b)
+	obj.OptString("system", c.System)
+	obj.OptString("version", c.Version)
+	obj.OptString("code", c.Code)
+	obj.OptString("display", c.Display)
+	if c.UserSelected != nil {
+		obj.Bool("userSelected", *c.UserSelected)
+	}
+	return obj.End()
+}
+
+func appendReference(b []byte, r *Reference) []byte {
+	obj := jsonenc.Begin(b)
+	obj.OptString("reference", r.Reference)
+	obj.OptString("type", r.Type)
+	if r.Identifier != nil {
+		obj.Set(appendIdentifier(obj.Key("identifier"), r.Identifier))
+	}
+	obj.OptString("display", r.Display)
+	return obj.End()
+}
+
+func appendIdentifier(b []byte, i *Identifier) []byte {
+	obj := jsonenc.Begin(b)
+	obj.OptString("use", i.Use)
+	optCodeableConcept(&obj, "type", i.Type)
+	obj.OptString("system", i.System)
+	obj.OptString("value", i.Value)
+	optPeriod(&obj, "period", i.Period)
+	optReference(&obj, "assigner", i.Assigner)
+	return obj.End()
+}
+
+func appendPeriod(b []byte, p *Period) []byte {
+	obj := jsonenc.Begin(b)
+	obj.OptTime("start", p.Start)
+	obj.OptTime("end", p.End)
+	return obj.End()
+}
+
+func appendQuantity(b []byte, q *Quantity) []byte {
+	obj := jsonenc.Begin(b)
+	obj.OptFloat("value", q.Value)
+	obj.OptString("comparator", q.Comparator)
+	obj.OptString("unit", q.Unit)
+	obj.OptString("system", q.System)
+	obj.OptString("code", q.Code)
+	return obj.End()
+}
+
+func appendRange(b []byte, r *Range) []byte {
+	obj := jsonenc.Begin(b)
+	optQuantity(&obj, "low", r.Low)
+	optQuantity(&obj, "high", r.High)
+	return obj.End()
+}
+
+func appendRatio(b []byte, r *Ratio) []byte {
+	obj := jsonenc.Begin(b)
+	optQuantity(&obj, "numerator", r.Numerator)
+	optQuantity(&obj, "denominator", r.Denominator)
+	return obj.End()
+}
+
+func appendAttachment(b []byte, a *Attachment) []byte {
+	obj := jsonenc.Begin(b)
+	obj.OptString("contentType", a.ContentType)
+	obj.OptString("language", a.Language)
+	obj.OptString("url", a.URL)
+	obj.OptInt("size", a.Size)
+	obj.OptString("hash", a.Hash)
+	obj.OptString("title", a.Title)
+	obj.OptTime("creation", a.Creation)
+	return obj.End()
+}
+
+func appendAnnotation(b []byte, a *Annotation) []byte {
+	obj := jsonenc.Begin(b)
+	optReference(&obj, "authorReference", a.AuthorReference)
+	obj.OptString("authorString", a.AuthorString)
+	obj.OptTime("time", a.Time)
+	obj.String("text", a.Text)
+	return obj.End()
+}
+
+func appendReferenceRange(b []byte, r *ReferenceRange) []byte {
+	obj := jsonenc.Begin(b)
+	optQuantity(&obj, "low", r.Low)
+	optQuantity(&obj, "high", r.High)
+	optCodeableConcept(&obj, "type", r.Type)
+	optArray(&obj, "appliesTo", r.AppliesTo, appendCodeableConcept)
+	if r.Age != nil {
+		obj.Set(appendRange(obj.Key("age"), r.Age))
+	}
+	obj.OptString("text", r.Text)
+	return obj.End()
+}
+
+func appendComponent(b []byte, c *Component) []byte {
+	obj := jsonenc.Begin(b)
+	obj.Set(appendCodeableConcept(obj.Key("code"), &c.Code))
+	appendValueX(&obj, c.ValueQuantity, c.ValueCodeable, c.ValueString, c.ValueBoolean, c.ValueInteger,
+		c.ValueRange, c.ValueRatio, c.ValueTime, c.ValueDateTime, c.ValuePeriod)
+	optCodeableConcept(&obj, "dataAbsentReason", c.DataAbsentReason)
+	optArray(&obj, "interpretation", c.Interpretation, appendCodeableConcept)
+	optArray(&obj, "referenceRange", c.ReferenceRange, appendReferenceRange)
+	return obj.End()
+}
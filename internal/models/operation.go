@@ -0,0 +1,78 @@
+package models
+
+import (
+	"encoding/json"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Operation kinds
+const (
+	OperationKindExport  = "export"  // Runs an ExportJob
+	OperationKindBulk    = "bulk"    // Runs a BulkJob
+	OperationKindIndexes = "indexes" // Builds database indexes
+	OperationKindViews   = "views"   // Refreshes the analytics materialized views
+	OperationKindReplay  = "replay"  // Runs an EventReplay
+)
+
+// Operation statuses
+const (
+	OperationQueued    = "queued"
+	OperationRunning   = "running"
+	OperationCompleted = "completed"
+	OperationFailed    = "failed"
+	OperationCancelled = "cancelled"
+)
+
+// Operation is a request accepted to run in the background because it may take longer
+// than a request may. Clients poll it for its progress and, once completed, its
+// result, and may cancel it while it is queued or running.
+type Operation struct {
+	ID              string          `json:"id" gorm:"primaryKey"`
+	Kind            string          `json:"kind" gorm:"index"`
+	Target          string          `json:"target,omitempty"` // The record the operation works on, e.g. ExportJob/{id}
+	Status          string          `json:"status" gorm:"index"`
+	Processed       int64           `json:"processed"`
+	Total           int64           `json:"total,omitempty"` // 0 while unknown
+	Result          json.RawMessage `json:"result,omitempty" gorm:"type:jsonb"`
+	Error           string          `json:"error,omitempty"`
+	CancelRequested bool            `json:"cancelRequested,omitempty"`
+	CreatedBy       string          `json:"createdBy" gorm:"index"`
+	CreatedAt       time.Time       `json:"createdAt"`
+	UpdatedAt       time.Time       `json:"updatedAt"`
+	StartedAt       *time.Time      `json:"startedAt,omitempty"`
+	CompletedAt     *time.Time      `json:"completedAt,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating an operation
+func (o *Operation) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	if o.Status == "" {
+		o.Status = OperationQueued
+	}
+	return nil
+}
+
+// TableName returns the table name for the Operation model
+func (Operation) TableName() string {
+	return "operations"
+}
+
+// TargetID returns the ID of the record the operation works on
+func (o *Operation) TargetID() string {
+	return o.Target[strings.LastIndex(o.Target, "/")+1:]
+}
+
+// Finished reports whether the operation has stopped for good
+func (o *Operation) Finished() bool {
+	switch o.Status {
+	case OperationCompleted, OperationFailed, OperationCancelled:
+		return true
+	}
+	return false
+}
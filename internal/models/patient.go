@@ -1,6 +1,7 @@
 package models
 
 import (
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
@@ -9,16 +10,28 @@ import (
 
 // Patient represents a FHIR-inspired Patient resource
 type Patient struct {
-	ID        string    `json:"id" gorm:"primaryKey"`
-	Active    bool      `json:"active" gorm:"default:true"`
-	Name      []Name    `json:"name" gorm:"serializer:json"`
-	Gender    string    `json:"gender" validate:"oneof=male female other unknown"`
-	BirthDate time.Time `json:"birthDate"`
-	Telecom   []Contact `json:"telecom" gorm:"serializer:json"`
-	Address   []Address `json:"address" gorm:"serializer:json"`
-	CreatedAt time.Time `json:"createdAt"`
-	UpdatedAt time.Time `json:"updatedAt"`
-	CreatedBy string    `json:"createdBy"`
+	ID                  string                 `json:"id" gorm:"primaryKey"`
+	Active              bool                   `json:"active" gorm:"default:true"`
+	Identifier          []Identifier           `json:"identifier,omitempty" gorm:"type:jsonb;serializer:json" validate:"dive"`
+	Name                []Name                 `json:"name" gorm:"type:jsonb;serializer:json"`
+	Gender              string                 `json:"gender" validate:"oneof=male female other unknown"`
+	GenderIdentity      *CodeableConcept       `json:"genderIdentity,omitempty" gorm:"type:jsonb;serializer:json"`
+	Pronouns            []CodeableConcept      `json:"pronouns,omitempty" gorm:"type:jsonb;serializer:json"`
+	SexAssignedAtBirth  string                 `json:"sexAssignedAtBirth,omitempty" validate:"omitempty,oneof=M F OTH UNK ASKU"`
+	Race                *RaceEthnicity         `json:"race,omitempty" gorm:"type:jsonb;serializer:json"`
+	Ethnicity           *RaceEthnicity         `json:"ethnicity,omitempty" gorm:"type:jsonb;serializer:json"`
+	BirthDate           time.Time              `json:"birthDate"`
+	Age                 *int                   `json:"age,omitempty" gorm:"-"` // Computed from BirthDate when read
+	Telecom             []Contact              `json:"telecom" gorm:"type:jsonb;serializer:json"`
+	Address             []Address              `json:"address" gorm:"type:jsonb;serializer:json"`
+	Communication       []PatientCommunication `json:"communication,omitempty" gorm:"type:jsonb;serializer:json" validate:"dive"`
+	InterpreterRequired bool                   `json:"interpreterRequired,omitempty"`
+	Link                []PatientLink          `json:"link,omitempty" gorm:"-"`
+	CreatedAt           time.Time              `json:"createdAt"`
+	UpdatedAt           time.Time              `json:"updatedAt"`
+	CreatedBy           string                 `json:"createdBy"`
+	DeletedAt           gorm.DeletedAt         `json:"-" gorm:"index"` // Set while a delete can still be undone
+	DeletedBy           string                 `json:"-"`
 }
 
 // Name represents a person's name following FHIR structure
@@ -30,12 +43,20 @@ type Name struct {
 	Suffix []string `json:"suffix,omitempty"`
 }
 
+// PatientCommunication represents a language the patient can use to communicate
+type PatientCommunication struct {
+	Language  CodeableConcept `json:"language"` // BCP-47 code, e.g. "es" or "zh-Hant"
+	Preferred bool            `json:"preferred,omitempty"`
+}
+
 // Contact represents contact information (phone, email, etc.)
 type Contact struct {
 	System string `json:"system" validate:"oneof=phone fax email pager url sms other"`
 	Value  string `json:"value" validate:"required"`
 	Use    string `json:"use" validate:"oneof=home work temp old mobile"`
 	Rank   int    `json:"rank,omitempty"`
+	// Set by the contact verification workflow; client-supplied values are ignored
+	Verified bool `json:"verified,omitempty"`
 }
 
 // Address represents a physical address
@@ -50,6 +71,15 @@ type Address struct {
 	PostalCode string   `json:"postalCode,omitempty"`
 	Country    string   `json:"country,omitempty"`
 	Period     *Period  `json:"period,omitempty"`
+	// Set by the address verification provider; client-supplied values are ignored
+	Verified    bool         `json:"verified,omitempty"`
+	Geolocation *Geolocation `json:"geolocation,omitempty"`
+}
+
+// Geolocation represents the coordinates of a verified address
+type Geolocation struct {
+	Latitude  float64 `json:"latitude"`
+	Longitude float64 `json:"longitude"`
 }
 
 // Period represents a time period with start and end
@@ -66,53 +96,55 @@ func (p *Patient) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// AfterFind is a GORM hook that computes the patient's current age
+func (p *Patient) AfterFind(tx *gorm.DB) error {
+	p.Age = p.AgeAt(time.Now())
+	return nil
+}
+
 // TableName returns the table name for the Patient model
 func (Patient) TableName() string {
 	return "patients"
 }
 
-// GetFullName returns the patient's full name
-func (p *Patient) GetFullName() string {
-	if len(p.Name) == 0 {
-		return ""
+// AgeAt returns the patient's age in whole years at the given time, or nil when the
+// birth date is unknown. A birthday on 29 February is reached on 1 March in common years.
+func (p *Patient) AgeAt(t time.Time) *int {
+	if p.BirthDate.IsZero() {
+		return nil
 	}
 
-	name := p.Name[0] // Use the first name entry
-	fullName := ""
-
-	// Add prefixes
-	for _, prefix := range name.Prefix {
-		if fullName != "" {
-			fullName += " "
-		}
-		fullName += prefix
+	birth := p.BirthDate.UTC()
+	t = t.UTC()
+	age := t.Year() - birth.Year()
+	if t.Month() < birth.Month() || (t.Month() == birth.Month() && t.Day() < birth.Day()) {
+		age--
 	}
-
-	// Add given names
-	for _, given := range name.Given {
-		if fullName != "" {
-			fullName += " "
-		}
-		fullName += given
+	if age < 0 {
+		age = 0
 	}
+	return &age
+}
 
-	// Add family name
-	if name.Family != "" {
-		if fullName != "" {
-			fullName += " "
-		}
-		fullName += name.Family
+// GetFullName returns the patient's full name
+func (p *Patient) GetFullName() string {
+	if len(p.Name) == 0 {
+		return ""
 	}
 
-	// Add suffixes
-	for _, suffix := range name.Suffix {
-		if fullName != "" {
-			fullName += " "
-		}
-		fullName += suffix
-	}
+	return p.Name[0].Full() // Use the first name entry
+}
 
-	return fullName
+// Full returns the name with its prefixes, given names, family name and suffixes
+func (n Name) Full() string {
+	parts := make([]string, 0, len(n.Prefix)+len(n.Given)+1+len(n.Suffix))
+	parts = append(parts, n.Prefix...)
+	parts = append(parts, n.Given...)
+	if n.Family != "" {
+		parts = append(parts, n.Family)
+	}
+	parts = append(parts, n.Suffix...)
+	return strings.Join(parts, " ")
 }
 
 // GetPrimaryEmail returns the patient's primary email address
@@ -125,6 +157,18 @@ func (p *Patient) GetPrimaryEmail() string {
 	return ""
 }
 
+// GetVerifiedTelecom returns the contacts of the given system that have been verified.
+// Notifications must only be sent to verified channels.
+func (p *Patient) GetVerifiedTelecom(system string) []Contact {
+	var verified []Contact
+	for _, contact := range p.Telecom {
+		if contact.Verified && contact.System == system {
+			verified = append(verified, contact)
+		}
+	}
+	return verified
+}
+
 // GetPrimaryPhone returns the patient's primary phone number
 func (p *Patient) GetPrimaryPhone() string {
 	for _, contact := range p.Telecom {
@@ -134,3 +178,21 @@ func (p *Patient) GetPrimaryPhone() string {
 	}
 	return ""
 }
+
+// SameLocation reports whether two addresses describe the same postal location,
+// ignoring use, period and verification results
+func (a Address) SameLocation(other Address) bool {
+	if len(a.Line) != len(other.Line) {
+		return false
+	}
+	for i := range a.Line {
+		if !strings.EqualFold(a.Line[i], other.Line[i]) {
+			return false
+		}
+	}
+	return strings.EqualFold(a.City, other.City) &&
+		strings.EqualFold(a.District, other.District) &&
+		strings.EqualFold(a.State, other.State) &&
+		strings.EqualFold(a.PostalCode, other.PostalCode) &&
+		strings.EqualFold(a.Country, other.Country)
+}
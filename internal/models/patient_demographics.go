@@ -0,0 +1,219 @@
+package models
+
+import (
+	"fmt"
+	"regexp"
+)
+
+// Code systems used by patient demographic extensions
+const (
+	SNOMEDSystem        = "http://snomed.info/sct"
+	LOINCSystem         = "http://loinc.org"
+	NullFlavorSystem    = "http://terminology.hl7.org/CodeSystem/v3-NullFlavor"
+	LanguageSystem      = "urn:ietf:bcp:47"
+	RaceEthnicitySystem = "urn:oid:2.16.840.1.113883.6.238" // CDC Race & Ethnicity
+)
+
+// RaceEthnicity follows the US Core race and ethnicity extensions: OMB minimum categories,
+// optional detailed CDC codes and a required free-text description
+type RaceEthnicity struct {
+	OMBCategory []Coding `json:"ombCategory,omitempty"`
+	Detailed    []Coding `json:"detailed,omitempty"`
+	Text        string   `json:"text,omitempty" validate:"required"`
+}
+
+// OMBRaceCategories are the OMB minimum race categories
+var OMBRaceCategories = map[string]string{
+	"1002-5": "American Indian or Alaska Native",
+	"2028-9": "Asian",
+	"2054-5": "Black or African American",
+	"2076-8": "Native Hawaiian or Other Pacific Islander",
+	"2106-3": "White",
+}
+
+// OMBEthnicityCategories are the OMB ethnicity categories
+var OMBEthnicityCategories = map[string]string{
+	"2135-2": "Hispanic or Latino",
+	"2186-5": "Not Hispanic or Latino",
+}
+
+// raceNullFlavors may be used instead of an OMB category when race or ethnicity is not known
+var raceNullFlavors = map[string]string{
+	"UNK":  "unknown",
+	"ASKU": "asked but unknown",
+}
+
+// languageTagPattern matches BCP-47 language tags such as "en", "es-MX" or "zh-Hant-TW"
+var languageTagPattern = regexp.MustCompile(`^[A-Za-z]{2,3}(-[A-Za-z0-9]{2,8})*$`)
+
+// genderIdentityCodes is the permitted value set for Patient.genderIdentity
+var genderIdentityCodes = map[string]map[string]string{
+	SNOMEDSystem: {
+		"446151000124109": "Identifies as male gender",
+		"446141000124107": "Identifies as female gender",
+		"446131000124102": "Identifies as nonbinary gender",
+		"33791000087105":  "Identifies as nonbinary gender",
+	},
+	NullFlavorSystem: {
+		"OTH":  "other",
+		"UNK":  "unknown",
+		"ASKU": "asked but unknown",
+	},
+}
+
+// pronounCodes is the permitted value set for Patient.pronouns (LOINC answer list LL5144-2)
+var pronounCodes = map[string]map[string]string{
+	LOINCSystem: {
+		"LA29518-0": "he/him/his/his/himself",
+		"LA29519-8": "she/her/her/hers/herself",
+		"LA29520-6": "they/them/their/theirs/themselves",
+	},
+	NullFlavorSystem: {
+		"OTH":  "other",
+		"UNK":  "unknown",
+		"ASKU": "asked but unknown",
+	},
+}
+
+// ValidateDemographics checks coded demographic extensions against their value sets
+func (p *Patient) ValidateDemographics() error {
+	if err := validateCodedValue("genderIdentity", p.GenderIdentity, genderIdentityCodes); err != nil {
+		return err
+	}
+	for _, pronoun := range p.Pronouns {
+		pronoun := pronoun
+		if err := validateCodedValue("pronouns", &pronoun, pronounCodes); err != nil {
+			return err
+		}
+	}
+
+	if err := validateRaceEthnicity("race", p.Race, OMBRaceCategories, len(OMBRaceCategories)); err != nil {
+		return err
+	}
+	if err := validateRaceEthnicity("ethnicity", p.Ethnicity, OMBEthnicityCategories, 1); err != nil {
+		return err
+	}
+
+	preferred := 0
+	for _, communication := range p.Communication {
+		if err := validateLanguage(communication.Language); err != nil {
+			return err
+		}
+		if communication.Preferred {
+			preferred++
+		}
+	}
+	if preferred > 1 {
+		return fmt.Errorf("only one communication language can be preferred")
+	}
+
+	return nil
+}
+
+// PreferredLanguage returns the code of the patient's preferred language, falling back
+// to the first listed language, or "" when none is recorded
+func (p *Patient) PreferredLanguage() string {
+	for _, communication := range p.Communication {
+		if communication.Preferred {
+			return communication.Language.LanguageCode()
+		}
+	}
+	if len(p.Communication) > 0 {
+		return p.Communication[0].Language.LanguageCode()
+	}
+	return ""
+}
+
+// LanguageCode returns the BCP-47 code of a language concept
+func (cc CodeableConcept) LanguageCode() string {
+	for _, coding := range cc.Coding {
+		if coding.System == LanguageSystem || coding.System == "" {
+			return coding.Code
+		}
+	}
+	return ""
+}
+
+// validateLanguage checks that a communication language carries a BCP-47 code
+func validateLanguage(language CodeableConcept) error {
+	code := language.LanguageCode()
+	if code == "" {
+		return fmt.Errorf("communication language requires a %s coding", LanguageSystem)
+	}
+	if !languageTagPattern.MatchString(code) {
+		return fmt.Errorf("communication language %q is not a valid BCP-47 tag", code)
+	}
+	return nil
+}
+
+// validateRaceEthnicity checks OMB categories against the permitted codes. Detailed codes
+// must come from the CDC Race & Ethnicity code system.
+func validateRaceEthnicity(field string, value *RaceEthnicity, categories map[string]string, maxCategories int) error {
+	if value == nil {
+		return nil
+	}
+	if value.Text == "" {
+		return fmt.Errorf("%s text is required", field)
+	}
+	if len(value.OMBCategory) > maxCategories {
+		return fmt.Errorf("%s allows at most %d OMB categories", field, maxCategories)
+	}
+
+	seen := make(map[string]bool)
+	for _, coding := range value.OMBCategory {
+		switch coding.System {
+		case RaceEthnicitySystem:
+			if _, ok := categories[coding.Code]; !ok {
+				return fmt.Errorf("%s code %q is not an OMB category", field, coding.Code)
+			}
+		case NullFlavorSystem:
+			if _, ok := raceNullFlavors[coding.Code]; !ok {
+				return fmt.Errorf("%s null flavor %q is not permitted", field, coding.Code)
+			}
+			if len(value.OMBCategory) > 1 {
+				return fmt.Errorf("%s null flavor cannot be combined with other categories", field)
+			}
+		default:
+			return fmt.Errorf("%s OMB category must use system %s", field, RaceEthnicitySystem)
+		}
+		if seen[coding.Code] {
+			return fmt.Errorf("%s OMB category %q is listed more than once", field, coding.Code)
+		}
+		seen[coding.Code] = true
+	}
+
+	for _, coding := range value.Detailed {
+		if coding.System != RaceEthnicitySystem || coding.Code == "" {
+			return fmt.Errorf("%s detailed codes must use system %s", field, RaceEthnicitySystem)
+		}
+	}
+
+	return nil
+}
+
+// validateCodedValue checks that a concept carries at least one coding from the value set.
+// A concept with only free text is accepted so patients can self-describe.
+func validateCodedValue(field string, concept *CodeableConcept, valueSet map[string]map[string]string) error {
+	if concept == nil {
+		return nil
+	}
+	if len(concept.Coding) == 0 {
+		if concept.Text == "" {
+			return fmt.Errorf("%s requires a coding or text", field)
+		}
+		return nil
+	}
+
+	for _, coding := range concept.Coding {
+		codes, ok := valueSet[coding.System]
+		if !ok {
+			continue
+		}
+		if _, ok := codes[coding.Code]; ok {
+			return nil
+		}
+		return fmt.Errorf("%s code %q is not valid for system %s", field, coding.Code, coding.System)
+	}
+
+	return fmt.Errorf("%s must include a coding from a supported system", field)
+}
@@ -0,0 +1,110 @@
+package models
+
+import "github.com/hillmatthew2000/HealthHub/pkg/jsonenc"
+
+// Hand-written JSON encoders of the patient types; see observation_json.go
+
+// AppendJSON appends the patient as JSON
+func (p *Patient) AppendJSON(b []byte) []byte {
+	obj := jsonenc.Begin(b)
+	obj.String("id", p.ID)
+	obj.Bool("active", p.Active)
+	optArray(&obj, "identifier", p.Identifier, appendIdentifier)
+	obj.Set(jsonenc.AppendArray(obj.Key("name"), p.Name, appendName))
+	obj.String("gender", p.Gender)
+	optCodeableConcept(&obj, "genderIdentity", p.GenderIdentity)
+	optArray(&obj, "pronouns", p.Pronouns, appendCodeableConcept)
+	obj.OptString("sexAssignedAtBirth", p.SexAssignedAtBirth)
+	if p.Race != nil {
+		obj.Set(appendRaceEthnicity(obj.Key("race"), p.Race))
+	}
+	if p.Ethnicity != nil {
+		obj.Set(appendRaceEthnicity(obj.Key("ethnicity"), p.Ethnicity))
+	}
+	obj.Time("birthDate", p.BirthDate)
+	if p.Age != nil {
+		obj.Int("age", int64(*p.Age))
+	}
+	obj.Set(jsonenc.AppendArray(obj.Key("telecom"), p.Telecom, appendContact))
+	obj.Set(jsonenc.AppendArray(obj.Key("address"), p.Address, appendAddress))
+	optArray(&obj, "communication", p.Communication, appendCommunication)
+	obj.OptBool("interpreterRequired", p.InterpreterRequired)
+	optArray(&obj, "link", p.Link, appendPatientLink)
+	obj.Time("createdAt", p.CreatedAt)
+	obj.Time("updatedAt", p.UpdatedAt)
+	obj.String("createdBy", p.CreatedBy)
+	return obj.End()
+}
+
+func appendName(b []byte, n *Name) []byte {
+	obj := jsonenc.Begin(b)
+	obj.String("use", n.Use)
+	obj.String("family", n.Family)
+	obj.Set(jsonenc.AppendStrings(obj.Key("given"), n.Given))
+	if len(n.Prefix) > 0 {
+		obj.Set(jsonenc.AppendStrings(obj.Key("prefix"), n.Prefix))
+	}
+	if len(n.Suffix) > 0 {
+		obj.Set(jsonenc.AppendStrings(obj.Key("suffix"), n.Suffix))
+	}
+	return obj.End()
+}
+
+func appendRaceEthnicity(b []byte, r *RaceEthnicity) []byte {
+	obj := jsonenc.Begin(b)
+	optArray(&obj, "ombCategory", r.OMBCategory, appendCoding)
+	optArray(&obj, "detailed", r.Detailed, appendCoding)
+	obj.OptString("text", r.Text)
+	return obj.End()
+}
+
+func appendContact(b []byte, c *Contact) []byte {
+	obj := jsonenc.Begin(b)
+	obj.String("system", c.System)
+	obj.String("value", c.Value)
+	obj.String("use", c.Use)
+	obj.OptInt("rank", int64(c.Rank))
+	obj.OptBool("verified", c.Verified)
+	return obj.End()
+}
+
+func appendAddress(b []byte, a *Address) []byte {
+	obj := jsonenc.Begin(b)
+	obj.String("use", a.Use)
+	obj.OptString("type", a.Type)
+	obj.OptString("text", a.Text)
+	if len(a.Line) > 0 {
+		obj.Set(jsonenc.AppendStrings(obj.Key("line"), a.Line))
+	}
+	obj.OptString("city", a.City)
+	obj.OptString("district", a.District)
+	obj.OptString("state", a.State)
+	obj.OptString("postalCode", a.PostalCode)
+	obj.OptString("country", a.Country)
+	optPeriod(&obj, "period", a.Period)
+	obj.OptBool("verified", a.Verified)
+	if a.Geolocation != nil {
+		geo := jsonenc.Begin(obj.Key("geolocation"))
+		geo.Float("latitude", a.Geolocation.Latitude)
+		geo.Float("longitude", a.Geolocation.Longitude)
+		obj.Set(geo.End())
+	}
+	return obj.End()
+}
+
+func appendCommunication(b []byte, c *PatientCommunication) []byte {
+	obj := jsonenc.Begin(b)
+	obj.Set(appendCodeableConcept(obj.Key("language"), &c.Language))
+	obj.OptBool("preferred", c.Preferred)
+	return obj.End()
+}
+
+func appendPatientLink(b []byte, l *PatientLink) []byte {
+	obj := jsonenc.Begin(b)
+	obj.String("id", l.ID)
+	obj.Set(appendReference(obj.Key("other"), &l.Other))
+	obj.String("type", l.Type)
+	obj.Time("createdAt", l.CreatedAt)
+	obj.String("createdBy", l.CreatedBy)
+	return obj.End()
+}
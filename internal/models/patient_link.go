@@ -0,0 +1,71 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Patient link types, following FHIR Patient.link.type
+const (
+	PatientLinkReplacedBy = "replaced-by"
+	PatientLinkReplaces   = "replaces"
+	PatientLinkRefer      = "refer"
+	PatientLinkSeeAlso    = "seealso"
+)
+
+// PatientLink links a patient record to another record for the same person, e.g. a
+// duplicate registered by another source system
+type PatientLink struct {
+	ID        string    `json:"id" gorm:"primaryKey"`
+	PatientID string    `json:"-" gorm:"index;uniqueIndex:idx_patient_links_pair"`
+	OtherID   string    `json:"-" gorm:"index;uniqueIndex:idx_patient_links_pair"`
+	Other     Reference `json:"other" gorm:"-"`
+	Type      string    `json:"type"`
+	CreatedAt time.Time `json:"createdAt"`
+	CreatedBy string    `json:"createdBy"`
+}
+
+// PatientLinkRequest represents a request to link a patient to another record
+type PatientLinkRequest struct {
+	Other string `json:"other" validate:"required"` // "Patient/{id}" or a bare patient ID
+	Type  string `json:"type" validate:"oneof=replaced-by replaces refer seealso"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a patient link
+func (l *PatientLink) BeforeCreate(tx *gorm.DB) error {
+	if l.ID == "" {
+		l.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// AfterFind is a GORM hook that populates the link reference from the stored ID
+func (l *PatientLink) AfterFind(tx *gorm.DB) error {
+	l.Other = Reference{Reference: "Patient/" + l.OtherID, Type: "Patient"}
+	return nil
+}
+
+// LiveLinks is a query scope that hides links to patients pending deletion. Those links
+// are kept so an undelete restores them, and are removed when the deletion is purged.
+func LiveLinks(tx *gorm.DB) *gorm.DB {
+	return tx.Where("other_id IN (?)", tx.Session(&gorm.Session{NewDB: true}).Model(&Patient{}).Select("id"))
+}
+
+// TableName returns the table name for the PatientLink model
+func (PatientLink) TableName() string {
+	return "patient_links"
+}
+
+// InversePatientLinkType returns the link type recorded on the other side of a link
+func InversePatientLinkType(linkType string) string {
+	switch linkType {
+	case PatientLinkReplacedBy:
+		return PatientLinkReplaces
+	case PatientLinkReplaces:
+		return PatientLinkReplacedBy
+	default:
+		return PatientLinkSeeAlso
+	}
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// PatientMerge records the merge of a duplicate patient record into the record that
+// survives it
+type PatientMerge struct {
+	ID             string    `json:"id" gorm:"primaryKey"`
+	PatientID      string    `json:"-" gorm:"index"` // The duplicate
+	Patient        Reference `json:"patient" gorm:"-"`
+	SurvivorID     string    `json:"-" gorm:"index"`
+	Survivor       Reference `json:"survivor" gorm:"-"`
+	Reason         string    `json:"reason,omitempty" sanitize:"note"`
+	ObservationIDs []string  `json:"observationIds" gorm:"type:jsonb;serializer:json"` // Observations re-pointed to the survivor
+	CreatedAt      time.Time `json:"createdAt"`
+	CreatedBy      string    `json:"createdBy"`
+}
+
+// PatientMergeRequest represents a request to merge a duplicate patient into another
+type PatientMergeRequest struct {
+	Survivor string `json:"survivor" validate:"required"` // "Patient/{id}" or a bare patient ID
+	Reason   string `json:"reason,omitempty" validate:"max=500"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a patient merge
+func (m *PatientMerge) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// AfterFind is a GORM hook that populates the patient references from the stored IDs
+func (m *PatientMerge) AfterFind(tx *gorm.DB) error {
+	m.Patient = Reference{Reference: "Patient/" + m.PatientID, Type: "Patient"}
+	m.Survivor = Reference{Reference: "Patient/" + m.SurvivorID, Type: "Patient"}
+	return nil
+}
+
+// TableName returns the table name for the PatientMerge model
+func (PatientMerge) TableName() string {
+	return "patient_merges"
+}
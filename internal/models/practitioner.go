@@ -0,0 +1,93 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Practitioner represents a FHIR-inspired Practitioner resource: a person who performs
+// or orders care, such as the performer of an observation. A practitioner may be the
+// user account the person signs in with.
+type Practitioner struct {
+	ID            string                      `json:"id" gorm:"primaryKey"`
+	Active        bool                        `json:"active" gorm:"default:true"`
+	NPI           *string                     `json:"npi,omitempty" gorm:"uniqueIndex"`
+	Name          []Name                      `json:"name" gorm:"type:jsonb;serializer:json"`
+	Qualification []PractitionerQualification `json:"qualification,omitempty" gorm:"type:jsonb;serializer:json"`
+	Specialty     []CodeableConcept           `json:"specialty,omitempty" gorm:"type:jsonb;serializer:json"`
+	Telecom       []Contact                   `json:"telecom,omitempty" gorm:"type:jsonb;serializer:json"`
+	Address       []Address                   `json:"address,omitempty" gorm:"type:jsonb;serializer:json"`
+	UserID        *string                     `json:"userId,omitempty" gorm:"uniqueIndex"` // Linked user account
+	CreatedAt     time.Time                   `json:"createdAt"`
+	UpdatedAt     time.Time                   `json:"updatedAt"`
+	CreatedBy     string                      `json:"createdBy"`
+}
+
+// PractitionerQualification is a certification, license or training of a practitioner
+type PractitionerQualification struct {
+	Code       CodeableConcept `json:"code"`
+	Identifier string          `json:"identifier,omitempty"` // e.g. the license number
+	Issuer     string          `json:"issuer,omitempty"`
+	Period     *Period         `json:"period,omitempty"`
+}
+
+// PractitionerRequest represents a request to create or replace a practitioner
+type PractitionerRequest struct {
+	Active        *bool                       `json:"active,omitempty"`
+	NPI           string                      `json:"npi,omitempty" validate:"omitempty,len=10,numeric"`
+	Name          []Name                      `json:"name" validate:"required,min=1,dive"`
+	Qualification []PractitionerQualification `json:"qualification,omitempty"`
+	Specialty     []CodeableConcept           `json:"specialty,omitempty"`
+	Telecom       []Contact                   `json:"telecom,omitempty" validate:"dive"`
+	Address       []Address                   `json:"address,omitempty" validate:"dive"`
+	UserID        string                      `json:"userId,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a practitioner
+func (p *Practitioner) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the Practitioner model
+func (Practitioner) TableName() string {
+	return "practitioners"
+}
+
+// GetFullName returns the practitioner's full name
+func (p *Practitioner) GetFullName() string {
+	if len(p.Name) == 0 {
+		return ""
+	}
+
+	return p.Name[0].Full()
+}
+
+// ValidNPI reports whether an NPI has a valid check digit. The check digit is the Luhn
+// check digit of the first nine digits prefixed with 80840, the NPI's card issuer
+// prefix.
+func ValidNPI(npi string) bool {
+	if len(npi) != 10 {
+		return false
+	}
+	digits := "80840" + npi
+	sum := 0
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if d < 0 || d > 9 {
+			return false
+		}
+		// Every second digit from the check digit is doubled
+		if (len(digits)-1-i)%2 == 1 {
+			if d *= 2; d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+	}
+	return sum%10 == 0
+}
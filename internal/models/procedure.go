@@ -0,0 +1,68 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Procedure statuses
+const (
+	ProcedurePreparation    = "preparation"
+	ProcedureInProgress     = "in-progress"
+	ProcedureNotDone        = "not-done"
+	ProcedureOnHold         = "on-hold"
+	ProcedureStopped        = "stopped"
+	ProcedureCompleted      = "completed"
+	ProcedureEnteredInError = "entered-in-error"
+	ProcedureUnknown        = "unknown"
+)
+
+// Procedure represents a FHIR-inspired Procedure resource: an action performed on
+// the patient, such as a biopsy, a transfusion or a surgical operation
+type Procedure struct {
+	ID          string            `json:"id" gorm:"primaryKey"`
+	Subject     Reference         `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Status      string            `json:"status" gorm:"index"`
+	Code        CodeableConcept   `json:"code" gorm:"type:jsonb;serializer:json"`
+	PerformedAt *time.Time        `json:"performedDateTime,omitempty" gorm:"index"`
+	Performer   []Reference       `json:"performer,omitempty" gorm:"serializer:json"`
+	Outcome     *CodeableConcept  `json:"outcome,omitempty" gorm:"type:jsonb;serializer:json"` // e.g. successful, unsuccessful
+	BodySite    []CodeableConcept `json:"bodySite,omitempty" gorm:"type:jsonb;serializer:json"`
+	Note        string            `json:"note,omitempty" sanitize:"note"`
+	CreatedAt   time.Time         `json:"createdAt"`
+	UpdatedAt   time.Time         `json:"updatedAt"`
+	CreatedBy   string            `json:"createdBy"`
+	DeletedAt   gorm.DeletedAt    `json:"-" gorm:"index"` // Set while a delete can still be undone
+	DeletedBy   string            `json:"-"`
+}
+
+// ProcedureRequest represents a request to record a procedure or to replace one. The
+// subject is taken from the path when posted under a patient.
+type ProcedureRequest struct {
+	Subject     Reference         `json:"subject"`
+	Status      string            `json:"status,omitempty" validate:"omitempty,oneof=preparation in-progress not-done on-hold stopped completed entered-in-error unknown"`
+	Code        CodeableConcept   `json:"code"`
+	PerformedAt *time.Time        `json:"performedDateTime,omitempty"`
+	Performer   []Reference       `json:"performer,omitempty" validate:"max=20"`
+	Outcome     *CodeableConcept  `json:"outcome,omitempty"`
+	BodySite    []CodeableConcept `json:"bodySite,omitempty" validate:"max=20"`
+	Note        string            `json:"note,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a procedure
+func (p *Procedure) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	if p.Status == "" {
+		p.Status = ProcedureCompleted
+	}
+	return nil
+}
+
+// TableName returns the table name for the Procedure model
+func (Procedure) TableName() string {
+	return "procedures"
+}
@@ -0,0 +1,99 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// QC result statuses
+const (
+	QCStatusBaseline = "baseline" // Recorded while the control's mean and SD are being established
+	QCStatusAccepted = "accepted"
+	QCStatusWarning  = "warning"
+	QCStatusRejected = "rejected"
+)
+
+// QCControl is a control material measured on an instrument: one level of one lot
+// for one test. Results are judged against its mean and standard deviation, either
+// assigned when the control is created or established from its first results.
+type QCControl struct {
+	ID           string     `json:"id" gorm:"primaryKey"`
+	Instrument   string     `json:"instrument" gorm:"uniqueIndex:idx_qc_controls_material"` // Device reference or identifier
+	Code         string     `json:"code" gorm:"uniqueIndex:idx_qc_controls_material"`
+	Display      string     `json:"display,omitempty" sanitize:"display"`
+	Lot          string     `json:"lot" gorm:"uniqueIndex:idx_qc_controls_material"`
+	Level        string     `json:"level" gorm:"uniqueIndex:idx_qc_controls_material"`
+	Unit         string     `json:"unit,omitempty"`
+	Mean         *float64   `json:"mean,omitempty"`
+	SD           *float64   `json:"sd,omitempty"`
+	BaselineSize int        `json:"baselineSize"` // Results used to establish mean and SD when not assigned
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+	Active       bool       `json:"active"`
+	CreatedAt    time.Time  `json:"createdAt"`
+	UpdatedAt    time.Time  `json:"updatedAt"`
+	CreatedBy    string     `json:"createdBy"`
+}
+
+// QCControlRequest represents a request to register a control material
+type QCControlRequest struct {
+	Instrument   string     `json:"instrument" validate:"required"`
+	Code         string     `json:"code" validate:"required"`
+	Display      string     `json:"display,omitempty"`
+	Lot          string     `json:"lot" validate:"required"`
+	Level        string     `json:"level" validate:"required"`
+	Unit         string     `json:"unit,omitempty"`
+	Mean         *float64   `json:"mean,omitempty" validate:"required_with=SD"`
+	SD           *float64   `json:"sd,omitempty" validate:"required_with=Mean"`
+	BaselineSize int        `json:"baselineSize,omitempty" validate:"omitempty,min=10,max=100"`
+	ExpiresAt    *time.Time `json:"expiresAt,omitempty"`
+}
+
+// QCResult is one measurement of a control material with the Westgard rules it
+// violated
+type QCResult struct {
+	ID         string    `json:"id" gorm:"primaryKey"`
+	ControlID  string    `json:"controlId" gorm:"index:idx_qc_results_control"`
+	Value      float64   `json:"value"`
+	ZScore     *float64  `json:"zScore,omitempty"`
+	Status     string    `json:"status"`
+	Violations []string  `json:"violations,omitempty" gorm:"type:jsonb;serializer:json"`
+	Comment    string    `json:"comment,omitempty" sanitize:"note"`
+	MeasuredAt time.Time `json:"measuredAt" gorm:"index:idx_qc_results_control"`
+	CreatedAt  time.Time `json:"createdAt"`
+	CreatedBy  string    `json:"createdBy"`
+}
+
+// QCResultRequest represents a request to record a control measurement
+type QCResultRequest struct {
+	Value      *float64   `json:"value" validate:"required"`
+	MeasuredAt *time.Time `json:"measuredAt,omitempty"` // Defaults to now
+	Comment    string     `json:"comment,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a QC control
+func (q *QCControl) BeforeCreate(tx *gorm.DB) error {
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating a QC result
+func (r *QCResult) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the QCControl model
+func (QCControl) TableName() string {
+	return "qc_controls"
+}
+
+// TableName returns the table name for the QCResult model
+func (QCResult) TableName() string {
+	return "qc_results"
+}
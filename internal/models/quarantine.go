@@ -0,0 +1,63 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Quarantined message statuses
+const (
+	QuarantineHeld         = "quarantined"  // Waiting for review
+	QuarantineReprocessing = "reprocessing" // Being resubmitted
+	QuarantineReprocessed  = "reprocessed"  // Accepted when reprocessed
+	QuarantineDiscarded    = "discarded"    // Dropped by an admin
+)
+
+// QuarantinedMessage is an inbound message that failed validation, kept with its
+// error so it can be corrected and reprocessed instead of being lost
+type QuarantinedMessage struct {
+	ID            string                 `json:"id" gorm:"primaryKey"`
+	CredentialID  string                 `json:"credentialId" gorm:"index"`
+	UserID        string                 `json:"userId"`
+	Method        string                 `json:"method"`
+	URI           string                 `json:"uri"`
+	Route         string                 `json:"route" gorm:"index"`
+	ContentType   string                 `json:"contentType"`
+	Headers       map[string]string      `json:"headers,omitempty" gorm:"type:jsonb;serializer:json"` // Request headers the inbound routes read, resent when reprocessing
+	Body          string                 `json:"body,omitempty"`
+	StatusCode    int                    `json:"statusCode"`
+	ErrorCode     string                 `json:"errorCode" gorm:"index"`
+	Error         string                 `json:"error"`
+	MappingHints  map[string]interface{} `json:"mappingHints,omitempty" gorm:"type:jsonb;serializer:json"` // Values set at dotted JSON paths of the body when reprocessing
+	Status        string                 `json:"status" gorm:"index"`
+	Attempts      int                    `json:"attempts"`
+	LastAttemptAt *time.Time             `json:"lastAttemptAt,omitempty"`
+	ResourceID    string                 `json:"resourceId,omitempty"` // Created by the reprocessed message
+	ResolvedAt    *time.Time             `json:"resolvedAt,omitempty" gorm:"index"`
+	ResolvedBy    string                 `json:"resolvedBy,omitempty"`
+	CreatedAt     time.Time              `json:"createdAt" gorm:"index"`
+	UpdatedAt     time.Time              `json:"updatedAt"`
+}
+
+// MappingHintsRequest represents a request to replace a quarantined message's mapping hints
+type MappingHintsRequest struct {
+	MappingHints map[string]interface{} `json:"mappingHints" validate:"max=100,dive,keys,required,max=200,endkeys"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a quarantined message
+func (m *QuarantinedMessage) BeforeCreate(tx *gorm.DB) error {
+	if m.ID == "" {
+		m.ID = uuid.New().String()
+	}
+	if m.Status == "" {
+		m.Status = QuarantineHeld
+	}
+	return nil
+}
+
+// TableName returns the table name for the QuarantinedMessage model
+func (QuarantinedMessage) TableName() string {
+	return "quarantined_messages"
+}
@@ -0,0 +1,287 @@
+package models
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Questionnaire represents a FHIR-inspired Questionnaire definition (intake forms, PHQ-9, fall risk)
+type Questionnaire struct {
+	ID          string              `json:"id" gorm:"primaryKey"`
+	Name        string              `json:"name" gorm:"uniqueIndex" validate:"required"`
+	Title       string              `json:"title" validate:"required"`
+	Status      string              `json:"status" validate:"oneof=draft active retired"`
+	Description string              `json:"description,omitempty"`
+	Code        []Coding            `json:"code,omitempty" gorm:"serializer:json"`
+	Item        []QuestionnaireItem `json:"item" gorm:"serializer:json" validate:"required,min=1,dive"`
+	Scoring     *ScoringRule        `json:"scoring,omitempty" gorm:"serializer:json"`
+	CreatedAt   time.Time           `json:"createdAt"`
+	UpdatedAt   time.Time           `json:"updatedAt"`
+	CreatedBy   string              `json:"createdBy"`
+}
+
+// QuestionnaireItem represents a question or group of questions
+type QuestionnaireItem struct {
+	LinkID       string              `json:"linkId" validate:"required"`
+	Text         string              `json:"text,omitempty"`
+	Type         string              `json:"type" validate:"oneof=group display boolean integer decimal string choice"`
+	Required     bool                `json:"required,omitempty"`
+	AnswerOption []AnswerOption      `json:"answerOption,omitempty" validate:"dive"`
+	Item         []QuestionnaireItem `json:"item,omitempty" validate:"dive"`
+}
+
+// AnswerOption represents a permitted answer for a choice question
+type AnswerOption struct {
+	ValueCoding *Coding  `json:"valueCoding,omitempty"`
+	ValueString string   `json:"valueString,omitempty"`
+	Score       *float64 `json:"score,omitempty"`
+}
+
+// ScoringRule describes how a response is scored and recorded as an observation
+type ScoringRule struct {
+	Method string          `json:"method" validate:"oneof=sum"`
+	Code   CodeableConcept `json:"code"` // Code of the derived score observation
+	Unit   string          `json:"unit,omitempty"`
+	Bands  []ScoreBand     `json:"bands,omitempty"`
+}
+
+// ScoreBand maps a score range (inclusive) to an interpretation, e.g. "moderate depression"
+type ScoreBand struct {
+	Min     float64 `json:"min"`
+	Max     float64 `json:"max"`
+	Code    string  `json:"code"`
+	Display string  `json:"display" sanitize:"display"`
+}
+
+// QuestionnaireResponse represents a completed or in-progress set of answers
+type QuestionnaireResponse struct {
+	ID                   string         `json:"id" gorm:"primaryKey"`
+	QuestionnaireID      string         `json:"questionnaire" gorm:"index"`
+	Status               string         `json:"status" validate:"oneof=in-progress completed amended entered-in-error stopped"`
+	Subject              Reference      `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Encounter            *Reference     `json:"encounter,omitempty" gorm:"embedded;embeddedPrefix:encounter_"`
+	Authored             time.Time      `json:"authored"`
+	Author               Reference      `json:"author" gorm:"embedded;embeddedPrefix:author_"`
+	Item                 []ResponseItem `json:"item" gorm:"serializer:json" validate:"dive"`
+	Score                *float64       `json:"score,omitempty"`
+	ScoreInterpretation  string         `json:"scoreInterpretation,omitempty"`
+	DerivedObservationID string         `json:"derivedObservation,omitempty"`
+	CreatedAt            time.Time      `json:"createdAt"`
+	UpdatedAt            time.Time      `json:"updatedAt"`
+	CreatedBy            string         `json:"createdBy"`
+}
+
+// ResponseItem represents the answers to a single questionnaire item
+type ResponseItem struct {
+	LinkID string           `json:"linkId" validate:"required"`
+	Answer []ResponseAnswer `json:"answer,omitempty"`
+	Item   []ResponseItem   `json:"item,omitempty" validate:"dive"`
+}
+
+// ResponseAnswer represents a single answer value
+type ResponseAnswer struct {
+	ValueBoolean *bool    `json:"valueBoolean,omitempty"`
+	ValueInteger *int     `json:"valueInteger,omitempty"`
+	ValueDecimal *float64 `json:"valueDecimal,omitempty"`
+	ValueString  string   `json:"valueString,omitempty"`
+	ValueCoding  *Coding  `json:"valueCoding,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a questionnaire
+func (q *Questionnaire) BeforeCreate(tx *gorm.DB) error {
+	if q.ID == "" {
+		q.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating a questionnaire response
+func (r *QuestionnaireResponse) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the Questionnaire model
+func (Questionnaire) TableName() string {
+	return "questionnaires"
+}
+
+// TableName returns the table name for the QuestionnaireResponse model
+func (QuestionnaireResponse) TableName() string {
+	return "questionnaire_responses"
+}
+
+// ValidateResponse checks answers against the questionnaire's item definitions.
+// Required items are only enforced for completed responses.
+func (q *Questionnaire) ValidateResponse(response *QuestionnaireResponse) error {
+	answers := make(map[string][]ResponseAnswer)
+	collectAnswers(response.Item, answers)
+
+	known := make(map[string]bool)
+	if err := validateItems(q.Item, answers, known, response.Status == "completed"); err != nil {
+		return err
+	}
+
+	for linkID := range answers {
+		if !known[linkID] {
+			return fmt.Errorf("answer for unknown item %q", linkID)
+		}
+	}
+
+	return nil
+}
+
+// Score computes the response score using the questionnaire's scoring rule
+func (q *Questionnaire) Score(response *QuestionnaireResponse) (float64, *ScoreBand, error) {
+	if q.Scoring == nil {
+		return 0, nil, fmt.Errorf("questionnaire %q has no scoring rule", q.Name)
+	}
+
+	answers := make(map[string][]ResponseAnswer)
+	collectAnswers(response.Item, answers)
+
+	total := 0.0
+	for _, item := range flattenItems(q.Item) {
+		for _, answer := range answers[item.LinkID] {
+			score, err := answerScore(item, answer)
+			if err != nil {
+				return 0, nil, err
+			}
+			total += score
+		}
+	}
+
+	for i := range q.Scoring.Bands {
+		band := q.Scoring.Bands[i]
+		if total >= band.Min && total <= band.Max {
+			return total, &band, nil
+		}
+	}
+
+	return total, nil, nil
+}
+
+// validateItems recursively validates answers for the given questionnaire items
+func validateItems(items []QuestionnaireItem, answers map[string][]ResponseAnswer, known map[string]bool, enforceRequired bool) error {
+	for _, item := range items {
+		known[item.LinkID] = true
+		itemAnswers := answers[item.LinkID]
+
+		if item.Type == "group" || item.Type == "display" {
+			if len(itemAnswers) > 0 {
+				return fmt.Errorf("item %q does not accept answers", item.LinkID)
+			}
+			if err := validateItems(item.Item, answers, known, enforceRequired); err != nil {
+				return err
+			}
+			continue
+		}
+
+		if enforceRequired && item.Required && len(itemAnswers) == 0 {
+			return fmt.Errorf("item %q is required", item.LinkID)
+		}
+
+		for _, answer := range itemAnswers {
+			if err := validateAnswer(item, answer); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// validateAnswer checks that an answer value matches the item type
+func validateAnswer(item QuestionnaireItem, answer ResponseAnswer) error {
+	valid := false
+	switch item.Type {
+	case "boolean":
+		valid = answer.ValueBoolean != nil
+	case "integer":
+		valid = answer.ValueInteger != nil
+	case "decimal":
+		valid = answer.ValueDecimal != nil || answer.ValueInteger != nil
+	case "string":
+		valid = answer.ValueString != ""
+	case "choice":
+		_, err := findOption(item, answer)
+		valid = err == nil
+	}
+
+	if !valid {
+		return fmt.Errorf("invalid answer for item %q of type %s", item.LinkID, item.Type)
+	}
+
+	return nil
+}
+
+// answerScore returns the numeric contribution of an answer to the total score
+func answerScore(item QuestionnaireItem, answer ResponseAnswer) (float64, error) {
+	switch item.Type {
+	case "choice":
+		option, err := findOption(item, answer)
+		if err != nil {
+			return 0, err
+		}
+		if option.Score != nil {
+			return *option.Score, nil
+		}
+	case "integer":
+		if answer.ValueInteger != nil {
+			return float64(*answer.ValueInteger), nil
+		}
+	case "decimal":
+		if answer.ValueDecimal != nil {
+			return *answer.ValueDecimal, nil
+		}
+		if answer.ValueInteger != nil {
+			return float64(*answer.ValueInteger), nil
+		}
+	case "boolean":
+		if answer.ValueBoolean != nil && *answer.ValueBoolean {
+			return 1, nil
+		}
+	}
+
+	return 0, nil
+}
+
+// findOption locates the answer option matching a choice answer
+func findOption(item QuestionnaireItem, answer ResponseAnswer) (*AnswerOption, error) {
+	for i := range item.AnswerOption {
+		option := &item.AnswerOption[i]
+		if option.ValueCoding != nil && answer.ValueCoding != nil &&
+			option.ValueCoding.Code == answer.ValueCoding.Code &&
+			(option.ValueCoding.System == "" || option.ValueCoding.System == answer.ValueCoding.System) {
+			return option, nil
+		}
+		if option.ValueString != "" && option.ValueString == answer.ValueString {
+			return option, nil
+		}
+	}
+
+	return nil, fmt.Errorf("answer for item %q is not one of the permitted options", item.LinkID)
+}
+
+// collectAnswers flattens nested response items into a map keyed by link ID
+func collectAnswers(items []ResponseItem, answers map[string][]ResponseAnswer) {
+	for _, item := range items {
+		answers[item.LinkID] = append(answers[item.LinkID], item.Answer...)
+		collectAnswers(item.Item, answers)
+	}
+}
+
+// flattenItems returns all questionnaire items including nested group members
+func flattenItems(items []QuestionnaireItem) []QuestionnaireItem {
+	var flat []QuestionnaireItem
+	for _, item := range items {
+		flat = append(flat, item)
+		flat = append(flat, flattenItems(item.Item)...)
+	}
+	return flat
+}
@@ -0,0 +1,49 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Reasons a refresh token family is revoked
+const (
+	RefreshRevokedLogout         = "logout"
+	RefreshRevokedReuse          = "reuse"           // A used token of the family was presented again
+	RefreshRevokedPasswordChange = "password_change" // The user changed their password
+	RefreshRevokedUserInactive   = "user_inactive"
+)
+
+// RefreshToken is a refresh token issued to a user, stored as the SHA-256 hash of the
+// token. Each use rotates it: the token is marked used and a new token of the same
+// family, the login session it descends from, is issued in its place.
+type RefreshToken struct {
+	ID            string     `json:"id" gorm:"primaryKey"`
+	UserID        string     `json:"userId" gorm:"not null;index"`
+	FamilyID      string     `json:"familyId" gorm:"not null;index"`
+	TokenHash     string     `json:"-" gorm:"not null;uniqueIndex"`
+	ExpiresAt     time.Time  `json:"expiresAt" gorm:"index"`
+	UsedAt        *time.Time `json:"usedAt,omitempty"`
+	RevokedAt     *time.Time `json:"revokedAt,omitempty"`
+	RevokedReason string     `json:"revokedReason,omitempty"`
+	ClientIP      string     `json:"clientIp,omitempty"`
+	UserAgent     string     `json:"userAgent,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a refresh token
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	if t.FamilyID == "" {
+		t.FamilyID = t.ID
+	}
+	return nil
+}
+
+// TableName returns the table name for the RefreshToken model
+func (RefreshToken) TableName() string {
+	return "refresh_tokens"
+}
@@ -0,0 +1,97 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Code systems of related person relationships
+const (
+	// RelatedPersonContactRoleSystem is HL7 v2 table 0131, the contact roles such as
+	// next-of-kin and emergency contact
+	RelatedPersonContactRoleSystem = "http://terminology.hl7.org/CodeSystem/v2-0131"
+	// RelatedPersonRoleCodeSystem is the HL7 v3 RoleCode system of personal
+	// relationships such as mother or spouse
+	RelatedPersonRoleCodeSystem = "http://terminology.hl7.org/CodeSystem/v3-RoleCode"
+)
+
+// Contact roles of HL7 v2 table 0131
+const (
+	ContactRoleEmergency = "C"
+	ContactRoleNextOfKin = "N"
+)
+
+// RelatedPersonContactRoles are the codes of HL7 v2 table 0131 with their displays
+var RelatedPersonContactRoles = map[string]string{
+	"BP":                 "Billing contact person",
+	"CP":                 "Contact person",
+	"EP":                 "Emergency contact person",
+	"PR":                 "Person preparing referral",
+	"E":                  "Employer",
+	ContactRoleEmergency: "Emergency Contact",
+	"F":                  "Federal Agency",
+	"I":                  "Insurance Company",
+	ContactRoleNextOfKin: "Next-of-Kin",
+	"S":                  "State Agency",
+	"O":                  "Other",
+	"U":                  "Unknown",
+}
+
+// RelatedPerson represents a FHIR-inspired RelatedPerson resource: someone with a
+// personal relationship to a patient, such as their next-of-kin or an emergency contact
+type RelatedPerson struct {
+	ID           string            `json:"id" gorm:"primaryKey"`
+	Active       bool              `json:"active"`
+	Patient      Reference         `json:"patient" gorm:"embedded;embeddedPrefix:patient_"`
+	Relationship []CodeableConcept `json:"relationship" gorm:"type:jsonb;serializer:json"` // v2-0131 contact roles and v3 RoleCode relationships
+	Name         []Name            `json:"name" gorm:"type:jsonb;serializer:json"`
+	Gender       string            `json:"gender,omitempty"`
+	Telecom      []Contact         `json:"telecom,omitempty" gorm:"type:jsonb;serializer:json"`
+	Address      []Address         `json:"address,omitempty" gorm:"type:jsonb;serializer:json"`
+	Period       Period            `json:"period" gorm:"embedded;embeddedPrefix:period_"` // When the relationship is in force
+	Rank         int               `json:"rank,omitempty"`                                // Order to contact in, 1 first
+	CreatedAt    time.Time         `json:"createdAt"`
+	UpdatedAt    time.Time         `json:"updatedAt"`
+	CreatedBy    string            `json:"createdBy"`
+}
+
+// RelatedPersonRequest represents a request to record a related person of a patient
+// or to replace one
+type RelatedPersonRequest struct {
+	Active       *bool             `json:"active,omitempty"`
+	Relationship []CodeableConcept `json:"relationship" validate:"required,min=1"`
+	Name         []Name            `json:"name" validate:"required,min=1,dive"`
+	Gender       string            `json:"gender,omitempty" validate:"omitempty,oneof=male female other unknown"`
+	Telecom      []Contact         `json:"telecom,omitempty" validate:"dive"`
+	Address      []Address         `json:"address,omitempty" validate:"dive"`
+	Period       Period            `json:"period"`
+	Rank         int               `json:"rank,omitempty" validate:"min=0"`
+}
+
+// HasRelationship reports whether the related person has a relationship of the code,
+// in any system
+func (r *RelatedPerson) HasRelationship(code string) bool {
+	for _, relationship := range r.Relationship {
+		for _, coding := range relationship.Coding {
+			if coding.Code == code {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// BeforeCreate is a GORM hook that runs before creating a related person
+func (r *RelatedPerson) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the RelatedPerson model
+func (RelatedPerson) TableName() string {
+	return "related_persons"
+}
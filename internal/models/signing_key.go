@@ -0,0 +1,22 @@
+package models
+
+import "time"
+
+// JWT signing key statuses
+const (
+	SigningKeyActive   = "active"   // Signs new tokens
+	SigningKeyRetiring = "retiring" // Still validates tokens until RetiresAt
+	SigningKeyRetired  = "retired"  // No longer accepted
+)
+
+// SigningKey is a JWT signing key created by a rotation. The ID is the key ID
+// carried in token headers; the secret is stored encrypted.
+type SigningKey struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	Secret    string     `json:"-"`
+	Status    string     `json:"status" gorm:"index"`
+	CreatedBy string     `json:"createdBy"`
+	CreatedAt time.Time  `json:"createdAt"`
+	RetiresAt *time.Time `json:"retiresAt,omitempty"`
+	RetiredAt *time.Time `json:"retiredAt,omitempty"`
+}
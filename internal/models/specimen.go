@@ -0,0 +1,88 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Specimen statuses
+const (
+	SpecimenStatusAvailable      = "available"
+	SpecimenStatusUnavailable    = "unavailable"
+	SpecimenStatusUnsatisfactory = "unsatisfactory" // Rejected by the laboratory
+	SpecimenStatusEnteredInError = "entered-in-error"
+)
+
+// SpecimenRejectReasonSystem is the code system of specimen rejection reasons
+const SpecimenRejectReasonSystem = "http://terminology.hl7.org/CodeSystem/v2-0490"
+
+// SpecimenRejectReasons maps the accepted rejection reason codes to their display
+var SpecimenRejectReasons = map[string]string{
+	"EX": "Expired",
+	"QS": "Quantity not sufficient",
+	"RB": "Broken container",
+	"RC": "Clotting",
+	"RH": "Hemolysis",
+	"RM": "Labeling",
+	"RN": "Contamination",
+	"RR": "Improper storage",
+}
+
+// Specimen represents a FHIR-inspired Specimen resource: a sample collected from a
+// patient for laboratory testing. Observations refer to it as Specimen/{id}.
+type Specimen struct {
+	ID               string              `json:"id" gorm:"primaryKey"`
+	Accession        string              `json:"accession" gorm:"uniqueIndex" validate:"required"`
+	Status           string              `json:"status" gorm:"index" validate:"omitempty,oneof=available unavailable unsatisfactory entered-in-error"`
+	Type             *CodeableConcept    `json:"type,omitempty" gorm:"type:jsonb;serializer:json"`
+	Subject          Reference           `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Department       string              `json:"department,omitempty" gorm:"index"` // Managed observation category code
+	OrderedBy        string              `json:"orderedBy,omitempty"`               // User ID of the ordering practitioner
+	CollectedAt      *time.Time          `json:"collectedAt,omitempty"`
+	Collector        *Reference          `json:"collector,omitempty" gorm:"type:jsonb;serializer:json"`
+	Container        []SpecimenContainer `json:"container,omitempty" gorm:"type:jsonb;serializer:json" validate:"max=20,dive"`
+	ReceivedAt       time.Time           `json:"receivedAt" gorm:"index"`
+	RejectionReason  string              `json:"rejectionReason,omitempty" gorm:"index"` // Code in SpecimenRejectReasonSystem
+	RejectionComment string              `json:"rejectionComment,omitempty" sanitize:"note"`
+	RejectedAt       *time.Time          `json:"rejectedAt,omitempty"`
+	RejectedBy       string              `json:"rejectedBy,omitempty"`
+	CreatedAt        time.Time           `json:"createdAt"`
+	UpdatedAt        time.Time           `json:"updatedAt"`
+	CreatedBy        string              `json:"createdBy"`
+}
+
+// SpecimenContainer is a container the specimen was collected or is kept in, such as
+// a tube identified by its barcode
+type SpecimenContainer struct {
+	Identifier  string           `json:"identifier,omitempty"`
+	Type        *CodeableConcept `json:"type,omitempty"` // e.g. a lavender-top EDTA tube
+	Description string           `json:"description,omitempty"`
+	Additive    *CodeableConcept `json:"additive,omitempty"`
+}
+
+// SpecimenRejectRequest represents a request to reject a specimen
+type SpecimenRejectRequest struct {
+	Reason  string `json:"reason" validate:"required,oneof=EX QS RB RC RH RM RN RR"`
+	Comment string `json:"comment,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a specimen
+func (s *Specimen) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	if s.Status == "" {
+		s.Status = SpecimenStatusAvailable
+	}
+	if s.ReceivedAt.IsZero() {
+		s.ReceivedAt = time.Now().UTC()
+	}
+	return nil
+}
+
+// TableName returns the table name for the Specimen model
+func (Specimen) TableName() string {
+	return "specimens"
+}
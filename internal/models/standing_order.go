@@ -0,0 +1,147 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Observation slot statuses
+const (
+	SlotStatusPending   = "pending"
+	SlotStatusCollected = "collected"
+	SlotStatusMissed    = "missed"
+	SlotStatusCancelled = "cancelled" // The standing order was discontinued
+)
+
+// DefaultSlotToleranceMinutes is how far from its due time an observation still
+// fulfils a slot when the order sets no tolerance
+const DefaultSlotToleranceMinutes = 60
+
+// DailySchedule repeats at the same local times every day from StartsAt until EndsAt
+type DailySchedule struct {
+	Times    []string   `json:"times" gorm:"type:jsonb;serializer:json"` // HH:MM
+	Timezone string     `json:"timezone"`
+	StartsAt time.Time  `json:"startsAt"`
+	EndsAt   *time.Time `json:"endsAt,omitempty"`
+}
+
+// StandingOrder is a recurring order for an observation, such as a daily glucose at
+// 06:00. It expects one observation with the order's code at each time of its
+// schedule.
+type StandingOrder struct {
+	ID               string          `json:"id" gorm:"primaryKey"`
+	Subject          Reference       `json:"subject" gorm:"embedded;embeddedPrefix:subject_"`
+	Code             CodeableConcept `json:"code" gorm:"type:jsonb;serializer:json"`
+	DailySchedule    `gorm:"embedded"`
+	ToleranceMinutes int        `json:"toleranceMinutes"`
+	Ward             string     `json:"ward,omitempty" gorm:"index"`
+	OrderedBy        string     `json:"orderedBy,omitempty"` // User ID of the ordering practitioner
+	Active           bool       `json:"active" gorm:"index"`
+	ScheduledUntil   *time.Time `json:"scheduledUntil,omitempty"` // Slots are generated up to here
+	CreatedAt        time.Time  `json:"createdAt"`
+	UpdatedAt        time.Time  `json:"updatedAt"`
+	CreatedBy        string     `json:"createdBy"`
+}
+
+// StandingOrderRequest represents a request to create a standing order
+type StandingOrderRequest struct {
+	Subject          Reference       `json:"subject"`
+	Code             CodeableConcept `json:"code"`
+	Times            []string        `json:"times" validate:"required,min=1,max=24,dive,datetime=15:04"`
+	Timezone         string          `json:"timezone,omitempty" validate:"omitempty,timezone"`
+	ToleranceMinutes int             `json:"toleranceMinutes,omitempty" validate:"omitempty,min=1,max=720"`
+	Ward             string          `json:"ward,omitempty"`
+	OrderedBy        string          `json:"orderedBy,omitempty"`
+	StartsAt         *time.Time      `json:"startsAt,omitempty"`
+	EndsAt           *time.Time      `json:"endsAt,omitempty"`
+}
+
+// ObservationSlot is one observation expected by a standing order
+type ObservationSlot struct {
+	ID            string     `json:"id" gorm:"primaryKey"`
+	OrderID       string     `json:"orderId" gorm:"uniqueIndex:idx_observation_slots_due"`
+	PatientID     string     `json:"patientId" gorm:"index:idx_observation_slots_patient"`
+	Ward          string     `json:"ward,omitempty" gorm:"index"`
+	Code          string     `json:"code"`
+	Display       string     `json:"display,omitempty" sanitize:"display"`
+	DueAt         time.Time  `json:"dueAt" gorm:"uniqueIndex:idx_observation_slots_due;index"`
+	Date          string     `json:"date" gorm:"index:idx_observation_slots_patient"` // Local date of DueAt, YYYY-MM-DD
+	Status        string     `json:"status" gorm:"index"`
+	ObservationID string     `json:"observationId,omitempty" gorm:"index"`
+	CollectedAt   *time.Time `json:"collectedAt,omitempty"`
+	CreatedAt     time.Time  `json:"createdAt"`
+}
+
+// Location returns the time zone of the schedule's times
+func (s *DailySchedule) Location() *time.Location {
+	if loc, err := time.LoadLocation(s.Timezone); err == nil {
+		return loc
+	}
+	return time.UTC
+}
+
+// DueTimes returns the times of the schedule in [from, to), in UTC
+func (s *DailySchedule) DueTimes(from, to time.Time) []time.Time {
+	if from.Before(s.StartsAt) {
+		from = s.StartsAt
+	}
+	if s.EndsAt != nil && to.After(*s.EndsAt) {
+		to = *s.EndsAt
+	}
+
+	loc := s.Location()
+	var due []time.Time
+	start := from.In(loc)
+	for day := time.Date(start.Year(), start.Month(), start.Day(), 0, 0, 0, 0, loc); day.Before(to); day = day.AddDate(0, 0, 1) {
+		for _, hhmm := range s.Times {
+			at, err := time.Parse("15:04", hhmm)
+			if err != nil {
+				continue
+			}
+			t := time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, loc)
+			if !t.Before(from) && t.Before(to) {
+				due = append(due, t.UTC())
+			}
+		}
+	}
+	return due
+}
+
+// Tolerance returns how far from its due time an observation still fulfils a slot
+func (o *StandingOrder) Tolerance() time.Duration {
+	if o.ToleranceMinutes <= 0 {
+		return DefaultSlotToleranceMinutes * time.Minute
+	}
+	return time.Duration(o.ToleranceMinutes) * time.Minute
+}
+
+// BeforeCreate is a GORM hook that runs before creating a standing order
+func (o *StandingOrder) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// BeforeCreate is a GORM hook that runs before creating an observation slot
+func (s *ObservationSlot) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	if s.Status == "" {
+		s.Status = SlotStatusPending
+	}
+	return nil
+}
+
+// TableName returns the table name for the StandingOrder model
+func (StandingOrder) TableName() string {
+	return "standing_orders"
+}
+
+// TableName returns the table name for the ObservationSlot model
+func (ObservationSlot) TableName() string {
+	return "observation_slots"
+}
@@ -17,6 +17,7 @@ type User struct {
 	LastName  string     `json:"lastName" validate:"required"`
 	Roles     []Role     `json:"roles" gorm:"many2many:user_roles;"`
 	Active    bool       `json:"active" gorm:"default:true"`
+	Sandbox   bool       `json:"sandbox" gorm:"default:false"` // Writes are simulated, not persisted
 	LastLogin *time.Time `json:"lastLogin,omitempty"`
 	CreatedAt time.Time  `json:"createdAt"`
 	UpdatedAt time.Time  `json:"updatedAt"`
@@ -165,11 +166,25 @@ type AuthRequest struct {
 	Password string `json:"password" validate:"required"`
 }
 
-// AuthResponse represents a login response
+// AuthResponse represents a login response. The access token is short-lived; the
+// refresh token renews it and is replaced by a new one each time it is used.
 type AuthResponse struct {
-	Token     string    `json:"token"`
-	ExpiresAt time.Time `json:"expiresAt"`
-	User      UserInfo  `json:"user"`
+	Token                 string    `json:"token"`
+	ExpiresAt             time.Time `json:"expiresAt"`
+	RefreshToken          string    `json:"refreshToken"`
+	RefreshTokenExpiresAt time.Time `json:"refreshTokenExpiresAt"`
+	User                  UserInfo  `json:"user"`
+}
+
+// RefreshRequest represents a request to renew an access token
+type RefreshRequest struct {
+	RefreshToken string `json:"refreshToken" validate:"required"`
+}
+
+// LogoutRequest represents a request to log out. The access token is sent in the
+// Authorization header; the refresh token is optional.
+type LogoutRequest struct {
+	RefreshToken string `json:"refreshToken"`
 }
 
 // UserInfo represents user information for responses
@@ -197,6 +212,11 @@ type ChangePasswordRequest struct {
 	NewPassword     string `json:"newPassword" validate:"required,min=8"`
 }
 
+// SandboxRequest represents a request to turn a user's sandbox mode on or off
+type SandboxRequest struct {
+	Sandbox *bool `json:"sandbox" validate:"required"`
+}
+
 // UpdateUserRequest represents a user update request
 type UpdateUserRequest struct {
 	FirstName string   `json:"firstName,omitempty"`
@@ -0,0 +1,60 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// UserIdentity links a user to the account that signs them in at an identity provider,
+// by the provider's issuer and its subject for the account. A user is linked on their
+// first single sign-on login.
+type UserIdentity struct {
+	ID        string     `json:"id" gorm:"primaryKey"`
+	UserID    string     `json:"userId" gorm:"not null;index"`
+	Issuer    string     `json:"issuer" gorm:"not null;uniqueIndex:idx_user_identity_subject"`
+	Subject   string     `json:"subject" gorm:"not null;uniqueIndex:idx_user_identity_subject"`
+	Groups    []string   `json:"groups" gorm:"type:jsonb;serializer:json"` // Provider groups at the last login
+	LastLogin *time.Time `json:"lastLogin,omitempty"`
+	CreatedAt time.Time  `json:"createdAt"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a user identity
+func (i *UserIdentity) BeforeCreate(tx *gorm.DB) error {
+	if i.ID == "" {
+		i.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the UserIdentity model
+func (UserIdentity) TableName() string {
+	return "user_identities"
+}
+
+// OIDCLoginState is a single sign-on login in progress: the user was sent to the
+// identity provider and is expected back with the state, stored as its SHA-256 hash.
+// It holds the nonce the ID token must carry and the PKCE verifier the code is
+// redeemed with.
+type OIDCLoginState struct {
+	ID           string    `json:"id" gorm:"primaryKey"`
+	StateHash    string    `json:"-" gorm:"not null;uniqueIndex"`
+	Nonce        string    `json:"-" gorm:"not null"`
+	CodeVerifier string    `json:"-" gorm:"not null"`
+	ExpiresAt    time.Time `json:"expiresAt" gorm:"index"`
+	CreatedAt    time.Time `json:"createdAt"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a login state
+func (s *OIDCLoginState) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the OIDCLoginState model
+func (OIDCLoginState) TableName() string {
+	return "oidc_login_states"
+}
@@ -0,0 +1,47 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// ValidationProfile is a set of deployment-specific field rules applied to a resource type
+// on top of struct validation. Profiles with an empty tenant apply to every tenant.
+type ValidationProfile struct {
+	ID           string      `json:"id" gorm:"primaryKey"`
+	Tenant       string      `json:"tenant,omitempty" gorm:"uniqueIndex:idx_validation_profiles_name"`
+	ResourceType string      `json:"resourceType" gorm:"uniqueIndex:idx_validation_profiles_name" validate:"required,oneof=Patient Observation"`
+	Name         string      `json:"name" gorm:"uniqueIndex:idx_validation_profiles_name" validate:"required"`
+	Description  string      `json:"description,omitempty"`
+	Active       bool        `json:"active" gorm:"default:true"`
+	Rules        []FieldRule `json:"rules" gorm:"type:jsonb;serializer:json" validate:"required,min=1,dive"`
+	CreatedAt    time.Time   `json:"createdAt"`
+	UpdatedAt    time.Time   `json:"updatedAt"`
+	CreatedBy    string      `json:"createdBy"`
+}
+
+// FieldRule constrains the values found at a JSON path, e.g. "birthDate",
+// "address.postalCode" or "identifier[type.coding.code=MR].value". Arrays along
+// the path are expanded, and a bracketed selector keeps only matching elements.
+type FieldRule struct {
+	Path      string `json:"path" validate:"required"`
+	Required  bool   `json:"required,omitempty"`
+	Forbidden bool   `json:"forbidden,omitempty"`
+	Pattern   string `json:"pattern,omitempty"`
+	Message   string `json:"message,omitempty"`
+}
+
+// BeforeCreate is a GORM hook that runs before creating a validation profile
+func (p *ValidationProfile) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// TableName returns the table name for the ValidationProfile model
+func (ValidationProfile) TableName() string {
+	return "validation_profiles"
+}
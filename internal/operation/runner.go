@@ -0,0 +1,261 @@
+// Package operation runs requests that may take longer than a request may, such as
+// exports and bulk updates, in the background. The request is answered with 202 and
+// an operation the client polls for its progress and result, and may cancel.
+package operation
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrFinished is returned when cancelling an operation that has already stopped
+var ErrFinished = errors.New("operation: operation has finished")
+
+// Polling intervals
+const (
+	pollInterval   = 10 * time.Second // Of queued operations, when the worker is not woken
+	cancelInterval = 2 * time.Second  // Of the cancellation of a running operation
+)
+
+// Func runs an operation of a kind and returns the result recorded on it. Progress is
+// reported through the task. ctx is cancelled when the operation is cancelled, which
+// the task reports, or when its worker stops; an operation interrupted by a stop is
+// run again from the start by the next worker, so a Func must be safe to repeat.
+type Func func(ctx context.Context, task *Task) (interface{}, error)
+
+// Runner runs operations, one at a time for each kind
+type Runner struct {
+	db    *gorm.DB
+	kinds map[string]*kind
+}
+
+// kind is a registered kind of operation
+type kind struct {
+	run  Func
+	wake chan struct{}
+}
+
+// NewRunner creates a new operation runner
+func NewRunner(db *gorm.DB) *Runner {
+	return &Runner{
+		db:    db,
+		kinds: map[string]*kind{},
+	}
+}
+
+// Register sets the function that runs operations of a kind. Kinds are registered
+// before their worker starts.
+func (r *Runner) Register(name string, run Func) {
+	r.kinds[name] = &kind{run: run, wake: make(chan struct{}, 1)}
+}
+
+// Queue creates a queued operation of a kind on target through db, which may be the
+// transaction creating the target. The kind's worker picks it up once woken.
+func (r *Runner) Queue(db *gorm.DB, name, target, createdBy string) (*models.Operation, error) {
+	if _, ok := r.kinds[name]; !ok {
+		return nil, fmt.Errorf("operation: unknown kind %q", name)
+	}
+
+	op := models.Operation{Kind: name, Target: target, Status: models.OperationQueued, CreatedBy: createdBy}
+	if err := db.Create(&op).Error; err != nil {
+		return nil, err
+	}
+	return &op, nil
+}
+
+// Wake signals the worker of a kind that an operation has been queued
+func (r *Runner) Wake(name string) {
+	if k, ok := r.kinds[name]; ok {
+		select {
+		case k.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// Cancel cancels an operation. A queued operation is cancelled at once; a running one
+// is asked to stop and is cancelled by its worker, which may run on another replica.
+func (r *Runner) Cancel(op *models.Operation) error {
+	now := time.Now()
+	result := r.db.Model(op).Where("status = ?", models.OperationQueued).
+		Updates(map[string]interface{}{"status": models.OperationCancelled, "cancel_requested": true, "completed_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		result = r.db.Model(op).Where("status = ?", models.OperationRunning).Update("cancel_requested", true)
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			return ErrFinished
+		}
+	}
+	var updated models.Operation
+	if err := r.db.Where("id = ?", op.ID).First(&updated).Error; err != nil {
+		return err
+	}
+	*op = updated
+	return nil
+}
+
+// Worker returns the worker of a kind, which runs its operations one at a time, oldest
+// first, until the context is cancelled
+func (r *Runner) Worker(name string) func(ctx context.Context) {
+	return func(ctx context.Context) {
+		k := r.kinds[name]
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for {
+			for {
+				var op models.Operation
+				err := r.db.Where("kind = ? AND status IN ?", name, []string{models.OperationQueued, models.OperationRunning}).
+					Order("created_at ASC").First(&op).Error
+				if err != nil {
+					if err != gorm.ErrRecordNotFound {
+						logger.Warn("Failed to fetch operations", zap.String("kind", name), zap.Error(err))
+					}
+					break
+				}
+				if err := r.process(ctx, k, &op); err != nil {
+					if ctx.Err() != nil {
+						return
+					}
+					logger.Error("Failed to record operation status", zap.String("operation_id", op.ID), zap.Error(err))
+					break
+				}
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-k.wake:
+			case <-ticker.C:
+			}
+		}
+	}
+}
+
+// process runs an operation and records how it ended. An operation interrupted by the
+// worker stopping is left running so it is picked up again.
+func (r *Runner) process(ctx context.Context, k *kind, op *models.Operation) error {
+	task := &Task{Operation: op, db: r.db}
+	if op.CancelRequested {
+		// Cancelled while its previous run was interrupted
+		return r.finish(task, nil, context.Canceled)
+	}
+	if op.Status == models.OperationQueued {
+		now := time.Now()
+		result := r.db.Model(op).Where("status = ?", models.OperationQueued).
+			Updates(map[string]interface{}{"status": models.OperationRunning, "started_at": now})
+		if result.Error != nil {
+			return result.Error
+		}
+		if result.RowsAffected == 0 {
+			// Cancelled before it started
+			return nil
+		}
+		op.Status = models.OperationRunning
+		op.StartedAt = &now
+	}
+
+	runCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	go task.watch(runCtx, cancel)
+
+	result, err := k.run(runCtx, task)
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return r.finish(task, result, err)
+}
+
+// finish records the outcome of an operation
+func (r *Runner) finish(task *Task, result interface{}, cause error) error {
+	op := task.Operation
+	now := time.Now()
+	op.CompletedAt = &now
+	switch {
+	case cause != nil && (task.Cancelled() || op.CancelRequested):
+		op.Status = models.OperationCancelled
+	case cause != nil:
+		op.Status = models.OperationFailed
+		op.Error = cause.Error()
+	default:
+		op.Status = models.OperationCompleted
+		if result != nil {
+			encoded, err := json.Marshal(result)
+			if err != nil {
+				return fmt.Errorf("failed to encode result: %w", err)
+			}
+			op.Result = encoded
+		}
+	}
+	if err := r.db.Model(op).Select("status", "result", "error", "completed_at").Updates(op).Error; err != nil {
+		return err
+	}
+
+	logger.LogAuditEvent("operation_"+op.Status, op.Kind, op.CreatedBy, map[string]interface{}{
+		"operation_id": op.ID,
+		"target":       op.Target,
+		"processed":    op.Processed,
+		"error":        op.Error,
+	})
+	return nil
+}
+
+// Task is the operation being run, given to its Func
+type Task struct {
+	Operation *models.Operation
+
+	db        *gorm.DB
+	cancelled atomic.Bool
+}
+
+// Progress records how much of the operation is done; total is 0 while unknown
+func (t *Task) Progress(processed, total int64) error {
+	t.Operation.Processed = processed
+	t.Operation.Total = total
+	return t.db.Model(t.Operation).Select("processed", "total").Updates(t.Operation).Error
+}
+
+// Cancelled reports whether the operation was cancelled, rather than interrupted by
+// its worker stopping
+func (t *Task) Cancelled() bool {
+	return t.cancelled.Load()
+}
+
+// watch cancels the run of the operation once cancellation is requested
+func (t *Task) watch(ctx context.Context, cancel context.CancelFunc) {
+	ticker := time.NewTicker(cancelInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			var requested []bool
+			if err := t.db.Model(&models.Operation{}).Where("id = ?", t.Operation.ID).
+				Pluck("cancel_requested", &requested).Error; err != nil {
+				logger.Warn("Failed to check operation cancellation", zap.String("operation_id", t.Operation.ID), zap.Error(err))
+				continue
+			}
+			if len(requested) > 0 && requested[0] {
+				t.cancelled.Store(true)
+				cancel()
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,155 @@
+package qc
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// DefaultBaselineSize is the number of results used to establish a control's mean
+// and SD when none are assigned
+const DefaultBaselineSize = 20
+
+var resultsRecorded = promauto.NewCounterVec(prometheus.CounterOpts{
+	Name: "qc_results_total",
+	Help: "Total number of quality control results recorded, by status",
+}, []string{"status"})
+
+// Recorder stores control measurements, judges them against the control's
+// statistics and raises an alert when a run is rejected
+type Recorder struct {
+	db *gorm.DB
+}
+
+// NewRecorder creates a new QC recorder
+func NewRecorder(db *gorm.DB) *Recorder {
+	return &Recorder{db: db}
+}
+
+// Record judges and stores a measurement of control. While the control's mean and
+// SD are being established results are stored unjudged; the result completing the
+// baseline sets them on the control. The alert is nil unless the run is rejected.
+func (r *Recorder) Record(ctx context.Context, control *models.QCControl, result *models.QCResult) (*models.Alert, error) {
+	var alert *models.Alert
+	err := r.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		result.ControlID = control.ID
+		if control.Mean == nil || control.SD == nil {
+			if err := r.establish(tx, control, result); err != nil {
+				return err
+			}
+			return tx.Create(result).Error
+		}
+
+		var previous []float64
+		if err := tx.Model(&models.QCResult{}).
+			Where("control_id = ? AND measured_at < ? AND status <> ?", control.ID, result.MeasuredAt, models.QCStatusBaseline).
+			Order("measured_at DESC").Limit(historySize-1).Pluck("value", &previous).Error; err != nil {
+			return fmt.Errorf("failed to read previous QC results: %w", err)
+		}
+
+		mean, sd := *control.Mean, *control.SD
+		z := make([]float64, 0, len(previous)+1)
+		for i := len(previous) - 1; i >= 0; i-- {
+			z = append(z, (previous[i]-mean)/sd)
+		}
+		score := (result.Value - mean) / sd
+		z = append(z, score)
+
+		result.ZScore = &score
+		result.Violations = Evaluate(z)
+		result.Status = models.QCStatusAccepted
+		for _, rule := range result.Violations {
+			if Rejects(rule) {
+				result.Status = models.QCStatusRejected
+				break
+			}
+			result.Status = models.QCStatusWarning
+		}
+		if err := tx.Create(result).Error; err != nil {
+			return err
+		}
+
+		if result.Status != models.QCStatusRejected {
+			return nil
+		}
+		alert = &models.Alert{
+			Type:         models.AlertTypeQCViolation,
+			Severity:     models.AlertSeverityCritical,
+			ResourceType: "QCResult",
+			ResourceID:   result.ID,
+			Department:   models.DepartmentLaboratory,
+			Message: fmt.Sprintf("QC run rejected on %s: %s lot %s level %s violated %s",
+				control.Instrument, control.Code, control.Lot, control.Level, strings.Join(result.Violations, ", ")),
+			Details: map[string]interface{}{
+				"control_id": control.ID,
+				"instrument": control.Instrument,
+				"code":       control.Code,
+				"lot":        control.Lot,
+				"level":      control.Level,
+				"value":      result.Value,
+				"z_score":    score,
+				"violations": result.Violations,
+			},
+		}
+		return tx.Create(alert).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	resultsRecorded.WithLabelValues(result.Status).Inc()
+	if alert != nil {
+		logger.Warn("QC run rejected",
+			zap.String("control_id", control.ID),
+			zap.String("instrument", control.Instrument),
+			zap.String("code", control.Code),
+			zap.Strings("violations", result.Violations),
+		)
+	}
+	return alert, nil
+}
+
+// establish records a baseline result and, once the baseline is complete, sets the
+// control's mean and SD from it
+func (r *Recorder) establish(tx *gorm.DB, control *models.QCControl, result *models.QCResult) error {
+	result.Status = models.QCStatusBaseline
+
+	var values []float64
+	if err := tx.Model(&models.QCResult{}).Where("control_id = ? AND status = ?", control.ID, models.QCStatusBaseline).
+		Pluck("value", &values).Error; err != nil {
+		return fmt.Errorf("failed to read baseline QC results: %w", err)
+	}
+	values = append(values, result.Value)
+
+	size := control.BaselineSize
+	if size <= 0 {
+		size = DefaultBaselineSize
+	}
+	if len(values) < size {
+		return nil
+	}
+
+	mean, sd := Statistics(values)
+	if sd == 0 {
+		// Identical results give no SD to judge against; keep collecting
+		logger.Warn("QC baseline results have no spread", zap.String("control_id", control.ID))
+		return nil
+	}
+	control.Mean, control.SD = &mean, &sd
+	if err := tx.Model(control).Select("mean", "sd").Updates(control).Error; err != nil {
+		return fmt.Errorf("failed to store QC control statistics: %w", err)
+	}
+	logger.Info("Established QC control statistics",
+		zap.String("control_id", control.ID),
+		zap.Float64("mean", mean),
+		zap.Float64("sd", sd),
+	)
+	return nil
+}
@@ -0,0 +1,93 @@
+// Package qc judges quality control measurements of laboratory instruments with the
+// Westgard multirules and keeps the statistics their Levey-Jennings charts are drawn
+// against.
+package qc
+
+import "math"
+
+// Westgard rules
+const (
+	Rule12s = "1-2s" // One result beyond 2 SD; a warning only
+	Rule13s = "1-3s" // One result beyond 3 SD
+	Rule22s = "2-2s" // Two consecutive results beyond 2 SD on the same side
+	RuleR4s = "R-4s" // Two consecutive results beyond 2 SD on opposite sides
+	Rule41s = "4-1s" // Four consecutive results beyond 1 SD on the same side
+	Rule10x = "10-x" // Ten consecutive results on the same side of the mean
+)
+
+// historySize is the number of results the longest rule looks at
+const historySize = 10
+
+// Rejects reports whether a violation of rule rejects the run
+func Rejects(rule string) bool {
+	return rule != Rule12s
+}
+
+// Evaluate returns the rules violated by the latest of the z-scores, which are
+// ordered oldest first. Only violations involving the latest result are reported,
+// so an earlier violation is not raised again for every result that follows it.
+func Evaluate(z []float64) []string {
+	if len(z) == 0 {
+		return nil
+	}
+	if len(z) > historySize {
+		z = z[len(z)-historySize:]
+	}
+	last := z[len(z)-1]
+
+	var violations []string
+	if math.Abs(last) > 2 {
+		violations = append(violations, Rule12s)
+	}
+	if math.Abs(last) > 3 {
+		violations = append(violations, Rule13s)
+	}
+	if len(z) >= 2 {
+		previous := z[len(z)-2]
+		if (last > 2 && previous > 2) || (last < -2 && previous < -2) {
+			violations = append(violations, Rule22s)
+		}
+		if (last > 2 && previous < -2) || (last < -2 && previous > 2) {
+			violations = append(violations, RuleR4s)
+		}
+	}
+	if sameSide(z, 4, 1) {
+		violations = append(violations, Rule41s)
+	}
+	if sameSide(z, 10, 0) {
+		violations = append(violations, Rule10x)
+	}
+	return violations
+}
+
+// sameSide reports whether the last n z-scores all lie beyond limit on the same
+// side of the mean
+func sameSide(z []float64, n int, limit float64) bool {
+	if len(z) < n {
+		return false
+	}
+	above, below := true, true
+	for _, v := range z[len(z)-n:] {
+		above = above && v > limit
+		below = below && v < -limit
+	}
+	return above || below
+}
+
+// Statistics returns the mean and sample standard deviation of values
+func Statistics(values []float64) (mean, sd float64) {
+	if len(values) == 0 {
+		return 0, 0
+	}
+	for _, v := range values {
+		mean += v
+	}
+	mean /= float64(len(values))
+	if len(values) < 2 {
+		return mean, 0
+	}
+	for _, v := range values {
+		sd += (v - mean) * (v - mean)
+	}
+	return mean, math.Sqrt(sd / float64(len(values)-1))
+}
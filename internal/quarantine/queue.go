@@ -0,0 +1,533 @@
+// Package quarantine keeps inbound messages that fail validation instead of
+// dropping them. A quarantined message is stored with its request and error; an
+// admin can correct it with mapping hints and reprocess it, which resubmits it on
+// the integration's behalf, or discard it.
+package quarantine
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/inbound"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/validation"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Header names the quarantined message on the response of a rejected request, so
+// the integration can refer to it
+const Header = "X-Quarantine-Id"
+
+// quarantinedStatuses are the responses that reject the message itself; other
+// failures, such as authorization or server errors, are for its sender to retry
+var quarantinedStatuses = map[int]bool{
+	http.StatusBadRequest:          true,
+	http.StatusNotFound:            true,
+	http.StatusConflict:            true,
+	http.StatusUnprocessableEntity: true,
+}
+
+// forwardedHeaders are the request headers kept with a message and resent when it
+// is reprocessed
+var forwardedHeaders = []string{validation.TenantHeader, inbound.FacilityHeader}
+
+// maxResponseBytes bounds the part of a response read for its error
+const maxResponseBytes = 64 << 10
+
+// purgeInterval is how often resolved messages are purged
+const purgeInterval = time.Hour
+
+// staleAfter is how long a message may stay claimed for reprocessing before it is
+// released, in case the instance reprocessing it stopped
+const staleAfter = 5 * time.Minute
+
+// ErrNotQuarantined is returned for a message that is no longer waiting for review
+var ErrNotQuarantined = errors.New("message is not quarantined")
+
+// ErrCredentialUnavailable is returned when the credential a message was signed
+// with can no longer sign its resubmission
+var ErrCredentialUnavailable = errors.New("integration credential is revoked or missing")
+
+// HintError reports mapping hints that cannot be applied to a message
+type HintError struct {
+	Reason string
+}
+
+func (e *HintError) Error() string {
+	return e.Reason
+}
+
+// reprocessKey marks the context of a resubmitted message, which is not
+// quarantined again
+type reprocessKey struct{}
+
+// Queue quarantines rejected inbound messages and reprocesses them
+type Queue struct {
+	db        *gorm.DB
+	verifier  *inbound.Verifier
+	retention time.Duration
+	handler   http.Handler
+}
+
+// NewQueue creates a new quarantine queue. Reprocessed and discarded messages are
+// removed once they were resolved longer than the retention ago.
+func NewQueue(db *gorm.DB, verifier *inbound.Verifier, retention time.Duration) *Queue {
+	return &Queue{db: db, verifier: verifier, retention: retention}
+}
+
+// SetHandler sets the handler messages are resubmitted to, normally the router
+// serving the inbound routes
+func (q *Queue) SetHandler(handler http.Handler) {
+	q.handler = handler
+}
+
+// Run releases stale claims and purges resolved messages until the context is
+// cancelled
+func (q *Queue) Run(ctx context.Context) {
+	ticker := time.NewTicker(purgeInterval)
+	defer ticker.Stop()
+
+	for {
+		db := q.db.WithContext(ctx)
+		if err := db.Model(&models.QuarantinedMessage{}).
+			Where("status = ? AND last_attempt_at < ?", models.QuarantineReprocessing, time.Now().Add(-staleAfter)).
+			Update("status", models.QuarantineHeld).Error; err != nil {
+			logger.Warn("Failed to release quarantined messages", zap.Error(err))
+		}
+		result := db.Where("resolved_at < ?", time.Now().Add(-q.retention)).Delete(&models.QuarantinedMessage{})
+		if result.Error != nil {
+			logger.Warn("Failed to purge quarantined messages", zap.Error(result.Error))
+		} else if result.RowsAffected > 0 {
+			logger.Info("Purged quarantined messages", zap.Int64("messages", result.RowsAffected))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Middleware quarantines the messages the inbound routes reject. It must follow
+// the verifier's middleware, which has read and bounded the body.
+func (q *Queue) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if c.Request.Context().Value(reprocessKey{}) != nil {
+			c.Next()
+			return
+		}
+
+		var body []byte
+		if c.Request.Body != nil {
+			read, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				c.Next()
+				return
+			}
+			body = read
+			c.Request.Body = io.NopCloser(bytes.NewReader(body))
+		}
+
+		writer := &responseWriter{ResponseWriter: c.Writer, id: uuid.New().String()}
+		c.Writer = writer
+		c.Next()
+
+		if c.FullPath() == "" || !writer.quarantined {
+			return
+		}
+		credentialID := c.GetString(inbound.CredentialKey)
+		userID, _ := auth.GetUserID(c)
+		code, message := responseError(writer.body.Bytes())
+		var headers map[string]string
+		for _, name := range forwardedHeaders {
+			if value := c.Request.Header.Get(name); value != "" {
+				if headers == nil {
+					headers = make(map[string]string)
+				}
+				headers[name] = value
+			}
+		}
+		msg := models.QuarantinedMessage{
+			ID:           writer.id,
+			CredentialID: credentialID,
+			UserID:       userID,
+			Method:       c.Request.Method,
+			URI:          c.Request.URL.RequestURI(),
+			Route:        c.FullPath(),
+			ContentType:  c.Request.Header.Get("Content-Type"),
+			Headers:      headers,
+			Body:         string(body),
+			StatusCode:   writer.Status(),
+			ErrorCode:    code,
+			Error:        message,
+		}
+		// Kept even when the client went away
+		if err := q.db.Create(&msg).Error; err != nil {
+			logger.Error("Failed to quarantine inbound message",
+				zap.String("credential_id", credentialID),
+				zap.String("route", msg.Route),
+				zap.Error(err),
+			)
+			return
+		}
+		logger.LogAuditEvent("quarantine", "QuarantinedMessage", userID, map[string]interface{}{
+			"message_id":    msg.ID,
+			"credential_id": credentialID,
+			"route":         msg.Route,
+			"error_code":    code,
+		})
+	}
+}
+
+// SetHints replaces the mapping hints of a quarantined message. The hints must
+// apply to its body.
+func (q *Queue) SetHints(ctx context.Context, id string, hints map[string]interface{}) (*models.QuarantinedMessage, error) {
+	msg, err := q.held(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := applyHints(msg.ContentType, []byte(msg.Body), hints); err != nil {
+		return nil, err
+	}
+	result := q.db.WithContext(ctx).Model(msg).Where("status = ?", models.QuarantineHeld).
+		Select("mapping_hints").Updates(&models.QuarantinedMessage{MappingHints: hints})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotQuarantined
+	}
+	msg.MappingHints = hints
+	return msg, nil
+}
+
+// Reprocess resubmits a quarantined message with its mapping hints applied, signed
+// with the credential it was received with. The message is resolved when the
+// resubmission is accepted and keeps the new error otherwise.
+func (q *Queue) Reprocess(ctx context.Context, id, userID string) (*models.QuarantinedMessage, error) {
+	msg, err := q.held(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	body, err := applyHints(msg.ContentType, []byte(msg.Body), msg.MappingHints)
+	if err != nil {
+		return nil, err
+	}
+
+	// Claim the message so it is not resubmitted twice at once
+	now := time.Now()
+	db := q.db.WithContext(ctx)
+	result := db.Model(&models.QuarantinedMessage{}).
+		Where("id = ? AND status = ?", id, models.QuarantineHeld).
+		Updates(map[string]interface{}{
+			"status":          models.QuarantineReprocessing,
+			"attempts":        gorm.Expr("attempts + 1"),
+			"last_attempt_at": now,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotQuarantined
+	}
+	msg.Attempts++
+	msg.LastAttemptAt = &now
+
+	recorder, err := q.resubmit(ctx, msg, body)
+	if err != nil {
+		// Released for another attempt
+		if releaseErr := db.Model(msg).Update("status", models.QuarantineHeld).Error; releaseErr != nil {
+			logger.Warn("Failed to release quarantined message", zap.String("message_id", id), zap.Error(releaseErr))
+		}
+		return nil, err
+	}
+
+	updates := map[string]interface{}{"status_code": recorder.status}
+	if recorder.status >= 200 && recorder.status < 300 {
+		var created struct {
+			ID string `json:"id"`
+		}
+		_ = json.Unmarshal(recorder.body.Bytes(), &created)
+		resolvedAt := time.Now()
+		msg.Status = models.QuarantineReprocessed
+		msg.ResourceID = created.ID
+		msg.ResolvedAt = &resolvedAt
+		msg.ResolvedBy = userID
+		updates["resource_id"] = created.ID
+		updates["resolved_at"] = resolvedAt
+		updates["resolved_by"] = userID
+	} else {
+		msg.Status = models.QuarantineHeld
+		msg.ErrorCode, msg.Error = responseError(recorder.body.Bytes())
+		updates["error_code"] = msg.ErrorCode
+		updates["error"] = msg.Error
+	}
+	msg.StatusCode = recorder.status
+	updates["status"] = msg.Status
+	if err := db.Model(msg).Updates(updates).Error; err != nil {
+		return nil, fmt.Errorf("failed to record reprocessing: %w", err)
+	}
+	return msg, nil
+}
+
+// Discard resolves a quarantined message without reprocessing it
+func (q *Queue) Discard(ctx context.Context, id, userID string) (*models.QuarantinedMessage, error) {
+	msg, err := q.held(ctx, id)
+	if err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	result := q.db.WithContext(ctx).Model(&models.QuarantinedMessage{}).
+		Where("id = ? AND status = ?", id, models.QuarantineHeld).
+		Updates(map[string]interface{}{
+			"status":      models.QuarantineDiscarded,
+			"resolved_at": now,
+			"resolved_by": userID,
+		})
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrNotQuarantined
+	}
+	msg.Status = models.QuarantineDiscarded
+	msg.ResolvedAt = &now
+	msg.ResolvedBy = userID
+	return msg, nil
+}
+
+// held loads a message waiting for review. A missing message is reported as
+// gorm.ErrRecordNotFound.
+func (q *Queue) held(ctx context.Context, id string) (*models.QuarantinedMessage, error) {
+	var msg models.QuarantinedMessage
+	if err := q.db.WithContext(ctx).Where("id = ?", id).First(&msg).Error; err != nil {
+		return nil, err
+	}
+	if msg.Status != models.QuarantineHeld {
+		return nil, ErrNotQuarantined
+	}
+	return &msg, nil
+}
+
+// resubmit serves the message's request again as its integration would send it
+func (q *Queue) resubmit(ctx context.Context, msg *models.QuarantinedMessage, body []byte) (*recorder, error) {
+	if q.handler == nil {
+		return nil, errors.New("no handler to reprocess messages with")
+	}
+	req, err := http.NewRequestWithContext(context.WithValue(ctx, reprocessKey{}, msg.ID), msg.Method, msg.URI, bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to build request: %w", err)
+	}
+	if msg.ContentType != "" {
+		req.Header.Set("Content-Type", msg.ContentType)
+	}
+	for name, value := range msg.Headers {
+		req.Header.Set(name, value)
+	}
+	if err := q.verifier.SignRequest(ctx, req, msg.CredentialID, body); err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return nil, ErrCredentialUnavailable
+		}
+		return nil, err
+	}
+
+	rec := &recorder{header: make(http.Header)}
+	q.handler.ServeHTTP(rec, req)
+	if rec.status == 0 {
+		rec.status = http.StatusOK
+	}
+	return rec, nil
+}
+
+// applyHints sets the values of the hints at their dotted paths of a JSON body.
+// Numeric path segments index arrays; a null value removes the field.
+func applyHints(contentType string, body []byte, hints map[string]interface{}) ([]byte, error) {
+	if len(hints) == 0 {
+		return body, nil
+	}
+	if contentType != "" && !strings.Contains(contentType, "json") {
+		return nil, &HintError{Reason: "mapping hints only apply to JSON messages"}
+	}
+	var doc interface{}
+	if err := json.Unmarshal(body, &doc); err != nil {
+		return nil, &HintError{Reason: "message body is not valid JSON"}
+	}
+
+	paths := make([]string, 0, len(hints))
+	for path := range hints {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	for _, path := range paths {
+		updated, err := setPath(doc, strings.Split(path, "."), hints[path])
+		if err != nil {
+			return nil, &HintError{Reason: fmt.Sprintf("%s: %s", path, err.Error())}
+		}
+		doc = updated
+	}
+	return json.Marshal(doc)
+}
+
+// setPath returns the node with the value set at the path below it
+func setPath(node interface{}, path []string, value interface{}) (interface{}, error) {
+	if len(path) == 0 {
+		return value, nil
+	}
+	segment := path[0]
+	if segment == "" {
+		return nil, errors.New("empty path segment")
+	}
+
+	switch n := node.(type) {
+	case nil:
+		return setPath(map[string]interface{}{}, path, value)
+	case map[string]interface{}:
+		if len(path) == 1 && value == nil {
+			delete(n, segment)
+			return n, nil
+		}
+		child, err := setPath(n[segment], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[segment] = child
+		return n, nil
+	case []interface{}:
+		index, err := strconv.Atoi(segment)
+		if err != nil || index < 0 || index >= len(n) {
+			return nil, fmt.Errorf("no array element %s", segment)
+		}
+		if len(path) == 1 && value == nil {
+			return append(n[:index], n[index+1:]...), nil
+		}
+		child, err := setPath(n[index], path[1:], value)
+		if err != nil {
+			return nil, err
+		}
+		n[index] = child
+		return n, nil
+	default:
+		return nil, fmt.Errorf("%s is not an object or array", segment)
+	}
+}
+
+// responseError returns the code and description of an error response, which FHIR
+// requests get as an OperationOutcome
+func responseError(body []byte) (string, string) {
+	var resp struct {
+		Error   string `json:"error"`
+		Message string `json:"message"`
+		Code    string `json:"code"`
+		Issue   []struct {
+			Details *struct {
+				Coding []struct {
+					Code string `json:"code"`
+				} `json:"coding"`
+			} `json:"details"`
+			Diagnostics string `json:"diagnostics"`
+		} `json:"issue"`
+	}
+	if err := json.Unmarshal(body, &resp); err != nil {
+		return "", strings.TrimSpace(string(body))
+	}
+	if len(resp.Issue) > 0 {
+		var code string
+		if details := resp.Issue[0].Details; details != nil && len(details.Coding) > 0 {
+			code = details.Coding[0].Code
+		}
+		diagnostics := make([]string, 0, len(resp.Issue))
+		for _, issue := range resp.Issue {
+			diagnostics = append(diagnostics, issue.Diagnostics)
+		}
+		return code, strings.Join(diagnostics, "; ")
+	}
+	if resp.Message != "" && resp.Message != resp.Error {
+		return resp.Code, resp.Error + ": " + resp.Message
+	}
+	return resp.Code, resp.Error
+}
+
+// responseWriter names the quarantined message on a rejecting response and keeps
+// the start of its body
+type responseWriter struct {
+	gin.ResponseWriter
+	id          string
+	quarantined bool
+	body        bytes.Buffer
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if quarantinedStatuses[code] && !w.Written() {
+		w.quarantined = true
+		w.Header().Set(Header, w.id)
+	} else if !w.Written() {
+		w.quarantined = false
+		w.Header().Del(Header)
+	}
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(data []byte) (int, error) {
+	w.keep(data)
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *responseWriter) WriteString(s string) (int, error) {
+	w.keep([]byte(s))
+	return w.ResponseWriter.WriteString(s)
+}
+
+// keep appends data to the kept body of a rejecting response up to its limit
+func (w *responseWriter) keep(data []byte) {
+	if !w.quarantined {
+		return
+	}
+	if room := maxResponseBytes - w.body.Len(); len(data) > room {
+		data = data[:room]
+	}
+	w.body.Write(data)
+}
+
+// recorder keeps the response to a resubmitted message
+type recorder struct {
+	header http.Header
+	status int
+	body   bytes.Buffer
+}
+
+func (r *recorder) Header() http.Header {
+	return r.header
+}
+
+func (r *recorder) WriteHeader(code int) {
+	if r.status == 0 {
+		r.status = code
+	}
+}
+
+func (r *recorder) Write(data []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	if room := maxResponseBytes - r.body.Len(); len(data) > room {
+		r.body.Write(data[:room])
+	} else {
+		r.body.Write(data)
+	}
+	return len(data), nil
+}
@@ -0,0 +1,160 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+)
+
+// holds lists the resources under an active legal hold
+type holds struct {
+	patients            []string // Patients on hold themselves
+	observations        []string
+	observationPatients []string            // Patients of held observations, kept since purging a patient removes its observations
+	records             map[string][]string // Held Records by resource type
+	recordPatients      []string            // Patients of held Records, kept since purging a patient removes its records
+}
+
+// activeHolds reads the resources under an active legal hold
+func activeHolds(db *gorm.DB) (holds, error) {
+	held := holds{records: make(map[string][]string)}
+	var active []models.LegalHold
+	if err := db.Where("released_at IS NULL").Find(&active).Error; err != nil {
+		return held, fmt.Errorf("failed to find legal holds: %w", err)
+	}
+	for i := range active {
+		switch active[i].ResourceType {
+		case "Patient":
+			held.patients = append(held.patients, active[i].ResourceID)
+		case "Observation":
+			held.observations = append(held.observations, active[i].ResourceID)
+		default:
+			held.records[active[i].ResourceType] = append(held.records[active[i].ResourceType], active[i].ResourceID)
+		}
+	}
+
+	if len(held.observations) > 0 {
+		var observations []models.Observation
+		if err := db.Unscoped().Select("id", "subject").Where("id IN ?", held.observations).Find(&observations).Error; err != nil {
+			return held, fmt.Errorf("failed to find held observations: %w", err)
+		}
+		for i := range observations {
+			held.observationPatients = append(held.observationPatients, strings.TrimPrefix(observations[i].Subject.Reference, "Patient/"))
+		}
+	}
+
+	for _, record := range Records {
+		ids := held.records[record.ResourceType]
+		if len(ids) == 0 {
+			continue
+		}
+		var refs []string
+		if err := db.Unscoped().Model(record.New()).Where("id IN ?", ids).Pluck(record.Column, &refs).Error; err != nil {
+			return held, fmt.Errorf("failed to find held %s records: %w", record.ResourceType, err)
+		}
+		for _, ref := range refs {
+			held.recordPatients = append(held.recordPatients, strings.TrimPrefix(ref, "Patient/"))
+		}
+	}
+	return held, nil
+}
+
+// keepPatients leaves held patients out of a query on patients
+func (h holds) keepPatients(query *gorm.DB) *gorm.DB {
+	ids := append(append(append([]string{}, h.patients...), h.observationPatients...), h.recordPatients...)
+	if len(ids) > 0 {
+		query = query.Where("id NOT IN ?", ids)
+	}
+	return query
+}
+
+// keepObservations leaves held observations, and those of held patients, out of a
+// query on observations
+func (h holds) keepObservations(query *gorm.DB) *gorm.DB {
+	if len(h.observations) > 0 {
+		query = query.Where("id NOT IN ?", h.observations)
+	}
+	if len(h.patients) > 0 {
+		refs := make([]string, len(h.patients))
+		for i, id := range h.patients {
+			refs[i] = "Patient/" + id
+		}
+		query = query.Where(dialect.Of(query).JSONText("subject", "reference")+" NOT IN ?", refs)
+	}
+	return query
+}
+
+// keepRecords leaves held records, and those of held patients, out of a query on a
+// kind of record
+func (h holds) keepRecords(query *gorm.DB, record Record) *gorm.DB {
+	if ids := h.records[record.ResourceType]; len(ids) > 0 {
+		query = query.Where("id NOT IN ?", ids)
+	}
+	if len(h.patients) > 0 {
+		refs := make([]string, len(h.patients))
+		for i, id := range h.patients {
+			refs[i] = "Patient/" + id
+		}
+		query = query.Where(record.Column+" NOT IN ?", refs)
+	}
+	return query
+}
+
+// Held reports which of the given patients, observations or Records are kept from
+// purging by a legal hold
+func (p *Purger) Held(ctx context.Context, resourceType string, ids []string) (map[string]bool, error) {
+	return Held(p.db.WithContext(ctx), resourceType, ids)
+}
+
+// Held reports which of the given patients, observations or Records are kept by a
+// legal hold. Records that are removed for good rather than deleted into the trash
+// check it before their delete.
+func Held(db *gorm.DB, resourceType string, ids []string) (map[string]bool, error) {
+	held := make(map[string]bool)
+	if len(ids) == 0 {
+		return held, nil
+	}
+	active, err := activeHolds(db)
+	if err != nil {
+		return nil, err
+	}
+
+	var model interface{}
+	keep := active.keepPatients
+	switch resourceType {
+	case "Patient":
+		model = &models.Patient{}
+	case "Observation":
+		model = &models.Observation{}
+		keep = active.keepObservations
+	default:
+		record, ok := RecordOf(resourceType)
+		if !ok {
+			return held, nil
+		}
+		model = record.New()
+		keep = func(query *gorm.DB) *gorm.DB { return active.keepRecords(query, record) }
+	}
+	var found, free []string
+	if err := db.Unscoped().Model(model).Where("id IN ?", ids).Pluck("id", &found).Error; err != nil {
+		return nil, fmt.Errorf("failed to check legal holds: %w", err)
+	}
+	if err := keep(db.Unscoped().Model(model).Where("id IN ?", ids)).Pluck("id", &free).Error; err != nil {
+		return nil, fmt.Errorf("failed to check legal holds: %w", err)
+	}
+
+	purgeable := make(map[string]bool, len(free))
+	for _, id := range free {
+		purgeable[id] = true
+	}
+	for _, id := range found {
+		if !purgeable[id] {
+			held[id] = true
+		}
+	}
+	return held, nil
+}
@@ -0,0 +1,331 @@
+package retention
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// purgeBatchSize bounds how many deleted patients or records are finalized per
+// transaction
+const purgeBatchSize = 100
+
+// Purger permanently removes resources whose undo window has passed
+type Purger struct {
+	db       *gorm.DB
+	storage  storage.Storage
+	window   time.Duration
+	interval time.Duration
+}
+
+// NewPurger creates a new purger for the given undo window. The stored files of
+// purged media and documents are removed from store.
+func NewPurger(db *gorm.DB, store storage.Storage, window time.Duration) *Purger {
+	return &Purger{
+		db:       db,
+		storage:  store,
+		window:   window,
+		interval: time.Minute,
+	}
+}
+
+// Purged counts the resources a purge removed
+type Purged struct {
+	Patients     int64 `json:"patients"`
+	Observations int64 `json:"observations"`
+	Records      int64 `json:"records"` // Conditions, allergies, coverages and the other Records
+}
+
+// add adds the counts of another purge
+func (p *Purged) add(other Purged) {
+	p.Patients += other.Patients
+	p.Observations += other.Observations
+	p.Records += other.Records
+}
+
+// Run purges expired deletions until the context is cancelled
+func (p *Purger) Run(ctx context.Context) {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		purged, err := p.Purge(ctx)
+		if err != nil {
+			logger.Warn("Failed to purge deleted resources", zap.Error(err))
+		} else if purged != (Purged{}) {
+			logger.Info("Purged deleted resources",
+				zap.Int64("patients", purged.Patients),
+				zap.Int64("observations", purged.Observations),
+				zap.Int64("records", purged.Records),
+			)
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Purge permanently removes patients, observations and records deleted before the undo
+// window and returns how many were removed. Resources under a legal hold are kept.
+func (p *Purger) Purge(ctx context.Context) (Purged, error) {
+	cutoff := time.Now().Add(-p.window)
+	db := p.db.WithContext(ctx)
+	var purged Purged
+
+	for {
+		held, err := activeHolds(db)
+		if err != nil {
+			return purged, err
+		}
+		var ids []string
+		if err := held.keepPatients(db.Unscoped().Model(&models.Patient{})).
+			Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+			Limit(purgeBatchSize).Pluck("id", &ids).Error; err != nil {
+			return purged, fmt.Errorf("failed to find deleted patients: %w", err)
+		}
+		if len(ids) == 0 {
+			break
+		}
+
+		removed, err := p.PurgePatients(ctx, ids)
+		if err != nil {
+			return purged, err
+		}
+		purged.add(removed)
+	}
+
+	held, err := activeHolds(db)
+	if err != nil {
+		return purged, err
+	}
+	result := held.keepObservations(db.Unscoped().Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff)).Delete(&models.Observation{})
+	if result.Error != nil {
+		return purged, fmt.Errorf("failed to purge deleted observations: %w", result.Error)
+	}
+	purged.Observations += result.RowsAffected
+
+	for _, record := range Records {
+		if !record.Trash {
+			continue
+		}
+		for {
+			var ids []string
+			if err := held.keepRecords(db.Unscoped().Model(record.New()), record).
+				Where("deleted_at IS NOT NULL AND deleted_at < ?", cutoff).
+				Limit(purgeBatchSize).Pluck("id", &ids).Error; err != nil {
+				return purged, fmt.Errorf("failed to find deleted %s records: %w", record.ResourceType, err)
+			}
+			removed, err := p.PurgeRecords(ctx, record.ResourceType, ids)
+			if err != nil {
+				return purged, err
+			}
+			purged.Records += removed
+			// Nothing is removed once none are left, or when those found were placed
+			// under a hold meanwhile
+			if removed == 0 {
+				break
+			}
+		}
+	}
+
+	return purged, nil
+}
+
+// PurgePatients permanently removes deleted patients with their observations, links
+// and Records in one transaction, without waiting for the undo window. Patients that
+// are not deleted or are under a legal hold are left alone.
+func (p *Purger) PurgePatients(ctx context.Context, ids []string) (Purged, error) {
+	var purged Purged
+	var files []string
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		held, err := activeHolds(tx)
+		if err != nil {
+			return err
+		}
+		if err := held.keepPatients(tx.Unscoped().Model(&models.Patient{})).
+			Where("id IN ? AND deleted_at IS NOT NULL", ids).Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to find deleted patients: %w", err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		refs := make([]string, len(ids))
+		for i, id := range ids {
+			refs[i] = "Patient/" + id
+		}
+
+		result := tx.Unscoped().Where(dialect.Of(tx).JSONText("subject", "reference")+" IN ?", refs).Delete(&models.Observation{})
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge patient observations: %w", result.Error)
+		}
+		purged.Observations = result.RowsAffected
+
+		// Records the patients deleted on their own go as well as those deleted with them
+		for _, record := range Records {
+			query := func() *gorm.DB {
+				return tx.Unscoped().Model(record.New()).Where(record.Column+" IN ?", refs)
+			}
+			found, err := storedFiles(query, record)
+			if err != nil {
+				return err
+			}
+			files = append(files, found...)
+
+			result := query().Delete(record.New())
+			if result.Error != nil {
+				return fmt.Errorf("failed to purge patient %s records: %w", record.ResourceType, result.Error)
+			}
+			purged.Records += result.RowsAffected
+		}
+
+		if err := tx.Where("patient_id IN ? OR other_id IN ?", ids, ids).Delete(&models.PatientLink{}).Error; err != nil {
+			return fmt.Errorf("failed to purge patient links: %w", err)
+		}
+
+		if err := tx.Unscoped().Where("id IN ?", ids).Delete(&models.Patient{}).Error; err != nil {
+			return fmt.Errorf("failed to purge patients: %w", err)
+		}
+		purged.Patients = int64(len(ids))
+		return nil
+	})
+	if err != nil {
+		return Purged{}, err
+	}
+	p.removeFiles(files)
+	return purged, nil
+}
+
+// PurgeObservations permanently removes deleted observations without waiting for the
+// undo window. Observations that are not deleted or are under a legal hold are left
+// alone.
+func (p *Purger) PurgeObservations(ctx context.Context, ids []string) (int64, error) {
+	db := p.db.WithContext(ctx)
+	held, err := activeHolds(db)
+	if err != nil {
+		return 0, err
+	}
+	result := held.keepObservations(db.Unscoped().Where("id IN ? AND deleted_at IS NOT NULL", ids)).Delete(&models.Observation{})
+	if result.Error != nil {
+		return 0, fmt.Errorf("failed to purge observations: %w", result.Error)
+	}
+	return result.RowsAffected, nil
+}
+
+// PurgeRecords permanently removes deleted records of a resource type without waiting
+// for the undo window, along with their stored files. Records that are not deleted or
+// whose patient is under a legal hold are left alone; purged media are detached from
+// the observations showing them.
+func (p *Purger) PurgeRecords(ctx context.Context, resourceType string, ids []string) (int64, error) {
+	record, ok := RecordOf(resourceType)
+	if !ok || !record.Trash || len(ids) == 0 {
+		return 0, nil
+	}
+
+	var removed int64
+	var files []string
+	err := p.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		held, err := activeHolds(tx)
+		if err != nil {
+			return err
+		}
+		if err := held.keepRecords(tx.Unscoped().Model(record.New()), record).
+			Where("id IN ? AND deleted_at IS NOT NULL", ids).Pluck("id", &ids).Error; err != nil {
+			return fmt.Errorf("failed to find deleted %s records: %w", resourceType, err)
+		}
+		if len(ids) == 0 {
+			return nil
+		}
+
+		query := func() *gorm.DB {
+			return tx.Unscoped().Model(record.New()).Where("id IN ?", ids)
+		}
+		if files, err = storedFiles(query, record); err != nil {
+			return err
+		}
+		if resourceType == "Media" {
+			if err := detachMedia(tx, ids); err != nil {
+				return err
+			}
+		}
+
+		result := query().Delete(record.New())
+		if result.Error != nil {
+			return fmt.Errorf("failed to purge %s records: %w", resourceType, result.Error)
+		}
+		removed = result.RowsAffected
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	p.removeFiles(files)
+	return removed, nil
+}
+
+// storedFiles returns the storage keys of the files of the records a query selects
+func storedFiles(query func() *gorm.DB, record Record) ([]string, error) {
+	var files []string
+	for _, column := range record.Files {
+		var keys []string
+		if err := query().Where(column+" <> ''").Pluck(column, &keys).Error; err != nil {
+			return nil, fmt.Errorf("failed to find stored %s files: %w", record.ResourceType, err)
+		}
+		files = append(files, keys...)
+	}
+	return files, nil
+}
+
+// detachMedia clears the valueAttachment of observations that show purged media
+func detachMedia(tx *gorm.DB, ids []string) error {
+	urls := make([]string, len(ids))
+	for i, id := range ids {
+		urls[i] = "Media/" + id
+	}
+
+	var observations []string
+	if err := tx.Unscoped().Model(&models.Observation{}).Where("value_attachment_url IN ?", urls).
+		Pluck("id", &observations).Error; err != nil {
+		return fmt.Errorf("failed to find observations of purged media: %w", err)
+	}
+	if len(observations) == 0 {
+		return nil
+	}
+	if err := tx.Unscoped().Model(&models.Observation{}).Where("id IN ?", observations).
+		UpdateColumns(map[string]interface{}{
+			"value_attachment_content_type": "",
+			"value_attachment_language":     "",
+			"value_attachment_url":          "",
+			"value_attachment_size":         0,
+			"value_attachment_hash":         "",
+			"value_attachment_title":        "",
+			"value_attachment_creation":     nil,
+		}).Error; err != nil {
+		return fmt.Errorf("failed to detach purged media: %w", err)
+	}
+	// The batch update bypasses the observation hooks, so publish the changes here
+	return models.RecordEvents(tx, "Observation", observations, models.EventActionUpdated)
+}
+
+// removeFiles deletes the stored files of purged records, logging failures; the
+// records are gone, so a file left behind is only wasted space
+func (p *Purger) removeFiles(keys []string) {
+	if p.storage == nil {
+		return
+	}
+	for _, key := range keys {
+		if err := p.storage.Delete(key); err != nil && err != storage.ErrNotFound {
+			logger.Warn("Failed to delete stored file of a purged record", zap.String("key", key), zap.Error(err))
+		}
+	}
+}
@@ -0,0 +1,191 @@
+package retention
+
+import (
+	"context"
+	"errors"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/storage"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// openTestDB migrates a fresh SQLite database
+func openTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+	db, err := database.Open("sqlite://" + filepath.Join(t.TempDir(), "healthhub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { database.CloseDB(db) })
+	db.Logger = logger.Discard
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// TestPurgePatients checks that purging a patient removes every record kept against
+// them, with the stored files, and leaves other patients' records alone
+func TestPurgePatients(t *testing.T) {
+	db := openTestDB(t)
+	store, err := storage.NewLocalStorage(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	patient := models.Patient{Gender: "female", CreatedBy: "test"}
+	other := models.Patient{Gender: "male", CreatedBy: "test"}
+	for _, p := range []*models.Patient{&patient, &other} {
+		if err := db.Create(p).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, p := range []models.Patient{patient, other} {
+		subject := models.Reference{Reference: "Patient/" + p.ID}
+		key := "media/" + p.ID
+		if _, err := store.Put(key, strings.NewReader("image")); err != nil {
+			t.Fatal(err)
+		}
+		for _, record := range []interface{}{
+			&models.Condition{Subject: subject, CreatedBy: "test"},
+			&models.AllergyIntolerance{Subject: subject, CreatedBy: "test"},
+			&models.Procedure{Subject: subject, CreatedBy: "test"},
+			&models.Coverage{Beneficiary: subject, CreatedBy: "test"},
+			&models.RelatedPerson{Patient: subject, CreatedBy: "test"},
+			&models.Media{Subject: subject, StorageKey: key, CreatedBy: "test"},
+			&models.DocumentReference{Subject: subject, CreatedBy: "test"},
+		} {
+			if err := db.Create(record).Error; err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+
+	// A patient deleted before the undo window, with their records deleted with them
+	deletedAt := gorm.DeletedAt{Time: time.Now().Add(-2 * time.Hour), Valid: true}
+	if err := db.Model(&patient).UpdateColumn("deleted_at", deletedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+	for _, record := range Records {
+		if !record.Trash {
+			continue
+		}
+		if err := db.Model(record.New()).Where(record.Column+" = ?", "Patient/"+patient.ID).
+			UpdateColumn("deleted_at", deletedAt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	purged, err := NewPurger(db, store, time.Hour).Purge(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Purged{Patients: 1, Records: int64(len(Records))}); purged != want {
+		t.Errorf("purged %+v, want %+v", purged, want)
+	}
+
+	for _, record := range Records {
+		for p, want := range map[string]int64{patient.ID: 0, other.ID: 1} {
+			var count int64
+			if err := db.Unscoped().Model(record.New()).Where(record.Column+" = ?", "Patient/"+p).
+				Count(&count).Error; err != nil {
+				t.Fatal(err)
+			}
+			if count != want {
+				t.Errorf("%s records of patient %s: %d, want %d", record.ResourceType, p, count, want)
+			}
+		}
+	}
+
+	if _, err := store.Get("media/" + patient.ID); !errors.Is(err, storage.ErrNotFound) {
+		t.Errorf("stored file of a purged patient was not removed: %v", err)
+	}
+	file, err := store.Get("media/" + other.ID)
+	if err != nil {
+		t.Fatalf("stored file of another patient was removed: %v", err)
+	}
+	file.Close()
+}
+
+// TestPurgeLegalHolds checks that a held record is kept with its patient, and that
+// Held reports it
+func TestPurgeLegalHolds(t *testing.T) {
+	db := openTestDB(t)
+	deletedAt := gorm.DeletedAt{Time: time.Now().Add(-2 * time.Hour), Valid: true}
+
+	patient := models.Patient{Gender: "female", CreatedBy: "test"}
+	if err := db.Create(&patient).Error; err != nil {
+		t.Fatal(err)
+	}
+	subject := models.Reference{Reference: "Patient/" + patient.ID}
+	held := models.Condition{Subject: subject, CreatedBy: "test"}
+	free := models.Procedure{Subject: subject, CreatedBy: "test"}
+	for _, record := range []interface{}{&held, &free} {
+		if err := db.Create(record).Error; err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Model(record).UpdateColumn("deleted_at", deletedAt).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	if err := db.Model(&patient).UpdateColumn("deleted_at", deletedAt).Error; err != nil {
+		t.Fatal(err)
+	}
+	hold := models.LegalHold{ResourceType: "Condition", ResourceID: held.ID, Reason: "test", PlacedAt: time.Now()}
+	if err := db.Create(&hold).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	purger := NewPurger(db, nil, time.Hour)
+	purged, err := purger.Purge(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := (Purged{Records: 1}); purged != want {
+		t.Errorf("purged %+v, want %+v", purged, want)
+	}
+	for _, model := range []interface{}{&models.Patient{}, &models.Condition{}} {
+		var count int64
+		if err := db.Unscoped().Model(model).Count(&count).Error; err != nil {
+			t.Fatal(err)
+		}
+		if count != 1 {
+			t.Errorf("%T: %d left, want the held one", model, count)
+		}
+	}
+
+	for resourceType, id := range map[string]string{"Condition": held.ID, "Patient": patient.ID} {
+		found, err := purger.Held(context.Background(), resourceType, []string{id})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !found[id] {
+			t.Errorf("%s %s is not reported held", resourceType, id)
+		}
+	}
+	found, err := purger.Held(context.Background(), "Procedure", []string{free.ID})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(found) != 0 {
+		t.Errorf("purged procedure reported held: %v", found)
+	}
+
+	now := time.Now()
+	if err := db.Model(&hold).UpdateColumn("released_at", &now).Error; err != nil {
+		t.Fatal(err)
+	}
+	if purged, err = purger.Purge(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if want := (Purged{Patients: 1, Records: 1}); purged != want {
+		t.Errorf("purged %+v once released, want %+v", purged, want)
+	}
+}
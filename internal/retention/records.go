@@ -0,0 +1,48 @@
+package retention
+
+import (
+	"reflect"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+)
+
+// Record is a kind of record kept against a patient besides observations and links,
+// purged together with the patient
+type Record struct {
+	ResourceType string
+	Model        interface{} // An empty model, used only for its type
+	Column       string      // Column holding the "Patient/{id}" reference
+	// Deleted into the trash, so a delete can be undone until the undo window passes
+	// and a patient's delete takes the records with it
+	Trash bool
+	Files []string // Columns holding the storage keys of stored files
+}
+
+// Records are the records of a patient the purge removes with them
+var Records = []Record{
+	{ResourceType: "Condition", Model: &models.Condition{}, Column: "subject_reference", Trash: true},
+	{ResourceType: "AllergyIntolerance", Model: &models.AllergyIntolerance{}, Column: "subject_reference", Trash: true},
+	{ResourceType: "Procedure", Model: &models.Procedure{}, Column: "subject_reference", Trash: true},
+	{ResourceType: "Coverage", Model: &models.Coverage{}, Column: "beneficiary_reference", Trash: true},
+	{ResourceType: "Media", Model: &models.Media{}, Column: "subject_reference", Trash: true,
+		Files: []string{"storage_key", "thumbnail_key"}},
+	{ResourceType: "DocumentReference", Model: &models.DocumentReference{}, Column: "subject_reference", Trash: true,
+		Files: []string{"storage_key"}},
+	{ResourceType: "RelatedPerson", Model: &models.RelatedPerson{}, Column: "patient_reference"},
+}
+
+// RecordOf returns the record of a resource type
+func RecordOf(resourceType string) (Record, bool) {
+	for _, record := range Records {
+		if record.ResourceType == resourceType {
+			return record, true
+		}
+	}
+	return Record{}, false
+}
+
+// New returns a new empty model of the record's type. Queries take a model of their
+// own, since gorm writes the values of an update into it.
+func (r Record) New() interface{} {
+	return reflect.New(reflect.TypeOf(r.Model).Elem()).Interface()
+}
@@ -0,0 +1,229 @@
+// Package standingorder generates the observation slots expected by standing orders
+// and matches recorded observations against them.
+package standingorder
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+const (
+	// horizon is how far ahead slots are generated, so wards can see what is due
+	horizon = 48 * time.Hour
+
+	// batchSize bounds how many slots are reconciled per pass
+	batchSize = 500
+
+	// maxTolerance is the largest tolerance an order may set
+	maxTolerance = 12 * time.Hour
+
+	// lateEntryWindow is how long after their due time missed slots are still
+	// collected by observations charted late
+	lateEntryWindow = 24 * time.Hour
+)
+
+// Scheduler keeps the observation slots of standing orders up to date
+type Scheduler struct {
+	db       *gorm.DB
+	interval time.Duration
+	wake     chan struct{}
+}
+
+// NewScheduler creates a new standing order scheduler
+func NewScheduler(db *gorm.DB) *Scheduler {
+	return &Scheduler{
+		db:       db,
+		interval: time.Minute,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Wake signals the scheduler that an order was created or changed
+func (s *Scheduler) Wake() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Run generates and reconciles slots until the context is cancelled
+func (s *Scheduler) Run(ctx context.Context) {
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+
+	for {
+		now := time.Now().UTC()
+		if err := s.Plan(ctx, now); err != nil {
+			logger.Error("Failed to generate observation slots", zap.Error(err))
+		}
+		if err := s.Reconcile(ctx, now); err != nil {
+			logger.Error("Failed to reconcile observation slots", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// Plan generates the slots of every active standing order up to the horizon
+func (s *Scheduler) Plan(ctx context.Context, now time.Time) error {
+	var orders []models.StandingOrder
+	if err := s.db.WithContext(ctx).Where("active = ?", true).
+		Where("scheduled_until IS NULL OR scheduled_until < ?", now.Add(horizon)).
+		Find(&orders).Error; err != nil {
+		return fmt.Errorf("failed to load standing orders: %w", err)
+	}
+
+	for i := range orders {
+		if err := s.plan(ctx, &orders[i], now.Add(horizon)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// plan generates the slots of an order from where it was scheduled until
+func (s *Scheduler) plan(ctx context.Context, order *models.StandingOrder, until time.Time) error {
+	from := order.StartsAt
+	if order.ScheduledUntil != nil {
+		from = *order.ScheduledUntil
+	}
+
+	code, display := "", order.Code.Text
+	if len(order.Code.Coding) > 0 {
+		code = order.Code.Coding[0].Code
+		if order.Code.Coding[0].Display != "" {
+			display = order.Code.Coding[0].Display
+		}
+	}
+	loc := order.Location()
+
+	var slots []models.ObservationSlot
+	for _, due := range order.DueTimes(from, until) {
+		slots = append(slots, models.ObservationSlot{
+			OrderID:   order.ID,
+			PatientID: strings.TrimPrefix(order.Subject.Reference, "Patient/"),
+			Ward:      order.Ward,
+			Code:      code,
+			Display:   display,
+			DueAt:     due,
+			Date:      due.In(loc).Format("2006-01-02"),
+		})
+	}
+
+	return s.db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		// Slots already generated by an earlier, interrupted pass are kept
+		if len(slots) > 0 {
+			if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&slots, batchSize).Error; err != nil {
+				return fmt.Errorf("failed to create slots of standing order %s: %w", order.ID, err)
+			}
+		}
+		return tx.Model(&models.StandingOrder{}).Where("id = ?", order.ID).
+			Update("scheduled_until", until).Error
+	})
+}
+
+// Reconcile marks pending slots collected when a matching observation was taken
+// within the order's tolerance of the due time, and missed once the tolerance has
+// passed without one. Recently missed slots are still collected by observations
+// charted late.
+func (s *Scheduler) Reconcile(ctx context.Context, now time.Time) error {
+	// Slots further ahead than the largest tolerance cannot be fulfilled yet
+	var slots []models.ObservationSlot
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND due_at <= ?", models.SlotStatusPending, now.Add(maxTolerance)).
+		Order("due_at").Limit(batchSize).Find(&slots).Error; err != nil {
+		return fmt.Errorf("failed to find pending slots: %w", err)
+	}
+	var missed []models.ObservationSlot
+	if err := s.db.WithContext(ctx).
+		Where("status = ? AND due_at >= ?", models.SlotStatusMissed, now.Add(-lateEntryWindow)).
+		Order("due_at").Limit(batchSize).Find(&missed).Error; err != nil {
+		return fmt.Errorf("failed to find missed slots: %w", err)
+	}
+	slots = append(slots, missed...)
+	if len(slots) == 0 {
+		return nil
+	}
+
+	orderIDs := make([]string, 0, len(slots))
+	for _, slot := range slots {
+		orderIDs = append(orderIDs, slot.OrderID)
+	}
+	var orders []models.StandingOrder
+	if err := s.db.WithContext(ctx).Where("id IN ?", orderIDs).Find(&orders).Error; err != nil {
+		return fmt.Errorf("failed to load standing orders: %w", err)
+	}
+	tolerances := make(map[string]time.Duration, len(orders))
+	for i := range orders {
+		tolerances[orders[i].ID] = orders[i].Tolerance()
+	}
+
+	d := dialect.Of(s.db)
+	for _, slot := range slots {
+		tolerance, ok := tolerances[slot.OrderID]
+		if !ok {
+			tolerance = models.DefaultSlotToleranceMinutes * time.Minute
+		}
+		if slot.DueAt.Add(-tolerance).After(now) {
+			continue
+		}
+
+		// The observation closest to the due time that no other slot claimed
+		var observations []models.Observation
+		if err := s.db.WithContext(ctx).Select("id", "effective_date_time").
+			Where(d.JSONText("subject", "reference")+" = ?", "Patient/"+slot.PatientID).
+			Where(d.JSONText("code", "coding", "0", "code")+" = ?", slot.Code).
+			Where("status NOT IN ?", []string{"cancelled", "entered-in-error"}).
+			Where("effective_date_time BETWEEN ? AND ?", slot.DueAt.Add(-tolerance), slot.DueAt.Add(tolerance)).
+			Where("id NOT IN (?)", s.db.Model(&models.ObservationSlot{}).Select("observation_id").Where("observation_id <> ''")).
+			Find(&observations).Error; err != nil {
+			return fmt.Errorf("failed to find observations for slot %s: %w", slot.ID, err)
+		}
+
+		var match *models.Observation
+		for i := range observations {
+			if match == nil || absDuration(observations[i].EffectiveDateTime.Sub(slot.DueAt)) < absDuration(match.EffectiveDateTime.Sub(slot.DueAt)) {
+				match = &observations[i]
+			}
+		}
+
+		updates := map[string]interface{}{}
+		switch {
+		case match != nil:
+			updates["status"] = models.SlotStatusCollected
+			updates["observation_id"] = match.ID
+			updates["collected_at"] = match.EffectiveDateTime
+		case slot.Status == models.SlotStatusPending && now.After(slot.DueAt.Add(tolerance)):
+			updates["status"] = models.SlotStatusMissed
+		default:
+			continue
+		}
+		if err := s.db.WithContext(ctx).Model(&models.ObservationSlot{}).
+			Where("id = ? AND status = ?", slot.ID, slot.Status).
+			Updates(updates).Error; err != nil {
+			return fmt.Errorf("failed to update slot %s: %w", slot.ID, err)
+		}
+	}
+	return nil
+}
+
+func absDuration(d time.Duration) time.Duration {
+	if d < 0 {
+		return -d
+	}
+	return d
+}
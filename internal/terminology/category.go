@@ -0,0 +1,242 @@
+package terminology
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultCategories seeds the category code system with the FHIR observation categories
+var DefaultCategories = []models.ObservationCategory{
+	{Code: "social-history", Display: "Social History", Definition: "Social history observations such as tobacco use, occupation and family support"},
+	{Code: "vital-signs", Display: "Vital Signs", Definition: "Clinical observations measuring the body's basic functions"},
+	{Code: "imaging", Display: "Imaging", Definition: "Observations generated by imaging"},
+	{Code: "laboratory", Display: "Laboratory", Definition: "Results of observations generated by laboratories"},
+	{Code: "procedure", Display: "Procedure", Definition: "Observations generated by other procedures"},
+	{Code: "survey", Display: "Survey", Definition: "Assessment tool and survey instrument observations"},
+	{Code: "exam", Display: "Exam", Definition: "Observations generated by physical examination findings"},
+	{Code: "therapy", Display: "Therapy", Definition: "Observations generated by non-interventional treatment protocols"},
+	{Code: "activity", Display: "Activity", Definition: "Observations that measure or record physical activity"},
+}
+
+// categorySynonyms maps common free-form values found in legacy data to managed codes
+var categorySynonyms = map[string]string{
+	"lab":            "laboratory",
+	"labs":           "laboratory",
+	"lab result":     "laboratory",
+	"vitals":         "vital-signs",
+	"vital signs":    "vital-signs",
+	"vital sign":     "vital-signs",
+	"radiology":      "imaging",
+	"social":         "social-history",
+	"social history": "social-history",
+	"questionnaire":  "survey",
+	"assessment":     "survey",
+	"physical exam":  "exam",
+}
+
+// CategoryService manages the observation category code system and validates categories on write
+type CategoryService struct {
+	db *gorm.DB
+
+	mu     sync.RWMutex
+	active map[string]models.ObservationCategory
+}
+
+// NewCategoryService creates a new category service
+func NewCategoryService(db *gorm.DB) *CategoryService {
+	return &CategoryService{db: db}
+}
+
+// InitializeDefaultCategories inserts any missing default categories and loads the code system
+func (s *CategoryService) InitializeDefaultCategories() error {
+	for _, category := range DefaultCategories {
+		category.Active = true
+		if err := s.db.Clauses(clause.OnConflict{DoNothing: true}).Create(&category).Error; err != nil {
+			return fmt.Errorf("failed to create category %s: %w", category.Code, err)
+		}
+	}
+	return s.Reload()
+}
+
+// Reload refreshes the cached set of active categories
+func (s *CategoryService) Reload() error {
+	var categories []models.ObservationCategory
+	if err := s.db.Where("active = ?", true).Find(&categories).Error; err != nil {
+		return fmt.Errorf("failed to load categories: %w", err)
+	}
+
+	active := make(map[string]models.ObservationCategory, len(categories))
+	for _, category := range categories {
+		active[category.Code] = category
+	}
+
+	s.mu.Lock()
+	s.active = active
+	s.mu.Unlock()
+	return nil
+}
+
+// Validate normalizes categories in place and checks that each one carries an active code
+// from the managed system. Codings from other systems are kept alongside the managed one.
+func (s *CategoryService) Validate(categories []models.Category) error {
+	if len(categories) == 0 {
+		return fmt.Errorf("at least one category is required")
+	}
+
+	for i := range categories {
+		normalized, ok := s.Normalize(categories[i])
+		if !ok {
+			return fmt.Errorf("category %q is not an active code in %s", describeCategory(categories[i]), models.ObservationCategorySystem)
+		}
+		categories[i] = normalized
+	}
+	return nil
+}
+
+// Normalize maps a category to the managed code system. It accepts codings in the managed
+// system, codings without a system and known synonyms in codes, displays or text.
+func (s *CategoryService) Normalize(category models.Category) (models.Category, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	var managed *models.ObservationCategory
+	var others []models.Coding
+
+	candidates := []string{category.Text}
+	for _, coding := range category.Coding {
+		switch coding.System {
+		case models.ObservationCategorySystem, "":
+			candidates = append([]string{coding.Code, coding.Display}, candidates...)
+		default:
+			others = append(others, coding)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if match, ok := s.lookup(candidate); ok {
+			managed = &match
+			break
+		}
+	}
+	if managed == nil {
+		return category, false
+	}
+
+	return models.Category{
+		Coding: append([]models.Coding{managed.Coding()}, others...),
+		Text:   category.Text,
+	}, true
+}
+
+// IsActive reports whether code is an active managed category
+func (s *CategoryService) IsActive(code string) bool {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	_, ok := s.active[code]
+	return ok
+}
+
+// MigrateExisting rewrites stored observation categories into the managed code system.
+// It returns the number of observations updated and the number that could not be mapped.
+func (s *CategoryService) MigrateExisting() (int, int, error) {
+	updated, unmapped := 0, 0
+
+	var observations []models.Observation
+	query := s.db.Select("id", "category")
+	// Only observations without any coding in the managed system need rewriting. Other
+	// databases scan every observation; unchanged ones are skipped below.
+	if dialect.IsPostgres(s.db) {
+		query = query.Where(`NOT EXISTS (
+			SELECT 1 FROM jsonb_array_elements(COALESCE(observations.category, '[]'::jsonb)) AS cat,
+				jsonb_array_elements(COALESCE(cat->'coding', '[]'::jsonb)) AS coding
+			WHERE coding->>'system' = ?)`, models.ObservationCategorySystem)
+	}
+	err := query.
+		FindInBatches(&observations, 500, func(tx *gorm.DB, batch int) error {
+			for _, observation := range observations {
+				changed, mappable := false, true
+				categories := make([]models.Category, len(observation.Category))
+				for i, category := range observation.Category {
+					normalized, ok := s.Normalize(category)
+					if !ok {
+						categories[i] = category
+						mappable = false
+						continue
+					}
+					if !sameCategory(normalized, category) {
+						changed = true
+					}
+					categories[i] = normalized
+				}
+
+				if !mappable {
+					unmapped++
+				}
+				if !changed {
+					continue
+				}
+
+				if err := s.db.Model(&models.Observation{ID: observation.ID}).
+					Select("category").Updates(models.Observation{Category: categories}).Error; err != nil {
+					return err
+				}
+				updated++
+			}
+			return nil
+		}).Error
+	if err != nil {
+		return updated, unmapped, fmt.Errorf("failed to migrate observation categories: %w", err)
+	}
+
+	return updated, unmapped, nil
+}
+
+// lookup resolves a code, display or synonym to an active category. The caller holds the lock.
+func (s *CategoryService) lookup(value string) (models.ObservationCategory, bool) {
+	key := strings.ToLower(strings.TrimSpace(value))
+	if key == "" {
+		return models.ObservationCategory{}, false
+	}
+	if category, ok := s.active[key]; ok {
+		return category, true
+	}
+	if code, ok := categorySynonyms[key]; ok {
+		category, ok := s.active[code]
+		return category, ok
+	}
+	for _, category := range s.active {
+		if strings.EqualFold(category.Display, key) {
+			return category, true
+		}
+	}
+	return models.ObservationCategory{}, false
+}
+
+// sameCategory reports whether two categories have identical codings
+func sameCategory(a, b models.Category) bool {
+	if len(a.Coding) != len(b.Coding) {
+		return false
+	}
+	for i := range a.Coding {
+		if a.Coding[i].System != b.Coding[i].System || a.Coding[i].Code != b.Coding[i].Code || a.Coding[i].Display != b.Coding[i].Display {
+			return false
+		}
+	}
+	return true
+}
+
+// describeCategory returns a short label for a category in error messages
+func describeCategory(category models.Category) string {
+	for _, coding := range category.Coding {
+		if coding.Code != "" {
+			return coding.Code
+		}
+	}
+	return category.Text
+}
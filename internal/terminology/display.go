@@ -0,0 +1,219 @@
+package terminology
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// DefaultLanguage is the language displays fall back to when none of the requested
+// languages has one
+const DefaultLanguage = "en"
+
+// DefaultDisplays seeds the displays of the interpretation flags, statuses and
+// categories observations carry, in English and Spanish
+var DefaultDisplays = defaultDisplays()
+
+func defaultDisplays() []models.ConceptDisplay {
+	displays := []struct {
+		system, code, en, es string
+	}{
+		{models.InterpretationSystem, "N", "Normal", "Normal"},
+		{models.InterpretationSystem, "A", "Abnormal", "Anormal"},
+		{models.InterpretationSystem, "AA", "Critical abnormal", "Anormal crítico"},
+		{models.InterpretationSystem, "H", "High", "Alto"},
+		{models.InterpretationSystem, "HH", "Critical high", "Crítico alto"},
+		{models.InterpretationSystem, "L", "Low", "Bajo"},
+		{models.InterpretationSystem, "LL", "Critical low", "Crítico bajo"},
+		{models.InterpretationSystem, "U", "Significant change up", "Aumento significativo"},
+		{models.InterpretationSystem, "D", "Significant change down", "Disminución significativa"},
+
+		{models.ObservationStatusSystem, "registered", "Registered", "Registrado"},
+		{models.ObservationStatusSystem, "preliminary", "Preliminary", "Preliminar"},
+		{models.ObservationStatusSystem, "final", "Final", "Final"},
+		{models.ObservationStatusSystem, "amended", "Amended", "Modificado"},
+		{models.ObservationStatusSystem, "corrected", "Corrected", "Corregido"},
+		{models.ObservationStatusSystem, "cancelled", "Cancelled", "Cancelado"},
+		{models.ObservationStatusSystem, "entered-in-error", "Entered in error", "Introducido por error"},
+
+		{models.ObservationCategorySystem, "social-history", "Social History", "Antecedentes sociales"},
+		{models.ObservationCategorySystem, "vital-signs", "Vital Signs", "Signos vitales"},
+		{models.ObservationCategorySystem, "imaging", "Imaging", "Imagenología"},
+		{models.ObservationCategorySystem, "laboratory", "Laboratory", "Laboratorio"},
+		{models.ObservationCategorySystem, "procedure", "Procedure", "Procedimiento"},
+		{models.ObservationCategorySystem, "survey", "Survey", "Encuesta"},
+		{models.ObservationCategorySystem, "exam", "Exam", "Examen físico"},
+		{models.ObservationCategorySystem, "therapy", "Therapy", "Terapia"},
+		{models.ObservationCategorySystem, "activity", "Activity", "Actividad"},
+	}
+
+	seeded := make([]models.ConceptDisplay, 0, 2*len(displays))
+	for _, d := range displays {
+		seeded = append(seeded,
+			models.ConceptDisplay{System: d.system, Code: d.code, Language: "en", Display: d.en},
+			models.ConceptDisplay{System: d.system, Code: d.code, Language: "es", Display: d.es},
+		)
+	}
+	return seeded
+}
+
+// Localizer looks up the displays of codes in the languages a patient reads
+type Localizer struct {
+	db *gorm.DB
+}
+
+// NewLocalizer creates a new localizer
+func NewLocalizer(db *gorm.DB) *Localizer {
+	return &Localizer{db: db}
+}
+
+// InitializeDefaultDisplays inserts the default displays that are missing; displays
+// that were edited are kept
+func (l *Localizer) InitializeDefaultDisplays() error {
+	displays := append([]models.ConceptDisplay(nil), DefaultDisplays...)
+	if err := l.db.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(&displays, 100).Error; err != nil {
+		return fmt.Errorf("failed to create concept displays: %w", err)
+	}
+	return nil
+}
+
+// Display returns the display of a code in the first of the languages that has one.
+// A regional language such as es-mx falls back to its base language, and every
+// language to DefaultLanguage. It reports whether a display was found.
+func (l *Localizer) Display(ctx context.Context, system, code string, languages []string) (models.ConceptDisplay, bool, error) {
+	candidates := Candidates(languages)
+	var displays []models.ConceptDisplay
+	if err := l.db.WithContext(ctx).
+		Where("system = ? AND code = ? AND language IN ?", system, code, candidates).
+		Find(&displays).Error; err != nil {
+		return models.ConceptDisplay{}, false, fmt.Errorf("failed to load concept displays: %w", err)
+	}
+
+	for _, language := range candidates {
+		for _, display := range displays {
+			if display.Language == language {
+				return display, true, nil
+			}
+		}
+	}
+	return models.ConceptDisplay{}, false, nil
+}
+
+// Localize replaces the display of each coding with its display in the first of the
+// languages that has one, falling back from a regional language to its base language.
+// A coding without a display in any of them keeps its own, and no language means no
+// lookup at all.
+func (l *Localizer) Localize(ctx context.Context, languages []string, codings ...[]models.Coding) error {
+	candidates := candidates(languages, false)
+	var codes []string
+	for _, list := range codings {
+		for i := range list {
+			if list[i].Code != "" {
+				codes = append(codes, list[i].Code)
+			}
+		}
+	}
+	if len(candidates) == 0 || len(codes) == 0 {
+		return nil
+	}
+
+	var displays []models.ConceptDisplay
+	if err := l.db.WithContext(ctx).
+		Where("code IN ? AND language IN ?", codes, candidates).
+		Find(&displays).Error; err != nil {
+		return fmt.Errorf("failed to load concept displays: %w", err)
+	}
+	type concept struct{ system, code string }
+	byLanguage := make(map[concept]map[string]string)
+	for _, display := range displays {
+		key := concept{display.System, display.Code}
+		if byLanguage[key] == nil {
+			byLanguage[key] = make(map[string]string)
+		}
+		byLanguage[key][display.Language] = display.Display
+	}
+
+	for _, list := range codings {
+		for i := range list {
+			found := byLanguage[concept{list[i].System, list[i].Code}]
+			for _, language := range candidates {
+				if display, ok := found[language]; ok {
+					list[i].Display = display
+					break
+				}
+			}
+		}
+	}
+	return nil
+}
+
+// Candidates returns the languages to look displays up in, in order of preference:
+// each language followed by its base language, then DefaultLanguage
+func Candidates(languages []string) []string {
+	return candidates(languages, true)
+}
+
+func candidates(languages []string, fallback bool) []string {
+	var candidates []string
+	seen := make(map[string]bool)
+	add := func(language string) {
+		if language != "" && !seen[language] {
+			seen[language] = true
+			candidates = append(candidates, language)
+		}
+	}
+	for _, language := range languages {
+		language = strings.ToLower(strings.TrimSpace(language))
+		add(language)
+		if base, _, ok := strings.Cut(language, "-"); ok {
+			add(base)
+		}
+	}
+	if fallback {
+		add(DefaultLanguage)
+	}
+	return candidates
+}
+
+// ParseAcceptLanguage returns the languages of an Accept-Language header, most
+// preferred first. Languages with a weight of 0 and the wildcard are left out.
+func ParseAcceptLanguage(header string) []string {
+	type weighted struct {
+		language string
+		q        float64
+	}
+	var ranges []weighted
+	for _, part := range strings.Split(header, ",") {
+		language, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		language = strings.ToLower(strings.TrimSpace(language))
+		if language == "" || language == "*" {
+			continue
+		}
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			name, value, ok := strings.Cut(strings.TrimSpace(param), "=")
+			if ok && strings.TrimSpace(name) == "q" {
+				if parsed, err := strconv.ParseFloat(strings.TrimSpace(value), 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q > 0 {
+			ranges = append(ranges, weighted{language, q})
+		}
+	}
+
+	// Languages of equal weight keep the order they were sent in
+	sort.SliceStable(ranges, func(i, j int) bool { return ranges[i].q > ranges[j].q })
+	languages := make([]string, len(ranges))
+	for i, r := range ranges {
+		languages[i] = r.language
+	}
+	return languages
+}
@@ -0,0 +1,57 @@
+package terminology
+
+import (
+	"context"
+	"path/filepath"
+	"testing"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"gorm.io/gorm/logger"
+)
+
+func TestLocalize(t *testing.T) {
+	db, err := database.Open("sqlite://" + filepath.Join(t.TempDir(), "healthhub.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer database.CloseDB(db)
+	db.Logger = logger.Discard
+	if err := database.AutoMigrate(db); err != nil {
+		t.Fatal(err)
+	}
+	localizer := NewLocalizer(db)
+	if err := localizer.InitializeDefaultDisplays(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name      string
+		languages []string
+		want      []string
+	}{
+		{"no languages", nil, []string{"High", "Amended", "Hemoglobin"}},
+		{"spanish", []string{"es"}, []string{"Alto", "Amended", "Hemoglobin"}},
+		{"regional", []string{"es-mx"}, []string{"Alto", "Amended", "Hemoglobin"}},
+		{"no display", []string{"fr"}, []string{"High", "Amended", "Hemoglobin"}},
+		{"second language", []string{"fr", "es"}, []string{"Alto", "Amended", "Hemoglobin"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			codings := []models.Coding{
+				{System: models.InterpretationSystem, Code: "H", Display: "High"},
+				// The status system has "amended", but the coding names another system
+				{System: "http://example.org/status", Code: "amended", Display: "Amended"},
+				{System: "http://loinc.org", Code: "718-7", Display: "Hemoglobin"},
+			}
+			if err := localizer.Localize(context.Background(), tt.languages, codings[:1], codings[1:]); err != nil {
+				t.Fatal(err)
+			}
+			for i, coding := range codings {
+				if coding.Display != tt.want[i] {
+					t.Errorf("display of %s is %q, want %q", coding.Code, coding.Display, tt.want[i])
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package terminology
+
+import (
+	"context"
+	"fmt"
+	"sort"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+)
+
+// Match is a code a source code translates to
+type Match struct {
+	Relationship string        `json:"relationship"`
+	Concept      models.Coding `json:"concept"`
+	Reversed     bool          `json:"reversed,omitempty"` // Found by reading a mapping from target to source
+	Priority     int           `json:"-"`
+}
+
+// Translator maps codes between systems using the concept maps in the database
+type Translator struct {
+	db *gorm.DB
+}
+
+// NewTranslator creates a new translator
+func NewTranslator(db *gorm.DB) *Translator {
+	return &Translator{db: db}
+}
+
+// Translate returns the codes of targetSystem that code of system maps to, preferred
+// first. Mappings stored in the other direction are used when there is no mapping
+// from system to targetSystem, with their relationship inverted. Not-related-to
+// mappings are never returned.
+func (t *Translator) Translate(ctx context.Context, system, code, targetSystem string) ([]Match, error) {
+	var mappings []models.ConceptMapping
+	if err := t.db.WithContext(ctx).
+		Where("source_system = ? AND source_code = ? AND target_system = ? AND active = ?", system, code, targetSystem, true).
+		Find(&mappings).Error; err != nil {
+		return nil, fmt.Errorf("failed to load concept mappings: %w", err)
+	}
+
+	matches := make([]Match, 0, len(mappings))
+	for _, m := range mappings {
+		matches = append(matches, Match{
+			Relationship: m.Relationship,
+			Concept:      models.Coding{System: m.TargetSystem, Code: m.TargetCode, Display: m.TargetDisplay},
+			Priority:     m.Priority,
+		})
+	}
+
+	if len(matches) == 0 {
+		if err := t.db.WithContext(ctx).
+			Where("target_system = ? AND target_code = ? AND source_system = ? AND active = ?", system, code, targetSystem, true).
+			Find(&mappings).Error; err != nil {
+			return nil, fmt.Errorf("failed to load concept mappings: %w", err)
+		}
+		for _, m := range mappings {
+			matches = append(matches, Match{
+				Relationship: models.InverseRelationship(m.Relationship),
+				Concept:      models.Coding{System: m.SourceSystem, Code: m.SourceCode, Display: m.SourceDisplay},
+				Reversed:     true,
+				Priority:     m.Priority,
+			})
+		}
+	}
+
+	filtered := matches[:0]
+	for _, match := range matches {
+		if match.Relationship != models.MappingNotRelatedTo {
+			filtered = append(filtered, match)
+		}
+	}
+	sort.SliceStable(filtered, func(i, j int) bool {
+		if filtered[i].Priority != filtered[j].Priority {
+			return filtered[i].Priority < filtered[j].Priority
+		}
+		return filtered[i].Concept.Code < filtered[j].Concept.Code
+	})
+	return filtered, nil
+}
+
+// BillingCode returns the preferred code of targetSystem for a clinical concept, or
+// nil when none of its codings maps to one that covers it. Only equivalent mappings
+// and mappings to a broader target qualify; a target narrower than the clinical code
+// would claim more than was recorded.
+func (t *Translator) BillingCode(ctx context.Context, concept models.CodeableConcept, targetSystem string) (*models.Coding, error) {
+	for _, coding := range concept.Coding {
+		if coding.System == targetSystem {
+			return nil, nil
+		}
+	}
+
+	for _, coding := range concept.Coding {
+		if coding.System == "" || coding.Code == "" {
+			continue
+		}
+		matches, err := t.Translate(ctx, coding.System, coding.Code, targetSystem)
+		if err != nil {
+			return nil, err
+		}
+		for _, match := range matches {
+			if match.Relationship == models.MappingEquivalent || match.Relationship == models.MappingSourceNarrower {
+				billing := match.Concept
+				return &billing, nil
+			}
+		}
+	}
+	return nil, nil
+}
@@ -0,0 +1,69 @@
+// Package turnaround measures laboratory turnaround times: from order to result and
+// from specimen collection to verification.
+package turnaround
+
+import (
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Turnaround intervals
+const (
+	OrderToResult            = "order_to_result"
+	CollectionToVerification = "collection_to_verification"
+)
+
+var durations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+	Name: "observation_turnaround_seconds",
+	Help: "Laboratory turnaround times by interval, observation code and department",
+	Buckets: []float64{
+		5 * 60, 15 * 60, 30 * 60, 60 * 60, 2 * 3600, 4 * 3600, 8 * 3600,
+		24 * 3600, 48 * 3600, 72 * 3600, 7 * 24 * 3600,
+	},
+}, []string{"interval", "code", "department"})
+
+// verifiedStatuses are the statuses of verified results
+var verifiedStatuses = map[string]bool{"final": true, "amended": true, "corrected": true}
+
+// Verified reports whether an observation holds a verified result
+func Verified(o *models.Observation) bool {
+	return verifiedStatuses[o.Status]
+}
+
+// Issue sets the verification time of a verified result that has none
+func Issue(o *models.Observation, now time.Time) {
+	if o.Issued == nil && Verified(o) {
+		o.Issued = &now
+	}
+}
+
+// ObserveCreated records the turnaround times of a new observation
+func ObserveCreated(o *models.Observation) {
+	if o.OrderedAt != nil {
+		observe(o, OrderToResult, o.CreatedAt.Sub(*o.OrderedAt))
+	}
+	ObserveVerified(o)
+}
+
+// ObserveVerified records the collection to verification time of an observation
+// once it is issued
+func ObserveVerified(o *models.Observation) {
+	if o.Issued != nil {
+		observe(o, CollectionToVerification, o.Issued.Sub(o.EffectiveDateTime))
+	}
+}
+
+func observe(o *models.Observation, interval string, d time.Duration) {
+	// Negative times come from clock skew or back-dated entries
+	if d < 0 {
+		return
+	}
+	code := ""
+	if len(o.Code.Coding) > 0 {
+		code = o.Code.Coding[0].Code
+	}
+	durations.WithLabelValues(interval, code, o.Department()).Observe(d.Seconds())
+}
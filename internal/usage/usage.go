@@ -0,0 +1,309 @@
+// Package usage counts the requests clients make to each endpoint, and to the
+// endpoints and fields that are deprecated, so removing them in a later API version is
+// decided on who still uses them.
+package usage
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/internal/auth"
+	"github.com/hillmatthew2000/HealthHub/internal/inbound"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// flushInterval is how often the counts of this instance are added to the database
+const flushInterval = time.Minute
+
+// maxUserAgent bounds the part of the user agent a client is told apart by
+const maxUserAgent = 200
+
+// maxBodyBytes bounds the part of a request body searched for deprecated fields
+const maxBodyBytes = 1 << 20
+
+// Prefixes of deprecated fields, naming where a request carries them
+const (
+	QueryField = "query."
+	BodyField  = "body."
+)
+
+// Deprecation is a deprecated endpoint, or a field of its requests when Field is set
+type Deprecation struct {
+	Method string `json:"method"`
+	Route  string `json:"route"`
+	Field  string `json:"field,omitempty"` // query.name or body.path.to.field
+}
+
+// ParseDeprecations parses deprecations of the form "METHOD route" for an endpoint or
+// "METHOD route=field" for a field of its requests, e.g. GET /api/v1/error-codes or
+// POST /api/v1/observations=body.note. Routes are the templates they are registered
+// with, such as /api/v1/patients/:id; fields are a query parameter, query.name, or a
+// JSON body field, body.name, with nested fields separated by dots.
+func ParseDeprecations(entries []string) ([]Deprecation, error) {
+	var deprecations []Deprecation
+	seen := make(map[Deprecation]bool)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, field, hasField := strings.Cut(entry, "=")
+		method, route, ok := strings.Cut(strings.TrimSpace(endpoint), " ")
+		d := Deprecation{
+			Method: strings.ToUpper(strings.TrimSpace(method)),
+			Route:  strings.TrimSpace(route),
+			Field:  strings.TrimSpace(field),
+		}
+		if !ok || d.Method == "" || !strings.HasPrefix(d.Route, "/") {
+			return nil, fmt.Errorf("usage: invalid deprecation %q, expected \"METHOD route\" or \"METHOD route=field\"", entry)
+		}
+		if hasField {
+			name := strings.TrimPrefix(strings.TrimPrefix(d.Field, QueryField), BodyField)
+			if name == d.Field || name == "" || strings.HasPrefix(name, ".") || strings.HasSuffix(name, ".") || strings.Contains(name, "..") {
+				return nil, fmt.Errorf("usage: invalid field in %q, expected query.name or body.name", entry)
+			}
+		}
+		if seen[d] {
+			return nil, fmt.Errorf("usage: duplicate deprecation %q", entry)
+		}
+		seen[d] = true
+		deprecations = append(deprecations, d)
+	}
+	return deprecations, nil
+}
+
+// key identifies a count: requests of a client to an endpoint on a day, or those using
+// a deprecated field of it
+type key struct {
+	day, method, route, field string
+	userID, apiKey, userAgent string
+}
+
+// Tracker counts requests in memory and adds the counts to the database periodically,
+// so counting costs a request no database write
+type Tracker struct {
+	db           *gorm.DB
+	retention    time.Duration
+	deprecations []Deprecation
+	fields       map[string][]string // Deprecated fields by method and route
+
+	mu     sync.Mutex
+	counts map[key]int64 // Requests since the last flush
+}
+
+// NewTracker creates a tracker of the given deprecations. Counts of days older than
+// the retention are removed.
+func NewTracker(db *gorm.DB, deprecations []Deprecation, retention time.Duration) *Tracker {
+	t := &Tracker{
+		db:           db,
+		retention:    retention,
+		deprecations: deprecations,
+		fields:       make(map[string][]string),
+		counts:       make(map[key]int64),
+	}
+	for _, d := range deprecations {
+		if d.Field != "" {
+			t.fields[d.Method+" "+d.Route] = append(t.fields[d.Method+" "+d.Route], d.Field)
+		}
+	}
+	return t
+}
+
+// Deprecations returns the deprecations the tracker counts
+func (t *Tracker) Deprecations() []Deprecation {
+	return append([]Deprecation(nil), t.deprecations...)
+}
+
+// Middleware counts the requests to every route by client. It must come before the
+// routes' own middleware; the client is read once the request was served, when the
+// authentication middleware has identified its user.
+func (t *Tracker) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		route := c.FullPath()
+		if route == "" {
+			c.Next()
+			return
+		}
+
+		// The fields are looked for before the handler consumes the body
+		var used []string
+		if fields := t.fields[c.Request.Method+" "+route]; len(fields) > 0 {
+			used = usedFields(c, fields)
+		}
+
+		c.Next()
+
+		userID, _ := auth.GetUserID(c)
+		userAgent := c.Request.UserAgent()
+		if len(userAgent) > maxUserAgent {
+			userAgent = strings.ToValidUTF8(userAgent[:maxUserAgent], "")
+		}
+		k := key{
+			day:       time.Now().UTC().Format("2006-01-02"),
+			method:    c.Request.Method,
+			route:     route,
+			userID:    userID,
+			apiKey:    c.GetString(inbound.CredentialKey),
+			userAgent: userAgent,
+		}
+		t.add(k)
+		for _, field := range used {
+			k.field = field
+			t.add(k)
+		}
+	}
+}
+
+func (t *Tracker) add(k key) {
+	t.mu.Lock()
+	t.counts[k]++
+	t.mu.Unlock()
+}
+
+// usedFields returns the deprecated fields a request carries
+func usedFields(c *gin.Context, fields []string) []string {
+	var used []string
+	var body interface{}
+	bodyRead := false
+	for _, field := range fields {
+		if name, ok := strings.CutPrefix(field, QueryField); ok {
+			if _, present := c.Request.URL.Query()[name]; present {
+				used = append(used, field)
+			}
+			continue
+		}
+
+		if !bodyRead {
+			bodyRead = true
+			body = readBody(c)
+		}
+		if hasPath(body, strings.Split(strings.TrimPrefix(field, BodyField), ".")) {
+			used = append(used, field)
+		}
+	}
+	return used
+}
+
+// readBody decodes the JSON body of a request and puts it back for the handler. A body
+// that is not JSON, or too large to search, holds no fields.
+func readBody(c *gin.Context) interface{} {
+	if c.Request.Body == nil || !strings.Contains(c.ContentType(), "json") {
+		return nil
+	}
+	body := c.Request.Body
+	read, _ := io.ReadAll(io.LimitReader(body, maxBodyBytes+1))
+	c.Request.Body = readCloser{io.MultiReader(bytes.NewReader(read), body), body}
+	if len(read) > maxBodyBytes {
+		return nil
+	}
+	var decoded interface{}
+	if err := json.Unmarshal(read, &decoded); err != nil {
+		return nil
+	}
+	return decoded
+}
+
+// hasPath reports whether a decoded JSON value holds a field at path. Arrays along the
+// path hold it when any of their elements does.
+func hasPath(value interface{}, path []string) bool {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		next, ok := v[path[0]]
+		if !ok {
+			return false
+		}
+		return len(path) == 1 || hasPath(next, path[1:])
+	case []interface{}:
+		for _, element := range v {
+			if hasPath(element, path) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// readCloser reads a body replayed in front of the rest of the original body
+type readCloser struct {
+	io.Reader
+	io.Closer
+}
+
+// Run adds the counts to the database and removes expired ones until the context is
+// cancelled. Requests served after that are added by a final Flush.
+func (t *Tracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(flushInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := t.Flush(ctx); err != nil {
+			logger.Warn("Failed to store API usage", zap.Error(err))
+		}
+		cutoff := time.Now().UTC().Add(-t.retention).Format("2006-01-02")
+		if err := t.db.WithContext(ctx).Where("day < ?", cutoff).Delete(&models.APIUsage{}).Error; err != nil {
+			logger.Warn("Failed to purge API usage", zap.Error(err))
+		}
+	}
+}
+
+// Flush adds the counts made since the last flush to the database. Counts that cannot
+// be stored are kept for the next flush.
+func (t *Tracker) Flush(ctx context.Context) error {
+	t.mu.Lock()
+	counts := t.counts
+	t.counts = make(map[key]int64)
+	t.mu.Unlock()
+	if len(counts) == 0 {
+		return nil
+	}
+
+	rows := make([]models.APIUsage, 0, len(counts))
+	for k, n := range counts {
+		rows = append(rows, models.APIUsage{
+			Day:       k.day,
+			Method:    k.method,
+			Route:     k.route,
+			Field:     k.field,
+			UserID:    k.userID,
+			APIKey:    k.apiKey,
+			UserAgent: k.userAgent,
+			Requests:  n,
+		})
+	}
+	// Other instances add their counts to the same rows
+	err := t.db.WithContext(ctx).Clauses(clause.OnConflict{
+		Columns: []clause.Column{
+			{Name: "day"}, {Name: "method"}, {Name: "route"}, {Name: "field"},
+			{Name: "user_id"}, {Name: "api_key"}, {Name: "user_agent"},
+		},
+		DoUpdates: clause.Assignments(map[string]interface{}{
+			"requests": gorm.Expr("api_usage.requests + excluded.requests"),
+		}),
+	}).CreateInBatches(&rows, 500).Error
+	if err != nil {
+		t.mu.Lock()
+		for k, n := range counts {
+			t.counts[k] += n
+		}
+		t.mu.Unlock()
+		return fmt.Errorf("failed to store API usage: %w", err)
+	}
+	return nil
+}
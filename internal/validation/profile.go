@@ -0,0 +1,364 @@
+package validation
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"gorm.io/gorm"
+)
+
+// TenantHeader is the request header that selects tenant-specific profiles
+const TenantHeader = "X-Tenant-ID"
+
+// ProfileService applies configurable field rules to resources at runtime. Rules from
+// tenant-wide profiles (empty tenant) always apply; a tenant's own profiles add to them.
+type ProfileService struct {
+	db *gorm.DB
+
+	mu    sync.RWMutex
+	rules map[string][]compiledRule // keyed by tenant + "/" + resource type
+}
+
+// compiledRule is a field rule with its path parsed and pattern compiled
+type compiledRule struct {
+	models.FieldRule
+	path    []segment
+	pattern *regexp.Regexp
+}
+
+// segment is one step of a rule path
+type segment struct {
+	name     string
+	selector []segment // Sub-path compared against value; nil when there is no selector
+	value    string
+}
+
+// NewProfileService creates a new profile service
+func NewProfileService(db *gorm.DB) *ProfileService {
+	return &ProfileService{db: db}
+}
+
+// Reload refreshes the cached rules from the active profiles
+func (s *ProfileService) Reload() error {
+	var profiles []models.ValidationProfile
+	if err := s.db.Where("active = ?", true).Order("name ASC").Find(&profiles).Error; err != nil {
+		return fmt.Errorf("failed to load validation profiles: %w", err)
+	}
+
+	rules := make(map[string][]compiledRule)
+	for _, profile := range profiles {
+		compiled, err := compileRules(profile.Rules)
+		if err != nil {
+			return fmt.Errorf("profile %s: %w", profile.Name, err)
+		}
+		key := profileKey(profile.Tenant, profile.ResourceType)
+		rules[key] = append(rules[key], compiled...)
+	}
+
+	s.mu.Lock()
+	s.rules = rules
+	s.mu.Unlock()
+	return nil
+}
+
+// Check reports whether every rule of a profile can be compiled
+func (s *ProfileService) Check(profile *models.ValidationProfile) error {
+	_, err := compileRules(profile.Rules)
+	return err
+}
+
+// Validate applies the rules for the tenant and resource type and returns the
+// violations keyed by rule path. A nil result means the resource is valid.
+func (s *ProfileService) Validate(tenant, resourceType string, resource interface{}) (map[string]string, error) {
+	doc, err := toDocument(resource)
+	if err != nil {
+		return nil, err
+	}
+	return s.validateDocument(tenant, resourceType, doc), nil
+}
+
+// ValidateUpdate validates the result of applying a partial update to an existing
+// resource. Top-level fields omitted from the update keep their existing values.
+func (s *ProfileService) ValidateUpdate(tenant, resourceType string, existing, update interface{}) (map[string]string, error) {
+	doc, err := toDocument(existing)
+	if err != nil {
+		return nil, err
+	}
+	changes, err := toDocument(update)
+	if err != nil {
+		return nil, err
+	}
+
+	for key, value := range changes.(map[string]interface{}) {
+		if present(value) {
+			doc.(map[string]interface{})[key] = value
+		}
+	}
+	return s.validateDocument(tenant, resourceType, doc), nil
+}
+
+// validateDocument evaluates the cached rules against a decoded JSON document
+func (s *ProfileService) validateDocument(tenant, resourceType string, doc interface{}) map[string]string {
+	s.mu.RLock()
+	rules := append([]compiledRule{}, s.rules[profileKey("", resourceType)]...)
+	if tenant != "" {
+		rules = append(rules, s.rules[profileKey(tenant, resourceType)]...)
+	}
+	s.mu.RUnlock()
+
+	var violations map[string]string
+	for _, rule := range rules {
+		if message := rule.evaluate(doc); message != "" {
+			if violations == nil {
+				violations = make(map[string]string)
+			}
+			if existing, ok := violations[rule.Path]; ok && existing != message {
+				message = existing + "; " + message
+			}
+			violations[rule.Path] = message
+		}
+	}
+	return violations
+}
+
+// evaluate returns the violation message for a document, or "" when the rule holds
+func (r compiledRule) evaluate(doc interface{}) string {
+	var values []interface{}
+	for _, value := range resolve(doc, r.path) {
+		if present(value) {
+			values = append(values, value)
+		}
+	}
+
+	switch {
+	case r.Required && len(values) == 0:
+		return r.message("is required")
+	case r.Forbidden && len(values) > 0:
+		return r.message("is not allowed")
+	}
+
+	if r.pattern != nil {
+		for _, value := range values {
+			if !r.pattern.MatchString(scalarString(value)) {
+				return r.message(fmt.Sprintf("must match %s", r.Pattern))
+			}
+		}
+	}
+	return ""
+}
+
+// message returns the rule's custom message, falling back to the default
+func (r compiledRule) message(fallback string) string {
+	if r.Message != "" {
+		return r.Message
+	}
+	return fallback
+}
+
+// compileRules parses rule paths and compiles their patterns
+func compileRules(rules []models.FieldRule) ([]compiledRule, error) {
+	compiled := make([]compiledRule, 0, len(rules))
+	for _, rule := range rules {
+		if rule.Required && rule.Forbidden {
+			return nil, fmt.Errorf("rule %q cannot be both required and forbidden", rule.Path)
+		}
+		if !rule.Required && !rule.Forbidden && rule.Pattern == "" {
+			return nil, fmt.Errorf("rule %q has no constraint", rule.Path)
+		}
+
+		path, err := parsePath(rule.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		c := compiledRule{FieldRule: rule, path: path}
+		if rule.Pattern != "" {
+			// Patterns constrain the whole value rather than a substring
+			if c.pattern, err = regexp.Compile("^(?:" + rule.Pattern + ")$"); err != nil {
+				return nil, fmt.Errorf("rule %q has an invalid pattern: %w", rule.Path, err)
+			}
+		}
+		compiled = append(compiled, c)
+	}
+	return compiled, nil
+}
+
+// parsePath splits a rule path on dots outside of selectors
+func parsePath(path string) ([]segment, error) {
+	var segments []segment
+	depth, start := 0, 0
+
+	for i := 0; i <= len(path); i++ {
+		if i < len(path) {
+			switch path[i] {
+			case '[':
+				depth++
+				continue
+			case ']':
+				depth--
+				if depth < 0 {
+					return nil, fmt.Errorf("path %q has an unbalanced selector", path)
+				}
+				continue
+			case '.':
+				if depth > 0 {
+					continue
+				}
+			default:
+				continue
+			}
+		}
+		if depth != 0 {
+			return nil, fmt.Errorf("path %q has an unbalanced selector", path)
+		}
+
+		seg, err := parseSegment(path[start:i])
+		if err != nil {
+			return nil, fmt.Errorf("path %q: %w", path, err)
+		}
+		segments = append(segments, seg)
+		start = i + 1
+	}
+	return segments, nil
+}
+
+// parseSegment parses "name" or "name[sub.path=value]"
+func parseSegment(raw string) (segment, error) {
+	open := strings.IndexByte(raw, '[')
+	if open < 0 {
+		if raw == "" {
+			return segment{}, fmt.Errorf("empty path segment")
+		}
+		return segment{name: raw}, nil
+	}
+	if open == 0 || !strings.HasSuffix(raw, "]") {
+		return segment{}, fmt.Errorf("invalid selector %q", raw)
+	}
+
+	selector := raw[open+1 : len(raw)-1]
+	eq := strings.IndexByte(selector, '=')
+	if eq <= 0 {
+		return segment{}, fmt.Errorf("selector %q must have the form [path=value]", raw)
+	}
+	sub, err := parsePath(selector[:eq])
+	if err != nil {
+		return segment{}, err
+	}
+	return segment{name: raw[:open], selector: sub, value: selector[eq+1:]}, nil
+}
+
+// resolve returns the values reached by following path from node, expanding arrays
+func resolve(node interface{}, path []segment) []interface{} {
+	if len(path) == 0 {
+		if items, ok := node.([]interface{}); ok {
+			return items
+		}
+		return []interface{}{node}
+	}
+
+	if items, ok := node.([]interface{}); ok {
+		var values []interface{}
+		for _, item := range items {
+			values = append(values, resolve(item, path)...)
+		}
+		return values
+	}
+
+	object, ok := node.(map[string]interface{})
+	if !ok {
+		return nil
+	}
+	seg := path[0]
+	child, ok := object[seg.name]
+	if !ok {
+		return nil
+	}
+
+	if seg.selector == nil {
+		return resolve(child, path[1:])
+	}
+
+	candidates, ok := child.([]interface{})
+	if !ok {
+		candidates = []interface{}{child}
+	}
+	var values []interface{}
+	for _, candidate := range candidates {
+		if matches(candidate, seg) {
+			values = append(values, resolve(candidate, path[1:])...)
+		}
+	}
+	return values
+}
+
+// matches reports whether any value at the selector path equals the selector value
+func matches(node interface{}, seg segment) bool {
+	for _, value := range resolve(node, seg.selector) {
+		if scalarString(value) == seg.value {
+			return true
+		}
+	}
+	return false
+}
+
+// present reports whether a decoded JSON value carries data
+func present(value interface{}) bool {
+	switch v := value.(type) {
+	case nil:
+		return false
+	case string:
+		return strings.TrimSpace(v) != ""
+	case []interface{}:
+		return len(v) > 0
+	case map[string]interface{}:
+		return len(v) > 0
+	}
+	return true
+}
+
+// scalarString formats a decoded JSON value for pattern and selector matching
+func scalarString(value interface{}) string {
+	switch v := value.(type) {
+	case string:
+		return v
+	case nil:
+		return ""
+	case map[string]interface{}, []interface{}:
+		data, _ := json.Marshal(v)
+		return string(data)
+	}
+	return fmt.Sprint(value)
+}
+
+// toDocument converts a resource to its decoded JSON representation
+func toDocument(resource interface{}) (interface{}, error) {
+	data, err := json.Marshal(resource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode resource: %w", err)
+	}
+	var doc map[string]interface{}
+	if err := json.Unmarshal(data, &doc); err != nil {
+		return nil, fmt.Errorf("failed to decode resource: %w", err)
+	}
+	return doc, nil
+}
+
+// profileKey builds the cache key for a tenant and resource type
+func profileKey(tenant, resourceType string) string {
+	return tenant + "/" + resourceType
+}
+
+// SortedPaths returns the violation paths in a stable order for messages
+func SortedPaths(violations map[string]string) []string {
+	paths := make([]string, 0, len(violations))
+	for path := range violations {
+		paths = append(paths, path)
+	}
+	sort.Strings(paths)
+	return paths
+}
@@ -0,0 +1,119 @@
+// Package warmup prepares a starting instance to serve its first requests as fast as
+// its later ones. Before the server listens it opens the connection pool, prepares the
+// statements of the hottest queries on every pooled connection and parses the schemas
+// of the models, work that otherwise falls on the first requests after a deploy.
+package warmup
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Query is a query the API runs on most requests. It is run with values that match no
+// record, so its statement is prepared and planned without reading patient data; the
+// record it does not find is not an error.
+type Query struct {
+	Name string
+	Run  func(tx *gorm.DB) error
+}
+
+// Options configures a warm-up
+type Options struct {
+	// Connections is the number of pooled connections the queries are prepared on,
+	// capped by the pool's maximum
+	Connections int
+	// Queries are run on each connection
+	Queries []Query
+	// Models have their schemas parsed into the database's schema cache
+	Models []interface{}
+}
+
+// Result summarizes a warm-up of one database
+type Result struct {
+	Connections int
+	Queries     int
+	Models      int
+	Failed      int
+	Duration    time.Duration
+}
+
+// Run warms db up within the deadline of ctx. Queries that fail are counted and the
+// first failure returned; the connections warmed up until then stay in the pool.
+func Run(ctx context.Context, db *gorm.DB, opts Options) (Result, error) {
+	start := time.Now()
+	result := Result{}
+
+	// The schema cache holds the parsed field mappings of every model, built on first use
+	for _, model := range opts.Models {
+		if err := (&gorm.Statement{DB: db}).Parse(model); err != nil {
+			return result, fmt.Errorf("failed to parse schema of %T: %w", model, err)
+		}
+		result.Models++
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return result, fmt.Errorf("failed to get underlying sql.DB: %w", err)
+	}
+	connections := opts.Connections
+	if limit := sqlDB.Stats().MaxOpenConnections; limit > 0 && connections > limit {
+		connections = limit
+	}
+
+	// Each connection is held until all are open, so the statements are prepared on as
+	// many distinct connections as were asked for
+	var (
+		mu       sync.Mutex
+		firstErr error
+		opened   sync.WaitGroup
+		done     sync.WaitGroup
+	)
+	fail := func(err error) {
+		mu.Lock()
+		defer mu.Unlock()
+		result.Failed++
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+	opened.Add(connections)
+	done.Add(connections)
+	for i := 0; i < connections; i++ {
+		go func() {
+			defer done.Done()
+			conn, err := sqlDB.Conn(ctx)
+			opened.Done()
+			if err != nil {
+				fail(fmt.Errorf("failed to open connection: %w", err))
+				return
+			}
+			defer conn.Close()
+
+			ran := 0
+			for _, query := range opts.Queries {
+				session := db.WithContext(ctx).Session(&gorm.Session{NewDB: true})
+				session.Statement.ConnPool = conn
+				if err := query.Run(session); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+					fail(fmt.Errorf("%s: %w", query.Name, err))
+					continue
+				}
+				ran++
+			}
+			opened.Wait()
+
+			mu.Lock()
+			result.Connections++
+			result.Queries += ran
+			mu.Unlock()
+		}()
+	}
+	done.Wait()
+
+	result.Duration = time.Since(start)
+	return result, firstErr
+}
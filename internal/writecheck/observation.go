@@ -0,0 +1,156 @@
+package writecheck
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/hillmatthew2000/HealthHub/internal/deltacheck"
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/terminology"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/ucum"
+	"gorm.io/gorm"
+)
+
+// Observation checks, in the order they run
+const (
+	ObservationCategory       = "category"
+	ObservationDuplicate      = "duplicate"
+	ObservationReferenceRange = "reference_range"
+	ObservationDeltaCheck     = "delta_check"
+)
+
+// RegisterObservationChecks registers the built-in checks of new observations. The
+// category and delta checks run unless disabled; deduplication and reference range
+// flagging only when enabled.
+func RegisterObservationChecks(r *Registry, db *gorm.DB, categories *terminology.CategoryService, deltas *deltacheck.Checker) {
+	r.Register("Observation", ObservationCategory,
+		"Requires an active category from the managed code system and normalizes categories to it", true,
+		observationCheck(func(ctx context.Context, o *models.Observation) (interface{}, error) {
+			if err := categories.Validate(o.Category); err != nil {
+				return nil, &Rejection{Code: "INVALID_CATEGORY", Reason: "Invalid observation category", Message: err.Error()}
+			}
+			return nil, nil
+		}))
+	r.Register("Observation", ObservationDuplicate,
+		"Rejects a result already recorded for the patient with the same code, effective time and value", false,
+		observationCheck(func(ctx context.Context, o *models.Observation) (interface{}, error) {
+			return nil, rejectDuplicate(ctx, db, o)
+		}))
+	r.Register("Observation", ObservationReferenceRange,
+		"Flags a quantity result outside its own reference range as high or low when it has no interpretation", false,
+		observationCheck(func(ctx context.Context, o *models.Observation) (interface{}, error) {
+			return flagOutOfRange(o), nil
+		}))
+	r.Register("Observation", ObservationDeltaCheck,
+		"Flags a result that changed more than its code's delta check rule allows since the patient's previous one", true,
+		observationCheck(func(ctx context.Context, o *models.Observation) (interface{}, error) {
+			return deltas.Check(ctx, o)
+		}))
+}
+
+// observationCheck adapts a check of observations to the registry
+func observationCheck(fn func(ctx context.Context, o *models.Observation) (interface{}, error)) CheckFunc {
+	return func(ctx context.Context, resource interface{}) (interface{}, error) {
+		o, ok := resource.(*models.Observation)
+		if !ok {
+			return nil, fmt.Errorf("expected an observation, got %T", resource)
+		}
+		return fn(ctx, o)
+	}
+}
+
+// rejectDuplicate rejects an observation whose patient already has a result with the
+// same code, effective time, value quantity and value string, such as one resent by
+// a lab interface
+func rejectDuplicate(ctx context.Context, db *gorm.DB, o *models.Observation) error {
+	if o.Subject.Reference == "" || len(o.Code.Coding) == 0 || o.EffectiveDateTime.IsZero() {
+		return nil
+	}
+	coding := o.Code.Coding[0]
+
+	d := dialect.Of(db)
+	query := db.WithContext(ctx).Model(&models.Observation{}).
+		Where(d.JSONText("subject", "reference")+" = ?", o.Subject.Reference).
+		Where(d.JSONText("code", "coding", "0", "code")+" = ?", coding.Code).
+		Where("effective_date_time = ? AND value_string = ? AND status <> ?", o.EffectiveDateTime, o.ValueString, "entered-in-error")
+	if coding.System != "" {
+		query = query.Where(d.JSONText("code", "coding", "0", "system")+" = ?", coding.System)
+	}
+	if o.ValueQuantity != nil {
+		query = query.Where("value_quantity_value = ?", o.ValueQuantity.Value)
+	} else {
+		query = query.Where("value_quantity_value IS NULL")
+	}
+
+	var existing models.Observation
+	err := query.Select("id").First(&existing).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to look up duplicate observations: %w", err)
+	}
+	return &Rejection{
+		Code:     "OBSERVATION_EXISTS",
+		Reason:   "Duplicate observation",
+		Message:  fmt.Sprintf("observation %s already records the result", existing.ID),
+		Conflict: true,
+	}
+}
+
+// flagOutOfRange adds a high or low interpretation to a quantity result outside the
+// first of its reference ranges that applies to every patient, returning the flag's
+// code, or nil when it is in range or not flagged. Results that already carry an
+// interpretation are left as sent.
+func flagOutOfRange(o *models.Observation) interface{} {
+	if o.ValueQuantity == nil || o.ValueQuantity.Comparator != "" || len(o.Interpretation) > 0 {
+		return nil
+	}
+	value, unit := o.ValueQuantity.Value, unitCode(o.ValueQuantity)
+
+	for _, r := range o.ReferenceRange {
+		if len(r.AppliesTo) > 0 || r.Age != nil || (r.Low == nil && r.High == nil) {
+			continue
+		}
+		var flag models.Coding
+		if high, ok := limit(r.High, unit); ok && value > high {
+			flag = models.Coding{System: models.InterpretationSystem, Code: "H", Display: "High"}
+		} else if low, ok := limit(r.Low, unit); ok && value < low {
+			flag = models.Coding{System: models.InterpretationSystem, Code: "L", Display: "Low"}
+		} else {
+			return nil
+		}
+		o.Interpretation = append(o.Interpretation, models.CodeableConcept{
+			Coding: []models.Coding{flag},
+			Text:   "Outside reference range",
+		})
+		return flag.Code
+	}
+	return nil
+}
+
+// limit returns a reference range limit in unit, which it must be convertible to
+func limit(q *models.Quantity, unit string) (float64, bool) {
+	if q == nil {
+		return 0, false
+	}
+	from := unitCode(q)
+	if from == "" || from == unit {
+		return q.Value, true
+	}
+	converted, err := ucum.Convert(q.Value, from, unit)
+	if err != nil {
+		return 0, false
+	}
+	return converted, true
+}
+
+// unitCode returns the UCUM code of a quantity, falling back to its display unit
+func unitCode(q *models.Quantity) string {
+	if q.Code != "" {
+		return q.Code
+	}
+	return q.Unit
+}
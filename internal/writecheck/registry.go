@@ -0,0 +1,182 @@
+// Package writecheck runs the checks a resource passes before it is written. Checks
+// register by resource type and run in the order they were registered; each can be
+// turned on or off per deployment, so one site can dedupe results another accepts.
+package writecheck
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Check outcomes recorded in the metrics
+const (
+	OutcomePassed   = "passed"
+	OutcomeRejected = "rejected"
+	OutcomeError    = "error"
+)
+
+var (
+	checkDurations = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "write_check_duration_seconds",
+		Help:    "Duration of write-time checks by resource type and check",
+		Buckets: []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"resource_type", "check"})
+
+	checkOutcomes = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "write_check_outcomes_total",
+		Help: "Total number of write-time checks run by resource type, check and outcome",
+	}, []string{"resource_type", "check", "outcome"})
+)
+
+// CheckFunc checks a resource about to be written, which it may also annotate, such
+// as by adding an interpretation. It returns a *Rejection to refuse the write and any
+// other error when it could not run; its result is handed back to the caller.
+type CheckFunc func(ctx context.Context, resource interface{}) (interface{}, error)
+
+// Rejection is the error of a check that refuses a write
+type Rejection struct {
+	Check    string // Set by the registry
+	Code     string // API error code
+	Reason   string // Short summary, the response's error
+	Message  string
+	Conflict bool // The resource conflicts with a stored one rather than being invalid
+}
+
+// Error returns the rejection's message
+func (r *Rejection) Error() string {
+	if r.Message == "" {
+		return r.Reason
+	}
+	return r.Reason + ": " + r.Message
+}
+
+// Results are the results of the checks run on a write, by check name
+type Results map[string]interface{}
+
+// CheckStatus describes a registered check
+type CheckStatus struct {
+	ResourceType string `json:"resourceType"`
+	Name         string `json:"name"`
+	Description  string `json:"description"`
+	Enabled      bool   `json:"enabled"`
+}
+
+type check struct {
+	CheckStatus
+	fn CheckFunc
+}
+
+// Registry holds the write checks of each resource type. Checks are registered and
+// configured at startup, before the first write is checked.
+type Registry struct {
+	types  []string // Resource types in registration order
+	checks map[string][]*check
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{checks: make(map[string][]*check)}
+}
+
+// Register adds a check of a resource type, run after those registered before it.
+// enabled is whether it runs when the deployment does not say.
+func (r *Registry) Register(resourceType, name, description string, enabled bool, fn CheckFunc) {
+	if _, ok := r.checks[resourceType]; !ok {
+		r.types = append(r.types, resourceType)
+	}
+	r.checks[resourceType] = append(r.checks[resourceType], &check{
+		CheckStatus: CheckStatus{ResourceType: resourceType, Name: name, Description: description, Enabled: enabled},
+		fn:          fn,
+	})
+}
+
+// Configure turns checks on and off by name, each name applying to the checks of that
+// name on every resource type, or by ResourceType.name. It returns the names that
+// match no check.
+func (r *Registry) Configure(enable, disable []string) []string {
+	var unknown []string
+	for _, names := range []struct {
+		names   []string
+		enabled bool
+	}{{enable, true}, {disable, false}} {
+		for _, name := range names.names {
+			if name = strings.TrimSpace(name); name == "" {
+				continue
+			}
+			matched := false
+			for _, checks := range r.checks {
+				for _, c := range checks {
+					if name == c.Name || name == c.ResourceType+"."+c.Name {
+						c.Enabled = names.enabled
+						matched = true
+					}
+				}
+			}
+			if !matched {
+				unknown = append(unknown, name)
+			}
+		}
+	}
+	return unknown
+}
+
+// Run runs the enabled checks of a resource type in order, stopping at the first that
+// rejects the resource or fails
+func (r *Registry) Run(ctx context.Context, resourceType string, resource interface{}) (Results, error) {
+	results := Results{}
+	for _, c := range r.checks[resourceType] {
+		if !c.Enabled {
+			continue
+		}
+
+		start := time.Now()
+		result, err := c.fn(ctx, resource)
+		checkDurations.WithLabelValues(resourceType, c.Name).Observe(time.Since(start).Seconds())
+
+		var rejection *Rejection
+		switch {
+		case errors.As(err, &rejection):
+			checkOutcomes.WithLabelValues(resourceType, c.Name, OutcomeRejected).Inc()
+			rejection.Check = c.Name
+			return results, rejection
+		case err != nil:
+			checkOutcomes.WithLabelValues(resourceType, c.Name, OutcomeError).Inc()
+			return results, &CheckError{Check: c.Name, Err: err}
+		}
+		checkOutcomes.WithLabelValues(resourceType, c.Name, OutcomePassed).Inc()
+		results[c.Name] = result
+	}
+	return results, nil
+}
+
+// Checks lists the registered checks, by resource type and in the order they run
+func (r *Registry) Checks() []CheckStatus {
+	statuses := []CheckStatus{}
+	for _, resourceType := range r.types {
+		for _, c := range r.checks[resourceType] {
+			statuses = append(statuses, c.CheckStatus)
+		}
+	}
+	return statuses
+}
+
+// CheckError is the error of a check that could not run
+type CheckError struct {
+	Check string
+	Err   error
+}
+
+// Error returns the check's error
+func (e *CheckError) Error() string {
+	return "write check " + e.Check + " failed: " + e.Err.Error()
+}
+
+// Unwrap returns the check's error
+func (e *CheckError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,79 @@
+// Package apierror writes the API's error responses. Every error carries a
+// machine-readable code documented in the catalog, when it happened and the ID of
+// the request, so a client's report can be matched to the server's logs.
+package apierror
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// RequestIDHeader carries the ID of a request. A usable ID sent by the client is kept
+// so calls can be traced across services; otherwise one is generated. The ID is
+// echoed on every response.
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey is the context key of the request ID
+const requestIDKey = "request_id"
+
+// requestIDPattern matches the request IDs accepted from clients
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._:-]{1,128}$`)
+
+// Response is the body of every error the API returns
+type Response struct {
+	Error     string            `json:"error"`
+	Message   string            `json:"message,omitempty"`
+	Code      string            `json:"code"`
+	Details   map[string]string `json:"details,omitempty"`
+	Reference string            `json:"reference,omitempty"` // Log entry of an internal error, for support
+	RequestID string            `json:"requestId,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+}
+
+// RequestIDMiddleware assigns every request its ID
+func RequestIDMiddleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		id := c.GetHeader(RequestIDHeader)
+		if !requestIDPattern.MatchString(id) {
+			id = uuid.New().String()
+		}
+		c.Set(requestIDKey, id)
+		c.Header(RequestIDHeader, id)
+		c.Next()
+	}
+}
+
+// RequestID returns the ID of a request, or an empty string outside the middleware
+func RequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// Stamp sets when an error happened and the request it answers. A code missing from
+// the catalog is logged so it gets documented.
+func Stamp(c *gin.Context, resp *Response) {
+	resp.Timestamp = time.Now().UTC()
+	resp.RequestID = RequestID(c)
+	if _, ok := codes[resp.Code]; !ok {
+		logger.Warn("Error code is not in the catalog",
+			zap.String("code", resp.Code),
+			zap.String("endpoint", c.FullPath()),
+		)
+	}
+}
+
+// Respond writes an error response
+func Respond(c *gin.Context, status int, resp Response) {
+	Stamp(c, &resp)
+	c.JSON(status, resp)
+}
+
+// Abort writes an error response and stops the handler chain
+func Abort(c *gin.Context, status int, resp Response) {
+	Stamp(c, &resp)
+	c.AbortWithStatusJSON(status, resp)
+}
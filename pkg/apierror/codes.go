@@ -0,0 +1,300 @@
+package apierror
+
+import "sort"
+
+// Code is a documented error code
+type Code struct {
+	Code        string `json:"code"`
+	Description string `json:"description"`
+	Remediation string `json:"remediation"` // What a client can do about the error
+	Retryable   bool   `json:"retryable"`   // Whether the same request may succeed when sent again later
+}
+
+// codes documents every error code the API returns. A code names one condition
+// wherever it is used; the error and message of a response say which record or field
+// it concerns.
+var codes = map[string]string{
+	// Requests
+	"INVALID_REQUEST_BODY":         "The request body is not valid JSON for the endpoint or could not be read",
+	"VALIDATION_FAILED":            "The request body failed validation; the message names the fields",
+	"PROFILE_VALIDATION_FAILED":    "The resource violates the tenant's validation profile; details maps each rule's path to the violation",
+	"PROFILE_VALIDATION_ERROR":     "A validation profile or FHIR profile could not be applied",
+	"REQUEST_TOO_LARGE":            "The request body exceeds the size limit",
+	"INVALID_ENVELOPE":             "The _envelope parameter is not paginated, bare or bundle",
+	"ENVELOPE_NOT_AVAILABLE":       "The list cannot be returned in the requested envelope, such as a Bundle of records that are not FHIR resources",
+	"UNSUPPORTED_SEARCH_PARAMETER": "A search parameter is not supported by the endpoint",
+	"ERROR_CODE_NOT_FOUND":         "The error code is not in the catalog",
+	"UNSUPPORTED_MEDIA_TYPE":       "The content type of an upload is not accepted",
+	"INVALID_CURSOR":               "The pagination cursor is malformed, tampered with, or was issued to another user or for other filters",
+	"CURSOR_EXPIRED":               "The pagination cursor has expired",
+	"INVALID_DATE":                 "A date parameter is not a valid date",
+	"INVALID_DATE_RANGE":           "A date range is malformed or ends before it starts",
+	"INVALID_PERIOD":               "A reporting period is malformed or out of range",
+	"INVALID_FORMAT":               "The requested output format is not supported",
+	"INVALID_TARGET":               "The target of the operation is not valid",
+	"INVALID_TENANT":               "The tenant is unknown or malformed",
+	"INVALID_UNIT":                 "A unit is not a valid UCUM unit or not convertible to the required one",
+	"INVALID_BREAKDOWN":            "The report breakdown is not supported",
+	"INVALID_RESOURCE_TYPE":        "The resource type is not supported by the operation",
+	"MISSING_FILTER":               "The operation requires a filter",
+	"EMPTY_FILTER":                 "The filter has no criteria",
+	"UNEXPECTED_FILTER":            "The operation does not take a filter for the resource type",
+	"EMPTY_PATCH":                  "The patch sets no fields",
+	"UNEXPECTED_PATCH":             "The operation does not take a patch",
+	"SUBJECT_MISMATCH":             "The subject of the request is not the patient of the path or record",
+
+	// Authentication and authorization
+	"MISSING_AUTH_HEADER":      "The request has no Authorization header",
+	"INVALID_AUTH_FORMAT":      "The Authorization header is not a bearer token",
+	"INVALID_TOKEN":            "The access or refresh token is invalid or expired",
+	"NOT_AUTHENTICATED":        "The request is not authenticated",
+	"INVALID_CLAIMS":           "The token's claims could not be read",
+	"MISSING_CLAIMS":           "The token's claims are missing from the request",
+	"INSUFFICIENT_PERMISSIONS": "The user's roles do not allow the operation",
+	"INVALID_CREDENTIALS":      "The email or password is incorrect",
+	"INVALID_CURRENT_PASSWORD": "The current password given to change it is incorrect",
+	"TOKEN_REVOKED":            "The access token was revoked by logging out",
+	"REFRESH_TOKEN_REUSED":     "The refresh token was already used, so every token of its session is revoked",
+	"USER_INACTIVE":            "The user does not exist or is deactivated",
+	"USER_ALREADY_EXISTS":      "A user with the email already exists",
+	"USER_NOT_FOUND":           "The user does not exist",
+	"INVALID_ROLE":             "The role does not exist",
+	"ROLE_ASSIGNMENT_FAILED":   "The role could not be assigned to the user",
+	"SSO_NOT_CONFIGURED":       "Single sign-on with an identity provider is not configured",
+	"SSO_LOGIN_FAILED":         "The identity provider refused the login or its ID token did not verify",
+	"SSO_PROVIDER_ERROR":       "The identity provider could not be reached or returned an invalid response",
+	"INVALID_LOGIN_STATE":      "The single sign-on callback's state was not issued, has expired or was already used",
+	"NO_MAPPED_ROLE":           "None of the user's identity provider groups is mapped to a role",
+	"PASSWORD_HASH_FAILED":     "The password could not be hashed",
+	"TOKEN_GENERATION_FAILED":  "The access or refresh token could not be issued",
+	"TRANSACTION_FAILED":       "The operation's transaction could not be completed",
+	"ROTATION_FAILED":          "The signing key could not be rotated",
+	"ROTATION_IN_PROGRESS":     "A signing key rotation is already in progress",
+	"SANDBOX_UNSUPPORTED":      "The write is not available to a sandbox user",
+	"INVALID_SIGNATURE":        "The request or download URL signature is invalid or expired",
+
+	// Signed integration requests
+	"SIGNATURE_REQUIRED":      "The integration request is not signed",
+	"SIGNATURE_EXPIRED":       "The signature timestamp is outside the accepted tolerance",
+	"INVALID_TIMESTAMP":       "The signature timestamp is malformed",
+	"INVALID_NONCE":           "The request nonce is missing or has the wrong length",
+	"REPLAYED_REQUEST":        "A request with the nonce was already received",
+	"ACCOUNT_INACTIVE":        "The integration account is not active",
+	"CREDENTIAL_NOT_FOUND":    "The integration credential does not exist",
+	"CREDENTIAL_EXISTS":       "An integration credential with the name already exists",
+	"CREDENTIAL_REVOKED":      "The integration credential is already revoked",
+	"CREDENTIAL_REQUIRED":     "The destination needs a credential",
+	"CREDENTIAL_UNAVAILABLE":  "The credential the message arrived with is no longer available to resubmit it",
+	"MAPPING_EXISTS":          "The integration already maps the source",
+	"MAPPING_NOT_FOUND":       "The integration or concept mapping does not exist",
+	"DISPLAY_NOT_FOUND":       "The code has no display in the requested languages or English, or the concept display does not exist",
+	"INVALID_LOOKUP":          "The display lookup has no system or code",
+	"INVALID_MAPPING_HINTS":   "The mapping hints of a quarantined message are invalid",
+	"MESSAGE_NOT_FOUND":       "The quarantined message does not exist",
+	"MESSAGE_NOT_QUARANTINED": "The message is no longer quarantined",
+	"ENCRYPTION_ERROR":        "A secret could not be encrypted",
+	"INVALID_PUBLIC_KEY":      "The encryption public key is invalid",
+
+	// Patients
+	"MISSING_PATIENT_ID":             "The patient ID is missing from the path",
+	"PATIENT_NOT_FOUND":              "The patient does not exist, whether named in the path or referenced by the request",
+	"PATIENT_DELETED":                "The record's patient is pending deletion; undelete the patient instead",
+	"PATIENT_NOT_DELETED":            "The patient is not pending deletion",
+	"PATIENT_ALREADY_REPLACED":       "The patient is already replaced by another record",
+	"PATIENT_LINK_CYCLE":             "The link would make patients replace each other",
+	"PATIENT_LINK_EXISTS":            "The patients are already linked",
+	"PATIENT_LINK_NOT_FOUND":         "The patient link does not exist",
+	"INVALID_PATIENT_LINK":           "A patient cannot be linked to itself",
+	"INVALID_PATIENT_MERGE":          "A patient cannot be merged into itself",
+	"SURVIVOR_REPLACED":              "The surviving patient of a merge is itself replaced by another record",
+	"INVALID_AGE_PARAMETER":          "The age search parameter is malformed",
+	"INVALID_BIRTHDATE_PARAMETER":    "The birthdate search parameter is malformed",
+	"INVALID_NEAR_PARAMETER":         "The near search parameter is not latitude|longitude|distance",
+	"INVALID_SEARCH_TERM":            "The search term is missing, too short or too long, or holds characters outside the allowed set",
+	"INVALID_FILTER":                 "A filter value is malformed or holds characters outside the allowed set",
+	"CONTACT_NOT_FOUND":              "The contact is not, or no longer, on the patient",
+	"VERIFICATION_NOT_FOUND":         "The contact verification does not exist",
+	"VERIFICATION_CODE_INVALID":      "The verification code is incorrect",
+	"VERIFICATION_EXPIRED":           "The verification code expired; request a new one",
+	"VERIFICATION_ALREADY_CONFIRMED": "The contact verification is already confirmed",
+	"VERIFICATION_THROTTLED":         "A verification code was sent recently; wait before requesting another",
+	"NOTIFICATION_FAILED":            "The email or SMS could not be sent",
+
+	// Observations
+	"MISSING_OBSERVATION_ID":           "The observation ID is missing from the path",
+	"OBSERVATION_NOT_FOUND":            "The observation does not exist",
+	"OBSERVATION_EXISTS":               "The patient already has an observation with the same code, effective time and value",
+	"OBSERVATION_NOT_DELETED":          "The observation is not pending deletion",
+	"INVALID_CATEGORY":                 "An observation category is not defined",
+	"CATEGORY_EXISTS":                  "An observation category with the code already exists",
+	"CATEGORY_NOT_FOUND":               "The observation category does not exist",
+	"INVALID_VALUE_QUANTITY_PARAMETER": "The value-quantity search parameter is malformed",
+	"UNDO_WINDOW_EXPIRED":              "The undo window of the delete has passed",
+	"RECORD_NOT_DELETED":               "The record is not pending deletion",
+	"TRASH_ITEM_NOT_FOUND":             "The deleted resource does not exist or was purged",
+	"RULE_NOT_FOUND":                   "The delta check rule does not exist",
+	"CONTROL_EXISTS":                   "A QC control with the lot already exists",
+	"CONTROL_INACTIVE":                 "The QC control is not in use",
+	"CONTROL_NOT_FOUND":                "The QC control does not exist",
+	"ALERT_NOT_FOUND":                  "The alert does not exist",
+	"ALERT_ACKNOWLEDGED":               "The alert is already acknowledged",
+	"CHAIN_NOT_FOUND":                  "The on-call chain does not exist",
+	"PROFILE_EXISTS":                   "A validation profile with the name already exists",
+	"PROFILE_NOT_FOUND":                "The validation profile does not exist",
+	"INVALID_PROFILE_RULE":             "A validation profile rule is invalid",
+
+	// Clinical records
+	"CONDITION_NOT_FOUND":              "The condition does not exist or is not the patient's",
+	"CONDITION_IN_USE":                 "The condition is the reason of other records; mark it entered-in-error instead",
+	"INVALID_REASON_REFERENCE":         "A reasonReference or addresses entry does not reference a condition",
+	"PRACTITIONER_NOT_FOUND":           "The practitioner does not exist, whether named in the path or referenced as a performer",
+	"PRACTITIONER_IN_USE":              "The practitioner is the performer or collector of other records; deactivate the practitioner instead",
+	"INVALID_NPI":                      "The NPI's check digit does not match",
+	"NPI_EXISTS":                       "A practitioner with the NPI already exists",
+	"USER_ALREADY_LINKED":              "The user is already linked to another practitioner",
+	"ALLERGY_NOT_FOUND":                "The allergy does not exist",
+	"COVERAGE_NOT_FOUND":               "The coverage does not exist",
+	"COVERAGE_EXISTS":                  "The patient already has an active coverage with the payor and subscriber ID",
+	"RELATED_PERSON_NOT_FOUND":         "The related person does not exist or belongs to another patient",
+	"PROCEDURE_NOT_FOUND":              "The procedure does not exist",
+	"CARE_PLAN_NOT_FOUND":              "The care plan does not exist",
+	"CARE_PLAN_ENDED":                  "The care plan was completed, revoked or entered in error",
+	"CARE_PLAN_NOT_ACTIVE":             "The care plan is not active",
+	"ACTIVITY_NOT_FOUND":               "The activity does not exist on the care plan",
+	"ACTIVITY_ENDED":                   "The care plan activity is already completed or cancelled",
+	"INVALID_STATUS_TRANSITION":        "The record cannot move from its status to the one requested",
+	"CHART_SNAPSHOT_NOT_FOUND":         "The chart snapshot does not exist",
+	"SNAPSHOT_CORRUPTED":               "The chart snapshot's content no longer matches its checksum",
+	"LEGAL_HOLD_NOT_FOUND":             "The legal hold does not exist",
+	"LEGAL_HOLD_RELEASED":              "The legal hold has already been released",
+	"LEGAL_HOLD_ACTIVE":                "The resource is under a legal hold and cannot be purged or deleted",
+	"MISSING_NOTE_ID":                  "The clinical note ID is missing from the path",
+	"MISSING_SUBJECT":                  "The record has no subject",
+	"NOTE_NOT_FOUND":                   "The clinical note does not exist",
+	"NOTE_SIGNED":                      "The clinical note is signed and cannot be modified; append an addendum instead",
+	"NOTE_NOT_SIGNED":                  "Addenda can only be appended to signed notes",
+	"NOT_NOTE_AUTHOR":                  "Only the author can sign the clinical note",
+	"VERSION_CONFLICT":                 "The record was modified by another request",
+	"SPECIMEN_NOT_FOUND":               "The specimen does not exist",
+	"SPECIMEN_REJECTED":                "The specimen is already rejected",
+	"SPECIMEN_IN_USE":                  "Observations refer to the specimen; mark it entered-in-error instead",
+	"INVALID_SPECIMEN_REFERENCE":       "The specimen reference is malformed, or names a specimen of another patient, rejected or entered in error",
+	"ACCESSION_EXISTS":                 "A specimen with the accession already exists",
+	"DEVICE_NOT_FOUND":                 "The device does not exist",
+	"DEVICE_EXISTS":                    "A device of the manufacturer with the serial number already exists",
+	"DEVICE_IN_USE":                    "Observations refer to the device; mark it inactive or entered-in-error instead",
+	"INVALID_DEVICE_REFERENCE":         "The device reference is malformed, or names a device that is inactive or entered in error",
+	"ORDER_NOT_FOUND":                  "The standing order does not exist",
+	"ORDER_DISCONTINUED":               "The standing order is already discontinued",
+	"MEDICATION_REQUEST_NOT_FOUND":     "The medication request does not exist",
+	"MEDICATION_REQUEST_NOT_ACTIVE":    "The medication request is not active",
+	"MEDICATION_REQUEST_ENDED":         "The medication request has ended",
+	"DOSE_ALREADY_RECORDED":            "The scheduled dose is already recorded",
+	"PATIENT_BARCODE_MISMATCH":         "The scanned patient barcode does not match the medication request",
+	"MEDICATION_BARCODE_MISMATCH":      "The scanned medication barcode does not match the medication request",
+	"INTERACTION_OVERRIDE_REQUIRED":    "The medication interacts severely with the patient's medications; give an interaction override reason",
+	"ALLERGY_OVERRIDE_REQUIRED":        "The patient is allergic to the medication; give an allergy override reason",
+	"INTERACTION_CHECK_UNAVAILABLE":    "The drug interaction service is unavailable",
+	"INTERACTION_NOT_FOUND":            "The drug interaction does not exist",
+	"QUESTIONNAIRE_NOT_FOUND":          "The questionnaire does not exist",
+	"QUESTIONNAIRE_ALREADY_EXISTS":     "A questionnaire with the name already exists",
+	"QUESTIONNAIRE_NOT_ACTIVE":         "The questionnaire is not active",
+	"QUESTIONNAIRE_RESPONSE_NOT_FOUND": "The questionnaire response does not exist",
+	"INVALID_QUESTIONNAIRE_RESPONSE":   "The response does not match its questionnaire",
+	"SCORING_FAILED":                   "The questionnaire response could not be scored",
+	"MISSING_MEDIA_ID":                 "The media ID is missing from the path",
+	"MISSING_MEDIA_FILE":               "The upload has no media file",
+	"INVALID_MEDIA_FILE":               "The media file could not be read",
+	"MEDIA_NOT_FOUND":                  "The media does not exist",
+	"MEDIA_CONTENT_NOT_FOUND":          "The media's stored content does not exist",
+	"MEDIA_TOO_LARGE":                  "The media file exceeds the upload size limit",
+	"MISSING_DOCUMENT_FILE":            "The upload has no document file",
+	"INVALID_DOCUMENT_FILE":            "The document file is empty or could not be read",
+	"DOCUMENT_TOO_LARGE":               "The document file exceeds the upload size limit",
+	"DOCUMENT_REFERENCE_NOT_FOUND":     "The document reference does not exist",
+	"DOCUMENT_CONTENT_NOT_FOUND":       "The document's stored file does not exist",
+
+	// Exports, jobs and administration
+	"OPERATION_NOT_FOUND":          "The operation does not exist or was started by another user",
+	"OPERATION_FINISHED":           "The operation has already completed, failed or been cancelled",
+	"EXPORT_JOB_NOT_FOUND":         "The export job does not exist",
+	"EXPORT_FILE_NOT_FOUND":        "The export file does not exist",
+	"EXPORT_NOT_READY":             "The export job has not completed",
+	"EXPORT_ERROR":                 "The export could not be written",
+	"DEIDENTIFICATION_UNSUPPORTED": "De-identified exports are not available for the resource type",
+	"SCHEDULE_EXISTS":              "An export schedule with the name already exists",
+	"SCHEDULE_INACTIVE":            "The export schedule is not active",
+	"SCHEDULE_NOT_FOUND":           "The export schedule does not exist",
+	"INVALID_SCHEDULE_NAME":        "The schedule name has characters other than letters, digits, '.', '_' and '-'",
+	"BULK_JOB_NOT_FOUND":           "The bulk job does not exist",
+	"JOB_ALREADY_EXECUTED":         "The bulk job has already been executed",
+	"PREVIEW_EXPIRED":              "The bulk job preview has expired",
+	"PREVIEW_STALE":                "The records matched by the bulk job changed since the preview",
+	"BACKUP_NOT_FOUND":             "The backup does not exist",
+	"BACKUP_IN_PROGRESS":           "A backup is already in progress",
+	"BACKUP_NOT_READY":             "Only completed backups can be restored",
+	"BACKUP_UNSUPPORTED":           "Backups are not available for the database",
+	"RESTORE_DISABLED":             "Restores are not enabled",
+	"RESTORE_IN_PROGRESS":          "The backup is being restored",
+	"RESTORE_NOT_FOUND":            "The restore does not exist",
+	"CAPTURE_NOT_FOUND":            "The capture session does not exist",
+	"CAPTURE_ACTIVE":               "The user's requests are already being captured",
+	"CAPTURE_ENDED":                "The capture session has ended",
+	"INTEGRITY_RUN_NOT_FOUND":      "The integrity run does not exist",
+	"INDEX_NOT_FOUND":              "The database index is not one the server maintains",
+	"INDEX_UNSUPPORTED":            "The database index cannot be created on the database",
+	"INVALID_FIXTURE":              "A fixture entity is invalid",
+	"UNSUPPORTED_DATABASE":         "The report is not available on the database",
+	"ANALYTICS_VIEW_NOT_READY":     "The report's data has not been computed yet",
+	"SEARCH_INDEX_DISABLED":        "The search index is not enabled",
+	"SEARCH_INDEX_ERROR":           "The search index query failed",
+	"INVALID_CONSUMER":             "The event consumer is not running on the server",
+	"REPLAY_NOT_FOUND":             "The event replay does not exist",
+	"REPLAY_NOT_RESUMABLE":         "Only failed or cancelled event replays can be resumed",
+
+	// Billing
+	"BILLING_NOT_CONFIGURED": "Billing is not configured",
+	"CHARGE_NOT_FOUND":       "The charge does not exist",
+	"CHARGE_RULE_NOT_FOUND":  "The charge rule does not exist",
+	"CLAIMS_INCOMPLETE":      "Some claims are missing required data",
+	"NO_CLAIMS":              "There are no claims to export",
+
+	// Server errors
+	"DATABASE_ERROR":    "A database operation failed",
+	"STORAGE_ERROR":     "Stored content could not be read, written or deleted",
+	"WRITE_CHECK_ERROR": "A check of the resource before it is written could not run; the message names the check",
+	"INTERNAL_ERROR":    "An unexpected server error; give the reference to support",
+	"GATEWAY_TIMEOUT":   "The request ran past its route's timeout and was cancelled",
+	"SERVER_OVERLOADED": "The request's priority class is being shed under load; retry after Retry-After seconds",
+}
+
+// Describe returns the description of an error code
+func Describe(code string) (string, bool) {
+	description, ok := codes[code]
+	return description, ok
+}
+
+// Lookup returns the documentation of an error code
+func Lookup(code string) (Code, bool) {
+	description, ok := codes[code]
+	if !ok {
+		return Code{}, false
+	}
+	return document(code, description), true
+}
+
+// Codes returns the documented error codes in alphabetical order
+func Codes() []Code {
+	list := make([]Code, 0, len(codes))
+	for code, description := range codes {
+		list = append(list, document(code, description))
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Code < list[j].Code })
+	return list
+}
+
+// document returns the documentation of a catalogued code
+func document(code, description string) Code {
+	r := remediate(code)
+	return Code{Code: code, Description: description, Remediation: r.text, Retryable: r.retryable}
+}
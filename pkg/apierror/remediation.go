@@ -0,0 +1,167 @@
+package apierror
+
+import "strings"
+
+// remediation is what a client can do about an error, and whether the same request
+// may succeed when sent again later
+type remediation struct {
+	text      string
+	retryable bool
+}
+
+// Remediations shared by the rules and the codes below
+var (
+	serverFailure = remediation{
+		text:      "A server-side failure. Retry with exponential backoff; if it persists, report the requestId, and the reference of an internal error, to support.",
+		retryable: true,
+	}
+	correctRequest = remediation{
+		text: "Correct the request as the message describes; sent unchanged it fails again.",
+	}
+	authenticate = remediation{
+		text: "Log in with POST /api/v1/auth/login and send the access token as Authorization: Bearer {token}.",
+	}
+	notConfigured = remediation{
+		text: "The operation is not available with the server's configuration or database; ask an administrator rather than retrying.",
+	}
+)
+
+// remediations are the remediations of codes that need handling of their own
+var remediations = map[string]remediation{
+	"VALIDATION_FAILED":         correctRequest,
+	"PROFILE_VALIDATION_FAILED": correctRequest,
+	"ENVELOPE_NOT_AVAILABLE":    correctRequest,
+	"ERROR_CODE_NOT_FOUND":      {text: "List the catalog with GET /api/v1/errors; codes are upper case."},
+	"PROFILE_VALIDATION_ERROR":  {text: "Check the tenant's validation profile and the FHIR profiles it applies; an administrator may need to fix the profile."},
+
+	"MISSING_AUTH_HEADER":      authenticate,
+	"INVALID_AUTH_FORMAT":      authenticate,
+	"NOT_AUTHENTICATED":        authenticate,
+	"INVALID_CLAIMS":           authenticate,
+	"MISSING_CLAIMS":           authenticate,
+	"INVALID_TOKEN":            {text: "Get a new access token by sending the refresh token to POST /api/v1/auth/refresh, or log in again when the refresh token has expired too."},
+	"TOKEN_REVOKED":            {text: "Log in again; the session was ended."},
+	"REFRESH_TOKEN_REUSED":     {text: "Log in again. Use each refresh token once and keep the one returned in its place; check the client for a leaked token."},
+	"INSUFFICIENT_PERMISSIONS": {text: "Ask an administrator for a role that allows the operation; retrying does not help."},
+	"INVALID_CREDENTIALS":      {text: "Check the email and password; do not retry automatically."},
+	"INVALID_CURRENT_PASSWORD": {text: "Send the user's current password to change it."},
+	"USER_INACTIVE":            {text: "Ask an administrator to reactivate the user."},
+	"SSO_NOT_CONFIGURED":       {text: "Log in with email and password at POST /api/v1/auth/login."},
+	"SSO_LOGIN_FAILED":         {text: "Start the login again with GET /api/v1/auth/oidc/login; ask an administrator if the identity provider keeps refusing it."},
+	"SSO_PROVIDER_ERROR":       {text: "The identity provider could not be reached. Retry later, or log in with email and password.", retryable: true},
+	"INVALID_LOGIN_STATE":      {text: "Start the login again with GET /api/v1/auth/oidc/login and complete it within 10 minutes; each login completes once."},
+	"NO_MAPPED_ROLE":           {text: "Ask an administrator to add the user to an identity provider group mapped to a role."},
+	"SANDBOX_UNSUPPORTED":      {text: "Make the write as a user outside the sandbox."},
+	"INVALID_SIGNATURE":        {text: "Request a new signed download URL, or sign the request again with the credential's current secret."},
+	"SIGNATURE_REQUIRED":       {text: "Sign the request with the integration credential's secret."},
+	"SIGNATURE_EXPIRED":        {text: "Synchronize the client's clock and sign the request again with the current time."},
+	"REPLAYED_REQUEST":         {text: "Sign the request again with a new nonce; a nonce can only be used once."},
+	"ACCOUNT_INACTIVE":         {text: "Ask an administrator to reactivate the integration account."},
+	"CREDENTIAL_REVOKED":       {text: "The credential is already revoked; issue a new one if the integration still needs access."},
+
+	"VERSION_CONFLICT":              {text: "Fetch the record again, reapply the change and send it again.", retryable: true},
+	"PREVIEW_EXPIRED":               {text: "Preview the bulk job again and execute the new preview."},
+	"PREVIEW_STALE":                 {text: "Preview the bulk job again to see the records it now matches, then execute the new preview."},
+	"CURSOR_EXPIRED":                {text: "Pass the cursor of a more recent page, which is issued afresh on every page, or start the listing again without a cursor."},
+	"EXPORT_NOT_READY":              {text: "Poll the export job until it completes, then download the file.", retryable: true},
+	"BACKUP_NOT_READY":              {text: "Restore a completed backup instead."},
+	"ANALYTICS_VIEW_NOT_READY":      {text: "Retry after the Retry-After delay, once the first refresh of the report's data completes.", retryable: true},
+	"VERIFICATION_EXPIRED":          {text: "Start a new verification to send a new code."},
+	"VERIFICATION_CODE_INVALID":     {text: "Ask the patient for the code again; start a new verification if it cannot be found."},
+	"NOTIFICATION_FAILED":           {text: "The email or SMS provider could not be reached. Retry later.", retryable: true},
+	"INTERACTION_CHECK_UNAVAILABLE": {text: "The drug interaction service could not be reached. Retry later.", retryable: true},
+	"INTERACTION_OVERRIDE_REQUIRED": {text: "Review the interaction, then send the request again with an interaction override reason."},
+	"ALLERGY_OVERRIDE_REQUIRED":     {text: "Review the allergy, then send the request again with an allergy override reason."},
+	"LEGAL_HOLD_ACTIVE":             {text: "The record cannot be purged or deleted until compliance staff release its legal holds."},
+	"SNAPSHOT_CORRUPTED":            {text: "Report the snapshot to compliance staff; do not rely on its content. Take a new snapshot if the chart is still needed."},
+	"PATIENT_ALREADY_REPLACED":      {text: "Fetch the patient's links to find the record that replaces it, and link or merge that record instead."},
+	"SURVIVOR_REPLACED":             {text: "Merge into the record the message names, which replaces the survivor."},
+	"USER_ALREADY_LINKED":           {text: "Unlink the user from the other practitioner first, or link another user."},
+	"NOT_NOTE_AUTHOR":               {text: "Ask the note's author to sign it."},
+	"PATIENT_DELETED":               {text: "Undelete the patient with POST /api/v1/patients/{id}/undelete before writing records for them."},
+	"NOTE_SIGNED":                   {text: "Append an addendum to the signed note instead."},
+	"CLAIMS_INCOMPLETE":             {text: "Complete the data the message lists for each claim, then export again."},
+	"CAPTURE_ACTIVE":                {text: "End the user's capture session before starting another."},
+	"DEIDENTIFICATION_UNSUPPORTED":  {text: "Export the resource type without de-identification, or leave it out of the export."},
+
+	"GATEWAY_TIMEOUT":   {text: "Retry later, or narrow the request with filters or smaller pages so it finishes within the route's timeout.", retryable: true},
+	"SERVER_OVERLOADED": {text: "Retry after the Retry-After delay, with backoff.", retryable: true},
+}
+
+// remediationRules give the remediation of the other codes by the shape of their
+// name; the first rule to match applies
+var remediationRules = []struct {
+	match       func(code string) bool
+	remediation remediation
+}{
+	{
+		match: func(code string) bool {
+			return code == "DATABASE_ERROR" || code == "STORAGE_ERROR" || code == "INTERNAL_ERROR" ||
+				strings.HasSuffix(code, "_FAILED") || strings.HasSuffix(code, "_ERROR")
+		},
+		remediation: serverFailure,
+	},
+	{
+		match:       func(code string) bool { return strings.HasSuffix(code, "_NOT_FOUND") },
+		remediation: remediation{text: "Check the ID or reference. The record may not exist, may have been deleted or purged, or may belong to another patient or user."},
+	},
+	{
+		match: func(code string) bool {
+			return strings.HasSuffix(code, "_IN_PROGRESS") || strings.HasSuffix(code, "_THROTTLED")
+		},
+		remediation: remediation{text: "Wait for the running operation to finish, or the throttle to pass, then send the request again.", retryable: true},
+	},
+	{
+		match:       func(code string) bool { return strings.HasSuffix(code, "_EXISTS") },
+		remediation: remediation{text: "Use the existing record, or change the value that must be unique."},
+	},
+	{
+		match:       func(code string) bool { return strings.Contains(code, "ALREADY_") },
+		remediation: remediation{text: "The change has already been made; fetch the record and continue from its current state."},
+	},
+	{
+		match:       func(code string) bool { return strings.HasSuffix(code, "_IN_USE") },
+		remediation: remediation{text: "Other records refer to it; mark it inactive or entered-in-error instead of deleting it."},
+	},
+	{
+		match:       func(code string) bool { return strings.HasSuffix(code, "_TOO_LARGE") },
+		remediation: remediation{text: "Send a smaller request or file; the limit is set by the server's configuration."},
+	},
+	{
+		match: func(code string) bool {
+			return strings.HasSuffix(code, "_UNSUPPORTED") || strings.HasSuffix(code, "_NOT_CONFIGURED") ||
+				strings.HasSuffix(code, "_DISABLED") || code == "UNSUPPORTED_DATABASE"
+		},
+		remediation: notConfigured,
+	},
+	{
+		match: func(code string) bool {
+			for _, prefix := range []string{"INVALID_", "MISSING_", "EMPTY_", "UNEXPECTED_", "UNSUPPORTED_"} {
+				if strings.HasPrefix(code, prefix) {
+					return true
+				}
+			}
+			return strings.HasSuffix(code, "_MISMATCH") || strings.HasSuffix(code, "_PARAMETER")
+		},
+		remediation: correctRequest,
+	},
+}
+
+// stateConflict is the remediation of codes no rule matches, which report a record
+// whose state does not allow the operation
+var stateConflict = remediation{
+	text: "The record's current state does not allow the operation; fetch it to see its state before trying something else.",
+}
+
+// remediate returns the remediation of a code
+func remediate(code string) remediation {
+	if r, ok := remediations[code]; ok {
+		return r
+	}
+	for _, rule := range remediationRules {
+		if rule.match(code) {
+			return rule.remediation
+		}
+	}
+	return stateConflict
+}
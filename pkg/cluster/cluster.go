@@ -0,0 +1,317 @@
+// Package cluster coordinates background work between API replicas with PostgreSQL
+// advisory locks, or MySQL named locks. A lock is held by a database session, so it is
+// released as soon as its holder exits or loses its connection, without leases to
+// expire.
+package cluster
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"sync"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// ErrLocked is returned when a lock is held by another process
+var ErrLocked = errors.New("cluster: lock is held by another process")
+
+// checkInterval is how often a held lock's session is checked
+const checkInterval = 5 * time.Second
+
+var lockHeld = promauto.NewGaugeVec(
+	prometheus.GaugeOpts{
+		Name: "cluster_lock_held",
+		Help: "Whether this process holds the named cluster lock (1) or not (0)",
+	},
+	[]string{"lock"},
+)
+
+// Lock is a held cluster lock
+type Lock struct {
+	name    string
+	key     int64
+	dialect string
+	conn    *sql.Conn // Nil for process-local locks
+}
+
+var (
+	localMu sync.Mutex
+	local   = map[string]bool{}
+)
+
+// Acquire takes the named lock without waiting, returning ErrLocked when another
+// process holds it. On SQLite, which runs as a single process, the lock only excludes
+// holders within this process.
+func Acquire(ctx context.Context, db *gorm.DB, name string) (*Lock, error) {
+	l := &Lock{name: name, key: lockKey(name), dialect: dialect.Of(db).Name()}
+
+	if l.dialect == dialect.SQLite {
+		localMu.Lock()
+		defer localMu.Unlock()
+		if local[name] {
+			return nil, ErrLocked
+		}
+		local[name] = true
+		lockHeld.WithLabelValues(name).Set(1)
+		return l, nil
+	}
+
+	sqlDB, err := db.DB()
+	if err != nil {
+		return nil, err
+	}
+	// Advisory locks belong to a session, so the lock keeps its own connection
+	conn, err := sqlDB.Conn(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var acquired sql.NullBool
+	if l.dialect == dialect.MySQL {
+		// GET_LOCK returns NULL on error, which is not taken as the lock being held
+		err = conn.QueryRowContext(ctx, "SELECT GET_LOCK(?, 0)", l.mysqlName()).Scan(&acquired)
+		if err == nil && !acquired.Valid {
+			err = fmt.Errorf("cluster: failed to take lock %s", name)
+		}
+	} else {
+		err = conn.QueryRowContext(ctx, "SELECT pg_try_advisory_lock($1)", l.key).Scan(&acquired)
+	}
+	if err != nil {
+		discard(conn)
+		return nil, err
+	}
+	if !acquired.Bool {
+		conn.Close()
+		return nil, ErrLocked
+	}
+
+	l.conn = conn
+	lockHeld.WithLabelValues(name).Set(1)
+	return l, nil
+}
+
+// Wait takes the named lock, retrying every interval while another process holds it,
+// until ctx is done. The wait is logged so that a replica held up at startup says
+// what it is waiting for.
+func Wait(ctx context.Context, db *gorm.DB, name string, interval time.Duration) (*Lock, error) {
+	start := time.Now()
+	waiting := false
+	for {
+		lock, err := Acquire(ctx, db, name)
+		if err == nil {
+			if waiting {
+				logger.Info("Acquired cluster lock", zap.String("lock", name), zap.Duration("waited", time.Since(start)))
+			}
+			return lock, nil
+		}
+		if !errors.Is(err, ErrLocked) {
+			return nil, err
+		}
+		if !waiting {
+			waiting = true
+			logger.Info("Waiting for cluster lock held by another process", zap.String("lock", name))
+		}
+
+		timer := time.NewTimer(interval)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return nil, fmt.Errorf("cluster: gave up waiting for lock %s after %s: %w", name, time.Since(start).Round(time.Millisecond), ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// Name returns the lock name
+func (l *Lock) Name() string {
+	return l.name
+}
+
+// Check reports an error when the session holding the lock has been lost, after
+// which another process may take the lock
+func (l *Lock) Check(ctx context.Context) error {
+	if l.conn == nil {
+		return nil
+	}
+	return l.conn.PingContext(ctx)
+}
+
+// Release gives up the lock
+func (l *Lock) Release() {
+	lockHeld.WithLabelValues(l.name).Set(0)
+
+	if l.conn == nil {
+		localMu.Lock()
+		delete(local, l.name)
+		localMu.Unlock()
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), checkInterval)
+	defer cancel()
+	var err error
+	if l.dialect == dialect.MySQL {
+		_, err = l.conn.ExecContext(ctx, "SELECT RELEASE_LOCK(?)", l.mysqlName())
+	} else {
+		_, err = l.conn.ExecContext(ctx, "SELECT pg_advisory_unlock($1)", l.key)
+	}
+	if err != nil {
+		// The session must not go back to the pool still holding the lock
+		logger.Warn("Failed to release cluster lock", zap.String("lock", l.name), zap.Error(err))
+		discard(l.conn)
+		return
+	}
+	l.conn.Close()
+}
+
+// discard closes the connection instead of returning it to the pool
+func discard(conn *sql.Conn) {
+	conn.Raw(func(interface{}) error { return driver.ErrBadConn })
+	conn.Close()
+}
+
+// mysqlName returns the MySQL lock name; names are limited to 64 characters, so the
+// lock's key is used instead of its name
+func (l *Lock) mysqlName() string {
+	return fmt.Sprintf("healthhub:%016x", uint64(l.key))
+}
+
+// lockKey maps a lock name to an advisory lock key
+func lockKey(name string) int64 {
+	h := fnv.New64a()
+	h.Write([]byte("healthhub:" + name))
+	return int64(h.Sum64())
+}
+
+// Singleton runs a background worker on one replica at a time. Every replica runs
+// the singleton; the one holding its lock runs the worker while the others retry
+// periodically and take over when the holder stops.
+type Singleton struct {
+	db    *gorm.DB
+	name  string
+	retry time.Duration
+	run   func(ctx context.Context)
+
+	mu      sync.Mutex
+	leading bool
+}
+
+var (
+	singletonsMu sync.Mutex
+	singletons   []*Singleton
+)
+
+// NewSingleton creates a singleton for the named worker, retrying for the lock every
+// retry interval. The context passed to run is cancelled when the lock is lost.
+func NewSingleton(db *gorm.DB, name string, retry time.Duration, run func(ctx context.Context)) *Singleton {
+	if retry <= 0 {
+		retry = 15 * time.Second
+	}
+	s := &Singleton{db: db, name: name, retry: retry, run: run}
+
+	singletonsMu.Lock()
+	singletons = append(singletons, s)
+	singletonsMu.Unlock()
+	return s
+}
+
+// Leading reports whether this replica is running the worker
+func (s *Singleton) Leading() bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.leading
+}
+
+// Run campaigns for the worker's lock until ctx is cancelled, running the worker
+// whenever it is held. A worker that returns on its own is started again after
+// the retry interval.
+func (s *Singleton) Run(ctx context.Context) {
+	lockName := "worker:" + s.name
+	for {
+		lock, err := Acquire(ctx, s.db, lockName)
+		switch {
+		case err == nil:
+			s.lead(ctx, lock)
+		case !errors.Is(err, ErrLocked) && ctx.Err() == nil:
+			logger.Warn("Failed to acquire worker lock", zap.String("worker", s.name), zap.Error(err))
+		}
+
+		timer := time.NewTimer(s.retry)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+	}
+}
+
+// lead runs the worker until it returns, ctx is cancelled or the lock is lost
+func (s *Singleton) lead(ctx context.Context, lock *Lock) {
+	defer lock.Release()
+
+	leaderCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	s.setLeading(true)
+	defer s.setLeading(false)
+	logger.Info("Acquired worker lock", zap.String("worker", s.name))
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		s.run(leaderCtx)
+	}()
+
+	ticker := time.NewTicker(checkInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-done:
+			return
+		case <-ticker.C:
+			checkCtx, cancelCheck := context.WithTimeout(ctx, checkInterval)
+			err := lock.Check(checkCtx)
+			cancelCheck()
+			if err != nil && ctx.Err() == nil {
+				// Another replica may already have taken over
+				logger.Warn("Lost worker lock", zap.String("worker", s.name), zap.Error(err))
+				cancel()
+				<-done
+				return
+			}
+		}
+	}
+}
+
+func (s *Singleton) setLeading(leading bool) {
+	s.mu.Lock()
+	s.leading = leading
+	s.mu.Unlock()
+}
+
+// Workers returns the state of every singleton of this process, "leader" or "standby"
+func Workers() map[string]string {
+	singletonsMu.Lock()
+	defer singletonsMu.Unlock()
+
+	states := make(map[string]string, len(singletons))
+	for _, s := range singletons {
+		state := "standby"
+		if s.Leading() {
+			state = "leader"
+		}
+		states[s.name] = state
+	}
+	return states
+}
@@ -0,0 +1,68 @@
+package cluster_test
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/cluster"
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"gorm.io/gorm"
+	gormlogger "gorm.io/gorm/logger"
+)
+
+// open connects to the database at url, or skips the test when url is empty
+func open(t *testing.T, url string) *gorm.DB {
+	if url == "" {
+		t.Skip("no database configured")
+	}
+	db, err := database.Open(url)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db.Logger = gormlogger.Discard
+	t.Cleanup(func() { database.CloseDB(db) })
+	return db
+}
+
+func TestAcquire(t *testing.T) {
+	logger.Init("error")
+	urls := map[string]string{
+		"sqlite":   "sqlite://" + filepath.Join(t.TempDir(), "cluster.db"),
+		"postgres": os.Getenv("DB_URL"),
+		"mysql":    os.Getenv("MYSQL_URL"),
+	}
+	for name, url := range urls {
+		t.Run(name, func(t *testing.T) {
+			db := open(t, url)
+			ctx := context.Background()
+
+			lock, err := cluster.Acquire(ctx, db, "test:"+name)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// On PostgreSQL and MySQL the second attempt runs on another session
+			if _, err := cluster.Acquire(ctx, db, "test:"+name); !errors.Is(err, cluster.ErrLocked) {
+				t.Fatalf("second Acquire returned %v, want ErrLocked", err)
+			}
+			other, err := cluster.Acquire(ctx, db, "test:"+name+":other")
+			if err != nil {
+				t.Fatalf("Acquire of another lock: %v", err)
+			}
+			other.Release()
+			if err := lock.Check(ctx); err != nil {
+				t.Fatal(err)
+			}
+
+			lock.Release()
+			again, err := cluster.Acquire(ctx, db, "test:"+name)
+			if err != nil {
+				t.Fatalf("Acquire after Release: %v", err)
+			}
+			again.Release()
+		})
+	}
+}
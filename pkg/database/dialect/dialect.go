@@ -0,0 +1,223 @@
+// Package dialect renders the SQL fragments that differ between the supported
+// databases: JSON column access, case-insensitive matching, date casts and index
+// definitions. Query builders ask the dialect of their connection for a fragment
+// instead of writing PostgreSQL syntax directly.
+package dialect
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// Dialect names, as reported by the gorm driver
+const (
+	Postgres = "postgres"
+	MySQL    = "mysql"
+	SQLite   = "sqlite"
+)
+
+// Dialect renders database specific SQL. Fragments containing "?" take their
+// arguments in the same order as the PostgreSQL form.
+type Dialect interface {
+	// Name returns the dialect name
+	Name() string
+	// JSONType returns the column type used for JSON documents
+	JSONType() string
+	// JSONText returns the text value at path inside a JSON column. Numeric path
+	// elements index arrays; without a path the whole document is returned as text.
+	// Columns and keys must be identifiers, as in every method taking a column.
+	JSONText(column string, path ...string) string
+	// JSONString returns the JSON document at path, or the whole column, serialized as
+	// text for substring matching
+	JSONString(column string, path ...string) string
+	// ContainsCoding returns a condition that an array of CodeableConcepts holds a
+	// coding matching the bound document, shaped [{"coding":[{"system":...,"code":...}]}]
+	ContainsCoding(column string) string
+	// ContainsReference returns a condition that an array of References holds one
+	// with the reference of the bound document, shaped [{"reference":...}]
+	ContainsReference(column string) string
+	// ILike returns a case-insensitive LIKE of expr against a placeholder, with
+	// backslash as the escape character
+	ILike(expr string) string
+	// Date returns expr cast to a calendar date; expr may be a placeholder
+	Date(expr string) string
+	// CreateIndex returns the statement creating an index on columns
+	CreateIndex(name, table string, columns ...string) string
+	// CreateJSONIndex returns the statement creating an index over a whole JSON
+	// column, or "" when the database cannot index one
+	CreateJSONIndex(name, table, column string) string
+}
+
+// Of returns the dialect of the connection. Unknown drivers are treated as
+// PostgreSQL.
+func Of(db *gorm.DB) Dialect {
+	if db != nil && db.Dialector != nil {
+		switch db.Dialector.Name() {
+		case MySQL:
+			return mysqlDialect{}
+		case SQLite:
+			return sqliteDialect{}
+		}
+	}
+	return postgresDialect{}
+}
+
+// IsPostgres reports whether the connection is to PostgreSQL. Searches built on
+// PostgreSQL JSON functions without an equivalent elsewhere check it first.
+func IsPostgres(db *gorm.DB) bool {
+	return Of(db).Name() == Postgres
+}
+
+type postgresDialect struct{}
+
+func (postgresDialect) Name() string     { return Postgres }
+func (postgresDialect) JSONType() string { return "jsonb" }
+
+func (postgresDialect) JSONText(column string, path ...string) string {
+	if len(path) == 0 {
+		return mustIdentifier(column) + "::text"
+	}
+	return pgPath(column, path[:len(path)-1]) + "->>" + pgKey(path[len(path)-1])
+}
+
+func (postgresDialect) JSONString(column string, path ...string) string {
+	if len(path) == 0 {
+		return mustIdentifier(column) + "::text"
+	}
+	return "(" + pgPath(column, path) + ")::text"
+}
+
+func (postgresDialect) ContainsCoding(column string) string {
+	return mustIdentifier(column) + " @> ?::jsonb"
+}
+
+func (postgresDialect) ContainsReference(column string) string {
+	return mustIdentifier(column) + " @> ?::jsonb"
+}
+
+func (postgresDialect) ILike(expr string) string { return expr + " ILIKE ?" }
+func (postgresDialect) Date(expr string) string  { return expr + "::date" }
+
+func (postgresDialect) CreateIndex(name, table string, columns ...string) string {
+	return fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s (%s)", name, table, strings.Join(columns, ", "))
+}
+
+func (postgresDialect) CreateJSONIndex(name, table, column string) string {
+	return fmt.Sprintf("CREATE INDEX CONCURRENTLY IF NOT EXISTS %s ON %s USING GIN (%s)", name, table, column)
+}
+
+// pgPath walks a JSON column with the -> operator
+func pgPath(column string, path []string) string {
+	expr := mustIdentifier(column)
+	for _, key := range path {
+		expr += "->" + pgKey(key)
+	}
+	return expr
+}
+
+// pgKey quotes an object key; array indexes stay numeric
+func pgKey(key string) string {
+	if _, err := strconv.Atoi(key); err == nil {
+		return key
+	}
+	return "'" + mustIdentifier(key) + "'"
+}
+
+type mysqlDialect struct{}
+
+func (mysqlDialect) Name() string     { return MySQL }
+func (mysqlDialect) JSONType() string { return "json" }
+
+func (mysqlDialect) JSONText(column string, path ...string) string {
+	return fmt.Sprintf("JSON_UNQUOTE(JSON_EXTRACT(%s, '%s'))", mustIdentifier(column), jsonPath(path))
+}
+
+func (mysqlDialect) JSONString(column string, path ...string) string {
+	if len(path) == 0 {
+		return "CAST(" + mustIdentifier(column) + " AS CHAR)"
+	}
+	return fmt.Sprintf("CAST(JSON_EXTRACT(%s, '%s') AS CHAR)", mustIdentifier(column), jsonPath(path))
+}
+
+func (mysqlDialect) ContainsCoding(column string) string {
+	return "JSON_CONTAINS(" + mustIdentifier(column) + ", ?)"
+}
+
+func (mysqlDialect) ContainsReference(column string) string {
+	return "JSON_CONTAINS(" + mustIdentifier(column) + ", ?)"
+}
+
+// JSON and text compare with binary collations, so both sides are lowered
+func (mysqlDialect) ILike(expr string) string { return "LOWER(" + expr + ") LIKE LOWER(?)" }
+func (mysqlDialect) Date(expr string) string  { return "DATE(" + expr + ")" }
+
+func (mysqlDialect) CreateIndex(name, table string, columns ...string) string {
+	return fmt.Sprintf("CREATE INDEX %s ON %s (%s)", name, table, strings.Join(columns, ", "))
+}
+
+// MySQL indexes JSON only through generated columns
+func (mysqlDialect) CreateJSONIndex(name, table, column string) string { return "" }
+
+type sqliteDialect struct{}
+
+func (sqliteDialect) Name() string { return SQLite }
+
+// SQLite stores JSON as text; the declared type only sets the column affinity
+func (sqliteDialect) JSONType() string { return "json" }
+
+func (sqliteDialect) JSONText(column string, path ...string) string {
+	if len(path) == 0 {
+		return mustIdentifier(column)
+	}
+	return fmt.Sprintf("json_extract(%s, '%s')", mustIdentifier(column), jsonPath(path))
+}
+
+func (sqliteDialect) JSONString(column string, path ...string) string {
+	if len(path) == 0 {
+		return mustIdentifier(column)
+	}
+	return fmt.Sprintf("json_extract(%s, '%s')", mustIdentifier(column), jsonPath(path))
+}
+
+func (sqliteDialect) ContainsCoding(column string) string {
+	return `EXISTS (
+		SELECT 1 FROM json_each(` + mustIdentifier(column) + `) AS concept,
+			json_each(concept.value, '$.coding') AS coding,
+			json_each(?, '$[0].coding') AS wanted
+		WHERE json_extract(coding.value, '$.code') = json_extract(wanted.value, '$.code')
+		AND (json_extract(wanted.value, '$.system') IS NULL
+			OR json_extract(coding.value, '$.system') = json_extract(wanted.value, '$.system')))`
+}
+
+func (sqliteDialect) ContainsReference(column string) string {
+	return `EXISTS (
+		SELECT 1 FROM json_each(` + mustIdentifier(column) + `) AS ref
+		WHERE json_extract(ref.value, '$.reference') = json_extract(?, '$[0].reference'))`
+}
+
+// SQLite's LIKE already ignores ASCII case but has no default escape character
+func (sqliteDialect) ILike(expr string) string { return expr + ` LIKE ? ESCAPE '\'` }
+func (sqliteDialect) Date(expr string) string  { return "date(" + expr + ")" }
+
+func (sqliteDialect) CreateIndex(name, table string, columns ...string) string {
+	return fmt.Sprintf("CREATE INDEX IF NOT EXISTS %s ON %s (%s)", name, table, strings.Join(columns, ", "))
+}
+
+func (sqliteDialect) CreateJSONIndex(name, table, column string) string { return "" }
+
+// jsonPath renders a SQL/JSON path such as $.coding[0].code
+func jsonPath(path []string) string {
+	var b strings.Builder
+	b.WriteString("$")
+	for _, key := range path {
+		if _, err := strconv.Atoi(key); err == nil {
+			b.WriteString("[" + key + "]")
+		} else {
+			b.WriteString(`."` + mustIdentifier(key) + `"`)
+		}
+	}
+	return b.String()
+}
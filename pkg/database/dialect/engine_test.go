@@ -0,0 +1,144 @@
+package dialect_test
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/database"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm"
+	"gorm.io/gorm/logger"
+)
+
+// engines opens every database the dialects can be tested against: SQLite always,
+// and PostgreSQL and MySQL when DB_URL and MYSQL_URL name one
+func engines(t *testing.T) map[string]*gorm.DB {
+	urls := map[string]string{dialect.SQLite: "sqlite://" + filepath.Join(t.TempDir(), "dialect.db")}
+	if url := os.Getenv("DB_URL"); url != "" {
+		urls[dialect.Postgres] = url
+	}
+	if url := os.Getenv("MYSQL_URL"); url != "" {
+		urls[dialect.MySQL] = url
+	}
+
+	dbs := make(map[string]*gorm.DB, len(urls))
+	for name, url := range urls {
+		db, err := database.Open(url)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		db.Logger = logger.Discard
+		t.Cleanup(func() { database.CloseDB(db) })
+		dbs[name] = db
+	}
+	return dbs
+}
+
+// dialectItems are the rows the fragments are run against
+var dialectItems = []struct {
+	id, doc, category, refs string
+}{
+	{"1", `{"code":{"text":"Heart rate","coding":[{"system":"http://loinc.org","code":"8867-4"}]}}`,
+		`[{"coding":[{"system":"http://terminology.hl7.org/CodeSystem/observation-category","code":"vital-signs"}]}]`,
+		`[{"reference":"Patient/1"}]`},
+	{"2", `{"code":{"text":"Glucose","coding":[{"system":"http://loinc.org","code":"2345-7"}]}}`,
+		`[{"coding":[{"system":"http://terminology.hl7.org/CodeSystem/observation-category","code":"laboratory"}]}]`,
+		`[{"reference":"Patient/2"},{"reference":"Patient/3"}]`},
+}
+
+func TestDialectQueries(t *testing.T) {
+	for name, db := range engines(t) {
+		t.Run(name, func(t *testing.T) {
+			d := dialect.Of(db)
+			if d.Name() != name {
+				t.Fatalf("dialect is %s", d.Name())
+			}
+
+			db.Exec("DROP TABLE IF EXISTS dialect_items")
+			t.Cleanup(func() { db.Exec("DROP TABLE IF EXISTS dialect_items") })
+			create := "CREATE TABLE dialect_items (id VARCHAR(64) PRIMARY KEY, doc " + d.JSONType() +
+				", category " + d.JSONType() + ", refs " + d.JSONType() + ")"
+			if err := db.Exec(create).Error; err != nil {
+				t.Fatal(err)
+			}
+			for _, item := range dialectItems {
+				if err := db.Exec("INSERT INTO dialect_items (id, doc, category, refs) VALUES (?, ?, ?, ?)",
+					item.id, item.doc, item.category, item.refs).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+			if err := db.Exec(d.CreateIndex("idx_dialect_items_id", "dialect_items", "id")).Error; err != nil {
+				t.Fatal(err)
+			}
+			if statement := d.CreateJSONIndex("idx_dialect_items_category", "dialect_items", "category"); statement != "" {
+				if err := db.Exec(statement).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			text := d.JSONText("doc", "code", "text")
+			contains, containsArgs := dialect.ContainsAny(d, "HEART", text, d.JSONText("doc", "code", "coding", "0", "code"))
+			tests := []struct {
+				name  string
+				where string
+				args  []interface{}
+				want  []string
+			}{
+				{"JSONText", d.JSONText("doc", "code", "coding", "0", "code") + " = ?", []interface{}{"2345-7"}, []string{"2"}},
+				{"JSONString", d.JSONString("doc", "code") + " LIKE ?", []interface{}{"%8867-4%"}, []string{"1"}},
+				{"ContainsCoding", d.ContainsCoding("category"),
+					[]interface{}{`[{"coding":[{"system":"http://terminology.hl7.org/CodeSystem/observation-category","code":"laboratory"}]}]`}, []string{"2"}},
+				{"ContainsCoding without system", d.ContainsCoding("category"),
+					[]interface{}{`[{"coding":[{"code":"vital-signs"}]}]`}, []string{"1"}},
+				{"ContainsReference", d.ContainsReference("refs"), []interface{}{`[{"reference":"Patient/3"}]`}, []string{"2"}},
+				{"ContainsAny", contains, containsArgs, []string{"1"}},
+				{"ILike", d.ILike(text), []interface{}{dialect.Contains("gluc")}, []string{"2"}},
+				{"Date", d.Date("?") + " = " + d.Date("?"), []interface{}{"2024-03-09", "2024-03-09"}, []string{"1", "2"}},
+			}
+			for _, tt := range tests {
+				var ids []string
+				if err := db.Table("dialect_items").Where(tt.where, tt.args...).Order("id").Pluck("id", &ids).Error; err != nil {
+					t.Errorf("%s: %v", tt.name, err)
+					continue
+				}
+				if !reflect.DeepEqual(ids, tt.want) {
+					t.Errorf("%s: %s matched %v, want %v", tt.name, strings.TrimSpace(tt.where), ids, tt.want)
+				}
+			}
+		})
+	}
+}
+
+// TestContainsLiteral checks that LIKE wildcards in a filter value match only
+// themselves
+func TestContainsLiteral(t *testing.T) {
+	for name, db := range engines(t) {
+		t.Run(name, func(t *testing.T) {
+			d := dialect.Of(db)
+			db.Exec("DROP TABLE IF EXISTS dialect_texts")
+			t.Cleanup(func() { db.Exec("DROP TABLE IF EXISTS dialect_texts") })
+			if err := db.Exec("CREATE TABLE dialect_texts (id VARCHAR(64) PRIMARY KEY, text VARCHAR(64))").Error; err != nil {
+				t.Fatal(err)
+			}
+			for id, text := range map[string]string{"1": "a_b", "2": "axb", "3": "100%", "4": "1000"} {
+				if err := db.Exec("INSERT INTO dialect_texts (id, text) VALUES (?, ?)", id, text).Error; err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for value, want := range map[string][]string{"a_b": {"1"}, "A_B": {"1"}, "100%": {"3"}} {
+				condition, args := dialect.ContainsAny(d, value, "text")
+				var ids []string
+				if err := db.Table("dialect_texts").Where(condition, args...).Order("id").Pluck("id", &ids).Error; err != nil {
+					t.Fatal(err)
+				}
+				if !reflect.DeepEqual(ids, want) {
+					t.Errorf("%q matched %v, want %v", value, ids, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,64 @@
+package dialect
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// MaxFilterLength bounds free-text filter values
+const MaxFilterLength = 200
+
+// filterTextPattern whitelists the characters of free-text filter values: letters,
+// digits, spaces and the punctuation of names, codes, units and contact details. Quotes,
+// semicolons, backslashes, comparison operators and the % wildcard are not allowed. The
+// underscore of identifiers and addresses is, and EscapeLike makes it match only itself.
+var filterTextPattern = regexp.MustCompile(`^[\p{L}\p{M}\p{N} '.,:/|#()+&@_-]*$`)
+
+// identifierPattern matches the column names and JSON object keys that fragments are
+// built from; they come from code, never from requests
+var identifierPattern = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*(\.[A-Za-z_][A-Za-z0-9_]*)?$`)
+
+// CheckFilterText returns an error describing a free-text filter value that is too long
+// or holds characters outside the whitelist. Values are always bound as arguments; the
+// check keeps malformed input from reaching the database at all.
+func CheckFilterText(name, value string) error {
+	if len(value) > MaxFilterLength {
+		return fmt.Errorf("%s must be at most %d characters", name, MaxFilterLength)
+	}
+	if !filterTextPattern.MatchString(value) {
+		return fmt.Errorf("%s may only contain letters, digits, spaces and the characters ' . , : / | # ( ) + & @ _ -", name)
+	}
+	return nil
+}
+
+// EscapeLike escapes LIKE wildcards so a value is matched literally
+func EscapeLike(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(value)
+}
+
+// Contains returns the LIKE pattern matching a value anywhere in a text
+func Contains(value string) string {
+	return "%" + EscapeLike(value) + "%"
+}
+
+// ContainsAny returns a condition that any of exprs contains value, ignoring case, with
+// its arguments
+func ContainsAny(d Dialect, value string, exprs ...string) (string, []interface{}) {
+	conditions := make([]string, len(exprs))
+	args := make([]interface{}, len(exprs))
+	for i, expr := range exprs {
+		conditions[i] = d.ILike(expr)
+		args[i] = Contains(value)
+	}
+	return "(" + strings.Join(conditions, " OR ") + ")", args
+}
+
+// mustIdentifier panics unless name is a column name or JSON object key, since a
+// fragment built from anything else is a bug in its caller
+func mustIdentifier(name string) string {
+	if !identifierPattern.MatchString(name) {
+		panic(fmt.Sprintf("dialect: %q is not a column name or JSON key", name))
+	}
+	return name
+}
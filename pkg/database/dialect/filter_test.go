@@ -0,0 +1,107 @@
+package dialect
+
+import (
+	"strings"
+	"testing"
+	"unicode"
+	"unicode/utf8"
+)
+
+// filterSeeds are filter values of the shapes clients send, and of the shapes an
+// injection would take
+var filterSeeds = []string{
+	"",
+	"8480-6",
+	"Blood pressure",
+	"http://loinc.org|8480-6",
+	"O'Brien",
+	"José Ñúñez",
+	"mm[Hg]",
+	"100%",
+	"a_b",
+	`x\`,
+	"' OR 1=1 --",
+	"'; DROP TABLE observations; --",
+	`"quoted"`,
+	"a<b>c",
+	"\x00",
+	"\xff\xfe",
+	strings.Repeat("a", MaxFilterLength+1),
+}
+
+// filterPunctuation is the punctuation CheckFilterText allows besides spaces
+const filterPunctuation = "'.,:/|#()+&@_-"
+
+func FuzzCheckFilterText(f *testing.F) {
+	for _, seed := range filterSeeds {
+		f.Add(seed)
+	}
+	f.Fuzz(func(t *testing.T, value string) {
+		if err := CheckFilterText("code", value); err != nil {
+			return
+		}
+		if len(value) > MaxFilterLength {
+			t.Fatalf("accepted %d bytes, more than %d", len(value), MaxFilterLength)
+		}
+		if !utf8.ValidString(value) {
+			t.Fatalf("accepted invalid UTF-8 %q", value)
+		}
+		for _, r := range value {
+			if unicode.IsLetter(r) || unicode.IsMark(r) || unicode.IsNumber(r) || r == ' ' ||
+				strings.ContainsRune(filterPunctuation, r) {
+				continue
+			}
+			t.Fatalf("accepted %q with character %q outside the whitelist", value, r)
+		}
+		checkEscaped(t, value, EscapeLike(value))
+	})
+}
+
+func FuzzContainsAny(f *testing.F) {
+	for _, seed := range filterSeeds {
+		f.Add(seed)
+	}
+	dialects := []Dialect{postgresDialect{}, mysqlDialect{}, sqliteDialect{}}
+	f.Fuzz(func(t *testing.T, value string) {
+		for _, d := range dialects {
+			// Expressions come from code; the value is only ever bound
+			exprs := []string{d.JSONText("code", "text"), d.JSONText("code", "coding", "0", "code")}
+			condition, args := ContainsAny(d, value, exprs...)
+			if want, _ := ContainsAny(d, "", exprs...); condition != want {
+				t.Fatalf("%s: condition %q depends on the value %q", d.Name(), condition, value)
+			}
+			if len(args) != len(exprs) {
+				t.Fatalf("%s: %d arguments for %d expressions", d.Name(), len(args), len(exprs))
+			}
+			for _, arg := range args {
+				pattern, ok := arg.(string)
+				if !ok || !strings.HasPrefix(pattern, "%") || !strings.HasSuffix(pattern, "%") || len(pattern) < 2 {
+					t.Fatalf("%s: argument %q is not a contains pattern", d.Name(), arg)
+				}
+				checkEscaped(t, value, pattern[1:len(pattern)-1])
+			}
+		}
+	})
+}
+
+// checkEscaped fails unless escaped holds no unescaped LIKE wildcard and unescapes
+// back to value
+func checkEscaped(t *testing.T, value, escaped string) {
+	t.Helper()
+	var unescaped strings.Builder
+	for i := 0; i < len(escaped); i++ {
+		switch escaped[i] {
+		case '\\':
+			if i+1 == len(escaped) || !strings.ContainsRune(`\%_`, rune(escaped[i+1])) {
+				t.Fatalf("EscapeLike(%q) = %q has a dangling escape", value, escaped)
+			}
+			i++
+		case '%', '_':
+			t.Fatalf("EscapeLike(%q) = %q has an unescaped wildcard", value, escaped)
+		}
+		unescaped.WriteByte(escaped[i])
+	}
+	if unescaped.String() != value {
+		t.Fatalf("EscapeLike(%q) = %q unescapes to %q", value, escaped, unescaped.String())
+	}
+}
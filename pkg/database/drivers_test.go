@@ -0,0 +1,76 @@
+package database
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"gorm.io/gorm/logger"
+)
+
+// TestOpen migrates each database engine that is available; SQLite always is, and
+// DB_URL and MYSQL_URL point at PostgreSQL and MySQL
+func TestOpen(t *testing.T) {
+	engines := []struct {
+		dialect, url string
+	}{
+		{dialect.SQLite, "sqlite://" + filepath.Join(t.TempDir(), "healthhub.db")},
+		{dialect.Postgres, os.Getenv("DB_URL")},
+		{dialect.MySQL, os.Getenv("MYSQL_URL")},
+	}
+	for _, engine := range engines {
+		t.Run(engine.dialect, func(t *testing.T) {
+			if engine.url == "" {
+				t.Skip("no database configured")
+			}
+			db, err := Open(engine.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer CloseDB(db)
+			db.Logger = logger.Discard
+
+			if name := dialect.Of(db).Name(); name != engine.dialect {
+				t.Fatalf("dialect is %s, want %s", name, engine.dialect)
+			}
+			if err := AutoMigrate(db); err != nil {
+				t.Fatal(err)
+			}
+			if err := CreateIndexes(db); err != nil {
+				t.Fatal(err)
+			}
+			if err := CreateViews(db); err != nil {
+				t.Fatal(err)
+			}
+
+			patient := models.Patient{Gender: "female", CreatedBy: "test"}
+			if err := db.Create(&patient).Error; err != nil {
+				t.Fatal(err)
+			}
+			var found models.Patient
+			if err := db.Where("id = ?", patient.ID).First(&found).Error; err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestParseDatabaseURL(t *testing.T) {
+	tests := []struct {
+		url, scheme, dsn string
+	}{
+		{"postgres://u:p@db:5432/hh", "postgres", "postgres://u:p@db:5432/hh"},
+		{"host=db user=u dbname=hh", "postgres", "host=db user=u dbname=hh"},
+		{"sqlite:///var/lib/healthhub/healthhub.db", "sqlite", "/var/lib/healthhub/healthhub.db"},
+		{"sqlite:healthhub.db", "sqlite", "healthhub.db"},
+		{"mysql://u:p@db/hh", "mysql", "u:p@tcp(db:3306)/hh?charset=utf8mb4&loc=UTC&parseTime=true"},
+	}
+	for _, tt := range tests {
+		scheme, dsn, err := parseDatabaseURL(tt.url)
+		if err != nil || scheme != tt.scheme || dsn != tt.dsn {
+			t.Errorf("parseDatabaseURL(%q) = %q, %q, %v; want %q, %q", tt.url, scheme, dsn, err, tt.scheme, tt.dsn)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Index statuses
+const (
+	IndexPresent = "present"
+	IndexMissing = "missing"
+	// Left behind by a failed or interrupted concurrent build; PostgreSQL keeps
+	// updating it but does not use it for queries
+	IndexInvalid     = "invalid"
+	IndexUnsupported = "unsupported" // The database cannot create it
+)
+
+var (
+	// ErrUnknownIndex is returned for an index that is not an additional index
+	ErrUnknownIndex = errors.New("database: unknown index")
+	// ErrUnsupportedIndex is returned for an index the database cannot create
+	ErrUnsupportedIndex = errors.New("database: index is not supported by the database")
+	// ErrInTransaction is returned when building indexes through a transaction, which
+	// PostgreSQL does not build concurrently
+	ErrInTransaction = errors.New("database: indexes cannot be built inside a transaction")
+)
+
+// IndexStatus is whether an additional index exists on the database
+type IndexStatus struct {
+	Name    string   `json:"name"`
+	Table   string   `json:"table"`
+	Columns []string `json:"columns"`
+	JSON    bool     `json:"json,omitempty"` // Over a whole JSON column
+	Status  string   `json:"status"`
+}
+
+// index is an additional index created after migration
+type index struct {
+	name    string
+	table   string
+	columns []string
+	json    bool // Whole JSON column, a GIN index on PostgreSQL
+	// Over an expression; MySQL cannot index expressions over text columns
+	expression bool
+	postgres   bool // Written in PostgreSQL's JSON operators, so only created there
+	// Over unbounded string columns, which MySQL stores as text and only indexes by a
+	// prefix
+	text bool
+}
+
+// mysqlPrefixLength is the prefix of text columns MySQL indexes; 191 characters of
+// utf8mb4 fit the 767 byte key limit of older row formats
+const mysqlPrefixLength = 191
+
+var indexes = []index{
+	// User indexes
+	{name: "idx_users_email", table: "users", columns: []string{"email"}},
+	{name: "idx_users_active", table: "users", columns: []string{"active"}},
+
+	// Patient indexes
+	{name: "idx_patients_active", table: "patients", columns: []string{"active"}},
+	{name: "idx_patients_created_at", table: "patients", columns: []string{"created_at"}},
+	{name: "idx_patients_created_by", table: "patients", columns: []string{"created_by"}, text: true},
+	{name: "idx_patients_name_gin", table: "patients", columns: []string{"name"}, json: true},
+	{name: "idx_patients_telecom_gin", table: "patients", columns: []string{"telecom"}, json: true},
+	{name: "idx_patients_identifier_gin", table: "patients", columns: []string{"identifier"}, json: true},
+
+	// Observation indexes
+	{name: "idx_observations_status", table: "observations", columns: []string{"status"}, text: true},
+	{name: "idx_observations_effective_date", table: "observations", columns: []string{"effective_date_time"}},
+	{name: "idx_observations_created_at", table: "observations", columns: []string{"created_at"}},
+	{name: "idx_observations_created_by", table: "observations", columns: []string{"created_by"}, text: true},
+	{name: "idx_observations_subject_gin", table: "observations", columns: []string{"subject"}, json: true},
+	{name: "idx_observations_code_gin", table: "observations", columns: []string{"code"}, json: true},
+	{name: "idx_observations_category_gin", table: "observations", columns: []string{"category"}, json: true},
+	{name: "idx_observations_value_quantity", table: "observations",
+		columns: []string{"(" + models.QuantityUnitExpression + ")", "value_quantity_value"}, expression: true},
+	{name: "idx_observations_value_quantity_value", table: "observations", columns: []string{"value_quantity_value"}},
+	{name: "idx_observations_reason_gin", table: "observations", columns: []string{"reason_reference"}, json: true},
+	{name: "idx_observations_encounter", table: "observations", columns: []string{"encounter_reference"}, text: true},
+	// Serves the latest observation of each code for a patient
+	{name: "idx_observations_latest", table: "observations",
+		columns: []string{"(subject->>'reference')", "(code->'coding'->0->>'system')", "(code->'coding'->0->>'code')",
+			"effective_date_time DESC"}, expression: true, postgres: true},
+
+	// Media indexes
+	{name: "idx_media_created_at", table: "media", columns: []string{"created_at"}},
+
+	// Clinical note indexes
+	{name: "idx_clinical_notes_subject", table: "clinical_notes", columns: []string{"subject_reference"}, text: true},
+	{name: "idx_clinical_notes_encounter", table: "clinical_notes", columns: []string{"encounter_reference"}, text: true},
+
+	// Condition indexes
+	{name: "idx_conditions_subject", table: "conditions", columns: []string{"subject_reference"}, text: true},
+
+	// Charge item indexes
+	{name: "idx_charge_items_service", table: "charge_items", columns: []string{"service_reference"}, text: true},
+	{name: "idx_charge_items_subject", table: "charge_items", columns: []string{"subject_reference"}, text: true},
+
+	// Questionnaire response indexes
+	{name: "idx_questionnaire_responses_subject", table: "questionnaire_responses", columns: []string{"subject_reference"}, text: true},
+}
+
+// statement returns the statement creating an index, or "" when the database
+// cannot create it. JSON column indexes need a database that can index a whole
+// document; MySQL cannot index expressions over text columns, and indexes written in
+// PostgreSQL's JSON operators are only created there. MySQL indexes text columns by
+// their prefix.
+func (idx index) statement(d dialect.Dialect) string {
+	if idx.expression && d.Name() == dialect.MySQL || idx.postgres && d.Name() != dialect.Postgres {
+		return ""
+	}
+	if idx.json {
+		return d.CreateJSONIndex(idx.name, idx.table, idx.columns[0])
+	}
+	columns := idx.columns
+	if idx.text && d.Name() == dialect.MySQL {
+		columns = make([]string, len(idx.columns))
+		for i, column := range idx.columns {
+			columns[i] = fmt.Sprintf("%s(%d)", column, mysqlPrefixLength)
+		}
+	}
+	return d.CreateIndex(idx.name, idx.table, columns...)
+}
+
+// IndexStatuses reports which of the additional indexes exist, are missing or, on
+// PostgreSQL, were left invalid by a build that did not complete
+func IndexStatuses(db *gorm.DB) ([]IndexStatus, error) {
+	d := dialect.Of(db)
+
+	// Whether each existing index is valid; only PostgreSQL keeps invalid indexes
+	var valid map[string]bool
+	if d.Name() == dialect.Postgres {
+		names := make([]string, len(indexes))
+		for i, idx := range indexes {
+			names[i] = idx.name
+		}
+		var rows []struct {
+			Name  string
+			Valid bool
+		}
+		if err := db.Raw(`SELECT c.relname AS name, i.indisvalid AS valid FROM pg_index i
+			JOIN pg_class c ON c.oid = i.indexrelid
+			JOIN pg_namespace n ON n.oid = c.relnamespace
+			WHERE n.nspname = current_schema() AND c.relname IN ?`, names).Scan(&rows).Error; err != nil {
+			return nil, fmt.Errorf("failed to read indexes: %w", err)
+		}
+		valid = make(map[string]bool, len(rows))
+		for _, row := range rows {
+			valid[row.Name] = row.Valid
+		}
+	}
+
+	statuses := make([]IndexStatus, 0, len(indexes))
+	for _, idx := range indexes {
+		status := IndexStatus{Name: idx.name, Table: idx.table, Columns: idx.columns, JSON: idx.json}
+		switch {
+		case idx.statement(d) == "":
+			status.Status = IndexUnsupported
+		case valid != nil:
+			if ok, exists := valid[idx.name]; !exists {
+				status.Status = IndexMissing
+			} else if !ok {
+				status.Status = IndexInvalid
+			} else {
+				status.Status = IndexPresent
+			}
+		case db.Migrator().HasIndex(idx.table, idx.name):
+			status.Status = IndexPresent
+		default:
+			status.Status = IndexMissing
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// CreateIndexes creates the additional indexes that are missing or invalid. An index
+// that fails to build does not stop the others; the failures are returned together.
+func CreateIndexes(db *gorm.DB) error {
+	statuses, err := IndexStatuses(db)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, status := range statuses {
+		if status.Status != IndexMissing && status.Status != IndexInvalid {
+			continue
+		}
+		if err := BuildIndex(context.Background(), db, status.Name, false); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// BuildIndex creates an additional index that is missing, replaces one left invalid,
+// and with rebuild also rebuilds one that exists. On PostgreSQL indexes are built
+// concurrently, so writes to the table go on meanwhile, which cannot be done inside a
+// transaction; a build that is cancelled or fails leaves the index invalid until it
+// is built again.
+func BuildIndex(ctx context.Context, db *gorm.DB, name string, rebuild bool) error {
+	if _, ok := db.Statement.ConnPool.(gorm.TxCommitter); ok {
+		return ErrInTransaction
+	}
+
+	var idx *index
+	for i := range indexes {
+		if indexes[i].name == name {
+			idx = &indexes[i]
+		}
+	}
+	if idx == nil {
+		return fmt.Errorf("%w %s", ErrUnknownIndex, name)
+	}
+	d := dialect.Of(db)
+	statement := idx.statement(d)
+	if statement == "" {
+		return fmt.Errorf("%w: %s", ErrUnsupportedIndex, name)
+	}
+
+	statuses, err := IndexStatuses(db)
+	if err != nil {
+		return err
+	}
+	var status string
+	for _, s := range statuses {
+		if s.Name == name {
+			status = s.Status
+		}
+	}
+
+	db = db.WithContext(ctx)
+	switch {
+	case status == IndexPresent && !rebuild:
+		return nil
+	case status == IndexPresent && d.Name() == dialect.Postgres:
+		// The old index serves queries until its replacement is ready
+		if err := db.Exec("REINDEX INDEX CONCURRENTLY " + idx.name).Error; err != nil {
+			return fmt.Errorf("failed to rebuild index %s: %w", idx.name, err)
+		}
+		return nil
+	case status == IndexInvalid:
+		if err := db.Exec("DROP INDEX CONCURRENTLY IF EXISTS " + idx.name).Error; err != nil {
+			return fmt.Errorf("failed to drop invalid index %s: %w", idx.name, err)
+		}
+	case status == IndexPresent:
+		if err := db.Migrator().DropIndex(idx.table, idx.name); err != nil {
+			return fmt.Errorf("failed to drop index %s: %w", idx.name, err)
+		}
+	}
+	if err := db.Exec(statement).Error; err != nil {
+		return fmt.Errorf("failed to create index %s: %w", idx.name, err)
+	}
+	return nil
+}
+
+// IndexBuildResult is the result of an index build operation
+type IndexBuildResult struct {
+	Built   []string      `json:"built"`
+	Indexes []IndexStatus `json:"indexes"` // Once the build completed
+}
+
+// IndexBuilder builds additional indexes as operations
+type IndexBuilder struct {
+	db *gorm.DB
+}
+
+// NewIndexBuilder creates a new index builder
+func NewIndexBuilder(db *gorm.DB) *IndexBuilder {
+	return &IndexBuilder{db: db}
+}
+
+// Execute builds the indexes of an operation: the index it targets, as Index/{name},
+// rebuilt even when it exists, else every missing or invalid index. Indexes that fail
+// to build do not stop the others, but fail the operation.
+func (b *IndexBuilder) Execute(ctx context.Context, task *operation.Task) (interface{}, error) {
+	var names []string
+	rebuild := task.Operation.Target != ""
+	if rebuild {
+		names = []string{task.Operation.TargetID()}
+	} else {
+		statuses, err := IndexStatuses(b.db)
+		if err != nil {
+			return nil, err
+		}
+		for _, status := range statuses {
+			if status.Status == IndexMissing || status.Status == IndexInvalid {
+				names = append(names, status.Name)
+			}
+		}
+	}
+
+	result := IndexBuildResult{Built: []string{}}
+	var failures []string
+	for i, name := range names {
+		if err := BuildIndex(ctx, b.db, name, rebuild); err != nil {
+			if ctx.Err() != nil {
+				return nil, ctx.Err()
+			}
+			logger.Warn("Failed to build index", zap.String("index", name), zap.Error(err))
+			failures = append(failures, err.Error())
+		} else {
+			result.Built = append(result.Built, name)
+		}
+		if err := task.Progress(int64(i+1), int64(len(names))); err != nil {
+			logger.Warn("Failed to record index build progress", zap.String("operation_id", task.Operation.ID), zap.Error(err))
+		}
+	}
+	if len(failures) > 0 {
+		return nil, fmt.Errorf("%d of %d indexes failed to build: %s", len(failures), len(names), strings.Join(failures, "; "))
+	}
+
+	statuses, err := IndexStatuses(b.db)
+	if err != nil {
+		return nil, err
+	}
+	result.Indexes = statuses
+
+	logger.LogAuditEvent("index_build", "Index", task.Operation.CreatedBy, map[string]interface{}{
+		"operation_id": task.Operation.ID,
+		"built":        result.Built,
+		"rebuild":      rebuild,
+	})
+	return result, nil
+}
@@ -2,12 +2,16 @@ package database
 
 import (
 	"fmt"
+	"net/url"
+	"strings"
 	"time"
 
 	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
 	"gorm.io/driver/postgres"
 	"gorm.io/gorm"
 	"gorm.io/gorm/logger"
+	"gorm.io/gorm/schema"
 )
 
 // Config holds database configuration
@@ -23,6 +27,11 @@ type Config struct {
 
 // NewPostgresDB creates a new PostgreSQL database connection
 func NewPostgresDB(databaseURL string) (*gorm.DB, error) {
+	return connect(postgres.Open(databaseURL))
+}
+
+// connect opens a connection through the gorm driver and configures its pool
+func connect(driver gorm.Dialector) (*gorm.DB, error) {
 	config := &gorm.Config{
 		Logger: logger.Default.LogMode(logger.Info),
 		NowFunc: func() time.Time {
@@ -30,7 +39,7 @@ func NewPostgresDB(databaseURL string) (*gorm.DB, error) {
 		},
 	}
 
-	db, err := gorm.Open(postgres.Open(databaseURL), config)
+	db, err := gorm.Open(driver, config)
 	if err != nil {
 		return nil, fmt.Errorf("failed to connect to database: %w", err)
 	}
@@ -44,6 +53,10 @@ func NewPostgresDB(databaseURL string) (*gorm.DB, error) {
 	sqlDB.SetMaxIdleConns(10)
 	sqlDB.SetMaxOpenConns(100)
 	sqlDB.SetConnMaxLifetime(time.Hour)
+	if driver.Name() == dialect.SQLite {
+		// SQLite allows a single writer; queue on the pool rather than fail with SQLITE_BUSY
+		sqlDB.SetMaxOpenConns(1)
+	}
 
 	// Test the connection
 	if err := sqlDB.Ping(); err != nil {
@@ -63,90 +76,130 @@ func NewPostgresDBFromConfig(config Config) (*gorm.DB, error) {
 
 // AutoMigrate runs database migrations for all models
 func AutoMigrate(db *gorm.DB) error {
-	err := db.AutoMigrate(
-		&models.User{},
-		&models.Role{},
-		&models.Permission{},
-		&models.UserRole{},
-		&models.RolePermission{},
-		&models.Patient{},
-		&models.Observation{},
-	)
-	if err != nil {
+	if err := adaptJSONColumns(db, migrated...); err != nil {
 		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	return nil
-}
-
-// CreateIndexes creates additional database indexes for performance
-func CreateIndexes(db *gorm.DB) error {
-	// User indexes
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_users_email ON users (email)").Error; err != nil {
-		return fmt.Errorf("failed to create users email index: %w", err)
-	}
-
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_users_active ON users (active)").Error; err != nil {
-		return fmt.Errorf("failed to create users active index: %w", err)
-	}
-
-	// Patient indexes
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_patients_active ON patients (active)").Error; err != nil {
-		return fmt.Errorf("failed to create patients active index: %w", err)
-	}
-
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_patients_created_at ON patients (created_at)").Error; err != nil {
-		return fmt.Errorf("failed to create patients created_at index: %w", err)
-	}
-
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_patients_created_by ON patients (created_by)").Error; err != nil {
-		return fmt.Errorf("failed to create patients created_by index: %w", err)
-	}
-
-	// Use GIN index for JSON fields
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_patients_name_gin ON patients USING GIN (name)").Error; err != nil {
-		return fmt.Errorf("failed to create patients name gin index: %w", err)
-	}
-
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_patients_telecom_gin ON patients USING GIN (telecom)").Error; err != nil {
-		return fmt.Errorf("failed to create patients telecom gin index: %w", err)
-	}
-
-	// Observation indexes
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_observations_status ON observations (status)").Error; err != nil {
-		return fmt.Errorf("failed to create observations status index: %w", err)
-	}
-
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_observations_effective_date ON observations (effective_date_time)").Error; err != nil {
-		return fmt.Errorf("failed to create observations effective_date index: %w", err)
+	if err := db.AutoMigrate(migrated...); err != nil {
+		return fmt.Errorf("failed to migrate database: %w", err)
 	}
 
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_observations_created_at ON observations (created_at)").Error; err != nil {
-		return fmt.Errorf("failed to create observations created_at index: %w", err)
-	}
+	return nil
+}
 
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_observations_created_by ON observations (created_by)").Error; err != nil {
-		return fmt.Errorf("failed to create observations created_by index: %w", err)
-	}
+// migrated lists the models whose tables AutoMigrate maintains
+var migrated = []interface{}{
+	&models.User{},
+	&models.Role{},
+	&models.Permission{},
+	&models.UserRole{},
+	&models.SigningKey{},
+	&models.RefreshToken{},
+	&models.UserIdentity{},
+	&models.OIDCLoginState{},
+	&models.RolePermission{},
+	&models.Patient{},
+	&models.PatientLink{},
+	&models.PatientMerge{},
+	&models.ContactVerification{},
+	&models.Practitioner{},
+	&models.ObservationCategory{},
+	&models.ValidationProfile{},
+	&models.Operation{},
+	&models.BulkJob{},
+	&models.BulkJobItem{},
+	&models.ExportJob{},
+	&models.ExportSchedule{},
+	&models.ExportScheduleRun{},
+	&models.BackupJob{},
+	&models.RestoreJob{},
+	&models.Observation{},
+	&models.Procedure{},
+	&models.CarePlan{},
+	&models.CarePlanActivity{},
+	&models.ChartSnapshot{},
+	&models.LegalHold{},
+	&models.Coverage{},
+	&models.RelatedPerson{},
+	&models.Device{},
+	&models.Media{},
+	&models.DocumentReference{},
+	&models.ClinicalNote{},
+	&models.NoteAddendum{},
+	&models.ClinicalNoteVersion{},
+	&models.Questionnaire{},
+	&models.QuestionnaireResponse{},
+	&models.OutboxEvent{},
+	&models.EventCheckpoint{},
+	&models.EventReplay{},
+	&models.AuditLog{},
+	&models.AuditArchive{},
+	&models.Alert{},
+	&models.QCControl{},
+	&models.QCResult{},
+	&models.DeltaCheckRule{},
+	&models.OnCallChain{},
+	&models.AlertEscalation{},
+	&models.Specimen{},
+	&models.StandingOrder{},
+	&models.ObservationSlot{},
+	&models.MedicationRequest{},
+	&models.DrugInteraction{},
+	&models.AllergyIntolerance{},
+	&models.Condition{},
+	&models.ConceptMapping{},
+	&models.ConceptDisplay{},
+	&models.MedicationAdministration{},
+	&models.FeeScheduleEntry{},
+	&models.ChargeRule{},
+	&models.ChargeItem{},
+	&models.CaptureSession{},
+	&models.CapturedExchange{},
+	&models.APIUsage{},
+	&models.FixtureEntity{},
+	&models.IntegrationCredential{},
+	&models.IntegrationNonce{},
+	&models.IntegrationMapping{},
+	&models.QuarantinedMessage{},
+	&models.IntegrityRun{},
+	&models.IntegrityFinding{},
+}
 
-	// GIN indexes for JSON fields in observations
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_observations_subject_gin ON observations USING GIN (subject)").Error; err != nil {
-		return fmt.Errorf("failed to create observations subject gin index: %w", err)
-	}
+// Models returns the models whose tables AutoMigrate maintains
+func Models() []interface{} {
+	return append([]interface{}(nil), migrated...)
+}
 
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_observations_code_gin ON observations USING GIN (code)").Error; err != nil {
-		return fmt.Errorf("failed to create observations code gin index: %w", err)
+// adaptJSONColumns rewrites the jsonb column type declared on the models to the
+// JSON type of the connection's database. Parsed schemas are cached per
+// connection, so the migrator sees the adapted type.
+func adaptJSONColumns(db *gorm.DB, values ...interface{}) error {
+	jsonType := schema.DataType(dialect.Of(db).JSONType())
+	if jsonType == "jsonb" {
+		return nil
 	}
 
-	if err := db.Exec("CREATE INDEX CONCURRENTLY IF NOT EXISTS idx_observations_category_gin ON observations USING GIN (category)").Error; err != nil {
-		return fmt.Errorf("failed to create observations category gin index: %w", err)
+	for _, value := range values {
+		stmt := &gorm.Statement{DB: db}
+		if err := stmt.Parse(value); err != nil {
+			return err
+		}
+		for _, field := range stmt.Schema.Fields {
+			if field.DataType == "jsonb" {
+				field.DataType = jsonType
+			}
+		}
 	}
-
 	return nil
 }
 
-// SetupSecurity configures database security settings
+// SetupSecurity configures database security settings. Row level security is only
+// available on PostgreSQL.
 func SetupSecurity(db *gorm.DB) error {
+	if !dialect.IsPostgres(db) {
+		return nil
+	}
+
 	// Enable row level security on sensitive tables
 	tables := []string{"users", "patients", "observations"}
 
@@ -160,6 +213,24 @@ func SetupSecurity(db *gorm.DB) error {
 	return nil
 }
 
+// SameDatabase reports whether two database URLs name the same host, port and database
+func SameDatabase(a, b string) bool {
+	ua, errA := url.Parse(a)
+	ub, errB := url.Parse(b)
+	if errA != nil || errB != nil || ua.Scheme == "" || ub.Scheme == "" {
+		return a == b
+	}
+
+	port := func(u *url.URL) string {
+		if p := u.Port(); p != "" {
+			return p
+		}
+		return "5432"
+	}
+	return strings.EqualFold(ua.Hostname(), ub.Hostname()) && port(ua) == port(ub) &&
+		strings.Trim(ua.Path, "/") == strings.Trim(ub.Path, "/")
+}
+
 // CloseDB gracefully closes the database connection
 func CloseDB(db *gorm.DB) error {
 	sqlDB, err := db.DB()
@@ -0,0 +1,87 @@
+package database
+
+import (
+	"context"
+	"database/sql"
+	"regexp"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"gorm.io/gorm"
+)
+
+// lsnPattern matches a PostgreSQL WAL position such as 16/B374D848
+var lsnPattern = regexp.MustCompile(`^[0-9A-Fa-f]{1,8}/[0-9A-Fa-f]{1,8}$`)
+
+var readsRouted = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "db_reads_routed_total",
+		Help: "Total number of routed reads by the database that served them",
+	},
+	[]string{"target"},
+)
+
+// Router sends reads to a streaming replica when one is configured. Writes return a
+// consistency token, the primary's WAL position after the write; a read presenting
+// the token is served by the replica only once it has replayed that far, and by the
+// primary otherwise, so clients always see their own writes.
+type Router struct {
+	primary *gorm.DB
+	replica *gorm.DB
+}
+
+// NewRouter creates a new router. A nil replica sends every read to the primary.
+func NewRouter(primary, replica *gorm.DB) *Router {
+	if replica != nil && !dialect.IsPostgres(primary) {
+		replica = nil
+	}
+	return &Router{primary: primary, replica: replica}
+}
+
+// Primary returns the primary connection
+func (r *Router) Primary() *gorm.DB {
+	return r.primary
+}
+
+// HasReplica reports whether reads may be routed to a replica
+func (r *Router) HasReplica() bool {
+	return r.replica != nil
+}
+
+// Token returns the primary's current WAL position
+func (r *Router) Token(ctx context.Context) (string, error) {
+	var lsn string
+	if err := r.primary.WithContext(ctx).Raw("SELECT pg_current_wal_lsn()::text").Scan(&lsn).Error; err != nil {
+		return "", err
+	}
+	return lsn, nil
+}
+
+// Reader returns the connection to serve a read with. Without a token the replica
+// is used. A token the replica has not replayed yet, or one that cannot be parsed,
+// sends the read to the primary.
+func (r *Router) Reader(ctx context.Context, token string) *gorm.DB {
+	if r.replica == nil {
+		return r.primary
+	}
+	if token == "" {
+		readsRouted.WithLabelValues("replica").Inc()
+		return r.replica
+	}
+
+	var caughtUp sql.NullBool
+	if lsnPattern.MatchString(token) {
+		// NULL when the replica is not replaying, which sends the read to the primary
+		if err := r.replica.WithContext(ctx).Raw("SELECT pg_last_wal_replay_lsn() >= ?::pg_lsn", token).
+			Scan(&caughtUp).Error; err != nil {
+			caughtUp = sql.NullBool{}
+		}
+	}
+	if caughtUp.Valid && caughtUp.Bool {
+		readsRouted.WithLabelValues("replica").Inc()
+		return r.replica
+	}
+	readsRouted.WithLabelValues("primary").Inc()
+	return r.primary
+}
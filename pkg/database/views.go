@@ -0,0 +1,269 @@
+package database
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/internal/models"
+	"github.com/hillmatthew2000/HealthHub/internal/operation"
+	"github.com/hillmatthew2000/HealthHub/pkg/database/dialect"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+	"gorm.io/gorm"
+)
+
+// Materialized views the analytics endpoints read instead of the main tables
+const (
+	ObservationDailyCountsView = "observation_daily_counts"
+	LatestObservationsView     = "latest_observations"
+)
+
+// ErrViewNotPopulated is returned when reading a materialized view that was created
+// but has not been refreshed yet
+var ErrViewNotPopulated = errors.New("database: materialized view has not been refreshed yet")
+
+// view is a materialized view. Views are created once and only refreshed after, so a
+// changed query needs a new name.
+type view struct {
+	name  string
+	query string
+	// unique are the columns of the unique index that lets the view be refreshed
+	// concurrently, without blocking its readers
+	unique []string
+}
+
+// jsonArray guards JSON columns that may hold null or a non-array value
+func jsonArray(expr string) string {
+	return "CASE WHEN jsonb_typeof(" + expr + ") = 'array' THEN " + expr + " ELSE '[]'::jsonb END"
+}
+
+var views = []view{
+	// Observations recorded per day and category; an observation of several categories
+	// counts in each, and one without under empty codes
+	{
+		name: ObservationDailyCountsView,
+		query: `SELECT (o.effective_date_time AT TIME ZONE 'UTC')::date AS day,
+				COALESCE(coding->>'system', '') AS category_system,
+				COALESCE(coding->>'code', '') AS category_code,
+				COUNT(DISTINCT o.id) AS observations,
+				COUNT(DISTINCT o.subject->>'reference') AS patients
+			FROM observations o
+			LEFT JOIN LATERAL jsonb_array_elements(` + jsonArray("o.category") + `) AS category ON true
+			LEFT JOIN LATERAL jsonb_array_elements(` + jsonArray("category->'coding'") + `) AS coding ON true
+			WHERE o.deleted_at IS NULL AND o.status <> 'entered-in-error'
+			GROUP BY 1, 2, 3`,
+		unique: []string{"day", "category_system", "category_code"},
+	},
+	// The latest observation of each patient for each code, by effective time
+	{
+		name: LatestObservationsView,
+		query: `SELECT DISTINCT ON (o.subject->>'reference', coding->>'system', coding->>'code')
+				o.subject->>'reference' AS subject_reference,
+				COALESCE(coding->>'system', '') AS code_system,
+				COALESCE(coding->>'code', '') AS code,
+				coding->>'display' AS display,
+				o.id AS observation_id,
+				o.status,
+				o.effective_date_time,
+				o.value_quantity_value,
+				o.value_quantity_unit,
+				o.value_string
+			FROM observations o
+			CROSS JOIN LATERAL jsonb_array_elements(` + jsonArray("o.code->'coding'") + `) AS coding
+			WHERE o.deleted_at IS NULL AND o.status NOT IN ('entered-in-error', 'cancelled')
+				AND o.subject->>'reference' IS NOT NULL
+			ORDER BY o.subject->>'reference', coding->>'system', coding->>'code', o.effective_date_time DESC, o.id DESC`,
+		unique: []string{"subject_reference", "code_system", "code"},
+	},
+}
+
+// ViewStatus is whether a materialized view holds data, and when it was last refreshed
+type ViewStatus struct {
+	Name        string     `json:"name"`
+	Populated   bool       `json:"populated"`
+	RefreshedAt *time.Time `json:"refreshedAt,omitempty"`
+}
+
+// CreateViews creates the materialized views that are missing, without data; they are
+// filled by their first refresh. Only PostgreSQL has materialized views.
+func CreateViews(db *gorm.DB) error {
+	if !dialect.IsPostgres(db) {
+		return nil
+	}
+	for _, v := range views {
+		if err := db.Exec(fmt.Sprintf("CREATE MATERIALIZED VIEW IF NOT EXISTS %s AS %s WITH NO DATA", v.name, v.query)).Error; err != nil {
+			return fmt.Errorf("failed to create materialized view %s: %w", v.name, err)
+		}
+		if err := db.Exec(fmt.Sprintf("CREATE UNIQUE INDEX IF NOT EXISTS idx_%s_key ON %s (%s)",
+			v.name, v.name, strings.Join(v.unique, ", "))).Error; err != nil {
+			return fmt.Errorf("failed to index materialized view %s: %w", v.name, err)
+		}
+	}
+	return nil
+}
+
+// ViewStatuses reports whether each materialized view holds data; refreshes are
+// timed by the operations that ran them
+func ViewStatuses(db *gorm.DB) ([]ViewStatus, error) {
+	if !dialect.IsPostgres(db) {
+		return []ViewStatus{}, nil
+	}
+
+	var rows []struct {
+		Name      string
+		Populated bool
+	}
+	if err := db.Raw(`SELECT matviewname AS name, ispopulated AS populated FROM pg_matviews
+		WHERE schemaname = current_schema()`).Scan(&rows).Error; err != nil {
+		return nil, fmt.Errorf("failed to read materialized views: %w", err)
+	}
+	populated := make(map[string]bool, len(rows))
+	for _, row := range rows {
+		populated[row.Name] = row.Populated
+	}
+
+	var last models.Operation
+	var refreshedAt *time.Time
+	err := db.Where("kind = ? AND status = ?", models.OperationKindViews, models.OperationCompleted).
+		Order("completed_at DESC").First(&last).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+	if err == nil {
+		refreshedAt = last.CompletedAt
+	}
+
+	statuses := make([]ViewStatus, 0, len(views))
+	for _, v := range views {
+		status := ViewStatus{Name: v.name, Populated: populated[v.name]}
+		if status.Populated {
+			status.RefreshedAt = refreshedAt
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses, nil
+}
+
+// ViewReady returns when the materialized view was last refreshed, or
+// ErrViewNotPopulated when it has no data yet
+func ViewReady(db *gorm.DB, name string) (*time.Time, error) {
+	statuses, err := ViewStatuses(db)
+	if err != nil {
+		return nil, err
+	}
+	for _, status := range statuses {
+		if status.Name == name {
+			if !status.Populated {
+				return nil, ErrViewNotPopulated
+			}
+			return status.RefreshedAt, nil
+		}
+	}
+	return nil, fmt.Errorf("database: unknown materialized view %s", name)
+}
+
+// RefreshView refreshes a materialized view. A populated view is refreshed
+// concurrently, so it goes on serving reads meanwhile; the first refresh cannot be.
+func RefreshView(ctx context.Context, db *gorm.DB, name string) error {
+	var populated bool
+	if err := db.WithContext(ctx).Raw("SELECT ispopulated FROM pg_matviews WHERE schemaname = current_schema() AND matviewname = ?", name).
+		Scan(&populated).Error; err != nil {
+		return fmt.Errorf("failed to read materialized view %s: %w", name, err)
+	}
+
+	statement := "REFRESH MATERIALIZED VIEW "
+	if populated {
+		statement += "CONCURRENTLY "
+	}
+	if err := db.WithContext(ctx).Exec(statement + name).Error; err != nil {
+		return fmt.Errorf("failed to refresh materialized view %s: %w", name, err)
+	}
+	return nil
+}
+
+// ViewRefreshResult is the result of a view refresh operation
+type ViewRefreshResult struct {
+	Refreshed []string `json:"refreshed"`
+}
+
+// ViewRefresher refreshes the materialized views as operations, queued on a schedule
+// or on request
+type ViewRefresher struct {
+	db       *gorm.DB
+	runner   *operation.Runner
+	interval time.Duration
+}
+
+// NewViewRefresher creates a view refresher that queues a refresh every interval;
+// 0 leaves refreshes to requests
+func NewViewRefresher(db *gorm.DB, runner *operation.Runner, interval time.Duration) *ViewRefresher {
+	return &ViewRefresher{db: db, runner: runner, interval: interval}
+}
+
+// Execute refreshes every materialized view, one after the other
+func (r *ViewRefresher) Execute(ctx context.Context, task *operation.Task) (interface{}, error) {
+	if !dialect.IsPostgres(r.db) {
+		return ViewRefreshResult{Refreshed: []string{}}, nil
+	}
+
+	result := ViewRefreshResult{Refreshed: []string{}}
+	for i, v := range views {
+		start := time.Now()
+		if err := RefreshView(ctx, r.db, v.name); err != nil {
+			return nil, err
+		}
+		logger.Info("Refreshed materialized view", zap.String("view", v.name), zap.Duration("duration", time.Since(start)))
+		result.Refreshed = append(result.Refreshed, v.name)
+		if err := task.Progress(int64(i+1), int64(len(views))); err != nil {
+			logger.Warn("Failed to record view refresh progress", zap.String("operation_id", task.Operation.ID), zap.Error(err))
+		}
+	}
+	return result, nil
+}
+
+// Run queues a refresh at start and then every interval until the context is
+// cancelled. A refresh still queued or running is not queued again, so a slow refresh
+// does not pile up behind itself.
+func (r *ViewRefresher) Run(ctx context.Context) {
+	if r.interval <= 0 || !dialect.IsPostgres(r.db) {
+		return
+	}
+	ticker := time.NewTicker(r.interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := r.Queue(""); err != nil {
+			logger.Warn("Failed to queue materialized view refresh", zap.Error(err))
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// Queue queues a refresh of every view, returning the refresh already queued or
+// running if there is one
+func (r *ViewRefresher) Queue(createdBy string) (*models.Operation, error) {
+	var pending models.Operation
+	err := r.db.Where("kind = ? AND status IN ?", models.OperationKindViews, []string{models.OperationQueued, models.OperationRunning}).
+		Order("created_at ASC").First(&pending).Error
+	if err == nil {
+		return &pending, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	op, err := r.runner.Queue(r.db, models.OperationKindViews, "", createdBy)
+	if err != nil {
+		return nil, err
+	}
+	r.runner.Wake(models.OperationKindViews)
+	return op, nil
+}
@@ -0,0 +1,129 @@
+// Package disclosure guards aggregate counts against re-identification: counts of too
+// few people are suppressed, and released counts may be perturbed with random noise.
+package disclosure
+
+import (
+	"fmt"
+	"math"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// DefaultMinCellSize is the minimum cell size of endpoints without a policy, following
+// the common practice of suppressing counts of 1 to 10
+const DefaultMinCellSize = 11
+
+// Policy is the disclosure control applied to the aggregates of an endpoint
+type Policy struct {
+	MinCellSize int     `json:"minCellSize"`          // Counts of fewer people are suppressed; 0 disables suppression
+	NoiseScale  float64 `json:"noiseScale,omitempty"` // Scale of the Laplace noise added to released counts; 0 adds none
+}
+
+// Policies holds the policies of endpoints, with a default for those not listed
+type Policies struct {
+	defaults  Policy
+	endpoints map[string]Policy
+}
+
+// ParsePolicies parses endpoint policies of the form endpoint=min_cell_size[:noise_scale],
+// e.g. demographics=20:2 to suppress counts under 20 and add noise of scale 2. The
+// endpoint * sets the policy of endpoints without an entry, which otherwise suppresses
+// counts under DefaultMinCellSize and adds no noise.
+func ParsePolicies(entries []string) (*Policies, error) {
+	policies := &Policies{defaults: Policy{MinCellSize: DefaultMinCellSize}, endpoints: make(map[string]Policy)}
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		endpoint, value, ok := strings.Cut(entry, "=")
+		endpoint = strings.TrimSpace(endpoint)
+		if !ok || endpoint == "" {
+			return nil, fmt.Errorf("disclosure: invalid policy %q, expected endpoint=min_cell_size[:noise_scale]", entry)
+		}
+		size, scale, hasScale := strings.Cut(value, ":")
+		var policy Policy
+		var err error
+		if policy.MinCellSize, err = strconv.Atoi(strings.TrimSpace(size)); err != nil || policy.MinCellSize < 0 {
+			return nil, fmt.Errorf("disclosure: invalid minimum cell size in %q", entry)
+		}
+		if hasScale {
+			if policy.NoiseScale, err = strconv.ParseFloat(strings.TrimSpace(scale), 64); err != nil || policy.NoiseScale < 0 || math.IsInf(policy.NoiseScale, 0) {
+				return nil, fmt.Errorf("disclosure: invalid noise scale in %q", entry)
+			}
+		}
+		if _, exists := policies.endpoints[endpoint]; exists {
+			return nil, fmt.Errorf("disclosure: duplicate policy for %s", endpoint)
+		}
+		if endpoint == "*" {
+			policies.defaults = policy
+		}
+		policies.endpoints[endpoint] = policy
+	}
+	return policies, nil
+}
+
+// For returns the policy of an endpoint
+func (p *Policies) For(endpoint string) Policy {
+	if policy, ok := p.endpoints[endpoint]; ok {
+		return policy
+	}
+	return p.defaults
+}
+
+// Suppressed reports whether a count of people is too small to release. Zero
+// counts are released, as they identify no one.
+func (p Policy) Suppressed(n int64) bool {
+	return n > 0 && n < int64(p.MinCellSize)
+}
+
+// SuppressPartition reports which counts of a partition, whose total is released,
+// must be suppressed. When only one count is too small to release, the smallest
+// other count is suppressed with it so the first cannot be recovered by subtracting
+// the rest from the total.
+func (p Policy) SuppressPartition(counts []int64) []bool {
+	suppressed := make([]bool, len(counts))
+	var primary int
+	for i, n := range counts {
+		if p.Suppressed(n) {
+			suppressed[i] = true
+			primary++
+		}
+	}
+	if primary != 1 {
+		return suppressed
+	}
+
+	released := make([]int, 0, len(counts))
+	for i, n := range counts {
+		if !suppressed[i] && n > 0 {
+			released = append(released, i)
+		}
+	}
+	if len(released) > 0 {
+		sort.SliceStable(released, func(a, b int) bool { return counts[released[a]] < counts[released[b]] })
+		suppressed[released[0]] = true
+	}
+	return suppressed
+}
+
+// Perturb returns a released count with Laplace noise of the policy's scale added,
+// rounded and kept from going negative. Counts are returned as they are when the
+// policy adds no noise.
+func (p Policy) Perturb(n int64) int64 {
+	if p.NoiseScale == 0 {
+		return n
+	}
+	// The inverse CDF of the Laplace distribution at a uniform draw in (-1/2, 1/2)
+	u := rand.Float64() - 0.5
+	if u == -0.5 {
+		u = 0
+	}
+	noise := -p.NoiseScale * math.Copysign(1, u) * math.Log(1-2*math.Abs(u))
+	if noisy := int64(math.Round(float64(n) + noise)); noisy > 0 {
+		return noisy
+	}
+	return 0
+}
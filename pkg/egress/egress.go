@@ -0,0 +1,287 @@
+// Package egress controls outbound connections made by the server. All HTTP clients
+// for external services are built here so that they share one egress allowlist,
+// the corporate proxy settings and optional TLS certificate pinning.
+package egress
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+	"golang.org/x/net/http/httpproxy"
+)
+
+// Options configures outbound connections
+type Options struct {
+	// Allowlist holds the destinations the server may connect to: host names,
+	// "*.example.com" for any subdomain, IP addresses or CIDR ranges. An empty
+	// allowlist allows every destination.
+	Allowlist []string
+	// ProxyURL routes outbound HTTP and HTTPS requests through a forward proxy. When
+	// empty the standard HTTP_PROXY, HTTPS_PROXY and NO_PROXY variables apply.
+	ProxyURL string
+	// NoProxy lists hosts reached directly, in the NO_PROXY format
+	NoProxy []string
+	// CAFile is a PEM bundle trusted in addition to the system roots, e.g. for a
+	// proxy that inspects TLS traffic
+	CAFile string
+	// Pins restricts the certificates accepted from a host, as entries of the form
+	// "host=sha256/BASE64" holding the hash of a public key in the host's chain
+	Pins []string
+}
+
+// DeniedError is returned for connections to a destination outside the allowlist
+type DeniedError struct {
+	Host string
+}
+
+func (e *DeniedError) Error() string {
+	return fmt.Sprintf("egress: destination %q is not on the allowlist", e.Host)
+}
+
+// policy is the compiled form of Options
+type policy struct {
+	hosts     map[string]bool
+	suffixes  []string
+	networks  []*net.IPNet
+	transport *http.Transport
+	pinned    map[string]*http.Transport // Per pinned host, verifying its pins
+}
+
+var (
+	mu      sync.RWMutex
+	current = mustCompile(Options{})
+)
+
+// Configure replaces the outbound settings. Clients created earlier pick up the new
+// settings on their next request.
+func Configure(opts Options) error {
+	p, err := compile(opts)
+	if err != nil {
+		return err
+	}
+
+	mu.Lock()
+	previous := current
+	current = p
+	mu.Unlock()
+
+	previous.transport.CloseIdleConnections()
+	for _, t := range previous.pinned {
+		t.CloseIdleConnections()
+	}
+	return nil
+}
+
+// Check returns a *DeniedError when host may not be connected to
+func Check(host string) error {
+	return active().check(host)
+}
+
+// Dial connects to address when its host is allowed. Non-HTTP clients such as
+// SFTP use it instead of net.Dial.
+func Dial(ctx context.Context, network, address string, timeout time.Duration) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return nil, err
+	}
+	if err := Check(host); err != nil {
+		return nil, err
+	}
+	dialer := &net.Dialer{Timeout: timeout}
+	return dialer.DialContext(ctx, network, address)
+}
+
+// NewClient returns an HTTP client whose requests are checked against the
+// allowlist and guarded by dependency
+func NewClient(dependency *resilience.Dependency, timeout time.Duration) *http.Client {
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: resilience.NewTransport(dependency, NewTransport()),
+	}
+}
+
+// NewTransport returns a transport applying the outbound settings. Requests to a
+// host outside the allowlist fail without a connection being made; the error is
+// marked permanent so it does not count against the dependency's circuit.
+func NewTransport() http.RoundTripper {
+	return roundTripper{}
+}
+
+type roundTripper struct{}
+
+func (roundTripper) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := active()
+	if err := p.check(req.URL.Hostname()); err != nil {
+		if req.Body != nil {
+			req.Body.Close()
+		}
+		return nil, resilience.Permanent(err)
+	}
+	return p.transportFor(req.URL.Hostname()).RoundTrip(req)
+}
+
+func active() *policy {
+	mu.RLock()
+	defer mu.RUnlock()
+	return current
+}
+
+// check applies the allowlist to host
+func (p *policy) check(host string) error {
+	if p.hosts == nil {
+		return nil
+	}
+
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	if p.hosts[host] {
+		return nil
+	}
+	for _, suffix := range p.suffixes {
+		if strings.HasSuffix(host, suffix) {
+			return nil
+		}
+	}
+	if ip := net.ParseIP(host); ip != nil {
+		for _, network := range p.networks {
+			if network.Contains(ip) {
+				return nil
+			}
+		}
+	}
+	return &DeniedError{Host: host}
+}
+
+// transportFor returns the transport for requests to host
+func (p *policy) transportFor(host string) *http.Transport {
+	if t, ok := p.pinned[strings.ToLower(strings.TrimSuffix(host, "."))]; ok {
+		return t
+	}
+	return p.transport
+}
+
+// verifyPins returns a check requiring one certificate of the verified chain to
+// carry one of the pinned keys. The host is bound here rather than read from the
+// connection state, which has no server name for IP addresses.
+func verifyPins(host string, pins map[string]bool) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		for _, chain := range cs.VerifiedChains {
+			for _, cert := range chain {
+				sum := sha256.Sum256(cert.RawSubjectPublicKeyInfo)
+				if pins[base64.StdEncoding.EncodeToString(sum[:])] {
+					return nil
+				}
+			}
+		}
+		return fmt.Errorf("egress: certificate of %q does not match a pinned key", host)
+	}
+}
+
+func compile(opts Options) (*policy, error) {
+	p := &policy{pinned: map[string]*http.Transport{}}
+
+	for _, entry := range opts.Allowlist {
+		entry = strings.ToLower(strings.TrimSpace(entry))
+		if entry == "" {
+			continue
+		}
+		if p.hosts == nil {
+			p.hosts = map[string]bool{}
+		}
+		switch {
+		case strings.HasPrefix(entry, "*."):
+			p.suffixes = append(p.suffixes, entry[1:])
+		case strings.Contains(entry, "/"):
+			_, network, err := net.ParseCIDR(entry)
+			if err != nil {
+				return nil, fmt.Errorf("egress: invalid allowlist range %q: %w", entry, err)
+			}
+			p.networks = append(p.networks, network)
+		default:
+			p.hosts[strings.TrimSuffix(entry, ".")] = true
+		}
+	}
+
+	pins := map[string]map[string]bool{}
+	for _, entry := range opts.Pins {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		host, pin, ok := strings.Cut(entry, "=")
+		hash, found := strings.CutPrefix(strings.TrimSpace(pin), "sha256/")
+		if !ok || !found {
+			return nil, fmt.Errorf("egress: invalid TLS pin %q, expected host=sha256/BASE64", entry)
+		}
+		if sum, err := base64.StdEncoding.DecodeString(hash); err != nil || len(sum) != sha256.Size {
+			return nil, fmt.Errorf("egress: invalid TLS pin hash for %q", host)
+		}
+		host = strings.ToLower(strings.TrimSpace(host))
+		if pins[host] == nil {
+			pins[host] = map[string]bool{}
+		}
+		pins[host][hash] = true
+	}
+
+	tlsConfig := &tls.Config{MinVersion: tls.VersionTLS12}
+	if opts.CAFile != "" {
+		pem, err := os.ReadFile(opts.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("egress: failed to read CA file: %w", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("egress: no certificates found in %s", opts.CAFile)
+		}
+		tlsConfig.RootCAs = roots
+	}
+
+	proxy := http.ProxyFromEnvironment
+	if opts.ProxyURL != "" {
+		if u, err := url.Parse(opts.ProxyURL); err != nil || u.Host == "" {
+			return nil, fmt.Errorf("egress: invalid proxy URL")
+		}
+		proxyFunc := (&httpproxy.Config{
+			HTTPProxy:  opts.ProxyURL,
+			HTTPSProxy: opts.ProxyURL,
+			NoProxy:    strings.Join(opts.NoProxy, ","),
+		}).ProxyFunc()
+		proxy = func(req *http.Request) (*url.URL, error) {
+			return proxyFunc(req.URL)
+		}
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = proxy
+	transport.TLSClientConfig = tlsConfig
+	p.transport = transport
+
+	for host, hashes := range pins {
+		pinned := transport.Clone()
+		pinned.TLSClientConfig.VerifyConnection = verifyPins(host, hashes)
+		p.pinned[host] = pinned
+	}
+	return p, nil
+}
+
+func mustCompile(opts Options) *policy {
+	p, err := compile(opts)
+	if err != nil {
+		panic(err)
+	}
+	return p
+}
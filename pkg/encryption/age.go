@@ -0,0 +1,276 @@
+package encryption
+
+import (
+	"bytes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/chacha20poly1305"
+	"golang.org/x/crypto/curve25519"
+	"golang.org/x/crypto/hkdf"
+)
+
+// ageChunkSize is the plaintext size of each payload chunk in the age format
+const ageChunkSize = 64 * 1024
+
+// ageRecipient encrypts to an age X25519 public key ("age1...") following the
+// age-encryption.org/v1 format, so output can be decrypted with the age or rage tools
+type ageRecipient struct {
+	publicKey []byte
+	encoded   string
+}
+
+// parseAgeRecipient decodes a Bech32-encoded age X25519 public key
+func parseAgeRecipient(key string) (*ageRecipient, error) {
+	hrp, data, err := bech32Decode(key)
+	if err != nil {
+		return nil, fmt.Errorf("invalid age public key: %w", err)
+	}
+	if hrp != "age" || len(data) != curve25519.PointSize {
+		return nil, errors.New("invalid age public key: not an X25519 recipient")
+	}
+
+	return &ageRecipient{publicKey: data, encoded: strings.ToLower(key)}, nil
+}
+
+// Extension returns the file extension for age-encrypted files
+func (r *ageRecipient) Extension() string {
+	return ".age"
+}
+
+// Fingerprint returns the recipient's public key, which is its own identifier in age
+func (r *ageRecipient) Fingerprint() string {
+	return r.encoded
+}
+
+// Encrypt writes the age header for a fresh file key to dst and returns the payload writer
+func (r *ageRecipient) Encrypt(dst io.Writer) (io.WriteCloser, error) {
+	fileKey := make([]byte, 16)
+	ephemeral := make([]byte, curve25519.ScalarSize)
+	nonce := make([]byte, 16)
+	for _, b := range [][]byte{fileKey, ephemeral, nonce} {
+		if _, err := rand.Read(b); err != nil {
+			return nil, err
+		}
+	}
+
+	// Wrap the file key for the recipient (X25519 stanza)
+	share, err := curve25519.X25519(ephemeral, curve25519.Basepoint)
+	if err != nil {
+		return nil, err
+	}
+	shared, err := curve25519.X25519(ephemeral, r.publicKey)
+	if err != nil {
+		return nil, err
+	}
+	salt := append(append([]byte{}, share...), r.publicKey...)
+	wrapKey, err := hkdfKey(shared, salt, "age-encryption.org/v1/X25519")
+	if err != nil {
+		return nil, err
+	}
+	wrapAEAD, err := chacha20poly1305.New(wrapKey)
+	if err != nil {
+		return nil, err
+	}
+	body := wrapAEAD.Seal(nil, make([]byte, chacha20poly1305.NonceSize), fileKey, nil)
+
+	var header bytes.Buffer
+	header.WriteString("age-encryption.org/v1\n")
+	fmt.Fprintf(&header, "-> X25519 %s\n", base64.RawStdEncoding.EncodeToString(share))
+	writeWrapped(&header, base64.RawStdEncoding.EncodeToString(body))
+	header.WriteString("---")
+
+	// The MAC covers the header up to and including the "---" marker
+	macKey, err := hkdfKey(fileKey, nil, "header")
+	if err != nil {
+		return nil, err
+	}
+	mac := hmac.New(sha256.New, macKey)
+	mac.Write(header.Bytes())
+	fmt.Fprintf(&header, " %s\n", base64.RawStdEncoding.EncodeToString(mac.Sum(nil)))
+	header.Write(nonce)
+
+	if _, err := dst.Write(header.Bytes()); err != nil {
+		return nil, err
+	}
+
+	payloadKey, err := hkdfKey(fileKey, nonce, "payload")
+	if err != nil {
+		return nil, err
+	}
+	aead, err := chacha20poly1305.New(payloadKey)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ageStreamWriter{dst: dst, aead: aead, buf: make([]byte, 0, ageChunkSize)}, nil
+}
+
+// ageStreamWriter encrypts the payload in STREAM chunks. A full chunk is held back
+// until more data arrives, because the final chunk must be flagged in its nonce.
+type ageStreamWriter struct {
+	dst     io.Writer
+	aead    cipher.AEAD
+	buf     []byte
+	counter uint64
+	closed  bool
+}
+
+// Write buffers p and seals every chunk that is known not to be the last
+func (w *ageStreamWriter) Write(p []byte) (int, error) {
+	if w.closed {
+		return 0, errors.New("write to closed age writer")
+	}
+
+	written := 0
+	for len(p) > 0 {
+		if len(w.buf) == ageChunkSize {
+			if err := w.flush(false); err != nil {
+				return written, err
+			}
+		}
+		n := copy(w.buf[len(w.buf):ageChunkSize], p)
+		w.buf = w.buf[:len(w.buf)+n]
+		p = p[n:]
+		written += n
+	}
+	return written, nil
+}
+
+// Close seals the final chunk
+func (w *ageStreamWriter) Close() error {
+	if w.closed {
+		return nil
+	}
+	w.closed = true
+	return w.flush(true)
+}
+
+// flush seals the buffered chunk with the next nonce
+func (w *ageStreamWriter) flush(last bool) error {
+	nonce := make([]byte, chacha20poly1305.NonceSize)
+	binary.BigEndian.PutUint64(nonce[3:11], w.counter)
+	if last {
+		nonce[11] = 1
+	}
+
+	if _, err := w.dst.Write(w.aead.Seal(nil, nonce, w.buf, nil)); err != nil {
+		return err
+	}
+	w.counter++
+	w.buf = w.buf[:0]
+	return nil
+}
+
+// hkdfKey derives a 32-byte key with HKDF-SHA-256
+func hkdfKey(secret, salt []byte, info string) ([]byte, error) {
+	key := make([]byte, 32)
+	if _, err := io.ReadFull(hkdf.New(sha256.New, secret, salt, []byte(info)), key); err != nil {
+		return nil, err
+	}
+	return key, nil
+}
+
+// writeWrapped writes a stanza body in 64-column lines. The last line is always shorter
+// than 64 columns, so an empty line follows a body that fills its last line.
+func writeWrapped(buf *bytes.Buffer, s string) {
+	for len(s) >= 64 {
+		buf.WriteString(s[:64] + "\n")
+		s = s[64:]
+	}
+	buf.WriteString(s + "\n")
+}
+
+const bech32Charset = "qpzry9x8gf2tvdw0s3jn54khce6mua7l"
+
+// bech32Decode decodes a Bech32 string into its human-readable part and data bytes
+func bech32Decode(s string) (string, []byte, error) {
+	if strings.ToLower(s) != s && strings.ToUpper(s) != s {
+		return "", nil, errors.New("mixed case")
+	}
+	s = strings.ToLower(s)
+
+	pos := strings.LastIndexByte(s, '1')
+	if pos < 1 || pos+7 > len(s) {
+		return "", nil, errors.New("separator in invalid position")
+	}
+	hrp := s[:pos]
+
+	values := make([]byte, 0, len(s)-pos-1)
+	for _, c := range s[pos+1:] {
+		idx := strings.IndexRune(bech32Charset, c)
+		if idx < 0 {
+			return "", nil, fmt.Errorf("invalid character %q", c)
+		}
+		values = append(values, byte(idx))
+	}
+
+	if bech32Polymod(append(bech32ExpandHRP(hrp), values...)) != 1 {
+		return "", nil, errors.New("invalid checksum")
+	}
+
+	data, err := convertBits(values[:len(values)-6], 5, 8)
+	if err != nil {
+		return "", nil, err
+	}
+	return hrp, data, nil
+}
+
+// bech32Polymod computes the Bech32 checksum polynomial
+func bech32Polymod(values []byte) uint32 {
+	generator := [5]uint32{0x3b6a57b2, 0x26508e6d, 0x1ea119fa, 0x3d4233dd, 0x2a1462b3}
+	chk := uint32(1)
+	for _, v := range values {
+		top := chk >> 25
+		chk = (chk&0x1ffffff)<<5 ^ uint32(v)
+		for i := 0; i < 5; i++ {
+			if (top>>i)&1 == 1 {
+				chk ^= generator[i]
+			}
+		}
+	}
+	return chk
+}
+
+// bech32ExpandHRP expands the human-readable part for checksum computation
+func bech32ExpandHRP(hrp string) []byte {
+	expanded := make([]byte, 0, len(hrp)*2+1)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]>>5)
+	}
+	expanded = append(expanded, 0)
+	for i := 0; i < len(hrp); i++ {
+		expanded = append(expanded, hrp[i]&31)
+	}
+	return expanded
+}
+
+// convertBits regroups 5-bit values into bytes without padding
+func convertBits(data []byte, from, to uint) ([]byte, error) {
+	var acc uint32
+	var bits uint
+	maxv := uint32(1)<<to - 1
+	out := make([]byte, 0, len(data)*int(from)/int(to))
+
+	for _, value := range data {
+		acc = acc<<from | uint32(value)
+		bits += from
+		for bits >= to {
+			bits -= to
+			out = append(out, byte(acc>>bits&maxv))
+		}
+	}
+
+	if bits >= from || (acc<<(to-bits))&maxv != 0 {
+		return nil, errors.New("invalid padding")
+	}
+	return out, nil
+}
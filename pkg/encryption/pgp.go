@@ -0,0 +1,51 @@
+package encryption
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+
+	"golang.org/x/crypto/openpgp"
+)
+
+// pgpRecipient encrypts to an OpenPGP public key, readable with gpg
+type pgpRecipient struct {
+	entities openpgp.EntityList
+}
+
+// parsePGPRecipient reads an ASCII-armored OpenPGP public key
+func parsePGPRecipient(key string) (*pgpRecipient, error) {
+	entities, err := openpgp.ReadArmoredKeyRing(strings.NewReader(key))
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenPGP public key: %w", err)
+	}
+	if len(entities) == 0 {
+		return nil, errors.New("invalid OpenPGP public key: no keys found")
+	}
+
+	// Reject keys without an encryption-capable subkey up front rather than when an export runs
+	w, err := openpgp.Encrypt(io.Discard, entities, nil, nil, nil)
+	if err != nil {
+		return nil, fmt.Errorf("invalid OpenPGP public key: %w", err)
+	}
+	w.Close()
+
+	return &pgpRecipient{entities: entities}, nil
+}
+
+// Extension returns the file extension for binary OpenPGP messages
+func (r *pgpRecipient) Extension() string {
+	return ".gpg"
+}
+
+// Fingerprint returns the fingerprint of the primary key
+func (r *pgpRecipient) Fingerprint() string {
+	return strings.ToUpper(hex.EncodeToString(r.entities[0].PrimaryKey.Fingerprint[:]))
+}
+
+// Encrypt returns a writer producing a binary OpenPGP message for the key
+func (r *pgpRecipient) Encrypt(dst io.Writer) (io.WriteCloser, error) {
+	return openpgp.Encrypt(dst, r.entities, nil, &openpgp.FileHints{IsBinary: true}, nil)
+}
@@ -0,0 +1,31 @@
+package encryption
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Recipient encrypts streams to a public key so that only the holder of the matching
+// private key can read them
+type Recipient interface {
+	// Encrypt returns a writer that encrypts everything written to it into dst.
+	// Close must be called to flush the final block; it does not close dst.
+	Encrypt(dst io.Writer) (io.WriteCloser, error)
+	// Extension is the file extension conventionally used for the encrypted output
+	Extension() string
+	// Fingerprint identifies the public key, e.g. in export manifests
+	Fingerprint() string
+}
+
+// ParseRecipient parses a public key for the given format ("age" or "gpg")
+func ParseRecipient(format, key string) (Recipient, error) {
+	switch strings.ToLower(format) {
+	case "age":
+		return parseAgeRecipient(strings.TrimSpace(key))
+	case "gpg", "pgp", "openpgp":
+		return parsePGPRecipient(key)
+	default:
+		return nil, fmt.Errorf("unsupported encryption format %q", format)
+	}
+}
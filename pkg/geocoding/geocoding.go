@@ -0,0 +1,87 @@
+package geocoding
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+)
+
+// ErrNoMatch is returned when a provider cannot match an address
+var ErrNoMatch = errors.New("geocoding: address could not be matched")
+
+// Address is a postal address to verify
+type Address struct {
+	Line       []string
+	City       string
+	State      string
+	PostalCode string
+	Country    string
+}
+
+// Result is a verified, normalized address and its coordinates
+type Result struct {
+	Address   Address
+	Formatted string
+	Latitude  float64
+	Longitude float64
+}
+
+// Provider verifies and geocodes postal addresses
+type Provider interface {
+	// Name returns the provider identifier, e.g. "nominatim"
+	Name() string
+	// Verify normalizes an address and resolves its coordinates. It returns ErrNoMatch
+	// when the address cannot be found.
+	Verify(ctx context.Context, address Address) (*Result, error)
+}
+
+// Options configures a provider
+type Options struct {
+	BaseURL string // Overrides the provider's default endpoint
+	APIKey  string // Google API key or Smarty auth token
+	AuthID  string // Smarty auth ID
+	Email   string // Contact address sent to Nominatim per its usage policy
+}
+
+// NewProvider returns the named provider, or nil when name is empty or "none"
+func NewProvider(name string, opts Options) (Provider, error) {
+	name = strings.ToLower(name)
+	if name == "" || name == "none" {
+		return nil, nil
+	}
+
+	client := egress.NewClient(resilience.Get("geocoding_"+name), 10*time.Second)
+
+	switch name {
+	case "nominatim":
+		return newNominatim(client, opts), nil
+	case "google":
+		if opts.APIKey == "" {
+			return nil, fmt.Errorf("geocoding: google provider requires an API key")
+		}
+		return newGoogle(client, opts), nil
+	case "smarty":
+		if opts.APIKey == "" || opts.AuthID == "" {
+			return nil, fmt.Errorf("geocoding: smarty provider requires an auth ID and token")
+		}
+		return newSmarty(client, opts), nil
+	default:
+		return nil, fmt.Errorf("geocoding: unknown provider %q", name)
+	}
+}
+
+// oneLine formats an address as a single line for free-form geocoders
+func oneLine(address Address) string {
+	parts := append([]string{}, address.Line...)
+	for _, part := range []string{address.City, address.State, address.PostalCode, address.Country} {
+		if part != "" {
+			parts = append(parts, part)
+		}
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,114 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// google geocodes addresses with the Google Geocoding API
+type google struct {
+	client  *http.Client
+	baseURL string
+	apiKey  string
+}
+
+func newGoogle(client *http.Client, opts Options) *google {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = "https://maps.googleapis.com/maps/api/geocode/json"
+	}
+	return &google{client: client, baseURL: baseURL, apiKey: opts.APIKey}
+}
+
+// Name returns the provider identifier
+func (g *google) Name() string {
+	return "google"
+}
+
+// Verify geocodes an address and normalizes it from the returned address components
+func (g *google) Verify(ctx context.Context, address Address) (*Result, error) {
+	params := url.Values{}
+	params.Set("address", oneLine(address))
+	params.Set("key", g.apiKey)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, g.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := g.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("google geocoding request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	var body struct {
+		Status  string `json:"status"`
+		Results []struct {
+			FormattedAddress  string `json:"formatted_address"`
+			PartialMatch      bool   `json:"partial_match"`
+			AddressComponents []struct {
+				LongName  string   `json:"long_name"`
+				ShortName string   `json:"short_name"`
+				Types     []string `json:"types"`
+			} `json:"address_components"`
+			Geometry struct {
+				Location struct {
+					Lat float64 `json:"lat"`
+					Lng float64 `json:"lng"`
+				} `json:"location"`
+			} `json:"geometry"`
+		} `json:"results"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return nil, fmt.Errorf("failed to decode google geocoding response: %w", err)
+	}
+
+	switch body.Status {
+	case "OK":
+	case "ZERO_RESULTS":
+		return nil, ErrNoMatch
+	default:
+		return nil, fmt.Errorf("google geocoding returned status %s", body.Status)
+	}
+
+	result := body.Results[0]
+	if result.PartialMatch {
+		return nil, ErrNoMatch
+	}
+
+	normalized := address
+	var number, route string
+	for _, component := range result.AddressComponents {
+		for _, t := range component.Types {
+			switch t {
+			case "street_number":
+				number = component.LongName
+			case "route":
+				route = component.ShortName
+			case "locality", "postal_town":
+				normalized.City = component.LongName
+			case "administrative_area_level_1":
+				normalized.State = component.ShortName
+			case "postal_code":
+				normalized.PostalCode = component.LongName
+			case "country":
+				normalized.Country = component.ShortName
+			}
+		}
+	}
+	if street := strings.TrimSpace(number + " " + route); street != "" {
+		normalized.Line = []string{street}
+	}
+
+	return &Result{
+		Address:   normalized,
+		Formatted: result.FormattedAddress,
+		Latitude:  result.Geometry.Location.Lat,
+		Longitude: result.Geometry.Location.Lng,
+	}, nil
+}
@@ -0,0 +1,127 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// nominatim geocodes addresses with an OpenStreetMap Nominatim server
+type nominatim struct {
+	client  *http.Client
+	baseURL string
+	email   string
+}
+
+func newNominatim(client *http.Client, opts Options) *nominatim {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = "https://nominatim.openstreetmap.org"
+	}
+	return &nominatim{client: client, baseURL: strings.TrimRight(baseURL, "/"), email: opts.Email}
+}
+
+// Name returns the provider identifier
+func (n *nominatim) Name() string {
+	return "nominatim"
+}
+
+// Verify geocodes an address using the structured search API
+func (n *nominatim) Verify(ctx context.Context, address Address) (*Result, error) {
+	params := url.Values{}
+	params.Set("format", "jsonv2")
+	params.Set("addressdetails", "1")
+	params.Set("limit", "1")
+	params.Set("street", strings.Join(address.Line, " "))
+	params.Set("city", address.City)
+	params.Set("state", address.State)
+	params.Set("postalcode", address.PostalCode)
+	params.Set("country", address.Country)
+	if n.email != "" {
+		params.Set("email", n.email)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, n.baseURL+"/search?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", "HealthHub API")
+
+	resp, err := n.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("nominatim request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("nominatim returned status %d", resp.StatusCode)
+	}
+
+	var places []struct {
+		Lat         string `json:"lat"`
+		Lon         string `json:"lon"`
+		DisplayName string `json:"display_name"`
+		Address     struct {
+			HouseNumber string `json:"house_number"`
+			Road        string `json:"road"`
+			City        string `json:"city"`
+			Town        string `json:"town"`
+			Village     string `json:"village"`
+			State       string `json:"state"`
+			Postcode    string `json:"postcode"`
+			CountryCode string `json:"country_code"`
+		} `json:"address"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&places); err != nil {
+		return nil, fmt.Errorf("failed to decode nominatim response: %w", err)
+	}
+	if len(places) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	place := places[0]
+	lat, err := strconv.ParseFloat(place.Lat, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid latitude in nominatim response: %w", err)
+	}
+	lon, err := strconv.ParseFloat(place.Lon, 64)
+	if err != nil {
+		return nil, fmt.Errorf("invalid longitude in nominatim response: %w", err)
+	}
+
+	city := place.Address.City
+	if city == "" {
+		city = place.Address.Town
+	}
+	if city == "" {
+		city = place.Address.Village
+	}
+
+	normalized := address
+	if street := strings.TrimSpace(place.Address.HouseNumber + " " + place.Address.Road); street != "" {
+		normalized.Line = []string{street}
+	}
+	if city != "" {
+		normalized.City = city
+	}
+	if place.Address.State != "" {
+		normalized.State = place.Address.State
+	}
+	if place.Address.Postcode != "" {
+		normalized.PostalCode = place.Address.Postcode
+	}
+	if place.Address.CountryCode != "" {
+		normalized.Country = strings.ToUpper(place.Address.CountryCode)
+	}
+
+	return &Result{
+		Address:   normalized,
+		Formatted: place.DisplayName,
+		Latitude:  lat,
+		Longitude: lon,
+	}, nil
+}
@@ -0,0 +1,103 @@
+package geocoding
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// smarty verifies US addresses with the Smarty US Street Address API
+type smarty struct {
+	client    *http.Client
+	baseURL   string
+	authID    string
+	authToken string
+}
+
+func newSmarty(client *http.Client, opts Options) *smarty {
+	baseURL := opts.BaseURL
+	if baseURL == "" {
+		baseURL = "https://us-street.api.smarty.com/street-address"
+	}
+	return &smarty{client: client, baseURL: baseURL, authID: opts.AuthID, authToken: opts.APIKey}
+}
+
+// Name returns the provider identifier
+func (s *smarty) Name() string {
+	return "smarty"
+}
+
+// Verify standardizes a US address to USPS format and resolves its coordinates
+func (s *smarty) Verify(ctx context.Context, address Address) (*Result, error) {
+	params := url.Values{}
+	params.Set("auth-id", s.authID)
+	params.Set("auth-token", s.authToken)
+	params.Set("street", strings.Join(address.Line, " "))
+	params.Set("city", address.City)
+	params.Set("state", address.State)
+	params.Set("zipcode", address.PostalCode)
+	params.Set("candidates", "1")
+	params.Set("match", "strict")
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, s.baseURL+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("smarty request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("smarty returned status %d", resp.StatusCode)
+	}
+
+	var candidates []struct {
+		DeliveryLine1 string `json:"delivery_line_1"`
+		DeliveryLine2 string `json:"delivery_line_2"`
+		LastLine      string `json:"last_line"`
+		Components    struct {
+			CityName  string `json:"city_name"`
+			StateAbbr string `json:"state_abbreviation"`
+			Zipcode   string `json:"zipcode"`
+			Plus4Code string `json:"plus4_code"`
+		} `json:"components"`
+		Metadata struct {
+			Latitude  float64 `json:"latitude"`
+			Longitude float64 `json:"longitude"`
+		} `json:"metadata"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&candidates); err != nil {
+		return nil, fmt.Errorf("failed to decode smarty response: %w", err)
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoMatch
+	}
+
+	candidate := candidates[0]
+	normalized := Address{
+		Line:       []string{candidate.DeliveryLine1},
+		City:       candidate.Components.CityName,
+		State:      candidate.Components.StateAbbr,
+		PostalCode: candidate.Components.Zipcode,
+		Country:    "US",
+	}
+	if candidate.DeliveryLine2 != "" {
+		normalized.Line = append(normalized.Line, candidate.DeliveryLine2)
+	}
+	if candidate.Components.Plus4Code != "" {
+		normalized.PostalCode += "-" + candidate.Components.Plus4Code
+	}
+
+	return &Result{
+		Address:   normalized,
+		Formatted: candidate.DeliveryLine1 + ", " + candidate.LastLine,
+		Latitude:  candidate.Metadata.Latitude,
+		Longitude: candidate.Metadata.Longitude,
+	}, nil
+}
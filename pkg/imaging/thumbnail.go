@@ -0,0 +1,96 @@
+package imaging
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	_ "image/gif" // Register GIF decoder
+	"image/jpeg"
+	_ "image/png" // Register PNG decoder
+	"io"
+)
+
+// DefaultThumbnailSize is the maximum width or height of generated thumbnails
+const DefaultThumbnailSize = 256
+
+// Thumbnail decodes an image and returns a JPEG encoded thumbnail that fits
+// within maxSize x maxSize, along with the original image dimensions
+func Thumbnail(r io.Reader, maxSize int) ([]byte, int, int, error) {
+	src, _, err := image.Decode(r)
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return nil, 0, 0, fmt.Errorf("image has no pixels")
+	}
+
+	dst := resize(src, maxSize)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, dst, &jpeg.Options{Quality: 80}); err != nil {
+		return nil, 0, 0, fmt.Errorf("failed to encode thumbnail: %w", err)
+	}
+
+	return buf.Bytes(), width, height, nil
+}
+
+// resize scales src to fit within maxSize using box sampling
+func resize(src image.Image, maxSize int) image.Image {
+	bounds := src.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+
+	if width <= maxSize && height <= maxSize {
+		return src
+	}
+
+	dstWidth, dstHeight := maxSize, maxSize
+	if width > height {
+		dstHeight = height * maxSize / width
+	} else {
+		dstWidth = width * maxSize / height
+	}
+	if dstWidth < 1 {
+		dstWidth = 1
+	}
+	if dstHeight < 1 {
+		dstHeight = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dstWidth, dstHeight))
+
+	for y := 0; y < dstHeight; y++ {
+		y0 := bounds.Min.Y + y*height/dstHeight
+		y1 := bounds.Min.Y + (y+1)*height/dstHeight
+		for x := 0; x < dstWidth; x++ {
+			x0 := bounds.Min.X + x*width/dstWidth
+			x1 := bounds.Min.X + (x+1)*width/dstWidth
+
+			// Average all source pixels that map onto this destination pixel
+			var r, g, b, a, n uint64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					pr, pg, pb, pa := src.At(sx, sy).RGBA()
+					r += uint64(pr)
+					g += uint64(pg)
+					b += uint64(pb)
+					a += uint64(pa)
+					n++
+				}
+			}
+			if n == 0 {
+				continue
+			}
+
+			offset := dst.PixOffset(x, y)
+			dst.Pix[offset+0] = uint8((r / n) >> 8)
+			dst.Pix[offset+1] = uint8((g / n) >> 8)
+			dst.Pix[offset+2] = uint8((b / n) >> 8)
+			dst.Pix[offset+3] = uint8((a / n) >> 8)
+		}
+	}
+
+	return dst
+}
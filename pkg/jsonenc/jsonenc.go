@@ -0,0 +1,245 @@
+// Package jsonenc appends JSON values to byte slices without reflection. Types with
+// hand-written encoders build their JSON from these functions, which produce the
+// same bytes encoding/json does, HTML-safe escaping included, so switching between
+// the two is invisible to clients.
+package jsonenc
+
+import (
+	"math"
+	"strconv"
+	"time"
+	"unicode/utf8"
+)
+
+const hex = "0123456789abcdef"
+
+// safe reports the ASCII characters written to a string unescaped
+var safe = func() (table [utf8.RuneSelf]bool) {
+	for i := ' '; i < utf8.RuneSelf; i++ {
+		table[i] = true
+	}
+	for _, c := range `"\<>&` {
+		table[c] = false
+	}
+	return table
+}()
+
+// AppendString appends s as a JSON string. Invalid UTF-8 is replaced by U+FFFD, and
+// <, >, &, U+2028 and U+2029 are escaped so the JSON can be embedded in HTML.
+func AppendString(b []byte, s string) []byte {
+	b = append(b, '"')
+	start := 0
+	for i := 0; i < len(s); {
+		if c := s[i]; c < utf8.RuneSelf {
+			if safe[c] {
+				i++
+				continue
+			}
+			b = append(b, s[start:i]...)
+			switch c {
+			case '\\', '"':
+				b = append(b, '\\', c)
+			case '\b':
+				b = append(b, '\\', 'b')
+			case '\f':
+				b = append(b, '\\', 'f')
+			case '\n':
+				b = append(b, '\\', 'n')
+			case '\r':
+				b = append(b, '\\', 'r')
+			case '\t':
+				b = append(b, '\\', 't')
+			default:
+				b = append(b, '\\', 'u', '0', '0', hex[c>>4], hex[c&0xF])
+			}
+			i++
+			start = i
+			continue
+		}
+		r, size := utf8.DecodeRuneInString(s[i:])
+		if r == utf8.RuneError && size == 1 {
+			b = append(b, s[start:i]...)
+			b = append(b, "\ufffd"...)
+			i += size
+			start = i
+			continue
+		}
+		if r == '\u2028' || r == '\u2029' {
+			b = append(b, s[start:i]...)
+			b = append(b, '\\', 'u', '2', '0', '2', hex[r&0xF])
+			i += size
+			start = i
+			continue
+		}
+		i += size
+	}
+	b = append(b, s[start:]...)
+	return append(b, '"')
+}
+
+// AppendStrings appends a JSON array of strings, or null for a nil slice
+func AppendStrings(b []byte, values []string) []byte {
+	if values == nil {
+		return append(b, "null"...)
+	}
+	b = append(b, '[')
+	for i, value := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = AppendString(b, value)
+	}
+	return append(b, ']')
+}
+
+// AppendInt appends an integer
+func AppendInt(b []byte, n int64) []byte {
+	return strconv.AppendInt(b, n, 10)
+}
+
+// AppendBool appends true or false
+func AppendBool(b []byte, v bool) []byte {
+	return strconv.AppendBool(b, v)
+}
+
+// AppendFloat appends a floating-point number in the shortest form that reads back as
+// the same value, using exponents for very small and very large magnitudes.
+// encoding/json refuses NaN and infinities; they are written as null.
+func AppendFloat(b []byte, f float64) []byte {
+	if math.IsNaN(f) || math.IsInf(f, 0) {
+		return append(b, "null"...)
+	}
+	format := byte('f')
+	if abs := math.Abs(f); abs != 0 && (abs < 1e-6 || abs >= 1e21) {
+		format = 'e'
+	}
+	b = strconv.AppendFloat(b, f, format, -1, 64)
+	if format == 'e' {
+		// Shorten e-09 to e-9
+		if n := len(b); n >= 4 && b[n-4] == 'e' && b[n-3] == '-' && b[n-2] == '0' {
+			b[n-2] = b[n-1]
+			b = b[:n-1]
+		}
+	}
+	return b
+}
+
+// AppendTime appends a time as an RFC 3339 string with sub-second precision. Times
+// whose year falls outside 0 to 9999, which encoding/json refuses, are written as null.
+func AppendTime(b []byte, t time.Time) []byte {
+	if year := t.Year(); year < 0 || year > 9999 {
+		return append(b, "null"...)
+	}
+	b = append(b, '"')
+	b = t.AppendFormat(b, time.RFC3339Nano)
+	return append(b, '"')
+}
+
+// Object appends the fields of a JSON object, separating them with commas
+type Object struct {
+	b     []byte
+	first bool
+}
+
+// Begin starts an object at the end of b
+func Begin(b []byte) Object {
+	return Object{b: append(b, '{'), first: true}
+}
+
+// Key appends the name of the next field; its value is appended to the returned slice,
+// which is handed back with Set
+func (o *Object) Key(name string) []byte {
+	if !o.first {
+		o.b = append(o.b, ',')
+	}
+	o.first = false
+	o.b = append(o.b, '"')
+	o.b = append(o.b, name...)
+	return append(o.b, '"', ':')
+}
+
+// Set takes back the slice the value of the last field was appended to
+func (o *Object) Set(b []byte) {
+	o.b = b
+}
+
+// String appends a string field
+func (o *Object) String(name, value string) {
+	o.Set(AppendString(o.Key(name), value))
+}
+
+// OptString appends a string field unless it is empty
+func (o *Object) OptString(name, value string) {
+	if value != "" {
+		o.String(name, value)
+	}
+}
+
+// Int appends an integer field
+func (o *Object) Int(name string, value int64) {
+	o.Set(AppendInt(o.Key(name), value))
+}
+
+// OptInt appends an integer field unless it is zero
+func (o *Object) OptInt(name string, value int64) {
+	if value != 0 {
+		o.Int(name, value)
+	}
+}
+
+// Float appends a number field
+func (o *Object) Float(name string, value float64) {
+	o.Set(AppendFloat(o.Key(name), value))
+}
+
+// OptFloat appends a number field unless it is zero
+func (o *Object) OptFloat(name string, value float64) {
+	if value != 0 {
+		o.Float(name, value)
+	}
+}
+
+// Bool appends a boolean field
+func (o *Object) Bool(name string, value bool) {
+	o.Set(AppendBool(o.Key(name), value))
+}
+
+// OptBool appends a boolean field unless it is false
+func (o *Object) OptBool(name string, value bool) {
+	if value {
+		o.Bool(name, value)
+	}
+}
+
+// Time appends a time field
+func (o *Object) Time(name string, value time.Time) {
+	o.Set(AppendTime(o.Key(name), value))
+}
+
+// OptTime appends a time field unless it is nil
+func (o *Object) OptTime(name string, value *time.Time) {
+	if value != nil {
+		o.Time(name, *value)
+	}
+}
+
+// End closes the object and returns the slice it was appended to
+func (o *Object) End() []byte {
+	return append(o.b, '}')
+}
+
+// AppendArray appends a JSON array of values with the given encoder, or null for a nil
+// slice
+func AppendArray[T any](b []byte, values []T, appendValue func([]byte, *T) []byte) []byte {
+	if values == nil {
+		return append(b, "null"...)
+	}
+	b = append(b, '[')
+	for i := range values {
+		if i > 0 {
+			b = append(b, ',')
+		}
+		b = appendValue(b, &values[i])
+	}
+	return append(b, ']')
+}
@@ -0,0 +1,297 @@
+// Package loadshed limits how many requests of each priority class run at once, so
+// that under overload interactive clinician requests keep being served while
+// lower-priority traffic, such as bulk exports, is shed with 503 and Retry-After.
+package loadshed
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// Reasons a request is shed, also the reason label of the shed counter
+const (
+	ReasonLimit      = "limit"      // Its class had no free slot within the class's wait
+	ReasonSaturation = "saturation" // A higher-priority class is saturated
+)
+
+var (
+	inFlight = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "priority_class_in_flight",
+			Help: "Number of requests of a priority class being served",
+		},
+		[]string{"class"},
+	)
+
+	shedTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "priority_class_shed_total",
+			Help: "Total number of requests of a priority class shed with 503 by reason",
+		},
+		[]string{"class", "reason"},
+	)
+)
+
+// Class is a priority class of requests
+type Class struct {
+	Name  string
+	Limit int           // Requests of the class served at once
+	Wait  time.Duration // How long a request waits for a free slot before it is shed
+}
+
+// Options configures a limiter
+type Options struct {
+	Classes    []Class           // Highest priority first; requests not assigned a class run in the first
+	Routes     map[string]string // Class by route prefix; the longest matching prefix applies
+	Keys       map[string]string // Class by API key, which takes precedence over the route
+	KeyHeader  string            // Header carrying the API key
+	Threshold  float64           // Share of a class's slots in use at which lower classes are shed, e.g. 0.8
+	RetryAfter time.Duration     // Sent to shed clients, rounded up to whole seconds
+}
+
+// Limiter admits requests by priority class
+type Limiter struct {
+	classes    []*class
+	routes     []route
+	keys       map[string]*class
+	keyHeader  string
+	retryAfter string
+}
+
+// class is the state of a priority class
+type class struct {
+	Class
+	priority   int
+	slots      chan struct{}
+	saturation int // Slots in use at which the class is saturated
+	shed       atomic.Int64
+}
+
+// route assigns the routes starting with a prefix to a class
+type route struct {
+	prefix string
+	class  *class
+}
+
+// ClassStatus is the load of a priority class on this instance
+type ClassStatus struct {
+	Name      string `json:"name"`
+	Priority  int    `json:"priority"` // 0 is the highest
+	Limit     int    `json:"limit"`
+	WaitMs    int64  `json:"waitMs"`
+	InFlight  int    `json:"inFlight"`
+	Saturated bool   `json:"saturated"`
+	Shed      int64  `json:"shed"` // Since the instance started
+}
+
+// ParseClasses parses priority classes of the form name=limit:wait_ms, highest
+// priority first, e.g. interactive=200:2000 for 200 requests at once that wait up to
+// two seconds for a free slot
+func ParseClasses(entries []string) ([]Class, error) {
+	var classes []Class
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		name, spec, ok := strings.Cut(entry, "=")
+		name = strings.TrimSpace(name)
+		limit, wait, hasWait := strings.Cut(spec, ":")
+		if !ok || name == "" || !hasWait {
+			return nil, fmt.Errorf("loadshed: invalid priority class %q, expected name=limit:wait_ms", entry)
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(limit))
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("loadshed: invalid priority class limit in %q", entry)
+		}
+		ms, err := strconv.Atoi(strings.TrimSpace(wait))
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("loadshed: invalid priority class wait in %q", entry)
+		}
+		if seen[name] {
+			return nil, fmt.Errorf("loadshed: duplicate priority class %s", name)
+		}
+		seen[name] = true
+		classes = append(classes, Class{Name: name, Limit: n, Wait: time.Duration(ms) * time.Millisecond})
+	}
+	return classes, nil
+}
+
+// ParseAssignments parses assignments of route prefixes or API keys to classes, of
+// the form key=class, e.g. /api/v1/exports=bulk
+func ParseAssignments(entries []string) (map[string]string, error) {
+	assignments := make(map[string]string)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		key, name, ok := strings.Cut(entry, "=")
+		key, name = strings.TrimSpace(key), strings.TrimSpace(name)
+		if !ok || key == "" || name == "" {
+			return nil, fmt.Errorf("loadshed: invalid priority assignment %q, expected key=class", entry)
+		}
+		if _, exists := assignments[key]; exists {
+			return nil, fmt.Errorf("loadshed: duplicate priority assignment of %s", key)
+		}
+		assignments[key] = name
+	}
+	return assignments, nil
+}
+
+// New creates a limiter
+func New(opts Options) (*Limiter, error) {
+	if len(opts.Classes) == 0 {
+		return nil, fmt.Errorf("loadshed: no priority classes")
+	}
+	if opts.Threshold <= 0 || opts.Threshold > 1 {
+		return nil, fmt.Errorf("loadshed: saturation threshold must be above 0 and at most 1")
+	}
+
+	l := &Limiter{
+		keys:       make(map[string]*class),
+		keyHeader:  opts.KeyHeader,
+		retryAfter: strconv.Itoa(int(math.Ceil(opts.RetryAfter.Seconds()))),
+	}
+	byName := make(map[string]*class)
+	for i, c := range opts.Classes {
+		cl := &class{
+			Class:      c,
+			priority:   i,
+			slots:      make(chan struct{}, c.Limit),
+			saturation: int(math.Ceil(opts.Threshold * float64(c.Limit))),
+		}
+		l.classes = append(l.classes, cl)
+		byName[c.Name] = cl
+		inFlight.WithLabelValues(c.Name).Set(0)
+	}
+
+	for prefix, name := range opts.Routes {
+		cl, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("loadshed: route %s is assigned to unknown priority class %s", prefix, name)
+		}
+		l.routes = append(l.routes, route{prefix: prefix, class: cl})
+	}
+	sort.Slice(l.routes, func(i, j int) bool { return len(l.routes[i].prefix) > len(l.routes[j].prefix) })
+
+	for key, name := range opts.Keys {
+		cl, ok := byName[name]
+		if !ok {
+			return nil, fmt.Errorf("loadshed: API key is assigned to unknown priority class %s", name)
+		}
+		l.keys[key] = cl
+	}
+	return l, nil
+}
+
+// Middleware serves each request once a slot of its class is free. A request is shed
+// with 503 and Retry-After when no slot frees up within its class's wait, or at once
+// when a higher-priority class is saturated, so that class gets the capacity.
+func (l *Limiter) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		cl := l.classify(c)
+		if reason := l.acquire(c.Request.Context(), cl); reason != "" {
+			cl.shed.Add(1)
+			shedTotal.WithLabelValues(cl.Name, reason).Inc()
+			c.Header("Retry-After", l.retryAfter)
+			apierror.Abort(c, http.StatusServiceUnavailable, apierror.Response{
+				Error:   "Server is overloaded",
+				Message: "requests of the " + cl.Name + " priority class are being shed; retry later",
+				Code:    "SERVER_OVERLOADED",
+			})
+			return
+		}
+		defer l.release(cl)
+
+		c.Next()
+	}
+}
+
+// Statuses returns the load of every class, highest priority first
+func (l *Limiter) Statuses() []ClassStatus {
+	statuses := make([]ClassStatus, 0, len(l.classes))
+	for _, cl := range l.classes {
+		statuses = append(statuses, ClassStatus{
+			Name:      cl.Name,
+			Priority:  cl.priority,
+			Limit:     cl.Limit,
+			WaitMs:    cl.Wait.Milliseconds(),
+			InFlight:  len(cl.slots),
+			Saturated: cl.saturated(),
+			Shed:      cl.shed.Load(),
+		})
+	}
+	return statuses
+}
+
+// classify returns the class of a request: the class of its API key, else of the
+// longest route prefix it matches, else the highest-priority class
+func (l *Limiter) classify(c *gin.Context) *class {
+	if l.keyHeader != "" {
+		if cl, ok := l.keys[c.GetHeader(l.keyHeader)]; ok {
+			return cl
+		}
+	}
+	path := c.Request.URL.Path
+	for _, r := range l.routes {
+		if strings.HasPrefix(path, r.prefix) {
+			return r.class
+		}
+	}
+	return l.classes[0]
+}
+
+// acquire takes a slot of a class, or returns why the request is shed
+func (l *Limiter) acquire(ctx context.Context, cl *class) string {
+	for _, higher := range l.classes[:cl.priority] {
+		if higher.saturated() {
+			return ReasonSaturation
+		}
+	}
+
+	select {
+	case cl.slots <- struct{}{}:
+		inFlight.WithLabelValues(cl.Name).Inc()
+		return ""
+	default:
+	}
+	if cl.Wait <= 0 {
+		return ReasonLimit
+	}
+
+	timer := time.NewTimer(cl.Wait)
+	defer timer.Stop()
+	select {
+	case cl.slots <- struct{}{}:
+		inFlight.WithLabelValues(cl.Name).Inc()
+		return ""
+	case <-timer.C:
+	case <-ctx.Done():
+	}
+	return ReasonLimit
+}
+
+// release frees a slot of a class
+func (l *Limiter) release(cl *class) {
+	<-cl.slots
+	inFlight.WithLabelValues(cl.Name).Dec()
+}
+
+// saturated reports whether the class uses at least its threshold share of its slots
+func (cl *class) saturated() bool {
+	return len(cl.slots) >= cl.saturation
+}
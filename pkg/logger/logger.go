@@ -1,6 +1,8 @@
 package logger
 
 import (
+	"sync"
+
 	"go.uber.org/zap"
 	"go.uber.org/zap/zapcore"
 )
@@ -147,6 +149,21 @@ func LogSecurityEvent(event string, userID string, details map[string]interface{
 	SecurityLogger().Info("Security event", fields...)
 }
 
+// AuditSink receives every audit event after it is logged, e.g. to persist it
+type AuditSink func(action string, resource string, userID string, details map[string]interface{})
+
+var (
+	auditSinkMu sync.RWMutex
+	auditSink   AuditSink
+)
+
+// SetAuditSink sets the sink audit events are passed to
+func SetAuditSink(sink AuditSink) {
+	auditSinkMu.Lock()
+	defer auditSinkMu.Unlock()
+	auditSink = sink
+}
+
 // LogAuditEvent logs an audit event
 func LogAuditEvent(action string, resource string, userID string, details map[string]interface{}) {
 	fields := []zap.Field{
@@ -160,6 +177,13 @@ func LogAuditEvent(action string, resource string, userID string, details map[st
 	}
 
 	AuditLogger().Info("Audit event", fields...)
+
+	auditSinkMu.RLock()
+	sink := auditSink
+	auditSinkMu.RUnlock()
+	if sink != nil {
+		sink(action, resource, userID, details)
+	}
 }
 
 // LogHTTPRequest logs an HTTP request
@@ -0,0 +1,81 @@
+package metrics
+
+import (
+	"sync"
+	"time"
+)
+
+// inFlight tracks the requests being served by route, and how the ones being served
+// when the server started shutting down ended
+type inFlight struct {
+	mu     sync.Mutex
+	routes map[string]int // By method and route
+	total  int
+
+	draining   bool
+	drainStart time.Time
+	atDrain    int // In flight when draining started
+	drained    int // Completed since draining started
+}
+
+// DrainSummary is how the requests being served when the server started shutting
+// down ended
+type DrainSummary struct {
+	InFlight      int            // When draining started
+	Drained       int            // Completed while draining, including requests accepted then
+	Aborted       int            // Still being served when draining ended
+	AbortedRoutes map[string]int // Aborted requests by method and route
+	Duration      time.Duration
+}
+
+// start records a request of a route being served
+func (f *inFlight) start(route string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.routes[route]++
+	f.total++
+}
+
+// end records a request of a route completing
+func (f *inFlight) end(route string) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if f.routes[route]--; f.routes[route] <= 0 {
+		delete(f.routes, route)
+	}
+	f.total--
+	if f.draining {
+		f.drained++
+	}
+}
+
+// StartDrain marks the start of a shutdown. Requests completing from now on count as
+// drained; the ones still being served when DrainSummary is called, as aborted.
+func (r *Registry) StartDrain() {
+	r.inFlight.mu.Lock()
+	defer r.inFlight.mu.Unlock()
+	r.inFlight.draining = true
+	r.inFlight.drainStart = time.Now()
+	r.inFlight.atDrain = r.inFlight.total
+	r.serverDraining.Set(1)
+}
+
+// DrainSummary reports how the requests being served when StartDrain was called
+// ended, counting the ones still being served as aborted
+func (r *Registry) DrainSummary() DrainSummary {
+	r.inFlight.mu.Lock()
+	defer r.inFlight.mu.Unlock()
+	summary := DrainSummary{
+		InFlight:      r.inFlight.atDrain,
+		Drained:       r.inFlight.drained,
+		Aborted:       r.inFlight.total,
+		AbortedRoutes: make(map[string]int, len(r.inFlight.routes)),
+	}
+	for route, count := range r.inFlight.routes {
+		summary.AbortedRoutes[route] = count
+	}
+	if r.inFlight.draining {
+		summary.Duration = time.Since(r.inFlight.drainStart)
+	}
+	return summary
+}
@@ -17,6 +17,11 @@ type Registry struct {
 	httpRequestSize     *prometheus.HistogramVec
 	httpResponseSize    *prometheus.HistogramVec
 
+	// Requests being served, and how they ended when shutting down
+	httpRequestsInFlight *prometheus.GaugeVec
+	serverDraining       prometheus.Gauge
+	inFlight             *inFlight
+
 	// Database metrics
 	dbConnectionsTotal  prometheus.Gauge
 	dbConnectionsActive prometheus.Gauge
@@ -33,6 +38,9 @@ type Registry struct {
 	goroutinesActive prometheus.Gauge
 	memoryUsage      prometheus.Gauge
 	gcDuration       prometheus.Summary
+
+	// Service level objectives, when tracked
+	slos *SLOTracker
 }
 
 // NewRegistry creates a new metrics registry with all application metrics
@@ -74,6 +82,23 @@ func NewRegistry() *Registry {
 			[]string{"method", "endpoint"},
 		),
 
+		httpRequestsInFlight: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "http_requests_in_flight",
+				Help: "Number of HTTP requests being served",
+			},
+			[]string{"method", "endpoint"},
+		),
+
+		serverDraining: promauto.NewGauge(
+			prometheus.GaugeOpts{
+				Name: "http_server_draining",
+				Help: "Whether the server is shutting down and draining in-flight requests (1) or not (0)",
+			},
+		),
+
+		inFlight: &inFlight{routes: make(map[string]int)},
+
 		// Database Metrics
 		dbConnectionsTotal: promauto.NewGauge(
 			prometheus.GaugeOpts{
@@ -170,13 +195,6 @@ func NewRegistry() *Registry {
 func (r *Registry) PrometheusMiddleware() gin.HandlerFunc {
 	return func(c *gin.Context) {
 		start := time.Now()
-
-		// Process request
-		c.Next()
-
-		// Calculate metrics
-		duration := time.Since(start).Seconds()
-		statusCode := strconv.Itoa(c.Writer.Status())
 		method := c.Request.Method
 		endpoint := c.FullPath()
 
@@ -185,9 +203,29 @@ func (r *Registry) PrometheusMiddleware() gin.HandlerFunc {
 			endpoint = "unknown"
 		}
 
+		// Requests are counted in flight until they complete, also when a panic
+		// aborts them
+		route := method + " " + endpoint
+		r.httpRequestsInFlight.WithLabelValues(method, endpoint).Inc()
+		r.inFlight.start(route)
+		defer func() {
+			r.httpRequestsInFlight.WithLabelValues(method, endpoint).Dec()
+			r.inFlight.end(route)
+		}()
+
+		// Process request
+		c.Next()
+
+		// Calculate metrics
+		duration := time.Since(start).Seconds()
+		statusCode := strconv.Itoa(c.Writer.Status())
+
 		// Record metrics
 		r.httpRequestsTotal.WithLabelValues(method, endpoint, statusCode).Inc()
 		r.httpRequestDuration.WithLabelValues(method, endpoint).Observe(duration)
+		if r.slos != nil && c.FullPath() != "" {
+			r.slos.observe(method, endpoint, c.Writer.Status(), time.Since(start), time.Now())
+		}
 
 		// Record request/response sizes if available
 		if c.Request.ContentLength > 0 {
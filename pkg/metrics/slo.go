@@ -0,0 +1,386 @@
+package metrics
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+// Service level indicators tracked for every route
+const (
+	SLILatency = "latency"
+	SLIErrors  = "errors"
+)
+
+// BurnWindows are the windows burn rates are computed over
+var BurnWindows = []time.Duration{5 * time.Minute, 30 * time.Minute, time.Hour, 6 * time.Hour}
+
+// A route violates its objective when it burns its error budget fast enough in a
+// long window and still does in the matching short one, so alerts resolve soon
+// after a burn stops: 14.4 spends 2% of a 30-day budget in an hour, 6 spends 5% in
+// six hours.
+var burnAlerts = []struct {
+	long, short time.Duration
+	rate        float64
+}{
+	{time.Hour, 5 * time.Minute, 14.4},
+	{6 * time.Hour, 30 * time.Minute, 6},
+}
+
+// minBurnRequests is the traffic a long window needs before its burn rate alerts, so
+// a single failure on a quiet route is not reported as a violation
+const minBurnRequests = 20
+
+// sloBuckets is the number of one-minute buckets kept per route, enough for the
+// longest burn window
+const sloBuckets = 360
+
+// Objective is the service level objective of a route group: the share of requests
+// that must complete within the latency threshold, and the share that may fail with
+// a server error
+type Objective struct {
+	Prefix        string // Routes starting with the prefix
+	Latency       time.Duration
+	LatencyTarget float64 // e.g. 0.99
+	ErrorRate     float64 // e.g. 0.01
+}
+
+// ParseObjectives parses objectives of the form prefix=latency_ms:latency_pct:error_pct,
+// e.g. /api/v1/observations=500:99:1 for 99% of requests within 500ms and at most 1%
+// server errors
+func ParseObjectives(entries []string) ([]Objective, error) {
+	var objectives []Objective
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, targets, ok := strings.Cut(entry, "=")
+		parts := strings.Split(targets, ":")
+		prefix = strings.TrimSpace(prefix)
+		if !ok || !strings.HasPrefix(prefix, "/") || len(parts) != 3 {
+			return nil, fmt.Errorf("metrics: invalid SLO target %q, expected prefix=latency_ms:latency_pct:error_pct", entry)
+		}
+		latency, err := strconv.Atoi(strings.TrimSpace(parts[0]))
+		if err != nil || latency <= 0 {
+			return nil, fmt.Errorf("metrics: invalid SLO latency in %q", entry)
+		}
+		latencyPct, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+		if err != nil || latencyPct <= 0 || latencyPct >= 100 {
+			return nil, fmt.Errorf("metrics: invalid SLO latency percentage in %q", entry)
+		}
+		errorPct, err := strconv.ParseFloat(strings.TrimSpace(parts[2]), 64)
+		if err != nil || errorPct <= 0 || errorPct >= 100 {
+			return nil, fmt.Errorf("metrics: invalid SLO error percentage in %q", entry)
+		}
+		if seen[prefix] {
+			return nil, fmt.Errorf("metrics: duplicate SLO target for %s", prefix)
+		}
+		seen[prefix] = true
+		objectives = append(objectives, Objective{
+			Prefix:        prefix,
+			Latency:       time.Duration(latency) * time.Millisecond,
+			LatencyTarget: latencyPct / 100,
+			ErrorRate:     errorPct / 100,
+		})
+	}
+	return objectives, nil
+}
+
+// RouteStatus is the compliance of a route with its objective
+type RouteStatus struct {
+	Method    string                        `json:"method"`
+	Route     string                        `json:"route"`
+	Objective RouteObjective                `json:"objective"`
+	Requests  int64                         `json:"requests"`  // In the longest burn window
+	BurnRates map[string]map[string]float64 `json:"burnRates"` // By SLI, then window
+	Violating bool                          `json:"violating"`
+	Alerts    []BurnAlert                   `json:"alerts,omitempty"`
+}
+
+// RouteObjective is the objective applying to a route
+type RouteObjective struct {
+	Prefix        string  `json:"prefix"`
+	LatencyMs     int64   `json:"latencyMs"`
+	LatencyTarget float64 `json:"latencyTarget"`
+	ErrorRate     float64 `json:"errorRate"`
+}
+
+// BurnAlert is a burn rate condition a route meets
+type BurnAlert struct {
+	SLI         string  `json:"sli"`
+	LongWindow  string  `json:"longWindow"`
+	ShortWindow string  `json:"shortWindow"`
+	Threshold   float64 `json:"threshold"`
+	BurnRate    float64 `json:"burnRate"` // In the long window
+}
+
+// sloBucket counts a route's requests of one minute
+type sloBucket struct {
+	minute int64
+	total  int64
+	slow   int64
+	errors int64
+}
+
+// routeSLO tracks the requests of one route
+type routeSLO struct {
+	method    string
+	route     string
+	objective *Objective
+	buckets   [sloBuckets]sloBucket
+	violating bool
+}
+
+// SLOTracker tracks the compliance of routes with their objectives. Each instance
+// tracks the requests it served.
+type SLOTracker struct {
+	objectives []Objective // Longest prefix first
+
+	mu     sync.Mutex
+	routes map[string]*routeSLO
+
+	requests *prometheus.CounterVec
+	burnRate *prometheus.GaugeVec
+}
+
+// TrackSLOs makes the registry's middleware track the routes' compliance with the
+// objectives. It must be called before the middleware serves requests.
+func (r *Registry) TrackSLOs(objectives []Objective) *SLOTracker {
+	sorted := append([]Objective(nil), objectives...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Prefix) > len(sorted[j].Prefix) })
+
+	r.slos = &SLOTracker{
+		objectives: sorted,
+		routes:     make(map[string]*routeSLO),
+		requests: promauto.NewCounterVec(
+			prometheus.CounterOpts{
+				Name: "slo_requests_total",
+				Help: "Total number of requests on routes with an SLO by result (good, slow, error)",
+			},
+			[]string{"method", "route", "result"},
+		),
+		burnRate: promauto.NewGaugeVec(
+			prometheus.GaugeOpts{
+				Name: "slo_burn_rate",
+				Help: "Rate at which routes spend their error budget; 1 spends it exactly over the SLO period",
+			},
+			[]string{"method", "route", "sli", "window"},
+		),
+	}
+	return r.slos
+}
+
+// objective returns the objective of the route, or nil when none applies
+func (t *SLOTracker) objective(route string) *Objective {
+	for i := range t.objectives {
+		if strings.HasPrefix(route, t.objectives[i].Prefix) {
+			return &t.objectives[i]
+		}
+	}
+	return nil
+}
+
+// observe records a served request
+func (t *SLOTracker) observe(method, route string, status int, duration time.Duration, now time.Time) {
+	objective := t.objective(route)
+	if objective == nil {
+		return
+	}
+
+	result := "good"
+	failed := status >= 500
+	slow := duration > objective.Latency
+	switch {
+	case failed:
+		result = "error"
+	case slow:
+		result = "slow"
+	}
+	t.requests.WithLabelValues(method, route, result).Inc()
+
+	key := method + " " + route
+	minute := now.Unix() / 60
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	r := t.routes[key]
+	if r == nil {
+		r = &routeSLO{method: method, route: route, objective: objective}
+		t.routes[key] = r
+	}
+	b := &r.buckets[minute%sloBuckets]
+	if b.minute != minute {
+		*b = sloBucket{minute: minute}
+	}
+	b.total++
+	if slow {
+		b.slow++
+	}
+	if failed {
+		b.errors++
+	}
+}
+
+// window sums the route's requests of the last d
+func (r *routeSLO) window(d time.Duration, now time.Time) (total, slow, errors int64) {
+	minute := now.Unix() / 60
+	for m := minute - int64(d/time.Minute) + 1; m <= minute; m++ {
+		if b := r.buckets[m%sloBuckets]; b.minute == m {
+			total += b.total
+			slow += b.slow
+			errors += b.errors
+		}
+	}
+	return total, slow, errors
+}
+
+// status computes the route's burn rates
+func (r *routeSLO) status(now time.Time) RouteStatus {
+	s := RouteStatus{
+		Method: r.method,
+		Route:  r.route,
+		Objective: RouteObjective{
+			Prefix:        r.objective.Prefix,
+			LatencyMs:     r.objective.Latency.Milliseconds(),
+			LatencyTarget: r.objective.LatencyTarget,
+			ErrorRate:     r.objective.ErrorRate,
+		},
+		BurnRates: map[string]map[string]float64{SLILatency: {}, SLIErrors: {}},
+	}
+
+	requests := make(map[time.Duration]int64)
+	for _, w := range BurnWindows {
+		total, slow, errors := r.window(w, now)
+		requests[w] = total
+		latencyBurn, errorBurn := 0.0, 0.0
+		if total > 0 {
+			latencyBurn = float64(slow) / float64(total) / (1 - r.objective.LatencyTarget)
+			errorBurn = float64(errors) / float64(total) / r.objective.ErrorRate
+		}
+		s.BurnRates[SLILatency][windowLabel(w)] = latencyBurn
+		s.BurnRates[SLIErrors][windowLabel(w)] = errorBurn
+	}
+	s.Requests = requests[BurnWindows[len(BurnWindows)-1]]
+
+	for _, sli := range []string{SLILatency, SLIErrors} {
+		for _, alert := range burnAlerts {
+			long := s.BurnRates[sli][windowLabel(alert.long)]
+			short := s.BurnRates[sli][windowLabel(alert.short)]
+			if requests[alert.long] >= minBurnRequests && long >= alert.rate && short >= alert.rate {
+				s.Alerts = append(s.Alerts, BurnAlert{
+					SLI:         sli,
+					LongWindow:  windowLabel(alert.long),
+					ShortWindow: windowLabel(alert.short),
+					Threshold:   alert.rate,
+					BurnRate:    long,
+				})
+			}
+		}
+	}
+	s.Violating = len(s.Alerts) > 0
+	return s
+}
+
+// Statuses returns the compliance of every route served since the instance started,
+// ordered by route
+func (t *SLOTracker) Statuses() []RouteStatus {
+	now := time.Now()
+
+	t.mu.Lock()
+	statuses := make([]RouteStatus, 0, len(t.routes))
+	for _, r := range t.routes {
+		statuses = append(statuses, r.status(now))
+	}
+	t.mu.Unlock()
+
+	sort.Slice(statuses, func(i, j int) bool {
+		if statuses[i].Route != statuses[j].Route {
+			return statuses[i].Route < statuses[j].Route
+		}
+		return statuses[i].Method < statuses[j].Method
+	})
+	return statuses
+}
+
+// Violations returns the routes currently violating their objective
+func (t *SLOTracker) Violations() []RouteStatus {
+	violations := []RouteStatus{}
+	for _, s := range t.Statuses() {
+		if s.Violating {
+			violations = append(violations, s)
+		}
+	}
+	return violations
+}
+
+// Run publishes the burn rates every minute and logs the routes that start or stop
+// violating their objective, until the context is cancelled
+func (t *SLOTracker) Run(ctx context.Context) {
+	ticker := time.NewTicker(time.Minute)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			t.evaluate()
+		}
+	}
+}
+
+// evaluate publishes the burn rates and logs violation changes
+func (t *SLOTracker) evaluate() {
+	now := time.Now()
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	for _, r := range t.routes {
+		s := r.status(now)
+		for sli, windows := range s.BurnRates {
+			for window, rate := range windows {
+				t.burnRate.WithLabelValues(r.method, r.route, sli, window).Set(rate)
+			}
+		}
+
+		switch {
+		case s.Violating && !r.violating:
+			for _, alert := range s.Alerts {
+				logger.Warn("Route is burning its SLO error budget",
+					zap.String("method", r.method),
+					zap.String("route", r.route),
+					zap.String("sli", alert.SLI),
+					zap.String("window", alert.LongWindow),
+					zap.Float64("burn_rate", alert.BurnRate),
+				)
+			}
+		case !s.Violating && r.violating:
+			logger.Info("Route is meeting its SLO again",
+				zap.String("method", r.method),
+				zap.String("route", r.route),
+			)
+		}
+		r.violating = s.Violating
+	}
+}
+
+// windowLabel formats a burn window, e.g. 5m or 6h
+func windowLabel(d time.Duration) string {
+	if d%time.Hour == 0 {
+		return strconv.Itoa(int(d/time.Hour)) + "h"
+	}
+	return strconv.Itoa(int(d/time.Minute)) + "m"
+}
@@ -0,0 +1,86 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"go.uber.org/zap"
+)
+
+// Message is a notification to a single recipient
+type Message struct {
+	To      string
+	Subject string // Ignored by SMS senders
+	Body    string
+}
+
+// Sender delivers messages over one channel (email, SMS)
+type Sender interface {
+	Send(ctx context.Context, msg Message) error
+}
+
+// LogSender writes messages to the application log instead of delivering them.
+// It is intended for development environments.
+type LogSender struct {
+	Channel string
+}
+
+// Send logs the message
+func (s *LogSender) Send(ctx context.Context, msg Message) error {
+	logger.Info("Notification (not delivered)",
+		zap.String("channel", s.Channel),
+		zap.String("to", msg.To),
+		zap.String("subject", msg.Subject),
+		zap.String("body", msg.Body),
+	)
+	return nil
+}
+
+// EmailOptions configures the email sender
+type EmailOptions struct {
+	Host     string
+	Port     int
+	Username string
+	Password string
+	From     string
+}
+
+// SMSOptions configures the SMS sender
+type SMSOptions struct {
+	AccountSID string
+	AuthToken  string
+	From       string
+	BaseURL    string // Overrides the Twilio API endpoint
+}
+
+// NewEmailSender returns the named email sender ("log" or "smtp")
+func NewEmailSender(provider string, opts EmailOptions) (Sender, error) {
+	switch strings.ToLower(provider) {
+	case "", "log":
+		return &LogSender{Channel: "email"}, nil
+	case "smtp":
+		if opts.Host == "" || opts.From == "" {
+			return nil, fmt.Errorf("notify: smtp sender requires a host and from address")
+		}
+		return NewSMTPSender(opts), nil
+	default:
+		return nil, fmt.Errorf("notify: unknown email provider %q", provider)
+	}
+}
+
+// NewSMSSender returns the named SMS sender ("log" or "twilio")
+func NewSMSSender(provider string, opts SMSOptions) (Sender, error) {
+	switch strings.ToLower(provider) {
+	case "", "log":
+		return &LogSender{Channel: "sms"}, nil
+	case "twilio":
+		if opts.AccountSID == "" || opts.AuthToken == "" || opts.From == "" {
+			return nil, fmt.Errorf("notify: twilio sender requires an account SID, auth token and from number")
+		}
+		return NewTwilioSender(opts), nil
+	default:
+		return nil, fmt.Errorf("notify: unknown SMS provider %q", provider)
+	}
+}
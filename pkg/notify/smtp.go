@@ -0,0 +1,69 @@
+package notify
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+)
+
+// SMTPSender delivers email through an SMTP relay
+type SMTPSender struct {
+	opts       EmailOptions
+	dependency *resilience.Dependency
+}
+
+// NewSMTPSender creates a new SMTP sender
+func NewSMTPSender(opts EmailOptions) *SMTPSender {
+	if opts.Port == 0 {
+		opts.Port = 587
+	}
+	return &SMTPSender{opts: opts, dependency: resilience.Get("smtp")}
+}
+
+// Send delivers a plain-text email
+func (s *SMTPSender) Send(ctx context.Context, msg Message) error {
+	// Reject header injection through recipient or subject
+	if strings.ContainsAny(msg.To, "\r\n") || strings.ContainsAny(msg.Subject, "\r\n") {
+		return fmt.Errorf("notify: invalid characters in email header")
+	}
+
+	var auth smtp.Auth
+	if s.opts.Username != "" {
+		auth = smtp.PlainAuth("", s.opts.Username, s.opts.Password, s.opts.Host)
+	}
+
+	body := "From: " + s.opts.From + "\r\n" +
+		"To: " + msg.To + "\r\n" +
+		"Subject: " + msg.Subject + "\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: text/plain; charset=UTF-8\r\n" +
+		"\r\n" + msg.Body + "\r\n"
+
+	// smtp.SendMail dials on its own, so the allowlist is checked up front
+	if err := egress.Check(s.opts.Host); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	addr := net.JoinHostPort(s.opts.Host, strconv.Itoa(s.opts.Port))
+	err := s.dependency.Do(ctx, func(ctx context.Context) error {
+		err := smtp.SendMail(addr, auth, s.opts.From, []string{msg.To}, []byte(body))
+		// A permanent rejection such as an unknown mailbox will not succeed on retry
+		var reply *textproto.Error
+		if errors.As(err, &reply) && reply.Code >= 500 {
+			return resilience.Permanent(err)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+	return nil
+}
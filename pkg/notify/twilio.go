@@ -0,0 +1,59 @@
+package notify
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+)
+
+// TwilioSender delivers SMS through the Twilio Messages API
+type TwilioSender struct {
+	opts   SMSOptions
+	client *http.Client
+}
+
+// NewTwilioSender creates a new Twilio SMS sender
+func NewTwilioSender(opts SMSOptions) *TwilioSender {
+	if opts.BaseURL == "" {
+		opts.BaseURL = "https://api.twilio.com"
+	}
+	return &TwilioSender{
+		opts:   opts,
+		client: egress.NewClient(resilience.Get("twilio"), 10*time.Second),
+	}
+}
+
+// Send delivers a text message
+func (s *TwilioSender) Send(ctx context.Context, msg Message) error {
+	form := url.Values{}
+	form.Set("To", msg.To)
+	form.Set("From", s.opts.From)
+	form.Set("Body", msg.Body)
+
+	endpoint := strings.TrimRight(s.opts.BaseURL, "/") + "/2010-04-01/Accounts/" + url.PathEscape(s.opts.AccountSID) + "/Messages.json"
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, strings.NewReader(form.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.SetBasicAuth(s.opts.AccountSID, s.opts.AuthToken)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("twilio request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("twilio returned status %d: %s", resp.StatusCode, data)
+	}
+	return nil
+}
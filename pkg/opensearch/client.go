@@ -0,0 +1,210 @@
+package opensearch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+)
+
+// Client is a minimal OpenSearch/Elasticsearch REST client covering index management,
+// document writes and search
+type Client struct {
+	baseURL    string
+	username   string
+	password   string
+	httpClient *http.Client
+}
+
+// Error represents a non-success response from the search cluster
+type Error struct {
+	StatusCode int
+	Body       string
+}
+
+// Error returns the error message
+func (e *Error) Error() string {
+	return fmt.Sprintf("opensearch: status %d: %s", e.StatusCode, e.Body)
+}
+
+// SearchResponse represents the subset of a _search response used by the API
+type SearchResponse struct {
+	Took int64 `json:"took"`
+	Hits struct {
+		Total struct {
+			Value int64 `json:"value"`
+		} `json:"total"`
+		Hits []Hit `json:"hits"`
+	} `json:"hits"`
+}
+
+// Hit represents a single search hit
+type Hit struct {
+	Index  string          `json:"_index"`
+	ID     string          `json:"_id"`
+	Score  float64         `json:"_score"`
+	Source json.RawMessage `json:"_source"`
+}
+
+// NewClient creates a new client for the cluster at baseURL. Username and password are
+// optional and sent as basic auth when set.
+func NewClient(baseURL, username, password string) *Client {
+	return &Client{
+		baseURL:    strings.TrimRight(baseURL, "/"),
+		username:   username,
+		password:   password,
+		httpClient: egress.NewClient(resilience.Get("opensearch"), 10*time.Second),
+	}
+}
+
+// Ping checks that the cluster is reachable
+func (c *Client) Ping(ctx context.Context) error {
+	return c.do(ctx, http.MethodGet, "/", nil, nil)
+}
+
+// IndexExists reports whether the index exists
+func (c *Client) IndexExists(ctx context.Context, index string) (bool, error) {
+	err := c.do(ctx, http.MethodHead, "/"+url.PathEscape(index), nil, nil)
+	if err == nil {
+		return true, nil
+	}
+	if IsNotFound(err) {
+		return false, nil
+	}
+	return false, err
+}
+
+// CreateIndex creates an index with the given settings and mappings body
+func (c *Client) CreateIndex(ctx context.Context, index string, body interface{}) error {
+	return c.do(ctx, http.MethodPut, "/"+url.PathEscape(index), body, nil)
+}
+
+// GetMappingMeta returns the _meta section of an index mapping
+func (c *Client) GetMappingMeta(ctx context.Context, index string) (map[string]interface{}, error) {
+	var response map[string]struct {
+		Mappings struct {
+			Meta map[string]interface{} `json:"_meta"`
+		} `json:"mappings"`
+	}
+	if err := c.do(ctx, http.MethodGet, "/"+url.PathEscape(index)+"/_mapping", nil, &response); err != nil {
+		return nil, err
+	}
+	for _, mapping := range response {
+		return mapping.Mappings.Meta, nil
+	}
+	return nil, nil
+}
+
+// PutMapping adds fields to an existing index mapping. Only additive changes are accepted
+// by the cluster; changing the type of an existing field requires a new index.
+func (c *Client) PutMapping(ctx context.Context, index string, mapping interface{}) error {
+	return c.do(ctx, http.MethodPut, "/"+url.PathEscape(index)+"/_mapping", mapping, nil)
+}
+
+// IndexDocument creates or replaces a document. When version is positive the write uses
+// external versioning, so a write carrying an older version than the stored document is
+// ignored rather than overwriting newer data.
+func (c *Client) IndexDocument(ctx context.Context, index, id string, version int64, doc interface{}) error {
+	path := "/" + url.PathEscape(index) + "/_doc/" + url.PathEscape(id)
+	if version > 0 {
+		path += "?version_type=external&version=" + strconv.FormatInt(version, 10)
+	}
+
+	err := c.do(ctx, http.MethodPut, path, doc, nil)
+	if IsConflict(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteDocument removes a document. Deleting a missing document is not an error.
+func (c *Client) DeleteDocument(ctx context.Context, index, id string) error {
+	err := c.do(ctx, http.MethodDelete, "/"+url.PathEscape(index)+"/_doc/"+url.PathEscape(id), nil, nil)
+	if IsNotFound(err) {
+		return nil
+	}
+	return err
+}
+
+// DeleteByQuery removes all documents matching the query
+func (c *Client) DeleteByQuery(ctx context.Context, index string, query interface{}) error {
+	body := map[string]interface{}{"query": query}
+	return c.do(ctx, http.MethodPost, "/"+url.PathEscape(index)+"/_delete_by_query?conflicts=proceed", body, nil)
+}
+
+// Search runs a search request against one or more indices
+func (c *Client) Search(ctx context.Context, indices []string, body interface{}) (*SearchResponse, error) {
+	escaped := make([]string, len(indices))
+	for i, index := range indices {
+		escaped[i] = url.PathEscape(index)
+	}
+
+	var response SearchResponse
+	if err := c.do(ctx, http.MethodPost, "/"+strings.Join(escaped, ",")+"/_search", body, &response); err != nil {
+		return nil, err
+	}
+	return &response, nil
+}
+
+// IsNotFound reports whether err is a 404 response from the cluster
+func IsNotFound(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusNotFound
+}
+
+// IsConflict reports whether err is a 409 version conflict response from the cluster
+func IsConflict(err error) bool {
+	e, ok := err.(*Error)
+	return ok && e.StatusCode == http.StatusConflict
+}
+
+// do sends a JSON request and decodes the JSON response into out when out is not nil
+func (c *Client) do(ctx context.Context, method, path string, body interface{}, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		payload, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request body: %w", err)
+		}
+		reader = bytes.NewReader(payload)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to build request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.username != "" {
+		req.SetBasicAuth(c.username, c.password)
+	}
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("opensearch request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+		return &Error{StatusCode: resp.StatusCode, Body: string(data)}
+	}
+
+	if out != nil {
+		if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+			return fmt.Errorf("failed to decode response: %w", err)
+		}
+	}
+
+	return nil
+}
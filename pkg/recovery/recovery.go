@@ -0,0 +1,173 @@
+// Package recovery recovers from panics in HTTP handlers, logging them with their
+// stack trace and request context and optionally reporting them to an error tracker.
+package recovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var panicsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_panics_total",
+		Help: "Total number of panics recovered in HTTP handlers",
+	},
+	[]string{"method", "endpoint"},
+)
+
+// Panic describes a recovered panic
+type Panic struct {
+	ID        string // Reference returned to the client and sent to the error tracker
+	Value     string
+	Type      string
+	Frames    []Frame // Innermost call first
+	Method    string
+	Endpoint  string // Route template; paths carry resource IDs and are not reported
+	RequestID string
+	UserID    string
+	ClientIP  string
+	Timestamp time.Time
+}
+
+// Frame is a call in the stack of a panic
+type Frame struct {
+	Function string
+	File     string
+	Line     int
+}
+
+// Reporter sends a recovered panic to an error tracker. Reports must not block the
+// request.
+type Reporter interface {
+	Report(ctx context.Context, p Panic)
+}
+
+// Middleware recovers from panics in the handlers that follow it. The panic is logged
+// with its stack trace, counted and passed to the reporter when one is set, and the
+// client receives a 500 response carrying the panic's reference.
+func Middleware(reporter Reporter) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		defer func() {
+			value := recover()
+			if value == nil {
+				return
+			}
+			if value == http.ErrAbortHandler {
+				panic(value)
+			}
+
+			endpoint := c.FullPath()
+			if endpoint == "" {
+				endpoint = "unknown"
+			}
+			userID, _ := c.Get("user_id")
+			p := Panic{
+				ID:        strings.ReplaceAll(uuid.New().String(), "-", ""),
+				Value:     fmt.Sprint(value),
+				Type:      fmt.Sprintf("%T", value),
+				Frames:    frames(),
+				Method:    c.Request.Method,
+				Endpoint:  endpoint,
+				RequestID: apierror.RequestID(c),
+				ClientIP:  c.ClientIP(),
+				Timestamp: time.Now().UTC(),
+			}
+			p.UserID, _ = userID.(string)
+
+			panicsTotal.WithLabelValues(p.Method, p.Endpoint).Inc()
+
+			// A client that went away cannot be answered
+			if brokenPipe(value) {
+				logger.Warn("Connection closed by client while handling request",
+					zap.String("method", p.Method),
+					zap.String("endpoint", p.Endpoint),
+					zap.Any("error", value),
+				)
+				c.Error(fmt.Errorf("%v", value))
+				c.Abort()
+				return
+			}
+
+			logger.Error("Recovered from panic in HTTP handler",
+				zap.String("panic_id", p.ID),
+				zap.String("panic", p.Value),
+				zap.String("method", p.Method),
+				zap.String("endpoint", p.Endpoint),
+				zap.String("path", c.Request.URL.Path),
+				zap.String("request_id", p.RequestID),
+				zap.String("user_id", p.UserID),
+				zap.String("client_ip", p.ClientIP),
+				zap.ByteString("stack", debug.Stack()),
+			)
+			if reporter != nil {
+				reporter.Report(c.Request.Context(), p)
+			}
+
+			if c.Writer.Written() {
+				c.Abort()
+				return
+			}
+			apierror.Abort(c, http.StatusInternalServerError, apierror.Response{
+				Error:     "Internal server error",
+				Code:      "INTERNAL_ERROR",
+				Reference: p.ID,
+			})
+		}()
+
+		c.Next()
+	}
+}
+
+// frames returns the stack of the panicking goroutine below the runtime's panic
+// handling and this package
+func frames() []Frame {
+	pcs := make([]uintptr, 64)
+	n := runtime.Callers(3, pcs)
+	iter := runtime.CallersFrames(pcs[:n])
+
+	var result []Frame
+	for {
+		frame, more := iter.Next()
+		if !strings.HasPrefix(frame.Function, "runtime.") {
+			result = append(result, Frame{Function: frame.Function, File: frame.File, Line: frame.Line})
+		}
+		if !more {
+			break
+		}
+	}
+	return result
+}
+
+// brokenPipe reports whether the panic is a write to a connection the client closed
+func brokenPipe(value interface{}) bool {
+	err, ok := value.(error)
+	if !ok {
+		return false
+	}
+	var opErr *net.OpError
+	if !errors.As(err, &opErr) {
+		return false
+	}
+	var sysErr *os.SyscallError
+	if errors.As(opErr, &sysErr) {
+		return errors.Is(sysErr.Err, syscall.EPIPE) || errors.Is(sysErr.Err, syscall.ECONNRESET)
+	}
+	return false
+}
@@ -0,0 +1,198 @@
+package recovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+	"go.uber.org/zap"
+)
+
+// sentryQueueSize bounds the reports waiting to be sent; panics beyond it are only
+// logged
+const sentryQueueSize = 100
+
+// SentryOptions configures reporting to Sentry
+type SentryOptions struct {
+	DSN         string // https://<public key>@<host>/<project id>
+	Environment string
+	Release     string
+	ServerName  string
+}
+
+// SentryReporter reports panics to a Sentry project through its store endpoint.
+// Reports are queued and sent by Run.
+type SentryReporter struct {
+	opts     SentryOptions
+	endpoint string
+	key      string
+	client   *http.Client
+	queue    chan Panic
+}
+
+// NewSentryReporter creates a new Sentry reporter
+func NewSentryReporter(opts SentryOptions) (*SentryReporter, error) {
+	dsn, err := url.Parse(opts.DSN)
+	if err != nil || dsn.User == nil || dsn.User.Username() == "" || dsn.Host == "" {
+		return nil, fmt.Errorf("recovery: invalid Sentry DSN")
+	}
+	project := strings.Trim(dsn.Path, "/")
+	if project == "" {
+		return nil, fmt.Errorf("recovery: Sentry DSN has no project")
+	}
+
+	// Self-hosted Sentry may be served below a path: https://key@host/sentry/42
+	base := ""
+	if i := strings.LastIndex(project, "/"); i >= 0 {
+		base, project = "/"+project[:i], project[i+1:]
+	}
+
+	return &SentryReporter{
+		opts:     opts,
+		endpoint: dsn.Scheme + "://" + dsn.Host + base + "/api/" + url.PathEscape(project) + "/store/",
+		key:      dsn.User.Username(),
+		client:   egress.NewClient(resilience.Get("sentry"), 10*time.Second),
+		queue:    make(chan Panic, sentryQueueSize),
+	}, nil
+}
+
+// Report queues the panic for sending
+func (s *SentryReporter) Report(ctx context.Context, p Panic) {
+	select {
+	case s.queue <- p:
+	default:
+		logger.Warn("Sentry report queue full, dropping panic report", zap.String("panic_id", p.ID))
+	}
+}
+
+// Run sends the queued reports until the context is cancelled
+func (s *SentryReporter) Run(ctx context.Context) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case p := <-s.queue:
+			if err := s.send(ctx, p); err != nil {
+				logger.Warn("Failed to report panic to Sentry", zap.String("panic_id", p.ID), zap.Error(err))
+			}
+		}
+	}
+}
+
+// sentryEvent is the part of the Sentry event payload reports fill in
+type sentryEvent struct {
+	EventID     string            `json:"event_id"`
+	Timestamp   string            `json:"timestamp"`
+	Level       string            `json:"level"`
+	Platform    string            `json:"platform"`
+	Logger      string            `json:"logger"`
+	Environment string            `json:"environment,omitempty"`
+	Release     string            `json:"release,omitempty"`
+	ServerName  string            `json:"server_name,omitempty"`
+	Transaction string            `json:"transaction"`
+	Tags        map[string]string `json:"tags"`
+	User        *sentryUser       `json:"user,omitempty"`
+	Request     sentryRequest     `json:"request"`
+	Exception   struct {
+		Values []sentryException `json:"values"`
+	} `json:"exception"`
+}
+
+type sentryUser struct {
+	ID string `json:"id"`
+}
+
+type sentryRequest struct {
+	Method string `json:"method"`
+	URL    string `json:"url"`
+}
+
+type sentryException struct {
+	Type       string `json:"type"`
+	Value      string `json:"value"`
+	Stacktrace struct {
+		Frames []sentryFrame `json:"frames"`
+	} `json:"stacktrace"`
+	Mechanism struct {
+		Type    string `json:"type"`
+		Handled bool   `json:"handled"`
+	} `json:"mechanism"`
+}
+
+type sentryFrame struct {
+	Function string `json:"function"`
+	AbsPath  string `json:"abs_path"`
+	Lineno   int    `json:"lineno"`
+	InApp    bool   `json:"in_app"`
+}
+
+// send posts a report to Sentry
+func (s *SentryReporter) send(ctx context.Context, p Panic) error {
+	event := sentryEvent{
+		EventID:     p.ID,
+		Timestamp:   p.Timestamp.Format(time.RFC3339),
+		Level:       "fatal",
+		Platform:    "go",
+		Logger:      "http",
+		Environment: s.opts.Environment,
+		Release:     s.opts.Release,
+		ServerName:  s.opts.ServerName,
+		Transaction: p.Method + " " + p.Endpoint,
+		Tags:        map[string]string{"method": p.Method, "endpoint": p.Endpoint},
+		Request:     sentryRequest{Method: p.Method, URL: p.Endpoint},
+	}
+	if p.UserID != "" {
+		event.User = &sentryUser{ID: p.UserID}
+	}
+	if p.RequestID != "" {
+		event.Tags["request_id"] = p.RequestID
+	}
+
+	exception := sentryException{Type: p.Type, Value: p.Value}
+	exception.Mechanism.Type = "gin.recovery"
+	// Sentry lists frames outermost call first
+	for i := len(p.Frames) - 1; i >= 0; i-- {
+		frame := p.Frames[i]
+		exception.Stacktrace.Frames = append(exception.Stacktrace.Frames, sentryFrame{
+			Function: frame.Function,
+			AbsPath:  frame.File,
+			Lineno:   frame.Line,
+			InApp:    strings.HasPrefix(frame.Function, "github.com/hillmatthew2000/HealthHub/"),
+		})
+	}
+	event.Exception.Values = []sentryException{exception}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, s.endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Sentry-Auth", "Sentry sentry_version=7, sentry_client=healthhub/1.0, sentry_key="+s.key)
+	// Sentry drops events it has already stored, so the report may be retried
+	req.Header.Set("Idempotency-Key", p.ID)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("sentry request failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("sentry returned status %d: %s", resp.StatusCode, data)
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package resilience
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// StatusError reports a response status that counts as a dependency failure
+type StatusError struct {
+	StatusCode int
+	RetryAfter time.Duration // From the Retry-After header, when sent
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("resilience: dependency returned status %d", e.StatusCode)
+}
+
+// transport guards the requests of an HTTP client
+type transport struct {
+	dependency *Dependency
+	base       http.RoundTripper
+}
+
+// NewTransport wraps base, or http.DefaultTransport when nil, so requests are guarded
+// by the dependency. Responses with status 429 or 5xx count as failures.
+// Only requests that are safe to repeat are retried: idempotent methods and
+// requests carrying an Idempotency-Key header, and only when their body can be
+// replayed. The final response is returned to the caller as usual.
+func NewTransport(dependency *Dependency, base http.RoundTripper) http.RoundTripper {
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	return &transport{dependency: dependency, base: base}
+}
+
+// RoundTrip sends the request under the dependency's policy
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	policy := t.dependency.policy
+	attempts := policy.MaxAttempts
+	if !replayable(req) {
+		attempts = 1
+	}
+
+	var last *http.Response
+	attempt := 0
+	err := t.dependency.run(req.Context(), attempts, func(ctx context.Context) error {
+		attempt++
+		if last != nil {
+			// A failed response from an earlier attempt is discarded before retrying
+			io.Copy(io.Discard, io.LimitReader(last.Body, 4096))
+			last.Body.Close()
+			last = nil
+		}
+
+		cancel := context.CancelFunc(func() {})
+		if policy.Timeout > 0 {
+			ctx, cancel = context.WithTimeout(ctx, policy.Timeout)
+		}
+		attemptReq := req.WithContext(ctx)
+		if attempt > 1 && req.GetBody != nil {
+			body, err := req.GetBody()
+			if err != nil {
+				cancel()
+				return Permanent(err)
+			}
+			attemptReq.Body = body
+		}
+
+		resp, err := t.base.RoundTrip(attemptReq)
+		if err != nil {
+			cancel()
+			return err
+		}
+		// The attempt's deadline must cover reading the body
+		resp.Body = &cancelBody{ReadCloser: resp.Body, cancel: cancel}
+		last = resp
+
+		if resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= 500 {
+			return &StatusError{StatusCode: resp.StatusCode, RetryAfter: retryAfter(resp.Header.Get("Retry-After"))}
+		}
+		return nil
+	})
+
+	if last != nil {
+		if _, ok := err.(*StatusError); ok || err == nil {
+			return last, nil
+		}
+		last.Body.Close()
+	}
+	return nil, err
+}
+
+// replayable reports whether a request may safely be sent more than once
+func replayable(req *http.Request) bool {
+	if req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		return false
+	}
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodPut, http.MethodDelete:
+		return true
+	}
+	return req.Header.Get("Idempotency-Key") != ""
+}
+
+// retryAfter parses a Retry-After header given in seconds
+func retryAfter(value string) time.Duration {
+	seconds, err := strconv.Atoi(value)
+	if err != nil || seconds < 0 {
+		return 0
+	}
+	return time.Duration(seconds) * time.Second
+}
+
+// cancelBody releases an attempt's context once its response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
@@ -0,0 +1,326 @@
+// Package resilience guards calls to external dependencies with timeouts, retries
+// with jittered backoff and a circuit breaker per dependency, and records
+// Prometheus metrics for every call.
+package resilience
+
+import (
+	"context"
+	"errors"
+	"math/rand"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+// ErrCircuitOpen is returned without calling a dependency whose circuit is open
+var ErrCircuitOpen = errors.New("resilience: circuit open")
+
+// Policy configures how calls to a dependency are guarded
+type Policy struct {
+	Timeout          time.Duration // Per attempt; zero leaves the caller's deadline
+	MaxAttempts      int           // Including the first call
+	BaseDelay        time.Duration // Backoff before the second attempt, doubled after each one
+	MaxDelay         time.Duration // Upper bound of a single backoff
+	FailureThreshold int           // Consecutive failures that open the circuit
+	OpenTimeout      time.Duration // How long the circuit stays open before a trial call
+}
+
+// DefaultPolicy guards dependencies that have not been registered with their own policy
+var DefaultPolicy = Policy{
+	Timeout:          10 * time.Second,
+	MaxAttempts:      3,
+	BaseDelay:        200 * time.Millisecond,
+	MaxDelay:         5 * time.Second,
+	FailureThreshold: 5,
+	OpenTimeout:      30 * time.Second,
+}
+
+// Circuit states, also reported by the circuit state gauge
+const (
+	StateClosed   = "closed"
+	StateHalfOpen = "half-open"
+	StateOpen     = "open"
+)
+
+var (
+	callsTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "external_calls_total",
+			Help: "Total number of calls to external dependencies by outcome",
+		},
+		[]string{"dependency", "outcome"},
+	)
+
+	callDuration = promauto.NewHistogramVec(
+		prometheus.HistogramOpts{
+			Name:    "external_call_duration_seconds",
+			Help:    "Duration of single attempts to call external dependencies in seconds",
+			Buckets: []float64{0.01, 0.05, 0.1, 0.25, 0.5, 1.0, 2.5, 5.0, 10.0},
+		},
+		[]string{"dependency"},
+	)
+
+	retriesTotal = promauto.NewCounterVec(
+		prometheus.CounterOpts{
+			Name: "external_retries_total",
+			Help: "Total number of retried calls to external dependencies",
+		},
+		[]string{"dependency"},
+	)
+
+	circuitState = promauto.NewGaugeVec(
+		prometheus.GaugeOpts{
+			Name: "external_circuit_state",
+			Help: "Circuit breaker state of external dependencies (0 closed, 1 half-open, 2 open)",
+		},
+		[]string{"dependency"},
+	)
+)
+
+// Dependency guards the calls to one external system
+type Dependency struct {
+	name   string
+	policy Policy
+
+	mu       sync.Mutex
+	state    string
+	failures int
+	openedAt time.Time
+	probing  bool
+}
+
+var (
+	registryMu   sync.Mutex
+	dependencies = map[string]*Dependency{}
+)
+
+// Register sets the policy of a dependency. It must be called before the dependency
+// is first used; clients created earlier keep the previous policy.
+func Register(name string, policy Policy) *Dependency {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	d := newDependency(name, policy)
+	dependencies[name] = d
+	return d
+}
+
+// Get returns the named dependency, registering it with DefaultPolicy on first use
+func Get(name string) *Dependency {
+	return Lookup(name, DefaultPolicy)
+}
+
+// Lookup returns the named dependency, registering it with policy on first use.
+// Packages use it to give a dependency a suitable default that callers may still
+// override with Register beforehand.
+func Lookup(name string, policy Policy) *Dependency {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	if d, ok := dependencies[name]; ok {
+		return d
+	}
+	d := newDependency(name, policy)
+	dependencies[name] = d
+	return d
+}
+
+// States returns the circuit state of every dependency used so far
+func States() map[string]string {
+	registryMu.Lock()
+	names := make([]string, 0, len(dependencies))
+	for name := range dependencies {
+		names = append(names, name)
+	}
+	registryMu.Unlock()
+	sort.Strings(names)
+
+	states := make(map[string]string, len(names))
+	for _, name := range names {
+		states[name] = Get(name).State()
+	}
+	return states
+}
+
+func newDependency(name string, policy Policy) *Dependency {
+	if policy.MaxAttempts < 1 {
+		policy.MaxAttempts = 1
+	}
+	d := &Dependency{name: name, policy: policy, state: StateClosed}
+	circuitState.WithLabelValues(name).Set(0)
+	return d
+}
+
+// Name returns the dependency name used in metrics
+func (d *Dependency) Name() string {
+	return d.name
+}
+
+// State returns the current circuit state
+func (d *Dependency) State() string {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	if d.state == StateOpen && time.Since(d.openedAt) >= d.policy.OpenTimeout {
+		return StateHalfOpen
+	}
+	return d.state
+}
+
+// Do calls fn under the dependency's policy. Each attempt gets its own timeout;
+// errors wrapped with Permanent are returned without retrying.
+func (d *Dependency) Do(ctx context.Context, fn func(ctx context.Context) error) error {
+	return d.run(ctx, d.policy.MaxAttempts, func(ctx context.Context) error {
+		if d.policy.Timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, d.policy.Timeout)
+			defer cancel()
+		}
+		return fn(ctx)
+	})
+}
+
+// run performs up to attempts calls of fn, backing off between them
+func (d *Dependency) run(ctx context.Context, attempts int, fn func(ctx context.Context) error) error {
+	for attempt := 1; ; attempt++ {
+		if err := d.allow(); err != nil {
+			callsTotal.WithLabelValues(d.name, "rejected").Inc()
+			return err
+		}
+
+		start := time.Now()
+		err := fn(ctx)
+		callDuration.WithLabelValues(d.name).Observe(time.Since(start).Seconds())
+
+		var permanent *permanentError
+		switch {
+		case err == nil:
+			callsTotal.WithLabelValues(d.name, "success").Inc()
+			d.record(true)
+			return nil
+		case errors.As(err, &permanent):
+			// The dependency answered; the request itself was refused
+			callsTotal.WithLabelValues(d.name, "rejected_by_dependency").Inc()
+			d.record(true)
+			return permanent.err
+		case ctx.Err() != nil:
+			// The caller gave up, which says nothing about the dependency's health
+			callsTotal.WithLabelValues(d.name, "cancelled").Inc()
+			d.release()
+			return err
+		}
+
+		callsTotal.WithLabelValues(d.name, "failure").Inc()
+		d.record(false)
+		if attempt >= attempts {
+			return err
+		}
+
+		retriesTotal.WithLabelValues(d.name).Inc()
+		timer := time.NewTimer(d.backoff(attempt, err))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return err
+		case <-timer.C:
+		}
+	}
+}
+
+// backoff returns a full-jitter delay for the given attempt, or the delay the
+// dependency asked for when it is longer
+func (d *Dependency) backoff(attempt int, err error) time.Duration {
+	ceiling := d.policy.BaseDelay << (attempt - 1)
+	if ceiling <= 0 || ceiling > d.policy.MaxDelay {
+		ceiling = d.policy.MaxDelay
+	}
+
+	var delay time.Duration
+	if ceiling > 0 {
+		delay = time.Duration(rand.Int63n(int64(ceiling) + 1))
+	}
+
+	var status *StatusError
+	if errors.As(err, &status) && status.RetryAfter > delay {
+		delay = status.RetryAfter
+		if d.policy.MaxDelay > 0 && delay > d.policy.MaxDelay {
+			delay = d.policy.MaxDelay
+		}
+	}
+	return delay
+}
+
+// allow reports whether a call may be made. After the open timeout a single trial
+// call is let through; its outcome closes or reopens the circuit.
+func (d *Dependency) allow() error {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	switch d.state {
+	case StateOpen:
+		if time.Since(d.openedAt) < d.policy.OpenTimeout {
+			return ErrCircuitOpen
+		}
+		d.setState(StateHalfOpen)
+		d.probing = true
+		return nil
+	case StateHalfOpen:
+		if d.probing {
+			return ErrCircuitOpen
+		}
+		d.probing = true
+	}
+	return nil
+}
+
+// record updates the circuit with the outcome of a call
+func (d *Dependency) record(healthy bool) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.probing = false
+	if healthy {
+		d.failures = 0
+		d.setState(StateClosed)
+		return
+	}
+
+	d.failures++
+	if d.state == StateHalfOpen || (d.policy.FailureThreshold > 0 && d.failures >= d.policy.FailureThreshold) {
+		d.openedAt = time.Now()
+		d.setState(StateOpen)
+	}
+}
+
+// release gives up a trial call whose outcome is unknown
+func (d *Dependency) release() {
+	d.mu.Lock()
+	d.probing = false
+	d.mu.Unlock()
+}
+
+func (d *Dependency) setState(state string) {
+	d.state = state
+	value := map[string]float64{StateClosed: 0, StateHalfOpen: 1, StateOpen: 2}[state]
+	circuitState.WithLabelValues(d.name).Set(value)
+}
+
+// permanentError marks an error that retrying cannot fix
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string { return e.err.Error() }
+func (e *permanentError) Unwrap() error { return e.err }
+
+// Permanent marks err as not worth retrying, e.g. a rejected request. The
+// dependency is still considered healthy.
+func Permanent(err error) error {
+	if err == nil {
+		return nil
+	}
+	return &permanentError{err: err}
+}
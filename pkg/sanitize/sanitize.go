@@ -0,0 +1,278 @@
+// Package sanitize cleans free-text fields before they are stored, so notes and display
+// strings that web UIs render later hold no control characters and, where configured,
+// no markup. Fields opt in with a sanitize struct tag naming their field class.
+package sanitize
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strings"
+	"sync"
+	"unicode"
+
+	"gorm.io/gorm"
+)
+
+// Field classes of free-text fields, as given in their sanitize tag
+const (
+	ClassNote       = "note"       // Notes, comments and clinical note bodies
+	ClassAnnotation = "annotation" // Text of annotations
+	ClassDisplay    = "display"    // Display strings of codings and references
+)
+
+// HTML policies of a field class
+const (
+	HTMLKeep  = "keep"  // Markup is stored as written; UIs must escape it
+	HTMLStrip = "strip" // Tags, comments, scripts and styles are removed
+)
+
+var classes = map[string]bool{ClassNote: true, ClassAnnotation: true, ClassDisplay: true}
+
+var (
+	// blockPattern matches scripts, styles and comments, which are removed with their
+	// content
+	blockPattern = regexp.MustCompile(`(?is)<script\b.*?</script\s*>|<style\b.*?</style\s*>|<!--.*?-->`)
+	// tagPattern matches start and end tags and declarations; a < not followed by a
+	// letter, as in "< 5 mg/dL", is not a tag
+	tagPattern = regexp.MustCompile(`</?[A-Za-z][^<>]*>|<![A-Za-z][^<>]*>`)
+)
+
+// Policy is the sanitation applied to the fields of a class. Control characters are
+// always removed; line breaks and tabs are kept in multiline classes only.
+type Policy struct {
+	HTML      string `json:"html"`
+	Multiline bool   `json:"multiline"`
+}
+
+// Sanitizer applies the policies of field classes to tagged fields on write
+type Sanitizer struct {
+	policies map[string]Policy
+
+	mu    sync.RWMutex
+	types map[reflect.Type]bool // Whether a type holds tagged fields
+}
+
+// New creates a sanitizer from class policies of the form class=html_policy, e.g.
+// display=strip. The class * sets the HTML policy of classes without an entry, which
+// otherwise keep markup. Notes and annotations are multiline; display strings are not.
+func New(entries []string) (*Sanitizer, error) {
+	defaults := HTMLKeep
+	configured := make(map[string]string)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		class, html, ok := strings.Cut(entry, "=")
+		class, html = strings.TrimSpace(class), strings.TrimSpace(html)
+		if !ok || (class != "*" && !classes[class]) {
+			return nil, fmt.Errorf("sanitize: invalid policy %q, expected class=html_policy with class one of note, annotation, display or *", entry)
+		}
+		if html != HTMLKeep && html != HTMLStrip {
+			return nil, fmt.Errorf("sanitize: invalid HTML policy in %q, expected keep or strip", entry)
+		}
+		if _, exists := configured[class]; exists {
+			return nil, fmt.Errorf("sanitize: duplicate policy for %s", class)
+		}
+		configured[class] = html
+		if class == "*" {
+			defaults = html
+		}
+	}
+
+	s := &Sanitizer{policies: make(map[string]Policy, len(classes)), types: make(map[reflect.Type]bool)}
+	for class := range classes {
+		html, ok := configured[class]
+		if !ok {
+			html = defaults
+		}
+		s.policies[class] = Policy{HTML: html, Multiline: class != ClassDisplay}
+	}
+	return s, nil
+}
+
+// Policies returns the policy of each field class
+func (s *Sanitizer) Policies() map[string]Policy {
+	policies := make(map[string]Policy, len(s.policies))
+	for class, policy := range s.policies {
+		policies[class] = policy
+	}
+	return policies
+}
+
+// Text sanitizes a value of a field class
+func (s *Sanitizer) Text(class, value string) string {
+	policy, ok := s.policies[class]
+	if !ok {
+		return value
+	}
+	value = strings.ToValidUTF8(value, "\ufffd")
+	if policy.HTML == HTMLStrip {
+		value = tagPattern.ReplaceAllString(blockPattern.ReplaceAllString(value, ""), "")
+	}
+	value = strings.ReplaceAll(value, "\r\n", "\n")
+	return strings.Map(func(r rune) rune {
+		switch {
+		case r == '\n' || r == '\t':
+			if policy.Multiline {
+				return r
+			}
+			return ' '
+		case unicode.IsControl(r), isBidiControl(r):
+			return -1
+		}
+		return r
+	}, value)
+}
+
+// isBidiControl reports whether r is a bidirectional embedding, override or isolate,
+// which can make rendered text read differently from what was stored
+func isBidiControl(r rune) bool {
+	return (r >= '\u202a' && r <= '\u202e') || (r >= '\u2066' && r <= '\u2069')
+}
+
+// Value sanitizes the tagged fields of v, which must be a pointer, in place, including
+// those of nested structs, slices and maps
+func (s *Sanitizer) Value(v interface{}) {
+	s.walk(reflect.ValueOf(v))
+}
+
+func (s *Sanitizer) walk(v reflect.Value) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Interface:
+		if !v.IsNil() {
+			s.walk(v.Elem())
+		}
+	case reflect.Slice, reflect.Array:
+		if !s.holdsTagged(v.Type().Elem()) {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			s.walk(v.Index(i))
+		}
+	case reflect.Map:
+		if !s.holdsTagged(v.Type().Elem()) {
+			return
+		}
+		// Map values are not addressable, so they are sanitized in a copy and stored back
+		iter := v.MapRange()
+		for iter.Next() {
+			value := reflect.New(iter.Value().Type()).Elem()
+			value.Set(iter.Value())
+			s.walk(value)
+			v.SetMapIndex(iter.Key(), value)
+		}
+	case reflect.Struct:
+		if !s.holdsTagged(v.Type()) {
+			return
+		}
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			field := t.Field(i)
+			if !field.IsExported() {
+				continue
+			}
+			value := v.Field(i)
+			if class := field.Tag.Get("sanitize"); class != "" && value.Kind() == reflect.String {
+				if value.CanSet() {
+					value.SetString(s.Text(class, value.String()))
+				}
+				continue
+			}
+			s.walk(value)
+		}
+	}
+}
+
+// holdsTagged reports whether values of t can hold tagged fields, caching the answer
+func (s *Sanitizer) holdsTagged(t reflect.Type) bool {
+	for t.Kind() == reflect.Ptr || t.Kind() == reflect.Slice || t.Kind() == reflect.Array || t.Kind() == reflect.Map {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return false
+	}
+
+	s.mu.RLock()
+	holds, ok := s.types[t]
+	s.mu.RUnlock()
+	if ok {
+		return holds
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.inspect(t)
+}
+
+// inspect works out whether t holds tagged fields; s.mu must be held. A struct is
+// assumed to hold none while its fields are inspected, which ends recursion through
+// self-referencing types; only structs are cached, so a slice of one is not taken to be
+// empty while the struct is still being inspected.
+func (s *Sanitizer) inspect(t reflect.Type) bool {
+	switch t.Kind() {
+	case reflect.Ptr, reflect.Slice, reflect.Array, reflect.Map:
+		return s.inspect(t.Elem())
+	case reflect.Struct:
+	default:
+		return false
+	}
+	if holds, ok := s.types[t]; ok {
+		return holds
+	}
+	s.types[t] = false
+
+	var holds bool
+	for i := 0; i < t.NumField() && !holds; i++ {
+		field := t.Field(i)
+		if !field.IsExported() {
+			continue
+		}
+		holds = field.Tag.Get("sanitize") != "" || s.inspect(field.Type)
+	}
+	s.types[t] = holds
+	return holds
+}
+
+// Name implements gorm.Plugin
+func (s *Sanitizer) Name() string {
+	return "sanitize"
+}
+
+// Initialize implements gorm.Plugin, sanitizing records before they are created or
+// updated
+func (s *Sanitizer) Initialize(db *gorm.DB) error {
+	if err := db.Callback().Create().Before("gorm:create").Register("sanitize:create", s.sanitizeStatement); err != nil {
+		return err
+	}
+	return db.Callback().Update().Before("gorm:update").Register("sanitize:update", s.sanitizeStatement)
+}
+
+// sanitizeStatement sanitizes the record a statement writes. Updates given a struct
+// value write from a sanitized copy of it, since the value itself cannot be changed.
+func (s *Sanitizer) sanitizeStatement(db *gorm.DB) {
+	stmt := db.Statement
+	if stmt.Schema == nil {
+		return
+	}
+	if stmt.ReflectValue.CanAddr() {
+		s.walk(stmt.ReflectValue)
+	}
+	if stmt.Dest == nil {
+		return
+	}
+
+	dest := reflect.ValueOf(stmt.Dest)
+	switch {
+	case dest.Kind() == reflect.Ptr:
+		if model := reflect.ValueOf(stmt.Model); model.Kind() != reflect.Ptr || model.Pointer() != dest.Pointer() {
+			s.walk(dest)
+		}
+	case dest.Kind() == reflect.Struct && s.holdsTagged(dest.Type()):
+		copied := reflect.New(dest.Type())
+		copied.Elem().Set(dest)
+		s.walk(copied)
+		stmt.Dest = copied.Interface()
+	}
+}
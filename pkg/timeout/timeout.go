@@ -0,0 +1,261 @@
+// Package timeout bounds how long a request may take. Once its route's timeout
+// passes, the request's context is cancelled, so database queries and calls made with
+// it stop, and the client is answered with 504 unless the handler already started its
+// response.
+package timeout
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/hillmatthew2000/HealthHub/pkg/apierror"
+	"github.com/hillmatthew2000/HealthHub/pkg/logger"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"go.uber.org/zap"
+)
+
+var timeoutsTotal = promauto.NewCounterVec(
+	prometheus.CounterOpts{
+		Name: "http_request_timeouts_total",
+		Help: "Total number of requests that ran past their route's timeout",
+	},
+	[]string{"endpoint", "answered"},
+)
+
+// Route is the timeout of the routes starting with a prefix
+type Route struct {
+	Prefix  string
+	Timeout time.Duration // Zero disables the timeout, e.g. for streamed downloads
+}
+
+// ParseRoutes parses route timeouts of the form prefix=timeout_ms, e.g.
+// /api/v1=30000 for 30 seconds; a timeout of 0 disables it for the prefix
+func ParseRoutes(entries []string) ([]Route, error) {
+	var routes []Route
+	seen := make(map[string]bool)
+	for _, entry := range entries {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		prefix, value, ok := strings.Cut(entry, "=")
+		prefix = strings.TrimSpace(prefix)
+		if !ok || !strings.HasPrefix(prefix, "/") {
+			return nil, fmt.Errorf("timeout: invalid route timeout %q, expected prefix=timeout_ms", entry)
+		}
+		ms, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil || ms < 0 {
+			return nil, fmt.Errorf("timeout: invalid timeout in %q", entry)
+		}
+		if seen[prefix] {
+			return nil, fmt.Errorf("timeout: duplicate route timeout for %s", prefix)
+		}
+		seen[prefix] = true
+		routes = append(routes, Route{Prefix: prefix, Timeout: time.Duration(ms) * time.Millisecond})
+	}
+	return routes, nil
+}
+
+// Middleware cancels the context of a request once the timeout of the longest route
+// prefix it matches passes. A handler that has not started its response by then is
+// answered with 504 GATEWAY_TIMEOUT at once, and what it writes afterwards is
+// dropped; the middleware still waits for it to return, since the context of the
+// request is only released then. Requests matching no prefix have no timeout.
+func Middleware(routes []Route) gin.HandlerFunc {
+	sorted := append([]Route(nil), routes...)
+	sort.SliceStable(sorted, func(i, j int) bool { return len(sorted[i].Prefix) > len(sorted[j].Prefix) })
+
+	return func(c *gin.Context) {
+		limit := routeTimeout(sorted, c.Request.URL.Path)
+		if limit <= 0 {
+			c.Next()
+			return
+		}
+
+		ctx, cancel := context.WithTimeout(c.Request.Context(), limit)
+		defer cancel()
+		original, request := c.Writer, c.Request
+		writer := &timeoutWriter{ResponseWriter: original, header: original.Header().Clone()}
+		c.Writer = writer
+		c.Request = request.WithContext(ctx)
+
+		// The callback can run after the handler returned and gin reused the context,
+		// so it only reads the context through the writer, which knows whether the
+		// handler is still running
+		endpoint := c.FullPath()
+		timer := time.AfterFunc(limit, func() {
+			if answered := writer.timeOut(c); answered || !writer.finished() {
+				timeoutsTotal.WithLabelValues(endpoint, strconv.FormatBool(answered)).Inc()
+				logger.Warn("Request timed out",
+					zap.String("method", request.Method),
+					zap.String("endpoint", endpoint),
+					zap.Duration("timeout", limit),
+					zap.Bool("answered", answered),
+				)
+			}
+			cancel()
+		})
+		c.Next()
+		timer.Stop()
+		writer.finish()
+
+		c.Writer, c.Request = original, request
+	}
+}
+
+// routeTimeout returns the timeout of the longest prefix matching a path
+func routeTimeout(routes []Route, path string) time.Duration {
+	for _, r := range routes {
+		if strings.HasPrefix(path, r.Prefix) {
+			return r.Timeout
+		}
+	}
+	return 0
+}
+
+// timeoutWriter passes the response of a handler through until the request times
+// out. Handlers set headers on a copy of the response headers, applied when they
+// start the response, so answering a timed out request does not race with them.
+type timeoutWriter struct {
+	gin.ResponseWriter
+	header http.Header
+
+	mu       sync.Mutex
+	started  bool // The handler started its response
+	done     bool // The handler returned
+	timedOut bool // The request was answered with 504
+}
+
+func (w *timeoutWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *timeoutWriter) WriteHeader(code int) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.timedOut {
+		w.ResponseWriter.WriteHeader(code)
+	}
+}
+
+func (w *timeoutWriter) WriteHeaderNow() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.start() {
+		w.ResponseWriter.WriteHeaderNow()
+	}
+}
+
+func (w *timeoutWriter) Write(data []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.start() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *timeoutWriter) WriteString(s string) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if !w.start() {
+		return 0, http.ErrHandlerTimeout
+	}
+	return w.ResponseWriter.WriteString(s)
+}
+
+func (w *timeoutWriter) Flush() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.start() {
+		w.ResponseWriter.Flush()
+	}
+}
+
+// Written reports the response of a timed out request as written, so handlers
+// checking it do not try to add another
+func (w *timeoutWriter) Written() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.timedOut || w.ResponseWriter.Written()
+}
+
+// start applies the handler's headers when it starts its response and reports
+// whether it may still write it. The lock is held.
+func (w *timeoutWriter) start() bool {
+	if w.timedOut {
+		return false
+	}
+	if !w.started {
+		w.started = true
+		w.applyHeader()
+	}
+	return true
+}
+
+// applyHeader replaces the response headers with the handler's
+func (w *timeoutWriter) applyHeader() {
+	header := w.ResponseWriter.Header()
+	for key := range header {
+		if _, ok := w.header[key]; !ok {
+			delete(header, key)
+		}
+	}
+	for key, values := range w.header {
+		header[key] = values
+	}
+}
+
+// finish applies the headers of a handler that returned without writing a body, such
+// as one answering 204, which are written once the handler chain completes
+func (w *timeoutWriter) finish() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.done = true
+	if !w.timedOut && !w.started {
+		w.applyHeader()
+	}
+}
+
+// finished reports whether the handler returned
+func (w *timeoutWriter) finished() bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.done
+}
+
+// timeOut answers a request whose handler has not started its response with 504 and
+// reports whether it did. The context is only read while the handler is running.
+func (w *timeoutWriter) timeOut(c *gin.Context) bool {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.started || w.done {
+		return false
+	}
+	w.timedOut = true
+
+	resp := apierror.Response{
+		Error:   "Request timed out",
+		Message: "the request did not complete in time; retry it or narrow it down",
+		Code:    "GATEWAY_TIMEOUT",
+	}
+	apierror.Stamp(c, &resp)
+	body, err := json.Marshal(resp)
+	if err != nil {
+		return true
+	}
+	w.ResponseWriter.Header().Set("Content-Type", "application/json; charset=utf-8")
+	w.ResponseWriter.WriteHeader(http.StatusGatewayTimeout)
+	_, _ = w.ResponseWriter.Write(body)
+	w.ResponseWriter.Flush()
+	return true
+}
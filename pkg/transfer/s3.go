@@ -0,0 +1,286 @@
+package transfer
+
+import (
+	"crypto/hmac"
+	"crypto/md5"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+)
+
+// S3 uploads objects to an S3 bucket, or an S3-compatible store when an endpoint is
+// configured, using Signature Version 4. Objects are written with server-side encryption.
+type S3 struct {
+	bucket      string
+	region      string
+	endpoint    *url.URL
+	pathStyle   bool
+	prefix      string
+	accessKeyID string
+	secretKey   string
+	client      *http.Client
+	now         func() time.Time
+}
+
+// NewS3 creates an S3 destination. A custom endpoint is addressed path-style
+// (https://endpoint/bucket/key), AWS itself virtual-hosted style.
+func NewS3(opts Options) (*S3, error) {
+	if opts.Bucket == "" || opts.Region == "" {
+		return nil, errors.New("s3 bucket and region are required")
+	}
+	if opts.AccessKeyID == "" || opts.Secret == "" {
+		return nil, errors.New("s3 access key ID and secret access key are required")
+	}
+
+	s := &S3{
+		bucket:      opts.Bucket,
+		region:      opts.Region,
+		prefix:      strings.Trim(opts.Prefix, "/"),
+		accessKeyID: opts.AccessKeyID,
+		secretKey:   opts.Secret,
+		client:      egress.NewClient(resilience.Lookup("s3", transferPolicy), 10*time.Minute),
+		now:         time.Now,
+	}
+
+	if opts.Endpoint != "" {
+		endpoint, err := url.Parse(opts.Endpoint)
+		if err != nil || endpoint.Host == "" {
+			return nil, fmt.Errorf("invalid s3 endpoint %q", opts.Endpoint)
+		}
+		s.endpoint = endpoint
+		s.pathStyle = true
+	} else {
+		s.endpoint = &url.URL{Scheme: "https", Host: fmt.Sprintf("%s.s3.%s.amazonaws.com", opts.Bucket, opts.Region)}
+	}
+
+	return s, nil
+}
+
+// Put uploads r as an object. The body is spooled to a temporary file first, since a
+// signed PUT needs the payload hash and length before the request is sent.
+func (s *S3) Put(name string, r io.Reader) (int64, error) {
+	return s.put(name, r, nil)
+}
+
+// PutLocked uploads r as an object under S3 Object Lock, so it cannot be changed or
+// deleted before retainUntil. Mode is GOVERNANCE or COMPLIANCE; the bucket must have
+// Object Lock enabled.
+func (s *S3) PutLocked(name string, r io.Reader, mode string, retainUntil time.Time) (int64, error) {
+	return s.put(name, r, map[string]string{
+		"X-Amz-Object-Lock-Mode":              mode,
+		"X-Amz-Object-Lock-Retain-Until-Date": retainUntil.UTC().Format(time.RFC3339),
+	})
+}
+
+func (s *S3) put(name string, r io.Reader, headers map[string]string) (int64, error) {
+	spool, err := os.CreateTemp("", "healthhub-s3-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hash := sha256.New()
+	// Object Lock uploads require an integrity header
+	sum := md5.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash, sum), r)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := spool.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+
+	key := s.key(name)
+	req, err := http.NewRequest(http.MethodPut, s.objectURL(key), io.NopCloser(spool))
+	if err != nil {
+		return 0, err
+	}
+	// Send the path exactly as it was signed
+	req.URL.RawPath = awsEscapePath(req.URL.Path)
+	req.ContentLength = size
+	req.Header.Set("Content-Type", "application/octet-stream")
+	req.Header.Set("Content-Md5", base64.StdEncoding.EncodeToString(sum.Sum(nil)))
+	req.Header.Set("X-Amz-Server-Side-Encryption", "AES256")
+	for name, value := range headers {
+		req.Header.Set(name, value)
+	}
+	s.sign(req, hex.EncodeToString(hash.Sum(nil)))
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("s3 upload failed: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return 0, fmt.Errorf("s3 upload of %s failed: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+
+	return size, nil
+}
+
+// Get downloads an object. The caller must close the returned reader.
+func (s *S3) Get(name string) (io.ReadCloser, error) {
+	resp, err := s.get(s.key(name), "")
+	if err != nil {
+		return nil, err
+	}
+	return resp.Body, nil
+}
+
+// Retention returns the Object Lock mode and retain-until date of an object
+func (s *S3) Retention(name string) (string, time.Time, error) {
+	resp, err := s.get(s.key(name), "retention")
+	if err != nil {
+		return "", time.Time{}, err
+	}
+	defer resp.Body.Close()
+
+	var retention struct {
+		Mode            string    `xml:"Mode"`
+		RetainUntilDate time.Time `xml:"RetainUntilDate"`
+	}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&retention); err != nil {
+		return "", time.Time{}, fmt.Errorf("invalid s3 retention response: %w", err)
+	}
+	return retention.Mode, retention.RetainUntilDate, nil
+}
+
+// get sends a signed GET for key, or for a subresource of it such as "retention"
+func (s *S3) get(key, subresource string) (*http.Response, error) {
+	target := s.objectURL(key)
+	if subresource != "" {
+		target += "?" + subresource + "="
+	}
+	req, err := http.NewRequest(http.MethodGet, target, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.URL.RawPath = awsEscapePath(req.URL.Path)
+	s.sign(req, emptyPayloadSHA256)
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("s3 download failed: %w", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		return nil, fmt.Errorf("s3 download of %s failed: %s: %s", key, resp.Status, strings.TrimSpace(string(body)))
+	}
+	return resp, nil
+}
+
+// emptyPayloadSHA256 is the payload hash of a request without a body
+const emptyPayloadSHA256 = "e3b0c44298fc1c149afbf4c8996fb92427ae41e4649b934ca495991b7852b855"
+
+// key returns the object key of a name below the prefix
+func (s *S3) key(name string) string {
+	if s.prefix != "" {
+		return s.prefix + "/" + name
+	}
+	return name
+}
+
+// objectURL returns the URL of an object key
+func (s *S3) objectURL(key string) string {
+	target := *s.endpoint
+	if s.pathStyle {
+		target.Path = path.Join("/", s.endpoint.Path, s.bucket, key)
+	} else {
+		target.Path = "/" + key
+	}
+	return target.String()
+}
+
+// Close is a no-op; S3 requests do not hold a connection open
+func (s *S3) Close() error {
+	return nil
+}
+
+// sign adds the Signature Version 4 authorization header to a request
+func (s *S3) sign(req *http.Request, payloadHash string) {
+	now := s.now().UTC()
+	amzDate := now.Format("20060102T150405Z")
+	day := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+
+	headers := map[string]string{"host": req.URL.Host}
+	for name, values := range req.Header {
+		headers[strings.ToLower(name)] = strings.TrimSpace(strings.Join(values, ","))
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var canonicalHeaders strings.Builder
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		awsEscapePath(req.URL.Path),
+		req.URL.Query().Encode(),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+
+	scope := day + "/" + s.region + "/s3/aws4_request"
+	requestHash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(requestHash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.secretKey), day)
+	key = hmacSHA256(key, s.region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.accessKeyID, scope, signedHeaders, signature))
+}
+
+// hmacSHA256 returns the HMAC-SHA256 of data under key
+func hmacSHA256(key []byte, data string) []byte {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(data))
+	return mac.Sum(nil)
+}
+
+// awsEscapePath URI-encodes each path segment as SigV4 requires: everything except
+// unreserved characters is percent-encoded
+func awsEscapePath(p string) string {
+	var b strings.Builder
+	for i := 0; i < len(p); i++ {
+		c := p[i]
+		if c == '/' || c == '-' || c == '_' || c == '.' || c == '~' ||
+			(c >= 'A' && c <= 'Z') || (c >= 'a' && c <= 'z') || (c >= '0' && c <= '9') {
+			b.WriteByte(c)
+		} else {
+			fmt.Fprintf(&b, "%%%02X", c)
+		}
+	}
+	return b.String()
+}
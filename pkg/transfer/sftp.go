@@ -0,0 +1,406 @@
+package transfer
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/egress"
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+	"golang.org/x/crypto/ssh"
+)
+
+// SFTP protocol version 3 packet types and flags (draft-ietf-secsh-filexfer-02)
+const (
+	sftpInit    = 1
+	sftpVersion = 2
+	sftpOpen    = 3
+	sftpClose   = 4
+	sftpWrite   = 6
+	sftpMkdir   = 14
+	sftpStat    = 17
+	sftpStatus  = 101
+	sftpHandle  = 102
+	sftpAttrs   = 105
+
+	sftpFlagWrite    = 0x02
+	sftpFlagCreate   = 0x08
+	sftpFlagTruncate = 0x10
+
+	sftpStatusOK = 0
+
+	// sftpChunkSize is the largest write payload every server is required to accept
+	sftpChunkSize = 32 * 1024
+
+	sftpTimeout = 30 * time.Second
+)
+
+// SFTP uploads files to a directory on an SFTP server. Requests are sent one at a
+// time, so an SFTP destination must not be used from several goroutines at once.
+type SFTP struct {
+	client  *ssh.Client
+	session *ssh.Session
+	in      io.WriteCloser
+	out     *bufio.Reader
+	base    string
+	nextID  uint32
+	madeDir map[string]bool
+}
+
+// DialSFTP connects to the SFTP server described by opts. The server key must match
+// opts.HostKey; connections to unknown servers are refused.
+func DialSFTP(opts Options) (*SFTP, error) {
+	if opts.Host == "" || opts.Username == "" {
+		return nil, errors.New("sftp host and username are required")
+	}
+
+	hostKeyCallback, err := pinnedHostKey(opts.HostKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var auth ssh.AuthMethod
+	if signer, err := ssh.ParsePrivateKey([]byte(opts.Secret)); err == nil {
+		auth = ssh.PublicKeys(signer)
+	} else {
+		auth = ssh.Password(opts.Secret)
+	}
+
+	port := opts.Port
+	if port == 0 {
+		port = 22
+	}
+
+	addr := net.JoinHostPort(opts.Host, strconv.Itoa(port))
+	var client *ssh.Client
+	err = resilience.Lookup("sftp", transferPolicy).Do(context.Background(), func(ctx context.Context) error {
+		conn, err := egress.Dial(ctx, "tcp", addr, sftpTimeout)
+		var denied *egress.DeniedError
+		if errors.As(err, &denied) {
+			return resilience.Permanent(err)
+		}
+		if err != nil {
+			return err
+		}
+
+		// Bound the handshake so an unresponsive server cannot stall the caller
+		conn.SetDeadline(time.Now().Add(sftpTimeout))
+		sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, &ssh.ClientConfig{
+			User:            opts.Username,
+			Auth:            []ssh.AuthMethod{auth},
+			HostKeyCallback: hostKeyCallback,
+		})
+		if err != nil {
+			conn.Close()
+			// A refused key or credential will not be accepted on retry
+			if strings.Contains(err.Error(), "unable to authenticate") || strings.Contains(err.Error(), "host key") {
+				return resilience.Permanent(err)
+			}
+			return err
+		}
+		conn.SetDeadline(time.Time{})
+		client = ssh.NewClient(sshConn, chans, reqs)
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("sftp connection failed: %w", err)
+	}
+
+	s, err := startSFTP(client)
+	if err != nil {
+		client.Close()
+		return nil, err
+	}
+	s.base = opts.Path
+	return s, nil
+}
+
+// startSFTP opens the sftp subsystem on an SSH connection and negotiates version 3
+func startSFTP(client *ssh.Client) (*SFTP, error) {
+	session, err := client.NewSession()
+	if err != nil {
+		return nil, err
+	}
+
+	in, err := session.StdinPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	out, err := session.StdoutPipe()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if err := session.RequestSubsystem("sftp"); err != nil {
+		session.Close()
+		return nil, fmt.Errorf("sftp subsystem unavailable: %w", err)
+	}
+
+	s := &SFTP{
+		client:  client,
+		session: session,
+		in:      in,
+		out:     bufio.NewReader(out),
+		madeDir: make(map[string]bool),
+	}
+
+	init := make([]byte, 0, 5)
+	init = append(init, sftpInit)
+	init = binary.BigEndian.AppendUint32(init, 3)
+	if err := s.send(init); err != nil {
+		session.Close()
+		return nil, err
+	}
+	kind, _, err := s.receive()
+	if err != nil {
+		session.Close()
+		return nil, err
+	}
+	if kind != sftpVersion {
+		session.Close()
+		return nil, fmt.Errorf("unexpected sftp packet type %d during handshake", kind)
+	}
+
+	return s, nil
+}
+
+// Put uploads r to name below the base path, creating missing directories
+func (s *SFTP) Put(name string, r io.Reader) (int64, error) {
+	target := path.Join(s.base, name)
+	if err := s.mkdirAll(path.Dir(target)); err != nil {
+		return 0, err
+	}
+
+	handle, err := s.open(target)
+	if err != nil {
+		return 0, err
+	}
+
+	var written int64
+	buf := make([]byte, sftpChunkSize)
+	for {
+		n, readErr := io.ReadFull(r, buf)
+		if n > 0 {
+			if err := s.write(handle, uint64(written), buf[:n]); err != nil {
+				s.closeHandle(handle)
+				return written, err
+			}
+			written += int64(n)
+		}
+		if readErr == io.EOF || readErr == io.ErrUnexpectedEOF {
+			break
+		}
+		if readErr != nil {
+			s.closeHandle(handle)
+			return written, readErr
+		}
+	}
+
+	return written, s.closeHandle(handle)
+}
+
+// Close ends the sftp session and the SSH connection
+func (s *SFTP) Close() error {
+	s.in.Close()
+	s.session.Close()
+	return s.client.Close()
+}
+
+// open creates or truncates a remote file for writing and returns its handle
+func (s *SFTP) open(name string) (string, error) {
+	id, packet := s.request(sftpOpen)
+	packet = appendString(packet, name)
+	packet = binary.BigEndian.AppendUint32(packet, sftpFlagWrite|sftpFlagCreate|sftpFlagTruncate)
+	packet = binary.BigEndian.AppendUint32(packet, 0) // No attributes
+
+	kind, payload, err := s.roundTrip(id, packet)
+	if err != nil {
+		return "", err
+	}
+	switch kind {
+	case sftpHandle:
+		handle, _, ok := readString(payload)
+		if !ok {
+			return "", errors.New("malformed sftp handle")
+		}
+		return handle, nil
+	case sftpStatus:
+		return "", statusError("open "+name, payload)
+	default:
+		return "", fmt.Errorf("unexpected sftp packet type %d", kind)
+	}
+}
+
+// write writes one chunk of a file at the given offset
+func (s *SFTP) write(handle string, offset uint64, data []byte) error {
+	id, packet := s.request(sftpWrite)
+	packet = appendString(packet, handle)
+	packet = binary.BigEndian.AppendUint64(packet, offset)
+	packet = appendString(packet, string(data))
+	return s.expectOK(id, packet, "write")
+}
+
+// closeHandle closes a remote file, which commits it on most servers
+func (s *SFTP) closeHandle(handle string) error {
+	id, packet := s.request(sftpClose)
+	packet = appendString(packet, handle)
+	return s.expectOK(id, packet, "close")
+}
+
+// mkdirAll creates dir and its parents. Existing directories are detected with a
+// stat, since version 3 status codes do not distinguish "exists" from other failures.
+func (s *SFTP) mkdirAll(dir string) error {
+	if dir == "." || dir == "/" || dir == "" || s.madeDir[dir] {
+		return nil
+	}
+	if err := s.mkdirAll(path.Dir(dir)); err != nil {
+		return err
+	}
+
+	id, packet := s.request(sftpStat)
+	packet = appendString(packet, dir)
+	kind, payload, err := s.roundTrip(id, packet)
+	if err != nil {
+		return err
+	}
+	if kind != sftpAttrs {
+		id, packet = s.request(sftpMkdir)
+		packet = appendString(packet, dir)
+		packet = binary.BigEndian.AppendUint32(packet, 0)
+		if err := s.expectOK(id, packet, "mkdir "+dir); err != nil {
+			return err
+		}
+	} else if len(payload) < 4 {
+		return errors.New("malformed sftp attributes")
+	}
+
+	s.madeDir[dir] = true
+	return nil
+}
+
+// request starts a packet of the given type with a fresh request ID
+func (s *SFTP) request(kind byte) (uint32, []byte) {
+	s.nextID++
+	packet := []byte{kind}
+	return s.nextID, binary.BigEndian.AppendUint32(packet, s.nextID)
+}
+
+// expectOK sends a request that is answered with a status and checks for success
+func (s *SFTP) expectOK(id uint32, packet []byte, op string) error {
+	kind, payload, err := s.roundTrip(id, packet)
+	if err != nil {
+		return err
+	}
+	if kind != sftpStatus {
+		return fmt.Errorf("unexpected sftp packet type %d", kind)
+	}
+	return statusError(op, payload)
+}
+
+// roundTrip sends a request and returns the response payload after the request ID
+func (s *SFTP) roundTrip(id uint32, packet []byte) (byte, []byte, error) {
+	if err := s.send(packet); err != nil {
+		return 0, nil, err
+	}
+	kind, payload, err := s.receive()
+	if err != nil {
+		return 0, nil, err
+	}
+	if len(payload) < 4 || binary.BigEndian.Uint32(payload) != id {
+		return 0, nil, errors.New("sftp response does not match request")
+	}
+	return kind, payload[4:], nil
+}
+
+// send writes a length-prefixed packet
+func (s *SFTP) send(packet []byte) error {
+	frame := binary.BigEndian.AppendUint32(make([]byte, 0, len(packet)+4), uint32(len(packet)))
+	_, err := s.in.Write(append(frame, packet...))
+	return err
+}
+
+// receive reads one packet and returns its type and payload
+func (s *SFTP) receive() (byte, []byte, error) {
+	var header [4]byte
+	if _, err := io.ReadFull(s.out, header[:]); err != nil {
+		return 0, nil, fmt.Errorf("sftp read failed: %w", err)
+	}
+	length := binary.BigEndian.Uint32(header[:])
+	if length == 0 || length > 256*1024 {
+		return 0, nil, fmt.Errorf("invalid sftp packet length %d", length)
+	}
+
+	packet := make([]byte, length)
+	if _, err := io.ReadFull(s.out, packet); err != nil {
+		return 0, nil, fmt.Errorf("sftp read failed: %w", err)
+	}
+	return packet[0], packet[1:], nil
+}
+
+// statusError converts a status payload into an error, or nil on success
+func statusError(op string, payload []byte) error {
+	if len(payload) < 4 {
+		return errors.New("malformed sftp status")
+	}
+	code := binary.BigEndian.Uint32(payload)
+	if code == sftpStatusOK {
+		return nil
+	}
+
+	message, _, _ := readString(payload[4:])
+	if message == "" {
+		message = "status " + strconv.Itoa(int(code))
+	}
+	return fmt.Errorf("sftp %s failed: %s", op, message)
+}
+
+// pinnedHostKey returns a callback accepting only the configured server key, given
+// either as an authorized_keys line or as its SHA256 fingerprint
+func pinnedHostKey(hostKey string) (ssh.HostKeyCallback, error) {
+	hostKey = strings.TrimSpace(hostKey)
+	if hostKey == "" {
+		return nil, errors.New("sftp host key is required")
+	}
+
+	if strings.HasPrefix(hostKey, "SHA256:") {
+		return func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			if ssh.FingerprintSHA256(key) != hostKey {
+				return fmt.Errorf("sftp host key mismatch: got %s", ssh.FingerprintSHA256(key))
+			}
+			return nil
+		}, nil
+	}
+
+	key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(hostKey))
+	if err != nil {
+		return nil, fmt.Errorf("invalid sftp host key: %w", err)
+	}
+	return ssh.FixedHostKey(key), nil
+}
+
+// appendString appends an SSH string (uint32 length followed by the bytes)
+func appendString(b []byte, s string) []byte {
+	b = binary.BigEndian.AppendUint32(b, uint32(len(s)))
+	return append(b, s...)
+}
+
+// readString reads an SSH string and returns the remainder of the buffer
+func readString(b []byte) (string, []byte, bool) {
+	if len(b) < 4 {
+		return "", nil, false
+	}
+	n := binary.BigEndian.Uint32(b)
+	if uint32(len(b)-4) < n {
+		return "", nil, false
+	}
+	return string(b[4 : 4+n]), b[4+n:], true
+}
@@ -0,0 +1,61 @@
+package transfer
+
+import (
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/hillmatthew2000/HealthHub/pkg/resilience"
+)
+
+// transferPolicy guards SFTP connections and S3 uploads. Uploads can take minutes, so
+// attempts are bounded by the transfer's own timeouts rather than a policy timeout.
+var transferPolicy = resilience.Policy{
+	MaxAttempts:      3,
+	BaseDelay:        time.Second,
+	MaxDelay:         10 * time.Second,
+	FailureThreshold: 5,
+	OpenTimeout:      time.Minute,
+}
+
+// Destination is a remote location export files are pushed to
+type Destination interface {
+	// Put uploads the contents of r as name below the destination's base path
+	Put(name string, r io.Reader) (int64, error)
+	// Close releases the connection to the destination
+	Close() error
+}
+
+// Options configures a destination. Secret holds the SFTP password or private key,
+// or the S3 secret access key.
+type Options struct {
+	Type string
+
+	// SFTP
+	Host     string
+	Port     int
+	Username string
+	HostKey  string // authorized_keys line or SHA256 fingerprint of the server key
+	Path     string
+
+	// S3
+	Bucket      string
+	Region      string
+	Endpoint    string // Overrides the AWS endpoint, e.g. for S3-compatible stores
+	Prefix      string
+	AccessKeyID string
+
+	Secret string
+}
+
+// Open connects to the destination described by opts
+func Open(opts Options) (Destination, error) {
+	switch opts.Type {
+	case "sftp":
+		return DialSFTP(opts)
+	case "s3":
+		return NewS3(opts)
+	default:
+		return nil, fmt.Errorf("unsupported destination type %q", opts.Type)
+	}
+}
@@ -0,0 +1,259 @@
+// Package ucum converts between units written as UCUM codes (https://ucum.org), the
+// unit system FHIR quantities use. It covers the metric prefixes and the clinical
+// units laboratories report in; codes using other units fail to parse.
+package ucum
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// Base dimensions. Substance amounts and international units are kept apart from
+// mass: converting mmol to mg needs the molar mass of the substance.
+const (
+	length = iota
+	mass
+	duration
+	temperature
+	amount
+	internationalUnit
+	dimensions
+)
+
+// Unit is a parsed unit: a factor to the base units of its dimension and, for
+// temperature scales, an offset
+type Unit struct {
+	factor float64
+	offset float64
+	dims   [dimensions]int
+}
+
+type atom struct {
+	factor float64
+	offset float64
+	dims   [dimensions]int
+	metric bool // Accepts prefixes
+}
+
+func dims(d int, exp int) [dimensions]int {
+	var v [dimensions]int
+	v[d] = exp
+	return v
+}
+
+var (
+	pressure = [dimensions]int{length: -1, mass: 1, duration: -2}
+	volume   = dims(length, 3)
+)
+
+// atoms lists the supported units by their case-sensitive UCUM code
+var atoms = map[string]atom{
+	"1":   {factor: 1},
+	"%":   {factor: 1e-2},
+	"m":   {factor: 1, dims: dims(length, 1), metric: true},
+	"g":   {factor: 1, dims: dims(mass, 1), metric: true},
+	"s":   {factor: 1, dims: dims(duration, 1), metric: true},
+	"K":   {factor: 1, dims: dims(temperature, 1), metric: true},
+	"mol": {factor: 1, dims: dims(amount, 1), metric: true},
+	"eq":  {factor: 1, dims: dims(amount, 1), metric: true},
+	"L":   {factor: 1e-3, dims: volume, metric: true},
+	"l":   {factor: 1e-3, dims: volume, metric: true},
+	"min": {factor: 60, dims: dims(duration, 1)},
+	"h":   {factor: 3600, dims: dims(duration, 1)},
+	"d":   {factor: 86400, dims: dims(duration, 1)},
+	"wk":  {factor: 604800, dims: dims(duration, 1)},
+	"mo":  {factor: 2629800, dims: dims(duration, 1)},
+	"a":   {factor: 31557600, dims: dims(duration, 1)},
+	"U":   {factor: 1e-6 / 60, dims: [dimensions]int{amount: 1, duration: -1}, metric: true},
+	"kat": {factor: 1, dims: [dimensions]int{amount: 1, duration: -1}, metric: true},
+	"Pa":  {factor: 1000, dims: pressure, metric: true},
+	"bar": {factor: 1e8, dims: pressure, metric: true},
+	"Cel": {factor: 1, offset: 273.15, dims: dims(temperature, 1)},
+
+	"m[Hg]":    {factor: 133322387.415, dims: pressure, metric: true},
+	"m[H2O]":   {factor: 9806650, dims: pressure, metric: true},
+	"[degF]":   {factor: 5.0 / 9, offset: 459.67 * 5 / 9, dims: dims(temperature, 1)},
+	"[IU]":     {factor: 1, dims: dims(internationalUnit, 1), metric: true},
+	"[iU]":     {factor: 1, dims: dims(internationalUnit, 1), metric: true},
+	"[ppm]":    {factor: 1e-6},
+	"[ppb]":    {factor: 1e-9},
+	"[lb_av]":  {factor: 453.59237, dims: dims(mass, 1)},
+	"[oz_av]":  {factor: 28.349523125, dims: dims(mass, 1)},
+	"[in_i]":   {factor: 0.0254, dims: dims(length, 1)},
+	"[ft_i]":   {factor: 0.3048, dims: dims(length, 1)},
+	"[mi_i]":   {factor: 1609.344, dims: dims(length, 1)},
+	"[pt_us]":  {factor: 4.73176473e-4, dims: volume},
+	"[foz_us]": {factor: 2.95735295625e-5, dims: volume},
+}
+
+// prefixes lists the metric prefixes by UCUM code
+var prefixes = map[string]float64{
+	"G": 1e9, "M": 1e6, "k": 1e3, "h": 1e2, "da": 1e1,
+	"d": 1e-1, "c": 1e-2, "m": 1e-3, "u": 1e-6, "n": 1e-9, "p": 1e-12, "f": 1e-15,
+}
+
+// Parse parses a UCUM unit code such as "mmol/L", "mg/dL", "10*9/L", "/min" or
+// "kg.m-2". Annotations in braces are ignored, so "{beats}/min" is "/min".
+func Parse(code string) (Unit, error) {
+	code = strings.TrimSpace(code)
+	if code == "" {
+		return Unit{}, fmt.Errorf("empty unit")
+	}
+	if strings.ContainsAny(code, "() ") {
+		return Unit{}, fmt.Errorf("unsupported unit %q", code)
+	}
+
+	u := Unit{factor: 1}
+	special := false
+	terms := 0
+	sign := 1
+	rest := code
+	if strings.HasPrefix(rest, "/") {
+		sign = -1
+		rest = rest[1:]
+	}
+	for {
+		end := strings.IndexAny(rest, "./")
+		term := rest
+		if end >= 0 {
+			term = rest[:end]
+		}
+
+		t, err := parseTerm(term)
+		if err != nil {
+			return Unit{}, fmt.Errorf("unsupported unit %q: %w", code, err)
+		}
+		if t.offset != 0 {
+			if sign < 0 {
+				return Unit{}, fmt.Errorf("unsupported unit %q: temperature scales cannot be divided by", code)
+			}
+			special = true
+			u.offset = t.offset
+		}
+		u.factor *= math.Pow(t.factor, float64(sign))
+		for i := range u.dims {
+			u.dims[i] += sign * t.dims[i]
+		}
+		terms++
+
+		if end < 0 {
+			break
+		}
+		if rest[end] == '/' {
+			sign = -1
+		} else {
+			sign = 1
+		}
+		rest = rest[end+1:]
+	}
+
+	// Temperature scales with an offset cannot be combined with other units
+	if special && terms > 1 {
+		return Unit{}, fmt.Errorf("unsupported unit %q: temperature scales cannot be combined", code)
+	}
+	return u, nil
+}
+
+// parseTerm parses one factor of a unit: an atom with optional prefix and exponent,
+// a power of ten such as 10*3, a number or an annotation
+func parseTerm(term string) (Unit, error) {
+	// Strip annotations; a bare annotation is the unity
+	for {
+		open := strings.Index(term, "{")
+		if open < 0 {
+			break
+		}
+		end := strings.Index(term[open:], "}")
+		if end < 0 {
+			return Unit{}, fmt.Errorf("unterminated annotation")
+		}
+		term = term[:open] + term[open+end+1:]
+	}
+	if term == "" {
+		return Unit{factor: 1}, nil
+	}
+
+	for _, sep := range []string{"10*", "10^"} {
+		if strings.HasPrefix(term, sep) {
+			exp, err := strconv.Atoi(term[len(sep):])
+			if err != nil {
+				return Unit{}, fmt.Errorf("invalid power of ten %q", term)
+			}
+			return Unit{factor: math.Pow(10, float64(exp))}, nil
+		}
+	}
+	if n, err := strconv.Atoi(term); err == nil && n > 0 {
+		return Unit{factor: float64(n)}, nil
+	}
+
+	// A trailing integer is the exponent, e.g. m2 or s-1
+	exp := 1
+	if i := strings.LastIndexFunc(term, func(r rune) bool { return (r < '0' || r > '9') && r != '-' && r != '+' }); i < len(term)-1 {
+		n, err := strconv.Atoi(term[i+1:])
+		if err != nil || n == 0 {
+			return Unit{}, fmt.Errorf("invalid exponent in %q", term)
+		}
+		exp = n
+		term = term[:i+1]
+	}
+
+	a, err := lookupAtom(term)
+	if err != nil {
+		return Unit{}, err
+	}
+	if a.offset != 0 && exp != 1 {
+		return Unit{}, fmt.Errorf("temperature scales cannot be raised to a power")
+	}
+
+	u := Unit{factor: math.Pow(a.factor, float64(exp)), offset: a.offset}
+	for i := range u.dims {
+		u.dims[i] = a.dims[i] * exp
+	}
+	return u, nil
+}
+
+// lookupAtom finds a unit atom, with or without a metric prefix. Exact atoms are
+// matched first, so "h" is hours rather than the hecto prefix.
+func lookupAtom(code string) (atom, error) {
+	if a, ok := atoms[code]; ok {
+		return a, nil
+	}
+	for prefix, factor := range prefixes {
+		if !strings.HasPrefix(code, prefix) {
+			continue
+		}
+		if a, ok := atoms[code[len(prefix):]]; ok && a.metric {
+			a.factor *= factor
+			return a, nil
+		}
+	}
+	return atom{}, fmt.Errorf("unknown unit %q", code)
+}
+
+// Compatible reports whether values in u can be converted to v
+func (u Unit) Compatible(v Unit) bool {
+	return u.dims == v.dims
+}
+
+// ConvertTo converts a value in u to unit v
+func (u Unit) ConvertTo(value float64, v Unit) (float64, error) {
+	if !u.Compatible(v) {
+		return 0, fmt.Errorf("units are not commensurable")
+	}
+	return (value*u.factor + u.offset - v.offset) / v.factor, nil
+}
+
+// Convert converts a value between two unit codes
+func Convert(value float64, from, to string) (float64, error) {
+	fromUnit, err := Parse(from)
+	if err != nil {
+		return 0, err
+	}
+	toUnit, err := Parse(to)
+	if err != nil {
+		return 0, err
+	}
+	return fromUnit.ConvertTo(value, toUnit)
+}